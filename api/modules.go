@@ -0,0 +1,140 @@
+package api
+
+import (
+	"sort"
+
+	"github.com/activecm/rita/pkg/beacon"
+	"github.com/activecm/rita/pkg/beaconproxy"
+	"github.com/activecm/rita/pkg/beaconsni"
+	"github.com/activecm/rita/pkg/blacklist"
+	"github.com/activecm/rita/pkg/dnstunnel"
+	"github.com/activecm/rita/pkg/explodeddns"
+	resultfilter "github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/pkg/threatscore"
+	"github.com/activecm/rita/pkg/uconn"
+	"github.com/activecm/rita/pkg/useragent"
+	"github.com/activecm/rita/resources"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// longConnThresh is the minimum duration in seconds of the long connections served
+const longConnThresh = 60 // 1 minute
+
+// module describes how the results of an analysis module are queried. The API appends
+// its own filtering, sorting, and pagination stages to the module's pipeline.
+type module struct {
+	// collection returns the name of the collection the pipeline runs against
+	collection func(res *resources.Resources) string
+	// pipeline builds the aggregation pipeline which produces every result matching filt
+	pipeline func(res *resources.Resources, filt resultfilter.Filter) ([]bson.M, error)
+	// results returns a pointer to an empty slice of the module's result type
+	results func() interface{}
+	// filters lists the result filters the pipeline applies, e.g. src or min_score
+	filters []string
+}
+
+// modules maps the names used in the API paths to the analysis results they serve
+var modules = map[string]module{
+	"beacons": {
+		collection: func(res *resources.Resources) string { return res.Config.T.Beacon.BeaconTable },
+		pipeline: func(res *resources.Resources, filt resultfilter.Filter) ([]bson.M, error) {
+			return beacon.ResultsQuery(res, 0, filt)
+		},
+		results: func() interface{} { return &[]beacon.Result{} },
+		filters: []string{"src", "dst", "min_score", "min_conns", "since", "until"},
+	},
+	"beacons-proxy": {
+		collection: func(res *resources.Resources) string { return res.Config.T.BeaconProxy.BeaconProxyTable },
+		pipeline: func(res *resources.Resources, filt resultfilter.Filter) ([]bson.M, error) {
+			return beaconproxy.ResultsQuery(res, 0, filt)
+		},
+		results: func() interface{} { return &[]beaconproxy.Result{} },
+		filters: []string{"src", "fqdn", "min_score", "min_conns", "since", "until"},
+	},
+	"beacons-sni": {
+		collection: func(res *resources.Resources) string { return res.Config.T.BeaconSNI.BeaconSNITable },
+		pipeline: func(res *resources.Resources, filt resultfilter.Filter) ([]bson.M, error) {
+			return beaconsni.ResultsQuery(res, 0, filt)
+		},
+		results: func() interface{} { return &[]beaconsni.Result{} },
+		filters: []string{"src", "fqdn", "min_score", "min_conns", "since", "until"},
+	},
+	"strobes": {
+		collection: func(res *resources.Resources) string { return res.Config.T.Structure.UniqueConnTable },
+		pipeline: func(res *resources.Resources, filt resultfilter.Filter) ([]bson.M, error) {
+			return beacon.StrobeResultsQuery(-1, filt)
+		},
+		results: func() interface{} { return &[]beacon.StrobeResult{} },
+		filters: []string{"src", "dst", "min_conns"},
+	},
+	"dns": {
+		collection: func(res *resources.Resources) string { return res.Config.T.DNS.ExplodedDNSTable },
+		pipeline: func(res *resources.Resources, filt resultfilter.Filter) ([]bson.M, error) {
+			return explodeddns.ResultsQuery(filt)
+		},
+		results: func() interface{} { return &[]explodeddns.Result{} },
+		filters: []string{"fqdn"},
+	},
+	"dns-tunnels": {
+		collection: func(res *resources.Resources) string { return res.Config.T.DNS.DNSTunnelTable },
+		pipeline: func(res *resources.Resources, filt resultfilter.Filter) ([]bson.M, error) {
+			return dnstunnel.ResultsQuery(filt)
+		},
+		results: func() interface{} { return &[]dnstunnel.Result{} },
+		filters: []string{"fqdn", "min_score"},
+	},
+	"bl-source-ips": {
+		collection: func(res *resources.Resources) string { return res.Config.T.Structure.HostTable },
+		pipeline: func(res *resources.Resources, filt resultfilter.Filter) ([]bson.M, error) {
+			return blacklist.SrcIPResultsQuery("conn_count"), nil
+		},
+		results: func() interface{} { return &[]blacklist.IPResult{} },
+	},
+	"bl-dest-ips": {
+		collection: func(res *resources.Resources) string { return res.Config.T.Structure.HostTable },
+		pipeline: func(res *resources.Resources, filt resultfilter.Filter) ([]bson.M, error) {
+			return blacklist.DstIPResultsQuery("conn_count"), nil
+		},
+		results: func() interface{} { return &[]blacklist.IPResult{} },
+	},
+	"bl-hostnames": {
+		collection: func(res *resources.Resources) string { return res.Config.T.DNS.HostnamesTable },
+		pipeline: func(res *resources.Resources, filt resultfilter.Filter) ([]bson.M, error) {
+			return blacklist.HostnameResultsQuery("conn_count"), nil
+		},
+		results: func() interface{} { return &[]blacklist.HostnameResult{} },
+	},
+	"useragents": {
+		collection: func(res *resources.Resources) string { return res.Config.T.UserAgent.UserAgentTable },
+		pipeline: func(res *resources.Resources, filt resultfilter.Filter) ([]bson.M, error) {
+			return useragent.ResultsQuery(1), nil
+		},
+		results: func() interface{} { return &[]useragent.Result{} },
+	},
+	"long-connections": {
+		collection: func(res *resources.Resources) string { return res.Config.T.Structure.UniqueConnTable },
+		pipeline: func(res *resources.Resources, filt resultfilter.Filter) ([]bson.M, error) {
+			return uconn.LongConnResultsQuery(longConnThresh, filt)
+		},
+		results: func() interface{} { return &[]uconn.LongConnResult{} },
+		filters: []string{"src", "dst", "since", "until"},
+	},
+	"threat-hunt": {
+		collection: func(res *resources.Resources) string { return res.Config.T.Structure.HostTable },
+		pipeline: func(res *resources.Resources, filt resultfilter.Filter) ([]bson.M, error) {
+			return threatscore.ResultsQuery(filt)
+		},
+		results: func() interface{} { return &[]threatscore.Result{} },
+		filters: []string{"src", "min_score"},
+	},
+}
+
+// moduleNames returns the names of the served modules in alphabetical order
+func moduleNames() []string {
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,455 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	resultfilter "github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/util"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// defaultPageSize is the number of results returned when no limit is given
+	defaultPageSize = 50
+	// maxPageSize is the largest number of results returned in a single page
+	maxPageSize = 1000
+)
+
+// resultFilters are the parameters which narrow down the results the same way as
+// the filtering flags of the show-* commands
+var resultFilters = []string{"src", "dst", "min_score", "min_conns", "since", "until", "fqdn"}
+
+type (
+	// query describes how the results of a module should be filtered, sorted, and paginated
+	query struct {
+		search     string              // case insensitive text which must appear in a string field
+		filt       resultfilter.Filter // result filters applied by the module
+		filtered   []string            // names of the result filters given
+		filters    []filter            // field comparisons which must all match
+		sortField  string              // dotted path of the field to sort by
+		descending bool                // sort from largest to smallest
+		offset     int                 // number of results to skip
+		limit      int                 // maximum number of results to return
+	}
+
+	// filter compares the field at a dotted path (e.g. ts.score) against a value
+	filter struct {
+		field string
+		op    string // one of eq, gt, gte, lt, lte
+		value string
+	}
+
+	// page holds a single page of filtered and sorted results
+	page struct {
+		Total   int                      `json:"total"`
+		Offset  int                      `json:"offset"`
+		Limit   int                      `json:"limit"`
+		Results []map[string]interface{} `json:"results"`
+	}
+
+	// pagedResults holds the output of the stages which count and paginate the results
+	pagedResults struct {
+		Total []struct {
+			Count int `bson:"count"`
+		} `bson:"total"`
+		Results bson.RawValue `bson:"results"`
+	}
+)
+
+// comparisons maps the filter operators to their MongoDB query operators
+var comparisons = map[string]string{"gt": "$gt", "gte": "$gte", "lt": "$lt", "lte": "$lte"}
+
+// parseQuery reads the query from URL parameters. The reserved parameters are
+// q (search text), sort (field), order (asc or desc), offset, limit, and the result
+// filters (src, dst, min_score, min_conns, since, until, and fqdn). Every other
+// parameter filters on a field, e.g. score[gte]=0.8.
+func parseQuery(values url.Values) (query, error) {
+	q := query{
+		search:    values.Get("q"),
+		sortField: values.Get("sort"),
+		limit:     defaultPageSize,
+	}
+
+	switch strings.ToLower(values.Get("order")) {
+	case "", "desc":
+		q.descending = true
+	case "asc":
+		q.descending = false
+	default:
+		return q, fmt.Errorf("invalid order %q: must be asc or desc", values.Get("order"))
+	}
+
+	var err error
+	if offset := values.Get("offset"); offset != "" {
+		q.offset, err = strconv.Atoi(offset)
+		if err != nil || q.offset < 0 {
+			return q, fmt.Errorf("invalid offset %q", offset)
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		q.limit, err = strconv.Atoi(limit)
+		if err != nil || q.limit < 1 || q.limit > maxPageSize {
+			return q, fmt.Errorf("invalid limit %q: must be between 1 and %d", limit, maxPageSize)
+		}
+	}
+
+	err = q.parseResultFilters(values)
+	if err != nil {
+		return q, err
+	}
+
+	for key, vals := range values {
+		switch key {
+		case "q", "sort", "order", "offset", "limit":
+			continue
+		}
+		if util.StringInSlice(key, resultFilters) {
+			continue
+		}
+		f := filter{field: key, op: "eq"}
+		if open := strings.Index(key, "["); open > 0 && strings.HasSuffix(key, "]") {
+			f.field = key[:open]
+			f.op = key[open+1 : len(key)-1]
+		}
+		switch f.op {
+		case "eq", "gt", "gte", "lt", "lte":
+		default:
+			return q, fmt.Errorf("invalid filter operator %q: must be one of eq, gt, gte, lt, lte", f.op)
+		}
+		for _, val := range vals {
+			f.value = val
+			if f.op != "eq" {
+				if _, err := strconv.ParseFloat(val, 64); err != nil {
+					return q, fmt.Errorf("invalid value %q for %s: must be a number", val, key)
+				}
+			}
+			q.filters = append(q.filters, f)
+		}
+	}
+	// apply the filters in a consistent order
+	sort.SliceStable(q.filters, func(i, j int) bool {
+		return q.filters[i].field < q.filters[j].field
+	})
+
+	return q, nil
+}
+
+// parseResultFilters reads the result filters from URL parameters. src and dst may
+// be repeated or comma separated.
+func (q *query) parseResultFilters(values url.Values) error {
+	var err error
+	for _, name := range resultFilters {
+		value := values.Get(name)
+		if value == "" {
+			continue
+		}
+		q.filtered = append(q.filtered, name)
+
+		switch name {
+		case "src", "dst":
+			var hosts []string
+			for _, val := range values[name] {
+				for _, host := range strings.Split(val, ",") {
+					if host = strings.TrimSpace(host); host != "" {
+						hosts = append(hosts, host)
+					}
+				}
+			}
+			if name == "src" {
+				q.filt.Src = hosts
+			} else {
+				q.filt.Dst = hosts
+			}
+		case "min_score":
+			q.filt.MinScore, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid min_score %q: must be a number", value)
+			}
+		case "min_conns":
+			q.filt.MinConns, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid min_conns %q: must be a whole number", value)
+			}
+		case "since":
+			q.filt.Since, err = resultfilter.ParseTime(value)
+			if err != nil {
+				return fmt.Errorf("since: %v", err)
+			}
+		case "until":
+			q.filt.Until, err = resultfilter.ParseTime(value)
+			if err != nil {
+				return fmt.Errorf("until: %v", err)
+			}
+		case "fqdn":
+			q.filt.FQDN = value
+		}
+	}
+	if q.filt.Since > 0 && q.filt.Until > 0 && q.filt.Since > q.filt.Until {
+		return fmt.Errorf("since must not be later than until")
+	}
+	return nil
+}
+
+// stages builds the aggregation stages which filter, sort, and paginate the output of
+// a module's pipeline. resultType is the type of the module's results, which maps the
+// JSON field names used in the query to the document fields. supported lists the
+// result filters the module's pipeline applies.
+func (q query) stages(resultType reflect.Type, supported []string) ([]bson.M, error) {
+	for _, name := range q.filtered {
+		if !util.StringInSlice(name, supported) {
+			return nil, fmt.Errorf("these results can't be filtered by %s", name)
+		}
+	}
+
+	var stages []bson.M
+
+	var predicates []bson.M
+	for _, f := range q.filters {
+		predicate, err := f.predicate(resultType)
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, predicate)
+	}
+
+	if q.search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(q.search), Options: "i"}
+		var matches []bson.M
+		for _, field := range searchFields(resultType, "") {
+			matches = append(matches, bson.M{field: pattern})
+		}
+		predicates = append(predicates, bson.M{"$or": matches})
+	}
+
+	if len(predicates) > 0 {
+		stages = append(stages, bson.M{"$match": bson.M{"$and": predicates}})
+	}
+
+	var results []bson.M
+	if q.sortField != "" {
+		field, _, err := bsonField(resultType, q.sortField)
+		if err != nil {
+			return nil, err
+		}
+		direction := 1
+		if q.descending {
+			direction = -1
+		}
+		// break ties so that the pages don't overlap
+		results = append(results, bson.M{"$sort": bson.D{{Key: field, Value: direction}, {Key: "_id", Value: 1}}})
+	}
+	results = append(results, bson.M{"$skip": q.offset}, bson.M{"$limit": q.limit})
+
+	stages = append(stages, bson.M{"$facet": bson.M{
+		"total":   []bson.M{{"$count": "count"}},
+		"results": results,
+	}})
+
+	return stages, nil
+}
+
+// page decodes the output of the pagination stages into a page of results.
+// results is a pointer to an empty slice of the module's result type.
+func (q query) page(paged pagedResults, results interface{}) (page, error) {
+	err := paged.Results.Unmarshal(results)
+	if err != nil {
+		return page{}, err
+	}
+
+	records, err := toRecords(results)
+	if err != nil {
+		return page{}, err
+	}
+
+	total := 0
+	if len(paged.Total) > 0 {
+		total = paged.Total[0].Count
+	}
+	return page{Total: total, Offset: q.offset, Limit: q.limit, Results: records}, nil
+}
+
+// predicate builds the MongoDB query predicate for the filter
+func (f filter) predicate(resultType reflect.Type) (bson.M, error) {
+	field, kind, err := bsonField(resultType, f.field)
+	if err != nil {
+		return nil, err
+	}
+
+	numeric := false
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		numeric = true
+	}
+
+	if f.op != "eq" {
+		if !numeric {
+			return nil, fmt.Errorf("%s is not a numeric field", f.field)
+		}
+		target, _ := strconv.ParseFloat(f.value, 64)
+		return bson.M{field: bson.M{comparisons[f.op]: target}}, nil
+	}
+
+	switch {
+	case numeric:
+		target, err := strconv.ParseFloat(f.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s: must be a number", f.value, f.field)
+		}
+		return bson.M{field: target}, nil
+	case kind == reflect.Bool:
+		target, err := strconv.ParseBool(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s: must be true or false", f.value, f.field)
+		}
+		return bson.M{field: target}, nil
+	case kind == reflect.String:
+		// strings are compared case insensitively
+		return bson.M{field: primitive.Regex{Pattern: "^" + regexp.QuoteMeta(f.value) + "$", Options: "i"}}, nil
+	}
+	return nil, fmt.Errorf("%s can't be compared to a value", f.field)
+}
+
+// bsonField finds the document field holding the result field at a dotted JSON path
+// (e.g. ts.score) along with the kind of value it holds. Arrays hold the kind of their items.
+func bsonField(resultType reflect.Type, path string) (string, reflect.Kind, error) {
+	var fields []string
+	current := resultType
+	for _, name := range strings.Split(path, ".") {
+		current = itemType(current)
+		if current.Kind() != reflect.Struct {
+			return "", reflect.Invalid, fmt.Errorf("unknown field %s", path)
+		}
+		names, fieldType, ok := findField(current, name)
+		if !ok {
+			return "", reflect.Invalid, fmt.Errorf("unknown field %s", path)
+		}
+		fields = append(fields, names...)
+		current = fieldType
+	}
+
+	for _, field := range fields {
+		if field == "-" {
+			return "", reflect.Invalid, fmt.Errorf("%s can't be used to filter or sort results", path)
+		}
+	}
+	return strings.Join(fields, "."), itemType(current).Kind(), nil
+}
+
+// findField finds the struct field with the given JSON name, including the fields of
+// embedded structs. The names of the document fields leading to it are returned, which
+// are empty if the field is inlined into its parent document.
+func findField(structType reflect.Type, name string) ([]string, reflect.Type, bool) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		jsonName := tagName(field.Tag.Get("json"))
+		bsonName, inline := bsonTag(field)
+
+		// the fields of embedded structs are promoted in JSON
+		if field.Anonymous && jsonName == "" {
+			names, fieldType, ok := findField(itemType(field.Type), name)
+			if !ok {
+				continue
+			}
+			if !inline {
+				names = append([]string{bsonName}, names...)
+			}
+			return names, fieldType, true
+		}
+
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+		if jsonName != name {
+			continue
+		}
+		if inline {
+			return nil, field.Type, true
+		}
+		return []string{bsonName}, field.Type, true
+	}
+	return nil, nil, false
+}
+
+// searchFields lists the document fields holding strings in the result type, which
+// are searched by the q parameter
+func searchFields(resultType reflect.Type, prefix string) []string {
+	var fields []string
+	structType := itemType(resultType)
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" || field.Tag.Get("json") == "-" {
+			continue
+		}
+		bsonName, inline := bsonTag(field)
+		if bsonName == "-" {
+			continue
+		}
+
+		path := prefix
+		if !inline {
+			path += bsonName
+		}
+
+		switch itemType(field.Type).Kind() {
+		case reflect.String:
+			fields = append(fields, path)
+		case reflect.Struct:
+			if path != prefix {
+				path += "."
+			}
+			fields = append(fields, searchFields(field.Type, path)...)
+		}
+	}
+	return fields
+}
+
+// tagName returns the name given in a struct tag, e.g. src for `json:"src,omitempty"`
+func tagName(tag string) string {
+	return strings.Split(tag, ",")[0]
+}
+
+// bsonTag returns the document field name of a struct field and whether it is inlined
+func bsonTag(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("bson")
+	name := tagName(tag)
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, strings.Contains(tag, ",inline")
+}
+
+// itemType dereferences pointers and returns the item type of arrays and slices
+func itemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t
+}
+
+// toRecords converts a slice of results into generic records using the JSON field names
+// of the result structs
+func toRecords(results interface{}) ([]map[string]interface{}, error) {
+	encoded, err := json.Marshal(results)
+	if err != nil {
+		return nil, err
+	}
+	var records []map[string]interface{}
+	err = json.Unmarshal(encoded, &records)
+	if err != nil {
+		return nil, err
+	}
+	if records == nil {
+		records = []map[string]interface{}{}
+	}
+	return records, nil
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/activecm/rita/pkg/blacklist"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type testResult struct {
+	Src   string  `bson:"src" json:"src"`
+	Score float64 `bson:"score" json:"score"`
+	TS    struct {
+		Range int64 `bson:"range" json:"range"`
+	} `bson:"ts" json:"ts"`
+	Strobe bool `bson:"strobe" json:"strobe"`
+}
+
+var testResultType = reflect.TypeOf(&[]testResult{})
+
+func TestQuerySortAndPaginate(t *testing.T) {
+	q, err := parseQuery(url.Values{"sort": {"ts.range"}, "order": {"asc"}, "offset": {"20"}, "limit": {"10"}})
+	require.Nil(t, err)
+
+	stages, err := q.stages(testResultType, nil)
+	require.Nil(t, err)
+	assert.Equal(t, []bson.M{{"$facet": bson.M{
+		"total": []bson.M{{"$count": "count"}},
+		"results": []bson.M{
+			{"$sort": bson.D{{Key: "ts.range", Value: 1}, {Key: "_id", Value: 1}}},
+			{"$skip": 20},
+			{"$limit": 10},
+		},
+	}}}, stages)
+
+	// without a sort field the order of the module is kept
+	q, err = parseQuery(url.Values{})
+	require.Nil(t, err)
+	stages, err = q.stages(testResultType, nil)
+	require.Nil(t, err)
+	assert.Equal(t, []bson.M{{"$skip": 0}, {"$limit": defaultPageSize}}, stages[0]["$facet"].(bson.M)["results"])
+}
+
+func TestQueryFilter(t *testing.T) {
+	q, err := parseQuery(url.Values{"score[gte]": {"0.7"}, "ts.range": {"300"}, "strobe": {"true"}})
+	require.Nil(t, err)
+
+	stages, err := q.stages(testResultType, nil)
+	require.Nil(t, err)
+	assert.Equal(t, bson.M{"$match": bson.M{"$and": []bson.M{
+		{"score": bson.M{"$gte": 0.7}},
+		{"strobe": true},
+		{"ts.range": float64(300)},
+	}}}, stages[0])
+
+	q, err = parseQuery(url.Values{"q": {"192.168."}})
+	require.Nil(t, err)
+
+	stages, err = q.stages(testResultType, nil)
+	require.Nil(t, err)
+	assert.Equal(t, bson.M{"$match": bson.M{"$and": []bson.M{{"$or": []bson.M{
+		{"src": primitive.Regex{Pattern: `192\.168\.`, Options: "i"}},
+	}}}}}, stages[0])
+}
+
+func TestQueryResultFilters(t *testing.T) {
+	q, err := parseQuery(url.Values{"src": {"10.0.0.0/8,192.168.1.1"}, "min_score": {"0.8"}, "since": {"2021-06-01"}})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.1"}, q.filt.Src)
+	assert.Equal(t, 0.8, q.filt.MinScore)
+	assert.Equal(t, int64(1622505600), q.filt.Since)
+
+	_, err = q.stages(testResultType, []string{"src", "min_score", "since"})
+	assert.Nil(t, err)
+
+	// modules reject the result filters they don't apply
+	_, err = q.stages(testResultType, []string{"src"})
+	assert.NotNil(t, err)
+}
+
+func TestBSONField(t *testing.T) {
+	// the blacklisted host is inlined into the document
+	field, kind, err := bsonField(reflect.TypeOf(&[]blacklist.IPResult{}), "host.ip")
+	require.Nil(t, err)
+	assert.Equal(t, "ip", field)
+	assert.Equal(t, reflect.String, kind)
+
+	field, kind, err = bsonField(reflect.TypeOf(&[]blacklist.IPResult{}), "peers.network_name")
+	require.Nil(t, err)
+	assert.Equal(t, "peers.network_name", field)
+	assert.Equal(t, reflect.String, kind)
+
+	_, _, err = bsonField(testResultType, "missing")
+	assert.NotNil(t, err)
+
+	assert.Equal(t, []string{"ip", "network_name", "peers.ip", "peers.network_name"},
+		searchFields(reflect.TypeOf(&[]blacklist.IPResult{}), ""))
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	invalid := []url.Values{
+		{"order": {"sideways"}},
+		{"limit": {"0"}},
+		{"limit": {"100000"}},
+		{"offset": {"-1"}},
+		{"score[near]": {"1"}},
+		{"score[gt]": {"high"}},
+		{"min_score": {"high"}},
+		{"since": {"yesterday"}},
+		{"since": {"2021-06-02"}, "until": {"2021-06-01"}},
+	}
+	for _, values := range invalid {
+		_, err := parseQuery(values)
+		assert.NotNil(t, err, values.Encode())
+	}
+
+	// the fields are checked against the type of the results
+	invalidFields := []url.Values{
+		{"missing": {"1"}},
+		{"src[gt]": {"1"}},
+		{"strobe": {"maybe"}},
+		{"sort": {"ts.missing"}},
+	}
+	for _, values := range invalidFields {
+		q, err := parseQuery(values)
+		require.Nil(t, err, values.Encode())
+		_, err = q.stages(testResultType, nil)
+		assert.NotNil(t, err, values.Encode())
+	}
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/resources"
+	log "github.com/sirupsen/logrus"
+)
+
+//go:embed ui
+var uiAssets embed.FS
+
+type (
+	// Server serves a read-only JSON API over the analyzed databases along with
+	// a single page web interface for browsing them
+	Server struct {
+		res *resources.Resources
+		mux *http.ServeMux
+	}
+
+	// databaseInfo describes a database in the API
+	databaseInfo struct {
+		Name         string `json:"name"`
+		Rolling      bool   `json:"rolling"`
+		TotalChunks  int    `json:"total_chunks"`
+		CurrentChunk int    `json:"current_chunk"`
+		MinTS        int64  `json:"min_ts"`
+		MaxTS        int64  `json:"max_ts"`
+		// collections of the analysis modules which could not run on the imported data
+		UnavailableModules []string `json:"unavailable_modules"`
+	}
+)
+
+// NewServer creates a new API server using the given resources
+func NewServer(res *resources.Resources) *Server {
+	s := &Server{
+		res: res,
+		mux: http.NewServeMux(),
+	}
+
+	ui, _ := fs.Sub(uiAssets, "ui")
+
+	s.mux.HandleFunc("/api/modules", s.handleModules)
+	s.mux.HandleFunc("/api/databases", s.handleDatabases)
+	s.mux.HandleFunc("/api/databases/", s.handleResults)
+	s.mux.Handle("/", http.FileServer(http.FS(ui)))
+
+	return s
+}
+
+// ServeHTTP rejects requests which would modify data and routes the rest
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "the API is read-only")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// handleModules lists the analysis modules which may be queried
+func (s *Server) handleModules(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, moduleNames())
+}
+
+// handleDatabases lists the analyzed databases
+func (s *Server) handleDatabases(w http.ResponseWriter, r *http.Request) {
+	databases := []databaseInfo{}
+	for _, name := range s.res.MetaDB.GetAnalyzedDatabases() {
+		info, err := s.res.MetaDB.GetDBMetaInfo(name)
+		if err != nil {
+			s.res.Log.WithFields(log.Fields{
+				"database": name,
+				"err":      err,
+			}).Error("Could not retrieve database information")
+			continue
+		}
+		databases = append(databases, databaseInfo{
+			Name:               info.Name,
+			Rolling:            info.Rolling,
+			TotalChunks:        info.TotalChunks,
+			CurrentChunk:       info.CurrentChunk,
+			MinTS:              info.TsRange.Min,
+			MaxTS:              info.TsRange.Max,
+			UnavailableModules: info.UnavailableModules,
+		})
+	}
+	writeJSON(w, http.StatusOK, databases)
+}
+
+// handleResults serves /api/databases/<database>/<module>
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/databases/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		writeError(w, http.StatusNotFound, "expected /api/databases/<database>/<module>")
+		return
+	}
+	dbName, moduleName := parts[0], parts[1]
+
+	module, ok := modules[moduleName]
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown module "+moduleName)
+		return
+	}
+
+	exists, err := s.res.MetaDB.DBExists(dbName)
+	if err != nil {
+		s.res.Log.Error(err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "unknown database "+dbName)
+		return
+	}
+
+	q, err := parseQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// requests are served concurrently, so each one works with its own database selection
+	res := *s.res
+	res.DB = s.res.DB.WithSelectedDB(dbName)
+
+	results := module.results()
+	stages, err := q.stages(reflect.TypeOf(results), module.filters)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	pipeline, err := module.pipeline(&res, q.filt)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	pipeline = append(pipeline, stages...)
+
+	var paged pagedResults
+	err = database.AggregateOne(res.DB.Context(), res.DB.Collection(module.collection(&res)), pipeline, &paged)
+	if err == nil {
+		var resultPage page
+		resultPage, err = q.page(paged, results)
+		if err == nil {
+			writeJSON(w, http.StatusOK, resultPage)
+			return
+		}
+	}
+
+	s.res.Log.WithFields(log.Fields{
+		"database": dbName,
+		"module":   moduleName,
+		"err":      err,
+	}).Error("Could not retrieve results")
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// writeJSON writes the value as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+}
+
+// writeError writes an error message as the JSON response body
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
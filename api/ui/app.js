@@ -0,0 +1,197 @@
+// Single page interface for browsing the RITA API
+(function () {
+  "use strict";
+
+  var state = {
+    database: "",
+    module: "beacons",
+    search: "",
+    sort: "",
+    order: "desc",
+    offset: 0,
+    limit: 50,
+    total: 0
+  };
+
+  var el = function (id) { return document.getElementById(id); };
+
+  function getJSON(url) {
+    return fetch(url).then(function (resp) {
+      return resp.json().then(function (body) {
+        if (!resp.ok) {
+          throw new Error(body.error || resp.statusText);
+        }
+        return body;
+      });
+    });
+  }
+
+  // flatten nested objects into dotted keys so each value gets its own column
+  function flatten(record, prefix, out) {
+    Object.keys(record).forEach(function (key) {
+      var value = record[key];
+      var path = prefix ? prefix + "." + key : key;
+      if (value !== null && typeof value === "object" && !Array.isArray(value)) {
+        flatten(value, path, out);
+      } else {
+        out[path] = value;
+      }
+    });
+    return out;
+  }
+
+  function format(value) {
+    if (value === null || value === undefined) {
+      return "";
+    }
+    if (Array.isArray(value)) {
+      return value.map(function (item) {
+        return typeof item === "object" ? JSON.stringify(item) : String(item);
+      }).join(", ");
+    }
+    return String(value);
+  }
+
+  function render(page) {
+    var rows = page.results.map(function (record) { return flatten(record, "", {}); });
+    var columns = [];
+    rows.forEach(function (row) {
+      Object.keys(row).forEach(function (key) {
+        if (columns.indexOf(key) === -1) {
+          columns.push(key);
+        }
+      });
+    });
+
+    var header = document.createElement("tr");
+    columns.forEach(function (column) {
+      var th = document.createElement("th");
+      th.textContent = column + (state.sort === column ? (state.order === "desc" ? " ▼" : " ▲") : "");
+      th.onclick = function () {
+        state.order = state.sort === column && state.order === "desc" ? "asc" : "desc";
+        state.sort = column;
+        state.offset = 0;
+        load();
+      };
+      header.appendChild(th);
+    });
+    el("header").replaceChildren(header);
+
+    var body = document.createDocumentFragment();
+    rows.forEach(function (row) {
+      var tr = document.createElement("tr");
+      columns.forEach(function (column) {
+        var td = document.createElement("td");
+        td.textContent = format(row[column]);
+        tr.appendChild(td);
+      });
+      body.appendChild(tr);
+    });
+    el("rows").replaceChildren(body);
+
+    state.total = page.total;
+    var end = Math.min(page.offset + page.results.length, page.total);
+    el("summary").textContent = page.total === 0 ? "No results" :
+      (page.offset + 1) + " - " + end + " of " + page.total;
+    el("prev").disabled = page.offset === 0;
+    el("next").disabled = end >= page.total;
+  }
+
+  function load() {
+    if (!state.database) {
+      return;
+    }
+    var params = new URLSearchParams({
+      offset: state.offset,
+      limit: state.limit,
+      order: state.order
+    });
+    if (state.sort) {
+      params.set("sort", state.sort);
+    }
+    if (state.search) {
+      params.set("q", state.search);
+    }
+    var url = "api/databases/" + encodeURIComponent(state.database) + "/" +
+      encodeURIComponent(state.module) + "?" + params.toString();
+
+    el("error").textContent = "";
+    getJSON(url).then(render).catch(function (err) {
+      el("error").textContent = err.message;
+      el("header").replaceChildren();
+      el("rows").replaceChildren();
+      el("summary").textContent = "";
+    });
+  }
+
+  function selectModule(name) {
+    state.module = name;
+    state.sort = "";
+    state.order = "desc";
+    state.offset = 0;
+    Array.prototype.forEach.call(el("modules").children, function (link) {
+      link.className = link.dataset.module === name ? "active" : "";
+    });
+    load();
+  }
+
+  function init() {
+    getJSON("api/modules").then(function (names) {
+      names.forEach(function (name) {
+        var link = document.createElement("a");
+        link.href = "#";
+        link.textContent = name;
+        link.dataset.module = name;
+        link.onclick = function (evt) {
+          evt.preventDefault();
+          selectModule(name);
+        };
+        el("modules").appendChild(link);
+      });
+      return getJSON("api/databases");
+    }).then(function (databases) {
+      databases.forEach(function (db) {
+        var option = document.createElement("option");
+        option.value = db.name;
+        option.textContent = db.name;
+        el("database").appendChild(option);
+      });
+      if (databases.length === 0) {
+        el("error").textContent = "No analyzed datasets were found";
+        return;
+      }
+      state.database = databases[0].name;
+      selectModule(state.module);
+    }).catch(function (err) {
+      el("error").textContent = err.message;
+    });
+
+    el("database").onchange = function () {
+      state.database = this.value;
+      state.offset = 0;
+      load();
+    };
+
+    var searchTimer;
+    el("search").oninput = function () {
+      var value = this.value;
+      clearTimeout(searchTimer);
+      searchTimer = setTimeout(function () {
+        state.search = value;
+        state.offset = 0;
+        load();
+      }, 300);
+    };
+
+    el("prev").onclick = function () {
+      state.offset = Math.max(0, state.offset - state.limit);
+      load();
+    };
+    el("next").onclick = function () {
+      state.offset += state.limit;
+      load();
+    };
+  }
+
+  init();
+})();
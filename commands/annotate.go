@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/activecm/rita/pkg/data"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sourceActivity holds the source hosts of a result and when they were active.
+// A zero time span means the time of the activity isn't known.
+type sourceActivity struct {
+	hosts    []data.UniqueIP
+	from, to int64
+}
+
+// annotateColumn inserts a column holding the cells after the column named after, or at the end
+// of the rows if there is no such column. Each of the values is added to the matching result
+// as the given field when the results are rendered as JSON.
+func annotateColumn(results interface{}, header []string, rows [][]string, after, column, field string,
+	cells []string, values []interface{}) (interface{}, []string, [][]string, error) {
+
+	col := len(header)
+	for idx, name := range header {
+		if name == after {
+			col = idx + 1
+			break
+		}
+	}
+
+	header = insertColumn(header, col, column)
+	for idx := range rows {
+		rows[idx] = insertColumn(rows[idx], col, cells[idx])
+	}
+
+	annotated, err := annotateJSON(results, field, values)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return annotated, header, rows, nil
+}
+
+// insertColumn inserts the value into the row at the given column
+func insertColumn(row []string, col int, value string) []string {
+	row = append(row, "")
+	copy(row[col+1:], row[col:])
+	row[col] = value
+	return row
+}
+
+// annotateJSON adds the values to each of the results, which must be a slice of structs,
+// as the given field of the objects they are rendered as
+func annotateJSON(results interface{}, field string, values []interface{}) ([]json.RawMessage, error) {
+	v := reflect.ValueOf(results)
+	if v.Kind() != reflect.Slice || v.Len() != len(values) {
+		return nil, fmt.Errorf("unable to annotate %d results with %d values", v.Len(), len(values))
+	}
+
+	annotated := make([]json.RawMessage, v.Len())
+	for idx := range annotated {
+		result, err := marshalJSON(v.Index(idx).Interface())
+		if err != nil {
+			return nil, err
+		}
+		valueJSON, err := marshalJSON(values[idx])
+		if err != nil {
+			return nil, err
+		}
+		if len(result) < 2 || result[len(result)-1] != '}' {
+			return nil, fmt.Errorf("unable to annotate result %s", result)
+		}
+
+		// splice the value into the end of the object
+		var buf bytes.Buffer
+		buf.Write(result[:len(result)-1])
+		if len(result) > 2 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`"` + field + `":`)
+		buf.Write(valueJSON)
+		buf.WriteByte('}')
+		annotated[idx] = buf.Bytes()
+	}
+	return annotated, nil
+}
+
+// marshalJSON encodes the value the same way results are rendered
+func marshalJSON(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// sourceHost identifies the source host of a result by its IP address and network
+func sourceHost(ip string, networkUUID primitive.Binary) data.UniqueIP {
+	return data.UniqueIP{IP: ip, NetworkUUID: networkUUID}
+}
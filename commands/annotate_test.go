@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/activecm/rita/pkg/lease"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertColumn(t *testing.T) {
+	assert.Equal(t, []string{"a", "b", "c"}, insertColumn([]string{"a", "c"}, 1, "b"))
+	assert.Equal(t, []string{"a", "b"}, insertColumn([]string{"a"}, 1, "b"))
+}
+
+func TestAnnotateJSON(t *testing.T) {
+	results := []struct {
+		Source string `json:"src"`
+	}{{"10.0.0.5"}, {"10.0.0.6"}}
+	devices := []interface{}{
+		[]lease.Lease{{MAC: "00:11:22:33:44:55", Hostname: "laptop", Start: 1000, End: 2000}},
+		[]lease.Lease{},
+	}
+
+	annotated, err := annotateJSON(results, "src_devices", devices)
+	require.Nil(t, err)
+
+	out, err := json.Marshal(annotated)
+	require.Nil(t, err)
+	assert.JSONEq(t, `[
+		{"src":"10.0.0.5","src_devices":[{"mac":"00:11:22:33:44:55","host_name":"laptop","start":1000,"end":2000}]},
+		{"src":"10.0.0.6","src_devices":[]}
+	]`, string(out))
+
+	// annotated results may be annotated again
+	annotated, err = annotateJSON(annotated, "src_users", []interface{}{[]string{"jdoe"}, []string{}})
+	require.Nil(t, err)
+
+	out, err = json.Marshal(annotated)
+	require.Nil(t, err)
+	assert.JSONEq(t, `[
+		{"src":"10.0.0.5","src_devices":[{"mac":"00:11:22:33:44:55","host_name":"laptop","start":1000,"end":2000}],"src_users":["jdoe"]},
+		{"src":"10.0.0.6","src_devices":[],"src_users":[]}
+	]`, string(out))
+
+	// each result needs its own value
+	_, err = annotateJSON(results, "src_devices", devices[:1])
+	assert.NotNil(t, err)
+}
@@ -4,8 +4,8 @@ import (
 	"fmt"
 
 	"github.com/activecm/rita/resources"
-	"github.com/globalsign/mgo/bson"
 	"github.com/urfave/cli"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 func init() {
@@ -34,6 +34,7 @@ func cleanDatabase(c *cli.Context) error {
 		res.Config.T.Structure.HostTable:            "Host Analysis",
 		res.Config.T.DNS.HostnamesTable:             "Hostnames Analysis",
 		res.Config.T.DNS.ExplodedDNSTable:           "ExplodedDNS Analysis",
+		res.Config.T.DNS.DNSTunnelTable:             "DNS Tunnel Analysis",
 		res.Config.T.Structure.UniqueConnProxyTable: "Uconn Proxy Analysis",
 		res.Config.T.BeaconProxy.BeaconProxyTable:   "Proxy Beacon Analysis",
 		res.Config.T.Beacon.BeaconTable:             "Beacon Analysis",
@@ -41,11 +42,16 @@ func cleanDatabase(c *cli.Context) error {
 		res.Config.T.BeaconSNI.BeaconSNITable:       "SNI Connection Analysis",
 		res.Config.T.UserAgent.UserAgentTable:       "UserAgent Analysis",
 		res.Config.T.Cert.CertificateTable:          "Certificate Analysis",
+		res.Config.T.Structure.SSHConnTable:         "SSH Connection Analysis",
+		res.Config.T.Structure.LeaseTable:           "DHCP Lease Analysis",
+		res.Config.T.Structure.LogonTable:           "User Logon Analysis",
+		res.Config.T.FileTransfer.FileTransferTable: "File Transfer Analysis",
+		res.Config.T.FileTransfer.FileUploadTable:   "File Upload Analysis",
 	}
 
-	session := res.DB.Session.Copy()
+	ctx := res.DB.Context()
 
-	allDBs, err := session.DatabaseNames()
+	allDBs, err := res.DB.Client.ListDatabaseNames(ctx, bson.M{})
 
 	if err != nil {
 		fmt.Print("Clean failed: Failed to fetch database list.\n")
@@ -66,7 +72,7 @@ func cleanDatabase(c *cli.Context) error {
 			continue
 		}
 
-		collections, err := session.DB(db).CollectionNames()
+		collections, err := res.DB.Client.Database(db).ListCollectionNames(ctx, bson.M{})
 		if err != nil {
 			fmt.Print("Clean failed: Failed to fetch collection list.\n")
 			return cli.NewExitError(err.Error(), -1)
@@ -102,10 +108,10 @@ func cleanDatabase(c *cli.Context) error {
 			IndexSize int64 `bson:"indexSize"`
 		}
 
-		err = session.DB(matchingDB).Run(bson.D{
-			{Name: "dbStats", Value: 1},
-			{Name: "scale", Value: 1024 * 1024},
-		}, &dbSize)
+		err = res.DB.Client.Database(matchingDB).RunCommand(ctx, bson.D{
+			{Key: "dbStats", Value: 1},
+			{Key: "scale", Value: 1024 * 1024},
+		}).Decode(&dbSize)
 
 		if err != nil {
 			fmt.Print("Clean failed: Failed to gather size of dataset\n")
@@ -119,7 +125,7 @@ func cleanDatabase(c *cli.Context) error {
 		}
 
 		if force || confirmAction("\t [?] Confirm we'll be deleting "+matchingDB) {
-			err = session.DB(matchingDB).DropDatabase()
+			err = res.DB.Client.Database(matchingDB).Drop(ctx)
 			if err != nil {
 				fmt.Print("Clean failed: Failed to delete dataset\n")
 				return cli.NewExitError(err.Error(), -1)
@@ -4,6 +4,7 @@ import (
 	"runtime"
 
 	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
@@ -72,6 +73,55 @@ var (
 		Value: -1,
 	}
 
+	// recursiveFlag searches the subdirectories of the import directories
+	recursiveFlag = cli.BoolFlag{
+		Name:  "recursive, r",
+		Usage: "Import logs found in subdirectories of the import directories as well. Symbolic links are not followed",
+	}
+
+	// datePatternFlag restricts the import to the logs from matching dates
+	datePatternFlag = cli.StringFlag{
+		Name:  "date-pattern, dp",
+		Usage: "Only import logs whose path contains a date (YYYY-MM-DD or YYYYMMDD) matching the glob `PATTERN`, e.g. 2023-01-*",
+	}
+
+	// quarantineFlag collects the lines which could not be imported in a file
+	quarantineFlag = cli.StringFlag{
+		Name:  "quarantine, q",
+		Usage: "Append the log lines which could not be imported to `FILE` as JSON, noting the source file and reason",
+	}
+
+	// importSinceFlag and importUntilFlag limit an import to the records inside a time window
+	importSinceFlag = cli.StringFlag{
+		Name:  "since",
+		Usage: "Only import records at or after `TIME` (unix timestamp, RFC 3339 timestamp, or YYYY-MM-DD)",
+	}
+
+	importUntilFlag = cli.StringFlag{
+		Name:  "until",
+		Usage: "Only import records before `TIME` (unix timestamp, RFC 3339 timestamp, or YYYY-MM-DD)",
+	}
+
+	// excludeWindowFlag leaves the records of a period, such as a maintenance window, out of an import
+	excludeWindowFlag = cli.StringSliceFlag{
+		Name:  "exclude-window",
+		Usage: "Skip records from `START/END`, e.g. 2023-01-02T02:00:00Z/2023-01-02T04:00:00Z. May be repeated",
+	}
+
+	// networkFlag assigns the hosts in some of the imported logs to a network
+	networkFlag = cli.StringSliceFlag{
+		Name: "network",
+		Usage: "Assign the hosts in matching logs to a network, given as `NAME=dir:PATH`, NAME=glob:PATTERN, " +
+			"or NAME=node:PATTERN (Zeek _node_name or _system_name). Add vlan:ID after a comma to only match " +
+			"the connections on a VLAN, e.g. NAME=dir:PATH,vlan:20. May be repeated",
+	}
+
+	// resumeFlag continues an interrupted import
+	resumeFlag = cli.BoolFlag{
+		Name:  "resume",
+		Usage: "Continue an import which was interrupted, skipping the batches which already finished",
+	}
+
 	// threadFlag allows users to specify how many threads should be used
 	threadFlag = cli.IntFlag{
 		Name:  "threads, t",
@@ -116,15 +166,74 @@ var (
 		Value: ",", //default to comma-separated
 	}
 
+	// outputFlag selects the rendering used by the show-* commands. Structured
+	// formats such as json and ndjson serialize the full results.
+	outputFlag = cli.StringFlag{
+		Name:  "output, o",
+		Usage: "Print results as `FORMAT`: json, ndjson, csv, or table. Overrides --human-readable",
+	}
+
 	netNamesFlag = cli.BoolFlag{
 		Name:  "network-names, nn",
 		Usage: "Show network names associated with IP addresses. Helps when private IPs are reused across multiple physical networks.",
 	}
 
+	devicesFlag = cli.BoolFlag{
+		Name:  "devices, dv",
+		Usage: "Show the devices (hostname and MAC address) which held the source IP addresses at the time of the activity. Requires dhcp.log.",
+	}
+
+	usersFlag = cli.BoolFlag{
+		Name:  "users, us",
+		Usage: "Show the user accounts which authenticated from the source IP addresses during the dataset. Requires kerberos.log or ntlm.log.",
+	}
+
+	uploadsFlag = cli.BoolFlag{
+		Name:  "uploads, up",
+		Usage: "Show the source hosts which uploaded more than the FileTransfer LargeUploadMegabytes setting instead of individual files",
+	}
+
 	noBrowserFlag = cli.BoolFlag{
 		Name:  "no-browser, nb",
 		Usage: "Prevent auto-launching of default browser.",
 	}
+
+	// the following flags narrow down the results of the show-* commands. Each
+	// command only registers the flags which apply to its results.
+	srcFlag = cli.StringSliceFlag{
+		Name:  "src",
+		Usage: "Only show results with a source matching `HOST` (IP address, CIDR range, or network name). May be repeated or comma separated",
+	}
+
+	dstFlag = cli.StringSliceFlag{
+		Name:  "dst",
+		Usage: "Only show results with a destination matching `HOST` (IP address, CIDR range, or network name). May be repeated or comma separated",
+	}
+
+	minScoreFlag = cli.Float64Flag{
+		Name:  "min-score",
+		Usage: "Only show results with a score of at least `SCORE`",
+	}
+
+	minConnsFlag = cli.Int64Flag{
+		Name:  "min-conns",
+		Usage: "Only show results with at least `N` connections",
+	}
+
+	sinceFlag = cli.StringFlag{
+		Name:  "since",
+		Usage: "Only show results active at or after `TIME` (unix timestamp, RFC 3339 timestamp, or YYYY-MM-DD)",
+	}
+
+	untilFlag = cli.StringFlag{
+		Name:  "until",
+		Usage: "Only show results active at or before `TIME` (unix timestamp, RFC 3339 timestamp, or YYYY-MM-DD)",
+	}
+
+	fqdnFlag = cli.StringFlag{
+		Name:  "fqdn",
+		Usage: "Only show results with a domain name matching `PATTERN`. Supports * and ? wildcards, e.g. *.example.com",
+	}
 )
 
 // SetConfigFilePath reads config file path from cli context and stores it in app metadata
@@ -148,6 +257,20 @@ func getConfigFilePath(c *cli.Context) string {
 	}
 }
 
+// checkModuleAvailable returns an error if the analysis module which builds the given
+// collection could not be run on the data imported into the database
+func checkModuleAvailable(res *resources.Resources, db string, collection string) error {
+	info, err := res.MetaDB.GetDBMetaInfo(db)
+	if err != nil {
+		// missing databases are reported when the results are queried
+		return nil
+	}
+	if util.StringInSlice(collection, info.UnavailableModules) {
+		return cli.NewExitError("This analysis is unavailable for "+db+" since it was imported from flow records, which do not include application layer data", -1)
+	}
+	return nil
+}
+
 // bootstrapCommands simply adds a given command to the allCommands array
 func bootstrapCommands(commands ...cli.Command) {
 	for _, command := range commands {
@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/activecm/rita/pkg/lease"
+	"github.com/activecm/rita/resources"
+	"github.com/urfave/cli"
+)
+
+// deviceAnnotator annotates the source hosts of the results of a show-* command
+// with the devices which held their IP addresses according to the DHCP leases
+type deviceAnnotator struct {
+	timeline *lease.Timeline
+}
+
+// newDeviceAnnotator loads the DHCP lease timeline of the database if the --devices
+// flag was given. Otherwise, the returned annotator is nil.
+func newDeviceAnnotator(c *cli.Context, res *resources.Resources, db string) (*deviceAnnotator, error) {
+	if !c.Bool("devices") {
+		return nil, nil
+	}
+
+	if err := checkModuleAvailable(res, db, res.Config.T.Structure.LeaseTable); err != nil {
+		return nil, err
+	}
+
+	timeline, err := lease.LoadTimeline(res, db)
+	if err != nil {
+		res.Log.Error(err)
+		return nil, cli.NewExitError(err, -1)
+	}
+	if timeline.Empty() {
+		return nil, cli.NewExitError("No DHCP leases were found for "+db+". Import the dhcp.log to annotate hosts with devices.", -1)
+	}
+
+	return &deviceAnnotator{timeline: timeline}, nil
+}
+
+// annotate adds a "Source Devices" column after the column named after, or at the end of the rows
+// if there is no such column. The devices are added to each result as "src_devices" when the
+// results are rendered as JSON.
+func (a *deviceAnnotator) annotate(results interface{}, header []string, rows [][]string,
+	after string, activity []sourceActivity) (interface{}, []string, [][]string, error) {
+
+	cells := make([]string, len(activity))
+	values := make([]interface{}, len(activity))
+	for idx := range activity {
+		devices := a.timeline.Devices(activity[idx].hosts, activity[idx].from, activity[idx].to)
+
+		var names []string
+		for _, device := range devices {
+			names = append(names, device.String())
+		}
+		cells[idx] = strings.Join(names, " ")
+
+		if devices == nil {
+			devices = []lease.Lease{}
+		}
+		values[idx] = devices
+	}
+
+	return annotateColumn(results, header, rows, after, "Source Devices", "src_devices", cells, values)
+}
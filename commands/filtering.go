@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/activecm/rita/pkg/filter"
+	"github.com/urfave/cli"
+)
+
+// parseFilter builds a result filter from the filter flags registered on the command.
+// Flags which the command does not register are left at their zero value.
+func parseFilter(c *cli.Context) (filter.Filter, error) {
+	filt := filter.Filter{
+		Src:      splitHosts(c.StringSlice("src")),
+		Dst:      splitHosts(c.StringSlice("dst")),
+		MinScore: c.Float64("min-score"),
+		MinConns: c.Int64("min-conns"),
+		FQDN:     c.String("fqdn"),
+	}
+
+	var err error
+	if since := c.String("since"); since != "" {
+		filt.Since, err = filter.ParseTime(since)
+		if err != nil {
+			return filt, fmt.Errorf("--since: %v", err)
+		}
+	}
+	if until := c.String("until"); until != "" {
+		filt.Until, err = filter.ParseTime(until)
+		if err != nil {
+			return filt, fmt.Errorf("--until: %v", err)
+		}
+	}
+	if filt.Since > 0 && filt.Until > 0 && filt.Since > filt.Until {
+		return filt, fmt.Errorf("--since must not be later than --until")
+	}
+
+	return filt, nil
+}
+
+// splitHosts flattens repeated and comma separated host flag values
+func splitHosts(values []string) []string {
+	var hosts []string
+	for _, value := range values {
+		for _, host := range strings.Split(value, ",") {
+			host = strings.TrimSpace(host)
+			if host != "" {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
+)
+
+func filterContext(t *testing.T, args ...string) *cli.Context {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range []cli.Flag{srcFlag, dstFlag, minScoreFlag, minConnsFlag, sinceFlag, untilFlag, fqdnFlag} {
+		f.Apply(set)
+	}
+	require.Nil(t, set.Parse(args))
+	return cli.NewContext(nil, set, nil)
+}
+
+func TestParseFilter(t *testing.T) {
+	c := filterContext(t,
+		"--src", "10.0.0.0/8, 192.168.1.1", "--src", "Branch Office",
+		"--min-score", "0.8", "--min-conns", "100",
+		"--since", "2021-06-01", "--until", "1622592000",
+		"--fqdn", "*.example.com",
+	)
+
+	filt, err := parseFilter(c)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.1", "Branch Office"}, filt.Src)
+	assert.Empty(t, filt.Dst)
+	assert.Equal(t, 0.8, filt.MinScore)
+	assert.Equal(t, int64(100), filt.MinConns)
+	assert.Equal(t, int64(1622505600), filt.Since)
+	assert.Equal(t, int64(1622592000), filt.Until)
+	assert.Equal(t, "*.example.com", filt.FQDN)
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	_, err := parseFilter(filterContext(t, "--since", "yesterday"))
+	assert.NotNil(t, err)
+
+	_, err = parseFilter(filterContext(t, "--since", "2021-06-02", "--until", "2021-06-01"))
+	assert.NotNil(t, err)
+}
@@ -1,9 +1,136 @@
 package commands
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
 	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli"
 )
 
+// supported values for the --output flag
+const (
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+	outputCSV    = "csv"
+	outputTable  = "table"
+
+	// outputDelim is the legacy delimiter separated output used when
+	// neither --output nor --human-readable is given
+	outputDelim = "delim"
+)
+
+// outputFormat determines how the results of a show-* command should be
+// rendered. The --output flag takes precedence over --human-readable.
+func outputFormat(c *cli.Context) (string, error) {
+	format := strings.ToLower(c.String("output"))
+	switch format {
+	case "":
+		if c.Bool("human-readable") {
+			return outputTable, nil
+		}
+		return outputDelim, nil
+	case outputJSON, outputNDJSON, outputCSV, outputTable:
+		return format, nil
+	}
+	return "", fmt.Errorf("unsupported output format %q: must be one of json, ndjson, csv, table", format)
+}
+
+// renderResults writes the results of a show-* command to stdout in the given format.
+// The json and ndjson formats serialize the full result structs, while the csv, table,
+// and delimited formats print the given header and rows.
+func renderResults(format string, results interface{}, header []string, rows [][]string, delim string) error {
+	switch format {
+	case outputJSON:
+		return renderJSON(os.Stdout, results)
+	case outputNDJSON:
+		return renderNDJSON(os.Stdout, results)
+	case outputCSV:
+		return renderCSV(os.Stdout, header, rows, delim)
+	case outputTable:
+		renderTable(os.Stdout, header, rows)
+		return nil
+	}
+	return renderDelim(os.Stdout, header, rows, delim)
+}
+
+// renderJSON writes results as a single indented JSON array
+func renderJSON(w io.Writer, results interface{}) error {
+	// avoid printing null for empty result sets
+	if v := reflect.ValueOf(results); v.Kind() == reflect.Slice && v.IsNil() {
+		results = []interface{}{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+// renderNDJSON writes each element of the results slice as a JSON
+// object on its own line
+func renderNDJSON(w io.Writer, results interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+
+	v := reflect.ValueOf(results)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return encoder.Encode(results)
+	}
+	for idx := 0; idx < v.Len(); idx++ {
+		if err := encoder.Encode(v.Index(idx).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderCSV writes the header and rows as RFC 4180 CSV. Fields containing the
+// separator, quotes, or newlines are quoted. A single character delimiter
+// may be used in place of the comma.
+func renderCSV(w io.Writer, header []string, rows [][]string, delim string) error {
+	writer := csv.NewWriter(w)
+	if utf8.RuneCountInString(delim) == 1 {
+		writer.Comma, _ = utf8.DecodeRuneInString(delim)
+	}
+
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// renderTable writes the header and rows as a human readable table
+func renderTable(w io.Writer, header []string, rows [][]string) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader(header)
+	table.AppendBulk(rows)
+	table.Render()
+}
+
+// renderDelim writes the header and rows with each field separated by delim.
+// No quoting is performed.
+func renderDelim(w io.Writer, header []string, rows [][]string, delim string) error {
+	if _, err := fmt.Fprintln(w, strings.Join(header, delim)); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if _, err := fmt.Fprintln(w, strings.Join(row, delim)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // helper functions for formatting floats and integers
 func f(f float64) string {
 	return strconv.FormatFloat(f, 'g', 6, 64)
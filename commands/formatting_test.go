@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/activecm/rita/pkg/useragent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderCSVQuoting(t *testing.T) {
+	var buf bytes.Buffer
+	header := []string{"User Agent", "Times Used"}
+	rows := [][]string{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64)", "10"},
+		{`curl, "quoted"`, "2"},
+	}
+
+	require.Nil(t, renderCSV(&buf, header, rows, ","))
+	assert.Equal(t,
+		"User Agent,Times Used\n"+
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64),10\n"+
+			"\"curl, \"\"quoted\"\"\",2\n",
+		buf.String(),
+	)
+
+	buf.Reset()
+	require.Nil(t, renderCSV(&buf, header, rows, "\t"))
+	assert.Equal(t,
+		"User Agent\tTimes Used\n"+
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64)\t10\n"+
+			"\"curl, \"\"quoted\"\"\"\t2\n",
+		buf.String(),
+	)
+}
+
+func TestRenderNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	results := []useragent.Result{
+		{UserAgent: "Mozilla/5.0 <script>", TimesUsed: 10},
+		{UserAgent: "curl/7.68.0", TimesUsed: 2},
+	}
+
+	require.Nil(t, renderNDJSON(&buf, results))
+	assert.Equal(t,
+		"{\"user_agent\":\"Mozilla/5.0 <script>\",\"seen\":10}\n"+
+			"{\"user_agent\":\"curl/7.68.0\",\"seen\":2}\n",
+		buf.String(),
+	)
+}
+
+func TestRenderJSONEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	var results []useragent.Result
+
+	require.Nil(t, renderJSON(&buf, results))
+	assert.Equal(t, "[]\n", buf.String())
+}
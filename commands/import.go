@@ -3,10 +3,14 @@ package commands
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/parser"
+	"github.com/activecm/rita/parser/files"
+	"github.com/activecm/rita/pkg/filter"
 	"github.com/activecm/rita/pkg/remover"
 	"github.com/activecm/rita/resources"
 	"github.com/activecm/rita/util"
@@ -28,6 +32,14 @@ func init() {
 			rollingFlag,
 			totalChunksFlag,
 			currentChunkFlag,
+			recursiveFlag,
+			datePatternFlag,
+			quarantineFlag,
+			importSinceFlag,
+			importUntilFlag,
+			excludeWindowFlag,
+			networkFlag,
+			resumeFlag,
 		},
 		Action: func(c *cli.Context) error {
 			importer := NewImporter(c)
@@ -52,6 +64,15 @@ type (
 		userRolling     bool
 		userTotalChunks int
 		userCurrChunk   int
+		gatherOpts      files.GatherOptions
+		quarantineFile  string
+		since           string
+		until           string
+		excludeWindows  []string
+		window          database.TimeWindow
+		networkFlags    []string
+		networks        []config.NetworkStaticCfg
+		resume          bool
 		threads         int
 	}
 )
@@ -65,7 +86,17 @@ func NewImporter(c *cli.Context) *Importer {
 		userRolling:     c.Bool("rolling"),
 		userTotalChunks: c.Int("numchunks"),
 		userCurrChunk:   c.Int("chunk"),
-		threads:         util.Max(c.Int("threads")/2, 1),
+		gatherOpts: files.GatherOptions{
+			Recursive:   c.Bool("recursive"),
+			DatePattern: c.String("date-pattern"),
+		},
+		quarantineFile: c.String("quarantine"),
+		since:          c.String("since"),
+		until:          c.String("until"),
+		excludeWindows: c.StringSlice("exclude-window"),
+		networkFlags:   c.StringSlice("network"),
+		resume:         c.Bool("resume"),
+		threads:        util.Max(c.Int("threads")/2, 1),
 	}
 }
 
@@ -89,14 +120,117 @@ func (i *Importer) parseArgs() error {
 		return err
 	}
 
-	err = i.checkForInvalidDBChars(i.targetDatabase)
+	err = checkForInvalidDBChars(i.targetDatabase)
 	if err != nil {
 		return cli.NewExitError(err.Error(), -1)
 	}
 
+	err = files.ValidateDatePattern(i.gatherOpts.DatePattern)
+	if err != nil {
+		return cli.NewExitError(fmt.Errorf("\n\t[!] Invalid date pattern: %v", err.Error()), -1)
+	}
+
+	i.window, err = parseTimeWindow(i.since, i.until, i.excludeWindows)
+	if err != nil {
+		return cli.NewExitError(fmt.Errorf("\n\t[!] Invalid time window: %v", err.Error()), -1)
+	}
+
+	i.networks, err = parseNetworkFlags(i.networkFlags)
+	if err != nil {
+		return cli.NewExitError(fmt.Errorf("\n\t[!] Invalid network: %v", err.Error()), -1)
+	}
+
+	// a resumed import continues with the chunk settings and time window of the interrupted import
+	if i.resume && (i.deleteOldData || i.userRolling || i.userTotalChunks != -1 || i.userCurrChunk != -1 ||
+		!i.window.IsEmpty()) {
+		return cli.NewExitError("\n\t[!] --resume cannot be combined with --delete, --rolling, --numchunks, --chunk, "+
+			"--since, --until, or --exclude-window", -1)
+	}
+
 	return nil
 }
 
+// parseTimeWindow builds the time window selected by the --since, --until, and --exclude-window flags
+func parseTimeWindow(since string, until string, excludeWindows []string) (database.TimeWindow, error) {
+	window := database.TimeWindow{}
+	var err error
+
+	if since != "" {
+		window.Since, err = filter.ParseTime(since)
+		if err != nil {
+			return window, fmt.Errorf("--since: %v", err)
+		}
+	}
+	if until != "" {
+		window.Until, err = filter.ParseTime(until)
+		if err != nil {
+			return window, fmt.Errorf("--until: %v", err)
+		}
+	}
+	if window.Since != 0 && window.Until != 0 && window.Since >= window.Until {
+		return window, fmt.Errorf("--since must be earlier than --until")
+	}
+
+	for _, excludeWindow := range excludeWindows {
+		bounds := strings.SplitN(excludeWindow, "/", 2)
+		if len(bounds) != 2 {
+			return window, fmt.Errorf("--exclude-window: %q must be given as START/END", excludeWindow)
+		}
+		start, err := filter.ParseTime(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return window, fmt.Errorf("--exclude-window: %v", err)
+		}
+		end, err := filter.ParseTime(strings.TrimSpace(bounds[1]))
+		if err != nil {
+			return window, fmt.Errorf("--exclude-window: %v", err)
+		}
+		if start >= end {
+			return window, fmt.Errorf("--exclude-window: %q must start before it ends", excludeWindow)
+		}
+		window.Exclude = append(window.Exclude, database.Range{Min: start, Max: end})
+	}
+	return window, nil
+}
+
+// parseNetworkFlags builds the network rules given with the --network flag. A rule may
+// match by several kinds at once, e.g. NAME=dir:PATH,vlan:ID.
+func parseNetworkFlags(networkFlags []string) ([]config.NetworkStaticCfg, error) {
+	var networks []config.NetworkStaticCfg
+	for _, networkFlag := range networkFlags {
+		nameAndMatch := strings.SplitN(networkFlag, "=", 2)
+		if len(nameAndMatch) != 2 || nameAndMatch[0] == "" {
+			return nil, fmt.Errorf("%q must be given as NAME=KIND:VALUE", networkFlag)
+		}
+
+		network := config.NetworkStaticCfg{Name: nameAndMatch[0]}
+		for _, match := range strings.Split(nameAndMatch[1], ",") {
+			kindAndValue := strings.SplitN(match, ":", 2)
+			if len(kindAndValue) != 2 || kindAndValue[1] == "" {
+				return nil, fmt.Errorf("%q must be given as NAME=KIND:VALUE", networkFlag)
+			}
+
+			switch kindAndValue[0] {
+			case "dir":
+				network.Directory = kindAndValue[1]
+			case "glob":
+				network.FileGlob = kindAndValue[1]
+			case "node":
+				network.NodeName = kindAndValue[1]
+			case "vlan":
+				vlan, err := strconv.Atoi(kindAndValue[1])
+				if err != nil || vlan <= 0 {
+					return nil, fmt.Errorf("%q does not give a valid VLAN ID", networkFlag)
+				}
+				network.VLAN = vlan
+			default:
+				return nil, fmt.Errorf("%q must match by dir, glob, node, or vlan", networkFlag)
+			}
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
 func checkFilesExist(files []string) error {
 	for _, file := range files {
 		if !util.Exists(file) {
@@ -220,27 +354,69 @@ func (i *Importer) run() error {
 		return cli.NewExitError(fmt.Errorf("\n\t[!] Error while reading existing database settings: %v", err.Error()), -1)
 	}
 
-	// validate the user given flags against the rolling settings from the MetaDB
-	// and determine the rolling configuration
-	rollingCfg, err := parseFlags(
-		exists, isRolling, currChunk, totalChunks,
-		i.userRolling, i.userCurrChunk, i.userTotalChunks, i.res.Config.S.Rolling.DefaultChunks,
-		i.deleteOldData,
-	)
+	checkpoint, err := i.res.MetaDB.GetImportCheckpoint(i.targetDatabase)
 	if err != nil {
-		return cli.NewExitError(err.Error(), -1)
+		return cli.NewExitError(fmt.Errorf("\n\t[!] Error while reading the import checkpoint: %v", err.Error()), -1)
+	}
+
+	var rollingCfg config.RollingStaticCfg
+	if i.resume {
+		if checkpoint == nil {
+			return cli.NewExitError(fmt.Errorf("\n\t[!] There is no interrupted import to resume in %s", i.targetDatabase), -1)
+		}
+		// continue importing into the chunk of the interrupted import
+		rollingCfg = config.RollingStaticCfg{
+			Rolling:       isRolling,
+			CurrentChunk:  checkpoint.CID,
+			TotalChunks:   util.Max(totalChunks, 1),
+			DefaultChunks: i.res.Config.S.Rolling.DefaultChunks,
+		}
+	} else {
+		if checkpoint != nil {
+			fmt.Printf("\n\t[!] An earlier import into %s was interrupted after batch %d of %d. "+
+				"Run with --resume to continue it instead.\n",
+				i.targetDatabase, checkpoint.BatchesDone, checkpoint.TotalBatches)
+		}
+
+		// validate the user given flags against the rolling settings from the MetaDB
+		// and determine the rolling configuration
+		rollingCfg, err = parseFlags(
+			exists, isRolling, currChunk, totalChunks,
+			i.userRolling, i.userCurrChunk, i.userTotalChunks, i.res.Config.S.Rolling.DefaultChunks,
+			i.deleteOldData,
+		)
+		if err != nil {
+			return cli.NewExitError(err.Error(), -1)
+		}
 	}
 	i.res.Config.S.Rolling = rollingCfg
 
+	// the networks given on the command line are checked before those in the config file
+	i.res.Config.S.Networks = append(i.networks, i.res.Config.S.Networks...)
+
 	importer, err := parser.NewFSImporter(i.res)
+	if err != nil {
+		return cli.NewExitError(fmt.Errorf("error creating new file system importer: %v", err.Error()), -1)
+	}
 	if len(importer.GetInternalSubnets()) == 0 {
 		return cli.NewExitError("Internal subnets are not defined. Please set the InternalSubnets section of the config file.", -1)
 	}
-	if err != nil {
-		return cli.NewExitError(fmt.Errorf("error creating new file system importer: %v", err.Error()), -1)
+
+	if i.quarantineFile != "" {
+		importer.SetQuarantineFile(i.quarantineFile)
+	}
+
+	if i.resume {
+		importer.SetResumeCheckpoint(checkpoint)
+		i.window, err = i.res.MetaDB.GetTimeWindow(i.targetDatabase)
+		if err != nil {
+			return cli.NewExitError(fmt.Errorf("\n\t[!] Error while reading the time window: %v", err.Error()), -1)
+		}
 	}
+	importer.SetTimeWindow(i.window)
+	importer.StopOnInterrupt()
 
-	indexedFiles := importer.CollectFileDetails(i.importFiles, i.threads)
+	indexedFiles := importer.CollectFileDetails(i.importFiles, i.gatherOpts, i.threads)
 	// if no compatible files for import were found, exit
 	if len(indexedFiles) == 0 {
 		return cli.NewExitError("No compatible log files found", -1)
@@ -323,7 +499,7 @@ func (i *Importer) handleDeleteOldData() error {
 }
 
 // validates target db name
-func (i *Importer) checkForInvalidDBChars(db string) error {
+func checkForInvalidDBChars(db string) error {
 	invalidChars := "/\\.,*<>:|?$#"
 	if strings.ContainsAny(db, invalidChars) {
 		return fmt.Errorf("\n\t[!] database cannot contain the characters < /, \\, ., \", *, <, >, :, |, ?, $ > as well as spaces or the null character")
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
 )
 
 func TestParseFlags(t *testing.T) {
@@ -271,3 +272,52 @@ func TestParseFlags(t *testing.T) {
 	}
 
 }
+
+func TestParseTimeWindow(t *testing.T) {
+	window, err := parseTimeWindow("2021-06-01", "2021-06-02T00:00:00Z",
+		[]string{"2021-06-01T02:00:00Z/2021-06-01T04:00:00Z", "1622563200 / 1622566800"})
+	assert.NoError(t, err)
+	assert.Equal(t, database.TimeWindow{
+		Since:   1622505600,
+		Until:   1622592000,
+		Exclude: []database.Range{{Min: 1622512800, Max: 1622520000}, {Min: 1622563200, Max: 1622566800}},
+	}, window)
+
+	assert.True(t, window.Contains(1622505600))
+	assert.False(t, window.Contains(1622505599))
+	assert.False(t, window.Contains(1622592000))
+	assert.False(t, window.Contains(1622512800))
+	assert.True(t, window.Contains(1622520000))
+
+	window, err = parseTimeWindow("", "", nil)
+	assert.NoError(t, err)
+	assert.True(t, window.IsEmpty())
+
+	for _, args := range [][]string{
+		{"yesterday", ""},
+		{"2021-06-02", "2021-06-01"},
+		{"", "", "2021-06-01T02:00:00Z"},
+		{"", "", "2021-06-01T04:00:00Z/2021-06-01T02:00:00Z"},
+	} {
+		_, err = parseTimeWindow(args[0], args[1], args[2:])
+		assert.Error(t, err, args)
+	}
+}
+
+func TestParseNetworkFlags(t *testing.T) {
+	networks, err := parseNetworkFlags([]string{
+		"site-a=dir:/opt/zeek/site-a", "site-b=glob:*site-b*", "site-c=node:site-c-*", "branch=dir:/opt/zeek/site-a,vlan:20",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []config.NetworkStaticCfg{
+		{Name: "site-a", Directory: "/opt/zeek/site-a"},
+		{Name: "site-b", FileGlob: "*site-b*"},
+		{Name: "site-c", NodeName: "site-c-*"},
+		{Name: "branch", Directory: "/opt/zeek/site-a", VLAN: 20},
+	}, networks)
+
+	for _, networkFlag := range []string{"site-a", "=dir:/opt", "site-a=dir", "site-a=dir:", "site-a=host:x", "branch=vlan:x", "branch=dir:/opt,"} {
+		_, err = parseNetworkFlags([]string{networkFlag})
+		assert.Error(t, err, networkFlag)
+	}
+}
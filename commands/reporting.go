@@ -16,6 +16,8 @@ func init() {
 		Flags: []cli.Flag{
 			ConfigFlag,
 			netNamesFlag,
+			devicesFlag,
+			usersFlag,
 			noBrowserFlag,
 		},
 		Action: func(c *cli.Context) error {
@@ -27,7 +29,7 @@ func init() {
 			} else {
 				databases = res.MetaDB.GetAnalyzedDatabases()
 			}
-			err := reporting.PrintHTML(databases, c.Bool("network-names"), c.Bool("no-browser"), res)
+			err := reporting.PrintHTML(databases, c.Bool("network-names"), c.Bool("devices"), c.Bool("users"), c.Bool("no-browser"), res)
 			if err != nil {
 				return cli.NewExitError(err.Error(), -1)
 			}
@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/activecm/rita/api"
+	"github.com/activecm/rita/resources"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	command := cli.Command{
+
+		Name:  "serve",
+		Usage: "Serve a web interface and read-only JSON API for browsing analyzed databases",
+		UsageText: "rita serve [command-options]\n\n" +
+			"Results are served from /api/databases/<database>/<module>. See the Readme for the query parameters.",
+		Flags: []cli.Flag{
+			ConfigFlag,
+			cli.StringFlag{
+				Name:  "listen, l",
+				Usage: "Serve on `ADDRESS` (host:port). Bind to 0.0.0.0 to allow remote access",
+				Value: "127.0.0.1:4096",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			res := resources.InitResources(getConfigFilePath(c))
+
+			server := &http.Server{
+				Addr:              c.String("listen"),
+				Handler:           api.NewServer(res),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+
+			fmt.Printf("[+] Serving RITA at http://%s\n", server.Addr)
+			err := server.ListenAndServe()
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+			return nil
+		},
+	}
+	bootstrapCommands(command)
+}
@@ -1,13 +1,9 @@
 package commands
 
 import (
-	"fmt"
-	"os"
-	"strings"
-
 	"github.com/activecm/rita/pkg/beaconproxy"
+	"github.com/activecm/rita/pkg/data"
 	"github.com/activecm/rita/resources"
-	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli"
 )
 
@@ -19,8 +15,16 @@ func init() {
 		Flags: []cli.Flag{
 			ConfigFlag,
 			humanFlag,
+			outputFlag,
 			delimFlag,
 			netNamesFlag,
+			devicesFlag,
+			srcFlag,
+			fqdnFlag,
+			minScoreFlag,
+			minConnsFlag,
+			sinceFlag,
+			untilFlag,
 		},
 		Action: showBeaconsProxy,
 	}
@@ -33,108 +37,91 @@ func showBeaconsProxy(c *cli.Context) error {
 	if db == "" {
 		return cli.NewExitError("Specify a database", -1)
 	}
+	filt, err := parseFilter(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), -1)
+	}
+
 	res := resources.InitResources(c.String("config"))
 	res.DB.SelectDB(db)
 
-	data, err := beaconproxy.Results(res, 0)
+	if err := checkModuleAvailable(res, db, res.Config.T.BeaconProxy.BeaconProxyTable); err != nil {
+		return err
+	}
+
+	devices, err := newDeviceAnnotator(c, res, db)
+	if err != nil {
+		return err
+	}
+
+	beacons, err := beaconproxy.Results(res, 0, filt)
 
 	if err != nil {
 		res.Log.Error(err)
 		return cli.NewExitError(err, -1)
 	}
 
-	if !(len(data) > 0) {
+	if !(len(beacons) > 0) {
 		return cli.NewExitError("No results were found for "+db, -1)
 	}
 
-	showNetNames := c.Bool("network-names")
-
-	if c.Bool("human-readable") {
-		err := showBeaconsProxyHuman(data, showNetNames)
-		if err != nil {
-			return cli.NewExitError(err.Error(), -1)
-		}
-		return nil
-	}
-
-	err = showBeaconsProxyDelim(data, c.String("delimiter"), showNetNames)
+	format, err := outputFormat(c)
 	if err != nil {
 		return cli.NewExitError(err.Error(), -1)
 	}
-	return nil
-}
 
-func showBeaconsProxyHuman(data []beaconproxy.Result, showNetNames bool) error {
-	table := tablewriter.NewWriter(os.Stdout)
-	var headerFields []string
-	if showNetNames {
-		headerFields = []string{
-			"Score", "Source Network", "Source IP", "FQDN", "Proxy Network", "Proxy IP",
-			"Connections", "TS Score", "Dur Score", "Hist Score", "Top Intvl",
+	header, rows := beaconProxyRows(beacons, c.Bool("network-names"))
+
+	var results interface{} = beacons
+	if devices != nil {
+		activity := make([]sourceActivity, len(beacons))
+		for idx, result := range beacons {
+			activity[idx] = sourceActivity{hosts: []data.UniqueIP{sourceHost(result.SrcIP, result.SrcNetworkUUID)}, from: result.Ts.First, to: result.Ts.Last}
 		}
-	} else {
-		headerFields = []string{
-			"Score", "Source IP", "FQDN", "Proxy IP",
-			"Connections", "TS Score", "Dur Score", "Hist Score", "Top Intvl",
+		results, header, rows, err = devices.annotate(beacons, header, rows, "Source IP", activity)
+		if err != nil {
+			return cli.NewExitError(err.Error(), -1)
 		}
 	}
 
-	table.SetHeader(headerFields)
-
-	for _, d := range data {
-		var row []string
-
-		if showNetNames {
-			row = []string{
-				f(d.Score), d.SrcNetworkName,
-				d.SrcIP, d.FQDN, d.Proxy.NetworkName, d.Proxy.IP,
-				i(d.Connections), f(d.Ts.Score), f(d.DurScore), f(d.HistScore), i(d.Ts.Mode),
-			}
-		} else {
-			row = []string{
-				f(d.Score), d.SrcIP, d.FQDN, d.Proxy.IP,
-				i(d.Connections), f(d.Ts.Score), f(d.DurScore), f(d.HistScore), i(d.Ts.Mode),
-			}
-		}
-		table.Append(row)
+	err = renderResults(format, results, header, rows, c.String("delimiter"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), -1)
 	}
-	table.Render()
 	return nil
 }
 
-func showBeaconsProxyDelim(data []beaconproxy.Result, delim string, showNetNames bool) error {
+// beaconProxyRows formats proxy beacon results as a header and rows for tabular output
+func beaconProxyRows(data []beaconproxy.Result, showNetNames bool) ([]string, [][]string) {
 	var headerFields []string
 	if showNetNames {
 		headerFields = []string{
 			"Score", "Source Network", "Source IP", "FQDN", "Proxy Network", "Proxy IP",
-			"Connections", "TS Score", "Dur Score", "Hist Score", "Top Intvl",
+			"Connections", "TS Score", "Dur Score", "Hist Score", "Period Score", "Top Intvl",
 		}
 	} else {
 		headerFields = []string{
 			"Score", "Source IP", "FQDN", "Proxy IP",
-			"Connections", "TS Score", "Dur Score", "Hist Score", "Top Intvl",
+			"Connections", "TS Score", "Dur Score", "Hist Score", "Period Score", "Top Intvl",
 		}
 	}
 
-	// Print the headers and analytic values, separated by a delimiter
-	fmt.Println(strings.Join(headerFields, delim))
+	var rows [][]string
 	for _, d := range data {
-
 		var row []string
 		if showNetNames {
 			row = []string{
 				f(d.Score), d.SrcNetworkName,
 				d.SrcIP, d.FQDN, d.Proxy.NetworkName, d.Proxy.IP,
-				i(d.Connections), f(d.Ts.Score), f(d.DurScore), f(d.HistScore), i(d.Ts.Mode),
+				i(d.Connections), f(d.Ts.Score), f(d.DurScore), f(d.HistScore), f(d.PeriodicityScore), i(d.Ts.Mode),
 			}
 		} else {
 			row = []string{
 				f(d.Score), d.SrcIP, d.FQDN, d.Proxy.IP,
-				i(d.Connections), f(d.Ts.Score), f(d.DurScore), f(d.HistScore), i(d.Ts.Mode),
+				i(d.Connections), f(d.Ts.Score), f(d.DurScore), f(d.HistScore), f(d.PeriodicityScore), i(d.Ts.Mode),
 			}
 		}
-
-		fmt.Println(strings.Join(row, delim))
+		rows = append(rows, row)
 	}
-	return nil
+	return headerFields, rows
 }
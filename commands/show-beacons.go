@@ -1,13 +1,9 @@
 package commands
 
 import (
-	"fmt"
-	"os"
-	"strings"
-
 	"github.com/activecm/rita/pkg/beacon"
+	"github.com/activecm/rita/pkg/data"
 	"github.com/activecm/rita/resources"
-	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli"
 )
 
@@ -19,8 +15,17 @@ func init() {
 		Flags: []cli.Flag{
 			ConfigFlag,
 			humanFlag,
+			outputFlag,
 			delimFlag,
 			netNamesFlag,
+			devicesFlag,
+			usersFlag,
+			srcFlag,
+			dstFlag,
+			minScoreFlag,
+			minConnsFlag,
+			sinceFlag,
+			untilFlag,
 		},
 		Action: showBeacons,
 	}
@@ -33,113 +38,104 @@ func showBeacons(c *cli.Context) error {
 	if db == "" {
 		return cli.NewExitError("Specify a database", -1)
 	}
+	filt, err := parseFilter(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), -1)
+	}
+
 	res := resources.InitResources(getConfigFilePath(c))
 	res.DB.SelectDB(db)
 
-	data, err := beacon.Results(res, 0)
+	devices, err := newDeviceAnnotator(c, res, db)
+	if err != nil {
+		return err
+	}
+
+	users, err := newUserAnnotator(c, res, db)
+	if err != nil {
+		return err
+	}
+
+	beacons, err := beacon.Results(res, 0, filt)
 
 	if err != nil {
 		res.Log.Error(err)
 		return cli.NewExitError(err, -1)
 	}
 
-	if !(len(data) > 0) {
+	if !(len(beacons) > 0) {
 		return cli.NewExitError("No results were found for "+db, -1)
 	}
 
-	showNetNames := c.Bool("network-names")
-
-	if c.Bool("human-readable") {
-		err := showBeaconsHuman(data, showNetNames)
-		if err != nil {
-			return cli.NewExitError(err.Error(), -1)
-		}
-		return nil
-	}
-
-	err = showBeaconsDelim(data, c.String("delimiter"), showNetNames)
+	format, err := outputFormat(c)
 	if err != nil {
 		return cli.NewExitError(err.Error(), -1)
 	}
-	return nil
-}
 
-func showBeaconsHuman(data []beacon.Result, showNetNames bool) error {
-	table := tablewriter.NewWriter(os.Stdout)
-	var headerFields []string
-	if showNetNames {
-		headerFields = []string{
-			"Score", "Source Network", "Destination Network", "Source IP", "Destination IP",
-			"Connections", "Avg. Bytes", "Total Bytes", "TS Score", "DS Score", "Dur Score",
-			"Hist Score", "Top Intvl",
-		}
-	} else {
-		headerFields = []string{
-			"Score", "Source IP", "Destination IP",
-			"Connections", "Avg. Bytes", "Total Bytes", "TS Score", "DS Score", "Dur Score",
-			"Hist Score", "Top Intvl",
-		}
-	}
-
-	table.SetHeader(headerFields)
+	header, rows := beaconRows(beacons, c.Bool("network-names"))
 
-	for _, d := range data {
-		var row []string
-		if showNetNames {
-			row = []string{
-				f(d.Score), d.SrcNetworkName, d.DstNetworkName,
-				d.SrcIP, d.DstIP, i(d.Connections), f(d.AvgBytes), i(d.TotalBytes),
-				f(d.Ts.Score), f(d.Ds.Score), f(d.DurScore), f(d.HistScore), i(d.Ts.Mode),
+	var results interface{} = beacons
+	if devices != nil || users != nil {
+		activity := make([]sourceActivity, len(beacons))
+		for idx, result := range beacons {
+			activity[idx] = sourceActivity{hosts: []data.UniqueIP{result.UniqueSrcIP.Unpair()}, from: result.Ts.First, to: result.Ts.Last}
+		}
+		// the devices are inserted right after the sources, ahead of the users
+		if users != nil {
+			results, header, rows, err = users.annotate(results, header, rows, "Source IP", activity)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
 			}
-		} else {
-			row = []string{
-				f(d.Score), d.SrcIP, d.DstIP, i(d.Connections), f(d.AvgBytes),
-				i(d.TotalBytes), f(d.Ts.Score), f(d.Ds.Score), f(d.DurScore),
-				f(d.HistScore), i(d.Ts.Mode),
+		}
+		if devices != nil {
+			results, header, rows, err = devices.annotate(results, header, rows, "Source IP", activity)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
 			}
 		}
-		table.Append(row)
 	}
-	table.Render()
+
+	err = renderResults(format, results, header, rows, c.String("delimiter"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), -1)
+	}
 	return nil
 }
 
-func showBeaconsDelim(data []beacon.Result, delim string, showNetNames bool) error {
+// beaconRows formats beacon results as a header and rows for tabular output
+func beaconRows(data []beacon.Result, showNetNames bool) ([]string, [][]string) {
 	var headerFields []string
 	if showNetNames {
 		headerFields = []string{
 			"Score", "Source Network", "Destination Network", "Source IP", "Destination IP",
 			"Connections", "Avg. Bytes", "Total Bytes", "TS Score", "DS Score", "Dur Score",
-			"Hist Score", "Top Intvl",
+			"Hist Score", "Period Score", "Top Intvl",
 		}
 	} else {
 		headerFields = []string{
 			"Score", "Source IP", "Destination IP",
 			"Connections", "Avg. Bytes", "Total Bytes", "TS Score", "DS Score", "Dur Score",
-			"Hist Score", "Top Intvl",
+			"Hist Score", "Period Score", "Top Intvl",
 		}
 	}
 
-	// Print the headers and analytic values, separated by a delimiter
-	fmt.Println(strings.Join(headerFields, delim))
+	var rows [][]string
 	for _, d := range data {
-
 		var row []string
 		if showNetNames {
 			row = []string{
 				f(d.Score), d.SrcNetworkName, d.DstNetworkName,
 				d.SrcIP, d.DstIP, i(d.Connections), f(d.AvgBytes), i(d.TotalBytes),
-				f(d.Ts.Score), f(d.Ds.Score), f(d.DurScore), f(d.HistScore), i(d.Ts.Mode),
+				f(d.Ts.Score), f(d.Ds.Score), f(d.DurScore), f(d.HistScore), f(d.PeriodicityScore), f(d.Ts.Mode),
 			}
 		} else {
 			row = []string{
 				f(d.Score), d.SrcIP, d.DstIP, i(d.Connections), f(d.AvgBytes),
 				i(d.TotalBytes), f(d.Ts.Score), f(d.Ds.Score), f(d.DurScore),
-				f(d.HistScore), i(d.Ts.Mode),
+				f(d.HistScore), f(d.PeriodicityScore), f(d.Ts.Mode),
 			}
 		}
-
-		fmt.Println(strings.Join(row, delim))
+		rows = append(rows, row)
 	}
-	return nil
+	return headerFields, rows
 }
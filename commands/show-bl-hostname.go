@@ -1,15 +1,10 @@
 package commands
 
 import (
-	"fmt"
-	"os"
-	"sort"
 	"strconv"
-	"strings"
 
 	"github.com/activecm/rita/pkg/blacklist"
 	"github.com/activecm/rita/resources"
-	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli"
 )
 
@@ -21,10 +16,12 @@ func init() {
 		Flags: []cli.Flag{
 			ConfigFlag,
 			humanFlag,
+			outputFlag,
 			limitFlag,
 			noLimitFlag,
 			delimFlag,
 			netNamesFlag,
+			devicesFlag,
 		},
 		Usage:  "Print blacklisted hostnames which received connections",
 		Action: printBLHostnames,
@@ -43,6 +40,15 @@ func printBLHostnames(c *cli.Context) error {
 	res := resources.InitResources(getConfigFilePath(c))
 	res.DB.SelectDB(db)
 
+	if err := checkModuleAvailable(res, db, res.Config.T.DNS.HostnamesTable); err != nil {
+		return err
+	}
+
+	devices, err := newDeviceAnnotator(c, res, db)
+	if err != nil {
+		return err
+	}
+
 	data, err := blacklist.HostnameResults(res, "conn_count", c.Int("limit"), c.Bool("no-limit"))
 
 	if err != nil {
@@ -54,96 +60,49 @@ func printBLHostnames(c *cli.Context) error {
 		return cli.NewExitError("No results were found for "+db, -1)
 	}
 
-	if c.Bool("human-readable") {
-		err = showBLHostnamesHuman(data, c.Bool("network-names"))
-		if err != nil {
-			return cli.NewExitError(err.Error(), -1)
-		}
-	} else {
-		err = showBLHostnames(data, c.String("delimiter"), c.Bool("network-names"))
-		if err != nil {
-			return cli.NewExitError(err.Error(), -1)
-		}
+	format, err := outputFormat(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), -1)
 	}
 
-	return nil
-}
+	header, rows := blHostnameRows(data, c.Bool("network-names"), format == outputTable)
 
-func showBLHostnames(hostnames []blacklist.HostnameResult, delim string, showNetNames bool) error {
-	headers := []string{"Host", "Connections", "Unique Connections", "Total Bytes", "Sources"}
-
-	// Print the headers and analytic values, separated by a delimiter
-	fmt.Println(strings.Join(headers, delim))
-	for _, entry := range hostnames {
-
-		serialized := []string{
-			entry.Host,
-			strconv.Itoa(entry.Connections),
-			strconv.Itoa(entry.UniqueConnections),
-			strconv.Itoa(entry.TotalBytes),
+	var results interface{} = data
+	if devices != nil {
+		activity := make([]sourceActivity, len(data))
+		for idx, result := range data {
+			activity[idx] = sourceActivity{hosts: result.ConnectedHosts}
 		}
-
-		var sourceIPs []string
-		if showNetNames {
-			for _, connectedUniqIP := range entry.ConnectedHosts {
-				escapedNetName := strings.ReplaceAll(connectedUniqIP.NetworkName, " ", "_")
-				escapedNetName = strings.ReplaceAll(escapedNetName, ":", "_")
-				connectedIPStr := escapedNetName + ":" + connectedUniqIP.IP
-				sourceIPs = append(sourceIPs, connectedIPStr)
-			}
-		} else {
-			for _, connectedUniqIP := range entry.ConnectedHosts {
-				sourceIPs = append(sourceIPs, connectedUniqIP.IP)
-			}
+		results, header, rows, err = devices.annotate(data, header, rows, "Sources", activity)
+		if err != nil {
+			return cli.NewExitError(err.Error(), -1)
 		}
+	}
 
-		sort.Strings(sourceIPs)
-		serialized = append(serialized, strings.Join(sourceIPs, " "))
-
-		fmt.Println(
-			strings.Join(
-				serialized,
-				delim,
-			),
-		)
+	err = renderResults(format, results, header, rows, c.String("delimiter"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), -1)
 	}
 
 	return nil
 }
 
-func showBLHostnamesHuman(hostnames []blacklist.HostnameResult, showNetNames bool) error {
-	table := tablewriter.NewWriter(os.Stdout)
-	headers := []string{"Hostname", "Connections", "Unique Connections", "Total Bytes", "Sources"}
+// blHostnameRows formats blacklisted hostname results as a header and rows for tabular output
+func blHostnameRows(hostnames []blacklist.HostnameResult, showNetNames bool, human bool) ([]string, [][]string) {
+	headers := []string{"Host", "Connections", "Unique Connections", "Total Bytes", "Sources"}
+	if human {
+		headers[0] = "Hostname"
+	}
 
-	table.SetHeader(headers)
+	var rows [][]string
 	for _, entry := range hostnames {
-
-		serialized := []string{
+		rows = append(rows, []string{
 			entry.Host,
 			strconv.Itoa(entry.Connections),
 			strconv.Itoa(entry.UniqueConnections),
 			strconv.Itoa(entry.TotalBytes),
-		}
-
-		var sourceIPs []string
-		if showNetNames {
-			for _, connectedUniqIP := range entry.ConnectedHosts {
-				escapedNetName := strings.ReplaceAll(connectedUniqIP.NetworkName, " ", "_")
-				escapedNetName = strings.ReplaceAll(escapedNetName, ":", "_")
-				connectedIPStr := escapedNetName + ":" + connectedUniqIP.IP
-				sourceIPs = append(sourceIPs, connectedIPStr)
-			}
-		} else {
-			for _, connectedUniqIP := range entry.ConnectedHosts {
-				sourceIPs = append(sourceIPs, connectedUniqIP.IP)
-			}
-		}
-
-		sort.Strings(sourceIPs)
-		serialized = append(serialized, strings.Join(sourceIPs, " "))
-
-		table.Append(serialized)
+			joinPeerIPs(entry.ConnectedHosts, showNetNames),
+		})
 	}
-	table.Render()
-	return nil
+	return headers, rows
 }
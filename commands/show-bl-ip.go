@@ -1,15 +1,13 @@
 package commands
 
 import (
-	"fmt"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/activecm/rita/pkg/blacklist"
+	"github.com/activecm/rita/pkg/data"
 	"github.com/activecm/rita/resources"
-	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli"
 )
 
@@ -20,12 +18,15 @@ func init() {
 		Flags: []cli.Flag{
 			ConfigFlag,
 			humanFlag,
+			outputFlag,
 			blConnFlag,
 			blSortFlag,
 			limitFlag,
 			noLimitFlag,
 			delimFlag,
 			netNamesFlag,
+			devicesFlag,
+			usersFlag,
 		},
 		Usage:  "Print blacklisted IPs which initiated connections",
 		Action: printBLSourceIPs,
@@ -37,12 +38,15 @@ func init() {
 		Flags: []cli.Flag{
 			ConfigFlag,
 			humanFlag,
+			outputFlag,
 			blConnFlag,
 			blSortFlag,
 			limitFlag,
 			noLimitFlag,
 			delimFlag,
 			netNamesFlag,
+			devicesFlag,
+			usersFlag,
 		},
 		Usage:  "Print blacklisted IPs which received connections",
 		Action: printBLDestIPs,
@@ -51,56 +55,83 @@ func init() {
 	bootstrapCommands(blSourceIPs, blDestIPs)
 }
 
-func parseBLArgs(c *cli.Context) (string, string, bool, bool, bool, error) {
+func parseBLArgs(c *cli.Context) (string, string, bool, string, bool, error) {
 	db := c.Args().Get(0)
 	sort := c.String("sort")
 	connected := c.Bool("connected")
-	human := c.Bool("human-readable")
 	showNetNames := c.Bool("network-names")
-	var err error
-	if db == "" {
+	format, err := outputFormat(c)
+	if err != nil {
+		err = cli.NewExitError(err.Error(), -1)
+	} else if db == "" {
 		err = cli.NewExitError("Specify a database", -1)
 	} else if sort != "conn_count" && sort != "total_bytes" {
 		err = cli.NewExitError("Invalid option passed to sort flag", -1)
 	}
-	return db, sort, connected, human, showNetNames, err
+	return db, sort, connected, format, showNetNames, err
 }
 
 func printBLSourceIPs(c *cli.Context) error {
-	db, sort, connected, human, showNetNames, err := parseBLArgs(c)
+	db, sort, connected, format, showNetNames, err := parseBLArgs(c)
 	if err != nil {
 		return err
 	}
 	res := resources.InitResources(getConfigFilePath(c))
 	res.DB.SelectDB(db)
 
-	data, err := blacklist.SrcIPResults(res, sort, c.Int("limit"), c.Bool("no-limit"))
+	devices, err := newDeviceAnnotator(c, res, db)
+	if err != nil {
+		return err
+	}
+
+	users, err := newUserAnnotator(c, res, db)
+	if err != nil {
+		return err
+	}
+
+	blHosts, err := blacklist.SrcIPResults(res, sort, c.Int("limit"), c.Bool("no-limit"))
 
 	if err != nil {
 		res.Log.Error(err)
 		return cli.NewExitError(err, -1)
 	}
 
-	if len(data) == 0 {
+	if len(blHosts) == 0 {
 		return cli.NewExitError("No results were found for "+db, -1)
 	}
 
-	if human {
-		err = showBLIPsHuman(data, connected, showNetNames, true)
-		if err != nil {
-			return cli.NewExitError(err.Error(), -1)
+	header, rows := blIPRows(blHosts, connected, showNetNames, true)
+
+	var results interface{} = blHosts
+	if devices != nil || users != nil {
+		activity := make([]sourceActivity, len(blHosts))
+		for idx, result := range blHosts {
+			activity[idx] = sourceActivity{hosts: []data.UniqueIP{result.Host}}
+		}
+		// the devices are inserted right after the sources, ahead of the users
+		if users != nil {
+			results, header, rows, err = users.annotate(results, header, rows, "IP", activity)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
 		}
-	} else {
-		err = showBLIPs(data, connected, showNetNames, true, c.String("delimiter"))
-		if err != nil {
-			return cli.NewExitError(err.Error(), -1)
+		if devices != nil {
+			results, header, rows, err = devices.annotate(results, header, rows, "IP", activity)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
 		}
 	}
+
+	err = renderResults(format, results, header, rows, c.String("delimiter"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), -1)
+	}
 	return nil
 }
 
 func printBLDestIPs(c *cli.Context) error {
-	db, sort, connected, human, showNetNames, err := parseBLArgs(c)
+	db, sort, connected, format, showNetNames, err := parseBLArgs(c)
 	if err != nil {
 		return err
 	}
@@ -108,6 +139,16 @@ func printBLDestIPs(c *cli.Context) error {
 	res := resources.InitResources(getConfigFilePath(c))
 	res.DB.SelectDB(db)
 
+	devices, err := newDeviceAnnotator(c, res, db)
+	if err != nil {
+		return err
+	}
+
+	users, err := newUserAnnotator(c, res, db)
+	if err != nil {
+		return err
+	}
+
 	data, err := blacklist.DstIPResults(res, sort, c.Int("limit"), c.Bool("no-limit"))
 
 	if err != nil {
@@ -119,21 +160,38 @@ func printBLDestIPs(c *cli.Context) error {
 		return cli.NewExitError("No results were found for "+db, -1)
 	}
 
-	if human {
-		err = showBLIPsHuman(data, connected, showNetNames, false)
-		if err != nil {
-			return cli.NewExitError(err.Error(), -1)
+	header, rows := blIPRows(data, connected, showNetNames, false)
+
+	var results interface{} = data
+	if devices != nil || users != nil {
+		activity := make([]sourceActivity, len(data))
+		for idx, result := range data {
+			activity[idx] = sourceActivity{hosts: result.Peers}
 		}
-	} else {
-		err = showBLIPs(data, connected, showNetNames, false, c.String("delimiter"))
-		if err != nil {
-			return cli.NewExitError(err.Error(), -1)
+		// the devices are inserted right after the sources, ahead of the users
+		if users != nil {
+			results, header, rows, err = users.annotate(results, header, rows, "Sources", activity)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+		}
+		if devices != nil {
+			results, header, rows, err = devices.annotate(results, header, rows, "Sources", activity)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
 		}
 	}
+
+	err = renderResults(format, results, header, rows, c.String("delimiter"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), -1)
+	}
 	return nil
 }
 
-func showBLIPs(ips []blacklist.IPResult, connectedHosts, showNetNames, source bool, delim string) error {
+// blIPRows formats blacklisted IP results as a header and rows for tabular output
+func blIPRows(ips []blacklist.IPResult, connectedHosts, showNetNames, source bool) ([]string, [][]string) {
 	var headerFields []string
 	if !showNetNames && !connectedHosts {
 		headerFields = []string{"IP", "Connections", "Unique Connections", "Total Bytes"}
@@ -149,8 +207,7 @@ func showBLIPs(ips []blacklist.IPResult, connectedHosts, showNetNames, source bo
 		headerFields = []string{"IP", "Network", "Connections", "Unique Connections", "Total Bytes", "Sources"}
 	}
 
-	// Print the headerFields and analytic values, separated by a delimiter
-	fmt.Println(strings.Join(headerFields, delim))
+	var rows [][]string
 	for _, entry := range ips {
 
 		var serialized []string
@@ -167,86 +224,26 @@ func showBLIPs(ips []blacklist.IPResult, connectedHosts, showNetNames, source bo
 		)
 
 		if connectedHosts {
-			var connectedHostsIPs []string
-			if showNetNames {
-				for _, connectedUniqIP := range entry.Peers {
-					escapedNetName := strings.ReplaceAll(connectedUniqIP.NetworkName, " ", "_")
-					escapedNetName = strings.ReplaceAll(escapedNetName, ":", "_")
-					connectedIPStr := escapedNetName + ":" + connectedUniqIP.IP
-					connectedHostsIPs = append(connectedHostsIPs, connectedIPStr)
-				}
-			} else {
-				for _, connectedUniqIP := range entry.Peers {
-					connectedHostsIPs = append(connectedHostsIPs, connectedUniqIP.IP)
-				}
-			}
-			sort.Strings(connectedHostsIPs)
-			serialized = append(serialized, strings.Join(connectedHostsIPs, " "))
+			serialized = append(serialized, joinPeerIPs(entry.Peers, showNetNames))
 		}
-		fmt.Println(
-			strings.Join(
-				serialized,
-				delim,
-			),
-		)
+		rows = append(rows, serialized)
 	}
-	return nil
+	return headerFields, rows
 }
 
-func showBLIPsHuman(ips []blacklist.IPResult, connectedHosts, showNetNames, source bool) error {
-	table := tablewriter.NewWriter(os.Stdout)
-	var headerFields []string
-
-	if !showNetNames && !connectedHosts {
-		headerFields = []string{"IP", "Connections", "Unique Connections", "Total Bytes"}
-	} else if showNetNames && !connectedHosts {
-		headerFields = []string{"IP", "Network", "Connections", "Unique Connections", "Total Bytes"}
-	} else if !showNetNames && connectedHosts && source {
-		headerFields = []string{"IP", "Connections", "Unique Connections", "Total Bytes", "Destinations"}
-	} else if !showNetNames && connectedHosts && !source {
-		headerFields = []string{"IP", "Connections", "Unique Connections", "Total Bytes", "Sources"}
-	} else if showNetNames && connectedHosts && source {
-		headerFields = []string{"IP", "Network", "Connections", "Unique Connections", "Total Bytes", "Destinations"}
-	} else if showNetNames && connectedHosts && !source {
-		headerFields = []string{"IP", "Network", "Connections", "Unique Connections", "Total Bytes", "Sources"}
-	}
-
-	table.SetHeader(headerFields)
-	for _, entry := range ips {
-
-		var serialized []string
+// joinPeerIPs sorts and joins the given IPs with spaces. If showNetNames is set,
+// each IP is prefixed with its escaped network name.
+func joinPeerIPs(peers []data.UniqueIP, showNetNames bool) string {
+	var peerIPs []string
+	for _, peer := range peers {
+		peerIPStr := peer.IP
 		if showNetNames {
-			serialized = []string{entry.Host.IP, entry.Host.NetworkName}
-		} else {
-			serialized = []string{entry.Host.IP}
+			escapedNetName := strings.ReplaceAll(peer.NetworkName, " ", "_")
+			escapedNetName = strings.ReplaceAll(escapedNetName, ":", "_")
+			peerIPStr = escapedNetName + ":" + peer.IP
 		}
-
-		serialized = append(serialized,
-			strconv.Itoa(entry.Connections),
-			strconv.Itoa(entry.UniqueConnections),
-			strconv.Itoa(entry.TotalBytes),
-		)
-
-		if connectedHosts {
-			var connectedHostsIPs []string
-			for _, connectedUniqIP := range entry.Peers {
-
-				var connectedIPStr string
-				if showNetNames {
-					escapedNetName := strings.ReplaceAll(connectedUniqIP.NetworkName, " ", "_")
-					escapedNetName = strings.ReplaceAll(escapedNetName, ":", "_")
-					connectedIPStr = escapedNetName + ":" + connectedUniqIP.IP
-				} else {
-					connectedIPStr = connectedUniqIP.IP
-				}
-
-				connectedHostsIPs = append(connectedHostsIPs, connectedIPStr)
-			}
-			sort.Strings(connectedHostsIPs)
-			serialized = append(serialized, strings.Join(connectedHostsIPs, " "))
-		}
-		table.Append(serialized)
+		peerIPs = append(peerIPs, peerIPStr)
 	}
-	table.Render()
-	return nil
+	sort.Strings(peerIPs)
+	return strings.Join(peerIPs, " ")
 }
@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/pkg/certificate"
+	"github.com/activecm/rita/resources"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	command := cli.Command{
+
+		Name:      "show-certificates",
+		Usage:     "Print TLS certificates presented by servers, scored by how risky they look",
+		ArgsUsage: "<database>",
+		Flags: []cli.Flag{
+			ConfigFlag,
+			humanFlag,
+			outputFlag,
+			limitFlag,
+			noLimitFlag,
+			delimFlag,
+			netNamesFlag,
+			dstFlag,
+			minScoreFlag,
+		},
+		Action: func(c *cli.Context) error {
+			db := c.Args().Get(0)
+			if db == "" {
+				return cli.NewExitError("Specify a database", -1)
+			}
+
+			filt, err := parseFilter(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			res := resources.InitResources(getConfigFilePath(c))
+			res.DB.SelectDB(db)
+
+			if err := checkModuleAvailable(res, db, res.Config.T.Cert.CertificateTable); err != nil {
+				return err
+			}
+
+			data, err := certificate.Results(res, c.Int("limit"), c.Bool("no-limit"), filt)
+
+			if err != nil {
+				res.Log.Error(err)
+				return cli.NewExitError(err, -1)
+			}
+
+			if len(data) == 0 {
+				return cli.NewExitError("No results were found for "+db, -1)
+			}
+
+			format, err := outputFormat(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			header, rows := certificateRows(data, c.Bool("network-names"))
+			if format == outputTable {
+				table := tablewriter.NewWriter(os.Stdout)
+				table.SetHeader(header)
+				table.AppendBulk(rows)
+				table.Render()
+				return nil
+			}
+			err = renderResults(format, data, header, rows, c.String("delimiter"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+			return nil
+		},
+	}
+	bootstrapCommands(command)
+}
+
+// certificateRows formats certificate results as a header and rows for tabular output
+func certificateRows(certs []certificate.Result, showNetNames bool) ([]string, [][]string) {
+	headers := []string{
+		"Score", "Risks", "Subject", "Issuer", "SANs", "Not Before", "Not After",
+		"Key Type", "Key Length", "Servers", "Times Seen", "Fingerprint",
+	}
+
+	var rows [][]string
+	for _, cert := range certs {
+		rows = append(rows, []string{
+			f(cert.Score), strings.Join(cert.Risks, " "), cert.Subject, cert.Issuer,
+			strings.Join(cert.SANs, " "), certificateTime(cert.NotBefore), certificateTime(cert.NotAfter),
+			cert.KeyType, i(int64(cert.KeyLength)), joinPeerIPs(cert.Servers, showNetNames), i(cert.Seen), cert.Fingerprint,
+		})
+	}
+	return headers, rows
+}
+
+// certificateTime formats the start or end of a certificate's validity window
+func certificateTime(ts int64) string {
+	if ts == 0 {
+		return ""
+	}
+	return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+}
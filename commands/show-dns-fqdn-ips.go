@@ -1,14 +1,9 @@
 package commands
 
 import (
-	"fmt"
-	"os"
-	"strings"
-
 	"github.com/activecm/rita/pkg/data"
 	"github.com/activecm/rita/pkg/hostname"
 	"github.com/activecm/rita/resources"
-	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli"
 )
 
@@ -20,6 +15,7 @@ func init() {
 		Flags: []cli.Flag{
 			ConfigFlag,
 			humanFlag,
+			outputFlag,
 			delimFlag,
 			netNamesFlag,
 		},
@@ -37,6 +33,10 @@ func showFqdnIps(c *cli.Context) error {
 	res := resources.InitResources(getConfigFilePath(c))
 	res.DB.SelectDB(db)
 
+	if err := checkModuleAvailable(res, db, res.Config.T.DNS.HostnamesTable); err != nil {
+		return err
+	}
+
 	ipResults, err := hostname.IPResults(res, fqdn)
 
 	if err != nil {
@@ -48,57 +48,22 @@ func showFqdnIps(c *cli.Context) error {
 		return cli.NewExitError("No results were found for "+db, -1)
 	}
 
-	showNetNames := c.Bool("network-names")
-
-	if c.Bool("human-readable") {
-		err := showFqdnIpsHuman(ipResults, showNetNames)
-		if err != nil {
-			return cli.NewExitError(err.Error(), -1)
-		}
-		return nil
-	}
-
-	err = showFqdnIpsDelim(ipResults, c.String("delimiter"), showNetNames)
+	format, err := outputFormat(c)
 	if err != nil {
 		return cli.NewExitError(err.Error(), -1)
 	}
 
-	return nil
-}
-
-func showFqdnIpsHuman(data []data.UniqueIP, showNetNames bool) error {
-	table := tablewriter.NewWriter(os.Stdout)
-	var headerFields []string
-	if showNetNames {
-		headerFields = []string{
-			"Resolved IP", "Network",
-		}
-	} else {
-		headerFields = []string{
-			"Resolved IP",
-		}
+	header, rows := fqdnIPRows(ipResults, c.Bool("network-names"))
+	err = renderResults(format, ipResults, header, rows, c.String("delimiter"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), -1)
 	}
 
-	table.SetHeader(headerFields)
-
-	for _, d := range data {
-		var row []string
-		if showNetNames {
-			row = []string{
-				d.IP, d.NetworkName,
-			}
-		} else {
-			row = []string{
-				d.IP,
-			}
-		}
-		table.Append(row)
-	}
-	table.Render()
 	return nil
 }
 
-func showFqdnIpsDelim(data []data.UniqueIP, delim string, showNetNames bool) error {
+// fqdnIPRows formats resolved IPs as a header and rows for tabular output
+func fqdnIPRows(data []data.UniqueIP, showNetNames bool) ([]string, [][]string) {
 	var headerFields []string
 	if showNetNames {
 		headerFields = []string{
@@ -110,8 +75,7 @@ func showFqdnIpsDelim(data []data.UniqueIP, delim string, showNetNames bool) err
 		}
 	}
 
-	// Print the headers and analytic values, separated by a delimiter
-	fmt.Println(strings.Join(headerFields, delim))
+	var rows [][]string
 	for _, d := range data {
 		var row []string
 		if showNetNames {
@@ -123,8 +87,7 @@ func showFqdnIpsDelim(data []data.UniqueIP, delim string, showNetNames bool) err
 				d.IP,
 			}
 		}
-
-		fmt.Println(strings.Join(row, delim))
+		rows = append(rows, row)
 	}
-	return nil
+	return headerFields, rows
 }
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/activecm/rita/pkg/dnstunnel"
+	"github.com/activecm/rita/resources"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	command := cli.Command{
+
+		Name:      "show-dns-tunnels",
+		Usage:     "Print domains scored by their likelihood of being used for DNS tunneling",
+		ArgsUsage: "<database>",
+		Flags: []cli.Flag{
+			ConfigFlag,
+			humanFlag,
+			outputFlag,
+			limitFlag,
+			noLimitFlag,
+			delimFlag,
+			minScoreFlag,
+			fqdnFlag,
+		},
+		Action: func(c *cli.Context) error {
+			db := c.Args().Get(0)
+			if db == "" {
+				return cli.NewExitError("Specify a database", -1)
+			}
+
+			filt, err := parseFilter(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			res := resources.InitResources(getConfigFilePath(c))
+			res.DB.SelectDB(db)
+
+			if err := checkModuleAvailable(res, db, res.Config.T.DNS.DNSTunnelTable); err != nil {
+				return err
+			}
+
+			data, err := dnstunnel.Results(res, c.Int("limit"), c.Bool("no-limit"), filt)
+
+			if err != nil {
+				res.Log.Error(err)
+				return cli.NewExitError(err, -1)
+			}
+
+			if len(data) == 0 {
+				return cli.NewExitError("No results were found for "+db, -1)
+			}
+
+			format, err := outputFormat(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			header, rows := tunnelRows(data)
+			if format == outputTable {
+				table := tablewriter.NewWriter(os.Stdout)
+				table.SetHeader(header)
+				table.AppendBulk(rows)
+				table.Render()
+				return nil
+			}
+			err = renderResults(format, data, header, rows, c.String("delimiter"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+			return nil
+		},
+	}
+	bootstrapCommands(command)
+}
+
+// tunnelRows formats dns tunnel results as a header and rows for tabular output
+func tunnelRows(tunnelResults []dnstunnel.Result) ([]string, [][]string) {
+	headers := []string{
+		"Score", "Domain", "Queries", "Unique Subdomains", "Max Client Subdomains",
+		"Avg Entropy", "Avg Label Length", "Max Label Length", "TXT/NULL/CNAME Ratio", "Bytes Per Query",
+	}
+
+	var rows [][]string
+	for _, result := range tunnelResults {
+		rows = append(rows, []string{
+			f(result.Score), result.Domain, i(result.Queries), i(result.UniqueSubdomains),
+			i(result.MaxClientSubdomains), f(result.AvgEntropy), f(result.AvgLabelLength),
+			i(result.MaxLabelLength), f(result.QTypeRatio), f(result.BytesPerQuery),
+		})
+	}
+	return headers, rows
+}
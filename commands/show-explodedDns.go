@@ -2,7 +2,6 @@ package commands
 
 import (
 	"bytes"
-	"fmt"
 	"os"
 	"strings"
 
@@ -21,9 +20,11 @@ func init() {
 		Flags: []cli.Flag{
 			ConfigFlag,
 			humanFlag,
+			outputFlag,
 			limitFlag,
 			noLimitFlag,
 			delimFlag,
+			fqdnFlag,
 		},
 		Action: func(c *cli.Context) error {
 			db := c.Args().Get(0)
@@ -31,10 +32,19 @@ func init() {
 				return cli.NewExitError("Specify a database", -1)
 			}
 
+			filt, err := parseFilter(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
 			res := resources.InitResources(getConfigFilePath(c))
 			res.DB.SelectDB(db)
 
-			data, err := explodeddns.Results(res, c.Int("limit"), c.Bool("no-limit"))
+			if err := checkModuleAvailable(res, db, res.Config.T.DNS.ExplodedDNSTable); err != nil {
+				return err
+			}
+
+			data, err := explodeddns.Results(res, c.Int("limit"), c.Bool("no-limit"), filt)
 
 			if err != nil {
 				res.Log.Error(err)
@@ -45,14 +55,17 @@ func init() {
 				return cli.NewExitError("No results were found for "+db, -1)
 			}
 
-			if c.Bool("human-readable") {
-				err := showDNSResultsHuman(data)
-				if err != nil {
-					return cli.NewExitError(err.Error(), -1)
-				}
+			format, err := outputFormat(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			if format == outputTable {
+				showDNSResultsHuman(data)
 				return nil
 			}
-			err = showDNSResults(data, c.String("delimiter"))
+			header, rows := dnsRows(data)
+			err = renderResults(format, data, header, rows, c.String("delimiter"))
 			if err != nil {
 				return cli.NewExitError(err.Error(), -1)
 			}
@@ -82,23 +95,18 @@ func splitSubN(s string, n int) []string {
 	return subs
 }
 
-func showDNSResults(dnsResults []explodeddns.Result, delim string) error {
+// dnsRows formats exploded dns results as a header and rows for tabular output
+func dnsRows(dnsResults []explodeddns.Result) ([]string, [][]string) {
 	headers := []string{"Domain", "Unique Subdomains", "Times Looked Up"}
 
-	// Print the headers and analytic values, separated by a delimiter
-	fmt.Println(strings.Join(headers, delim))
+	var rows [][]string
 	for _, result := range dnsResults {
-		fmt.Println(
-			strings.Join(
-				[]string{result.Domain, i(result.SubdomainCount), i(result.Visited)},
-				delim,
-			),
-		)
+		rows = append(rows, []string{result.Domain, i(result.SubdomainCount), i(result.Visited)})
 	}
-	return nil
+	return headers, rows
 }
 
-func showDNSResultsHuman(dnsResults []explodeddns.Result) error {
+func showDNSResultsHuman(dnsResults []explodeddns.Result) {
 	const DOMAINRECLEN = 80
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetAutoWrapText(true)
@@ -117,5 +125,4 @@ func showDNSResultsHuman(dnsResults []explodeddns.Result) error {
 		})
 	}
 	table.Render()
-	return nil
 }
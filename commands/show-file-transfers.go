@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/filetransfer"
+	"github.com/activecm/rita/resources"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	command := cli.Command{
+
+		Name:      "show-file-transfers",
+		Usage:     "Print executables, scripts, and archives downloaded from rare or new servers, files matching IOC hashes, and large uploads",
+		ArgsUsage: "<database>",
+		Flags: []cli.Flag{
+			ConfigFlag,
+			humanFlag,
+			outputFlag,
+			limitFlag,
+			noLimitFlag,
+			delimFlag,
+			netNamesFlag,
+			devicesFlag,
+			usersFlag,
+			srcFlag,
+			dstFlag,
+			sinceFlag,
+			untilFlag,
+			fqdnFlag,
+			uploadsFlag,
+		},
+		Action: func(c *cli.Context) error {
+			db := c.Args().Get(0)
+			if db == "" {
+				return cli.NewExitError("Specify a database", -1)
+			}
+
+			filt, err := parseFilter(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			res := resources.InitResources(getConfigFilePath(c))
+			res.DB.SelectDB(db)
+
+			table := res.Config.T.FileTransfer.FileTransferTable
+			if c.Bool("uploads") {
+				table = res.Config.T.FileTransfer.FileUploadTable
+			}
+			if err := checkModuleAvailable(res, db, table); err != nil {
+				return err
+			}
+
+			devices, err := newDeviceAnnotator(c, res, db)
+			if err != nil {
+				return err
+			}
+
+			users, err := newUserAnnotator(c, res, db)
+			if err != nil {
+				return err
+			}
+
+			format, err := outputFormat(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			var results interface{}
+			var header []string
+			var rows [][]string
+			var activity []sourceActivity
+
+			if c.Bool("uploads") {
+				minBytes := int64(res.Config.S.FileTransfer.LargeUploadMegabytes) * 1024 * 1024
+				uploads, err := filetransfer.UploadResults(res, c.Int("limit"), c.Bool("no-limit"), filt, minBytes)
+				if err != nil {
+					res.Log.Error(err)
+					return cli.NewExitError(err, -1)
+				}
+
+				if len(uploads) == 0 {
+					return cli.NewExitError("No results were found for "+db, -1)
+				}
+
+				results = uploads
+				header, rows = fileUploadRows(uploads, c.Bool("network-names"))
+				activity = make([]sourceActivity, len(uploads))
+				for idx, result := range uploads {
+					activity[idx] = sourceActivity{hosts: []data.UniqueIP{result.UniqueSrcIP.Unpair()}, from: result.FirstSeen, to: result.LastSeen}
+				}
+			} else {
+				transfers, err := filetransfer.Results(res, c.Int("limit"), c.Bool("no-limit"), filt)
+				if err != nil {
+					res.Log.Error(err)
+					return cli.NewExitError(err, -1)
+				}
+
+				if len(transfers) == 0 {
+					return cli.NewExitError("No results were found for "+db, -1)
+				}
+
+				results = transfers
+				header, rows = fileTransferRows(transfers, c.Bool("network-names"))
+				activity = make([]sourceActivity, len(transfers))
+				for idx, result := range transfers {
+					activity[idx] = sourceActivity{hosts: []data.UniqueIP{result.UniqueSrcIP.Unpair()}, from: result.TimeStamp, to: result.TimeStamp}
+				}
+			}
+
+			// the devices are inserted right after the sources, ahead of the users
+			if users != nil {
+				results, header, rows, err = users.annotate(results, header, rows, "Source IP", activity)
+				if err != nil {
+					return cli.NewExitError(err.Error(), -1)
+				}
+			}
+			if devices != nil {
+				results, header, rows, err = devices.annotate(results, header, rows, "Source IP", activity)
+				if err != nil {
+					return cli.NewExitError(err.Error(), -1)
+				}
+			}
+
+			err = renderResults(format, results, header, rows, c.String("delimiter"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+			return nil
+		},
+	}
+	bootstrapCommands(command)
+}
+
+// fileTransferRows formats file transfer results as a header and rows for tabular output
+func fileTransferRows(results []filetransfer.Result, showNetNames bool) ([]string, [][]string) {
+	headers := []string{
+		"Detections", "Timestamp", "Source IP", "Destination IP", "FQDN", "Protocol", "Direction",
+		"Category", "MIME Type", "Filename", "Bytes", "FQDN Sources", "MD5", "SHA1", "SHA256", "IOC",
+	}
+	if showNetNames {
+		headers = append([]string{headers[0], headers[1], "Source Network", "Destination Network"}, headers[2:]...)
+	}
+
+	var rows [][]string
+	for _, result := range results {
+		row := []string{strings.Join(result.Detections, " "), transferTime(result.TimeStamp)}
+		if showNetNames {
+			row = append(row, result.SrcNetworkName, result.DstNetworkName)
+		}
+		row = append(row,
+			result.SrcIP, result.DstIP, result.FQDN, result.Protocol, result.Direction,
+			result.Category, result.MimeType, result.Filename, i(result.Bytes), i(result.FQDNSources),
+			result.MD5, result.SHA1, result.SHA256, result.IOC,
+		)
+		rows = append(rows, row)
+	}
+	return headers, rows
+}
+
+// fileUploadRows formats upload results as a header and rows for tabular output
+func fileUploadRows(results []filetransfer.UploadResult, showNetNames bool) ([]string, [][]string) {
+	headers := []string{"Source IP", "Bytes", "Files", "Destinations", "FQDNs", "First Seen", "Last Seen"}
+	if showNetNames {
+		headers = append([]string{"Source Network"}, headers...)
+	}
+
+	var rows [][]string
+	for _, result := range results {
+		var row []string
+		if showNetNames {
+			row = append(row, result.SrcNetworkName)
+		}
+		row = append(row,
+			result.SrcIP, i(result.Bytes), i(result.Files), strings.Join(result.Destinations, " "),
+			strings.Join(result.FQDNs, " "), transferTime(result.FirstSeen), transferTime(result.LastSeen),
+		)
+		rows = append(rows, row)
+	}
+	return headers, rows
+}
+
+// transferTime formats the time of a file transfer
+func transferTime(ts int64) string {
+	if ts == 0 {
+		return ""
+	}
+	return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/activecm/rita/parser/files"
+	"github.com/activecm/rita/resources"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	command := cli.Command{
+
+		Name:      "show-import-stats",
+		Usage:     "Print how many records of each imported file were parsed, filtered, and rejected",
+		ArgsUsage: "<database>",
+		Flags: []cli.Flag{
+			ConfigFlag,
+			humanFlag,
+			outputFlag,
+			delimFlag,
+		},
+		Action: func(c *cli.Context) error {
+			db := c.Args().Get(0)
+			if db == "" {
+				return cli.NewExitError("Specify a database", -1)
+			}
+
+			res := resources.InitResources(getConfigFilePath(c))
+
+			indexedFiles, err := res.MetaDB.GetFiles(db)
+			if err != nil {
+				res.Log.Error(err)
+				return cli.NewExitError(err, -1)
+			}
+
+			if len(indexedFiles) == 0 {
+				return cli.NewExitError("No imported files were found for "+db, -1)
+			}
+
+			format, err := outputFormat(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			data := importStatsResults(indexedFiles)
+			header, rows := importStatsRows(data)
+			err = renderResults(format, data, header, rows, c.String("delimiter"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+			return nil
+		},
+	}
+	bootstrapCommands(command)
+}
+
+// importStatsResult holds the parse statistics of a single imported file
+type importStatsResult struct {
+	File       string `json:"file"`
+	Collection string `json:"collection"`
+	files.ParseStats
+}
+
+// importStatsResults pairs the parse statistics of each file with its name, sorted by name
+func importStatsResults(indexedFiles []files.IndexedFile) []importStatsResult {
+	results := make([]importStatsResult, 0, len(indexedFiles))
+	for _, indexedFile := range indexedFiles {
+		results = append(results, importStatsResult{
+			File:       indexedFile.Name(),
+			Collection: indexedFile.TargetCollection,
+			ParseStats: indexedFile.Stats,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].File < results[j].File })
+	return results
+}
+
+// importStatsRows formats parse statistics as a header and rows for tabular output
+func importStatsRows(results []importStatsResult) ([]string, [][]string) {
+	headers := []string{"File", "Collection", "Lines Read", "Lines Skipped", "Records Parsed",
+		"Records Filtered", "Filtered By", "Records Rejected", "Rejected For"}
+
+	var rows [][]string
+	for _, result := range results {
+		rows = append(rows, []string{
+			result.File, result.Collection,
+			i(result.LinesRead), i(result.LinesSkipped), i(result.RecordsParsed),
+			i(result.RecordsFiltered), formatCounts(result.FilteredBy),
+			i(result.RecordsRejected), formatCounts(result.RejectedFor),
+		})
+	}
+	return headers, rows
+}
+
+// formatCounts lists the counts of each filter rule or reject reason, e.g. "external_to_external: 5"
+func formatCounts(counts map[string]int64) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s: %d", name, counts[name]))
+	}
+	return strings.Join(parts, "; ")
+}
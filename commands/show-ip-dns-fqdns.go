@@ -1,12 +1,8 @@
 package commands
 
 import (
-	"fmt"
-	"os"
-
 	"github.com/activecm/rita/pkg/hostname"
 	"github.com/activecm/rita/resources"
-	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli"
 )
 
@@ -18,6 +14,7 @@ func init() {
 		Flags: []cli.Flag{
 			ConfigFlag,
 			humanFlag,
+			outputFlag,
 			delimFlag,
 		},
 		Action: showIPFqdns,
@@ -35,6 +32,10 @@ func showIPFqdns(c *cli.Context) error {
 	res := resources.InitResources(getConfigFilePath(c))
 	res.DB.SelectDB(db)
 
+	if err := checkModuleAvailable(res, db, res.Config.T.DNS.HostnamesTable); err != nil {
+		return err
+	}
+
 	fqdnResults, err := hostname.FQDNResults(res, ip)
 
 	if err != nil {
@@ -46,15 +47,13 @@ func showIPFqdns(c *cli.Context) error {
 		return cli.NewExitError("No results were found for "+db, -1)
 	}
 
-	if c.Bool("human-readable") {
-		err := showIPFqdnsHuman(fqdnResults)
-		if err != nil {
-			return cli.NewExitError(err.Error(), -1)
-		}
-		return nil
+	format, err := outputFormat(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), -1)
 	}
 
-	err = showIPFqdnsRaw(fqdnResults)
+	header, rows := ipFqdnRows(fqdnResults)
+	err = renderResults(format, fqdnResults, header, rows, c.String("delimiter"))
 	if err != nil {
 		return cli.NewExitError(err.Error(), -1)
 	}
@@ -62,28 +61,15 @@ func showIPFqdns(c *cli.Context) error {
 	return nil
 }
 
-func showIPFqdnsHuman(data []*hostname.FQDNResult) error {
-	table := tablewriter.NewWriter(os.Stdout)
+// ipFqdnRows formats queried FQDNs as a header and rows for tabular output
+func ipFqdnRows(data []*hostname.FQDNResult) ([]string, [][]string) {
 	headerFields := []string{
 		"Queried FQDN",
 	}
 
-	table.SetHeader(headerFields)
-
-	for _, d := range data {
-		row := []string{
-			d.Hostname,
-		}
-		table.Append(row)
-	}
-	table.Render()
-	return nil
-}
-
-func showIPFqdnsRaw(data []*hostname.FQDNResult) error {
-	fmt.Println("Queried FQDN")
+	var rows [][]string
 	for _, d := range data {
-		fmt.Println(d.Hostname)
+		rows = append(rows, []string{d.Hostname})
 	}
-	return nil
+	return headerFields, rows
 }
@@ -1,15 +1,13 @@
 package commands
 
 import (
-	"fmt"
-	"os"
 	"strings"
 	"time"
 
+	"github.com/activecm/rita/pkg/data"
 	"github.com/activecm/rita/pkg/uconn"
 	"github.com/activecm/rita/resources"
 	"github.com/activecm/rita/util"
-	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli"
 )
 
@@ -22,10 +20,17 @@ func init() {
 		Flags: []cli.Flag{
 			ConfigFlag,
 			humanFlag,
+			outputFlag,
 			limitFlag,
 			noLimitFlag,
 			delimFlag,
 			netNamesFlag,
+			devicesFlag,
+			usersFlag,
+			srcFlag,
+			dstFlag,
+			sinceFlag,
+			untilFlag,
 		},
 		Action: func(c *cli.Context) error {
 			db := c.Args().Get(0)
@@ -33,29 +38,65 @@ func init() {
 				return cli.NewExitError("Specify a database", -1)
 			}
 
+			filt, err := parseFilter(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
 			res := resources.InitResources(getConfigFilePath(c))
 			res.DB.SelectDB(db)
 
+			devices, err := newDeviceAnnotator(c, res, db)
+			if err != nil {
+				return err
+			}
+
+			users, err := newUserAnnotator(c, res, db)
+			if err != nil {
+				return err
+			}
+
 			thresh := 60 // 1 minute
-			data, err := uconn.LongConnResults(res, thresh, c.Int("limit"), c.Bool("no-limit"))
+			longConns, err := uconn.LongConnResults(res, thresh, c.Int("limit"), c.Bool("no-limit"), filt)
 
 			if err != nil {
 				res.Log.Error(err)
 				return cli.NewExitError(err, -1)
 			}
 
-			if !(len(data) > 0) {
+			if !(len(longConns) > 0) {
 				return cli.NewExitError("No results were found for "+db, -1)
 			}
 
-			if c.Bool("human-readable") {
-				err := showConnsHuman(data, c.Bool("network-names"))
-				if err != nil {
-					return cli.NewExitError(err.Error(), -1)
+			format, err := outputFormat(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			header, rows := longConnRows(longConns, c.Bool("network-names"), format == outputTable)
+
+			var results interface{} = longConns
+			if devices != nil || users != nil {
+				activity := make([]sourceActivity, len(longConns))
+				for idx, result := range longConns {
+					activity[idx] = sourceActivity{hosts: []data.UniqueIP{result.UniqueSrcIP.Unpair()}}
+				}
+				// the devices are inserted right after the sources, ahead of the users
+				if users != nil {
+					results, header, rows, err = users.annotate(results, header, rows, "Source IP", activity)
+					if err != nil {
+						return cli.NewExitError(err.Error(), -1)
+					}
+				}
+				if devices != nil {
+					results, header, rows, err = devices.annotate(results, header, rows, "Source IP", activity)
+					if err != nil {
+						return cli.NewExitError(err.Error(), -1)
+					}
 				}
-				return nil
 			}
-			err = showConns(data, c.String("delimiter"), c.Bool("network-names"))
+
+			err = renderResults(format, results, header, rows, c.String("delimiter"))
 			if err != nil {
 				return cli.NewExitError(err.Error(), -1)
 			}
@@ -65,8 +106,9 @@ func init() {
 	bootstrapCommands(command)
 }
 
-func showConns(connResults []uconn.LongConnResult, delim string, showNetNames bool) error {
-
+// longConnRows formats long connection results as a header and rows for tabular output.
+// Durations are printed in a human friendly format when human is set.
+func longConnRows(connResults []uconn.LongConnResult, showNetNames bool, human bool) ([]string, [][]string) {
 	var headerFields []string
 	if showNetNames {
 		headerFields = []string{"Source Network", "Destination Network", "Source IP", "Destination IP", "Port:Protocol:Service", "Total Duration", "Longest Duration", "Connections", "Total Bytes", "State"}
@@ -74,8 +116,7 @@ func showConns(connResults []uconn.LongConnResult, delim string, showNetNames bo
 		headerFields = []string{"Source IP", "Destination IP", "Port:Protocol:Service", "Total Duration", "Longest Duration", "Connections", "Total Bytes", "State"}
 	}
 
-	// Print the headers and analytic values, separated by a delimiter
-	fmt.Println(strings.Join(headerFields, delim))
+	var rows [][]string
 	for _, result := range connResults {
 		var row []string
 
@@ -85,55 +126,10 @@ func showConns(connResults []uconn.LongConnResult, delim string, showNetNames bo
 			state = "open"
 		}
 
-		if showNetNames {
-			row = []string{
-				result.SrcNetworkName,
-				result.DstNetworkName,
-				result.SrcIP,
-				result.DstIP,
-				strings.Join(result.Tuples, " "),
-				f(result.TotalDuration),
-				f(result.MaxDuration),
-				i(result.ConnectionCount),
-				i(result.TotalBytes),
-				state,
-			}
-		} else {
-			row = []string{
-				result.SrcIP,
-				result.DstIP,
-				strings.Join(result.Tuples, " "),
-				f(result.TotalDuration),
-				f(result.MaxDuration),
-				i(result.ConnectionCount),
-				i(result.TotalBytes),
-				state,
-			}
-		}
-
-		fmt.Println(strings.Join(row, delim))
-	}
-	return nil
-}
-
-func showConnsHuman(connResults []uconn.LongConnResult, showNetNames bool) error {
-	table := tablewriter.NewWriter(os.Stdout)
-
-	var headerFields []string
-	if showNetNames {
-		headerFields = []string{"Source Network", "Destination Network", "Source IP", "Destination IP", "Port:Protocol:Service", "Total Duration", "Longest Duration", "Connections", "Total Bytes", "State"}
-	} else {
-		headerFields = []string{"Source IP", "Destination IP", "Port:Protocol:Service", "Total Duration", "Longest Duration", "Connections", "Total Bytes", "State"}
-	}
-
-	table.SetHeader(headerFields)
-	for _, result := range connResults {
-		var row []string
-
-		// Convert the true/false open/closed state to a nice string
-		state := "closed"
-		if result.Open {
-			state = "open"
+		totalDuration, maxDuration := f(result.TotalDuration), f(result.MaxDuration)
+		if human {
+			totalDuration = util.FormatDuration(time.Duration(int(result.TotalDuration * float64(time.Second))))
+			maxDuration = util.FormatDuration(time.Duration(int(result.MaxDuration * float64(time.Second))))
 		}
 
 		if showNetNames {
@@ -143,8 +139,8 @@ func showConnsHuman(connResults []uconn.LongConnResult, showNetNames bool) error
 				result.SrcIP,
 				result.DstIP,
 				strings.Join(result.Tuples, " "),
-				util.FormatDuration(time.Duration(int(result.TotalDuration * float64(time.Second)))),
-				util.FormatDuration(time.Duration(int(result.MaxDuration * float64(time.Second)))),
+				totalDuration,
+				maxDuration,
 				i(result.ConnectionCount),
 				i(result.TotalBytes),
 				state,
@@ -154,16 +150,15 @@ func showConnsHuman(connResults []uconn.LongConnResult, showNetNames bool) error
 				result.SrcIP,
 				result.DstIP,
 				strings.Join(result.Tuples, " "),
-				util.FormatDuration(time.Duration(int(result.TotalDuration * float64(time.Second)))),
-				util.FormatDuration(time.Duration(int(result.MaxDuration * float64(time.Second)))),
+				totalDuration,
+				maxDuration,
 				i(result.ConnectionCount),
 				i(result.TotalBytes),
 				state,
 			}
 		}
 
-		table.Append(row)
+		rows = append(rows, row)
 	}
-	table.Render()
-	return nil
+	return headerFields, rows
 }
@@ -2,14 +2,13 @@ package commands
 
 import (
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/activecm/rita/pkg/data"
 	"github.com/activecm/rita/pkg/uconn"
 	"github.com/activecm/rita/resources"
-	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli"
 )
 
@@ -22,10 +21,12 @@ func init() {
 		Flags: []cli.Flag{
 			ConfigFlag,
 			humanFlag,
+			outputFlag,
 			limitFlag,
 			noLimitFlag,
 			delimFlag,
 			netNamesFlag,
+			devicesFlag,
 		},
 		Action: func(c *cli.Context) error {
 			db := c.Args().Get(0)
@@ -36,26 +37,43 @@ func init() {
 			res := resources.InitResources(getConfigFilePath(c))
 			res.DB.SelectDB(db)
 
+			devices, err := newDeviceAnnotator(c, res, db)
+			if err != nil {
+				return err
+			}
+
 			thresh := 60 // 1 minute
-			data, err := uconn.OpenConnResults(res, thresh, c.Int("limit"), c.Bool("no-limit"))
+			openConns, err := uconn.OpenConnResults(res, thresh, c.Int("limit"), c.Bool("no-limit"))
 
 			if err != nil {
 				res.Log.Error(err)
 				return cli.NewExitError(err, -1)
 			}
 
-			if !(len(data) > 0) {
+			if !(len(openConns) > 0) {
 				return cli.NewExitError("No results were found for "+db, -1)
 			}
 
-			if c.Bool("human-readable") {
-				err := showOpenConnsHuman(data, c.Bool("network-names"))
+			format, err := outputFormat(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			header, rows := openConnRows(openConns, c.Bool("network-names"))
+
+			var results interface{} = openConns
+			if devices != nil {
+				activity := make([]sourceActivity, len(openConns))
+				for idx, result := range openConns {
+					activity[idx] = sourceActivity{hosts: []data.UniqueIP{result.UniqueSrcIP.Unpair()}}
+				}
+				results, header, rows, err = devices.annotate(openConns, header, rows, "Source IP", activity)
 				if err != nil {
 					return cli.NewExitError(err.Error(), -1)
 				}
-				return nil
 			}
-			err = showOpenConns(data, c.String("delimiter"), c.Bool("network-names"))
+
+			err = renderResults(format, results, header, rows, c.String("delimiter"))
 			if err != nil {
 				return cli.NewExitError(err.Error(), -1)
 			}
@@ -92,50 +110,8 @@ func openDuration(d time.Duration) string {
 	return b.String()
 }
 
-func showOpenConns(connResults []uconn.OpenConnResult, delim string, showNetNames bool) error {
-
-	var headerFields []string
-	if showNetNames {
-		headerFields = []string{"Source Network", "Destination Network", "Source IP", "Destination IP", "Port:Protocol:Service", "Duration", "Bytes", "Zeek UID"}
-	} else {
-		headerFields = []string{"Source IP", "Destination IP", "Port:Protocol:Service", "Duration", "Bytes", "Zeek UID"}
-	}
-
-	// Print the headers and analytic values, separated by a delimiter
-	fmt.Println(strings.Join(headerFields, delim))
-	for _, result := range connResults {
-		var row []string
-
-		if showNetNames {
-			row = []string{
-				result.SrcNetworkName,
-				result.DstNetworkName,
-				result.SrcIP,
-				result.DstIP,
-				result.Tuple,
-				openDuration(time.Duration(int(result.Duration * float64(time.Second)))),
-				strconv.Itoa(result.Bytes),
-				result.UID,
-			}
-		} else {
-			row = []string{
-				result.SrcIP,
-				result.DstIP,
-				result.Tuple,
-				openDuration(time.Duration(int(result.Duration * float64(time.Second)))),
-				strconv.Itoa(result.Bytes),
-				result.UID,
-			}
-		}
-
-		fmt.Println(strings.Join(row, delim))
-	}
-	return nil
-}
-
-func showOpenConnsHuman(connResults []uconn.OpenConnResult, showNetNames bool) error {
-	table := tablewriter.NewWriter(os.Stdout)
-
+// openConnRows formats open connection results as a header and rows for tabular output
+func openConnRows(connResults []uconn.OpenConnResult, showNetNames bool) ([]string, [][]string) {
 	var headerFields []string
 	if showNetNames {
 		headerFields = []string{"Source Network", "Destination Network", "Source IP", "Destination IP", "Port:Protocol:Service", "Duration", "Bytes", "Zeek UID"}
@@ -143,7 +119,7 @@ func showOpenConnsHuman(connResults []uconn.OpenConnResult, showNetNames bool) e
 		headerFields = []string{"Source IP", "Destination IP", "Port:Protocol:Service", "Duration", "Bytes", "Zeek UID"}
 	}
 
-	table.SetHeader(headerFields)
+	var rows [][]string
 	for _, result := range connResults {
 		var row []string
 
@@ -169,8 +145,7 @@ func showOpenConnsHuman(connResults []uconn.OpenConnResult, showNetNames bool) e
 			}
 		}
 
-		table.Append(row)
+		rows = append(rows, row)
 	}
-	table.Render()
-	return nil
+	return headerFields, rows
 }
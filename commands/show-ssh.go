@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/sshconn"
+	"github.com/activecm/rita/resources"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	command := cli.Command{
+
+		Name:      "show-ssh",
+		Usage:     "Print SSH sessions between hosts, scored for brute force, password spraying, and beaconing",
+		ArgsUsage: "<database>",
+		Flags: []cli.Flag{
+			ConfigFlag,
+			humanFlag,
+			outputFlag,
+			limitFlag,
+			noLimitFlag,
+			delimFlag,
+			netNamesFlag,
+			devicesFlag,
+			srcFlag,
+			dstFlag,
+			minScoreFlag,
+		},
+		Action: func(c *cli.Context) error {
+			db := c.Args().Get(0)
+			if db == "" {
+				return cli.NewExitError("Specify a database", -1)
+			}
+
+			filt, err := parseFilter(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			res := resources.InitResources(getConfigFilePath(c))
+			res.DB.SelectDB(db)
+
+			if err := checkModuleAvailable(res, db, res.Config.T.Structure.SSHConnTable); err != nil {
+				return err
+			}
+
+			devices, err := newDeviceAnnotator(c, res, db)
+			if err != nil {
+				return err
+			}
+
+			sshConns, err := sshconn.Results(res, c.Int("limit"), c.Bool("no-limit"), filt)
+
+			if err != nil {
+				res.Log.Error(err)
+				return cli.NewExitError(err, -1)
+			}
+
+			if len(sshConns) == 0 {
+				return cli.NewExitError("No results were found for "+db, -1)
+			}
+
+			format, err := outputFormat(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			header, rows := sshRows(sshConns, c.Bool("network-names"))
+
+			var results interface{} = sshConns
+			if devices != nil {
+				activity := make([]sourceActivity, len(sshConns))
+				for idx, result := range sshConns {
+					activity[idx] = sourceActivity{hosts: []data.UniqueIP{result.UniqueSrcIP.Unpair()}, from: result.FirstSeen, to: result.LastSeen}
+				}
+				results, header, rows, err = devices.annotate(sshConns, header, rows, "Source IP", activity)
+				if err != nil {
+					return cli.NewExitError(err.Error(), -1)
+				}
+			}
+
+			if format == outputTable {
+				table := tablewriter.NewWriter(os.Stdout)
+				table.SetHeader(header)
+				table.AppendBulk(rows)
+				table.Render()
+				return nil
+			}
+			err = renderResults(format, results, header, rows, c.String("delimiter"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+			return nil
+		},
+	}
+	bootstrapCommands(command)
+}
+
+// sshRows formats SSH results as a header and rows for tabular output
+func sshRows(results []sshconn.Result, showNetNames bool) ([]string, [][]string) {
+	headers := []string{
+		"Score", "Detections", "Source IP", "Destination IP", "Ports", "Sessions", "Auth Attempts",
+		"Auth Successes", "Failed Sessions", "Spray Targets", "Beacon Score", "Client Versions",
+		"Server Versions", "HASSH", "HASSH Server",
+	}
+	if showNetNames {
+		headers = append([]string{headers[0], headers[1], "Source Network", "Destination Network"}, headers[2:]...)
+	}
+
+	var rows [][]string
+	for _, result := range results {
+		var ports []string
+		for _, port := range result.DstPorts {
+			ports = append(ports, strconv.Itoa(port))
+		}
+
+		row := []string{
+			f(result.Score), strings.Join(result.Detections, " "),
+		}
+		if showNetNames {
+			row = append(row, result.SrcNetworkName, result.DstNetworkName)
+		}
+		row = append(row,
+			result.SrcIP, result.DstIP, strings.Join(ports, " "), i(result.Sessions), i(result.AuthAttempts),
+			i(result.AuthSuccesses), i(result.FailedSessions), i(result.SprayTargets), f(result.BeaconScore),
+			strings.Join(result.ClientVersions, " "), strings.Join(result.ServerVersions, " "),
+			strings.Join(result.HASSHs, " "), strings.Join(result.HASSHServers, " "),
+		)
+		rows = append(rows, row)
+	}
+	return headers, rows
+}
@@ -1,11 +1,10 @@
 package commands
 
 import (
-	"fmt"
 	"os"
-	"strings"
 
 	"github.com/activecm/rita/pkg/beacon"
+	"github.com/activecm/rita/pkg/data"
 	"github.com/activecm/rita/resources"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli"
@@ -20,6 +19,7 @@ func init() {
 		Flags: []cli.Flag{
 			ConfigFlag,
 			humanFlag,
+			outputFlag,
 			cli.BoolFlag{
 				Name:  "connection-count, l",
 				Usage: "Sort the strobes by largest connection count.",
@@ -28,6 +28,10 @@ func init() {
 			noLimitFlag,
 			delimFlag,
 			netNamesFlag,
+			devicesFlag,
+			srcFlag,
+			dstFlag,
+			minConnsFlag,
 		},
 		Action: func(c *cli.Context) error {
 			db := c.Args().Get(0)
@@ -35,33 +39,59 @@ func init() {
 				return cli.NewExitError("Specify a database", -1)
 			}
 
+			filt, err := parseFilter(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
 			res := resources.InitResources(getConfigFilePath(c))
 			res.DB.SelectDB(db)
 
+			devices, err := newDeviceAnnotator(c, res, db)
+			if err != nil {
+				return err
+			}
+
 			sortDirection := -1
 			if !c.Bool("connection-count") {
 				sortDirection = 1
 			}
 
-			data, err := beacon.StrobeResults(res, sortDirection, c.Int("limit"), c.Bool("no-limit"))
+			strobes, err := beacon.StrobeResults(res, sortDirection, c.Int("limit"), c.Bool("no-limit"), filt)
 
 			if err != nil {
 				res.Log.Error(err)
 				return cli.NewExitError(err, -1)
 			}
 
-			if len(data) == 0 {
+			if len(strobes) == 0 {
 				return cli.NewExitError("No results were found for "+db, -1)
 			}
 
-			if c.Bool("human-readable") {
-				err := showStrobesHuman(data, c.Bool("network-names"))
+			format, err := outputFormat(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			header, rows := strobeRows(strobes, c.Bool("network-names"))
+
+			var results interface{} = strobes
+			if devices != nil {
+				activity := make([]sourceActivity, len(strobes))
+				for idx, result := range strobes {
+					activity[idx] = sourceActivity{hosts: []data.UniqueIP{result.UniqueSrcIP.Unpair()}}
+				}
+				results, header, rows, err = devices.annotate(strobes, header, rows, "Source", activity)
 				if err != nil {
 					return cli.NewExitError(err.Error(), -1)
 				}
+			}
+
+			if format == outputTable {
+				showStrobesHuman(header, rows)
 				return nil
 			}
-			err = showStrobes(data, c.String("delimiter"), c.Bool("network-names"))
+			err = renderResults(format, results, header, rows, c.String("delimiter"))
 			if err != nil {
 				return cli.NewExitError(err.Error(), -1)
 			}
@@ -71,7 +101,8 @@ func init() {
 	bootstrapCommands(command)
 }
 
-func showStrobes(strobes []beacon.StrobeResult, delim string, showNetNames bool) error {
+// strobeRows formats strobe results as a header and rows for tabular output
+func strobeRows(strobes []beacon.StrobeResult, showNetNames bool) ([]string, [][]string) {
 	var headerFields []string
 	if showNetNames {
 		headerFields = []string{"Source Network", "Destination Network", "Source", "Destination", "Connection Count"}
@@ -79,8 +110,7 @@ func showStrobes(strobes []beacon.StrobeResult, delim string, showNetNames bool)
 		headerFields = []string{"Source", "Destination", "Connection Count"}
 	}
 
-	// Print the headers and analytic values, separated by a delimiter
-	fmt.Println(strings.Join(headerFields, delim))
+	var rows [][]string
 	for _, strobe := range strobes {
 		var row []string
 		if showNetNames {
@@ -98,42 +128,15 @@ func showStrobes(strobes []beacon.StrobeResult, delim string, showNetNames bool)
 				i(strobe.ConnectionCount),
 			}
 		}
-		fmt.Println(strings.Join(row, delim))
+		rows = append(rows, row)
 	}
-	return nil
+	return headerFields, rows
 }
 
-func showStrobesHuman(strobes []beacon.StrobeResult, showNetNames bool) error {
+func showStrobesHuman(header []string, rows [][]string) {
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetColWidth(100)
-
-	var headerFields []string
-	if showNetNames {
-		headerFields = []string{"Source Network", "Destination Network", "Source", "Destination", "Connection Count"}
-	} else {
-		headerFields = []string{"Source", "Destination", "Connection Count"}
-	}
-	table.SetHeader(headerFields)
-
-	for _, strobe := range strobes {
-		var row []string
-		if showNetNames {
-			row = []string{
-				strobe.SrcNetworkName,
-				strobe.DstNetworkName,
-				strobe.SrcIP,
-				strobe.DstIP,
-				i(strobe.ConnectionCount),
-			}
-		} else {
-			row = []string{
-				strobe.SrcIP,
-				strobe.DstIP,
-				i(strobe.ConnectionCount),
-			}
-		}
-		table.Append(row)
-	}
+	table.SetHeader(header)
+	table.AppendBulk(rows)
 	table.Render()
-	return nil
 }
@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/threatscore"
+	"github.com/activecm/rita/resources"
+	"github.com/urfave/cli"
+)
+
+func init() {
+	command := cli.Command{
+
+		Name:      "show-threat-hunt",
+		Usage:     "Print internal hosts ranked by a threat score combining the results of every analysis",
+		ArgsUsage: "<database>",
+		Flags: []cli.Flag{
+			ConfigFlag,
+			humanFlag,
+			outputFlag,
+			limitFlag,
+			noLimitFlag,
+			delimFlag,
+			netNamesFlag,
+			devicesFlag,
+			srcFlag,
+			minScoreFlag,
+		},
+		Action: func(c *cli.Context) error {
+			db := c.Args().Get(0)
+			if db == "" {
+				return cli.NewExitError("Specify a database", -1)
+			}
+
+			filt, err := parseFilter(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			res := resources.InitResources(getConfigFilePath(c))
+			res.DB.SelectDB(db)
+
+			devices, err := newDeviceAnnotator(c, res, db)
+			if err != nil {
+				return err
+			}
+
+			scores, err := threatscore.Results(res, c.Int("limit"), c.Bool("no-limit"), filt)
+
+			if err != nil {
+				res.Log.Error(err)
+				return cli.NewExitError(err, -1)
+			}
+
+			if len(scores) == 0 {
+				return cli.NewExitError("No results were found for "+db, -1)
+			}
+
+			format, err := outputFormat(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			header, rows := threatRows(scores, c.Bool("network-names"))
+
+			var results interface{} = scores
+			if devices != nil {
+				activity := make([]sourceActivity, len(scores))
+				for idx, result := range scores {
+					activity[idx] = sourceActivity{hosts: []data.UniqueIP{result.Host}}
+				}
+				results, header, rows, err = devices.annotate(scores, header, rows, "Source IP", activity)
+				if err != nil {
+					return cli.NewExitError(err.Error(), -1)
+				}
+			}
+
+			err = renderResults(format, results, header, rows, c.String("delimiter"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+			return nil
+		},
+	}
+	bootstrapCommands(command)
+}
+
+// threatRows formats threat score results as a header and rows for tabular output
+func threatRows(hosts []threatscore.Result, showNetNames bool) ([]string, [][]string) {
+	var headers []string
+	if showNetNames {
+		headers = []string{"Score", "Source IP", "Source Network", "Contributing Factors"}
+	} else {
+		headers = []string{"Score", "Source IP", "Contributing Factors"}
+	}
+
+	var rows [][]string
+	for _, host := range hosts {
+		var factors []string
+		for _, factor := range host.Factors {
+			factors = append(factors, factor.Name+" "+f(factor.Score)+" ("+factor.Detail+")")
+		}
+
+		row := []string{f(host.Score), host.Host.IP}
+		if showNetNames {
+			row = append(row, host.Host.NetworkName)
+		}
+		row = append(row, strings.Join(factors, "; "))
+		rows = append(rows, row)
+	}
+	return headers, rows
+}
@@ -1,9 +1,7 @@
 package commands
 
 import (
-	"fmt"
 	"os"
-	"strings"
 
 	"github.com/activecm/rita/pkg/useragent"
 	"github.com/activecm/rita/resources"
@@ -20,6 +18,7 @@ func init() {
 		Flags: []cli.Flag{
 			ConfigFlag,
 			humanFlag,
+			outputFlag,
 			cli.BoolFlag{
 				Name:  "least-used, l",
 				Usage: "Sort the user agents from least used to most used.",
@@ -37,6 +36,10 @@ func init() {
 			res := resources.InitResources(getConfigFilePath(c))
 			res.DB.SelectDB(db)
 
+			if err := checkModuleAvailable(res, db, res.Config.T.UserAgent.UserAgentTable); err != nil {
+				return err
+			}
+
 			sortDirection := 1
 			if !c.Bool("least-used") {
 				sortDirection = -1
@@ -53,14 +56,17 @@ func init() {
 				return cli.NewExitError("No results were found for "+db, -1)
 			}
 
-			if c.Bool("human-readable") {
-				err := showAgentsHuman(data)
-				if err != nil {
-					return cli.NewExitError(err.Error(), -1)
-				}
+			format, err := outputFormat(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), -1)
+			}
+
+			header, rows := agentRows(data)
+			if format == outputTable {
+				showAgentsHuman(header, rows)
 				return nil
 			}
-			err = showAgents(data, c.String("delimiter"))
+			err = renderResults(format, data, header, rows, c.String("delimiter"))
 			if err != nil {
 				return cli.NewExitError(err.Error(), -1)
 			}
@@ -70,29 +76,21 @@ func init() {
 	bootstrapCommands(command)
 }
 
-func showAgents(agents []useragent.Result, delim string) error {
+// agentRows formats user agent results as a header and rows for tabular output
+func agentRows(agents []useragent.Result) ([]string, [][]string) {
 	headers := []string{"User Agent", "Times Used"}
 
-	// Print the headers and analytic values, separated by a delimiter
-	fmt.Println(strings.Join(headers, delim))
+	var rows [][]string
 	for _, agent := range agents {
-		fmt.Println(
-			strings.Join(
-				[]string{agent.UserAgent, i(agent.TimesUsed)},
-				delim,
-			),
-		)
+		rows = append(rows, []string{agent.UserAgent, i(agent.TimesUsed)})
 	}
-	return nil
+	return headers, rows
 }
 
-func showAgentsHuman(agents []useragent.Result) error {
+func showAgentsHuman(header []string, rows [][]string) {
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetColWidth(100)
-	table.SetHeader([]string{"User Agent", "Times Used"})
-	for _, agent := range agents {
-		table.Append([]string{agent.UserAgent, i(agent.TimesUsed)})
-	}
+	table.SetHeader(header)
+	table.AppendBulk(rows)
 	table.Render()
-	return nil
 }
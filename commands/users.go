@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/activecm/rita/pkg/logon"
+	"github.com/activecm/rita/resources"
+	"github.com/urfave/cli"
+)
+
+// userAnnotator annotates the source hosts of the results of a show-* command with the
+// user accounts which authenticated from them according to the Kerberos and NTLM logs
+type userAnnotator struct {
+	timeline *logon.Timeline
+}
+
+// newUserAnnotator loads the logon timeline of the database if the --users flag was given.
+// Otherwise, the returned annotator is nil.
+func newUserAnnotator(c *cli.Context, res *resources.Resources, db string) (*userAnnotator, error) {
+	if !c.Bool("users") {
+		return nil, nil
+	}
+
+	if err := checkModuleAvailable(res, db, res.Config.T.Structure.LogonTable); err != nil {
+		return nil, err
+	}
+
+	timeline, err := logon.LoadTimeline(res, db)
+	if err != nil {
+		res.Log.Error(err)
+		return nil, cli.NewExitError(err, -1)
+	}
+	if timeline.Empty() {
+		return nil, cli.NewExitError("No user logons were found for "+db+". Import the kerberos.log or ntlm.log to annotate hosts with users.", -1)
+	}
+
+	return &userAnnotator{timeline: timeline}, nil
+}
+
+// annotate adds a "Source Users" column after the column named after, or at the end of the rows
+// if there is no such column. The accounts are added to each result as "src_users" when the
+// results are rendered as JSON. Every account seen during the dataset is listed regardless of
+// when the activity happened.
+func (a *userAnnotator) annotate(results interface{}, header []string, rows [][]string,
+	after string, activity []sourceActivity) (interface{}, []string, [][]string, error) {
+
+	cells := make([]string, len(activity))
+	values := make([]interface{}, len(activity))
+	for idx := range activity {
+		accounts := a.timeline.Accounts(activity[idx].hosts)
+
+		var names []string
+		for _, account := range accounts {
+			names = append(names, account.Name)
+		}
+		cells[idx] = strings.Join(names, " ")
+
+		if accounts == nil {
+			accounts = []logon.Account{}
+		}
+		values[idx] = accounts
+	}
+
+	return annotateColumn(results, header, rows, after, "Source Users", "src_users", cells, values)
+}
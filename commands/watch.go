@@ -0,0 +1,420 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/parser"
+	"github.com/activecm/rita/parser/files"
+	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+// zeekArchiveName matches the name Zeek gives to rotated logs, e.g. conn.13:00:00-14:00:00.log.gz,
+// capturing the time the log was opened. Rotated logs may be compressed with any format RITA reads.
+var zeekArchiveName = regexp.MustCompile(`\.(\d{2}:\d{2}:\d{2})-\d{2}:\d{2}:\d{2}\.log(\.gz|\.zst|\.bz2|\.xz)?$`)
+
+func init() {
+	watchCommand := cli.Command{
+		Name:  "watch",
+		Usage: "Continuously import rotated zeek logs into a rolling database",
+		UsageText: "rita watch [command options] <zeek log directory> <database name>\n\n" +
+			"Logs in <zeek log directory> and its dated (YYYY-MM-DD) subdirectories are imported" +
+			" into the rolling database named <database name> as they are rotated. Each hour" +
+			" of logs is imported into the next chunk of the database.",
+		Flags: []cli.Flag{
+			ConfigFlag,
+			threadFlag,
+			totalChunksFlag,
+			cli.DurationFlag{
+				Name:  "interval, i",
+				Usage: "Check for new logs every `DURATION`. Logs modified within the last interval are assumed to still be written to",
+				Value: time.Minute,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			watcher := NewWatcher(c)
+			return watcher.run()
+		},
+	}
+
+	bootstrapCommands(watchCommand)
+}
+
+type (
+	//Watcher imports logs from a Zeek log directory as they are rotated
+	Watcher struct {
+		res             *resources.Resources
+		configFile      string
+		args            cli.Args
+		watchDir        string
+		targetDatabase  string
+		userTotalChunks int
+		interval        time.Duration
+		threads         int
+		// seen tracks the files which have already been considered for import
+		// so they are not re-hashed on every pass
+		seen map[string]watchedFile
+		// chunks maps the start of each imported hour (unix timestamp) to the chunk holding it
+		chunks map[int64]int
+	}
+
+	watchedFile struct {
+		size    int64
+		modTime time.Time
+	}
+)
+
+// NewWatcher creates a Watcher from the command line options
+func NewWatcher(c *cli.Context) *Watcher {
+	return &Watcher{
+		configFile:      getConfigFilePath(c),
+		args:            c.Args(),
+		userTotalChunks: c.Int("numchunks"),
+		interval:        c.Duration("interval"),
+		threads:         util.Max(c.Int("threads")/2, 1),
+		seen:            make(map[string]watchedFile),
+		chunks:          make(map[int64]int),
+	}
+}
+
+// parseArgs handles parsing the positional watch arguments
+func (w *Watcher) parseArgs() error {
+	if len(w.args) != 2 || w.args[0] == "" || w.args[1] == "" {
+		return cli.NewExitError("\n\t[!] Both <zeek log directory> and <database name> are required.", -1)
+	}
+
+	w.watchDir = w.args[0]
+	w.targetDatabase = w.args[1]
+
+	if !util.IsDir(w.watchDir) {
+		return cli.NewExitError(fmt.Errorf("\n\t[!] %v is not a directory", w.watchDir), -1)
+	}
+
+	if w.interval <= 0 {
+		return cli.NewExitError("\n\t[!] The watch interval must be greater than 0", -1)
+	}
+
+	err := checkForInvalidDBChars(w.targetDatabase)
+	if err != nil {
+		return cli.NewExitError(err.Error(), -1)
+	}
+
+	return nil
+}
+
+// run imports any new logs every interval until the process is interrupted
+func (w *Watcher) run() error {
+	err := w.parseArgs()
+	if err != nil {
+		return err
+	}
+
+	w.res = resources.InitResources(w.configFile)
+	w.res.DB.SelectDB(w.targetDatabase)
+
+	importer, err := parser.NewFSImporter(w.res)
+	if err != nil {
+		return cli.NewExitError(fmt.Errorf("error creating new file system importer: %v", err.Error()), -1)
+	}
+	if len(importer.GetInternalSubnets()) == 0 {
+		return cli.NewExitError("Internal subnets are not defined. Please set the InternalSubnets section of the config file.", -1)
+	}
+
+	// late logs are added to the chunk holding the rest of their hour
+	periods, err := w.res.MetaDB.GetWatchedPeriods(w.targetDatabase)
+	if err != nil {
+		return cli.NewExitError(fmt.Errorf("\n\t[!] Error while reading existing database settings: %v", err.Error()), -1)
+	}
+	for _, period := range periods {
+		w.chunks[period.Start] = period.CID
+	}
+
+	// finish the current import before stopping
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	fmt.Printf("\n\t[+] Watching %s for new logs. Press Ctrl+C to stop.\n", w.watchDir)
+	w.res.Log.WithFields(log.Fields{
+		"directory": w.watchDir,
+		"database":  w.targetDatabase,
+		"interval":  w.interval.String(),
+	}).Info("Watching for new logs")
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		err = w.importNewLogs(importer)
+		if err != nil {
+			return cli.NewExitError(err.Error(), -1)
+		}
+
+		select {
+		case <-stop:
+			fmt.Println("\t[-] Stopped watching for new logs")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// importNewLogs imports the logs which have been rotated since the last pass,
+// placing each new hour of logs in the next chunk of the database. Logs for an hour
+// which was already imported are added to the chunk holding the rest of the hour.
+func (w *Watcher) importNewLogs(importer *parser.FSImporter) error {
+	_, _, _, totalChunks, err := w.res.MetaDB.GetRollingSettings(w.targetDatabase)
+	if err != nil {
+		return fmt.Errorf("\n\t[!] Error while reading existing database settings: %v", err.Error())
+	}
+	if w.userTotalChunks != -1 {
+		totalChunks = w.userTotalChunks
+	} else if totalChunks == 0 {
+		totalChunks = w.res.Config.S.Rolling.DefaultChunks
+	}
+
+	logFiles := w.findRotatedLogs(time.Now(), w.oldestHour(totalChunks))
+	if len(logFiles) == 0 {
+		return nil
+	}
+
+	indexedFiles := importer.CollectNewFileDetails(logFiles, w.threads)
+	if len(indexedFiles) == 0 {
+		return nil
+	}
+
+	var newPeriods []logPeriod
+	for _, period := range groupFilesByPeriod(indexedFiles) {
+		cid, ok := w.chunks[period.start.Unix()]
+		if !ok {
+			newPeriods = append(newPeriods, period)
+			continue
+		}
+		err = w.importPeriod(importer, period, cid)
+		if err != nil {
+			return err
+		}
+	}
+
+	// when catching up on a backlog, older hours would be immediately
+	// cycled out of the dataset, so skip them
+	if len(newPeriods) > totalChunks {
+		skipped := len(newPeriods) - totalChunks
+		w.res.Log.WithFields(log.Fields{
+			"skipped_hours": skipped,
+		}).Warn("Skipping logs which are older than the rolling dataset can hold")
+		fmt.Printf("\t[!] Skipping %d hour(s) of logs which are older than the rolling dataset can hold\n", skipped)
+		newPeriods = newPeriods[skipped:]
+	}
+
+	for _, period := range newPeriods {
+		err = w.importPeriod(importer, period, -1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importPeriod imports an hour of logs into the given chunk, or into the next chunk
+// of the database if cid is -1
+func (w *Watcher) importPeriod(importer *parser.FSImporter, period logPeriod, cid int) error {
+	exists, isRolling, currChunk, dbTotalChunks, err := w.res.MetaDB.GetRollingSettings(w.targetDatabase)
+	if err != nil {
+		return fmt.Errorf("\n\t[!] Error while reading existing database settings: %v", err.Error())
+	}
+
+	// advance to the next chunk, wrapping back to 0 when needed
+	rollingCfg, err := parseFlags(
+		exists, isRolling, currChunk, dbTotalChunks,
+		true, cid, w.userTotalChunks, w.res.Config.S.Rolling.DefaultChunks,
+		false,
+	)
+	if err != nil {
+		return err
+	}
+	w.res.Config.S.Rolling = rollingCfg
+
+	for _, file := range period.files {
+		file.CID = rollingCfg.CurrentChunk
+	}
+
+	late := cid != -1
+	if late {
+		fmt.Printf("\n\t[+] Adding late logs from %s to chunk %d:\n",
+			period.start.Format(util.TimeFormat), rollingCfg.CurrentChunk)
+	} else {
+		fmt.Printf("\n\t[+] Importing logs from %s into chunk %d:\n",
+			period.start.Format(util.TimeFormat), rollingCfg.CurrentChunk)
+	}
+
+	importer.SetAppendToChunk(late)
+	importer.Run(period.files, w.threads)
+	importer.SetAppendToChunk(false)
+
+	w.res.Log.WithFields(log.Fields{
+		"period": period.start.Format(util.TimeFormat),
+		"chunk":  rollingCfg.CurrentChunk,
+		"files":  len(period.files),
+		"late":   late,
+	}).Info("Finished importing rotated logs")
+
+	if late {
+		return nil
+	}
+
+	// the hour replaces whichever hour the chunk held before
+	for start, chunk := range w.chunks {
+		if chunk == rollingCfg.CurrentChunk {
+			delete(w.chunks, start)
+		}
+	}
+	w.chunks[period.start.Unix()] = rollingCfg.CurrentChunk
+
+	periods := make([]database.WatchedPeriod, 0, len(w.chunks))
+	for start, chunk := range w.chunks {
+		periods = append(periods, database.WatchedPeriod{Start: start, CID: chunk})
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].Start < periods[j].Start
+	})
+	err = w.res.MetaDB.SetWatchedPeriods(w.targetDatabase, periods)
+	if err != nil {
+		return fmt.Errorf("\n\t[!] Error while recording the chunk holding the imported logs: %v", err.Error())
+	}
+	return nil
+}
+
+// oldestHour returns the start of the oldest hour the rolling dataset still holds once
+// every chunk is in use. Logs from earlier hours have cycled out of the dataset.
+func (w *Watcher) oldestHour(totalChunks int) time.Time {
+	if len(w.chunks) < totalChunks {
+		return time.Time{}
+	}
+
+	var oldest int64
+	for start := range w.chunks {
+		if oldest == 0 || start < oldest {
+			oldest = start
+		}
+	}
+	return time.Unix(oldest, 0)
+}
+
+// findRotatedLogs returns the logs which have not been seen before and have not been
+// modified within the last interval. The active Zeek spool ("current") is skipped since
+// its logs are moved into a dated directory when they are rotated. Logs covering hours
+// before oldest have cycled out of the dataset and are skipped as well.
+func (w *Watcher) findRotatedLogs(now time.Time, oldest time.Time) []string {
+	dirs := []string{w.watchDir}
+	entries, err := ioutil.ReadDir(w.watchDir)
+	if err != nil {
+		w.res.Log.WithFields(log.Fields{
+			"error": err.Error(),
+			"path":  w.watchDir,
+		}).Error("Error when reading directory")
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", entry.Name()); err == nil {
+			dirs = append(dirs, filepath.Join(w.watchDir, entry.Name()))
+		}
+	}
+
+	var toReturn []string
+	found := make(map[string]bool)
+	for _, path := range files.GatherLogFiles(dirs, files.GatherOptions{}, w.res.Log) {
+		found[path] = true
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		// the log may still be written to
+		if now.Sub(info.ModTime()) < w.interval {
+			continue
+		}
+		// there is no longer a chunk for the log's hour, so it no longer needs to be tracked
+		if logPeriodStart(path, info.ModTime()).Before(oldest) {
+			delete(w.seen, path)
+			continue
+		}
+
+		stamp := watchedFile{size: info.Size(), modTime: info.ModTime()}
+		if previous, ok := w.seen[path]; ok && previous == stamp {
+			continue
+		}
+		w.seen[path] = stamp
+		toReturn = append(toReturn, path)
+	}
+
+	// forget the logs which were removed from the directory
+	for path := range w.seen {
+		if !found[path] {
+			delete(w.seen, path)
+		}
+	}
+	return toReturn
+}
+
+// logPeriod holds the logs covering a single hour
+type logPeriod struct {
+	start time.Time
+	files []*files.IndexedFile
+}
+
+// groupFilesByPeriod groups the files by the hour they cover, oldest first
+func groupFilesByPeriod(indexedFiles []*files.IndexedFile) []logPeriod {
+	byStart := make(map[time.Time]*logPeriod)
+	for _, file := range indexedFiles {
+		start := logPeriodStart(file.Path, file.ModTime)
+		period, ok := byStart[start]
+		if !ok {
+			period = &logPeriod{start: start}
+			byStart[start] = period
+		}
+		period.files = append(period.files, file)
+	}
+
+	periods := make([]logPeriod, 0, len(byStart))
+	for _, period := range byStart {
+		periods = append(periods, *period)
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].start.Before(periods[j].start)
+	})
+	return periods
+}
+
+// logPeriodStart determines the start of the hour a log covers. Logs
+// rotated by Zeek are placed by the date of their directory and the time in their
+// name. Other logs are placed by the time they were last modified.
+func logPeriodStart(path string, modTime time.Time) time.Time {
+	if match := zeekArchiveName.FindStringSubmatch(filepath.Base(path)); match != nil {
+		date := filepath.Base(filepath.Dir(path))
+		opened, err := time.ParseInLocation("2006-01-02 15:04:05", date+" "+match[1], time.Local)
+		if err == nil {
+			return startOfHour(opened)
+		}
+	}
+	return startOfHour(modTime)
+}
+
+// startOfHour truncates the time to the hour in its own time zone. time.Truncate
+// works in absolute time and would be off for zones with fractional hour offsets.
+func startOfHour(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/parser/files"
+	"github.com/activecm/rita/resources"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogPeriodStart(t *testing.T) {
+	modTime := time.Date(2021, 3, 4, 15, 0, 7, 0, time.Local)
+
+	// rotated logs are placed by the date of their directory and the time in their name
+	assert.Equal(t,
+		time.Date(2021, 3, 4, 14, 0, 0, 0, time.Local),
+		logPeriodStart("/opt/zeek/logs/2021-03-04/conn.14:00:00-15:00:00.log.gz", modTime),
+	)
+	assert.Equal(t,
+		time.Date(2021, 3, 3, 23, 0, 0, 0, time.Local),
+		logPeriodStart("/opt/zeek/logs/2021-03-03/dns.23:00:00-00:00:00.log", modTime),
+	)
+	assert.Equal(t,
+		time.Date(2021, 3, 4, 14, 0, 0, 0, time.Local),
+		logPeriodStart("/opt/zeek/logs/2021-03-04/http.14:00:00-15:00:00.log.zst", modTime),
+	)
+
+	// other logs fall back to their modification time
+	assert.Equal(t,
+		time.Date(2021, 3, 4, 15, 0, 0, 0, time.Local),
+		logPeriodStart("/data/conn.log", modTime),
+	)
+	assert.Equal(t,
+		time.Date(2021, 3, 4, 15, 0, 0, 0, time.Local),
+		logPeriodStart("/data/not-a-date/conn.14:00:00-15:00:00.log", modTime),
+	)
+}
+
+func TestGroupFilesByPeriod(t *testing.T) {
+	modTime := time.Date(2021, 3, 4, 15, 0, 7, 0, time.Local)
+	indexedFiles := []*files.IndexedFile{
+		{Path: "/logs/2021-03-04/conn.14:00:00-15:00:00.log.gz", ModTime: modTime},
+		{Path: "/logs/2021-03-04/conn.13:00:00-14:00:00.log.gz", ModTime: modTime},
+		{Path: "/logs/2021-03-04/dns.14:00:00-15:00:00.log.gz", ModTime: modTime},
+	}
+
+	periods := groupFilesByPeriod(indexedFiles)
+	assert.Len(t, periods, 2)
+
+	assert.Equal(t, time.Date(2021, 3, 4, 13, 0, 0, 0, time.Local), periods[0].start)
+	assert.Equal(t, []*files.IndexedFile{indexedFiles[1]}, periods[0].files)
+
+	assert.Equal(t, time.Date(2021, 3, 4, 14, 0, 0, 0, time.Local), periods[1].start)
+	assert.Equal(t, []*files.IndexedFile{indexedFiles[0], indexedFiles[2]}, periods[1].files)
+}
+
+func TestFindRotatedLogs(t *testing.T) {
+	watchDir, err := ioutil.TempDir("", "rita-watch")
+	require.NoError(t, err)
+	defer os.RemoveAll(watchDir)
+
+	dayDir := filepath.Join(watchDir, "2021-03-04")
+	require.NoError(t, os.Mkdir(dayDir, 0755))
+	earlier := filepath.Join(dayDir, "conn.13:00:00-14:00:00.log")
+	later := filepath.Join(dayDir, "conn.14:00:00-15:00:00.log")
+	modTime := time.Date(2021, 3, 4, 15, 0, 7, 0, time.Local)
+	for _, path := range []string{earlier, later} {
+		require.NoError(t, ioutil.WriteFile(path, []byte("#fields\tts\n"), 0644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	w := &Watcher{
+		res:      &resources.Resources{Log: logger},
+		watchDir: watchDir,
+		interval: time.Minute,
+		seen:     make(map[string]watchedFile),
+	}
+	now := time.Now()
+
+	assert.ElementsMatch(t, []string{earlier, later}, w.findRotatedLogs(now, time.Time{}))
+	assert.Empty(t, w.findRotatedLogs(now, time.Time{}))
+
+	// logs from hours which cycled out of the dataset are no longer tracked
+	oldest := time.Date(2021, 3, 4, 14, 0, 0, 0, time.Local)
+	assert.Empty(t, w.findRotatedLogs(now, oldest))
+	assert.Len(t, w.seen, 1)
+	assert.Contains(t, w.seen, later)
+
+	// neither are logs which were removed
+	require.NoError(t, os.Remove(later))
+	assert.Empty(t, w.findRotatedLogs(now, oldest))
+	assert.Empty(t, w.seen)
+}
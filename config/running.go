@@ -5,11 +5,25 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"strings"
 
-	"github.com/activecm/mgosec"
 	"github.com/blang/semver"
 )
 
+// authMechanisms holds the MongoDB authentication mechanisms supported by the
+// MongoDB driver. An empty mechanism disables authentication.
+var authMechanisms = []string{
+	"SCRAM-SHA-1", "SCRAM-SHA-256", "MONGODB-CR", "PLAIN", "GSSAPI", "MONGODB-X509", "MONGODB-AWS", "",
+}
+
+// The storage backends RITA supports
+const (
+	//MongoDBBackend stores RITA's databases on a MongoDB server
+	MongoDBBackend = "mongodb"
+	//EmbeddedBackend stores RITA's databases in a local directory without a separate server
+	EmbeddedBackend = "embedded"
+)
+
 type (
 	//RunningCfg holds configuration options that are parsed at run time
 	RunningCfg struct {
@@ -19,7 +33,7 @@ type (
 
 	//MongoDBRunningCfg holds parsed information for connecting to MongoDB
 	MongoDBRunningCfg struct {
-		AuthMechanismParsed mgosec.AuthMechanism
+		AuthMechanismParsed string
 		TLS                 struct {
 			TLSConfig *tls.Config
 		}
@@ -31,6 +45,11 @@ type (
 func initRunningConfig(static *StaticCfg, running *RunningCfg) error {
 	var err error
 
+	if static.Storage.Backend != MongoDBBackend && static.Storage.Backend != EmbeddedBackend {
+		fmt.Println("[!] Storage backend must be either mongodb or embedded")
+		return fmt.Errorf("unsupported storage backend: %s", static.Storage.Backend)
+	}
+
 	//parse the tls configuration
 	if static.MongoDB.TLS.Enabled {
 		tlsConf := &tls.Config{}
@@ -51,11 +70,10 @@ func initRunningConfig(static *StaticCfg, running *RunningCfg) error {
 	}
 
 	//parse out the mongo authentication mechanism
-	authMechanism, err := mgosec.ParseAuthMechanism(
+	authMechanism, err := parseAuthMechanism(
 		static.MongoDB.AuthMechanism,
 	)
 	if err != nil {
-		authMechanism = mgosec.None
 		fmt.Println("[!] Could not parse MongoDB authentication mechanism")
 	}
 	running.MongoDB.AuthMechanismParsed = authMechanism
@@ -69,3 +87,16 @@ func initRunningConfig(static *StaticCfg, running *RunningCfg) error {
 	}
 	return err
 }
+
+// parseAuthMechanism normalizes a MongoDB authentication mechanism and
+// ensures it is supported. Unsupported mechanisms disable authentication.
+func parseAuthMechanism(mechanism string) (string, error) {
+	mechanism = strings.ToUpper(strings.TrimSpace(mechanism))
+	for _, authMechanism := range authMechanisms {
+		if mechanism == authMechanism {
+			return authMechanism, nil
+		}
+	}
+	return "", fmt.Errorf("%s did not match an existing MongoDB "+
+		"authentication mechanism", mechanism)
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"time"
 
 	yaml "gopkg.in/yaml.v2"
@@ -31,19 +32,25 @@ const minBeaconConnectionThreshLimit int = 23
 type (
 	//StaticCfg is the container for other static config sections
 	StaticCfg struct {
-		UserConfig   UserCfgStaticCfg     `yaml:"UserConfig"`
-		MongoDB      MongoDBStaticCfg     `yaml:"MongoDB"`
-		Rolling      RollingStaticCfg     `yaml:"Rolling"`
-		Log          LogStaticCfg         `yaml:"LogConfig"`
-		Blacklisted  BlacklistedStaticCfg `yaml:"BlackListed"`
-		Beacon       BeaconStaticCfg      `yaml:"Beacon"`
-		BeaconProxy  BeaconProxyStaticCfg `yaml:"BeaconProxy"`
-		BeaconSNI    BeaconSNIStaticCfg   `yaml:"BeaconSNI"`
-		DNS          DNSStaticCfg         `yaml:"DNS"`
-		UserAgent    UserAgentStaticCfg   `yaml:"UserAgent"`
-		Bro          BroStaticCfg         `yaml:"Bro"` // kept in for MetaDB backwards compatibility
-		Filtering    FilteringStaticCfg   `yaml:"Filtering"`
-		Strobe       StrobeStaticCfg      `yaml:"Strobe"`
+		UserConfig   UserCfgStaticCfg      `yaml:"UserConfig"`
+		MongoDB      MongoDBStaticCfg      `yaml:"MongoDB"`
+		Storage      StorageStaticCfg      `yaml:"Storage"`
+		Rolling      RollingStaticCfg      `yaml:"Rolling"`
+		Log          LogStaticCfg          `yaml:"LogConfig"`
+		Blacklisted  BlacklistedStaticCfg  `yaml:"BlackListed"`
+		Beacon       BeaconStaticCfg       `yaml:"Beacon"`
+		BeaconProxy  BeaconProxyStaticCfg  `yaml:"BeaconProxy"`
+		BeaconSNI    BeaconSNIStaticCfg    `yaml:"BeaconSNI"`
+		DNS          DNSStaticCfg          `yaml:"DNS"`
+		UserAgent    UserAgentStaticCfg    `yaml:"UserAgent"`
+		Bro          BroStaticCfg          `yaml:"Bro"` // kept in for MetaDB backwards compatibility
+		Filtering    FilteringStaticCfg    `yaml:"Filtering"`
+		Strobe       StrobeStaticCfg       `yaml:"Strobe"`
+		ThreatScore  ThreatScoreStaticCfg  `yaml:"ThreatScore"`
+		FileTransfer FileTransferStaticCfg `yaml:"FileTransfer"`
+		JSONMapping  JSONMappingStaticCfg  `yaml:"JSONMapping"`
+		Import       ImportStaticCfg       `yaml:"Import"`
+		Networks     []NetworkStaticCfg    `yaml:"Networks"`
 		Version      string
 		ExactVersion string
 	}
@@ -57,6 +64,14 @@ type (
 		MetaDB           string        `yaml:"MetaDB" default:"MetaDatabase"`
 	}
 
+	//StorageStaticCfg selects where RITA keeps its databases. The mongodb backend uses
+	//the MongoDB server configured in the MongoDB section. The embedded backend stores
+	//the databases in EmbeddedPath without a separate database server.
+	StorageStaticCfg struct {
+		Backend      string `yaml:"Backend" default:"mongodb"`
+		EmbeddedPath string `yaml:"EmbeddedPath" default:"/var/lib/rita/db"`
+	}
+
 	//TLSStaticCfg contains the means for connecting to MongoDB over TLS
 	TLSStaticCfg struct {
 		Enabled           bool   `yaml:"Enable" default:"false"`
@@ -103,10 +118,11 @@ type (
 	BeaconStaticCfg struct {
 		Enabled                      bool    `yaml:"Enabled" default:"true"`
 		DefaultConnectionThresh      int     `yaml:"DefaultConnectionThresh" default:"23"`
-		TsWeight                     float64 `yaml:"TimestampScoreWeight" default:"0.25"`
-		DsWeight                     float64 `yaml:"DatasizeScoreWeight" default:"0.25"`
-		DurWeight                    float64 `yaml:"DurationScoreWeight" default:"0.25"`
-		HistWeight                   float64 `yaml:"HistogramScoreWeight" default:"0.25"`
+		TsWeight                     float64 `yaml:"TimestampScoreWeight" default:"0.2"`
+		DsWeight                     float64 `yaml:"DatasizeScoreWeight" default:"0.2"`
+		DurWeight                    float64 `yaml:"DurationScoreWeight" default:"0.2"`
+		HistWeight                   float64 `yaml:"HistogramScoreWeight" default:"0.2"`
+		PeriodicityWeight            float64 `yaml:"PeriodicityScoreWeight" default:"0.2"`
 		DurMinHoursSeen              int     `yaml:"DurationMinHoursSeen" default:"6"`
 		DurConsistencyIdealHoursSeen int     `yaml:"DurationConsistencyIdealHoursSeen" default:"12"`
 		HistBimodalBucketSize        float64 `yaml:"HistogramBimodalBucketSize" default:"0.05"`
@@ -118,9 +134,10 @@ type (
 	BeaconProxyStaticCfg struct {
 		Enabled                      bool    `yaml:"Enabled" default:"true"`
 		DefaultConnectionThresh      int     `yaml:"DefaultConnectionThresh" default:"23"`
-		TsWeight                     float64 `yaml:"TimestampScoreWeight" default:"0.333"`
-		DurWeight                    float64 `yaml:"DurationScoreWeight" default:"0.333"`
-		HistWeight                   float64 `yaml:"HistogramScoreWeight" default:"0.333"`
+		TsWeight                     float64 `yaml:"TimestampScoreWeight" default:"0.25"`
+		DurWeight                    float64 `yaml:"DurationScoreWeight" default:"0.25"`
+		HistWeight                   float64 `yaml:"HistogramScoreWeight" default:"0.25"`
+		PeriodicityWeight            float64 `yaml:"PeriodicityScoreWeight" default:"0.25"`
 		DurMinHoursSeen              int     `yaml:"DurationMinHoursSeen" default:"6"`
 		DurConsistencyIdealHoursSeen int     `yaml:"DurationConsistencyIdealHoursSeen" default:"12"`
 		HistBimodalBucketSize        float64 `yaml:"HistogramBimodalBucketSize" default:"0.05"`
@@ -132,10 +149,11 @@ type (
 	BeaconSNIStaticCfg struct {
 		Enabled                      bool    `yaml:"Enabled" default:"true"`
 		DefaultConnectionThresh      int     `yaml:"DefaultConnectionThresh" default:"23"`
-		TsWeight                     float64 `yaml:"TimestampScoreWeight" default:"0.25"`
-		DsWeight                     float64 `yaml:"DatasizeScoreWeight" default:"0.25"`
-		DurWeight                    float64 `yaml:"DurationScoreWeight" default:"0.25"`
-		HistWeight                   float64 `yaml:"HistogramScoreWeight" default:"0.25"`
+		TsWeight                     float64 `yaml:"TimestampScoreWeight" default:"0.2"`
+		DsWeight                     float64 `yaml:"DatasizeScoreWeight" default:"0.2"`
+		DurWeight                    float64 `yaml:"DurationScoreWeight" default:"0.2"`
+		HistWeight                   float64 `yaml:"HistogramScoreWeight" default:"0.2"`
+		PeriodicityWeight            float64 `yaml:"PeriodicityScoreWeight" default:"0.2"`
 		DurMinHoursSeen              int     `yaml:"DurationMinHoursSeen" default:"6"`
 		DurConsistencyIdealHoursSeen int     `yaml:"DurationConsistencyIdealHoursSeen" default:"12"`
 		HistBimodalBucketSize        float64 `yaml:"HistogramBimodalBucketSize" default:"0.05"`
@@ -153,6 +171,14 @@ type (
 		Enabled bool `yaml:"Enabled" default:"true"`
 	}
 
+	//FileTransferStaticCfg is used to control the file transfer analysis module.
+	//IOCHashFiles lists local files holding the MD5, SHA1, or SHA256 hashes of known bad files.
+	FileTransferStaticCfg struct {
+		Enabled              bool     `yaml:"Enabled" default:"true"`
+		IOCHashFiles         []string `yaml:"IOCHashFiles" default:"[]"`
+		LargeUploadMegabytes int      `yaml:"LargeUploadMegabytes" default:"100"`
+	}
+
 	//FilteringStaticCfg controls address filtering
 	FilteringStaticCfg struct {
 		AlwaysInclude            []string `yaml:"AlwaysInclude" default:"[]"`
@@ -167,6 +193,64 @@ type (
 	StrobeStaticCfg struct {
 		ConnectionLimit int `yaml:"ConnectionLimit" default:"86400"`
 	}
+
+	//JSONMappingStaticCfg maps JSON logs which were reshaped by a log shipper (e.g. Filebeat)
+	//back onto the Zeek field names
+	JSONMappingStaticCfg struct {
+		Presets      []string                             `yaml:"Presets" default:"[]"`
+		LogTypeRules []JSONLogTypeRuleStaticCfg           `yaml:"LogTypeRules"`
+		FieldAliases map[string][]JSONFieldAliasStaticCfg `yaml:"FieldAliases"`
+	}
+
+	//JSONLogTypeRuleStaticCfg detects the Zeek log type of a JSON log from one of its fields.
+	//If LogType is empty, the field's value with TrimPrefix removed is used as the log type.
+	JSONLogTypeRuleStaticCfg struct {
+		Field      string `yaml:"Field"`
+		Match      string `yaml:"Match"`
+		LogType    string `yaml:"LogType"`
+		TrimPrefix string `yaml:"TrimPrefix"`
+	}
+
+	//JSONFieldAliasStaticCfg fills in a Zeek field from another field of a JSON log.
+	//Numeric values are multiplied by Scale if it is set.
+	JSONFieldAliasStaticCfg struct {
+		Field string  `yaml:"Field"`
+		From  string  `yaml:"From"`
+		Scale float64 `yaml:"Scale"`
+	}
+
+	//ImportStaticCfg limits the memory used to hold connection details while importing.
+	//A MemoryLimit of 0 keeps every connection detail in memory.
+	ImportStaticCfg struct {
+		MemoryLimit    int    `yaml:"MemoryLimit" default:"0"`
+		SpillDirectory string `yaml:"SpillDirectory" default:""`
+	}
+
+	//NetworkStaticCfg assigns the hosts seen by a sensor to a named network so that
+	//private address space reused at different sites is kept apart. Logs are matched by
+	//the directory or file name they were read from, the Zeek _node_name or _system_name
+	//field, or the VLAN ID of a connection. Every field which is set must match.
+	//Only conn logs record the VLAN, so VLAN must be combined with another field.
+	//If UUID is empty, one is derived from Name.
+	NetworkStaticCfg struct {
+		Name      string `yaml:"Name"`
+		UUID      string `yaml:"UUID"`
+		Directory string `yaml:"Directory"`
+		FileGlob  string `yaml:"FileGlob"`
+		NodeName  string `yaml:"NodeName"`
+		VLAN      int    `yaml:"VLAN"`
+	}
+
+	//ThreatScoreStaticCfg is used to control the per host threat score analysis module
+	ThreatScoreStaticCfg struct {
+		Enabled             bool    `yaml:"Enabled" default:"true"`
+		BeaconWeight        float64 `yaml:"BeaconWeight" default:"0.3"`
+		BlacklistWeight     float64 `yaml:"BlacklistWeight" default:"0.25"`
+		LongConnWeight      float64 `yaml:"LongConnectionWeight" default:"0.15"`
+		RareSignatureWeight float64 `yaml:"RareSignatureWeight" default:"0.1"`
+		InvalidCertWeight   float64 `yaml:"InvalidCertificateWeight" default:"0.1"`
+		StrobeWeight        float64 `yaml:"StrobeWeight" default:"0.1"`
+	}
 )
 
 // readStaticConfigFile attempts to read the contents of the
@@ -232,6 +316,20 @@ func parseStaticConfig(cfgFile []byte, config *StaticCfg) error {
 		config.BeaconSNI.DurConsistencyIdealHoursSeen = 1
 	}
 
+	// keep beacon scores within [0, 1] for configs written before the periodicity
+	// score weight was introduced, which pick up its default on top of their own weights
+	normalizeWeights(&config.Beacon.TsWeight, &config.Beacon.DsWeight, &config.Beacon.DurWeight,
+		&config.Beacon.HistWeight, &config.Beacon.PeriodicityWeight)
+	normalizeWeights(&config.BeaconProxy.TsWeight, &config.BeaconProxy.DurWeight,
+		&config.BeaconProxy.HistWeight, &config.BeaconProxy.PeriodicityWeight)
+	normalizeWeights(&config.BeaconSNI.TsWeight, &config.BeaconSNI.DsWeight, &config.BeaconSNI.DurWeight,
+		&config.BeaconSNI.HistWeight, &config.BeaconSNI.PeriodicityWeight)
+
+	// keep threat scores within [0, 1]
+	normalizeWeights(&config.ThreatScore.BeaconWeight, &config.ThreatScore.BlacklistWeight,
+		&config.ThreatScore.LongConnWeight, &config.ThreatScore.RareSignatureWeight,
+		&config.ThreatScore.InvalidCertWeight, &config.ThreatScore.StrobeWeight)
+
 	// expand env variables, config is a pointer
 	// so we have to call elem on the reflect value
 	expandConfig(reflect.ValueOf(config).Elem())
@@ -239,8 +337,16 @@ func parseStaticConfig(cfgFile []byte, config *StaticCfg) error {
 	// set the socket time out in hours
 	config.MongoDB.SocketTimeout *= time.Hour
 
+	config.Storage.Backend = strings.ToLower(strings.TrimSpace(config.Storage.Backend))
+
 	// clean all filepaths
 	config.Log.RitaLogPath = filepath.Clean(config.Log.RitaLogPath)
+	if config.Storage.EmbeddedPath != "" {
+		config.Storage.EmbeddedPath = filepath.Clean(config.Storage.EmbeddedPath)
+	}
+	if config.Import.SpillDirectory != "" {
+		config.Import.SpillDirectory = filepath.Clean(config.Import.SpillDirectory)
+	}
 
 	// grab the version constants set by the build process
 	config.Version = Version
@@ -248,3 +354,18 @@ func parseStaticConfig(cfgFile []byte, config *StaticCfg) error {
 
 	return nil
 }
+
+// normalizeWeights scales the given weights down in place so that they sum to one
+// if they add up to more than one
+func normalizeWeights(weights ...*float64) {
+	total := 0.0
+	for _, weight := range weights {
+		total += *weight
+	}
+	if total <= 1 {
+		return
+	}
+	for _, weight := range weights {
+		*weight /= total
+	}
+}
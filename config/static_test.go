@@ -194,3 +194,60 @@ LogConfig:
 	assert.Nil(t, err)
 	assert.Equal(t, config.Log, testConfigExp.Log)
 }
+
+// TestBeaconWeightNormalization ensures that beacon score weights which
+// add up to more than one are scaled down to sum to one.
+func TestBeaconWeightNormalization(t *testing.T) {
+	testConfig := `
+Beacon:
+    TimestampScoreWeight: 0.25
+    DatasizeScoreWeight: 0.25
+    DurationScoreWeight: 0.25
+    HistogramScoreWeight: 0.25
+    PeriodicityScoreWeight: 0.25
+BeaconProxy:
+    TimestampScoreWeight: 0.3
+    DurationScoreWeight: 0.3
+    HistogramScoreWeight: 0.3
+`
+	config := &StaticCfg{}
+	err := parseStaticConfig([]byte(testConfig), config)
+
+	assert.Nil(t, err)
+	assert.InDelta(t, 0.2, config.Beacon.TsWeight, 1e-9)
+	assert.InDelta(t, 0.2, config.Beacon.PeriodicityWeight, 1e-9)
+	assert.InDelta(t, 0.3, config.BeaconProxy.TsWeight, 1e-9)
+	assert.Equal(t, 0.0, config.BeaconProxy.PeriodicityWeight)
+}
+
+// TestJSONMappingConfig ensures that JSON field aliases are read by log type.
+func TestJSONMappingConfig(t *testing.T) {
+	testConfig := `
+JSONMapping:
+    Presets: [ecs]
+    LogTypeRules:
+        - Field: event.dataset
+          Match: "zeek.*"
+          TrimPrefix: "zeek."
+    FieldAliases:
+        "*":
+            - Field: id.orig_h
+              From: source.ip
+        conn:
+            - Field: duration
+              From: event.duration
+              Scale: 0.000000001
+`
+	config := &StaticCfg{}
+	err := parseStaticConfig([]byte(testConfig), config)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ecs"}, config.JSONMapping.Presets)
+	assert.Equal(t, []JSONLogTypeRuleStaticCfg{
+		{Field: "event.dataset", Match: "zeek.*", TrimPrefix: "zeek."},
+	}, config.JSONMapping.LogTypeRules)
+	assert.Equal(t, map[string][]JSONFieldAliasStaticCfg{
+		"*":    {{Field: "id.orig_h", From: "source.ip"}},
+		"conn": {{Field: "duration", From: "event.duration", Scale: 1e-9}},
+	}, config.JSONMapping.FieldAliases)
+}
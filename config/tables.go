@@ -3,15 +3,16 @@ package config
 type (
 	//TableCfg is the container for other table config sections
 	TableCfg struct {
-		Log         LogTableCfg
-		DNS         DNSTableCfg
-		Structure   StructureTableCfg
-		Beacon      BeaconTableCfg
-		BeaconSNI   BeaconSNITableCfg
-		BeaconProxy BeaconProxyTableCfg
-		UserAgent   UserAgentTableCfg
-		Cert        CertificateTableCfg
-		Meta        MetaTableCfg
+		Log          LogTableCfg
+		DNS          DNSTableCfg
+		Structure    StructureTableCfg
+		Beacon       BeaconTableCfg
+		BeaconSNI    BeaconSNITableCfg
+		BeaconProxy  BeaconProxyTableCfg
+		UserAgent    UserAgentTableCfg
+		Cert         CertificateTableCfg
+		FileTransfer FileTransferTableCfg
+		Meta         MetaTableCfg
 	}
 
 	//LogTableCfg contains the configuration for logging
@@ -30,12 +31,22 @@ type (
 		UniqueConnTable      string `default:"uconn"`
 		UniqueConnProxyTable string `default:"uconnProxy"`
 		SNIConnTable         string `default:"SNIconn"`
+		X509Table            string `default:"x509"`
+		SSHTable             string `default:"ssh"`
+		SSHConnTable         string `default:"SSHconn"`
+		DHCPTable            string `default:"dhcp"`
+		LeaseTable           string `default:"lease"`
+		KerberosTable        string `default:"kerberos"`
+		NTLMTable            string `default:"ntlm"`
+		LogonTable           string `default:"logon"`
+		FilesTable           string `default:"files"`
 	}
 
 	//DNSTableCfg is used to control the dns analysis module
 	DNSTableCfg struct {
 		ExplodedDNSTable string `default:"explodedDns"`
 		HostnamesTable   string `default:"hostnames"`
+		DNSTunnelTable   string `default:"dnsTunnel"`
 	}
 
 	//BeaconTableCfg is used to control the beaconing analysis module
@@ -63,6 +74,12 @@ type (
 		CertificateTable string `default:"cert"`
 	}
 
+	//FileTransferTableCfg is used to control the file transfer analysis module
+	FileTransferTableCfg struct {
+		FileTransferTable string `default:"fileTransfer"`
+		FileUploadTable   string `default:"fileUpload"`
+	}
+
 	//MetaTableCfg contains the meta db collection names
 	MetaTableCfg struct {
 		FilesTable     string `default:"files"`
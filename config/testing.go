@@ -32,10 +32,11 @@ DNS:
 Beacon:
     Enabled: true
     DefaultConnectionThresh: 23
-    TimestampScoreWeight: 0.25
-    DatasizeScoreWeight: 0.25
-    DurationScoreWeight: 0.25
-    HistogramScoreWeight: 0.25
+    TimestampScoreWeight: 0.2
+    DatasizeScoreWeight: 0.2
+    DurationScoreWeight: 0.2
+    HistogramScoreWeight: 0.2
+    PeriodicityScoreWeight: 0.2
     DurationMinHoursSeen: 6
     DurationConsistencyIdealHoursSeen: 12
     HistogramBimodalBucketSize: 0.05
@@ -44,10 +45,11 @@ Beacon:
 BeaconSNI:
     Enabled: true
     DefaultConnectionThresh: 23
-    TimestampScoreWeight: 0.25
-    DatasizeScoreWeight: 0.25
-    DurationScoreWeight: 0.25
-    HistogramScoreWeight: 0.25
+    TimestampScoreWeight: 0.2
+    DatasizeScoreWeight: 0.2
+    DurationScoreWeight: 0.2
+    HistogramScoreWeight: 0.2
+    PeriodicityScoreWeight: 0.2
     DurationMinHoursSeen: 6
     DurationConsistencyIdealHoursSeen: 12
     HistogramBimodalBucketSize: 0.05
@@ -56,9 +58,10 @@ BeaconSNI:
 BeaconProxy:
     Enabled: true
     DefaultConnectionThresh: 23
-    TimestampScoreWeight: 0.333
-    DurationScoreWeight: 0.333
-    HistogramScoreWeight: 0.333
+    TimestampScoreWeight: 0.25
+    DurationScoreWeight: 0.25
+    HistogramScoreWeight: 0.25
+    PeriodicityScoreWeight: 0.25
     DurationMinHoursSeen: 6
     DurationConsistencyIdealHoursSeen: 12
     HistogramBimodalBucketSize: 0.05
@@ -66,6 +69,14 @@ BeaconProxy:
     HistogramBimodalMinHoursSeen: 11
 Strobe:
     ConnectionLimit: 250000
+ThreatScore:
+    Enabled: true
+    BeaconWeight: 0.3
+    BlacklistWeight: 0.25
+    LongConnectionWeight: 0.15
+    RareSignatureWeight: 0.1
+    InvalidCertificateWeight: 0.1
+    StrobeWeight: 0.1
 Filtering:
     AlwaysInclude: ["8.8.8.8/32"]
     NeverInclude: ["8.8.4.4/32"]
@@ -76,7 +87,8 @@ Filtering:
     FilterExternalToInternal: false
 `
 
-// LoadTestingConfig loads the hard coded testing config
+// LoadTestingConfig loads the hard coded testing config. An empty mongoURI keeps the
+// databases in memory with the embedded storage backend instead of using MongoDB.
 func LoadTestingConfig(mongoURI string) (*Config, error) {
 	config := &Config{}
 
@@ -97,6 +109,11 @@ func LoadTestingConfig(mongoURI string) (*Config, error) {
 		return nil, err
 	}
 
+	if mongoURI == "" {
+		config.S.Storage.Backend = EmbeddedBackend
+		config.S.Storage.EmbeddedPath = ""
+	}
+
 	config.S.Version = "v0.0.0+testing"
 	config.S.ExactVersion = "v0.0.0+testing"
 
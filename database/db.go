@@ -1,14 +1,19 @@
 package database
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/activecm/mgosec"
 	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database/embedded"
 	"github.com/blang/semver"
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/bson"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 //MinMongoDBVersion is the lower, inclusive bound on the
@@ -22,67 +27,176 @@ var MinMongoDBVersion = semver.Version{
 //MaxMongoDBVersion is the upper, exclusive bound on the
 //versions of MongoDB compatible with RITA
 var MaxMongoDBVersion = semver.Version{
-	Major: 4,
-	Minor: 3,
+	Major: 7,
+	Minor: 0,
 	Patch: 0,
 }
 
-// DB is the workhorse container for messing with the database
-type DB struct {
-	Session  *mgo.Session
-	log      *log.Logger
-	selected string
+//connectTimeout bounds how long RITA waits to reach MongoDB so that an unreachable server
+//is reported quickly. The configured socket timeout only applies to reads and writes.
+const connectTimeout = 20 * time.Second
+
+//embeddedServers holds the embedded database servers started by this process by data
+//directory. A data directory can only be opened once, so every DB using it shares its
+//server, which is stopped once the last of them is closed.
+var embeddedServers = struct {
+	sync.Mutex
+	servers map[string]*sharedServer
+}{servers: make(map[string]*sharedServer)}
+
+//sharedServer is an embedded database server along with the number of DBs using it
+type sharedServer struct {
+	server *embedded.Server
+	refs   int
 }
 
+type (
+	// DB is the workhorse container for messing with the database
+	DB struct {
+		Client   *mongo.Client
+		ctx      context.Context
+		cancel   context.CancelFunc
+		log      *log.Logger
+		selected string
+		// embedded is the embedded database server RITA is connected to, if the
+		// embedded storage backend is used, and embeddedPath is its data directory
+		embedded     *embedded.Server
+		embeddedPath string
+	}
+
+	// Index describes an index to create on a collection. Each key is a field name,
+	// prefixed with "-" for a descending index or "$hashed:" for a hashed index.
+	Index struct {
+		Key    []string
+		Unique bool
+	}
+)
+
 //NewDB constructs a new DB struct
 func NewDB(conf *config.Config, log *log.Logger) (*DB, error) {
+	// operations run under a context which is cancelled when the DB is closed
+	// so that in-flight operations are aborted rather than left hanging
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// the embedded backend serves the databases from within RITA
+	var server *embedded.Server
+	if conf.S.Storage.Backend == config.EmbeddedBackend {
+		var err error
+		server, err = openEmbedded(conf.S.Storage.EmbeddedPath)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("could not open the embedded database in %s: %v", conf.S.Storage.EmbeddedPath, err)
+		}
+	}
+
 	// Jump into the requested database
-	session, err := connectToMongoDB(conf, log)
+	client, err := connectToMongoDB(ctx, conf, server, log)
 	if err != nil {
+		cancel()
+		if server != nil {
+			closeEmbedded(conf.S.Storage.EmbeddedPath)
+		}
 		return nil, err
 	}
-	session.SetSocketTimeout(conf.S.MongoDB.SocketTimeout)
-	session.SetSyncTimeout(conf.S.MongoDB.SocketTimeout)
-	session.SetCursorTimeout(0)
 
 	return &DB{
-		Session:  session,
-		log:      log,
-		selected: "",
+		Client:       client,
+		ctx:          ctx,
+		cancel:       cancel,
+		log:          log,
+		selected:     "",
+		embedded:     server,
+		embeddedPath: conf.S.Storage.EmbeddedPath,
 	}, nil
 }
 
-//connectToMongoDB connects to MongoDB possibly with authentication and TLS
-func connectToMongoDB(conf *config.Config, logger *log.Logger) (*mgo.Session, error) {
-	connString := conf.S.MongoDB.ConnectionString
-	authMechanism := conf.R.MongoDB.AuthMechanismParsed
-	tlsConfig := conf.R.MongoDB.TLS.TLSConfig
+//openEmbedded returns the embedded database server for a data directory, starting it
+//if this process has not done so yet
+func openEmbedded(dir string) (*embedded.Server, error) {
+	embeddedServers.Lock()
+	defer embeddedServers.Unlock()
+	shared, ok := embeddedServers.servers[dir]
+	if !ok {
+		server, err := embedded.Start(dir)
+		if err != nil {
+			return nil, err
+		}
+		shared = &sharedServer{server: server}
+		embeddedServers.servers[dir] = shared
+	}
+	shared.refs++
+	return shared.server, nil
+}
 
-	var sess *mgo.Session
-	var err error
-	if conf.S.MongoDB.TLS.Enabled {
-		sess, err = mgosec.Dial(connString, authMechanism, tlsConfig)
+//closeEmbedded releases an embedded database server returned by openEmbedded and
+//stops it once it is no longer used
+func closeEmbedded(dir string) error {
+	embeddedServers.Lock()
+	defer embeddedServers.Unlock()
+	shared, ok := embeddedServers.servers[dir]
+	if !ok {
+		return nil
+	}
+	shared.refs--
+	if shared.refs > 0 {
+		return nil
+	}
+	delete(embeddedServers.servers, dir)
+	return shared.server.Close()
+}
+
+//connectToMongoDB connects to MongoDB possibly with authentication and TLS. If
+//server is given, the embedded server is connected to instead.
+func connectToMongoDB(ctx context.Context, conf *config.Config, server *embedded.Server, logger *log.Logger) (*mongo.Client, error) {
+	opts := options.Client().
+		SetSocketTimeout(conf.S.MongoDB.SocketTimeout).
+		SetConnectTimeout(connectTimeout).
+		SetServerSelectionTimeout(connectTimeout)
+
+	if server != nil {
+		// the embedded server is reached over a local socket without authentication
+		opts.ApplyURI(server.URI())
 	} else {
-		sess, err = mgosec.DialInsecure(connString, authMechanism)
+		opts.ApplyURI(conf.S.MongoDB.ConnectionString)
+
+		// the credentials are taken from the connection string, but they are
+		// only used if an authentication mechanism is configured
+		if conf.R.MongoDB.AuthMechanismParsed == "" {
+			opts.Auth = nil
+		} else {
+			if opts.Auth == nil {
+				opts.Auth = &options.Credential{}
+			}
+			opts.Auth.AuthMechanism = conf.R.MongoDB.AuthMechanismParsed
+		}
+
+		if conf.S.MongoDB.TLS.Enabled {
+			opts.SetTLSConfig(conf.R.MongoDB.TLS.TLSConfig)
+		}
 	}
+
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
-		return sess, err
+		return nil, err
 	}
 
-	buildInfo, err := sess.BuildInfo()
+	var buildInfo struct {
+		Version string `bson:"version"`
+	}
+	err = client.Database("admin").RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&buildInfo)
 	if err != nil {
-		sess.Close()
+		client.Disconnect(context.Background())
 		return nil, err
 	}
 
 	semVersion, err := semver.ParseTolerant(buildInfo.Version)
 	if err != nil {
-		sess.Close()
+		client.Disconnect(context.Background())
 		return nil, err
 	}
 
 	if !(semVersion.GE(MinMongoDBVersion) && semVersion.LT(MaxMongoDBVersion)) {
-		sess.Close()
+		client.Disconnect(context.Background())
 		return nil, fmt.Errorf(
 			"unsupported version of MongoDB. %s not within [%s, %s)",
 			semVersion.String(),
@@ -91,8 +205,34 @@ func connectToMongoDB(conf *config.Config, logger *log.Logger) (*mgo.Session, er
 		)
 	}
 
-	return sess, nil
+	return client, nil
+
+}
+
+//Context returns the context database operations should run under. It is
+//cancelled by Cancel and Close.
+func (d *DB) Context() context.Context {
+	return d.ctx
+}
 
+//Cancel aborts any in-flight database operations. Operations started
+//after Cancel is called fail immediately.
+func (d *DB) Cancel() {
+	d.cancel()
+}
+
+//Close aborts any in-flight database operations and disconnects from MongoDB.
+//The embedded database server, if used, is stopped once no other DB uses it.
+func (d *DB) Close() {
+	d.cancel()
+	d.Client.Disconnect(context.Background())
+	if d.embedded != nil {
+		if err := closeEmbedded(d.embeddedPath); err != nil {
+			d.log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Error("Failed to write the embedded database to disk")
+		}
+	}
 }
 
 //SelectDB selects a database for analysis
@@ -100,17 +240,35 @@ func (d *DB) SelectDB(db string) {
 	d.selected = db
 }
 
+//WithSelectedDB returns a copy of the DB with the given database selected. The copy
+//shares the connection to MongoDB, so selecting a database on the copy does not
+//affect the original. This allows concurrent users to work with separate databases.
+func (d *DB) WithSelectedDB(db string) *DB {
+	selected := *d
+	selected.selected = db
+	return &selected
+}
+
 //GetSelectedDB retrieves the currently selected database for analysis
 func (d *DB) GetSelectedDB() string {
 	return d.selected
 }
 
+//Collection returns a handle to a collection in the currently selected database
+func (d *DB) Collection(name string) *mongo.Collection {
+	return d.Client.Database(d.selected).Collection(name)
+}
+
+//CollectionNames returns the names of the collections in the currently
+//selected database
+func (d *DB) CollectionNames() ([]string, error) {
+	return d.Client.Database(d.selected).ListCollectionNames(d.ctx, bson.M{})
+}
+
 //CollectionExists returns true if collection exists in the currently
 //selected database
 func (d *DB) CollectionExists(table string) bool {
-	ssn := d.Session.Copy()
-	defer ssn.Close()
-	coll, err := ssn.DB(d.selected).CollectionNames()
+	coll, err := d.CollectionNames()
 	if err != nil {
 		d.log.WithFields(log.Fields{
 			"error": err.Error(),
@@ -127,37 +285,62 @@ func (d *DB) CollectionExists(table string) bool {
 
 //CreateCollection creates a new collection in the currently selected
 //database with the required indexes
-func (d *DB) CreateCollection(name string, indexes []mgo.Index) error {
-	// Make a copy of the current session
-	session := d.Session.Copy()
-	defer session.Close()
-
+func (d *DB) CreateCollection(name string, indexes []Index) error {
 	d.log.Debug("Building collection: ", name)
 
-	// Create new collection by referencing to it, no need to call Create
-	err := session.DB(d.selected).C(name).Create(
-		&mgo.CollectionInfo{},
-	)
+	// Create the collection explicitly so it exists even if no indexes are requested
+	err := d.Client.Database(d.selected).CreateCollection(d.ctx, name)
 
 	// Make sure it actually got created
 	if err != nil {
 		return err
 	}
 
-	collection := session.DB(d.selected).C(name)
+	return EnsureIndexes(d.ctx, d.Collection(name), indexes)
+}
+
+//EnsureIndexes creates the given indexes on a collection if they don't already exist
+func EnsureIndexes(ctx context.Context, collection *mongo.Collection, indexes []Index) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	models := make([]mongo.IndexModel, 0, len(indexes))
 	for _, index := range indexes {
-		err := collection.EnsureIndex(index)
-		if err != nil {
-			return err
+		models = append(models, index.model())
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, models)
+	return err
+}
+
+// model converts the index description into an index model for the driver
+func (i Index) model() mongo.IndexModel {
+	keys := bson.D{}
+	for _, field := range i.Key {
+		var order interface{} = 1
+		if strings.HasPrefix(field, "$hashed:") {
+			field = strings.TrimPrefix(field, "$hashed:")
+			order = "hashed"
+		} else if strings.HasPrefix(field, "-") {
+			field = strings.TrimPrefix(field, "-")
+			order = -1
+		} else if strings.HasPrefix(field, "+") {
+			field = strings.TrimPrefix(field, "+")
 		}
+		keys = append(keys, bson.E{Key: field, Value: order})
 	}
 
-	return nil
+	model := mongo.IndexModel{Keys: keys}
+	if i.Unique {
+		model.Options = options.Index().SetUnique(true)
+	}
+	return model
 }
 
-//AggregateCollection builds a collection via a MongoDB pipeline
-func (d *DB) AggregateCollection(sourceCollection string,
-	session *mgo.Session, pipeline []bson.D) *mgo.Iter {
+//AggregateCollection runs a MongoDB pipeline against a collection in the
+//currently selected database and returns a cursor over the results
+func (d *DB) AggregateCollection(sourceCollection string, pipeline []bson.D) *mongo.Cursor {
 
 	// Identify the source collection we will aggregate information from into the new collection
 	if !d.CollectionExists(sourceCollection) {
@@ -165,22 +348,49 @@ func (d *DB) AggregateCollection(sourceCollection string,
 			sourceCollection, " doesn't exist)")
 		return nil
 	}
-	collection := session.DB(d.selected).C(sourceCollection)
-
-	// Create the pipe
-	pipe := collection.Pipe(pipeline).AllowDiskUse()
+	collection := d.Collection(sourceCollection)
 
-	iter := pipe.Iter()
+	// Run the pipeline
+	cursor, err := collection.Aggregate(d.ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
 
 	// If error, Throw computer against wall and drink 2 angry beers while
 	// questioning your life, purpose, and relationships.
-	if iter.Err() != nil {
+	if err != nil {
 		d.log.WithFields(log.Fields{
-			"error": iter.Err().Error(),
+			"error": err.Error(),
 		}).Error("Failed aggregate operation")
 		return nil
 	}
-	return iter
+	return cursor
+}
+
+//AggregateAll runs a pipeline against a collection, allowing MongoDB to use
+//disk space for large stages, and decodes every result into results
+func AggregateAll(ctx context.Context, collection *mongo.Collection, pipeline interface{}, results interface{}) error {
+	cursor, err := collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
+
+//AggregateOne runs a pipeline against a collection, allowing MongoDB to use
+//disk space for large stages, and decodes the first result into result.
+//mongo.ErrNoDocuments is returned if the pipeline doesn't produce any results.
+func AggregateOne(ctx context.Context, collection *mongo.Collection, pipeline interface{}, result interface{}) error {
+	cursor, err := collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	if !cursor.Next(ctx) {
+		if cursor.Err() != nil {
+			return cursor.Err()
+		}
+		return mongo.ErrNoDocuments
+	}
+	return cursor.Decode(result)
 }
 
 // MergeBSONMaps recursively merges several bson.M objects into a single map.
@@ -227,3 +437,77 @@ func MergeBSONMaps(maps ...bson.M) bson.M {
 
 	return result
 }
+
+//StagedChunk is the chunk ID the results of an unfinished import batch are written under.
+//The results are moved into the chunk being imported once the batch finishes.
+const StagedChunk = -1
+
+//stashedDatField holds the earlier state of a dat subdocument which a staged import batch
+//moved into the staged chunk, so that the subdocument can be restored if the batch is rolled back
+const stashedDatField = "prev"
+
+//FindDatEntry returns the dat subdocument matched by the selector in the first document
+//matching the selector. The selector must match on the dat array. nil is returned if
+//no document matches.
+func FindDatEntry(ctx context.Context, collection *mongo.Collection, selector bson.M) (bson.Raw, error) {
+	var result struct {
+		Dat []bson.Raw `bson:"dat"`
+	}
+	err := collection.FindOne(ctx, selector, options.FindOne().SetProjection(bson.M{"dat.$": 1})).Decode(&result)
+	if err == mongo.ErrNoDocuments || (err == nil && len(result.Dat) == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result.Dat[0], nil
+}
+
+//MoveDatEntry formats an update which sets the given fields of a dat subdocument found
+//with FindDatEntry and moves the subdocument into the given chunk. The update must be
+//run with the selector passed to FindDatEntry. If a staged import batch moves a subdocument
+//from another chunk, the subdocument is stashed so that it can be restored on rollback.
+func MoveDatEntry(entry bson.Raw, fields bson.M, chunk int) bson.M {
+	set := bson.M{"dat.$.cid": chunk}
+	for field, value := range fields {
+		set["dat.$."+field] = value
+	}
+
+	entryCID, _ := entry.Lookup("cid").AsInt64OK()
+	_, stashed := entry.Lookup(stashedDatField).DocumentOK()
+	if chunk == StagedChunk && entryCID != StagedChunk && !stashed {
+		set["dat.$."+stashedDatField] = entry
+	}
+	return bson.M{"$set": set}
+}
+
+//RestoreStagedDatEntries returns the dat subdocuments which an unfinished import batch moved
+//into the staged chunk to the state they had before the batch. The subdocuments the batch
+//wrote are left under the staged chunk ID.
+func RestoreStagedDatEntries(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.UpdateMany(ctx,
+		bson.M{"dat." + stashedDatField: bson.M{"$exists": true}},
+		[]bson.M{{"$set": bson.M{"dat": bson.M{"$map": bson.M{
+			"input": "$dat",
+			"as":    "entry",
+			"in":    bson.M{"$ifNull": []interface{}{"$$entry." + stashedDatField, "$$entry"}},
+		}}}}},
+	)
+	return err
+}
+
+//CommitStagedDatEntries moves the dat subdocuments written by a finished import batch
+//into the given chunk and drops the earlier states stashed by MoveDatEntry
+func CommitStagedDatEntries(ctx context.Context, collection *mongo.Collection, staged int, cid int) error {
+	_, err := collection.UpdateMany(ctx,
+		bson.M{"dat.cid": staged},
+		bson.M{
+			"$set":   bson.M{"dat.$[staged].cid": cid},
+			"$unset": bson.M{"dat.$[staged]." + stashedDatField: ""},
+		},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"staged.cid": staged}},
+		}),
+	)
+	return err
+}
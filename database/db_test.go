@@ -0,0 +1,32 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMoveDatEntry(t *testing.T) {
+	earlierEntry, err := bson.Marshal(bson.M{"mdip": "1.1.1.1", "max_duration": 10.0, "cid": 3})
+	require.NoError(t, err)
+
+	// a staged batch stashes subdocuments it moves out of earlier chunks
+	update := MoveDatEntry(earlierEntry, bson.M{"max_duration": 20.0}, StagedChunk)
+	assert.Equal(t, bson.M{"$set": bson.M{
+		"dat.$.max_duration": 20.0,
+		"dat.$.cid":          StagedChunk,
+		"dat.$.prev":         bson.Raw(earlierEntry),
+	}}, update)
+
+	// the earliest state is kept if the batch moves the subdocument again
+	stagedEntry, err := bson.Marshal(bson.M{"max_duration": 20.0, "cid": StagedChunk, "prev": bson.Raw(earlierEntry)})
+	require.NoError(t, err)
+	update = MoveDatEntry(stagedEntry, bson.M{"max_duration": 30.0}, StagedChunk)
+	assert.Equal(t, bson.M{"$set": bson.M{"dat.$.max_duration": 30.0, "dat.$.cid": StagedChunk}}, update)
+
+	// nothing is stashed outside of a staged batch
+	update = MoveDatEntry(earlierEntry, bson.M{"max_duration": 20.0}, 4)
+	assert.Equal(t, bson.M{"$set": bson.M{"dat.$.max_duration": 20.0, "dat.$.cid": 4}}, update)
+}
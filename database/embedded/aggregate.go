@@ -0,0 +1,526 @@
+package embedded
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// pipeline runs aggregation pipelines. Stages never modify the documents they are
+// given, so stored documents can be passed in without copying them.
+type pipeline struct {
+	// collection returns the documents of another collection in the same database
+	// for $lookup
+	collection func(name string) ([]bson.D, error)
+	// vars holds the variables defined by an enclosing $lookup
+	vars *scope
+}
+
+// run runs the stages of a pipeline over docs
+func (p *pipeline) run(docs []bson.D, stages bson.A) ([]bson.D, error) {
+	for _, stage := range stages {
+		stageDoc, ok := stage.(bson.D)
+		if !ok || len(stageDoc) != 1 {
+			return nil, errors.New("a pipeline stage specification object must contain exactly one field")
+		}
+		var err error
+		docs, err = p.runStage(docs, stageDoc[0].Key, stageDoc[0].Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+// runStage runs a single pipeline stage
+func (p *pipeline) runStage(docs []bson.D, name string, spec interface{}) ([]bson.D, error) {
+	switch name {
+	case "$match":
+		filter, ok := spec.(bson.D)
+		if !ok {
+			return nil, errors.New("the match filter must be an expression in an object")
+		}
+		out := make([]bson.D, 0, len(docs))
+		for _, doc := range docs {
+			matched, err := matchDocument(doc, filter, nil, p.vars)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				out = append(out, doc)
+			}
+		}
+		return out, nil
+	case "$project", "$unset":
+		proj, err := stageProjection(name, spec)
+		if err != nil {
+			return nil, err
+		}
+		return p.mapDocs(docs, func(doc bson.D) (bson.D, error) {
+			return proj.apply(doc, p.vars)
+		})
+	case "$addFields", "$set":
+		fields, ok := spec.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("%s specification stage must be an object", name)
+		}
+		return p.mapDocs(docs, func(doc bson.D) (bson.D, error) {
+			return addFields(doc, fields, p.vars)
+		})
+	case "$replaceRoot", "$replaceWith":
+		return p.mapDocs(docs, func(doc bson.D) (bson.D, error) {
+			return replaceRoot(name, spec, doc, p.vars)
+		})
+	case "$unwind":
+		return unwind(docs, spec)
+	case "$group":
+		return p.group(docs, spec)
+	case "$sort":
+		keys, ok := spec.(bson.D)
+		if !ok || len(keys) == 0 {
+			return nil, errors.New("$sort key specification must be an object")
+		}
+		sorted := make([]bson.D, len(docs))
+		copy(sorted, docs)
+		sortDocuments(sorted, keys)
+		return sorted, nil
+	case "$limit":
+		n, ok := toInt(spec)
+		if !ok || n <= 0 {
+			return nil, errors.New("the limit must be positive")
+		}
+		if int(n) < len(docs) {
+			docs = docs[:n]
+		}
+		return docs, nil
+	case "$skip":
+		n, ok := toInt(spec)
+		if !ok || n < 0 {
+			return nil, errors.New("the skip must be a non-negative number")
+		}
+		if int(n) >= len(docs) {
+			return nil, nil
+		}
+		return docs[n:], nil
+	case "$count":
+		field, ok := spec.(string)
+		if !ok || field == "" || strings.HasPrefix(field, "$") || strings.Contains(field, ".") {
+			return nil, errors.New("the count field must be a non-empty string without '$' or '.'")
+		}
+		if len(docs) == 0 {
+			return nil, nil
+		}
+		return []bson.D{{{Key: field, Value: normalizeInt(int64(len(docs)))}}}, nil
+	case "$lookup":
+		return p.lookup(docs, spec)
+	case "$facet":
+		facets, ok := spec.(bson.D)
+		if !ok {
+			return nil, errors.New("the $facet specification must be an object")
+		}
+		result := bson.D{}
+		for _, facet := range facets {
+			stages, isArr := facet.Value.(bson.A)
+			if !isArr {
+				return nil, fmt.Errorf("arguments to $facet must be arrays, %s is type %s", facet.Key, typeName(facet.Value))
+			}
+			out, err := p.run(docs, stages)
+			if err != nil {
+				return nil, err
+			}
+			arr := make(bson.A, len(out))
+			for i, doc := range out {
+				arr[i] = doc
+			}
+			result = append(result, bson.E{Key: facet.Key, Value: arr})
+		}
+		return []bson.D{result}, nil
+	}
+	return nil, fmt.Errorf("unrecognized pipeline stage name: '%s'", name)
+}
+
+// mapDocs transforms every document
+func (p *pipeline) mapDocs(docs []bson.D, fn func(bson.D) (bson.D, error)) ([]bson.D, error) {
+	out := make([]bson.D, len(docs))
+	for i, doc := range docs {
+		var err error
+		out[i], err = fn(doc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// stageProjection parses the specification of a $project or $unset stage
+func stageProjection(name string, spec interface{}) (*projection, error) {
+	if name == "$project" {
+		doc, ok := spec.(bson.D)
+		if !ok {
+			return nil, errors.New("$project specification must be an object")
+		}
+		return parseProjection(doc)
+	}
+
+	var fields bson.A
+	switch val := spec.(type) {
+	case string:
+		fields = bson.A{val}
+	case bson.A:
+		fields = val
+	default:
+		return nil, errors.New("$unset specification must be a string or an array")
+	}
+	doc := bson.D{}
+	for _, field := range fields {
+		name, ok := field.(string)
+		if !ok {
+			return nil, errors.New("$unset specification must be a string or an array containing only string values")
+		}
+		doc = append(doc, bson.E{Key: name, Value: int32(0)})
+	}
+	return parseProjection(doc)
+}
+
+// replaceRoot evaluates the new root document of a $replaceRoot or $replaceWith stage
+func replaceRoot(name string, spec interface{}, doc bson.D, vars *scope) (bson.D, error) {
+	expr := spec
+	if name == "$replaceRoot" {
+		opts, ok := spec.(bson.D)
+		if !ok {
+			return nil, errors.New("$replaceRoot specification must be an object")
+		}
+		newRoot, hasRoot := lookupField(opts, "newRoot")
+		if !hasRoot {
+			return nil, errors.New("no newRoot specified for the $replaceRoot stage")
+		}
+		expr = newRoot
+	}
+	v, err := evalExpr(expr, newScope(doc, vars))
+	if err != nil {
+		return nil, err
+	}
+	root, ok := v.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("'newRoot' expression must evaluate to an object, but resulting value was of type %s", typeName(v))
+	}
+	return root, nil
+}
+
+// unwind runs an $unwind stage, which outputs a document for each element of an array
+func unwind(docs []bson.D, spec interface{}) ([]bson.D, error) {
+	var path, indexField string
+	preserve := false
+	switch val := spec.(type) {
+	case string:
+		path = val
+	case bson.D:
+		for _, opt := range val {
+			switch opt.Key {
+			case "path":
+				path, _ = opt.Value.(string)
+			case "includeArrayIndex":
+				indexField, _ = opt.Value.(string)
+			case "preserveNullAndEmptyArrays":
+				preserve = isTruthy(opt.Value)
+			default:
+				return nil, fmt.Errorf("unrecognized option to $unwind: %s", opt.Key)
+			}
+		}
+	default:
+		return nil, errors.New("expected either a string or an object as specification for $unwind stage")
+	}
+	if !strings.HasPrefix(path, "$") {
+		return nil, errors.New("path option to $unwind stage should be prefixed with a '$'")
+	}
+	parts := strings.Split(path[1:], ".")
+
+	var out []bson.D
+	for _, doc := range docs {
+		v := lookupPath(doc, path[1:])
+		arr, isArr := v.(bson.A)
+		if !isArr {
+			if isNullish(v) {
+				if preserve {
+					out = append(out, withIndex(doc, indexField, nil))
+				}
+				continue
+			}
+			out = append(out, withIndex(doc, indexField, nil))
+			continue
+		}
+		if len(arr) == 0 {
+			if preserve {
+				out = append(out, withIndex(withPath(doc, parts, missing), indexField, nil))
+			}
+			continue
+		}
+		for i, elem := range arr {
+			out = append(out, withIndex(withPath(doc, parts, elem), indexField, int64(i)))
+		}
+	}
+	return out, nil
+}
+
+// withIndex sets the includeArrayIndex field of an unwound document
+func withIndex(doc bson.D, field string, idx interface{}) bson.D {
+	if field == "" {
+		return doc
+	}
+	return withPath(doc, strings.Split(field, "."), idx)
+}
+
+// group runs a $group stage. Groups are output in the order they are first seen.
+func (p *pipeline) group(docs []bson.D, spec interface{}) ([]bson.D, error) {
+	fields, ok := spec.(bson.D)
+	if !ok {
+		return nil, errors.New("a group's fields must be specified in an object")
+	}
+	idExpr, hasID := lookupField(fields, "_id")
+	if !hasID {
+		return nil, errors.New("a group specification must include an _id")
+	}
+
+	type accField struct {
+		name string
+		op   string
+		expr interface{}
+	}
+	var accFields []accField
+	for _, field := range fields {
+		if field.Key == "_id" {
+			continue
+		}
+		def, isDoc := field.Value.(bson.D)
+		if !isDoc || len(def) != 1 {
+			return nil, fmt.Errorf("the field '%s' must be an accumulator object", field.Key)
+		}
+		if newAccumulator(def[0].Key) == nil {
+			return nil, fmt.Errorf("unknown group operator '%s'", def[0].Key)
+		}
+		accFields = append(accFields, accField{name: field.Key, op: def[0].Key, expr: def[0].Value})
+	}
+
+	type groupState struct {
+		id   interface{}
+		accs []accumulator
+	}
+	groups := make(map[string]*groupState)
+	var order []*groupState
+
+	for _, doc := range docs {
+		s := newScope(doc, p.vars)
+		id, err := evalExpr(idExpr, s)
+		if err != nil {
+			return nil, err
+		}
+		if _, isMissing := id.(missingValue); isMissing {
+			id = nil
+		}
+		key := groupKey(id)
+		g, exists := groups[key]
+		if !exists {
+			g = &groupState{id: id}
+			for _, f := range accFields {
+				g.accs = append(g.accs, newAccumulator(f.op))
+			}
+			groups[key] = g
+			order = append(order, g)
+		}
+		for i, f := range accFields {
+			v, err := evalExpr(f.expr, s)
+			if err != nil {
+				return nil, err
+			}
+			if err := g.accs[i].add(v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	out := make([]bson.D, 0, len(order))
+	for _, g := range order {
+		doc := bson.D{{Key: "_id", Value: g.id}}
+		for i, f := range accFields {
+			doc = append(doc, bson.E{Key: f.name, Value: g.accs[i].result()})
+		}
+		out = append(out, doc)
+	}
+	return out, nil
+}
+
+// lookup runs a $lookup stage, either joining on equal fields or running a pipeline
+// on the foreign collection for each document
+func (p *pipeline) lookup(docs []bson.D, spec interface{}) ([]bson.D, error) {
+	opts, ok := spec.(bson.D)
+	if !ok {
+		return nil, errors.New("the $lookup specification must be an object")
+	}
+	var from, localField, foreignField, as string
+	var let bson.D
+	var stages bson.A
+	hasPipeline := false
+	for _, opt := range opts {
+		switch opt.Key {
+		case "from":
+			from, _ = opt.Value.(string)
+		case "localField":
+			localField, _ = opt.Value.(string)
+		case "foreignField":
+			foreignField, _ = opt.Value.(string)
+		case "as":
+			as, _ = opt.Value.(string)
+		case "let":
+			let, _ = opt.Value.(bson.D)
+		case "pipeline":
+			stages, _ = opt.Value.(bson.A)
+			hasPipeline = true
+		default:
+			return nil, fmt.Errorf("unknown argument to $lookup: %s", opt.Key)
+		}
+	}
+	if from == "" || as == "" {
+		return nil, errors.New("$lookup requires 'from' and 'as' to be specified")
+	}
+	if !hasPipeline && (localField == "" || foreignField == "") {
+		return nil, errors.New("$lookup requires either 'pipeline' or both 'localField' and 'foreignField' to be specified")
+	}
+
+	foreign, err := p.collection(from)
+	if err != nil {
+		return nil, err
+	}
+	asPath := strings.Split(as, ".")
+	out := make([]bson.D, len(docs))
+
+	if hasPipeline {
+		for i, doc := range docs {
+			vars := make(map[string]interface{}, len(let))
+			s := newScope(doc, p.vars)
+			for _, v := range let {
+				val, err := evalExpr(v.Value, s)
+				if err != nil {
+					return nil, err
+				}
+				vars[v.Key] = val
+			}
+			sub := &pipeline{collection: p.collection, vars: p.vars.with(vars)}
+			joined, err := sub.run(foreign, stages)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = withPath(doc, asPath, documentArray(joined))
+		}
+		return out, nil
+	}
+
+	// index the foreign documents by the values of their foreign field
+	foreignPath := strings.Split(foreignField, ".")
+	byValue := make(map[string][]int)
+	for i, doc := range foreign {
+		for _, key := range lookupKeys(resolveFieldPath(doc, foreignPath)) {
+			if len(byValue[key]) == 0 || byValue[key][len(byValue[key])-1] != i {
+				byValue[key] = append(byValue[key], i)
+			}
+		}
+	}
+
+	localPath := strings.Split(localField, ".")
+	for i, doc := range docs {
+		seen := make(map[int]struct{})
+		var matches []int
+		for _, key := range lookupKeys(resolveFieldPath(doc, localPath)) {
+			for _, idx := range byValue[key] {
+				if _, dup := seen[idx]; !dup {
+					seen[idx] = struct{}{}
+					matches = append(matches, idx)
+				}
+			}
+		}
+		sort.Ints(matches)
+		joined := make([]bson.D, len(matches))
+		for j, idx := range matches {
+			joined[j] = foreign[idx]
+		}
+		out[i] = withPath(doc, asPath, documentArray(joined))
+	}
+	return out, nil
+}
+
+// lookupKeys returns the keys a value matches in a $lookup equality join. Arrays
+// match on each of their elements and missing values match null.
+func lookupKeys(v interface{}) []string {
+	if _, isMissing := v.(missingValue); isMissing {
+		v = nil
+	}
+	arr, isArr := v.(bson.A)
+	if !isArr {
+		return []string{groupKey(v)}
+	}
+	keys := []string{groupKey(arr)}
+	for _, elem := range arr {
+		keys = append(keys, groupKey(elem))
+	}
+	if len(arr) == 0 {
+		keys = append(keys, groupKey(nil))
+	}
+	return keys
+}
+
+// documentArray converts documents to an array value
+func documentArray(docs []bson.D) bson.A {
+	arr := make(bson.A, len(docs))
+	for i, doc := range docs {
+		arr[i] = doc
+	}
+	return arr
+}
+
+// sortDocuments stably sorts documents by a sort specification
+func sortDocuments(docs []bson.D, keys bson.D) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		return compareSortKeys(docs[i], docs[j], keys) < 0
+	})
+}
+
+// compareSortKeys compares two documents by a sort specification
+func compareSortKeys(a, b bson.D, keys bson.D) int {
+	for _, key := range keys {
+		order := 1
+		if n, ok := toInt(key.Value); ok && n < 0 {
+			order = -1
+		}
+		path := strings.Split(key.Key, ".")
+		av := sortValue(resolveFieldPath(a, path), order)
+		bv := sortValue(resolveFieldPath(b, path), order)
+		if c := compareValues(av, bv); c != 0 {
+			return c * order
+		}
+	}
+	return 0
+}
+
+// sortValue returns the value a document sorts by. Arrays sort by their smallest
+// element in ascending sorts and by their largest element in descending sorts.
+func sortValue(v interface{}, order int) interface{} {
+	if _, isMissing := v.(missingValue); isMissing {
+		return nil
+	}
+	arr, isArr := v.(bson.A)
+	if !isArr {
+		return v
+	}
+	if len(arr) == 0 {
+		return nil
+	}
+	best := arr[0]
+	for _, elem := range arr[1:] {
+		if compareValues(elem, best)*order < 0 {
+			best = elem
+		}
+	}
+	return best
+}
@@ -0,0 +1,1000 @@
+package embedded
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// server limits reported to clients
+const (
+	maxBSONObjectSize = 16 * 1024 * 1024
+	maxWriteBatchSize = 100000
+	// maxBatchBytes bounds the size of the documents returned in a single batch so
+	// that replies stay within the message size limit
+	maxBatchBytes = maxBSONObjectSize
+	// defaultBatchSize is the number of documents in the first batch of a cursor if
+	// the client does not ask for a batch size
+	defaultBatchSize = 101
+	// cursorTimeout is how long an unused cursor is kept
+	cursorTimeout = 10 * time.Minute
+)
+
+// serverVersion is the MongoDB version the server reports. RITA checks it against
+// the range of versions it supports.
+const serverVersion = "4.4.0"
+
+// codeNames holds the names of the error codes reported to clients
+var codeNames = map[int32]string{
+	codeBadValue:             "BadValue",
+	codeFailedToParse:        "FailedToParse",
+	codeNamespaceNotFound:    "NamespaceNotFound",
+	codeIndexNotFound:        "IndexNotFound",
+	codeCursorNotFound:       "CursorNotFound",
+	codeNamespaceExists:      "NamespaceExists",
+	codeInvalidNamespace:     "InvalidNamespace",
+	codeIndexOptionsConflict: "IndexOptionsConflict",
+	codeCommandNotFound:      "CommandNotFound",
+	codeDuplicateKey:         "DuplicateKey",
+}
+
+// commandFunc runs a command against a database and returns the fields of its reply
+type commandFunc func(s *Server, db string, cmd bson.D) (bson.D, error)
+
+// commands maps the names of the supported commands to their implementations
+var commands map[string]commandFunc
+
+func init() {
+	commands = map[string]commandFunc{
+		"hello":           cmdHello,
+		"isMaster":        cmdHello,
+		"ismaster":        cmdHello,
+		"buildInfo":       cmdBuildInfo,
+		"buildinfo":       cmdBuildInfo,
+		"ping":            cmdEmpty,
+		"endSessions":     cmdEmpty,
+		"listDatabases":   cmdListDatabases,
+		"dropDatabase":    cmdDropDatabase,
+		"listCollections": cmdListCollections,
+		"create":          cmdCreate,
+		"drop":            cmdDrop,
+		"createIndexes":   cmdCreateIndexes,
+		"listIndexes":     cmdListIndexes,
+		"dropIndexes":     cmdDropIndexes,
+		"dbStats":         cmdDBStats,
+		"insert":          cmdInsert,
+		"update":          cmdUpdate,
+		"delete":          cmdDelete,
+		"find":            cmdFind,
+		"getMore":         cmdGetMore,
+		"killCursors":     cmdKillCursors,
+		"aggregate":       cmdAggregate,
+		"count":           cmdCount,
+		"distinct":        cmdDistinct,
+	}
+}
+
+// handleCommand runs a command and builds its reply
+func (s *Server) handleCommand(cmd bson.D) bson.D {
+	if len(cmd) == 0 {
+		return errorReply(errorf(codeFailedToParse, "empty command"))
+	}
+	fn, ok := commands[cmd[0].Key]
+	if !ok {
+		return errorReply(errorf(codeCommandNotFound, "no such command: '%s'", cmd[0].Key))
+	}
+	db := "admin"
+	if name, ok := lookupField(cmd, "$db"); ok {
+		if nameStr, isStr := name.(string); isStr {
+			db = nameStr
+		}
+	}
+	reply, err := fn(s, db, cmd)
+	// the changes of the command are handed to the operating system before the
+	// client hears of them
+	if journalErr := s.engine.journal.commit(); journalErr != nil && err == nil {
+		err = journalErr
+	}
+	if err != nil {
+		return errorReply(err)
+	}
+	return append(reply, bson.E{Key: "ok", Value: 1.0})
+}
+
+// errorReply builds the reply to a failed command
+func errorReply(err error) bson.D {
+	code := errorCode(err)
+	return bson.D{
+		{Key: "ok", Value: 0.0},
+		{Key: "errmsg", Value: err.Error()},
+		{Key: "code", Value: code},
+		{Key: "codeName", Value: codeNames[code]},
+	}
+}
+
+// collectionArg returns the collection name given as the value of a command
+func collectionArg(cmd bson.D) (string, error) {
+	name, ok := cmd[0].Value.(string)
+	if !ok || name == "" {
+		return "", errorf(codeInvalidNamespace, "collection name has invalid type %s", typeName(cmd[0].Value))
+	}
+	return name, nil
+}
+
+// docArg returns an optional document argument of a command
+func docArg(cmd bson.D, key string) (bson.D, error) {
+	v, ok := lookupField(cmd, key)
+	if !ok || isNullish(v) {
+		return bson.D{}, nil
+	}
+	doc, isDoc := v.(bson.D)
+	if !isDoc {
+		return nil, errorf(codeFailedToParse, "'%s' must be an object", key)
+	}
+	return doc, nil
+}
+
+// intArg returns an optional integer argument of a command
+func intArg(cmd bson.D, key string) (int64, error) {
+	v, ok := lookupField(cmd, key)
+	if !ok || isNullish(v) {
+		return 0, nil
+	}
+	n, isInt := toInt(v)
+	if !isInt {
+		return 0, errorf(codeFailedToParse, "'%s' must be a number", key)
+	}
+	return n, nil
+}
+
+func cmdEmpty(s *Server, db string, cmd bson.D) (bson.D, error) {
+	return bson.D{}, nil
+}
+
+func cmdHello(s *Server, db string, cmd bson.D) (bson.D, error) {
+	primary := "ismaster"
+	if cmd[0].Key == "hello" {
+		primary = "isWritablePrimary"
+	}
+	return bson.D{
+		{Key: primary, Value: true},
+		{Key: "helloOk", Value: true},
+		{Key: "maxBsonObjectSize", Value: int32(maxBSONObjectSize)},
+		{Key: "maxMessageSizeBytes", Value: int32(maxMessageSize)},
+		{Key: "maxWriteBatchSize", Value: int32(maxWriteBatchSize)},
+		{Key: "localTime", Value: primitive.NewDateTimeFromTime(time.Now())},
+		{Key: "minWireVersion", Value: int32(0)},
+		{Key: "maxWireVersion", Value: int32(9)},
+		{Key: "readOnly", Value: false},
+	}, nil
+}
+
+func cmdBuildInfo(s *Server, db string, cmd bson.D) (bson.D, error) {
+	return bson.D{
+		{Key: "version", Value: serverVersion},
+		{Key: "versionArray", Value: bson.A{int32(4), int32(4), int32(0), int32(0)}},
+		{Key: "bits", Value: int32(64)},
+		{Key: "maxBsonObjectSize", Value: int32(maxBSONObjectSize)},
+	}, nil
+}
+
+func cmdListDatabases(s *Server, db string, cmd bson.D) (bson.D, error) {
+	filter, err := docArg(cmd, "filter")
+	if err != nil {
+		return nil, err
+	}
+	nameOnly, _ := lookupField(cmd, "nameOnly")
+
+	s.engine.mu.RLock()
+	defer s.engine.mu.RUnlock()
+	databases := bson.A{}
+	var total int64
+	for _, name := range s.engine.databaseNames() {
+		info := bson.D{{Key: "name", Value: name}}
+		if !isTruthy(nameOnly) {
+			var size int64
+			for _, c := range s.engine.databases[name].collections {
+				size += c.size()
+			}
+			total += size
+			info = append(info,
+				bson.E{Key: "sizeOnDisk", Value: size},
+				bson.E{Key: "empty", Value: size == 0},
+			)
+		}
+		matched, err := matchDocument(info, filter, nil, nil)
+		if err != nil {
+			return nil, errorf(codeBadValue, "%s", err.Error())
+		}
+		if matched {
+			databases = append(databases, info)
+		}
+	}
+	reply := bson.D{{Key: "databases", Value: databases}}
+	if !isTruthy(nameOnly) {
+		reply = append(reply, bson.E{Key: "totalSize", Value: total})
+	}
+	return reply, nil
+}
+
+func cmdDropDatabase(s *Server, db string, cmd bson.D) (bson.D, error) {
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	s.engine.dropDatabase(db)
+	return bson.D{{Key: "dropped", Value: db}}, nil
+}
+
+func cmdListCollections(s *Server, db string, cmd bson.D) (bson.D, error) {
+	filter, err := docArg(cmd, "filter")
+	if err != nil {
+		return nil, err
+	}
+	cursorOpts, err := docArg(cmd, "cursor")
+	if err != nil {
+		return nil, err
+	}
+	batchSize, err := intArg(cursorOpts, "batchSize")
+	if err != nil {
+		return nil, err
+	}
+
+	s.engine.mu.RLock()
+	var infos []bson.D
+	for _, name := range s.engine.collectionNames(db) {
+		info := bson.D{
+			{Key: "name", Value: name},
+			{Key: "type", Value: "collection"},
+			{Key: "options", Value: bson.D{}},
+			{Key: "info", Value: bson.D{{Key: "readOnly", Value: false}}},
+			{Key: "idIndex", Value: s.engine.collection(db, name).indexes[0].spec},
+		}
+		matched, err := matchDocument(info, filter, nil, nil)
+		if err != nil {
+			s.engine.mu.RUnlock()
+			return nil, errorf(codeBadValue, "%s", err.Error())
+		}
+		if matched {
+			infos = append(infos, info)
+		}
+	}
+	s.engine.mu.RUnlock()
+	return s.cursors.open(db+".$cmd.listCollections", infos, int(batchSize))
+}
+
+func cmdCreate(s *Server, db string, cmd bson.D) (bson.D, error) {
+	coll, err := collectionArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	if s.engine.collection(db, coll) != nil {
+		return nil, errorf(codeNamespaceExists, "Collection already exists. NS: %s.%s", db, coll)
+	}
+	_, err = s.engine.ensureCollection(db, coll)
+	return bson.D{}, err
+}
+
+func cmdDrop(s *Server, db string, cmd bson.D) (bson.D, error) {
+	coll, err := collectionArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	c := s.engine.collection(db, coll)
+	if c == nil {
+		return nil, errorf(codeNamespaceNotFound, "ns not found")
+	}
+	indexes := len(c.indexes)
+	s.engine.dropCollection(db, coll)
+	return bson.D{
+		{Key: "nIndexesWas", Value: int32(indexes)},
+		{Key: "ns", Value: db + "." + coll},
+	}, nil
+}
+
+func cmdCreateIndexes(s *Server, db string, cmd bson.D) (bson.D, error) {
+	coll, err := collectionArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	specs, _ := lookupField(cmd, "indexes")
+	specArr, ok := specs.(bson.A)
+	if !ok || len(specArr) == 0 {
+		return nil, errorf(codeFailedToParse, "'indexes' must be a non-empty array")
+	}
+
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	created := s.engine.collection(db, coll) == nil
+	c, err := s.engine.ensureCollection(db, coll)
+	if err != nil {
+		return nil, err
+	}
+	before := len(c.indexes)
+	for _, spec := range specArr {
+		specDoc, isDoc := spec.(bson.D)
+		if !isDoc {
+			return nil, errorf(codeFailedToParse, "index specifications must be objects")
+		}
+		if err := c.createIndex(specDoc); err != nil {
+			return nil, err
+		}
+	}
+	return bson.D{
+		{Key: "createdCollectionAutomatically", Value: created},
+		{Key: "numIndexesBefore", Value: int32(before)},
+		{Key: "numIndexesAfter", Value: int32(len(c.indexes))},
+	}, nil
+}
+
+func cmdListIndexes(s *Server, db string, cmd bson.D) (bson.D, error) {
+	coll, err := collectionArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	s.engine.mu.RLock()
+	c := s.engine.collection(db, coll)
+	if c == nil {
+		s.engine.mu.RUnlock()
+		return nil, errorf(codeNamespaceNotFound, "ns does not exist: %s.%s", db, coll)
+	}
+	specs := make([]bson.D, len(c.indexes))
+	for i, idx := range c.indexes {
+		specs[i] = idx.spec
+	}
+	s.engine.mu.RUnlock()
+	return s.cursors.open(db+"."+coll, specs, 0)
+}
+
+func cmdDropIndexes(s *Server, db string, cmd bson.D) (bson.D, error) {
+	coll, err := collectionArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	c := s.engine.collection(db, coll)
+	if c == nil {
+		return nil, errorf(codeNamespaceNotFound, "ns not found %s.%s", db, coll)
+	}
+	before := len(c.indexes)
+
+	target, _ := lookupField(cmd, "index")
+	var names []string
+	switch val := target.(type) {
+	case string:
+		names = []string{val}
+	case bson.A:
+		for _, name := range val {
+			if nameStr, ok := name.(string); ok {
+				names = append(names, nameStr)
+			}
+		}
+	case bson.D:
+		for _, idx := range c.indexes {
+			if key, _ := lookupField(idx.spec, "key"); valuesEqual(key, val) {
+				names = append(names, idx.name)
+			}
+		}
+		if len(names) == 0 {
+			return nil, errorf(codeIndexNotFound, "can't find index with key: %v", val)
+		}
+	default:
+		return nil, errorf(codeFailedToParse, "'index' must be a string, an array or an object")
+	}
+	for _, name := range names {
+		if err := c.dropIndex(name); err != nil {
+			return nil, err
+		}
+	}
+	return bson.D{{Key: "nIndexesWas", Value: int32(before)}}, nil
+}
+
+func cmdDBStats(s *Server, db string, cmd bson.D) (bson.D, error) {
+	scale, err := intArg(cmd, "scale")
+	if err != nil {
+		return nil, err
+	}
+	if scale <= 0 {
+		scale = 1
+	}
+
+	s.engine.mu.RLock()
+	defer s.engine.mu.RUnlock()
+	var collections, objects, indexes, dataSize int64
+	for _, name := range s.engine.collectionNames(db) {
+		c := s.engine.collection(db, name)
+		collections++
+		objects += int64(c.count())
+		indexes += int64(len(c.indexes))
+		dataSize += c.size()
+	}
+	var avgObjSize float64
+	if objects > 0 {
+		avgObjSize = float64(dataSize) / float64(objects)
+	}
+	return bson.D{
+		{Key: "db", Value: db},
+		{Key: "collections", Value: collections},
+		{Key: "views", Value: int64(0)},
+		{Key: "objects", Value: objects},
+		{Key: "avgObjSize", Value: avgObjSize},
+		{Key: "dataSize", Value: dataSize / scale},
+		{Key: "storageSize", Value: dataSize / scale},
+		{Key: "indexes", Value: indexes},
+		{Key: "indexSize", Value: int64(0)},
+		{Key: "totalSize", Value: dataSize / scale},
+		{Key: "scaleFactor", Value: scale},
+	}, nil
+}
+
+// writeErrorsField builds the writeErrors field of a write command's reply
+func writeErrorsField(errs []writeError) bson.E {
+	arr := make(bson.A, len(errs))
+	for i, e := range errs {
+		arr[i] = bson.D{
+			{Key: "index", Value: int32(e.index)},
+			{Key: "code", Value: errorCode(e.err)},
+			{Key: "errmsg", Value: e.err.Error()},
+		}
+	}
+	return bson.E{Key: "writeErrors", Value: arr}
+}
+
+// orderedArg returns whether a write command stops at its first error
+func orderedArg(cmd bson.D) bool {
+	ordered, ok := lookupField(cmd, "ordered")
+	return !ok || isTruthy(ordered)
+}
+
+// statements returns the documents of an array argument of a write command
+func statements(cmd bson.D, key string) ([]bson.D, error) {
+	v, _ := lookupField(cmd, key)
+	arr, ok := v.(bson.A)
+	if !ok {
+		return nil, errorf(codeFailedToParse, "'%s' must be an array", key)
+	}
+	docs := make([]bson.D, len(arr))
+	for i, elem := range arr {
+		doc, isDoc := elem.(bson.D)
+		if !isDoc {
+			return nil, errorf(codeFailedToParse, "'%s' must only hold objects", key)
+		}
+		docs[i] = doc
+	}
+	return docs, nil
+}
+
+func cmdInsert(s *Server, db string, cmd bson.D) (bson.D, error) {
+	coll, err := collectionArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := statements(cmd, "documents")
+	if err != nil {
+		return nil, err
+	}
+
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	n, errs, err := s.engine.insertDocuments(db, coll, docs, orderedArg(cmd))
+	if err != nil {
+		return nil, err
+	}
+	reply := bson.D{{Key: "n", Value: int32(n)}}
+	if len(errs) > 0 {
+		reply = append(reply, writeErrorsField(errs))
+	}
+	return reply, nil
+}
+
+func cmdUpdate(s *Server, db string, cmd bson.D) (bson.D, error) {
+	coll, err := collectionArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	stmts, err := statements(cmd, "updates")
+	if err != nil {
+		return nil, err
+	}
+	if err := validateNames(db, coll); err != nil {
+		return nil, err
+	}
+
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	var n, modified int
+	upserted := bson.A{}
+	var errs []writeError
+	for i, stmt := range stmts {
+		spec, err := parseUpdateStatement(stmt)
+		var res updateResult
+		if err == nil {
+			res, err = s.engine.updateDocuments(db, coll, spec)
+		}
+		if err != nil {
+			errs = append(errs, writeError{index: i, err: err})
+			if orderedArg(cmd) {
+				break
+			}
+			continue
+		}
+		n += res.matched
+		modified += res.modified
+		if res.upserted != nil {
+			n++
+			upserted = append(upserted, bson.D{{Key: "index", Value: int32(i)}, {Key: "_id", Value: res.upserted}})
+		}
+	}
+
+	reply := bson.D{{Key: "n", Value: int32(n)}, {Key: "nModified", Value: int32(modified)}}
+	if len(upserted) > 0 {
+		reply = append(reply, bson.E{Key: "upserted", Value: upserted})
+	}
+	if len(errs) > 0 {
+		reply = append(reply, writeErrorsField(errs))
+	}
+	return reply, nil
+}
+
+// parseUpdateStatement parses a statement of an update command
+func parseUpdateStatement(stmt bson.D) (updateSpec, error) {
+	var spec updateSpec
+	for _, elem := range stmt {
+		switch elem.Key {
+		case "q":
+			filter, ok := elem.Value.(bson.D)
+			if !ok {
+				return spec, errorf(codeFailedToParse, "the update filter must be an object")
+			}
+			spec.filter = filter
+		case "u":
+			spec.update = elem.Value
+		case "upsert":
+			spec.upsert = isTruthy(elem.Value)
+		case "multi":
+			spec.multi = isTruthy(elem.Value)
+		case "arrayFilters":
+			filters, ok := elem.Value.(bson.A)
+			if !ok {
+				return spec, errorf(codeFailedToParse, "arrayFilters must be an array")
+			}
+			spec.arrayFilters = filters
+		}
+	}
+	switch update := spec.update.(type) {
+	case bson.D:
+	case bson.A:
+		if spec.arrayFilters != nil {
+			return spec, errorf(codeFailedToParse, "arrayFilters may not be specified for pipeline-style updates")
+		}
+	default:
+		return spec, errorf(codeFailedToParse, "the update must be an object or an array, not %s", typeName(update))
+	}
+	return spec, nil
+}
+
+func cmdDelete(s *Server, db string, cmd bson.D) (bson.D, error) {
+	coll, err := collectionArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	stmts, err := statements(cmd, "deletes")
+	if err != nil {
+		return nil, err
+	}
+
+	s.engine.mu.Lock()
+	defer s.engine.mu.Unlock()
+	n := 0
+	var errs []writeError
+	for i, stmt := range stmts {
+		filter, err := docArg(stmt, "q")
+		var limit int64
+		if err == nil {
+			limit, err = intArg(stmt, "limit")
+		}
+		var deleted int
+		if err == nil {
+			deleted, err = s.engine.deleteDocuments(db, coll, filter, int(limit))
+		}
+		n += deleted
+		if err != nil {
+			errs = append(errs, writeError{index: i, err: err})
+			if orderedArg(cmd) {
+				break
+			}
+		}
+	}
+	reply := bson.D{{Key: "n", Value: int32(n)}}
+	if len(errs) > 0 {
+		reply = append(reply, writeErrorsField(errs))
+	}
+	return reply, nil
+}
+
+func cmdFind(s *Server, db string, cmd bson.D) (bson.D, error) {
+	coll, err := collectionArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := docArg(cmd, "filter")
+	if err != nil {
+		return nil, err
+	}
+	sortSpec, err := docArg(cmd, "sort")
+	if err != nil {
+		return nil, err
+	}
+	projSpec, err := docArg(cmd, "projection")
+	if err != nil {
+		return nil, err
+	}
+	skip, err := intArg(cmd, "skip")
+	if err != nil {
+		return nil, err
+	}
+	limit, err := intArg(cmd, "limit")
+	if err != nil {
+		return nil, err
+	}
+	batchSize, err := intArg(cmd, "batchSize")
+	if err != nil {
+		return nil, err
+	}
+	singleBatch, _ := lookupField(cmd, "singleBatch")
+	if limit < 0 {
+		limit = -limit
+		singleBatch = true
+	}
+
+	proj, positional, err := parseFindProjection(projSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	// stored documents are never modified in place, so the results may be used
+	// after the lock is released
+	s.engine.mu.RLock()
+	results, err := s.engine.findDocuments(db, coll, filter)
+	s.engine.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sortSpec) > 0 {
+		sort.SliceStable(results, func(i, j int) bool {
+			return compareSortKeys(results[i].doc, results[j].doc, sortSpec) < 0
+		})
+	}
+	if skip > 0 {
+		if int(skip) >= len(results) {
+			results = nil
+		} else {
+			results = results[skip:]
+		}
+	}
+	if limit > 0 && int(limit) < len(results) {
+		results = results[:limit]
+	}
+
+	docs := make([]bson.D, len(results))
+	for i, res := range results {
+		docs[i] = res.doc
+		if proj == nil {
+			continue
+		}
+		if docs[i], err = proj.apply(res.doc, nil); err != nil {
+			return nil, errorf(codeBadValue, "%s", err.Error())
+		}
+		for _, path := range positional {
+			arr, isArr := lookupPath(res.doc, path).(bson.A)
+			if isArr && res.state.posSet && res.state.pos < len(arr) {
+				docs[i] = withPath(docs[i], strings.Split(path, "."), bson.A{arr[res.state.pos]})
+			}
+		}
+	}
+
+	if isTruthy(singleBatch) {
+		return s.cursors.single(db+"."+coll, docs)
+	}
+	return s.cursors.open(db+"."+coll, docs, int(batchSize))
+}
+
+// parseFindProjection parses the projection of a find. Fields ending in ".$" are
+// returned separately since they project the array element the filter matched.
+func parseFindProjection(spec bson.D) (*projection, []string, error) {
+	if len(spec) == 0 {
+		return nil, nil, nil
+	}
+	var positional []string
+	plain := make(bson.D, 0, len(spec))
+	for _, elem := range spec {
+		if strings.HasSuffix(elem.Key, ".$") {
+			path := strings.TrimSuffix(elem.Key, ".$")
+			positional = append(positional, path)
+			plain = append(plain, bson.E{Key: path, Value: int32(1)})
+			continue
+		}
+		plain = append(plain, elem)
+	}
+	proj, err := parseProjection(plain)
+	if err != nil {
+		return nil, nil, errorf(codeBadValue, "%s", err.Error())
+	}
+	return proj, positional, nil
+}
+
+func cmdAggregate(s *Server, db string, cmd bson.D) (bson.D, error) {
+	coll, err := collectionArg(cmd)
+	if err != nil {
+		return nil, errorf(codeInvalidNamespace, "aggregations must run against a collection")
+	}
+	stages, _ := lookupField(cmd, "pipeline")
+	stageArr, ok := stages.(bson.A)
+	if !ok {
+		return nil, errorf(codeFailedToParse, "'pipeline' must be an array")
+	}
+	cursorOpts, err := docArg(cmd, "cursor")
+	if err != nil {
+		return nil, err
+	}
+	batchSize, err := intArg(cursorOpts, "batchSize")
+	if err != nil {
+		return nil, err
+	}
+
+	s.engine.mu.RLock()
+	docs, err := s.engine.aggregate(db, coll, stageArr)
+	s.engine.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return s.cursors.open(db+"."+coll, docs, int(batchSize))
+}
+
+func cmdCount(s *Server, db string, cmd bson.D) (bson.D, error) {
+	coll, err := collectionArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := docArg(cmd, "query")
+	if err != nil {
+		return nil, err
+	}
+	skip, err := intArg(cmd, "skip")
+	if err != nil {
+		return nil, err
+	}
+	limit, err := intArg(cmd, "limit")
+	if err != nil {
+		return nil, err
+	}
+
+	s.engine.mu.RLock()
+	results, err := s.engine.findDocuments(db, coll, filter)
+	s.engine.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	n := int64(len(results)) - skip
+	if n < 0 {
+		n = 0
+	}
+	if limit < 0 {
+		limit = -limit
+	}
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	return bson.D{{Key: "n", Value: normalizeInt(n)}}, nil
+}
+
+func cmdDistinct(s *Server, db string, cmd bson.D) (bson.D, error) {
+	coll, err := collectionArg(cmd)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := lookupField(cmd, "key")
+	keyStr, isStr := key.(string)
+	if !ok || !isStr {
+		return nil, errorf(codeFailedToParse, "'key' must be a string")
+	}
+	filter, err := docArg(cmd, "query")
+	if err != nil {
+		return nil, err
+	}
+
+	s.engine.mu.RLock()
+	results, err := s.engine.findDocuments(db, coll, filter)
+	s.engine.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	values := bson.A{}
+	seen := make(map[string]struct{})
+	add := func(v interface{}) {
+		if _, isMissing := v.(missingValue); isMissing {
+			return
+		}
+		k := groupKey(v)
+		if _, dup := seen[k]; !dup {
+			seen[k] = struct{}{}
+			values = append(values, v)
+		}
+	}
+	path := strings.Split(keyStr, ".")
+	for _, res := range results {
+		v := resolveFieldPath(res.doc, path)
+		if arr, isArr := v.(bson.A); isArr {
+			for _, elem := range arr {
+				add(elem)
+			}
+			continue
+		}
+		add(v)
+	}
+	return bson.D{{Key: "values", Value: values}}, nil
+}
+
+func cmdGetMore(s *Server, db string, cmd bson.D) (bson.D, error) {
+	id, ok := toInt(cmd[0].Value)
+	if !ok {
+		return nil, errorf(codeFailedToParse, "the cursor id must be a number")
+	}
+	batchSize, err := intArg(cmd, "batchSize")
+	if err != nil {
+		return nil, err
+	}
+	return s.cursors.next(id, int(batchSize))
+}
+
+func cmdKillCursors(s *Server, db string, cmd bson.D) (bson.D, error) {
+	ids, _ := lookupField(cmd, "cursors")
+	idArr, ok := ids.(bson.A)
+	if !ok {
+		return nil, errorf(codeFailedToParse, "'cursors' must be an array")
+	}
+	killed, notFound := bson.A{}, bson.A{}
+	for _, v := range idArr {
+		id, _ := toInt(v)
+		if s.cursors.kill(id) {
+			killed = append(killed, id)
+		} else {
+			notFound = append(notFound, id)
+		}
+	}
+	return bson.D{
+		{Key: "cursorsKilled", Value: killed},
+		{Key: "cursorsNotFound", Value: notFound},
+		{Key: "cursorsAlive", Value: bson.A{}},
+		{Key: "cursorsUnknown", Value: bson.A{}},
+	}, nil
+}
+
+// cursor holds the documents of a result which were not returned yet
+type cursor struct {
+	ns       string
+	docs     []bson.D
+	lastUsed time.Time
+}
+
+// cursorStore holds the open cursors of a server
+type cursorStore struct {
+	mu      sync.Mutex
+	nextID  int64
+	cursors map[int64]*cursor
+}
+
+// newCursorStore creates an empty cursorStore
+func newCursorStore() *cursorStore {
+	return &cursorStore{cursors: make(map[int64]*cursor)}
+}
+
+// open returns the first batch of a result, keeping the rest in a new cursor
+func (cs *cursorStore) open(ns string, docs []bson.D, batchSize int) (bson.D, error) {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	batch, rest, err := takeBatch(docs, batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var id int64
+	if len(rest) > 0 {
+		cs.mu.Lock()
+		cs.expire()
+		cs.nextID++
+		id = cs.nextID
+		cs.cursors[id] = &cursor{ns: ns, docs: rest, lastUsed: time.Now()}
+		cs.mu.Unlock()
+	}
+	return cursorReply("firstBatch", ns, id, batch), nil
+}
+
+// single returns a result in a single batch without opening a cursor
+func (cs *cursorStore) single(ns string, docs []bson.D) (bson.D, error) {
+	batch, _, err := takeBatch(docs, len(docs))
+	if err != nil {
+		return nil, err
+	}
+	return cursorReply("firstBatch", ns, 0, batch), nil
+}
+
+// next returns the next batch of a cursor
+func (cs *cursorStore) next(id int64, batchSize int) (bson.D, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	c, ok := cs.cursors[id]
+	if !ok {
+		return nil, errorf(codeCursorNotFound, "cursor id %d not found", id)
+	}
+	if batchSize <= 0 {
+		batchSize = len(c.docs)
+	}
+	batch, rest, err := takeBatch(c.docs, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	c.docs = rest
+	c.lastUsed = time.Now()
+	if len(rest) == 0 {
+		delete(cs.cursors, id)
+		id = 0
+	}
+	return cursorReply("nextBatch", c.ns, id, batch), nil
+}
+
+// kill closes a cursor and reports whether it was open
+func (cs *cursorStore) kill(id int64) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	_, ok := cs.cursors[id]
+	delete(cs.cursors, id)
+	return ok
+}
+
+// expire closes the cursors which were not used for a while. The caller must hold mu.
+func (cs *cursorStore) expire() {
+	for id, c := range cs.cursors {
+		if time.Since(c.lastUsed) > cursorTimeout {
+			delete(cs.cursors, id)
+		}
+	}
+}
+
+// takeBatch encodes up to n documents, stopping early once the batch grows too large
+func takeBatch(docs []bson.D, n int) (bson.A, []bson.D, error) {
+	batch := bson.A{}
+	size := 0
+	i := 0
+	for ; i < len(docs) && i < n; i++ {
+		data, err := bson.Marshal(docs[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		if i > 0 && size+len(data) > maxBatchBytes {
+			break
+		}
+		size += len(data)
+		batch = append(batch, bson.Raw(data))
+	}
+	return batch, docs[i:], nil
+}
+
+// cursorReply builds the reply holding a batch of a cursor
+func cursorReply(field string, ns string, id int64, batch bson.A) bson.D {
+	return bson.D{{Key: "cursor", Value: bson.D{
+		{Key: field, Value: batch},
+		{Key: "id", Value: id},
+		{Key: "ns", Value: ns},
+	}}}
+}
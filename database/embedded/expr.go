@@ -0,0 +1,968 @@
+package embedded
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// scope holds the variables visible to an aggregation expression. CURRENT and ROOT
+// name the document the expression is evaluated against.
+type scope struct {
+	vars   map[string]interface{}
+	parent *scope
+}
+
+// newScope creates a scope which evaluates expressions against doc, inheriting
+// the variables of parent
+func newScope(doc bson.D, parent *scope) *scope {
+	return &scope{
+		vars:   map[string]interface{}{"ROOT": doc, "CURRENT": doc},
+		parent: parent,
+	}
+}
+
+// with creates a child scope holding additional variables
+func (s *scope) with(vars map[string]interface{}) *scope {
+	return &scope{vars: vars, parent: s}
+}
+
+// lookup returns the value of a variable
+func (s *scope) lookup(name string) (interface{}, bool) {
+	for current := s; current != nil; current = current.parent {
+		if v, ok := current.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// evalExpr evaluates an aggregation expression
+func evalExpr(expr interface{}, s *scope) (interface{}, error) {
+	switch val := expr.(type) {
+	case string:
+		if strings.HasPrefix(val, "$$") {
+			return evalVariable(val[2:], s)
+		}
+		if strings.HasPrefix(val, "$") {
+			current, _ := s.lookup("CURRENT")
+			return resolveFieldPath(current, strings.Split(val[1:], ".")), nil
+		}
+		return val, nil
+	case bson.D:
+		if len(val) == 1 && strings.HasPrefix(val[0].Key, "$") {
+			return evalOperator(val[0].Key, val[0].Value, s)
+		}
+		out := make(bson.D, 0, len(val))
+		for _, elem := range val {
+			if strings.HasPrefix(elem.Key, "$") {
+				return nil, fmt.Errorf("unrecognized expression '%s'", elem.Key)
+			}
+			v, err := evalExpr(elem.Value, s)
+			if err != nil {
+				return nil, err
+			}
+			if _, isMissing := v.(missingValue); !isMissing {
+				out = append(out, bson.E{Key: elem.Key, Value: v})
+			}
+		}
+		return out, nil
+	case bson.A:
+		out := make(bson.A, len(val))
+		for i, elem := range val {
+			v, err := evalExpr(elem, s)
+			if err != nil {
+				return nil, err
+			}
+			if _, isMissing := v.(missingValue); isMissing {
+				v = nil
+			}
+			out[i] = v
+		}
+		return out, nil
+	}
+	return expr, nil
+}
+
+// evalVariable resolves a variable reference such as $$ROOT or $$this.field
+func evalVariable(ref string, s *scope) (interface{}, error) {
+	parts := strings.Split(ref, ".")
+	if parts[0] == "REMOVE" {
+		return missing, nil
+	}
+	v, ok := s.lookup(parts[0])
+	if !ok {
+		return nil, fmt.Errorf("use of undefined variable: %s", parts[0])
+	}
+	if len(parts) == 1 {
+		return v, nil
+	}
+	return resolveFieldPath(v, parts[1:]), nil
+}
+
+// resolveFieldPath follows a field path the way aggregation expressions do. A path
+// through an array of documents yields the array of the values found in its elements.
+func resolveFieldPath(current interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return current
+	}
+	switch val := current.(type) {
+	case bson.D:
+		next, _ := lookupField(val, path[0])
+		return resolveFieldPath(next, path[1:])
+	case bson.A:
+		out := bson.A{}
+		for _, elem := range val {
+			switch elem.(type) {
+			case bson.D, bson.A:
+				v := resolveFieldPath(elem, path)
+				if _, isMissing := v.(missingValue); !isMissing {
+					out = append(out, v)
+				}
+			}
+		}
+		return out
+	}
+	return missing
+}
+
+// evalArgs evaluates the arguments of an operator, which may be given as an array
+// or as a single expression
+func evalArgs(arg interface{}, s *scope) ([]interface{}, error) {
+	list, ok := arg.(bson.A)
+	if !ok {
+		list = bson.A{arg}
+	}
+	out := make([]interface{}, len(list))
+	for i, elem := range list {
+		v, err := evalExpr(elem, s)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// evalArgsN evaluates exactly n operator arguments
+func evalArgsN(op string, arg interface{}, n int, s *scope) ([]interface{}, error) {
+	args, err := evalArgs(arg, s)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != n {
+		return nil, fmt.Errorf("expression %s takes exactly %d arguments. %d were passed in", op, n, len(args))
+	}
+	return args, nil
+}
+
+// namedArgs reads the fields of an operator which takes a document of named arguments
+func namedArgs(op string, arg interface{}) (map[string]interface{}, error) {
+	doc, ok := arg.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("%s only supports an object as its argument", op)
+	}
+	out := make(map[string]interface{}, len(doc))
+	for _, elem := range doc {
+		out[elem.Key] = elem.Value
+	}
+	return out, nil
+}
+
+// evalOperator evaluates an expression operator
+func evalOperator(op string, arg interface{}, s *scope) (interface{}, error) {
+	switch op {
+	case "$literal":
+		return arg, nil
+	case "$sum", "$avg", "$max", "$min":
+		return evalArrayAccumulator(op, arg, s)
+	case "$add", "$multiply":
+		args, err := evalArgs(arg, s)
+		if err != nil {
+			return nil, err
+		}
+		return arithmetic(op, args)
+	case "$subtract", "$divide", "$mod":
+		args, err := evalArgsN(op, arg, 2, s)
+		if err != nil {
+			return nil, err
+		}
+		return arithmetic(op, args)
+	case "$ceil", "$floor", "$abs", "$sqrt", "$log10", "$ln", "$exp":
+		args, err := evalArgsN(op, arg, 1, s)
+		if err != nil {
+			return nil, err
+		}
+		return unaryMath(op, args[0])
+	case "$round", "$trunc":
+		args, err := evalArgs(arg, s)
+		if err != nil {
+			return nil, err
+		}
+		return roundNumber(op, args)
+	case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$cmp":
+		args, err := evalArgsN(op, arg, 2, s)
+		if err != nil {
+			return nil, err
+		}
+		c := compareExprValues(args[0], args[1])
+		switch op {
+		case "$eq":
+			return c == 0, nil
+		case "$ne":
+			return c != 0, nil
+		case "$gt":
+			return c > 0, nil
+		case "$gte":
+			return c >= 0, nil
+		case "$lt":
+			return c < 0, nil
+		case "$lte":
+			return c <= 0, nil
+		}
+		return int32(c), nil
+	case "$and", "$or":
+		args, err := evalArgs(arg, s)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range args {
+			if op == "$and" && !isTruthy(v) {
+				return false, nil
+			}
+			if op == "$or" && isTruthy(v) {
+				return true, nil
+			}
+		}
+		return op == "$and", nil
+	case "$not":
+		args, err := evalArgsN(op, arg, 1, s)
+		if err != nil {
+			return nil, err
+		}
+		return !isTruthy(args[0]), nil
+	case "$cond":
+		return evalCond(arg, s)
+	case "$ifNull":
+		args, err := evalArgs(arg, s)
+		if err != nil {
+			return nil, err
+		}
+		if len(args) < 2 {
+			return nil, errors.New("$ifNull needs at least two arguments")
+		}
+		for _, v := range args[:len(args)-1] {
+			if !isNullish(v) {
+				return v, nil
+			}
+		}
+		return args[len(args)-1], nil
+	case "$size":
+		args, err := evalArgsN(op, arg, 1, s)
+		if err != nil {
+			return nil, err
+		}
+		arr, ok := args[0].(bson.A)
+		if !ok {
+			return nil, fmt.Errorf("the argument to $size must be an array, but was of type: %s", typeName(args[0]))
+		}
+		return int32(len(arr)), nil
+	case "$concatArrays":
+		args, err := evalArgs(arg, s)
+		if err != nil {
+			return nil, err
+		}
+		out := bson.A{}
+		for _, v := range args {
+			if isNullish(v) {
+				return nil, nil
+			}
+			arr, ok := v.(bson.A)
+			if !ok {
+				return nil, fmt.Errorf("$concatArrays only supports arrays, not %s", typeName(v))
+			}
+			out = append(out, arr...)
+		}
+		return out, nil
+	case "$setUnion":
+		args, err := evalArgs(arg, s)
+		if err != nil {
+			return nil, err
+		}
+		out := bson.A{}
+		seen := make(map[string]struct{})
+		for _, v := range args {
+			if isNullish(v) {
+				return nil, nil
+			}
+			arr, ok := v.(bson.A)
+			if !ok {
+				return nil, fmt.Errorf("all operands of $setUnion must be arrays. One argument is of type: %s", typeName(v))
+			}
+			for _, elem := range arr {
+				key := groupKey(elem)
+				if _, dup := seen[key]; !dup {
+					seen[key] = struct{}{}
+					out = append(out, elem)
+				}
+			}
+		}
+		return out, nil
+	case "$in":
+		args, err := evalArgsN(op, arg, 2, s)
+		if err != nil {
+			return nil, err
+		}
+		arr, ok := args[1].(bson.A)
+		if !ok {
+			return nil, fmt.Errorf("$in requires an array as a second argument, found: %s", typeName(args[1]))
+		}
+		for _, elem := range arr {
+			if valuesEqual(elem, args[0]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$arrayElemAt":
+		args, err := evalArgsN(op, arg, 2, s)
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(args[0]) || isNullish(args[1]) {
+			return nil, nil
+		}
+		arr, ok := args[0].(bson.A)
+		idx, isInt := toInt(args[1])
+		if !ok || !isInt {
+			return nil, errors.New("$arrayElemAt needs an array and an integral index")
+		}
+		if idx < 0 {
+			idx += int64(len(arr))
+		}
+		if idx < 0 || idx >= int64(len(arr)) {
+			return missing, nil
+		}
+		return arr[idx], nil
+	case "$first", "$last":
+		args, err := evalArgsN(op, arg, 1, s)
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(args[0]) {
+			return nil, nil
+		}
+		arr, ok := args[0].(bson.A)
+		if !ok {
+			return nil, fmt.Errorf("%s's argument must be an array, but is %s", op, typeName(args[0]))
+		}
+		if len(arr) == 0 {
+			return missing, nil
+		}
+		if op == "$first" {
+			return arr[0], nil
+		}
+		return arr[len(arr)-1], nil
+	case "$slice":
+		return evalSlice(arg, s)
+	case "$map":
+		return evalMap(arg, s)
+	case "$filter":
+		return evalFilter(arg, s)
+	case "$reduce":
+		return evalReduce(arg, s)
+	case "$objectToArray":
+		args, err := evalArgsN(op, arg, 1, s)
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(args[0]) {
+			return nil, nil
+		}
+		doc, ok := args[0].(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("$objectToArray requires a document input, found: %s", typeName(args[0]))
+		}
+		out := make(bson.A, len(doc))
+		for i, elem := range doc {
+			out[i] = bson.D{{Key: "k", Value: elem.Key}, {Key: "v", Value: elem.Value}}
+		}
+		return out, nil
+	case "$arrayToObject":
+		args, err := evalArgsN(op, arg, 1, s)
+		if err != nil {
+			return nil, err
+		}
+		return arrayToObject(args[0])
+	case "$mergeObjects":
+		args, err := evalArgs(arg, s)
+		if err != nil {
+			return nil, err
+		}
+		out := bson.D{}
+		for _, v := range args {
+			if isNullish(v) {
+				continue
+			}
+			doc, ok := v.(bson.D)
+			if !ok {
+				return nil, fmt.Errorf("$mergeObjects requires object inputs, but input is of type %s", typeName(v))
+			}
+			for _, elem := range doc {
+				out = setField(out, elem.Key, elem.Value)
+			}
+		}
+		return out, nil
+	case "$concat":
+		args, err := evalArgs(arg, s)
+		if err != nil {
+			return nil, err
+		}
+		var sb strings.Builder
+		for _, v := range args {
+			if isNullish(v) {
+				return nil, nil
+			}
+			str, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("$concat only supports strings, not %s", typeName(v))
+			}
+			sb.WriteString(str)
+		}
+		return sb.String(), nil
+	case "$toLower", "$toUpper":
+		args, err := evalArgsN(op, arg, 1, s)
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(args[0]) {
+			return "", nil
+		}
+		str, _ := args[0].(string)
+		if op == "$toLower" {
+			return strings.ToLower(str), nil
+		}
+		return strings.ToUpper(str), nil
+	case "$type":
+		args, err := evalArgsN(op, arg, 1, s)
+		if err != nil {
+			return nil, err
+		}
+		return typeName(args[0]), nil
+	case "$isArray":
+		args, err := evalArgsN(op, arg, 1, s)
+		if err != nil {
+			return nil, err
+		}
+		_, ok := args[0].(bson.A)
+		return ok, nil
+	}
+	return nil, fmt.Errorf("unsupported expression operator %s", op)
+}
+
+// compareExprValues orders two values as aggregation comparisons do, which place
+// missing values before null
+func compareExprValues(a, b interface{}) int {
+	_, aMissing := a.(missingValue)
+	_, bMissing := b.(missingValue)
+	switch {
+	case aMissing && bMissing:
+		return 0
+	case aMissing:
+		return -1
+	case bMissing:
+		return 1
+	}
+	return compareValues(a, b)
+}
+
+// typeName names the BSON type of a value as $type does
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case missingValue:
+		return "missing"
+	case nil, primitive.Null:
+		return "null"
+	case float64:
+		return "double"
+	case string:
+		return "string"
+	case bson.D:
+		return "object"
+	case bson.A:
+		return "array"
+	case primitive.Binary:
+		return "binData"
+	case primitive.ObjectID:
+		return "objectId"
+	case bool:
+		return "bool"
+	case primitive.DateTime:
+		return "date"
+	case primitive.Regex:
+		return "regex"
+	case int32:
+		return "int"
+	case int64, int:
+		return "long"
+	case primitive.Timestamp:
+		return "timestamp"
+	case primitive.Decimal128:
+		return "decimal"
+	}
+	return "unknown"
+}
+
+// evalCond evaluates $cond in either its array or its document form
+func evalCond(arg interface{}, s *scope) (interface{}, error) {
+	var ifExpr, thenExpr, elseExpr interface{}
+	switch val := arg.(type) {
+	case bson.A:
+		if len(val) != 3 {
+			return nil, errors.New("expression $cond takes exactly 3 arguments")
+		}
+		ifExpr, thenExpr, elseExpr = val[0], val[1], val[2]
+	case bson.D:
+		args, err := namedArgs("$cond", val)
+		if err != nil {
+			return nil, err
+		}
+		ifExpr, thenExpr, elseExpr = args["if"], args["then"], args["else"]
+	default:
+		return nil, errors.New("$cond needs an array or an object")
+	}
+
+	cond, err := evalExpr(ifExpr, s)
+	if err != nil {
+		return nil, err
+	}
+	if isTruthy(cond) {
+		return evalExpr(thenExpr, s)
+	}
+	return evalExpr(elseExpr, s)
+}
+
+// evalArrayAccumulator evaluates $sum, $avg, $max and $min used as expressions. A
+// single array argument is aggregated element by element.
+func evalArrayAccumulator(op string, arg interface{}, s *scope) (interface{}, error) {
+	var values []interface{}
+	if _, isList := arg.(bson.A); isList {
+		args, err := evalArgs(arg, s)
+		if err != nil {
+			return nil, err
+		}
+		values = args
+	} else {
+		v, err := evalExpr(arg, s)
+		if err != nil {
+			return nil, err
+		}
+		if arr, ok := v.(bson.A); ok {
+			values = arr
+		} else {
+			values = []interface{}{v}
+		}
+	}
+
+	acc := newAccumulator(op)
+	for _, v := range values {
+		if err := acc.add(v); err != nil {
+			return nil, err
+		}
+	}
+	return acc.result(), nil
+}
+
+// arithmetic evaluates the arithmetic operators. Integers stay integers unless a
+// result overflows, and any double argument makes the result a double.
+func arithmetic(op string, args []interface{}) (interface{}, error) {
+	dateArgs := make([]bool, len(args))
+	for i, v := range args {
+		if isNullish(v) {
+			return nil, nil
+		}
+		if d, isDate := v.(primitive.DateTime); isDate && (op == "$add" || op == "$subtract") {
+			args[i] = int64(d)
+			dateArgs[i] = true
+			continue
+		}
+		if !isNumber(v) {
+			return nil, fmt.Errorf("%s only supports numeric types, not %s", op, typeName(v))
+		}
+	}
+
+	// adding to a date or subtracting a number from a date gives a date
+	dateResult := false
+	for _, isDate := range dateArgs {
+		dateResult = dateResult || (isDate && op == "$add")
+	}
+	if op == "$subtract" {
+		dateResult = dateArgs[0] && !dateArgs[1]
+	}
+
+	var result interface{}
+	switch op {
+	case "$add", "$multiply":
+		if op == "$add" {
+			result = int32(0)
+		} else {
+			result = int32(1)
+		}
+		for _, v := range args {
+			result = combineNumbers(op, result, v)
+		}
+	case "$subtract":
+		result = combineNumbers(op, args[0], args[1])
+	case "$divide":
+		divisor, _ := toFloat(args[1])
+		if divisor == 0 {
+			return nil, errors.New("can't $divide by zero")
+		}
+		dividend, _ := toFloat(args[0])
+		return dividend / divisor, nil
+	case "$mod":
+		if isInteger(args[0]) && isInteger(args[1]) {
+			a, _ := toInt(args[0])
+			b, _ := toInt(args[1])
+			if b == 0 {
+				return nil, errors.New("can't $mod by zero")
+			}
+			return widestInt(args[0], args[1], a%b), nil
+		}
+		a, _ := toFloat(args[0])
+		b, _ := toFloat(args[1])
+		if b == 0 {
+			return nil, errors.New("can't $mod by zero")
+		}
+		return math.Mod(a, b), nil
+	}
+
+	if dateResult {
+		ms, _ := toFloat(result)
+		return primitive.DateTime(math.Round(ms)), nil
+	}
+	return result, nil
+}
+
+// combineNumbers adds, subtracts or multiplies two numbers, promoting the result type
+// the way MongoDB does
+func combineNumbers(op string, a, b interface{}) interface{} {
+	if isInteger(a) && isInteger(b) {
+		x, _ := toInt(a)
+		y, _ := toInt(b)
+		var r int64
+		overflow := false
+		switch op {
+		case "$add":
+			r = x + y
+			overflow = (y > 0 && r < x) || (y < 0 && r > x)
+		case "$subtract":
+			r = x - y
+			overflow = (y < 0 && r < x) || (y > 0 && r > x)
+		case "$multiply":
+			r = x * y
+			overflow = x != 0 && (r/x != y || (x == -1 && y == math.MinInt64))
+		}
+		if overflow {
+			fx, fy := float64(x), float64(y)
+			switch op {
+			case "$add":
+				return fx + fy
+			case "$subtract":
+				return fx - fy
+			}
+			return fx * fy
+		}
+		return widestInt(a, b, r)
+	}
+
+	x, _ := toFloat(a)
+	y, _ := toFloat(b)
+	switch op {
+	case "$add":
+		return x + y
+	case "$subtract":
+		return x - y
+	}
+	return x * y
+}
+
+// widestInt stores an integer result as an int64 if either argument was an int64 or
+// the result does not fit into an int32
+func widestInt(a, b interface{}, r int64) interface{} {
+	_, aLong := a.(int64)
+	_, bLong := b.(int64)
+	if aLong || bLong {
+		return r
+	}
+	return normalizeInt(r)
+}
+
+// unaryMath evaluates the single argument math operators
+func unaryMath(op string, v interface{}) (interface{}, error) {
+	if isNullish(v) {
+		return nil, nil
+	}
+	if !isNumber(v) {
+		return nil, fmt.Errorf("%s only supports numeric types, not %s", op, typeName(v))
+	}
+	if isInteger(v) && (op == "$ceil" || op == "$floor" || op == "$abs") {
+		if op == "$abs" {
+			n, _ := toInt(v)
+			if n < 0 {
+				return widestInt(v, v, -n), nil
+			}
+		}
+		return v, nil
+	}
+	f, _ := toFloat(v)
+	switch op {
+	case "$ceil":
+		return math.Ceil(f), nil
+	case "$floor":
+		return math.Floor(f), nil
+	case "$abs":
+		return math.Abs(f), nil
+	case "$exp":
+		return math.Exp(f), nil
+	case "$log10", "$ln":
+		if f <= 0 {
+			return nil, fmt.Errorf("%s's argument must be a positive number, but is %v", op, f)
+		}
+		if op == "$ln" {
+			return math.Log(f), nil
+		}
+		return math.Log10(f), nil
+	}
+	if f < 0 {
+		return nil, fmt.Errorf("$sqrt's argument must be greater than or equal to 0")
+	}
+	return math.Sqrt(f), nil
+}
+
+// roundNumber evaluates $round and $trunc, which take an optional number of decimal places
+func roundNumber(op string, args []interface{}) (interface{}, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("%s takes one or two arguments", op)
+	}
+	if isNullish(args[0]) {
+		return nil, nil
+	}
+	if !isNumber(args[0]) {
+		return nil, fmt.Errorf("%s only supports numeric types, not %s", op, typeName(args[0]))
+	}
+	var place int64
+	if len(args) == 2 {
+		var ok bool
+		if place, ok = toInt(args[1]); !ok {
+			return nil, fmt.Errorf("%s needs an integral number of places", op)
+		}
+	}
+	if isInteger(args[0]) && place >= 0 {
+		return args[0], nil
+	}
+
+	f, _ := toFloat(args[0])
+	scale := math.Pow(10, float64(place))
+	if op == "$trunc" {
+		return math.Trunc(f*scale) / scale, nil
+	}
+	// $round rounds half to even
+	return math.RoundToEven(f*scale) / scale, nil
+}
+
+// evalSlice evaluates $slice with either a count or a position and a count
+func evalSlice(arg interface{}, s *scope) (interface{}, error) {
+	args, err := evalArgs(arg, s)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 2 && len(args) != 3 {
+		return nil, errors.New("expression $slice takes at least 2 arguments, and at most 3")
+	}
+	if isNullish(args[0]) {
+		return nil, nil
+	}
+	arr, ok := args[0].(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("first argument to $slice must be an array, but is of type: %s", typeName(args[0]))
+	}
+
+	n, ok := toInt(args[len(args)-1])
+	if !ok {
+		return nil, errors.New("$slice needs integral arguments")
+	}
+	if len(args) == 2 {
+		if n < 0 {
+			start := int64(len(arr)) + n
+			if start < 0 {
+				start = 0
+			}
+			return append(bson.A{}, arr[start:]...), nil
+		}
+		if n > int64(len(arr)) {
+			n = int64(len(arr))
+		}
+		return append(bson.A{}, arr[:n]...), nil
+	}
+
+	pos, ok := toInt(args[1])
+	if !ok || n < 0 {
+		return nil, errors.New("$slice needs a position and a positive count")
+	}
+	if pos < 0 {
+		pos += int64(len(arr))
+		if pos < 0 {
+			pos = 0
+		}
+	}
+	if pos > int64(len(arr)) {
+		pos = int64(len(arr))
+	}
+	end := pos + n
+	if end > int64(len(arr)) {
+		end = int64(len(arr))
+	}
+	return append(bson.A{}, arr[pos:end]...), nil
+}
+
+// evalMap evaluates $map, which applies an expression to every element of an array
+func evalMap(arg interface{}, s *scope) (interface{}, error) {
+	args, err := namedArgs("$map", arg)
+	if err != nil {
+		return nil, err
+	}
+	input, err := evalExpr(args["input"], s)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(input) {
+		return nil, nil
+	}
+	arr, ok := input.(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("input to $map must be an array not %s", typeName(input))
+	}
+	name := "this"
+	if as, hasAs := args["as"].(string); hasAs {
+		name = as
+	}
+
+	out := make(bson.A, len(arr))
+	for i, elem := range arr {
+		v, err := evalExpr(args["in"], s.with(map[string]interface{}{name: elem}))
+		if err != nil {
+			return nil, err
+		}
+		if _, isMissing := v.(missingValue); isMissing {
+			v = nil
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// evalFilter evaluates $filter, which keeps the elements of an array a condition holds for
+func evalFilter(arg interface{}, s *scope) (interface{}, error) {
+	args, err := namedArgs("$filter", arg)
+	if err != nil {
+		return nil, err
+	}
+	input, err := evalExpr(args["input"], s)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(input) {
+		return nil, nil
+	}
+	arr, ok := input.(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("input to $filter must be an array not %s", typeName(input))
+	}
+	name := "this"
+	if as, hasAs := args["as"].(string); hasAs {
+		name = as
+	}
+
+	out := bson.A{}
+	for _, elem := range arr {
+		v, err := evalExpr(args["cond"], s.with(map[string]interface{}{name: elem}))
+		if err != nil {
+			return nil, err
+		}
+		if isTruthy(v) {
+			out = append(out, elem)
+		}
+	}
+	return out, nil
+}
+
+// evalReduce evaluates $reduce, which folds the elements of an array into a single value
+func evalReduce(arg interface{}, s *scope) (interface{}, error) {
+	args, err := namedArgs("$reduce", arg)
+	if err != nil {
+		return nil, err
+	}
+	input, err := evalExpr(args["input"], s)
+	if err != nil {
+		return nil, err
+	}
+	if isNullish(input) {
+		return nil, nil
+	}
+	arr, ok := input.(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("$reduce requires that 'input' be an array, found: %s", typeName(input))
+	}
+
+	value, err := evalExpr(args["initialValue"], s)
+	if err != nil {
+		return nil, err
+	}
+	for _, elem := range arr {
+		value, err = evalExpr(args["in"], s.with(map[string]interface{}{"this": elem, "value": value}))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// arrayToObject converts an array of key value pairs into a document
+func arrayToObject(v interface{}) (interface{}, error) {
+	if isNullish(v) {
+		return nil, nil
+	}
+	arr, ok := v.(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("$arrayToObject requires an array input, found: %s", typeName(v))
+	}
+	out := bson.D{}
+	for _, elem := range arr {
+		switch pair := elem.(type) {
+		case bson.D:
+			k, _ := lookupField(pair, "k")
+			key, isStr := k.(string)
+			if !isStr {
+				return nil, errors.New("$arrayToObject requires documents with 'k' and 'v' fields")
+			}
+			value, _ := lookupField(pair, "v")
+			out = setField(out, key, value)
+		case bson.A:
+			if len(pair) != 2 {
+				return nil, errors.New("$arrayToObject requires arrays of size 2")
+			}
+			key, isStr := pair[0].(string)
+			if !isStr {
+				return nil, errors.New("$arrayToObject requires string keys")
+			}
+			out = setField(out, key, pair[1])
+		default:
+			return nil, errors.New("$arrayToObject requires an array of key value pairs")
+		}
+	}
+	return out, nil
+}
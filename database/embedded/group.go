@@ -0,0 +1,252 @@
+package embedded
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// accumulator combines the values of the documents in a $group
+type accumulator interface {
+	add(v interface{}) error
+	result() interface{}
+}
+
+// newAccumulator creates the accumulator for an operator, or nil if the operator
+// is not an accumulator
+func newAccumulator(op string) accumulator {
+	switch op {
+	case "$sum":
+		return &sumAccumulator{sum: int32(0)}
+	case "$avg":
+		return &avgAccumulator{}
+	case "$max":
+		return &extremeAccumulator{sign: 1}
+	case "$min":
+		return &extremeAccumulator{sign: -1}
+	case "$first":
+		return &firstAccumulator{}
+	case "$last":
+		return &lastAccumulator{}
+	case "$push":
+		return &pushAccumulator{values: bson.A{}}
+	case "$addToSet":
+		return &setAccumulator{values: bson.A{}, seen: make(map[string]struct{})}
+	case "$mergeObjects":
+		return &mergeAccumulator{doc: bson.D{}}
+	}
+	return nil
+}
+
+// sumAccumulator adds up numbers, ignoring other values
+type sumAccumulator struct {
+	sum interface{}
+}
+
+func (a *sumAccumulator) add(v interface{}) error {
+	if isNumber(v) {
+		a.sum = combineNumbers("$add", a.sum, v)
+	}
+	return nil
+}
+
+func (a *sumAccumulator) result() interface{} {
+	return a.sum
+}
+
+// avgAccumulator averages numbers, ignoring other values
+type avgAccumulator struct {
+	total float64
+	count int
+}
+
+func (a *avgAccumulator) add(v interface{}) error {
+	if f, ok := toFloat(v); ok && isNumber(v) {
+		a.total += f
+		a.count++
+	}
+	return nil
+}
+
+func (a *avgAccumulator) result() interface{} {
+	if a.count == 0 {
+		return nil
+	}
+	return a.total / float64(a.count)
+}
+
+// extremeAccumulator keeps the largest or smallest value, ignoring null values
+type extremeAccumulator struct {
+	sign  int
+	value interface{}
+	set   bool
+}
+
+func (a *extremeAccumulator) add(v interface{}) error {
+	if isNullish(v) {
+		return nil
+	}
+	if !a.set || compareValues(v, a.value)*a.sign > 0 {
+		a.value = v
+		a.set = true
+	}
+	return nil
+}
+
+func (a *extremeAccumulator) result() interface{} {
+	if !a.set {
+		return nil
+	}
+	return a.value
+}
+
+// firstAccumulator keeps the value of the first document
+type firstAccumulator struct {
+	value interface{}
+	set   bool
+}
+
+func (a *firstAccumulator) add(v interface{}) error {
+	if !a.set {
+		a.value = v
+		a.set = true
+	}
+	return nil
+}
+
+func (a *firstAccumulator) result() interface{} {
+	if _, isMissing := a.value.(missingValue); isMissing {
+		return nil
+	}
+	return a.value
+}
+
+// lastAccumulator keeps the value of the last document
+type lastAccumulator struct {
+	value interface{}
+}
+
+func (a *lastAccumulator) add(v interface{}) error {
+	a.value = v
+	return nil
+}
+
+func (a *lastAccumulator) result() interface{} {
+	if _, isMissing := a.value.(missingValue); isMissing {
+		return nil
+	}
+	return a.value
+}
+
+// pushAccumulator collects every value
+type pushAccumulator struct {
+	values bson.A
+}
+
+func (a *pushAccumulator) add(v interface{}) error {
+	if _, isMissing := v.(missingValue); !isMissing {
+		a.values = append(a.values, v)
+	}
+	return nil
+}
+
+func (a *pushAccumulator) result() interface{} {
+	return a.values
+}
+
+// setAccumulator collects the distinct values
+type setAccumulator struct {
+	values bson.A
+	seen   map[string]struct{}
+}
+
+func (a *setAccumulator) add(v interface{}) error {
+	if _, isMissing := v.(missingValue); isMissing {
+		return nil
+	}
+	key := groupKey(v)
+	if _, dup := a.seen[key]; !dup {
+		a.seen[key] = struct{}{}
+		a.values = append(a.values, v)
+	}
+	return nil
+}
+
+func (a *setAccumulator) result() interface{} {
+	return a.values
+}
+
+// mergeAccumulator combines documents into one
+type mergeAccumulator struct {
+	doc bson.D
+}
+
+func (a *mergeAccumulator) add(v interface{}) error {
+	if isNullish(v) {
+		return nil
+	}
+	doc, ok := v.(bson.D)
+	if !ok {
+		return fmt.Errorf("$mergeObjects requires object inputs, but input is of type %s", typeName(v))
+	}
+	for _, elem := range doc {
+		a.doc = setField(a.doc, elem.Key, elem.Value)
+	}
+	return nil
+}
+
+func (a *mergeAccumulator) result() interface{} {
+	return a.doc
+}
+
+// groupKey encodes a value so that values MongoDB considers equal, such as numbers
+// of different types holding the same value, have the same key
+func groupKey(v interface{}) string {
+	var sb strings.Builder
+	writeGroupKey(&sb, v)
+	return sb.String()
+}
+
+// writeGroupKey appends the key of a value to sb
+func writeGroupKey(sb *strings.Builder, v interface{}) {
+	sb.WriteByte(byte(typeOrder(v)))
+	switch val := v.(type) {
+	case nil, missingValue:
+	case int32, int64, int, float64:
+		if n, ok := toInt(val); ok && (isInteger(val) || math.Abs(float64(n)) < 1<<53) {
+			sb.WriteString(strconv.FormatInt(n, 10))
+		} else {
+			f, _ := toFloat(val)
+			sb.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	case string:
+		writeLength(sb, len(val))
+		sb.WriteString(val)
+	case bson.D:
+		writeLength(sb, len(val))
+		for _, elem := range val {
+			writeLength(sb, len(elem.Key))
+			sb.WriteString(elem.Key)
+			writeGroupKey(sb, elem.Value)
+		}
+	case bson.A:
+		writeLength(sb, len(val))
+		for _, elem := range val {
+			writeGroupKey(sb, elem)
+		}
+	default:
+		data := encodeValue(val)
+		writeLength(sb, len(data))
+		sb.Write(data)
+	}
+}
+
+// writeLength appends a length prefix to sb
+func writeLength(sb *strings.Builder, n int) {
+	var buf [binary.MaxVarintLen64]byte
+	sb.Write(buf[:binary.PutUvarint(buf[:], uint64(n))])
+}
@@ -0,0 +1,257 @@
+package embedded
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// journalPrefix is the start of the names of journal files
+const journalPrefix = "journal-"
+
+// journal operations
+const (
+	journalCreate      = "create"
+	journalDrop        = "drop"
+	journalPut         = "put"
+	journalDelete      = "delete"
+	journalCreateIndex = "createIndex"
+	journalDropIndex   = "dropIndex"
+)
+
+// journal appends every change made to the databases to a file so that changes
+// survive the process exiting without the server being closed. A flush starts a
+// new journal file and removes the older ones once the collections are written.
+// Entries are recorded while the engine is locked and handed to the operating
+// system after each command.
+type journal struct {
+	mu   sync.Mutex
+	dir  string
+	seq  int
+	file *os.File
+	w    *bufio.Writer
+	// size is the number of bytes written to the current file
+	size int64
+	// err is the first error since the last commit
+	err error
+}
+
+// journalPath returns the path of a journal file
+func journalPath(dir string, seq int) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%d%s", journalPrefix, seq, collectionExt))
+}
+
+// journalFiles returns the sequence numbers of the journal files in dir in order
+func journalFiles(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []int
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, journalPrefix) || !strings.HasSuffix(name, collectionExt) {
+			continue
+		}
+		var seq int
+		if _, err := fmt.Sscanf(strings.TrimSuffix(name, collectionExt), journalPrefix+"%d", &seq); err == nil {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Ints(seqs)
+	return seqs, nil
+}
+
+// openJournal starts a new journal file
+func openJournal(dir string, seq int) (*journal, error) {
+	j := &journal{dir: dir}
+	if err := j.open(seq); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *journal) open(seq int) error {
+	f, err := os.OpenFile(journalPath(j.dir, seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	j.seq = seq
+	j.file = f
+	j.w = bufio.NewWriter(f)
+	j.size = 0
+	return nil
+}
+
+// record appends an entry to the journal. Errors are reported by the next commit.
+// Recording to a nil journal does nothing.
+func (j *journal) record(entry bson.D) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.err != nil {
+		return
+	}
+	data, err := bson.Marshal(entry)
+	if err == nil {
+		_, err = j.w.Write(data)
+	}
+	if err != nil {
+		j.err = fmt.Errorf("could not write to the journal: %v", err)
+		return
+	}
+	j.size += int64(len(data))
+}
+
+// commit hands the recorded entries to the operating system
+func (j *journal) commit() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	err := j.err
+	j.err = nil
+	if flushErr := j.w.Flush(); flushErr != nil && err == nil {
+		err = fmt.Errorf("could not write to the journal: %v", flushErr)
+	}
+	return err
+}
+
+// written returns the number of bytes written to the current journal file
+func (j *journal) written() int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.size
+}
+
+// rotate starts a new journal file and returns the sequence number of the new
+// file. The files before it may be removed once the collections are written.
+func (j *journal) rotate() (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	err := j.w.Flush()
+	if closeErr := j.file.Close(); err == nil {
+		err = closeErr
+	}
+	if openErr := j.open(j.seq + 1); openErr != nil {
+		return 0, openErr
+	}
+	return j.seq, err
+}
+
+// close writes the recorded entries and closes the journal file
+func (j *journal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	err := j.w.Flush()
+	if closeErr := j.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// removeJournals removes the journal files before seq
+func removeJournals(dir string, seq int) error {
+	seqs, err := journalFiles(dir)
+	if err != nil {
+		return err
+	}
+	for _, old := range seqs {
+		if old >= seq {
+			break
+		}
+		if err := os.Remove(journalPath(dir, old)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayJournal applies the entries of a journal file to an engine. A truncated
+// last entry, left by a process which died while writing it, is ignored.
+func replayJournal(e *engine, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		entry, err := readDocument(r)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := e.replay(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// replay applies a journal entry. Entries put the engine into the state it had
+// when they were recorded, so replaying a journal over collections written after
+// it started gives the same result.
+func (e *engine) replay(entry bson.D) error {
+	op, _ := lookupField(entry, "op")
+	db, _ := lookupField(entry, "db")
+	coll, _ := lookupField(entry, "coll")
+	dbName, _ := db.(string)
+	collName, _ := coll.(string)
+
+	if op == journalDrop {
+		e.dropCollection(dbName, collName)
+		return nil
+	}
+	c, err := e.ensureCollection(dbName, collName)
+	if err != nil {
+		return err
+	}
+	switch op {
+	case journalCreate:
+	case journalPut:
+		doc, _ := lookupField(entry, "doc")
+		docD, ok := doc.(bson.D)
+		if !ok {
+			return errors.New("journal entry has no document")
+		}
+		id, _ := lookupField(docD, "_id")
+		if rec := c.findID(id); rec != nil {
+			return c.replace(rec, docD)
+		}
+		return c.insert(docD)
+	case journalDelete:
+		id, _ := lookupField(entry, "id")
+		if rec := c.findID(id); rec != nil {
+			c.remove(rec)
+		}
+	case journalCreateIndex:
+		spec, _ := lookupField(entry, "spec")
+		specD, ok := spec.(bson.D)
+		if !ok {
+			return errors.New("journal entry has no index")
+		}
+		return c.createIndex(specD)
+	case journalDropIndex:
+		name, _ := lookupField(entry, "name")
+		nameStr, _ := name.(string)
+		if err := c.dropIndex(nameStr); err != nil && errorCode(err) != codeIndexNotFound {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown journal operation %v", op)
+	}
+	return nil
+}
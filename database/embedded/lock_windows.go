@@ -0,0 +1,17 @@
+package embedded
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// lockDir opens the lock file of a data directory. Windows does not support flock,
+// so the directory is not protected from being used by two servers at once.
+func lockDir(dir string) (*os.File, error) {
+	return os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, 0600)
+}
+
+// unlockDir closes the lock file opened by lockDir
+func unlockDir(f *os.File) error {
+	return f.Close()
+}
@@ -0,0 +1,452 @@
+package embedded
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// noMatch is returned by value predicates which do not match. Predicates which match
+// return the index of the array element they matched, or -1.
+const noMatch = -2
+
+// predicate tests a value reached by following a query path
+type predicate func(v interface{}) (int, error)
+
+// matchState records the position of the array element a query matched, which
+// positional updates and projections refer to with "$"
+type matchState struct {
+	pos    int
+	posSet bool
+}
+
+// record stores the position of the matched array element unless one was recorded earlier
+func (s *matchState) record(pos int) {
+	if s != nil && !s.posSet && pos >= 0 {
+		s.pos = pos
+		s.posSet = true
+	}
+}
+
+// regexCache holds compiled query regular expressions
+var regexCache sync.Map
+
+// compileRegex translates a BSON regular expression into a Go regular expression
+func compileRegex(re primitive.Regex) (*regexp.Regexp, error) {
+	key := re.Options + "/" + re.Pattern
+	if cached, ok := regexCache.Load(key); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	flags := ""
+	for _, opt := range re.Options {
+		switch opt {
+		case 'i', 'm', 's':
+			flags += string(opt)
+		}
+	}
+	pattern := re.Pattern
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %q: %v", re.Pattern, err)
+	}
+	regexCache.Store(key, compiled)
+	return compiled, nil
+}
+
+// regexMatches checks whether a string value matches a regular expression
+func regexMatches(re primitive.Regex, v interface{}) (bool, error) {
+	if other, ok := v.(primitive.Regex); ok {
+		return other == re, nil
+	}
+	str, ok := v.(string)
+	if !ok {
+		if sym, isSym := v.(primitive.Symbol); isSym {
+			str, ok = string(sym), true
+		}
+	}
+	if !ok {
+		return false, nil
+	}
+	compiled, err := compileRegex(re)
+	if err != nil {
+		return false, err
+	}
+	return compiled.MatchString(str), nil
+}
+
+// matchDocument checks whether a document matches a query filter. vars holds the
+// variables $expr conditions may refer to.
+func matchDocument(doc bson.D, filter bson.D, state *matchState, vars *scope) (bool, error) {
+	for _, cond := range filter {
+		ok, err := matchCondition(doc, cond.Key, cond.Value, state, vars)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchCondition checks a single top level entry of a query filter
+func matchCondition(doc bson.D, key string, value interface{}, state *matchState, vars *scope) (bool, error) {
+	switch key {
+	case "$and", "$or", "$nor":
+		clauses, ok := value.(bson.A)
+		if !ok || len(clauses) == 0 {
+			return false, fmt.Errorf("%s must be a nonempty array", key)
+		}
+		for _, clause := range clauses {
+			clauseDoc, isDoc := clause.(bson.D)
+			if !isDoc {
+				return false, fmt.Errorf("%s entries must be objects", key)
+			}
+			clauseState := state
+			if key == "$nor" {
+				clauseState = nil
+			}
+			ok, err := matchDocument(doc, clauseDoc, clauseState, vars)
+			if err != nil {
+				return false, err
+			}
+			switch {
+			case key == "$and" && !ok:
+				return false, nil
+			case key == "$or" && ok:
+				return true, nil
+			case key == "$nor" && ok:
+				return false, nil
+			}
+		}
+		return key != "$or", nil
+	case "$expr":
+		result, err := evalExpr(value, newScope(doc, vars))
+		if err != nil {
+			return false, err
+		}
+		return isTruthy(result), nil
+	case "$comment":
+		return true, nil
+	}
+	if strings.HasPrefix(key, "$") {
+		return false, fmt.Errorf("unknown top level operator: %s", key)
+	}
+	return matchField(doc, strings.Split(key, "."), value, state, vars)
+}
+
+// matchField checks the condition on a field path, which is either a value the
+// field must equal or a document of query operators
+func matchField(doc bson.D, path []string, cond interface{}, state *matchState, vars *scope) (bool, error) {
+	if re, ok := cond.(primitive.Regex); ok {
+		return matchPath(doc, path, regexPredicate(re), true, state, 0)
+	}
+	if !isOperatorDocument(cond) {
+		return matchPath(doc, path, equalityPredicate(cond), true, state, 0)
+	}
+
+	ops := cond.(bson.D)
+	for _, op := range ops {
+		ok, err := matchOperator(doc, path, op.Key, op.Value, ops, state, vars)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchOperator checks a single query operator on a field path. ops holds the
+// operator's siblings, which $regex reads its $options from.
+func matchOperator(doc bson.D, path []string, op string, arg interface{}, ops bson.D, state *matchState, vars *scope) (bool, error) {
+	switch op {
+	case "$eq":
+		return matchPath(doc, path, equalityPredicate(arg), true, state, 0)
+	case "$ne":
+		ok, err := matchPath(doc, path, equalityPredicate(arg), true, nil, 0)
+		return !ok, err
+	case "$gt", "$gte", "$lt", "$lte":
+		return matchPath(doc, path, comparisonPredicate(op, arg), true, state, 0)
+	case "$in", "$nin":
+		values, ok := arg.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("%s needs an array", op)
+		}
+		if op == "$nin" {
+			ok, err := matchPath(doc, path, inPredicate(values), true, nil, 0)
+			return !ok, err
+		}
+		return matchPath(doc, path, inPredicate(values), true, state, 0)
+	case "$exists":
+		ok, err := matchPath(doc, path, existsPredicate, false, state, 0)
+		if isTruthy(arg) {
+			return ok, err
+		}
+		return !ok, err
+	case "$size":
+		size, ok := toInt(arg)
+		if !ok {
+			return false, fmt.Errorf("$size needs a number")
+		}
+		return matchPath(doc, path, func(v interface{}) (int, error) {
+			if arr, isArr := v.(bson.A); isArr && int64(len(arr)) == size {
+				return -1, nil
+			}
+			return noMatch, nil
+		}, false, state, 0)
+	case "$all":
+		values, ok := arg.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("$all needs an array")
+		}
+		if len(values) == 0 {
+			return false, nil
+		}
+		for _, value := range values {
+			ok, err := matchField(doc, path, value, state, vars)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case "$elemMatch":
+		sub, ok := arg.(bson.D)
+		if !ok {
+			return false, fmt.Errorf("$elemMatch needs an object")
+		}
+		return matchPath(doc, path, elemMatchPredicate(sub, vars), false, state, 0)
+	case "$not":
+		var ok bool
+		var err error
+		if re, isRegex := arg.(primitive.Regex); isRegex {
+			ok, err = matchPath(doc, path, regexPredicate(re), true, nil, 0)
+		} else if isOperatorDocument(arg) {
+			ok, err = matchField(doc, path, arg, nil, vars)
+		} else {
+			return false, fmt.Errorf("$not needs a regex or a document")
+		}
+		return !ok, err
+	case "$regex":
+		re, err := regexArgument(arg, ops)
+		if err != nil {
+			return false, err
+		}
+		return matchPath(doc, path, regexPredicate(re), true, state, 0)
+	case "$options":
+		// read along with $regex
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown operator: %s", op)
+}
+
+// regexArgument builds the regular expression of a $regex operator and its $options sibling
+func regexArgument(arg interface{}, ops bson.D) (primitive.Regex, error) {
+	var re primitive.Regex
+	switch val := arg.(type) {
+	case primitive.Regex:
+		re = val
+	case string:
+		re.Pattern = val
+	default:
+		return re, fmt.Errorf("$regex has to be a string")
+	}
+	if options, ok := lookupField(ops, "$options"); ok {
+		re.Options, _ = options.(string)
+	}
+	return re, nil
+}
+
+// matchPath follows a query path through documents and arrays and tests the values it
+// reaches. Arrays of documents along the path are searched element by element. If expand
+// is set, the elements of an array at the end of the path are tested as well as the array.
+func matchPath(current interface{}, path []string, pred predicate, expand bool, state *matchState, arrayDepth int) (bool, error) {
+	if len(path) == 0 {
+		pos, err := pred(current)
+		if err != nil {
+			return false, err
+		}
+		if pos != noMatch {
+			if arrayDepth == 0 {
+				state.record(pos)
+			}
+			return true, nil
+		}
+		if arr, ok := current.(bson.A); ok && expand {
+			for i, elem := range arr {
+				pos, err = pred(elem)
+				if err != nil {
+					return false, err
+				}
+				if pos != noMatch {
+					if arrayDepth == 0 {
+						state.record(i)
+					}
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+
+	switch val := current.(type) {
+	case bson.D:
+		next, _ := lookupField(val, path[0])
+		return matchPath(next, path[1:], pred, expand, state, arrayDepth)
+	case bson.A:
+		if idx, err := strconv.Atoi(path[0]); err == nil && idx >= 0 {
+			if idx < len(val) {
+				return matchPath(val[idx], path[1:], pred, expand, state, arrayDepth)
+			}
+			return matchPath(missing, path[1:], pred, expand, state, arrayDepth)
+		}
+		for i, elem := range val {
+			if _, isDoc := elem.(bson.D); !isDoc {
+				continue
+			}
+			ok, err := matchPath(elem, path, pred, expand, state, arrayDepth+1)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				if arrayDepth == 0 {
+					state.record(i)
+				}
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	// the rest of the path does not exist below a value which is not a document
+	pos, err := pred(missing)
+	return pos != noMatch, err
+}
+
+// equalityPredicate matches values equal to the target. A null target matches
+// missing values as well.
+func equalityPredicate(target interface{}) predicate {
+	return func(v interface{}) (int, error) {
+		if isNullish(target) {
+			if isNullish(v) {
+				return -1, nil
+			}
+			return noMatch, nil
+		}
+		if valuesEqual(v, target) {
+			return -1, nil
+		}
+		return noMatch, nil
+	}
+}
+
+// regexPredicate matches strings matching a regular expression
+func regexPredicate(re primitive.Regex) predicate {
+	return func(v interface{}) (int, error) {
+		ok, err := regexMatches(re, v)
+		if err != nil || !ok {
+			return noMatch, err
+		}
+		return -1, nil
+	}
+}
+
+// comparisonPredicate matches values which compare to the target as the operator
+// requires. Only values of the same type as the target are compared.
+func comparisonPredicate(op string, target interface{}) predicate {
+	return func(v interface{}) (int, error) {
+		if isNullish(target) {
+			if (op == "$gte" || op == "$lte") && isNullish(v) {
+				return -1, nil
+			}
+			return noMatch, nil
+		}
+		if _, isMissing := v.(missingValue); isMissing || typeOrder(v) != typeOrder(target) {
+			return noMatch, nil
+		}
+		c := compareValues(v, target)
+		var ok bool
+		switch op {
+		case "$gt":
+			ok = c > 0
+		case "$gte":
+			ok = c >= 0
+		case "$lt":
+			ok = c < 0
+		case "$lte":
+			ok = c <= 0
+		}
+		if ok {
+			return -1, nil
+		}
+		return noMatch, nil
+	}
+}
+
+// inPredicate matches values equal to any of the targets. Regular expressions
+// among the targets are matched against strings.
+func inPredicate(targets bson.A) predicate {
+	return func(v interface{}) (int, error) {
+		for _, target := range targets {
+			var pos int
+			var err error
+			if re, ok := target.(primitive.Regex); ok {
+				pos, err = regexPredicate(re)(v)
+			} else {
+				pos, err = equalityPredicate(target)(v)
+			}
+			if err != nil || pos != noMatch {
+				return pos, err
+			}
+		}
+		return noMatch, nil
+	}
+}
+
+// existsPredicate matches any value which is present
+func existsPredicate(v interface{}) (int, error) {
+	if _, ok := v.(missingValue); ok {
+		return noMatch, nil
+	}
+	return -1, nil
+}
+
+// elemMatchPredicate matches arrays holding an element which matches the sub query.
+// The sub query is either a document query or operators which test the elements.
+func elemMatchPredicate(sub bson.D, vars *scope) predicate {
+	valueQuery := isOperatorDocument(sub) && sub[0].Key != "$and" && sub[0].Key != "$or" &&
+		sub[0].Key != "$nor" && sub[0].Key != "$expr"
+
+	return func(v interface{}) (int, error) {
+		arr, ok := v.(bson.A)
+		if !ok {
+			return noMatch, nil
+		}
+		for i, elem := range arr {
+			var matched bool
+			var err error
+			if valueQuery {
+				matched, err = matchField(bson.D{{Key: "v", Value: elem}}, []string{"v"}, sub, nil, vars)
+			} else if elemDoc, isDoc := elem.(bson.D); isDoc {
+				matched, err = matchDocument(elemDoc, sub, nil, vars)
+			}
+			if err != nil {
+				return noMatch, err
+			}
+			if matched {
+				return i, nil
+			}
+		}
+		return noMatch, nil
+	}
+}
+
+// matchValue checks whether a single value satisfies a condition, which is either a
+// value to equal or a document of query operators. It is used by $pull and array filters.
+func matchValue(v interface{}, cond interface{}, vars *scope) (bool, error) {
+	return matchField(bson.D{{Key: "v", Value: v}}, []string{"v"}, cond, nil, vars)
+}
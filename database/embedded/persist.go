@@ -0,0 +1,297 @@
+package embedded
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// collectionExt is the extension of the files holding collections
+const collectionExt = ".bson"
+
+// snapshot holds the documents of a collection as they were when a flush started
+type snapshot struct {
+	db, coll string
+	indexes  bson.A
+	docs     []bson.D
+}
+
+// persister writes the databases of an engine to disk. Each collection is stored
+// in its own file, a header document listing its indexes followed by its documents.
+// The changes made since the collections were last written are kept in journals.
+type persister struct {
+	// mu serializes flushes so that files are not written by two flushes at once
+	mu  sync.Mutex
+	dir string
+}
+
+// collectionPath returns the path of the file holding a collection
+func (p *persister) collectionPath(db, coll string) string {
+	return filepath.Join(p.dir, url.PathEscape(db), url.PathEscape(coll)+collectionExt)
+}
+
+// load reads the databases stored in the persister's directory into an engine,
+// applies the journals left since they were written and starts a new journal
+func (p *persister) load(e *engine) error {
+	e.replaying = true
+	defer func() { e.replaying = false }()
+	if err := p.loadCollections(e); err != nil {
+		return err
+	}
+
+	seqs, err := journalFiles(p.dir)
+	if err != nil {
+		return err
+	}
+	next := 1
+	for _, seq := range seqs {
+		if err := replayJournal(e, journalPath(p.dir, seq)); err != nil {
+			return fmt.Errorf("could not apply journal %d: %v", seq, err)
+		}
+		next = seq + 1
+	}
+	if e.journal, err = openJournal(p.dir, next); err != nil {
+		return err
+	}
+	if len(seqs) == 0 {
+		return nil
+	}
+	// the journals were left by a process which did not close its server. Write the
+	// collections now so that journals do not pile up over many runs.
+	e.replaying = false
+	return p.flush(e)
+}
+
+// loadCollections reads the collection files into an engine
+func (p *persister) loadCollections(e *engine) error {
+	dbDirs, err := os.ReadDir(p.dir)
+	if err != nil {
+		return err
+	}
+	for _, dbDir := range dbDirs {
+		if !dbDir.IsDir() {
+			continue
+		}
+		db, err := url.PathUnescape(dbDir.Name())
+		if err != nil {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(p.dir, dbDir.Name()))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if file.IsDir() || !strings.HasSuffix(file.Name(), collectionExt) {
+				continue
+			}
+			coll, err := url.PathUnescape(strings.TrimSuffix(file.Name(), collectionExt))
+			if err != nil {
+				continue
+			}
+			if err := p.loadCollection(e, db, coll); err != nil {
+				return fmt.Errorf("could not load collection %s.%s: %v", db, coll, err)
+			}
+		}
+	}
+	return nil
+}
+
+// loadCollection reads a collection file into an engine
+func (p *persister) loadCollection(e *engine, db, coll string) error {
+	f, err := os.Open(p.collectionPath(db, coll))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	c, err := e.ensureCollection(db, coll)
+	if err != nil {
+		return err
+	}
+	header, err := readDocument(r)
+	if err != nil {
+		return err
+	}
+	if indexes, ok := lookupField(header, "indexes"); ok {
+		specs, _ := indexes.(bson.A)
+		for _, spec := range specs {
+			if specDoc, isDoc := spec.(bson.D); isDoc {
+				if err := c.createIndex(specDoc); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for {
+		doc, err := readDocument(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := c.insert(doc); err != nil {
+			return err
+		}
+	}
+	c.dirty = false
+	return nil
+}
+
+// readDocument reads a single BSON document
+func readDocument(r io.Reader) (bson.D, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(size[:])
+	if n < 5 {
+		return nil, errors.New("invalid document length")
+	}
+	data := make([]byte, n)
+	copy(data, size[:])
+	if _, err := io.ReadFull(r, data[4:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// flush writes the collections which changed since the last flush, removes the
+// files of dropped collections and then removes the journals of the changes
+func (p *persister) flush(e *engine) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// take a snapshot so that the files are written without holding the engine's lock.
+	// Stored documents are never modified in place, so they can be shared. Changes
+	// made after the snapshot go to a new journal.
+	e.mu.Lock()
+	seq, err := e.journal.rotate()
+	if err != nil {
+		e.mu.Unlock()
+		return err
+	}
+	var snapshots []snapshot
+	for dbName, d := range e.databases {
+		for collName, c := range d.collections {
+			if !c.dirty {
+				continue
+			}
+			s := snapshot{db: dbName, coll: collName, indexes: bson.A{}}
+			for _, idx := range c.indexes[1:] {
+				s.indexes = append(s.indexes, idx.spec)
+			}
+			for _, rec := range c.all() {
+				s.docs = append(s.docs, rec.doc)
+			}
+			snapshots = append(snapshots, s)
+			c.dirty = false
+		}
+	}
+	dropped := e.dropped
+	e.dropped = make(map[string]map[string]struct{})
+	e.mu.Unlock()
+
+	var firstErr error
+	for db, colls := range dropped {
+		for coll := range colls {
+			if err := os.Remove(p.collectionPath(db, coll)); err != nil && !os.IsNotExist(err) && firstErr == nil {
+				firstErr = err
+			}
+		}
+		// the database directory is removed once it is empty
+		os.Remove(filepath.Join(p.dir, url.PathEscape(db)))
+	}
+
+	for _, s := range snapshots {
+		if err := p.writeCollection(s); err != nil {
+			// write the collection again on the next flush
+			e.mu.Lock()
+			if c := e.collection(s.db, s.coll); c != nil {
+				c.dirty = true
+			}
+			e.mu.Unlock()
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if firstErr != nil {
+		// the journals are needed to restore the changes which were not written
+		return firstErr
+	}
+	return removeJournals(p.dir, seq)
+}
+
+// close writes the databases of an engine to disk and closes its journal
+func (p *persister) close(e *engine) error {
+	if err := p.flush(e); err != nil {
+		e.journal.close()
+		return err
+	}
+	// nothing was recorded after the flush since the server no longer runs commands
+	if err := e.journal.close(); err != nil {
+		return err
+	}
+	return removeJournals(p.dir, e.journal.seq+1)
+}
+
+// writeCollection writes a collection to a temporary file and moves it into place
+// so that an interrupted write does not corrupt the stored collection
+func (p *persister) writeCollection(s snapshot) error {
+	path := p.collectionPath(s.db, s.coll)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	err = writeDocument(w, bson.D{{Key: "indexes", Value: s.indexes}})
+	for i := 0; err == nil && i < len(s.docs); i++ {
+		err = writeDocument(w, s.docs[i])
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// writeDocument writes a single BSON document
+func writeDocument(w io.Writer, doc bson.D) error {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,306 @@
+package embedded
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// projectionNode describes what a projection does with a field and the fields below it
+type projectionNode struct {
+	name     string
+	include  bool
+	exclude  bool
+	computed interface{}
+	hasExpr  bool
+	children []*projectionNode
+}
+
+// child returns the node for a sub field, creating it if needed
+func (n *projectionNode) child(name string) *projectionNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	c := &projectionNode{name: name}
+	n.children = append(n.children, c)
+	return c
+}
+
+// projection is a parsed $project stage or find projection
+type projection struct {
+	root      *projectionNode
+	inclusion bool
+	excludeID bool
+}
+
+// isInclusionValue checks whether a projection value includes a field
+func isInclusionValue(v interface{}) (include bool, exclude bool) {
+	switch val := v.(type) {
+	case bool:
+		return val, !val
+	case int32, int64, float64:
+		f, _ := toFloat(val)
+		return f != 0, f == 0
+	}
+	return false, false
+}
+
+// parseProjection parses a projection specification
+func parseProjection(spec bson.D) (*projection, error) {
+	p := &projection{root: &projectionNode{}}
+	hasInclusion, hasExclusion := false, false
+
+	var add func(node *projectionNode, path []string, value interface{}) error
+	add = func(node *projectionNode, path []string, value interface{}) error {
+		for _, part := range path {
+			node = node.child(part)
+		}
+		include, exclude := isInclusionValue(value)
+		switch {
+		case include:
+			node.include = true
+			hasInclusion = true
+		case exclude:
+			node.exclude = true
+			hasExclusion = true
+		default:
+			if doc, ok := value.(bson.D); ok && !isOperatorDocument(doc) && len(doc) > 0 {
+				for _, elem := range doc {
+					if err := add(node, strings.Split(elem.Key, "."), elem.Value); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			node.computed = value
+			node.hasExpr = true
+			hasInclusion = true
+		}
+		return nil
+	}
+
+	for _, elem := range spec {
+		if elem.Key == "_id" {
+			if _, exclude := isInclusionValue(elem.Value); exclude {
+				p.excludeID = true
+				continue
+			}
+		}
+		if strings.HasPrefix(elem.Key, "$") {
+			return nil, fmt.Errorf("field names in a projection may not start with '$': %s", elem.Key)
+		}
+		if err := add(p.root, strings.Split(elem.Key, "."), elem.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	if hasInclusion && hasExclusion {
+		return nil, errors.New("cannot do exclusion and inclusion in the same projection")
+	}
+	p.inclusion = hasInclusion
+	return p, nil
+}
+
+// apply projects a document
+func (p *projection) apply(doc bson.D, vars *scope) (bson.D, error) {
+	s := newScope(doc, vars)
+	if !p.inclusion {
+		out := excludeFields(doc, p.root)
+		if p.excludeID {
+			out = removeField(out, "_id")
+		}
+		return out, nil
+	}
+
+	out, err := includeFields(doc, p.root, s)
+	if err != nil {
+		return nil, err
+	}
+
+	if id, ok := lookupField(doc, "_id"); ok && !p.excludeID {
+		if _, projected := lookupField(out, "_id"); !projected {
+			out = append(bson.D{{Key: "_id", Value: id}}, out...)
+		}
+	}
+	return out, nil
+}
+
+// includeFields builds a document holding the included and computed fields of doc
+func includeFields(doc bson.D, node *projectionNode, s *scope) (bson.D, error) {
+	out := bson.D{}
+	for _, elem := range doc {
+		child := findChild(node, elem.Key)
+		if child == nil || child.hasExpr {
+			continue
+		}
+		if child.include {
+			out = append(out, elem)
+			continue
+		}
+		if len(child.children) > 0 {
+			v, err := includeValue(elem.Value, child, s)
+			if err != nil {
+				return nil, err
+			}
+			if _, isMissing := v.(missingValue); !isMissing {
+				out = append(out, bson.E{Key: elem.Key, Value: v})
+			}
+		}
+	}
+
+	// computed fields follow the fields taken from the document
+	for _, child := range node.children {
+		switch {
+		case child.hasExpr:
+			v, err := evalExpr(child.computed, s)
+			if err != nil {
+				return nil, err
+			}
+			if _, isMissing := v.(missingValue); !isMissing {
+				out = setField(out, child.name, v)
+			}
+		case !child.include && hasComputed(child):
+			// fields taken from the document already hold their computed sub fields
+			if _, present := lookupField(out, child.name); present {
+				continue
+			}
+			sub, err := includeFields(bson.D{}, child, s)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, bson.E{Key: child.name, Value: sub})
+		}
+	}
+	return out, nil
+}
+
+// includeValue applies the sub fields of an inclusion projection to a value. Arrays
+// are projected element by element.
+func includeValue(v interface{}, node *projectionNode, s *scope) (interface{}, error) {
+	switch val := v.(type) {
+	case bson.D:
+		return includeFields(val, node, s)
+	case bson.A:
+		out := bson.A{}
+		for _, elem := range val {
+			switch elem.(type) {
+			case bson.D, bson.A:
+				projected, err := includeValue(elem, node, s)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, projected)
+			}
+		}
+		return out, nil
+	}
+	return missing, nil
+}
+
+// hasComputed checks whether a node or any node below it computes a field
+func hasComputed(node *projectionNode) bool {
+	if node.hasExpr {
+		return true
+	}
+	for _, c := range node.children {
+		if hasComputed(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// findChild returns the node of a sub field or nil
+func findChild(node *projectionNode, name string) *projectionNode {
+	for _, c := range node.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// excludeFields copies a document without the excluded fields
+func excludeFields(doc bson.D, node *projectionNode) bson.D {
+	out := make(bson.D, 0, len(doc))
+	for _, elem := range doc {
+		child := findChild(node, elem.Key)
+		if child == nil {
+			out = append(out, elem)
+			continue
+		}
+		if child.exclude {
+			continue
+		}
+		out = append(out, bson.E{Key: elem.Key, Value: excludeValue(elem.Value, child)})
+	}
+	return out
+}
+
+// excludeValue removes the excluded sub fields of a value
+func excludeValue(v interface{}, node *projectionNode) interface{} {
+	switch val := v.(type) {
+	case bson.D:
+		return excludeFields(val, node)
+	case bson.A:
+		out := make(bson.A, len(val))
+		for i, elem := range val {
+			out[i] = excludeValue(elem, node)
+		}
+		return out
+	}
+	return v
+}
+
+// addFields evaluates the fields of an $addFields stage and sets them on a copy of doc
+func addFields(doc bson.D, spec bson.D, vars *scope) (bson.D, error) {
+	s := newScope(doc, vars)
+	out := doc
+	for _, elem := range spec {
+		v, err := evalExpr(elem.Value, s)
+		if err != nil {
+			return nil, err
+		}
+		out = withPath(out, strings.Split(elem.Key, "."), v)
+	}
+	return out, nil
+}
+
+// withPath returns a copy of doc with the value at a dotted path replaced. Documents
+// are created along the path as needed and a missing value removes the field. The
+// documents of doc are not modified.
+func withPath(doc bson.D, path []string, v interface{}) bson.D {
+	out := make(bson.D, len(doc))
+	copy(out, doc)
+
+	if len(path) == 1 {
+		if _, isMissing := v.(missingValue); isMissing {
+			return removeField(out, path[0])
+		}
+		return setField(out, path[0], v)
+	}
+
+	existing, _ := lookupField(out, path[0])
+	switch val := existing.(type) {
+	case bson.D:
+		return setField(out, path[0], withPath(val, path[1:], v))
+	case bson.A:
+		arr := make(bson.A, len(val))
+		for i, elem := range val {
+			if sub, isDoc := elem.(bson.D); isDoc {
+				arr[i] = withPath(sub, path[1:], v)
+			} else {
+				arr[i] = elem
+			}
+		}
+		return setField(out, path[0], arr)
+	}
+	if _, isMissing := v.(missingValue); isMissing {
+		return out
+	}
+	return setField(out, path[0], withPath(bson.D{}, path[1:], v))
+}
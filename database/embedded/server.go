@@ -0,0 +1,250 @@
+// Package embedded provides a storage backend which runs inside of RITA so that
+// small deployments and test runs do not need a MongoDB server. It serves the
+// subset of the MongoDB wire protocol RITA uses over a unix socket, so the regular
+// MongoDB driver and every Repository type work with it unchanged. Databases are
+// kept in memory. Every change is appended to a journal in the data directory
+// after each command, and the collections are written out periodically and on
+// Close, so no data is lost if the process exits without closing the server.
+// Without a data directory the databases are discarded on Close.
+package embedded
+
+import (
+	"bufio"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// the collections are written to disk every flushInterval, or sooner once the
+// journal grows past maxJournalSize, so that the journal stays quick to replay
+const (
+	flushInterval  = 10 * time.Minute
+	maxJournalSize = 256 * 1024 * 1024
+	// flushCheckInterval is how often the size of the journal is checked
+	flushCheckInterval = time.Minute
+)
+
+// Server serves the databases stored in a data directory to MongoDB clients
+type Server struct {
+	engine    *engine
+	persister *persister
+	lock      *os.File
+	listener  net.Listener
+	sockDir   string
+	sockPath  string
+
+	cursors    *cursorStore
+	requestID  int32
+	connsMu    sync.Mutex
+	conns      map[net.Conn]struct{}
+	wg         sync.WaitGroup
+	done       chan struct{}
+	closeOnce  sync.Once
+	closeError error
+}
+
+// Start loads the databases stored in dir, creating it if needed, and starts
+// serving them on a new unix socket. If dir is empty the databases are only kept
+// in memory.
+func Start(dir string) (*Server, error) {
+	s := &Server{
+		engine:  newEngine(),
+		cursors: newCursorStore(),
+		conns:   make(map[net.Conn]struct{}),
+		done:    make(chan struct{}),
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		lock, err := lockDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		s.lock = lock
+		s.persister = &persister{dir: dir}
+		if err := s.persister.load(s.engine); err != nil {
+			unlockDir(lock)
+			return nil, err
+		}
+	}
+
+	err := s.listen(dir)
+	if err != nil {
+		if s.lock != nil {
+			unlockDir(s.lock)
+		}
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+	if s.persister != nil {
+		s.wg.Add(1)
+		go s.flushPeriodically()
+	}
+	return s, nil
+}
+
+// maxSocketPath is the longest unix socket path every platform accepts
+const maxSocketPath = 100
+
+// usableSocketPath checks whether clients can connect to a socket at path. The
+// driver lowercases the addresses it connects to, socket paths included.
+func usableSocketPath(path string) bool {
+	return len(path) <= maxSocketPath && path == strings.ToLower(path)
+}
+
+// listen creates the unix socket the server listens on. The socket is placed in
+// the data directory, where a socket left by a process which did not close its
+// server is replaced, or otherwise in a new temporary directory.
+func (s *Server) listen(dir string) error {
+	var err error
+	if sockPath := filepath.Join(dir, "mongodb.sock"); dir != "" && usableSocketPath(sockPath) {
+		s.sockPath = sockPath
+		// the data directory is locked, so no other server uses the socket
+		if err := os.Remove(sockPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		tmp := os.TempDir()
+		if !usableSocketPath(filepath.Join(tmp, "rita-db-0123456789", "mongodb.sock")) {
+			tmp = "/tmp"
+		}
+		s.sockDir, err = os.MkdirTemp(tmp, "rita-db-")
+		if err != nil {
+			return err
+		}
+		s.sockPath = filepath.Join(s.sockDir, "mongodb.sock")
+	}
+	s.listener, err = net.Listen("unix", s.sockPath)
+	if err != nil && s.sockDir != "" {
+		os.RemoveAll(s.sockDir)
+	}
+	return err
+}
+
+// URI returns the connection string clients connect to the server with
+func (s *Server) URI() string {
+	return "mongodb://" + url.PathEscape(s.sockPath)
+}
+
+// Flush writes the collections which changed since the last flush to disk
+func (s *Server) Flush() error {
+	if s.persister == nil {
+		return nil
+	}
+	return s.persister.flush(s.engine)
+}
+
+// Close stops the server and writes its databases to disk
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.listener.Close()
+		s.connsMu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.connsMu.Unlock()
+		s.wg.Wait()
+
+		if s.persister != nil {
+			s.closeError = s.persister.close(s.engine)
+		}
+		if s.lock != nil {
+			if err := unlockDir(s.lock); err != nil && s.closeError == nil {
+				s.closeError = err
+			}
+		}
+		if s.sockDir != "" {
+			os.RemoveAll(s.sockDir)
+		}
+	})
+	return s.closeError
+}
+
+// serve accepts connections until the server is closed
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		// Close may have already closed the connections it knows about
+		s.connsMu.Lock()
+		select {
+		case <-s.done:
+			s.connsMu.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.connsMu.Unlock()
+		go s.handleConn(conn)
+	}
+}
+
+// handleConn answers the requests sent over a connection
+func (s *Server) handleConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.connsMu.Lock()
+		delete(s.conns, conn)
+		s.connsMu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		req, err := readMessage(r)
+		if err != nil {
+			return
+		}
+		reply := s.handleCommand(req.command)
+		if req.noReply {
+			continue
+		}
+		out, err := encodeReply(atomic.AddInt32(&s.requestID, 1), req, reply)
+		if err != nil {
+			out, err = encodeReply(atomic.AddInt32(&s.requestID, 1), req, errorReply(err))
+			if err != nil {
+				return
+			}
+		}
+		if _, err := conn.Write(out); err != nil {
+			return
+		}
+	}
+}
+
+// flushPeriodically writes changed collections to disk until the server is closed
+func (s *Server) flushPeriodically() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(flushCheckInterval)
+	defer ticker.Stop()
+	lastFlush := time.Now()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			if now.Sub(lastFlush) < flushInterval && s.engine.journal.written() < maxJournalSize {
+				continue
+			}
+			// a failed flush is retried on the next tick and reported by Close
+			s.Flush()
+			lastFlush = now
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package embedded
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// connect starts a server storing its data in dir and connects a client to it
+func connect(t *testing.T, dir string) (*Server, *mongo.Client) {
+	t.Helper()
+	server, err := Start(dir)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(server.URI()))
+	require.NoError(t, err)
+	require.NoError(t, client.Ping(ctx, nil))
+	return server, client
+}
+
+// disconnect closes a client and the server it is connected to
+func disconnect(t *testing.T, server *Server, client *mongo.Client) {
+	t.Helper()
+	require.NoError(t, client.Disconnect(context.Background()))
+	require.NoError(t, server.Close())
+}
+
+func TestCRUD(t *testing.T) {
+	server, client := connect(t, t.TempDir())
+	defer disconnect(t, server, client)
+	ctx := context.Background()
+	coll := client.Database("dataset").Collection("host")
+
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{{
+		Keys:    bson.D{{Key: "ip", Value: 1}, {Key: "network_uuid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}})
+	require.NoError(t, err)
+
+	_, err = coll.InsertMany(ctx, []interface{}{
+		bson.M{"ip": "10.0.0.1", "network_uuid": "a", "count": 1},
+		bson.M{"ip": "10.0.0.2", "network_uuid": "a", "count": 5},
+	})
+	require.NoError(t, err)
+
+	// the unique index rejects duplicate hosts
+	_, err = coll.InsertOne(ctx, bson.M{"ip": "10.0.0.1", "network_uuid": "a"})
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+
+	res, err := coll.UpdateOne(ctx,
+		bson.M{"ip": "10.0.0.1", "network_uuid": "a"},
+		bson.M{"$inc": bson.M{"count": 2}, "$push": bson.M{"dat": bson.M{"cid": 1, "bl": false}}},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), res.MatchedCount)
+	assert.Equal(t, int64(1), res.ModifiedCount)
+
+	res, err = coll.UpdateOne(ctx,
+		bson.M{"ip": "10.0.0.3", "network_uuid": "a"},
+		bson.M{"$set": bson.M{"count": 7}},
+		options.Update().SetUpsert(true),
+	)
+	require.NoError(t, err)
+	assert.NotNil(t, res.UpsertedID)
+
+	var host struct {
+		IP    string `bson:"ip"`
+		Count int    `bson:"count"`
+	}
+	require.NoError(t, coll.FindOne(ctx, bson.M{"ip": "10.0.0.3"}).Decode(&host))
+	assert.Equal(t, 7, host.Count)
+
+	count, err := coll.CountDocuments(ctx, bson.M{"count": bson.M{"$gte": 3}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	cursor, err := coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"count": -1}).SetLimit(2))
+	require.NoError(t, err)
+	var hosts []struct {
+		IP string `bson:"ip"`
+	}
+	require.NoError(t, cursor.All(ctx, &hosts))
+	require.Len(t, hosts, 2)
+	assert.Equal(t, "10.0.0.3", hosts[0].IP)
+	assert.Equal(t, "10.0.0.2", hosts[1].IP)
+
+	deleted, err := coll.DeleteMany(ctx, bson.M{"ip": bson.M{"$regex": `^10\.0\.0\.[12]$`}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted.DeletedCount)
+
+	names, err := client.Database("dataset").ListCollectionNames(ctx, bson.M{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"host"}, names)
+}
+
+func TestCursorBatches(t *testing.T) {
+	server, client := connect(t, t.TempDir())
+	defer disconnect(t, server, client)
+	ctx := context.Background()
+	coll := client.Database("dataset").Collection("conn")
+
+	docs := make([]interface{}, 1000)
+	for i := range docs {
+		docs[i] = bson.M{"n": i}
+	}
+	_, err := coll.InsertMany(ctx, docs)
+	require.NoError(t, err)
+
+	// results larger than the first batch are fetched with getMore
+	cursor, err := coll.Find(ctx, bson.M{}, options.Find().SetBatchSize(30))
+	require.NoError(t, err)
+	n := 0
+	for cursor.Next(ctx) {
+		assert.Equal(t, int32(n), cursor.Current.Lookup("n").Int32())
+		n++
+	}
+	require.NoError(t, cursor.Err())
+	assert.Equal(t, 1000, n)
+}
+
+func TestPositionalUpdates(t *testing.T) {
+	server, client := connect(t, t.TempDir())
+	defer disconnect(t, server, client)
+	ctx := context.Background()
+	coll := client.Database("dataset").Collection("uconn")
+
+	_, err := coll.InsertOne(ctx, bson.M{"src": "a", "dat": bson.A{
+		bson.M{"cid": 0, "count": 1, "prev": 1},
+		bson.M{"cid": 1, "count": 2, "prev": 1},
+	}})
+	require.NoError(t, err)
+
+	// the positional operator updates the element the filter matched
+	_, err = coll.UpdateOne(ctx,
+		bson.M{"src": "a", "dat.cid": 1},
+		bson.M{"$set": bson.M{"dat.$.count": 5}},
+	)
+	require.NoError(t, err)
+
+	var entry struct {
+		Dat []struct {
+			CID   int `bson:"cid"`
+			Count int `bson:"count"`
+		} `bson:"dat"`
+	}
+	err = coll.FindOne(ctx,
+		bson.M{"src": "a", "dat.cid": 1},
+		options.FindOne().SetProjection(bson.M{"dat.$": 1}),
+	).Decode(&entry)
+	require.NoError(t, err)
+	require.Len(t, entry.Dat, 1)
+	assert.Equal(t, 5, entry.Dat[0].Count)
+
+	// array filters update every matching element
+	_, err = coll.UpdateOne(ctx,
+		bson.M{"src": "a"},
+		bson.M{"$set": bson.M{"dat.$[entry].cid": 2}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			bson.M{"entry.cid": bson.M{"$lt": 2}},
+		}}),
+	)
+	require.NoError(t, err)
+
+	// array filters match the elements as they were before the update
+	_, err = coll.UpdateOne(ctx,
+		bson.M{"src": "a"},
+		bson.D{
+			{Key: "$set", Value: bson.M{"dat.$[entry].cid": 3}},
+			{Key: "$unset", Value: bson.M{"dat.$[entry].prev": ""}},
+		},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			bson.M{"entry.cid": 2},
+		}}),
+	)
+	require.NoError(t, err)
+
+	// pipeline updates can rewrite arrays
+	_, err = coll.UpdateOne(ctx, bson.M{"src": "a"}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"total": bson.M{"$sum": "$dat.count"}}}},
+	})
+	require.NoError(t, err)
+
+	var result struct {
+		Total int `bson:"total"`
+		Dat   []struct {
+			CID  int  `bson:"cid"`
+			Prev *int `bson:"prev"`
+		} `bson:"dat"`
+	}
+	require.NoError(t, coll.FindOne(ctx, bson.M{"src": "a"}).Decode(&result))
+	assert.Equal(t, 6, result.Total)
+	require.Len(t, result.Dat, 2)
+	for _, entry := range result.Dat {
+		assert.Equal(t, 3, entry.CID)
+		assert.Nil(t, entry.Prev)
+	}
+
+	// $pull removes the matching elements
+	_, err = coll.UpdateOne(ctx, bson.M{"src": "a"}, bson.M{"$pull": bson.M{"dat": bson.M{"count": 1}}})
+	require.NoError(t, err)
+	require.NoError(t, coll.FindOne(ctx, bson.M{"src": "a"}).Decode(&result))
+	assert.Len(t, result.Dat, 1)
+}
+
+func TestAggregate(t *testing.T) {
+	server, client := connect(t, t.TempDir())
+	defer disconnect(t, server, client)
+	ctx := context.Background()
+	db := client.Database("dataset")
+
+	_, err := db.Collection("uconn").InsertMany(ctx, []interface{}{
+		bson.M{"src": "a", "dst": "x", "dat": bson.A{bson.M{"bytes": 10}, bson.M{"bytes": 5}}},
+		bson.M{"src": "a", "dst": "y", "dat": bson.A{bson.M{"bytes": 1}}},
+		bson.M{"src": "b", "dst": "x", "dat": bson.A{bson.M{"bytes": 2}}},
+	})
+	require.NoError(t, err)
+	_, err = db.Collection("host").InsertMany(ctx, []interface{}{
+		bson.M{"ip": "a", "blacklisted": true},
+		bson.M{"ip": "b", "blacklisted": false},
+	})
+	require.NoError(t, err)
+
+	cursor, err := db.Collection("uconn").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$unwind", Value: "$dat"}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$src",
+			"bytes": bson.M{"$sum": "$dat.bytes"},
+			"dsts":  bson.M{"$addToSet": "$dst"},
+		}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "host",
+			"localField":   "_id",
+			"foreignField": "ip",
+			"as":           "host",
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"bytes":       1,
+			"dst_count":   bson.M{"$size": "$dsts"},
+			"blacklisted": bson.M{"$arrayElemAt": bson.A{"$host.blacklisted", 0}},
+		}}},
+		{{Key: "$sort", Value: bson.M{"bytes": -1}}},
+	})
+	require.NoError(t, err)
+
+	var results []struct {
+		Src         string `bson:"_id"`
+		Bytes       int    `bson:"bytes"`
+		DstCount    int    `bson:"dst_count"`
+		Blacklisted bool   `bson:"blacklisted"`
+	}
+	require.NoError(t, cursor.All(ctx, &results))
+	require.Len(t, results, 2)
+	assert.Equal(t, "a", results[0].Src)
+	assert.Equal(t, 16, results[0].Bytes)
+	assert.Equal(t, 2, results[0].DstCount)
+	assert.True(t, results[0].Blacklisted)
+	assert.Equal(t, "b", results[1].Src)
+	assert.Equal(t, 2, results[1].Bytes)
+	assert.False(t, results[1].Blacklisted)
+}
+
+func TestPersistence(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	server, client := connect(t, dir)
+	coll := client.Database("dataset").Collection("host")
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{{
+		Keys:    bson.D{{Key: "ip", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}})
+	require.NoError(t, err)
+	_, err = coll.InsertOne(ctx, bson.M{"ip": "10.0.0.1"})
+	require.NoError(t, err)
+	_, err = client.Database("dropped").Collection("conn").InsertOne(ctx, bson.M{"n": 1})
+	require.NoError(t, err)
+	require.NoError(t, server.Flush())
+	require.NoError(t, client.Database("dropped").Drop(ctx))
+
+	// a second server can not use the directory while the first one runs
+	_, err = Start(dir)
+	assert.Error(t, err)
+	disconnect(t, server, client)
+
+	// the data and indexes survive a restart
+	server, client = connect(t, dir)
+	defer disconnect(t, server, client)
+	coll = client.Database("dataset").Collection("host")
+	count, err := coll.CountDocuments(ctx, bson.M{"ip": "10.0.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	_, err = coll.InsertOne(ctx, bson.M{"ip": "10.0.0.1"})
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+
+	names, err := client.ListDatabaseNames(ctx, bson.M{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dataset"}, names)
+}
+
+func TestJournalRecovery(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	server, client := connect(t, dir)
+	coll := client.Database("dataset").Collection("host")
+	_, err := coll.InsertMany(ctx, []interface{}{bson.M{"ip": "10.0.0.1"}, bson.M{"ip": "10.0.0.2"}})
+	require.NoError(t, err)
+	require.NoError(t, server.Flush())
+	_, err = coll.UpdateOne(ctx, bson.M{"ip": "10.0.0.1"}, bson.M{"$set": bson.M{"count": 3}})
+	require.NoError(t, err)
+	_, err = coll.DeleteOne(ctx, bson.M{"ip": "10.0.0.2"})
+	require.NoError(t, err)
+	_, err = client.Database("dataset").Collection("conn").InsertOne(ctx, bson.M{"n": 1})
+	require.NoError(t, err)
+
+	// abandon the server the way an exiting process would, without writing the
+	// collections
+	require.NoError(t, client.Disconnect(ctx))
+	server.listener.Close()
+	server.engine.journal.file.Close()
+	require.NoError(t, unlockDir(server.lock))
+
+	// the changes made after the flush are restored from the journal
+	server, client = connect(t, dir)
+	defer disconnect(t, server, client)
+	var hosts []struct {
+		IP    string `bson:"ip"`
+		Count int    `bson:"count"`
+	}
+	cursor, err := client.Database("dataset").Collection("host").Find(ctx, bson.M{})
+	require.NoError(t, err)
+	require.NoError(t, cursor.All(ctx, &hosts))
+	require.Len(t, hosts, 1)
+	assert.Equal(t, "10.0.0.1", hosts[0].IP)
+	assert.Equal(t, 3, hosts[0].Count)
+	count, err := client.Database("dataset").Collection("conn").CountDocuments(ctx, bson.M{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,836 @@
+package embedded
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// error codes reported to the driver, matching the ones MongoDB uses
+const (
+	codeBadValue             = 2
+	codeFailedToParse        = 9
+	codeNamespaceNotFound    = 26
+	codeIndexNotFound        = 27
+	codeCursorNotFound       = 43
+	codeNamespaceExists      = 48
+	codeInvalidNamespace     = 73
+	codeIndexOptionsConflict = 85
+	codeCommandNotFound      = 59
+	codeDuplicateKey         = 11000
+)
+
+// commandError is an error reported to the driver with a MongoDB error code
+type commandError struct {
+	code int32
+	msg  string
+}
+
+func (e *commandError) Error() string {
+	return e.msg
+}
+
+// errorf creates a commandError
+func errorf(code int32, format string, args ...interface{}) error {
+	return &commandError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+// errorCode returns the MongoDB error code of an error
+func errorCode(err error) int32 {
+	var cmdErr *commandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.code
+	}
+	return codeBadValue
+}
+
+// record holds a stored document. Documents are never modified in place, an update
+// replaces the document of its record.
+type record struct {
+	seq     uint64
+	doc     bson.D
+	deleted bool
+}
+
+// index maps the values of the indexed fields to the records holding them. Only
+// equality lookups are supported, which is what RITA's queries need.
+type index struct {
+	name   string
+	spec   bson.D
+	fields []string
+	unique bool
+	// entries maps the key of all of the indexed fields to the records holding it
+	entries map[string]map[*record]struct{}
+	// prefix maps the key of the first indexed field to the records holding it
+	prefix map[string]map[*record]struct{}
+	// multikey is set once an indexed path holds an array. Lookups then can not
+	// use the index since array fields match the values of their elements.
+	multikey bool
+}
+
+// newIndex creates an empty index from its specification
+func newIndex(spec bson.D) (*index, error) {
+	name, _ := lookupField(spec, "name")
+	keys, _ := lookupField(spec, "key")
+	nameStr, ok := name.(string)
+	keyDoc, isDoc := keys.(bson.D)
+	if !ok || nameStr == "" || !isDoc || len(keyDoc) == 0 {
+		return nil, errorf(codeFailedToParse, "an index specification requires a name and a non-empty key")
+	}
+	unique, _ := lookupField(spec, "unique")
+	idx := &index{
+		name:    nameStr,
+		spec:    spec,
+		unique:  isTruthy(unique),
+		entries: make(map[string]map[*record]struct{}),
+		prefix:  make(map[string]map[*record]struct{}),
+	}
+	for _, key := range keyDoc {
+		idx.fields = append(idx.fields, key.Key)
+	}
+	return idx, nil
+}
+
+// keys returns the key of a document's indexed fields and the key of its first
+// indexed field. ok is false if an indexed path holds an array.
+func (idx *index) keys(doc bson.D) (full string, first string, ok bool) {
+	var sb strings.Builder
+	for i, field := range idx.fields {
+		v, isPlain := indexValue(doc, field)
+		if !isPlain {
+			return "", "", false
+		}
+		writeGroupKey(&sb, v)
+		if i == 0 {
+			first = sb.String()
+		}
+	}
+	return sb.String(), first, true
+}
+
+// indexValue returns the value of a dotted path, treating missing values as null.
+// ok is false if the path holds an array.
+func indexValue(doc bson.D, path string) (interface{}, bool) {
+	var current interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		switch val := current.(type) {
+		case bson.D:
+			current, _ = lookupField(val, part)
+		case bson.A:
+			return nil, false
+		default:
+			return nil, true
+		}
+	}
+	switch current.(type) {
+	case bson.A:
+		return nil, false
+	case missingValue:
+		return nil, true
+	}
+	return current, true
+}
+
+// conflicts returns an error if adding doc to a unique index would duplicate the
+// key of a record other than self
+func (idx *index) conflicts(doc bson.D, self *record) error {
+	if !idx.unique {
+		return nil
+	}
+	full, _, ok := idx.keys(doc)
+	if !ok {
+		return nil
+	}
+	for rec := range idx.entries[full] {
+		if rec != self {
+			return errorf(codeDuplicateKey, "E11000 duplicate key error index: %s dup key", idx.name)
+		}
+	}
+	return nil
+}
+
+// add adds a record to the index
+func (idx *index) add(rec *record) {
+	full, first, ok := idx.keys(rec.doc)
+	if !ok {
+		idx.multikey = true
+		return
+	}
+	addEntry(idx.entries, full, rec)
+	addEntry(idx.prefix, first, rec)
+}
+
+// remove removes a record from the index
+func (idx *index) remove(rec *record) {
+	full, first, ok := idx.keys(rec.doc)
+	if !ok {
+		return
+	}
+	removeEntry(idx.entries, full, rec)
+	removeEntry(idx.prefix, first, rec)
+}
+
+func addEntry(entries map[string]map[*record]struct{}, key string, rec *record) {
+	set, ok := entries[key]
+	if !ok {
+		set = make(map[*record]struct{}, 1)
+		entries[key] = set
+	}
+	set[rec] = struct{}{}
+}
+
+func removeEntry(entries map[string]map[*record]struct{}, key string, rec *record) {
+	if set, ok := entries[key]; ok {
+		delete(set, rec)
+		if len(set) == 0 {
+			delete(entries, key)
+		}
+	}
+}
+
+// collection holds the documents of a collection in insertion order along with
+// its indexes
+type collection struct {
+	db, name string
+	engine   *engine
+	// records holds the records in insertion order. Deleted records are only
+	// marked and dropped from the slice once they make up half of it.
+	records []*record
+	deleted int
+	nextSeq uint64
+	indexes []*index
+	// dirty is set when the collection changed since it was last written to disk
+	dirty bool
+}
+
+// newCollection creates an empty collection with the default _id index
+func newCollection(e *engine, db, name string) *collection {
+	c := &collection{db: db, name: name, engine: e}
+	idIndex, _ := newIndex(bson.D{
+		{Key: "v", Value: int32(2)},
+		{Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}},
+		{Key: "name", Value: "_id_"},
+	})
+	idIndex.unique = true
+	c.indexes = append(c.indexes, idIndex)
+	return c
+}
+
+// all returns the records of the collection in insertion order
+func (c *collection) all() []*record {
+	out := make([]*record, 0, len(c.records)-c.deleted)
+	for _, rec := range c.records {
+		if !rec.deleted {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+// count returns the number of documents in the collection
+func (c *collection) count() int {
+	return len(c.records) - c.deleted
+}
+
+// sortRecords sorts records into insertion order
+func sortRecords(recs []*record) {
+	sort.Slice(recs, func(i, j int) bool { return recs[i].seq < recs[j].seq })
+}
+
+// candidates returns the records which may match a filter in insertion order. An
+// index is used if the filter has equality conditions on its fields.
+func (c *collection) candidates(filter bson.D) []*record {
+	eq := equalityConditions(filter)
+	if len(eq) > 0 {
+		var best map[*record]struct{}
+		found := false
+		for _, idx := range c.indexes {
+			if idx.multikey {
+				continue
+			}
+			var sb strings.Builder
+			complete := true
+			var first string
+			for i, field := range idx.fields {
+				v, ok := eq[field]
+				if !ok {
+					complete = false
+					break
+				}
+				writeGroupKey(&sb, v)
+				if i == 0 {
+					first = sb.String()
+				}
+			}
+			var set map[*record]struct{}
+			switch {
+			case complete:
+				set = idx.entries[sb.String()]
+			case first != "":
+				set = idx.prefix[first]
+			default:
+				continue
+			}
+			if !found || len(set) < len(best) {
+				best = set
+				found = true
+			}
+		}
+		if found {
+			out := make([]*record, 0, len(best))
+			for rec := range best {
+				out = append(out, rec)
+			}
+			sortRecords(out)
+			return out
+		}
+	}
+	return c.all()
+}
+
+// equalityConditions collects the fields a filter requires to equal a scalar value
+func equalityConditions(filter bson.D) map[string]interface{} {
+	out := make(map[string]interface{})
+	var collect func(filter bson.D)
+	collect = func(filter bson.D) {
+		for _, cond := range filter {
+			if cond.Key == "$and" {
+				clauses, _ := cond.Value.(bson.A)
+				for _, clause := range clauses {
+					if clauseDoc, ok := clause.(bson.D); ok {
+						collect(clauseDoc)
+					}
+				}
+				continue
+			}
+			if strings.HasPrefix(cond.Key, "$") {
+				continue
+			}
+			value := cond.Value
+			if ops, ok := value.(bson.D); ok && isOperatorDocument(ops) {
+				if len(ops) != 1 || ops[0].Key != "$eq" {
+					continue
+				}
+				value = ops[0].Value
+			}
+			switch value.(type) {
+			case bson.D, bson.A, primitive.Regex, nil, primitive.Null:
+				// documents, arrays and null also match in other ways
+				continue
+			}
+			out[cond.Key] = value
+		}
+	}
+	collect(filter)
+	return out
+}
+
+// insert adds a document, which must have an _id, to the collection
+func (c *collection) insert(doc bson.D) error {
+	rec := &record{seq: c.nextSeq, doc: doc}
+	if err := c.checkConflicts(doc, nil); err != nil {
+		return err
+	}
+	c.nextSeq++
+	c.records = append(c.records, rec)
+	for _, idx := range c.indexes {
+		idx.add(rec)
+	}
+	c.dirty = true
+	c.record(journalPut, bson.E{Key: "doc", Value: doc})
+	return nil
+}
+
+// checkConflicts checks whether a document would violate a unique index. Replayed
+// journals may pass through states which did not exist, so they are not checked.
+func (c *collection) checkConflicts(doc bson.D, self *record) error {
+	if c.engine.replaying {
+		return nil
+	}
+	for _, idx := range c.indexes {
+		if err := idx.conflicts(doc, self); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record adds a change of the collection to the engine's journal
+func (c *collection) record(op string, fields ...bson.E) {
+	entry := bson.D{{Key: "op", Value: op}, {Key: "db", Value: c.db}, {Key: "coll", Value: c.name}}
+	c.engine.journal.record(append(entry, fields...))
+}
+
+// findID returns the record with the given _id or nil if there is none
+func (c *collection) findID(id interface{}) *record {
+	full, _, ok := c.indexes[0].keys(bson.D{{Key: "_id", Value: id}})
+	if !ok {
+		return nil
+	}
+	for rec := range c.indexes[0].entries[full] {
+		return rec
+	}
+	return nil
+}
+
+// replace replaces the document of a record
+func (c *collection) replace(rec *record, doc bson.D) error {
+	if err := c.checkConflicts(doc, rec); err != nil {
+		return err
+	}
+	for _, idx := range c.indexes {
+		idx.remove(rec)
+	}
+	rec.doc = doc
+	for _, idx := range c.indexes {
+		idx.add(rec)
+	}
+	c.dirty = true
+	c.record(journalPut, bson.E{Key: "doc", Value: doc})
+	return nil
+}
+
+// remove deletes a record
+func (c *collection) remove(rec *record) {
+	for _, idx := range c.indexes {
+		idx.remove(rec)
+	}
+	rec.deleted = true
+	c.deleted++
+	c.dirty = true
+	id, _ := lookupField(rec.doc, "_id")
+	c.record(journalDelete, bson.E{Key: "id", Value: id})
+
+	if c.deleted > len(c.records)/2 {
+		kept := c.records[:0]
+		for _, r := range c.records {
+			if !r.deleted {
+				kept = append(kept, r)
+			}
+		}
+		for i := len(kept); i < len(c.records); i++ {
+			c.records[i] = nil
+		}
+		c.records = kept
+		c.deleted = 0
+	}
+}
+
+// createIndex adds an index built from the existing documents. Creating an index
+// which already exists does nothing.
+func (c *collection) createIndex(spec bson.D) error {
+	idx, err := newIndex(spec)
+	if err != nil {
+		return err
+	}
+	newKey, _ := lookupField(spec, "key")
+	for _, existing := range c.indexes {
+		existingKey, _ := lookupField(existing.spec, "key")
+		sameKey := valuesEqual(existingKey, newKey)
+		switch {
+		case existing.name == idx.name && sameKey:
+			return nil
+		case existing.name == idx.name:
+			return errorf(codeIndexOptionsConflict, "an index named %s already exists with a different key", idx.name)
+		case sameKey:
+			return errorf(codeIndexOptionsConflict, "index with the same key already exists with a different name: %s", existing.name)
+		}
+	}
+	for _, rec := range c.all() {
+		if !c.engine.replaying {
+			if err := idx.conflicts(rec.doc, nil); err != nil {
+				return err
+			}
+		}
+		idx.add(rec)
+	}
+	c.indexes = append(c.indexes, idx)
+	c.dirty = true
+	c.record(journalCreateIndex, bson.E{Key: "spec", Value: spec})
+	return nil
+}
+
+// dropIndex removes an index by name. "*" drops all indexes but the _id index.
+func (c *collection) dropIndex(name string) error {
+	if name == "_id_" {
+		return errorf(codeInvalidNamespace, "cannot drop _id index")
+	}
+	kept := c.indexes[:1]
+	found := false
+	for _, idx := range c.indexes[1:] {
+		if name == "*" || idx.name == name {
+			found = true
+			continue
+		}
+		kept = append(kept, idx)
+	}
+	if !found && name != "*" {
+		return errorf(codeIndexNotFound, "index not found with name [%s]", name)
+	}
+	c.indexes = kept
+	c.dirty = true
+	c.record(journalDropIndex, bson.E{Key: "name", Value: name})
+	return nil
+}
+
+// size returns the number of bytes the documents of the collection take up
+func (c *collection) size() int64 {
+	var total int64
+	for _, rec := range c.all() {
+		data, err := bson.Marshal(rec.doc)
+		if err == nil {
+			total += int64(len(data))
+		}
+	}
+	return total
+}
+
+// database holds the collections of a database
+type database struct {
+	name        string
+	collections map[string]*collection
+}
+
+// engine stores the databases of an embedded server. Commands lock the engine
+// for their whole run, so each command sees and leaves a consistent state.
+type engine struct {
+	mu        sync.RWMutex
+	databases map[string]*database
+	// dropped holds the collections which were removed since the engine was last
+	// written to disk
+	dropped map[string]map[string]struct{}
+	// journal records the changes to the databases if they are stored on disk
+	journal *journal
+	// replaying is set while a journal is applied
+	replaying bool
+}
+
+// newEngine creates an empty engine
+func newEngine() *engine {
+	return &engine{
+		databases: make(map[string]*database),
+		dropped:   make(map[string]map[string]struct{}),
+	}
+}
+
+// validateNames checks the names of a database and collection
+func validateNames(db, coll string) error {
+	if db == "" || strings.ContainsAny(db, "/\\. \"$\x00") {
+		return errorf(codeInvalidNamespace, "invalid database name: '%s'", db)
+	}
+	if coll == "" || strings.Contains(coll, "$") || strings.Contains(coll, "\x00") || strings.HasPrefix(coll, ".") {
+		return errorf(codeInvalidNamespace, "invalid collection name: '%s'", coll)
+	}
+	return nil
+}
+
+// collection returns a collection or nil if it does not exist
+func (e *engine) collection(db, coll string) *collection {
+	if d, ok := e.databases[db]; ok {
+		return d.collections[coll]
+	}
+	return nil
+}
+
+// ensureCollection returns a collection, creating it if it does not exist
+func (e *engine) ensureCollection(db, coll string) (*collection, error) {
+	if c := e.collection(db, coll); c != nil {
+		return c, nil
+	}
+	if err := validateNames(db, coll); err != nil {
+		return nil, err
+	}
+	d, ok := e.databases[db]
+	if !ok {
+		d = &database{name: db, collections: make(map[string]*collection)}
+		e.databases[db] = d
+	}
+	c := newCollection(e, db, coll)
+	c.dirty = true
+	d.collections[coll] = c
+	if dropped, ok := e.dropped[db]; ok {
+		delete(dropped, coll)
+	}
+	c.record(journalCreate)
+	return c, nil
+}
+
+// dropCollection removes a collection
+func (e *engine) dropCollection(db, coll string) bool {
+	d, ok := e.databases[db]
+	if !ok {
+		return false
+	}
+	if _, ok := d.collections[coll]; !ok {
+		return false
+	}
+	delete(d.collections, coll)
+	if len(d.collections) == 0 {
+		delete(e.databases, db)
+	}
+	if _, ok := e.dropped[db]; !ok {
+		e.dropped[db] = make(map[string]struct{})
+	}
+	e.dropped[db][coll] = struct{}{}
+	e.journal.record(bson.D{{Key: "op", Value: journalDrop}, {Key: "db", Value: db}, {Key: "coll", Value: coll}})
+	return true
+}
+
+// dropDatabase removes a database and its collections
+func (e *engine) dropDatabase(db string) {
+	d, ok := e.databases[db]
+	if !ok {
+		return
+	}
+	for name := range d.collections {
+		e.dropCollection(db, name)
+	}
+}
+
+// collectionNames returns the names of the collections of a database in sorted order
+func (e *engine) collectionNames(db string) []string {
+	d, ok := e.databases[db]
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, len(d.collections))
+	for name := range d.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// databaseNames returns the names of the databases in sorted order
+func (e *engine) databaseNames() []string {
+	names := make([]string, 0, len(e.databases))
+	for name := range e.databases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// documents returns the documents of a collection in insertion order, or none if
+// the collection does not exist
+func (e *engine) documents(db, coll string, filter bson.D) []bson.D {
+	c := e.collection(db, coll)
+	if c == nil {
+		return nil
+	}
+	recs := c.candidates(filter)
+	docs := make([]bson.D, len(recs))
+	for i, rec := range recs {
+		docs[i] = rec.doc
+	}
+	return docs
+}
+
+// writeError describes a failed write of a batch
+type writeError struct {
+	index int
+	err   error
+}
+
+// insertDocuments inserts documents, adding an _id to those which lack one
+func (e *engine) insertDocuments(db, coll string, docs []bson.D, ordered bool) (int, []writeError, error) {
+	c, err := e.ensureCollection(db, coll)
+	if err != nil {
+		return 0, nil, err
+	}
+	n := 0
+	var errs []writeError
+	for i, doc := range docs {
+		if err := c.insert(withID(doc)); err != nil {
+			errs = append(errs, writeError{index: i, err: err})
+			if ordered {
+				break
+			}
+			continue
+		}
+		n++
+	}
+	return n, errs, nil
+}
+
+// withID returns a document with its _id as the first field, generating the _id if needed
+func withID(doc bson.D) bson.D {
+	id, ok := lookupField(doc, "_id")
+	if !ok {
+		id = primitive.NewObjectID()
+	} else if doc[0].Key == "_id" {
+		return doc
+	}
+	return append(bson.D{{Key: "_id", Value: id}}, removeField(doc, "_id")...)
+}
+
+// updateSpec is a single statement of an update command
+type updateSpec struct {
+	filter       bson.D
+	update       interface{}
+	upsert       bool
+	multi        bool
+	arrayFilters bson.A
+}
+
+// updateResult reports the outcome of an update statement
+type updateResult struct {
+	matched  int
+	modified int
+	upserted interface{}
+}
+
+// updateDocuments runs an update statement
+func (e *engine) updateDocuments(db, coll string, spec updateSpec) (updateResult, error) {
+	var res updateResult
+	if spec.multi && isReplacement(spec.update) {
+		return res, errorf(codeFailedToParse, "multi update is not supported for replacement-style update")
+	}
+	filters, err := parseArrayFilters(spec.arrayFilters)
+	if err != nil {
+		return res, errorf(codeFailedToParse, "%s", err.Error())
+	}
+
+	c := e.collection(db, coll)
+	if c != nil {
+		for _, rec := range c.candidates(spec.filter) {
+			state := &matchState{}
+			matched, err := matchDocument(rec.doc, spec.filter, state, nil)
+			if err != nil {
+				return res, errorf(codeBadValue, "%s", err.Error())
+			}
+			if !matched {
+				continue
+			}
+			res.matched++
+			updated, err := applyUpdate(copyDocument(rec.doc), spec.update, &updateContext{state: state, arrayFilters: filters})
+			if err != nil {
+				return res, errorf(codeBadValue, "%s", err.Error())
+			}
+			if !sameDocument(updated, rec.doc) {
+				if err := c.replace(rec, updated); err != nil {
+					return res, err
+				}
+				res.modified++
+			}
+			if !spec.multi {
+				break
+			}
+		}
+	}
+	if res.matched > 0 || !spec.upsert {
+		return res, nil
+	}
+
+	doc := upsertDocument(spec.filter)
+	if isReplacement(spec.update) {
+		replacement := copyDocument(spec.update.(bson.D))
+		if id, ok := lookupField(doc, "_id"); ok {
+			if _, hasID := lookupField(replacement, "_id"); !hasID {
+				replacement = append(bson.D{{Key: "_id", Value: id}}, replacement...)
+			}
+		}
+		doc = replacement
+	} else {
+		doc, err = applyUpdate(withID(doc), spec.update, &updateContext{arrayFilters: filters, inserting: true})
+		if err != nil {
+			return res, errorf(codeBadValue, "%s", err.Error())
+		}
+	}
+	doc = withID(doc)
+	if c == nil {
+		if c, err = e.ensureCollection(db, coll); err != nil {
+			return res, err
+		}
+	}
+	if err := c.insert(doc); err != nil {
+		return res, err
+	}
+	res.upserted, _ = lookupField(doc, "_id")
+	return res, nil
+}
+
+// sameDocument checks whether two documents encode to the same bytes
+func sameDocument(a, b bson.D) bool {
+	aData, aErr := bson.Marshal(a)
+	bData, bErr := bson.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aData, bData)
+}
+
+// deleteDocuments deletes the documents matching a filter, stopping after limit
+// documents if limit is not zero
+func (e *engine) deleteDocuments(db, coll string, filter bson.D, limit int) (int, error) {
+	c := e.collection(db, coll)
+	if c == nil {
+		return 0, nil
+	}
+	n := 0
+	for _, rec := range c.candidates(filter) {
+		matched, err := matchDocument(rec.doc, filter, nil, nil)
+		if err != nil {
+			return n, errorf(codeBadValue, "%s", err.Error())
+		}
+		if !matched {
+			continue
+		}
+		c.remove(rec)
+		n++
+		if limit > 0 && n >= limit {
+			break
+		}
+	}
+	return n, nil
+}
+
+// findResult is a document matched by a find along with the array position the
+// filter matched, which positional projections use
+type findResult struct {
+	doc   bson.D
+	state matchState
+}
+
+// findDocuments returns the documents matching a filter
+func (e *engine) findDocuments(db, coll string, filter bson.D) ([]findResult, error) {
+	c := e.collection(db, coll)
+	if c == nil {
+		return nil, nil
+	}
+	var out []findResult
+	for _, rec := range c.candidates(filter) {
+		var state matchState
+		matched, err := matchDocument(rec.doc, filter, &state, nil)
+		if err != nil {
+			return nil, errorf(codeBadValue, "%s", err.Error())
+		}
+		if matched {
+			out = append(out, findResult{doc: rec.doc, state: state})
+		}
+	}
+	return out, nil
+}
+
+// aggregate runs a pipeline over a collection
+func (e *engine) aggregate(db, coll string, stages bson.A) ([]bson.D, error) {
+	var filter bson.D
+	if len(stages) > 0 {
+		if first, ok := stages[0].(bson.D); ok && len(first) == 1 && first[0].Key == "$match" {
+			filter, _ = first[0].Value.(bson.D)
+		}
+	}
+	p := &pipeline{
+		collection: func(name string) ([]bson.D, error) {
+			return e.documents(db, name, nil), nil
+		},
+	}
+	docs, err := p.run(e.documents(db, coll, filter), stages)
+	if err != nil {
+		return nil, errorf(codeBadValue, "%s", err.Error())
+	}
+	return docs, nil
+}
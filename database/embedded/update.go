@@ -0,0 +1,534 @@
+package embedded
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// updateContext holds what an update needs besides the document it changes
+type updateContext struct {
+	state        *matchState
+	arrayFilters map[string]bson.D
+	inserting    bool
+	// original is the document before the update. Array filters are matched against
+	// it so that operators do not affect which elements the others update.
+	original bson.D
+}
+
+// parseArrayFilters groups the array filters of an update by the identifier they bind
+func parseArrayFilters(filters bson.A) (map[string]bson.D, error) {
+	out := make(map[string]bson.D)
+	for _, filter := range filters {
+		doc, ok := filter.(bson.D)
+		if !ok || len(doc) == 0 {
+			return nil, errors.New("array filters must be objects")
+		}
+		for _, cond := range doc {
+			ident := strings.SplitN(cond.Key, ".", 2)[0]
+			out[ident] = append(out[ident], cond)
+		}
+	}
+	return out, nil
+}
+
+// isPipelineUpdate checks whether an update is an aggregation pipeline
+func isPipelineUpdate(update interface{}) bool {
+	_, ok := update.(bson.A)
+	return ok
+}
+
+// isReplacement checks whether an update replaces the document instead of using
+// update operators
+func isReplacement(update interface{}) bool {
+	doc, ok := update.(bson.D)
+	return ok && !isOperatorDocument(doc)
+}
+
+// applyUpdate applies an update to a document and returns the updated document.
+// doc must be a copy which the update may modify.
+func applyUpdate(doc bson.D, update interface{}, ctx *updateContext) (bson.D, error) {
+	if stages, ok := update.(bson.A); ok {
+		return applyPipelineUpdate(doc, stages)
+	}
+	ops, ok := update.(bson.D)
+	if !ok {
+		return nil, errors.New("an update must be an object or an array")
+	}
+
+	if isReplacement(ops) {
+		id, hasID := lookupField(doc, "_id")
+		out := copyDocument(ops)
+		if hasID {
+			if newID, replacesID := lookupField(out, "_id"); replacesID && !valuesEqual(newID, id) {
+				return nil, errors.New("the _id field cannot be changed")
+			}
+			out = append(bson.D{{Key: "_id", Value: id}}, removeField(out, "_id")...)
+		}
+		return out, nil
+	}
+
+	ctx.original = copyDocument(doc)
+	for _, op := range ops {
+		fields, isDoc := op.Value.(bson.D)
+		if !isDoc {
+			return nil, fmt.Errorf("modifiers operate on fields but we found type %s instead", typeName(op.Value))
+		}
+		for _, field := range fields {
+			var err error
+			doc, err = applyOperator(doc, op.Key, field.Key, copyValue(field.Value), ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return doc, nil
+}
+
+// applyOperator applies a single update operator to a field
+func applyOperator(doc bson.D, op string, field string, arg interface{}, ctx *updateContext) (bson.D, error) {
+	path := strings.Split(field, ".")
+	if path[0] == "_id" && !ctx.inserting && op != "$setOnInsert" {
+		return nil, errors.New("performing an update on the path '_id' would modify the immutable field '_id'")
+	}
+
+	var change func(old interface{}) (interface{}, error)
+	create := true
+	switch op {
+	case "$set":
+		change = func(interface{}) (interface{}, error) { return arg, nil }
+	case "$setOnInsert":
+		if !ctx.inserting {
+			return doc, nil
+		}
+		change = func(interface{}) (interface{}, error) { return arg, nil }
+	case "$unset":
+		create = false
+		change = func(interface{}) (interface{}, error) { return missing, nil }
+	case "$inc", "$mul":
+		if !isNumber(arg) {
+			return nil, fmt.Errorf("cannot %s with non-numeric argument: {%s: %v}", op[1:], field, arg)
+		}
+		change = func(old interface{}) (interface{}, error) {
+			if _, isMissing := old.(missingValue); isMissing {
+				if op == "$mul" {
+					return combineNumbers("$multiply", int32(0), arg), nil
+				}
+				return arg, nil
+			}
+			if !isNumber(old) {
+				return nil, fmt.Errorf("cannot apply %s to a value of non-numeric type %s", op, typeName(old))
+			}
+			if op == "$mul" {
+				return combineNumbers("$multiply", old, arg), nil
+			}
+			return combineNumbers("$add", old, arg), nil
+		}
+	case "$max", "$min":
+		sign := 1
+		if op == "$min" {
+			sign = -1
+		}
+		change = func(old interface{}) (interface{}, error) {
+			if _, isMissing := old.(missingValue); isMissing || compareValues(arg, old)*sign > 0 {
+				return arg, nil
+			}
+			return old, nil
+		}
+	case "$push", "$addToSet":
+		change = func(old interface{}) (interface{}, error) {
+			return pushValues(op, field, old, arg)
+		}
+	case "$pull":
+		create = false
+		change = func(old interface{}) (interface{}, error) {
+			return pullValues(field, old, arg)
+		}
+	case "$rename":
+		newName, ok := arg.(string)
+		if !ok {
+			return nil, errors.New("the 'to' field for $rename must be a string")
+		}
+		value := lookupPath(doc, field)
+		if _, isMissing := value.(missingValue); isMissing {
+			return doc, nil
+		}
+		doc, err := applyOperator(doc, "$unset", field, nil, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return applyOperator(doc, "$set", newName, value, ctx)
+	default:
+		return nil, fmt.Errorf("unknown modifier: %s", op)
+	}
+
+	result, err := modifyValue(doc, ctx.original, path, ctx, create, change)
+	if err != nil {
+		return nil, err
+	}
+	return result.(bson.D), nil
+}
+
+// modifyValue applies change to the value at path below current and returns the
+// updated current value. Documents are created along the path if create is set.
+// original is the value current held before the update.
+func modifyValue(current interface{}, original interface{}, path []string, ctx *updateContext, create bool, change func(interface{}) (interface{}, error)) (interface{}, error) {
+	part := path[0]
+	switch val := current.(type) {
+	case bson.D:
+		if strings.HasPrefix(part, "$") {
+			return nil, fmt.Errorf("cannot apply array updates to non-array element %s", part)
+		}
+		old, exists := lookupField(val, part)
+		if len(path) == 1 {
+			v, err := change(old)
+			if err != nil {
+				return nil, err
+			}
+			if _, isMissing := v.(missingValue); isMissing {
+				return removeField(val, part), nil
+			}
+			return setField(val, part, v), nil
+		}
+		if !exists || isNullish(old) {
+			if !create {
+				return val, nil
+			}
+			if !exists {
+				old = bson.D{}
+			}
+		}
+		var originalField interface{} = missing
+		if originalDoc, ok := original.(bson.D); ok {
+			originalField, _ = lookupField(originalDoc, part)
+		}
+		v, err := modifyValue(old, originalField, path[1:], ctx, create, change)
+		if err != nil {
+			return nil, err
+		}
+		return setField(val, part, v), nil
+	case bson.A:
+		originalArr, _ := original.(bson.A)
+		indexes, err := arrayIndexes(val, originalArr, part, ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, idx := range indexes {
+			for idx >= len(val) {
+				if !create {
+					return val, nil
+				}
+				val = append(val, nil)
+			}
+			var v interface{}
+			if len(path) == 1 {
+				v, err = change(val[idx])
+				if _, isMissing := v.(missingValue); isMissing {
+					// unsetting an array element leaves null in its place
+					v = nil
+				}
+			} else {
+				elem := val[idx]
+				if isNullish(elem) && create {
+					elem = bson.D{}
+				}
+				var originalElem interface{} = missing
+				if idx < len(originalArr) {
+					originalElem = originalArr[idx]
+				}
+				v, err = modifyValue(elem, originalElem, path[1:], ctx, create, change)
+			}
+			if err != nil {
+				return nil, err
+			}
+			val[idx] = v
+		}
+		return val, nil
+	}
+
+	if !create {
+		return current, nil
+	}
+	return nil, fmt.Errorf("cannot create field '%s' in element of type %s", part, typeName(current))
+}
+
+// arrayIndexes resolves a path component addressing array elements: an index, the
+// positional "$", "$[]" for every element or "$[id]" for the elements of the original
+// array matching an array filter
+func arrayIndexes(arr bson.A, original bson.A, part string, ctx *updateContext) ([]int, error) {
+	switch {
+	case part == "$":
+		if ctx.state == nil || !ctx.state.posSet {
+			return nil, errors.New("the positional operator did not find the match needed from the query")
+		}
+		return []int{ctx.state.pos}, nil
+	case part == "$[]":
+		out := make([]int, len(arr))
+		for i := range arr {
+			out[i] = i
+		}
+		return out, nil
+	case strings.HasPrefix(part, "$[") && strings.HasSuffix(part, "]"):
+		ident := part[2 : len(part)-1]
+		filter, ok := ctx.arrayFilters[ident]
+		if !ok {
+			return nil, fmt.Errorf("no array filter found for identifier '%s'", ident)
+		}
+		var out []int
+		for i, elem := range original {
+			if i >= len(arr) {
+				break
+			}
+			matched, err := matchArrayFilter(elem, ident, filter)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				out = append(out, i)
+			}
+		}
+		return out, nil
+	}
+	idx, err := strconv.Atoi(part)
+	if err != nil || idx < 0 {
+		return nil, fmt.Errorf("cannot create field '%s' in an array", part)
+	}
+	return []int{idx}, nil
+}
+
+// matchArrayFilter checks whether an array element matches the array filter of an identifier
+func matchArrayFilter(elem interface{}, ident string, filter bson.D) (bool, error) {
+	for _, cond := range filter {
+		var matched bool
+		var err error
+		if cond.Key == ident {
+			matched, err = matchValue(elem, cond.Value, nil)
+		} else {
+			doc, isDoc := elem.(bson.D)
+			if !isDoc {
+				return false, nil
+			}
+			matched, err = matchField(doc, strings.Split(strings.TrimPrefix(cond.Key, ident+"."), "."), cond.Value, nil, nil)
+		}
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// pushValues implements $push and $addToSet along with their $each, $slice and $sort modifiers
+func pushValues(op string, field string, old interface{}, arg interface{}) (interface{}, error) {
+	var arr bson.A
+	switch val := old.(type) {
+	case missingValue:
+		arr = bson.A{}
+	case bson.A:
+		arr = val
+	default:
+		return nil, fmt.Errorf("the field '%s' must be an array but is of type %s", field, typeName(old))
+	}
+
+	values := bson.A{arg}
+	var slice interface{}
+	var sortSpec interface{}
+	position := -1
+	if mods, ok := arg.(bson.D); ok && len(mods) > 0 && mods[0].Key == "$each" {
+		each, isArr := mods[0].Value.(bson.A)
+		if !isArr {
+			return nil, errors.New("the argument to $each must be an array")
+		}
+		values = each
+		for _, mod := range mods[1:] {
+			switch mod.Key {
+			case "$slice":
+				slice = mod.Value
+			case "$sort":
+				sortSpec = mod.Value
+			case "$position":
+				n, isInt := toInt(mod.Value)
+				if !isInt {
+					return nil, errors.New("$position must be an integer")
+				}
+				position = int(n)
+			default:
+				return nil, fmt.Errorf("unrecognized clause in %s: %s", op, mod.Key)
+			}
+		}
+	}
+
+	if op == "$addToSet" {
+		for _, v := range values {
+			duplicate := false
+			for _, existing := range arr {
+				if valuesEqual(existing, v) {
+					duplicate = true
+					break
+				}
+			}
+			if !duplicate {
+				arr = append(arr, v)
+			}
+		}
+		return arr, nil
+	}
+
+	if position < 0 || position > len(arr) {
+		arr = append(arr, values...)
+	} else {
+		arr = append(arr[:position], append(append(bson.A{}, values...), arr[position:]...)...)
+	}
+
+	if sortSpec != nil {
+		if err := sortArray(arr, sortSpec); err != nil {
+			return nil, err
+		}
+	}
+	if slice != nil {
+		n, ok := toInt(slice)
+		if !ok {
+			return nil, errors.New("$slice must be a numeric value")
+		}
+		if n >= 0 && int(n) < len(arr) {
+			arr = arr[:n]
+		} else if n < 0 && int(-n) < len(arr) {
+			arr = arr[len(arr)+int(n):]
+		}
+	}
+	return arr, nil
+}
+
+// sortArray sorts the elements of an array for the $sort modifier of $push
+func sortArray(arr bson.A, spec interface{}) error {
+	if order, ok := toInt(spec); ok {
+		sort.SliceStable(arr, func(i, j int) bool {
+			return compareValues(arr[i], arr[j])*int(order) < 0
+		})
+		return nil
+	}
+	keys, ok := spec.(bson.D)
+	if !ok {
+		return errors.New("the $sort modifier must be a number or an object")
+	}
+	sort.SliceStable(arr, func(i, j int) bool {
+		a, _ := arr[i].(bson.D)
+		b, _ := arr[j].(bson.D)
+		return compareSortKeys(a, b, keys) < 0
+	})
+	return nil
+}
+
+// pullValues implements $pull, which removes the array elements matching a condition
+func pullValues(field string, old interface{}, cond interface{}) (interface{}, error) {
+	arr, ok := old.(bson.A)
+	if !ok {
+		if _, isMissing := old.(missingValue); isMissing {
+			return old, nil
+		}
+		return nil, fmt.Errorf("cannot apply $pull to a non-array value at '%s'", field)
+	}
+
+	out := bson.A{}
+	for _, elem := range arr {
+		var matched bool
+		var err error
+		if condDoc, isDoc := cond.(bson.D); isDoc && !isOperatorDocument(condDoc) {
+			// a document condition is a query on the elements
+			if elemDoc, elemIsDoc := elem.(bson.D); elemIsDoc {
+				matched, err = matchDocument(elemDoc, condDoc, nil, nil)
+			}
+		} else {
+			matched, err = matchValue(elem, cond, nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			out = append(out, elem)
+		}
+	}
+	return out, nil
+}
+
+// applyPipelineUpdate applies an update given as an aggregation pipeline
+func applyPipelineUpdate(doc bson.D, stages bson.A) (bson.D, error) {
+	id, hasID := lookupField(doc, "_id")
+	for _, stage := range stages {
+		stageDoc, ok := stage.(bson.D)
+		if !ok || len(stageDoc) != 1 {
+			return nil, errors.New("each stage of an update pipeline must be an object with one field")
+		}
+		name, spec := stageDoc[0].Key, stageDoc[0].Value
+		var err error
+		switch name {
+		case "$set", "$addFields":
+			fields, isDoc := spec.(bson.D)
+			if !isDoc {
+				return nil, fmt.Errorf("%s specification must be an object", name)
+			}
+			doc, err = addFields(doc, fields, nil)
+		case "$unset", "$project":
+			var p *projection
+			p, err = stageProjection(name, spec)
+			if err == nil {
+				doc, err = p.apply(doc, nil)
+			}
+		case "$replaceRoot", "$replaceWith":
+			doc, err = replaceRoot(name, spec, doc, nil)
+		default:
+			return nil, fmt.Errorf("%s is not allowed to be used within an update", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if hasID {
+		if newID, ok := lookupField(doc, "_id"); !ok || !valuesEqual(newID, id) {
+			doc = append(bson.D{{Key: "_id", Value: id}}, removeField(doc, "_id")...)
+		}
+	}
+	return doc, nil
+}
+
+// upsertDocument builds the document an upsert inserts from the equality conditions of its filter
+func upsertDocument(filter bson.D) bson.D {
+	doc := bson.D{}
+	var collect func(filter bson.D)
+	collect = func(filter bson.D) {
+		for _, cond := range filter {
+			if cond.Key == "$and" {
+				if clauses, ok := cond.Value.(bson.A); ok {
+					for _, clause := range clauses {
+						if clauseDoc, isDoc := clause.(bson.D); isDoc {
+							collect(clauseDoc)
+						}
+					}
+				}
+				continue
+			}
+			if strings.HasPrefix(cond.Key, "$") {
+				continue
+			}
+			value := cond.Value
+			if ops, isOps := value.(bson.D); isOps && isOperatorDocument(ops) {
+				eq, hasEq := lookupField(ops, "$eq")
+				if !hasEq {
+					continue
+				}
+				value = eq
+			}
+			if _, isRegex := value.(primitive.Regex); isRegex {
+				continue
+			}
+			doc = withPath(doc, strings.Split(cond.Key, "."), copyValue(value))
+		}
+	}
+	collect(filter)
+	return doc
+}
@@ -0,0 +1,385 @@
+package embedded
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// missingValue marks a field which is not present in a document. It differs from
+// null, which is a value.
+type missingValue struct{}
+
+var missing = missingValue{}
+
+// typeOrder returns the rank of a value's type in the order MongoDB sorts
+// values of different types in
+func typeOrder(v interface{}) int {
+	switch v.(type) {
+	case primitive.MinKey:
+		return 1
+	case nil, missingValue, primitive.Null, primitive.Undefined:
+		return 2
+	case int32, int64, float64, int, primitive.Decimal128:
+		return 3
+	case string, primitive.Symbol:
+		return 4
+	case bson.D:
+		return 5
+	case bson.A:
+		return 6
+	case primitive.Binary:
+		return 7
+	case primitive.ObjectID:
+		return 8
+	case bool:
+		return 9
+	case primitive.DateTime:
+		return 10
+	case primitive.Timestamp:
+		return 11
+	case primitive.Regex:
+		return 12
+	case primitive.MaxKey:
+		return 14
+	}
+	return 13
+}
+
+// isNumber checks whether a value is numeric
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int32, int64, float64, int:
+		return true
+	}
+	return false
+}
+
+// isNullish checks whether a value is null or missing
+func isNullish(v interface{}) bool {
+	switch v.(type) {
+	case nil, missingValue, primitive.Null, primitive.Undefined:
+		return true
+	}
+	return false
+}
+
+// toFloat converts a numeric value into a float64
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	case primitive.Decimal128:
+		f, err := strconv.ParseFloat(n.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// toInt converts an integral value into an int64
+func toInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case float64:
+		if n == math.Trunc(n) {
+			return int64(n), true
+		}
+	}
+	return 0, false
+}
+
+// isInteger checks whether a value is stored as an integer type
+func isInteger(v interface{}) bool {
+	switch v.(type) {
+	case int32, int64, int:
+		return true
+	}
+	return false
+}
+
+// compareValues orders two values the way MongoDB sorts them. Values of different
+// types are ordered by type, numbers of different types are compared by value.
+func compareValues(a, b interface{}) int {
+	ta, tb := typeOrder(a), typeOrder(b)
+	if ta != tb {
+		return compareInts(int64(ta), int64(tb))
+	}
+
+	switch av := a.(type) {
+	case int32, int64, int, float64, primitive.Decimal128:
+		return compareNumbers(a, b)
+	case string:
+		return strings.Compare(av, stringValue(b))
+	case primitive.Symbol:
+		return strings.Compare(string(av), stringValue(b))
+	case bson.D:
+		return compareDocuments(av, b.(bson.D))
+	case bson.A:
+		return compareArrays(av, b.(bson.A))
+	case primitive.Binary:
+		bv := b.(primitive.Binary)
+		if len(av.Data) != len(bv.Data) {
+			return compareInts(int64(len(av.Data)), int64(len(bv.Data)))
+		}
+		if av.Subtype != bv.Subtype {
+			return compareInts(int64(av.Subtype), int64(bv.Subtype))
+		}
+		return bytes.Compare(av.Data, bv.Data)
+	case primitive.ObjectID:
+		bv := b.(primitive.ObjectID)
+		return bytes.Compare(av[:], bv[:])
+	case bool:
+		bv := b.(bool)
+		if av == bv {
+			return 0
+		}
+		if !av {
+			return -1
+		}
+		return 1
+	case primitive.DateTime:
+		return compareInts(int64(av), int64(b.(primitive.DateTime)))
+	case primitive.Timestamp:
+		bv := b.(primitive.Timestamp)
+		if av.T != bv.T {
+			return compareInts(int64(av.T), int64(bv.T))
+		}
+		return compareInts(int64(av.I), int64(bv.I))
+	case primitive.Regex:
+		bv := b.(primitive.Regex)
+		if c := strings.Compare(av.Pattern, bv.Pattern); c != 0 {
+			return c
+		}
+		return strings.Compare(av.Options, bv.Options)
+	}
+
+	if typeOrder(a) == 2 || typeOrder(a) == 1 || typeOrder(a) == 14 {
+		return 0
+	}
+
+	// fall back to comparing the encoded values of the remaining types
+	return bytes.Compare(encodeValue(a), encodeValue(b))
+}
+
+// stringValue returns the text of a string or symbol
+func stringValue(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case primitive.Symbol:
+		return string(s)
+	}
+	return ""
+}
+
+// compareInts orders two integers
+func compareInts(a, b int64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// compareNumbers orders two numbers of any numeric type. NaN sorts before
+// every other number.
+func compareNumbers(a, b interface{}) int {
+	if isInteger(a) && isInteger(b) {
+		ai, _ := toInt(a)
+		bi, _ := toInt(b)
+		return compareInts(ai, bi)
+	}
+	af, _ := toFloat(a)
+	bf, _ := toFloat(b)
+	switch {
+	case math.IsNaN(af) && math.IsNaN(bf):
+		return 0
+	case math.IsNaN(af):
+		return -1
+	case math.IsNaN(bf):
+		return 1
+	case af < bf:
+		return -1
+	case af > bf:
+		return 1
+	}
+	return 0
+}
+
+// compareDocuments orders two documents by comparing their fields in order
+func compareDocuments(a, b bson.D) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		ta, tb := typeOrder(a[i].Value), typeOrder(b[i].Value)
+		if ta != tb {
+			return compareInts(int64(ta), int64(tb))
+		}
+		if c := strings.Compare(a[i].Key, b[i].Key); c != 0 {
+			return c
+		}
+		if c := compareValues(a[i].Value, b[i].Value); c != 0 {
+			return c
+		}
+	}
+	return compareInts(int64(len(a)), int64(len(b)))
+}
+
+// compareArrays orders two arrays by comparing their elements in order
+func compareArrays(a, b bson.A) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(int64(len(a)), int64(len(b)))
+}
+
+// valuesEqual checks whether two values are equal. Numbers of different types
+// are equal if they hold the same value. Null and missing values are not equal.
+func valuesEqual(a, b interface{}) bool {
+	_, aMissing := a.(missingValue)
+	_, bMissing := b.(missingValue)
+	if aMissing || bMissing {
+		return aMissing && bMissing
+	}
+	return compareValues(a, b) == 0
+}
+
+// encodeValue encodes a single value as BSON so that values can be compared and
+// hashed as bytes
+func encodeValue(v interface{}) []byte {
+	if _, ok := v.(missingValue); ok {
+		v = nil
+	}
+	_, data, err := bson.MarshalValue(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// copyValue deep copies documents and arrays so that stored documents are never
+// modified in place
+func copyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case bson.D:
+		return copyDocument(val)
+	case bson.A:
+		out := make(bson.A, len(val))
+		for i, elem := range val {
+			out[i] = copyValue(elem)
+		}
+		return out
+	}
+	return v
+}
+
+// copyDocument deep copies a document
+func copyDocument(doc bson.D) bson.D {
+	out := make(bson.D, len(doc))
+	for i, elem := range doc {
+		out[i] = bson.E{Key: elem.Key, Value: copyValue(elem.Value)}
+	}
+	return out
+}
+
+// lookupField returns the value of a top level field of a document
+func lookupField(doc bson.D, key string) (interface{}, bool) {
+	for _, elem := range doc {
+		if elem.Key == key {
+			return elem.Value, true
+		}
+	}
+	return missing, false
+}
+
+// setField sets a top level field of a document, appending it if it is not present
+func setField(doc bson.D, key string, value interface{}) bson.D {
+	for i := range doc {
+		if doc[i].Key == key {
+			doc[i].Value = value
+			return doc
+		}
+	}
+	return append(doc, bson.E{Key: key, Value: value})
+}
+
+// removeField removes a top level field of a document
+func removeField(doc bson.D, key string) bson.D {
+	for i := range doc {
+		if doc[i].Key == key {
+			return append(doc[:i:i], doc[i+1:]...)
+		}
+	}
+	return doc
+}
+
+// lookupPath returns the value at a dotted path without traversing arrays of
+// documents. Numeric path components index into arrays.
+func lookupPath(doc bson.D, path string) interface{} {
+	var current interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		switch val := current.(type) {
+		case bson.D:
+			current, _ = lookupField(val, part)
+		case bson.A:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(val) {
+				return missing
+			}
+			current = val[idx]
+		default:
+			return missing
+		}
+	}
+	return current
+}
+
+// isOperatorDocument checks whether a document's keys are operators, such as
+// a query condition like {"$gt": 1}
+func isOperatorDocument(v interface{}) bool {
+	doc, ok := v.(bson.D)
+	if !ok || len(doc) == 0 {
+		return false
+	}
+	return strings.HasPrefix(doc[0].Key, "$")
+}
+
+// isTruthy evaluates a value as a boolean the way aggregation expressions do
+func isTruthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil, missingValue, primitive.Null, primitive.Undefined:
+		return false
+	case bool:
+		return val
+	case int32, int64, int, float64:
+		f, _ := toFloat(val)
+		return f != 0
+	}
+	return true
+}
+
+// normalizeInt stores an integer as an int32 if it fits, matching the type MongoDB
+// gives the result of integer arithmetic
+func normalizeInt(n int64) interface{} {
+	if n >= math.MinInt32 && n <= math.MaxInt32 {
+		return int32(n)
+	}
+	return n
+}
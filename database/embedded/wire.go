@@ -0,0 +1,230 @@
+package embedded
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// opcodes of the MongoDB wire protocol messages the server handles
+const (
+	opReply = 1
+	opQuery = 2004
+	opMsg   = 2013
+)
+
+// OP_MSG flags
+const (
+	flagChecksumPresent = 1 << 0
+	flagMoreToCome      = 1 << 1
+)
+
+// headerSize is the size of the header every message starts with
+const headerSize = 16
+
+// maxMessageSize is the largest message the server accepts
+const maxMessageSize = 48 * 1000 * 1000
+
+// castagnoli is the table of the CRC-32C checksums OP_MSG messages may carry
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// message is a request read from a client
+type message struct {
+	requestID int32
+	opCode    int32
+	// command is the command document. Document sequences of an OP_MSG are added
+	// to it as array fields.
+	command bson.D
+	// noReply is set for OP_MSG requests with the moreToCome flag, which the client
+	// does not wait for a reply to
+	noReply bool
+}
+
+// readMessage reads a request from a client
+func readMessage(r io.Reader) (*message, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := int32(binary.LittleEndian.Uint32(header[0:]))
+	if length < headerSize || length > maxMessageSize {
+		return nil, fmt.Errorf("invalid message length %d", length)
+	}
+	body := make([]byte, length-headerSize)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	msg := &message{
+		requestID: int32(binary.LittleEndian.Uint32(header[4:])),
+		opCode:    int32(binary.LittleEndian.Uint32(header[12:])),
+	}
+	var err error
+	switch msg.opCode {
+	case opMsg:
+		err = msg.parseMsg(header[:], body)
+	case opQuery:
+		err = msg.parseQuery(body)
+	default:
+		err = fmt.Errorf("unsupported opcode %d", msg.opCode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// parseMsg parses the body of an OP_MSG
+func (msg *message) parseMsg(header, body []byte) error {
+	if len(body) < 4 {
+		return errors.New("OP_MSG is too short")
+	}
+	flags := binary.LittleEndian.Uint32(body)
+	msg.noReply = flags&flagMoreToCome != 0
+	sections := body[4:]
+	if flags&flagChecksumPresent != 0 {
+		if len(sections) < 4 {
+			return errors.New("OP_MSG is too short for its checksum")
+		}
+		checksum := binary.LittleEndian.Uint32(sections[len(sections)-4:])
+		sections = sections[:len(sections)-4]
+		crc := crc32.Update(0, castagnoli, header)
+		crc = crc32.Update(crc, castagnoli, body[:len(body)-4])
+		if crc != checksum {
+			return errors.New("OP_MSG checksum does not match")
+		}
+	}
+
+	var sequences bson.D
+	for len(sections) > 0 {
+		kind := sections[0]
+		sections = sections[1:]
+		switch kind {
+		case 0:
+			doc, rest, err := splitDocument(sections)
+			if err != nil {
+				return err
+			}
+			if err := bson.Unmarshal(doc, &msg.command); err != nil {
+				return err
+			}
+			sections = rest
+		case 1:
+			if len(sections) < 4 {
+				return errors.New("OP_MSG document sequence is too short")
+			}
+			size := int(binary.LittleEndian.Uint32(sections))
+			if size < 4 || size > len(sections) {
+				return errors.New("invalid OP_MSG document sequence length")
+			}
+			seq := sections[4:size]
+			sections = sections[size:]
+			nameEnd := 0
+			for nameEnd < len(seq) && seq[nameEnd] != 0 {
+				nameEnd++
+			}
+			if nameEnd == len(seq) {
+				return errors.New("unterminated OP_MSG document sequence identifier")
+			}
+			name := string(seq[:nameEnd])
+			seq = seq[nameEnd+1:]
+			docs := bson.A{}
+			for len(seq) > 0 {
+				doc, rest, err := splitDocument(seq)
+				if err != nil {
+					return err
+				}
+				var d bson.D
+				if err := bson.Unmarshal(doc, &d); err != nil {
+					return err
+				}
+				docs = append(docs, d)
+				seq = rest
+			}
+			sequences = append(sequences, bson.E{Key: name, Value: docs})
+		default:
+			return fmt.Errorf("unknown OP_MSG section kind %d", kind)
+		}
+	}
+	if msg.command == nil {
+		return errors.New("OP_MSG has no command document")
+	}
+	msg.command = append(msg.command, sequences...)
+	return nil
+}
+
+// parseQuery parses the body of an OP_QUERY, which clients only use for the
+// initial handshake
+func (msg *message) parseQuery(body []byte) error {
+	// skip the flags and the collection name
+	if len(body) < 4 {
+		return errors.New("OP_QUERY is too short")
+	}
+	rest := body[4:]
+	nameEnd := 0
+	for nameEnd < len(rest) && rest[nameEnd] != 0 {
+		nameEnd++
+	}
+	if nameEnd+9 > len(rest) {
+		return errors.New("OP_QUERY is too short")
+	}
+	// skip the name, its terminator, numberToSkip and numberToReturn
+	doc, _, err := splitDocument(rest[nameEnd+9:])
+	if err != nil {
+		return err
+	}
+	if err := bson.Unmarshal(doc, &msg.command); err != nil {
+		return err
+	}
+	// the command may be wrapped along with read preferences
+	if query, ok := lookupField(msg.command, "$query"); ok {
+		if queryDoc, isDoc := query.(bson.D); isDoc {
+			msg.command = queryDoc
+		}
+	}
+	return nil
+}
+
+// splitDocument splits the first BSON document off of data
+func splitDocument(data []byte) ([]byte, []byte, error) {
+	if len(data) < 5 {
+		return nil, nil, errors.New("document is too short")
+	}
+	size := int(binary.LittleEndian.Uint32(data))
+	if size < 5 || size > len(data) {
+		return nil, nil, errors.New("invalid document length")
+	}
+	return data[:size], data[size:], nil
+}
+
+// encodeReply encodes the reply to a request in the format of the request
+func encodeReply(requestID int32, req *message, reply bson.D) ([]byte, error) {
+	doc, err := bson.Marshal(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	opCode := int32(opMsg)
+	if req.opCode == opQuery {
+		opCode = opReply
+		// responseFlags, cursorID, startingFrom and numberReturned
+		body = make([]byte, 20, 20+len(doc))
+		binary.LittleEndian.PutUint32(body[16:], 1)
+	} else {
+		// flagBits and the kind of the single section
+		body = make([]byte, 5, 5+len(doc))
+	}
+	body = append(body, doc...)
+
+	out := make([]byte, headerSize, headerSize+len(body))
+	binary.LittleEndian.PutUint32(out[0:], uint32(headerSize+len(body)))
+	binary.LittleEndian.PutUint32(out[4:], uint32(requestID))
+	binary.LittleEndian.PutUint32(out[8:], uint32(req.requestID))
+	binary.LittleEndian.PutUint32(out[12:], uint32(opCode))
+	return append(out, body...), nil
+}
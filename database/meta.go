@@ -1,6 +1,7 @@
 package database
 
 import (
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -8,9 +9,11 @@ import (
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/parser/files"
 	"github.com/blang/semver"
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/bson"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type (
@@ -19,16 +22,16 @@ type (
 	MetaDB struct {
 		lock     *sync.Mutex    // Read and write lock
 		config   *config.Config // configuration info
-		dbHandle *mgo.Session   // Database handle
+		dbHandle *DB            // Database handle
 		log      *log.Logger    // Logging object
 	}
 
 	// LogInfo defines information about the UpdateChecker log
 	LogInfo struct {
-		ID      bson.ObjectId `bson:"_id,omitempty"`   // Ident
-		Time    time.Time     `bson:"LastUpdateCheck"` // Top level name of the database
-		Message string        `bson:"Message"`         // Top level name of the database
-		Version string        `bson:"NewestVersion"`   // Top level name of the database
+		ID      primitive.ObjectID `bson:"_id,omitempty"`   // Ident
+		Time    time.Time          `bson:"LastUpdateCheck"` // Top level name of the database
+		Message string             `bson:"Message"`         // Top level name of the database
+		Version string             `bson:"NewestVersion"`   // Top level name of the database
 	}
 
 	// Range defines a min and max value
@@ -39,19 +42,127 @@ type (
 
 	// DBMetaInfo defines some information about the database
 	DBMetaInfo struct {
-		ID             bson.ObjectId `bson:"_id,omitempty"`   // Ident
-		Name           string        `bson:"name"`            // Top level name of the database
-		Analyzed       bool          `bson:"analyzed"`        // Has this database been analyzed
-		AnalyzeVersion string        `bson:"analyze_version"` // Rita version at analyze
-		Rolling        bool          `bson:"rolling"`
-		TotalChunks    int           `bson:"total_chunks"`
-		CurrentChunk   int           `bson:"current_chunk"`
-		TsRange        Range         `bson:"ts_range"`
+		ID             primitive.ObjectID `bson:"_id,omitempty"`   // Ident
+		Name           string             `bson:"name"`            // Top level name of the database
+		Analyzed       bool               `bson:"analyzed"`        // Has this database been analyzed
+		AnalyzeVersion string             `bson:"analyze_version"` // Rita version at analyze
+		Rolling        bool               `bson:"rolling"`
+		TotalChunks    int                `bson:"total_chunks"`
+		CurrentChunk   int                `bson:"current_chunk"`
+		TsRange        Range              `bson:"ts_range"`
+		// analysis modules which could not run on the imported data (e.g. flow records carry no DNS data)
+		UnavailableModules []string `bson:"unavailable_modules"`
+		// progress of an import which has not finished yet
+		ImportCheckpoint *ImportCheckpoint `bson:"import_checkpoint,omitempty"`
+		// period of time selected for analysis when the logs were imported
+		TimeWindow *TimeWindow `bson:"time_window,omitempty"`
+		// hours of logs imported by rita watch and the chunks holding them
+		WatchedPeriods []WatchedPeriod `bson:"watched_periods,omitempty"`
+	}
+
+	// WatchedPeriod records the chunk holding an hour of logs imported by rita watch
+	WatchedPeriod struct {
+		Start int64 `bson:"start"` // unix timestamp of the start of the hour
+		CID   int   `bson:"cid"`
+	}
+
+	// TimeWindow selects the records which are analyzed by their timestamps.
+	// Records before Since, at or after Until, or inside one of the excluded
+	// ranges are left out. A bound of 0 leaves that side of the window open.
+	TimeWindow struct {
+		Since   int64   `bson:"since"`
+		Until   int64   `bson:"until"`
+		Exclude []Range `bson:"exclude"`
+	}
+
+	// ImportCheckpoint records how far an import got so that it can be resumed
+	// if it is interrupted
+	ImportCheckpoint struct {
+		CID             int       `bson:"cid"`
+		BatchesDone     int       `bson:"batches_done"`
+		TotalBatches    int       `bson:"total_batches"`
+		BatchInProgress bool      `bson:"batch_in_progress"` // set while the results of a batch are being written
+		Committing      bool      `bson:"committing"`        // set while the staged results of a finished batch are moved into the chunk
+		Updated         time.Time `bson:"updated"`
 	}
 )
 
+// IsEmpty checks whether the time window selects every record
+func (w TimeWindow) IsEmpty() bool {
+	return w.Since == 0 && w.Until == 0 && len(w.Exclude) == 0
+}
+
+// Contains checks whether a record with the given timestamp falls inside the time window
+func (w TimeWindow) Contains(ts int64) bool {
+	if (w.Since != 0 && ts < w.Since) || (w.Until != 0 && ts >= w.Until) {
+		return false
+	}
+	for _, excluded := range w.Exclude {
+		if ts >= excluded.Min && ts < excluded.Max {
+			return false
+		}
+	}
+	return true
+}
+
+// Clamp replaces the observed timestamp range of a dataset with the bounds of the time window
+func (w TimeWindow) Clamp(min int64, max int64) (int64, int64) {
+	if w.Since != 0 {
+		min = w.Since
+	}
+	if w.Until != 0 {
+		max = w.Until
+	}
+	return min, max
+}
+
+// Excludes checks whether any part of the period from min up to max falls inside one of the
+// excluded ranges of the time window
+func (w TimeWindow) Excludes(min int64, max int64) bool {
+	for _, excluded := range w.Exclude {
+		if excluded.Min < max && excluded.Max > min {
+			return true
+		}
+	}
+	return false
+}
+
+// ExcludedDuration returns how much of the period from min up to max falls inside the excluded
+// ranges of the time window. Time covered by more than one excluded range is only counted once.
+func (w TimeWindow) ExcludedDuration(min int64, max int64) int64 {
+	ranges := make([]Range, len(w.Exclude))
+	copy(ranges, w.Exclude)
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Min < ranges[j].Min })
+
+	var duration int64
+	covered := min // the excluded time before this point has been counted
+	for _, excluded := range ranges {
+		start, end := excluded.Min, excluded.Max
+		if start < covered {
+			start = covered
+		}
+		if end > max {
+			end = max
+		}
+		if start < end {
+			duration += end - start
+			covered = end
+		}
+	}
+	return duration
+}
+
+// Scale multiplies the bounds of the time window, such as to convert them into milliseconds
+func (w TimeWindow) Scale(factor int64) TimeWindow {
+	scaled := TimeWindow{Since: w.Since * factor, Until: w.Until * factor}
+	for _, excluded := range w.Exclude {
+		scaled.Exclude = append(scaled.Exclude, Range{Min: excluded.Min * factor, Max: excluded.Max * factor})
+	}
+	return scaled
+}
+
 // NewMetaDB instantiates a new handle for the RITA MetaDatabase
-func NewMetaDB(config *config.Config, dbHandle *mgo.Session,
+func NewMetaDB(config *config.Config, dbHandle *DB,
 	log *log.Logger) *MetaDB {
 	metaDB := &MetaDB{
 		lock:     new(sync.Mutex),
@@ -63,15 +174,20 @@ func NewMetaDB(config *config.Config, dbHandle *mgo.Session,
 	return metaDB
 }
 
+// collection returns a handle to a collection in the MetaDatabase
+func (m *MetaDB) collection(name string) *mongo.Collection {
+	return m.dbHandle.Client.Database(m.config.S.MongoDB.MetaDB).Collection(name)
+}
+
 //GetRollingSettings gets the current rolling settings
 func (m *MetaDB) GetRollingSettings(db string) (exists bool, isRolling bool, currChunk int, totalChunks int, err error) {
 	// pull down dataset record from metadatabase
 	result, err := m.GetDBMetaInfo(db)
-	if err != nil && err != mgo.ErrNotFound {
+	if err != nil && err != mongo.ErrNoDocuments {
 		return
 	}
 
-	if err != mgo.ErrNotFound {
+	if err != mongo.ErrNoDocuments {
 		exists = true
 	}
 	err = nil
@@ -92,8 +208,6 @@ func (m *MetaDB) SetRollingSettings(db string, chunk int, numchunks int) error {
 
 	m.lock.Lock()
 	defer m.lock.Unlock()
-	ssn := m.dbHandle.Copy()
-	defer ssn.Close()
 
 	selector := bson.M{"name": db}
 	update := bson.M{
@@ -121,7 +235,7 @@ func (m *MetaDB) SetRollingSettings(db string, chunk int, numchunks int) error {
 	}
 
 	// update rolling settings
-	_, err = ssn.DB(m.config.S.MongoDB.MetaDB).C(m.config.T.Meta.DatabasesTable).Upsert(selector, bson.M{"$set": update})
+	_, err = m.collection(m.config.T.Meta.DatabasesTable).UpdateOne(m.dbHandle.Context(), selector, bson.M{"$set": update}, options.Update().SetUpsert(true))
 	if err != nil {
 		return err
 	}
@@ -131,13 +245,12 @@ func (m *MetaDB) SetRollingSettings(db string, chunk int, numchunks int) error {
 
 //LastCheck returns most recent version check
 func (m *MetaDB) LastCheck() (time.Time, semver.Version) {
-	ssn := m.dbHandle.Copy()
-	defer ssn.Close()
-
-	iter := ssn.DB(m.config.S.MongoDB.MetaDB).C("logs").Find(bson.M{"Message": "Checking versions..."}).Sort("-Time").Iter()
-
 	var db LogInfo
-	iter.Next(&db)
+	m.collection("logs").FindOne(
+		m.dbHandle.Context(),
+		bson.M{"Message": "Checking versions..."},
+		options.FindOne().SetSort(bson.M{"Time": -1}),
+	).Decode(&db)
 
 	retVersion, err := semver.ParseTolerant(db.Version)
 
@@ -153,10 +266,9 @@ func (m *MetaDB) LastCheck() (time.Time, semver.Version) {
 func (m *MetaDB) AddNewDB(name string, currentChunk, totalChunks int) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
-	ssn := m.dbHandle.Copy()
-	defer ssn.Close()
 
-	err := ssn.DB(m.config.S.MongoDB.MetaDB).C(m.config.T.Meta.DatabasesTable).Insert(
+	_, err := m.collection(m.config.T.Meta.DatabasesTable).InsertOne(
+		m.dbHandle.Context(),
 		DBMetaInfo{
 			Name:           name,
 			Analyzed:       false,
@@ -180,14 +292,16 @@ func (m *MetaDB) AddNewDB(name string, currentChunk, totalChunks int) error {
 		Set bool `bson:"set"`
 	}, totalChunks)
 
-	_, err = ssn.DB(m.config.S.MongoDB.MetaDB).C(m.config.T.Meta.DatabasesTable).
-		Upsert(
+	_, err = m.collection(m.config.T.Meta.DatabasesTable).
+		UpdateOne(
+			m.dbHandle.Context(),
 			// selector
 			bson.M{"name": name},
 			// data
 			bson.M{"$set": bson.M{
 				"cid_list": cidList,
 			}},
+			options.Update().SetUpsert(true),
 		)
 	if err != nil {
 		return err
@@ -199,10 +313,10 @@ func (m *MetaDB) AddNewDB(name string, currentChunk, totalChunks int) error {
 //DBExists returns whether or not a metadatabase record has been created for a database
 func (m *MetaDB) DBExists(name string) (bool, error) {
 	_, err := m.GetDBMetaInfo(name)
-	if err != nil && err != mgo.ErrNotFound {
+	if err != nil && err != mongo.ErrNoDocuments {
 		return false, err
 	}
-	if err == mgo.ErrNotFound {
+	if err == mongo.ErrNoDocuments {
 		return false, nil
 	}
 	return true, nil
@@ -221,17 +335,15 @@ func (m *MetaDB) DeleteDB(name string) error {
 
 	m.lock.Lock()
 	defer m.lock.Unlock()
-	ssn := m.dbHandle.Copy()
-	defer ssn.Close()
 
 	//delete the record
-	_, err = ssn.DB(m.config.S.MongoDB.MetaDB).C(m.config.T.Meta.DatabasesTable).RemoveAll(bson.M{"name": name})
+	_, err = m.collection(m.config.T.Meta.DatabasesTable).DeleteMany(m.dbHandle.Context(), bson.M{"name": name})
 	if err != nil {
 		return err
 	}
 
 	//delete any parsed file records associated
-	_, err = ssn.DB(m.config.S.MongoDB.MetaDB).C(m.config.T.Meta.FilesTable).RemoveAll(bson.M{"database": name})
+	_, err = m.collection(m.config.T.Meta.FilesTable).DeleteMany(m.dbHandle.Context(), bson.M{"database": name})
 	if err != nil {
 		return err
 	}
@@ -256,26 +368,24 @@ func (m *MetaDB) GetTSRange(name string) (int64, int64, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	ssn := m.dbHandle.Copy()
-	defer ssn.Close()
-
 	type tsInfo struct {
 		Min int64 `bson:"min" json:"min"`
 		Max int64 `bson:"max" json:"max"`
 	}
 
 	var tsRes struct {
-		TSRange tsInfo `bson:"ts_range"`
+		TSRange    tsInfo      `bson:"ts_range"`
+		TimeWindow *TimeWindow `bson:"time_window"`
 	}
 
 	// get min and max timestamps
-	err = ssn.DB(m.config.S.MongoDB.MetaDB).C(m.config.T.Meta.DatabasesTable).Find(bson.M{"name": name}).One(&tsRes)
+	err = m.collection(m.config.T.Meta.DatabasesTable).FindOne(m.dbHandle.Context(), bson.M{"name": name}).Decode(&tsRes)
 
 	if err != nil {
 		m.log.WithFields(log.Fields{
 			"metadb_attempted":   m.config.S.MongoDB.MetaDB,
 			"database_requested": name,
-			"_id":                dbr.ID.Hex,
+			"_id":                dbr.ID.Hex(),
 			"error":              err.Error(),
 		}).Error("Could not retrieve timestamp range from metadatabase: ", err)
 		return min, max, err
@@ -284,6 +394,11 @@ func (m *MetaDB) GetTSRange(name string) (int64, int64, error) {
 	min = tsRes.TSRange.Min
 	max = tsRes.TSRange.Max
 
+	// the time window selected at import time takes precedence over the observed timestamps
+	if tsRes.TimeWindow != nil {
+		min, max = tsRes.TimeWindow.Clamp(min, max)
+	}
+
 	return min, max, nil
 }
 
@@ -302,24 +417,23 @@ func (m *MetaDB) AddTSRange(name string, min int64, max int64) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	ssn := m.dbHandle.Copy()
-	defer ssn.Close()
-
-	_, err = ssn.DB(m.config.S.MongoDB.MetaDB).C(m.config.T.Meta.DatabasesTable).
-		Upsert(
+	_, err = m.collection(m.config.T.Meta.DatabasesTable).
+		UpdateOne(
+			m.dbHandle.Context(),
 			bson.M{"_id": dbr.ID},
 			bson.M{
 				"$set": bson.M{
 					"ts_range.min": min,
 					"ts_range.max": max,
 				}},
+			options.Update().SetUpsert(true),
 		)
 
 	if err != nil {
 		m.log.WithFields(log.Fields{
 			"metadb_attempted":   m.config.S.MongoDB.MetaDB,
 			"database_requested": name,
-			"_id":                dbr.ID.Hex,
+			"_id":                dbr.ID.Hex(),
 			"error":              err.Error(),
 		}).Error("Could not update timestamp range for database entry in metadatabase")
 		return err
@@ -327,6 +441,183 @@ func (m *MetaDB) AddTSRange(name string, min int64, max int64) error {
 	return nil
 }
 
+// SetUnavailableModules records the analysis modules which could not be run on the
+// data imported into a database. An empty list marks every module as available.
+func (m *MetaDB) SetUnavailableModules(name string, modules []string) error {
+	dbr, err := m.GetDBMetaInfo(name)
+
+	if err != nil {
+		m.log.WithFields(log.Fields{
+			"database_requested": name,
+			"error":              err.Error(),
+		}).Error("Could not set unavailable modules: database not found in metadata directory")
+		return err
+	}
+
+	if modules == nil {
+		modules = []string{}
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, err = m.collection(m.config.T.Meta.DatabasesTable).
+		UpdateOne(
+			m.dbHandle.Context(),
+			bson.M{"_id": dbr.ID},
+			bson.M{
+				"$set": bson.M{
+					"unavailable_modules": modules,
+				}},
+			options.Update().SetUpsert(true),
+		)
+
+	if err != nil {
+		m.log.WithFields(log.Fields{
+			"metadb_attempted":   m.config.S.MongoDB.MetaDB,
+			"database_requested": name,
+			"_id":                dbr.ID.Hex(),
+			"error":              err.Error(),
+		}).Error("Could not update unavailable modules for database entry in metadatabase")
+		return err
+	}
+	return nil
+}
+
+// GetTimeWindow returns the time window selected when logs were imported into a database,
+// which is empty if every record was imported
+func (m *MetaDB) GetTimeWindow(name string) (TimeWindow, error) {
+	dbr, err := m.GetDBMetaInfo(name)
+	if err == mongo.ErrNoDocuments {
+		return TimeWindow{}, nil
+	}
+	if err != nil || dbr.TimeWindow == nil {
+		return TimeWindow{}, err
+	}
+	return *dbr.TimeWindow, nil
+}
+
+// SetTimeWindow records the time window selected when logs are imported into a database
+func (m *MetaDB) SetTimeWindow(name string, window TimeWindow) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	update := bson.M{"$set": bson.M{"time_window": window}}
+	if window.IsEmpty() {
+		update = bson.M{"$unset": bson.M{"time_window": ""}}
+	}
+
+	_, err := m.collection(m.config.T.Meta.DatabasesTable).
+		UpdateOne(m.dbHandle.Context(), bson.M{"name": name}, update)
+
+	if err != nil {
+		m.log.WithFields(log.Fields{
+			"metadb_attempted":   m.config.S.MongoDB.MetaDB,
+			"database_requested": name,
+			"error":              err.Error(),
+		}).Error("Could not update time window for database entry in metadatabase")
+		return err
+	}
+	return nil
+}
+
+// GetWatchedPeriods returns the hours of logs rita watch imported into a database
+// along with the chunks holding them
+func (m *MetaDB) GetWatchedPeriods(name string) ([]WatchedPeriod, error) {
+	dbr, err := m.GetDBMetaInfo(name)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return dbr.WatchedPeriods, nil
+}
+
+// SetWatchedPeriods records the hours of logs rita watch imported into a database
+// along with the chunks holding them
+func (m *MetaDB) SetWatchedPeriods(name string, periods []WatchedPeriod) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, err := m.collection(m.config.T.Meta.DatabasesTable).
+		UpdateOne(
+			m.dbHandle.Context(),
+			bson.M{"name": name},
+			bson.M{"$set": bson.M{"watched_periods": periods}},
+		)
+
+	if err != nil {
+		m.log.WithFields(log.Fields{
+			"metadb_attempted":   m.config.S.MongoDB.MetaDB,
+			"database_requested": name,
+			"error":              err.Error(),
+		}).Error("Could not update watched periods for database entry in metadatabase")
+		return err
+	}
+	return nil
+}
+
+// GetImportCheckpoint returns the progress of the unfinished import into a database,
+// or nil if every import into the database has finished
+func (m *MetaDB) GetImportCheckpoint(name string) (*ImportCheckpoint, error) {
+	dbr, err := m.GetDBMetaInfo(name)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return dbr.ImportCheckpoint, nil
+}
+
+// SetImportCheckpoint records the progress of an import into a database
+func (m *MetaDB) SetImportCheckpoint(name string, checkpoint ImportCheckpoint) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	checkpoint.Updated = time.Now()
+	_, err := m.collection(m.config.T.Meta.DatabasesTable).
+		UpdateOne(
+			m.dbHandle.Context(),
+			bson.M{"name": name},
+			bson.M{"$set": bson.M{"import_checkpoint": checkpoint}},
+		)
+
+	if err != nil {
+		m.log.WithFields(log.Fields{
+			"metadb_attempted":   m.config.S.MongoDB.MetaDB,
+			"database_requested": name,
+			"error":              err.Error(),
+		}).Error("Could not update import checkpoint for database entry in metadatabase")
+		return err
+	}
+	return nil
+}
+
+// ClearImportCheckpoint marks the import into a database as finished
+func (m *MetaDB) ClearImportCheckpoint(name string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, err := m.collection(m.config.T.Meta.DatabasesTable).
+		UpdateOne(
+			m.dbHandle.Context(),
+			bson.M{"name": name},
+			bson.M{"$unset": bson.M{"import_checkpoint": ""}},
+		)
+
+	if err != nil {
+		m.log.WithFields(log.Fields{
+			"metadb_attempted":   m.config.S.MongoDB.MetaDB,
+			"database_requested": name,
+			"error":              err.Error(),
+		}).Error("Could not clear import checkpoint for database entry in metadatabase")
+		return err
+	}
+	return nil
+}
+
 // MarkDBAnalyzed marks a database as having been analyzed
 func (m *MetaDB) MarkDBAnalyzed(name string, complete bool) error {
 	dbr, err := m.GetDBMetaInfo(name)
@@ -349,22 +640,19 @@ func (m *MetaDB) MarkDBAnalyzed(name string, complete bool) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	ssn := m.dbHandle.Copy()
-	defer ssn.Close()
-
-	_, err = ssn.DB(m.config.S.MongoDB.MetaDB).C(m.config.T.Meta.DatabasesTable).
-		Upsert(bson.M{"_id": dbr.ID}, bson.M{
+	_, err = m.collection(m.config.T.Meta.DatabasesTable).
+		UpdateOne(m.dbHandle.Context(), bson.M{"_id": dbr.ID}, bson.M{
 			"$set": bson.D{
-				{Name: "analyzed", Value: complete},
-				{Name: "analyze_version", Value: versionTag},
+				{Key: "analyzed", Value: complete},
+				{Key: "analyze_version", Value: versionTag},
 			},
-		})
+		}, options.Update().SetUpsert(true))
 
 	if err != nil {
 		m.log.WithFields(log.Fields{
 			"metadb_attempted":   m.config.S.MongoDB.MetaDB,
 			"database_requested": name,
-			"_id":                dbr.ID.Hex,
+			"_id":                dbr.ID.Hex(),
 			"error":              err.Error(),
 		}).Error("could not update database entry in meta")
 		return err
@@ -378,11 +666,13 @@ func (m *MetaDB) runDBMetaInfoQuery(queryDoc bson.M) ([]DBMetaInfo, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	ssn := m.dbHandle.Copy()
-	defer ssn.Close()
-
 	var results []DBMetaInfo
-	err := ssn.DB(m.config.S.MongoDB.MetaDB).C(m.config.T.Meta.DatabasesTable).Find(queryDoc).All(&results)
+	ctx := m.dbHandle.Context()
+	cursor, err := m.collection(m.config.T.Meta.DatabasesTable).Find(ctx, queryDoc)
+	if err != nil {
+		return results, err
+	}
+	err = cursor.All(ctx, &results)
 	if err != nil {
 		return results, err
 	}
@@ -395,16 +685,15 @@ func (m *MetaDB) SetChunk(cid int, db string, analyzed bool) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	ssn := m.dbHandle.Copy()
-	defer ssn.Close()
-
-	_, err := ssn.DB(m.config.S.MongoDB.MetaDB).C(m.config.T.Meta.DatabasesTable).
-		Upsert(
+	_, err := m.collection(m.config.T.Meta.DatabasesTable).
+		UpdateOne(
+			m.dbHandle.Context(),
 			bson.M{"name": db},
 			bson.M{
 				"$set": bson.M{
 					"cid_list." + strconv.Itoa(cid) + ".set": analyzed,
 				}},
+			options.Update().SetUpsert(true),
 		)
 
 	if err != nil {
@@ -423,9 +712,6 @@ func (m *MetaDB) IsChunkSet(cid int, db string) (bool, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	ssn := m.dbHandle.Copy()
-	defer ssn.Close()
-
 	query := bson.M{
 		"$and": []interface{}{
 			bson.M{"name": db},
@@ -433,7 +719,7 @@ func (m *MetaDB) IsChunkSet(cid int, db string) (bool, error) {
 		},
 	}
 
-	queryCount, err := ssn.DB(m.config.S.MongoDB.MetaDB).C(m.config.T.Meta.DatabasesTable).Find(query).Count()
+	queryCount, err := m.collection(m.config.T.Meta.DatabasesTable).CountDocuments(m.dbHandle.Context(), query)
 
 	if err != nil {
 		return false, err
@@ -454,14 +740,14 @@ func (m *MetaDB) GetDBMetaInfo(name string) (DBMetaInfo, error) {
 		return DBMetaInfo{}, err
 	}
 	if len(results) == 0 {
-		return DBMetaInfo{}, mgo.ErrNotFound
+		return DBMetaInfo{}, mongo.ErrNoDocuments
 	}
 	return results[0], nil
 }
 
 // GetDatabases returns a list of databases being tracked in metadb or an empty array on failure
 func (m *MetaDB) GetDatabases() []string {
-	dbs, err := m.runDBMetaInfoQuery(nil)
+	dbs, err := m.runDBMetaInfoQuery(bson.M{})
 	if err != nil {
 		m.log.WithFields(log.Fields{
 			"error": err.Error(),
@@ -529,11 +815,11 @@ func (m *MetaDB) GetFiles(database string) ([]files.IndexedFile, error) {
 	defer m.lock.Unlock()
 	var toReturn []files.IndexedFile
 
-	ssn := m.dbHandle.Copy()
-	defer ssn.Close()
-
-	err := ssn.DB(m.config.S.MongoDB.MetaDB).C(m.config.T.Meta.FilesTable).
-		Find(bson.M{"database": database}).Iter().All(&toReturn)
+	ctx := m.dbHandle.Context()
+	cursor, err := m.collection(m.config.T.Meta.FilesTable).Find(ctx, bson.M{"database": database})
+	if err == nil {
+		err = cursor.All(ctx, &toReturn)
+	}
 	if err != nil {
 		m.log.WithFields(log.Fields{
 			"error": err.Error(),
@@ -550,11 +836,6 @@ func (m *MetaDB) AddNewFilesToIndex(files []*files.IndexedFile) error {
 	if len(files) == 0 {
 		return nil
 	}
-	ssn := m.dbHandle.Copy()
-	defer ssn.Close()
-
-	bulk := ssn.DB(m.config.S.MongoDB.MetaDB).C(m.config.T.Meta.FilesTable).Bulk()
-	bulk.Unordered()
 
 	//construct the interface slice for bulk
 	interfaceSlice := make([]interface{}, len(files))
@@ -562,8 +843,9 @@ func (m *MetaDB) AddNewFilesToIndex(files []*files.IndexedFile) error {
 		interfaceSlice[i] = *d
 	}
 
-	bulk.Insert(interfaceSlice...)
-	_, err := bulk.Run()
+	_, err := m.collection(m.config.T.Meta.FilesTable).InsertMany(
+		m.dbHandle.Context(), interfaceSlice, options.InsertMany().SetOrdered(false),
+	)
 	if err != nil {
 		m.log.WithFields(log.Fields{
 			"error": err.Error(),
@@ -613,11 +895,9 @@ func (m *MetaDB) FilterOutPreviouslyIndexedFiles(indexedFiles []*files.IndexedFi
 func (m *MetaDB) RemoveFilesByChunk(database string, cid int) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
-	ssn := m.dbHandle.Copy()
-	defer ssn.Close()
 
-	_, err := ssn.DB(m.config.S.MongoDB.MetaDB).C(m.config.T.Meta.FilesTable).
-		RemoveAll(bson.M{"database": database, "cid": cid})
+	_, err := m.collection(m.config.T.Meta.FilesTable).
+		DeleteMany(m.dbHandle.Context(), bson.M{"database": database, "cid": cid})
 	if err != nil {
 		m.log.WithFields(log.Fields{
 			"database": database,
@@ -628,3 +908,25 @@ func (m *MetaDB) RemoveFilesByChunk(database string, cid int) error {
 	}
 	return nil
 }
+
+//CommitStagedFiles moves the FilesTable entries of a finished batch from the staged chunk ID
+//into the chunk they were imported into
+func (m *MetaDB) CommitStagedFiles(database string, staged int, cid int) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, err := m.collection(m.config.T.Meta.FilesTable).UpdateMany(
+		m.dbHandle.Context(),
+		bson.M{"database": database, "cid": staged},
+		bson.M{"$set": bson.M{"cid": cid}},
+	)
+	if err != nil {
+		m.log.WithFields(log.Fields{
+			"database": database,
+			"cid":      cid,
+			"error":    err.Error(),
+		}).Error("could not move staged files into their chunk in the meta database")
+		return err
+	}
+	return nil
+}
@@ -4,13 +4,14 @@ import (
 	"sync"
 
 	"github.com/activecm/rita/config"
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/bson"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type (
-	// BulkChange represents mgo upserts, updates, and removals
+	// BulkChange represents MongoDB upserts, updates, and removals
 	BulkChange struct {
 		Selector  interface{} // The selector document
 		Update    interface{} // The update document if updating the document
@@ -22,8 +23,8 @@ type (
 	// BulkChanges is a map of collections to the changes that should be applied to each one
 	BulkChanges map[string][]BulkChange
 
-	// MgoBulkWriter is a pipeline worker which properly batches bulk updates for MongoDB
-	MgoBulkWriter struct {
+	// MongoBulkWriter is a pipeline worker which properly batches bulk updates for MongoDB
+	MongoBulkWriter struct {
 		db           *DB              // provides access to MongoDB
 		conf         *config.Config   // contains details needed to access MongoDB
 		log          *log.Logger      // main logger for RITA
@@ -46,40 +47,42 @@ func (m BulkChange) Size(buffer []byte) ([]byte, int) {
 	}
 
 	if m.Selector != nil {
-		buffer, _ = bson.MarshalBuffer(m.Selector, buffer)
+		buffer, _ = bson.MarshalAppend(buffer, m.Selector)
 		size += len(buffer)
 		buffer = buffer[:0]
 	}
 	if m.Update != nil {
-		buffer, _ = bson.MarshalBuffer(m.Update, buffer)
+		buffer, _ = bson.MarshalAppend(buffer, m.Update)
 		size += len(buffer)
 		buffer = buffer[:0]
 	}
 	return buffer, size
 }
 
-// Apply adds the change described to a bulk buffer
-func (m BulkChange) Apply(bulk *mgo.Bulk) {
+// Model converts the change described into a write model for a bulk write.
+// Nil is returned if the change does not describe a write.
+func (m BulkChange) Model() mongo.WriteModel {
 	if m.Selector == nil {
-		return // can't describe a change without a selector
+		return nil // can't describe a change without a selector
 	}
 
 	if m.Remove && m.SelectAll {
-		bulk.RemoveAll(m.Selector)
+		return mongo.NewDeleteManyModel().SetFilter(m.Selector)
 	} else if m.Remove /*&& !m.SelectAll*/ {
-		bulk.Remove(m.Selector)
+		return mongo.NewDeleteOneModel().SetFilter(m.Selector)
 	} else if m.Update != nil && m.Upsert {
-		bulk.Upsert(m.Selector, m.Update)
+		return mongo.NewUpdateOneModel().SetFilter(m.Selector).SetUpdate(m.Update).SetUpsert(true)
 	} else if m.Update != nil && m.SelectAll {
-		bulk.UpdateAll(m.Selector, m.Update)
+		return mongo.NewUpdateManyModel().SetFilter(m.Selector).SetUpdate(m.Update)
 	} else if m.Update != nil /*&& !m.Upsert && !m.SelectAll*/ {
-		bulk.Update(m.Selector, m.Update)
+		return mongo.NewUpdateOneModel().SetFilter(m.Selector).SetUpdate(m.Update)
 	}
+	return nil
 }
 
 // NewBulkWriter creates a new writer object to write output data to collections
-func NewBulkWriter(db *DB, conf *config.Config, log *log.Logger, unorderedWritesOK bool, writerName string) *MgoBulkWriter {
-	return &MgoBulkWriter{
+func NewBulkWriter(db *DB, conf *config.Config, log *log.Logger, unorderedWritesOK bool, writerName string) *MongoBulkWriter {
+	return &MongoBulkWriter{
 		db:           db,
 		conf:         conf,
 		log:          log,
@@ -95,84 +98,72 @@ func NewBulkWriter(db *DB, conf *config.Config, log *log.Logger, unorderedWrites
 }
 
 // Collect sends a group of results to the writer for writing out to the database
-func (w *MgoBulkWriter) Collect(data BulkChanges) {
+func (w *MongoBulkWriter) Collect(data BulkChanges) {
 	w.writeChannel <- data
 }
 
 // close waits for the write threads to finish
-func (w *MgoBulkWriter) Close() {
+func (w *MongoBulkWriter) Close() {
 	close(w.writeChannel)
 	w.writeWg.Wait()
 }
 
 // start kicks off a new write thread
-func (w *MgoBulkWriter) Start() {
+func (w *MongoBulkWriter) Start() {
 	w.writeWg.Add(1)
 	go func() {
-		ssn := w.db.Session.Copy()
-		defer ssn.Close()
-
-		bulkBuffers := map[string]*mgo.Bulk{} // stores a mgo.Bulk buffer for each collection
-		bulkBufferSizes := map[string]int{}   // stores the size in bytes of the BSON documents in each mgo.Bulk buffer
-		bulkBufferLengths := map[string]int{} // stores the number of changes stored in each mgo.Bulk buffer
-		var sizeBuffer []byte                 // used (and re-used) for BSON serialization in order to calculate the size of each BSON doc
-		var changeSize int                    // holds the total size of each BSON serialized change before being added to bulkBufferSizes
+		ctx := w.db.Context()
+		bulkOptions := options.BulkWrite().SetOrdered(!w.unordered) // if the order in which the updates occur doesn't matter, allow MongoDB to apply the updates in parallel
+
+		bulkBuffers := map[string][]mongo.WriteModel{} // stores a buffer of write models for each collection
+		bulkBufferSizes := map[string]int{}            // stores the size in bytes of the BSON documents in each bulk buffer
+		var sizeBuffer []byte                          // used (and re-used) for BSON serialization in order to calculate the size of each BSON doc
+		var changeSize int                             // holds the total size of each BSON serialized change before being added to bulkBufferSizes
+
+		// runBulk applies the changes buffered for a collection to MongoDB
+		runBulk := func(tgtColl string) {
+			if len(bulkBuffers[tgtColl]) == 0 {
+				return
+			}
+			info, err := w.db.Collection(tgtColl).BulkWrite(ctx, bulkBuffers[tgtColl], bulkOptions)
+			if err != nil {
+				w.log.WithFields(log.Fields{
+					"Module":     w.writerName,
+					"Collection": tgtColl,
+					"Info":       info,
+				}).Error(err)
+			}
+			// make sure to reset the stats we are tracking about the bulk buffer
+			bulkBuffers[tgtColl] = bulkBuffers[tgtColl][:0]
+			bulkBufferSizes[tgtColl] = 0
+		}
 
 		for data := range w.writeChannel { // process data as it streams into the writer
 			for tgtColl, bulkChanges := range data { // loop through each collection that needs updated
-
-				// initialize/ grab the bulk buffer associated with this collection
-				bulkBuffer, bufferExists := bulkBuffers[tgtColl]
-				if !bufferExists {
-					bulkBuffer = ssn.DB(w.db.GetSelectedDB()).C(tgtColl).Bulk()
-					if w.unordered {
-						// if the order in which the updates occur doesn't matter, allow MongoDB to apply the updates in parallel
-						bulkBuffer.Unordered()
-					}
-					bulkBuffers[tgtColl] = bulkBuffer
-				}
-
 				for _, change := range bulkChanges { // loop through each change that needs to be applied to the collection
+					model := change.Model()
+					if model == nil {
+						continue
+					}
 					sizeBuffer, changeSize = change.Size(sizeBuffer)
 
 					// if the bulk buffer has already reached the max number of changes or
 					// if the total size of the bulk buffer would exceed the max size after inserting the current change
 					// run the existing bulk buffer against MongoDB
-					if bulkBufferLengths[tgtColl] >= w.maxBulkCount || bulkBufferSizes[tgtColl]+changeSize >= w.maxBulkSize {
-						info, err := bulkBuffer.Run()
-						if err != nil {
-							w.log.WithFields(log.Fields{
-								"Module":     w.writerName,
-								"Collection": tgtColl,
-								"Info":       info,
-							}).Error(err)
-						}
-						// make sure to reset the stats we are tracking about the bulk buffer
-						bulkBufferLengths[tgtColl] = 0
-						bulkBufferSizes[tgtColl] = 0
+					if len(bulkBuffers[tgtColl]) >= w.maxBulkCount || bulkBufferSizes[tgtColl]+changeSize >= w.maxBulkSize {
+						runBulk(tgtColl)
 					}
 
 					// insert the change into the bulk buffer and update the stats we are tracking about the bulk buffer
-					change.Apply(bulkBuffer)
-					bulkBufferLengths[tgtColl]++
+					bulkBuffers[tgtColl] = append(bulkBuffers[tgtColl], model)
 					bulkBufferSizes[tgtColl] += changeSize
 				}
 			}
 		}
 
 		// after the writer is done receiving inputs, make sure to drain all of the buffers before exiting
-		for tgtColl, bulkBuffer := range bulkBuffers {
-			info, err := bulkBuffer.Run()
-			if err != nil {
-				w.log.WithFields(log.Fields{
-					"Module":     w.writerName,
-					"Collection": tgtColl,
-					"Info":       info,
-				}).Error(err)
-			}
-
-			bulkBufferLengths[tgtColl] = 0
-			bulkBufferSizes[tgtColl] = 0
+		for tgtColl := range bulkBuffers {
+			runBulk(tgtColl)
 		}
 		w.writeWg.Done()
 	}()
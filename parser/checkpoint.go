@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/remover"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SetResumeCheckpoint continues the interrupted import described by the checkpoint
+// instead of starting a new one. The files which were already imported are skipped
+// and an unfinished batch is rolled back before the import continues.
+func (fs *FSImporter) SetResumeCheckpoint(checkpoint *database.ImportCheckpoint) {
+	fs.resume = checkpoint
+}
+
+// StopOnInterrupt makes the import finish the batch it is working on when the process is
+// interrupted, so that the import can be resumed later. Interrupting the process a second
+// time stops it immediately.
+func (fs *FSImporter) StopOnInterrupt() {
+	fs.stopOnInterrupt = true
+}
+
+// isStopping checks whether the import was asked to stop after the current batch
+func (fs *FSImporter) isStopping() bool {
+	return atomic.LoadInt32(&fs.stopping) == 1
+}
+
+// handleInterrupts watches for interrupts while the import runs and returns a function
+// which stops watching
+func (fs *FSImporter) handleInterrupts() func() {
+	signals := make(chan os.Signal, 2)
+	done := make(chan struct{})
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+				if atomic.CompareAndSwapInt32(&fs.stopping, 0, 1) {
+					fmt.Println("\n\t[!] Stopping once the current batch is finished. Interrupt again to stop immediately.")
+					continue
+				}
+				fs.log.WithField("database", fs.database.GetSelectedDB()).Warn("Import stopped during a batch")
+				fmt.Println("\n\t[!] Import stopped during a batch. Run the import again with --resume to roll back the batch and continue.")
+				os.Exit(1)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// settleInterruptedBatch finishes or rolls back the batch an interrupted import was
+// working on. A batch which was being committed is committed again, since its files are
+// already indexed, while the staged results of any other unfinished batch are removed so
+// that its files are imported again. The batches before it are kept.
+func (fs *FSImporter) settleInterruptedBatch() error {
+	checkpoint := fs.resume
+	if checkpoint == nil {
+		var err error
+		checkpoint, err = fs.metaDB.GetImportCheckpoint(fs.database.GetSelectedDB())
+		if err != nil {
+			return err
+		}
+	}
+	if checkpoint == nil || !checkpoint.BatchInProgress {
+		return nil
+	}
+
+	if checkpoint.Committing {
+		fmt.Printf("\t[-] Committing batch %d of the interrupted import into chunk %d ... \n",
+			checkpoint.BatchesDone+1, checkpoint.CID)
+		return fs.commitBatch(checkpoint.CID)
+	}
+
+	fmt.Printf("\t[-] Rolling back batch %d of the interrupted import since it did not finish ... \n",
+		checkpoint.BatchesDone+1)
+	removerRepo := remover.NewMongoRemover(fs.database, fs.config, fs.log)
+	err := removerRepo.RemoveStaged(database.StagedChunk, checkpoint.CID)
+	if err != nil {
+		return err
+	}
+	return fs.metaDB.RemoveFilesByChunk(fs.database.GetSelectedDB(), database.StagedChunk)
+}
+
+// commitBatch moves the staged results and file records of a finished batch into its chunk
+func (fs *FSImporter) commitBatch(cid int) error {
+	removerRepo := remover.NewMongoRemover(fs.database, fs.config, fs.log)
+	err := removerRepo.CommitStaged(database.StagedChunk, cid)
+	if err != nil {
+		return err
+	}
+	return fs.metaDB.CommitStagedFiles(fs.database.GetSelectedDB(), database.StagedChunk, cid)
+}
+
+// localHostsInChunk finds the internal hosts which were seen by the finished batches
+// of a chunk so they can be scored along with those of the remaining batches
+func (fs *FSImporter) localHostsInChunk(cid int) map[string]data.UniqueIP {
+	localHosts, err := fs.findLocalHosts(bson.M{"cid": cid})
+	if err != nil {
+		fs.log.WithFields(log.Fields{
+			"cid":   cid,
+			"error": err.Error(),
+		}).Error("Could not find the internal hosts of the interrupted import")
+	}
+	return localHosts
+}
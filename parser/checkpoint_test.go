@@ -0,0 +1,24 @@
+package parser
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleInterrupts(t *testing.T) {
+	fs := &FSImporter{}
+	fs.StopOnInterrupt()
+	assert.True(t, fs.stopOnInterrupt)
+
+	stop := fs.handleInterrupts()
+	defer stop()
+	assert.False(t, fs.isStopping())
+
+	// the first interrupt only asks the import to stop after the current batch
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGINT))
+	assert.Eventually(t, fs.isStopping, time.Second, 10*time.Millisecond)
+}
@@ -14,7 +14,7 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func parseConnEntry(parseConn *parsetypes.Conn, filter filter, retVals ParseResults, logger *log.Logger) {
+func parseConnEntry(parseConn *parsetypes.Conn, filter filter, retVals ParseResults, logger *log.Logger) entryOutcome {
 
 	// get source destination pair for connection record
 	src := parseConn.Source
@@ -31,15 +31,15 @@ func parseConnEntry(parseConn *parsetypes.Conn, filter filter, retVals ParseResu
 			"src": parseConn.Source,
 			"dst": parseConn.Destination,
 		}).Error("Unable to parse valid ip address pair from conn log entry, skipping entry.")
-		return
+		return entryOutcome{rejectedFor: rejectInvalidAddress}
 	}
 
 	// Run conn pair through filter to filter out certain connections
-	ignore := filter.filterConnPair(srcIP, dstIP)
+	filteredBy := filter.connPairFilterRule(srcIP, dstIP)
 
 	// If connection pair is not subject to filtering, process
-	if ignore {
-		return
+	if filteredBy != "" {
+		return entryOutcome{filteredBy: filteredBy}
 	}
 
 	// disambiguate addresses which are not publicly routable
@@ -74,6 +74,8 @@ func parseConnEntry(parseConn *parsetypes.Conn, filter filter, retVals ParseResu
 	updateCertificatesByConn(dstKey, tuple, retVals)
 
 	updateZeekUIDRecordsByConn(parseConn.UID, parseConn.OrigIPBytes, parseConn.RespBytes, roundedDuration, retVals)
+
+	return entryOutcome{}
 }
 
 func updateUniqueConnectionsByConn(srcIP, dstIP net.IP, srcDstPair data.UniqueIPPair, srcDstKey string,
@@ -139,7 +141,7 @@ func updateUniqueConnectionsByConn(srcIP, dstIP net.IP, srcDstPair data.UniqueIP
 
 	// ///// APPEND TIMESTAMP TO UNIQUE CONNECTION TIMESTAMP LIST /////
 	retVals.UniqueConnMap[srcDstKey].TsList = append(
-		retVals.UniqueConnMap[srcDstKey].TsList, parseConn.TimeStamp,
+		retVals.UniqueConnMap[srcDstKey].TsList, parseConn.TimeStampMillis(),
 	)
 
 	// ///// APPEND IP BYTES TO UNIQUE CONNECTION BYTES LIST /////
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"net"
+
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/lease"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func parseDHCPEntry(parseDHCP *parsetypes.DHCP, filter filter, retVals ParseResults, logger *log.Logger) entryOutcome {
+	// only an acknowledgement from the server grants an address to the client.
+	// older bro versions only log acknowledgements and don't record the message types.
+	if len(parseDHCP.MsgTypes) > 0 && !acknowledged(parseDHCP.MsgTypes) {
+		return entryOutcome{}
+	}
+
+	assignedAddr := parseDHCP.AssignedAddr
+	if assignedAddr == "" {
+		assignedAddr = parseDHCP.AssignedIP
+	}
+
+	// the lease can't be tied to a device without the device's hardware address or name,
+	// and acknowledgements of DHCPINFORM messages don't assign an address
+	if assignedAddr == "" || (parseDHCP.MAC == "" && parseDHCP.HostName == "") {
+		return entryOutcome{}
+	}
+
+	assignedIP := net.ParseIP(assignedAddr)
+	if assignedIP == nil {
+		logger.WithFields(log.Fields{
+			"mac":           parseDHCP.MAC,
+			"assigned_addr": assignedAddr,
+		}).Error("Unable to parse valid ip address from dhcp log entry, skipping entry.")
+		return entryOutcome{rejectedFor: rejectInvalidAddress}
+	}
+
+	if filteredBy := filter.singleIPFilterRule(assignedIP); filteredBy != "" {
+		return entryOutcome{filteredBy: filteredBy}
+	}
+
+	host := data.NewUniqueIP(assignedIP, parseDHCP.AgentUUID, parseDHCP.AgentHostname)
+
+	updateLeasesByDHCP(host, parseDHCP, retVals)
+
+	return entryOutcome{}
+}
+
+func updateLeasesByDHCP(host data.UniqueIP, parseDHCP *parsetypes.DHCP, retVals ParseResults) {
+	leaseTime := int64(parseDHCP.LeaseTime)
+	if leaseTime <= 0 {
+		leaseTime = lease.DefaultLeaseTime
+	}
+
+	hostKey := host.MapKey()
+
+	retVals.LeaseLock.Lock()
+	defer retVals.LeaseLock.Unlock()
+
+	if _, ok := retVals.LeaseMap[hostKey]; !ok {
+		retVals.LeaseMap[hostKey] = &lease.Input{
+			Host: host,
+		}
+	}
+
+	// ///// RECORD THE GRANT OF THE ADDRESS TO THE DEVICE /////
+	retVals.LeaseMap[hostKey].Grants = append(retVals.LeaseMap[hostKey].Grants, lease.Grant{
+		MAC:      parseDHCP.MAC,
+		Hostname: parseDHCP.HostName,
+		Start:    parseDHCP.TimeStamp,
+		End:      parseDHCP.TimeStamp + leaseTime,
+	})
+}
+
+// acknowledged determines whether the server acknowledged the client's request in a DHCP exchange
+func acknowledged(msgTypes []string) bool {
+	for _, msgType := range msgTypes {
+		if msgType == "ACK" {
+			return true
+		}
+	}
+	return false
+}
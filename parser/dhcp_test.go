@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/pkg/lease"
+	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDHCPEntries(t *testing.T) {
+	internal, _ := util.ParseSubnets([]string{"10.0.0.0/8"})
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	fs := &FSImporter{filter: filter{internal: internal}, log: logger}
+	retVals := newParseResults()
+
+	entries := []*parsetypes.DHCP{
+		{TimeStamp: 1622548800, MAC: "00:11:22:33:44:55", HostName: "laptop", AssignedAddr: "10.0.0.5", LeaseTime: 3600, MsgTypes: []string{"REQUEST", "ACK"}},
+		// the lease time defaults to a day if it isn't logged
+		{TimeStamp: 1622552400, MAC: "00:11:22:33:44:55", AssignedAddr: "10.0.0.5", MsgTypes: []string{"REQUEST", "ACK"}},
+		// older bro versions log the assigned address without the message types
+		{TimeStamp: 1622548800, MAC: "66:77:88:99:aa:bb", AssignedIP: "10.0.0.6", LeaseTime: 600},
+		// a request which wasn't acknowledged doesn't grant an address
+		{TimeStamp: 1622548800, MAC: "66:77:88:99:aa:bb", AssignedAddr: "10.0.0.7", MsgTypes: []string{"REQUEST", "NAK"}},
+		// a lease which can't be tied to a device is skipped
+		{TimeStamp: 1622548800, AssignedAddr: "10.0.0.8", MsgTypes: []string{"ACK"}},
+	}
+	for _, entry := range entries {
+		fs.parseEntry(entry, retVals, logger)
+	}
+
+	// an entry with an invalid address is skipped
+	fs.parseEntry(&parsetypes.DHCP{MAC: "66:77:88:99:aa:bb", AssignedAddr: "not an ip", MsgTypes: []string{"ACK"}}, retVals, logger)
+
+	// a lease granted outside of the imported time window is dropped
+	fs.SetTimeWindow(database.TimeWindow{Since: 1622548800})
+	fs.parseEntry(&parsetypes.DHCP{TimeStamp: 1622548799, MAC: "66:77:88:99:aa:bb", AssignedAddr: "10.0.0.9", MsgTypes: []string{"ACK"}}, retVals, logger)
+
+	require.Len(t, retVals.LeaseMap, 2)
+	for _, input := range retVals.LeaseMap {
+		switch input.Host.IP {
+		case "10.0.0.5":
+			assert.Equal(t, []lease.Grant{
+				{MAC: "00:11:22:33:44:55", Hostname: "laptop", Start: 1622548800, End: 1622552400},
+				{MAC: "00:11:22:33:44:55", Start: 1622552400, End: 1622552400 + lease.DefaultLeaseTime},
+			}, input.Grants)
+		case "10.0.0.6":
+			assert.Equal(t, []lease.Grant{
+				{MAC: "66:77:88:99:aa:bb", Start: 1622548800, End: 1622549400},
+			}, input.Grants)
+		default:
+			t.Errorf("unexpected lease for %s", input.Host.IP)
+		}
+	}
+}
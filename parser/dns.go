@@ -2,15 +2,19 @@ package parser
 
 import (
 	"net"
+	"strings"
 
 	"github.com/activecm/rita/parser/parsetypes"
 	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/dnstunnel"
 	"github.com/activecm/rita/pkg/hostname"
+	"github.com/activecm/rita/util"
 
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/publicsuffix"
 )
 
-func parseDNSEntry(parseDNS *parsetypes.DNS, filter filter, retVals ParseResults, logger *log.Logger) {
+func parseDNSEntry(parseDNS *parsetypes.DNS, filter filter, retVals ParseResults, logger *log.Logger) entryOutcome {
 	// get source destination pair
 	src := parseDNS.Source
 	dst := parseDNS.Destination
@@ -26,7 +30,7 @@ func parseDNSEntry(parseDNS *parsetypes.DNS, filter filter, retVals ParseResults
 			"src": parseDNS.Source,
 			"dst": parseDNS.Destination,
 		}).Error("Unable to parse valid ip address pair from dns log entry, skipping entry.")
-		return
+		return entryOutcome{rejectedFor: rejectInvalidAddress}
 	}
 
 	// get domain
@@ -34,17 +38,23 @@ func parseDNSEntry(parseDNS *parsetypes.DNS, filter filter, retVals ParseResults
 
 	// Run domain through filter to filter out certain domains and
 	// filter out traffic which is external -> external or external -> internal (if specified in the config file)
-	ignore := (filter.filterDomain(domain) || filter.filterDNSPair(srcIP, dstIP))
+	filteredBy := filter.domainFilterRule(domain)
+	if filteredBy == "" {
+		filteredBy = filter.dnsPairFilterRule(srcIP, dstIP)
+	}
 
 	// If domain is not subject to filtering, process
-	if ignore {
-		return
+	if filteredBy != "" {
+		return entryOutcome{filteredBy: filteredBy}
 	}
 
 	srcUniqIP := data.NewUniqueIP(srcIP, parseDNS.AgentUUID, parseDNS.AgentHostname)
 
 	updateExplodedDNSbyDNS(domain, retVals)
 	updateHostnamesByDNS(srcUniqIP, domain, parseDNS, retVals)
+	updateDNSTunnelByDNS(srcUniqIP, domain, parseDNS, retVals)
+
+	return entryOutcome{}
 }
 
 func updateExplodedDNSbyDNS(domain string, retVals ParseResults) {
@@ -83,3 +93,76 @@ func updateHostnamesByDNS(srcUniqIP data.UniqueIP, domain string, parseDNS *pars
 		}
 	}
 }
+
+func updateDNSTunnelByDNS(srcUniqIP data.UniqueIP, domain string, parseDNS *parsetypes.DNS, retVals ParseResults) {
+
+	name := strings.TrimSuffix(strings.ToLower(domain), ".")
+
+	// reverse lookups are not useful for tunnel detection
+	if name == "" || strings.HasSuffix(name, ".arpa") {
+		return
+	}
+
+	// group queries by their registered domain so that the subdomains
+	// carrying the encoded data can be examined together
+	base, err := publicsuffix.EffectiveTLDPlusOne(name)
+	if err != nil {
+		return
+	}
+	subdomain := strings.TrimSuffix(strings.TrimSuffix(name, base), ".")
+
+	var longestLabel int64
+	for _, label := range strings.Split(subdomain, ".") {
+		if int64(len(label)) > longestLabel {
+			longestLabel = int64(len(label))
+		}
+	}
+
+	var answerBytes int64
+	for _, answer := range parseDNS.Answers {
+		answerBytes += int64(len(answer))
+	}
+
+	retVals.DNSTunnelLock.Lock()
+	defer retVals.DNSTunnelLock.Unlock()
+
+	if _, ok := retVals.DNSTunnelMap[base]; !ok {
+		retVals.DNSTunnelMap[base] = &dnstunnel.Input{
+			Domain:           base,
+			Subdomains:       make(data.StringSet),
+			ClientSubdomains: make(map[string]data.StringSet),
+		}
+	}
+	tunnel := retVals.DNSTunnelMap[base]
+
+	tunnel.QueryCount++
+
+	// ///// UNION SUBDOMAIN INTO THE DOMAIN AND CLIENT SUBDOMAIN SETS /////
+	if subdomain != "" {
+		tunnel.Subdomains.Insert(subdomain)
+
+		clientKey := srcUniqIP.MapKey()
+		if _, ok := tunnel.ClientSubdomains[clientKey]; !ok {
+			tunnel.ClientSubdomains[clientKey] = make(data.StringSet)
+		}
+		tunnel.ClientSubdomains[clientKey].Insert(subdomain)
+	}
+
+	tunnel.EntropySum += util.ShannonEntropy(subdomain)
+	tunnel.LabelLengthSum += longestLabel
+	if longestLabel > tunnel.MaxLabelLength {
+		tunnel.MaxLabelLength = longestLabel
+	}
+
+	switch parseDNS.QTypeName {
+	case "TXT":
+		tunnel.TXTCount++
+	case "NULL":
+		tunnel.NULLCount++
+	case "CNAME":
+		tunnel.CNAMECount++
+	}
+
+	tunnel.QueryBytes += int64(len(name))
+	tunnel.AnswerBytes += answerBytes
+}
@@ -0,0 +1,174 @@
+package parser
+
+import (
+	"net"
+	"strings"
+
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/filetransfer"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func parseFilesEntry(parseFiles *parsetypes.Files, iocHashes filetransfer.IOCHashes, filter filter,
+	retVals ParseResults, logger *log.Logger) entryOutcome {
+	src, dst, uids := fileTransferHosts(parseFiles)
+
+	// parse addresses into binary format
+	srcIP := net.ParseIP(src)
+	dstIP := net.ParseIP(dst)
+
+	// verify that both addresses were able to be parsed successfully
+	if (srcIP == nil) || (dstIP == nil) {
+		logger.WithFields(log.Fields{
+			"fuid": parseFiles.FUID,
+			"src":  src,
+			"dst":  dst,
+		}).Error("Unable to parse valid ip address pair from files log entry, skipping entry.")
+		return entryOutcome{rejectedFor: rejectInvalidAddress}
+	}
+
+	// Run conn pair through filter to filter out certain connections
+	if filteredBy := filter.connPairFilterRule(srcIP, dstIP); filteredBy != "" {
+		return entryOutcome{filteredBy: filteredBy}
+	}
+
+	// certificates exchanged during TLS handshakes are logged as files as well
+	if isCertificateMimeType(parseFiles.MimeType) {
+		return entryOutcome{}
+	}
+
+	srcUniqIP := data.NewUniqueIP(srcIP, parseFiles.AgentUUID, parseFiles.AgentHostname)
+	dstUniqIP := data.NewUniqueIP(dstIP, parseFiles.AgentUUID, parseFiles.AgentHostname)
+	srcDstPair := data.NewUniqueIPPair(srcUniqIP, dstUniqIP)
+
+	// the originator of the connection sent the file if it is an upload
+	direction := filetransfer.DirectionDownload
+	if parseFiles.IsOrig {
+		direction = filetransfer.DirectionUpload
+		updateFileUploadsByFiles(srcDstPair, uids, parseFiles, retVals)
+	}
+
+	category := filetransfer.Category(parseFiles.MimeType, parseFiles.Filename)
+	ioc, _ := iocHashes.Match(parseFiles.MD5, parseFiles.SHA1, parseFiles.SHA256)
+
+	// only risky downloads and files matching an IOC are recorded individually
+	if ioc != "" || (category != "" && direction == filetransfer.DirectionDownload) {
+		updateFileTransfersByFiles(srcDstPair, uids, direction, category, ioc, parseFiles, retVals)
+	}
+
+	return entryOutcome{}
+}
+
+// fileTransferHosts returns the originator and responder of the connection which carried
+// the file along with the connection's Zeek UIDs. Older versions of Zeek only record the
+// hosts which sent and received the file.
+func fileTransferHosts(parseFiles *parsetypes.Files) (string, string, []string) {
+	if parseFiles.UID != "" || parseFiles.Source != "" {
+		return parseFiles.Source, parseFiles.Destination, []string{parseFiles.UID}
+	}
+
+	if len(parseFiles.TxHosts) == 0 || len(parseFiles.RxHosts) == 0 {
+		return "", "", parseFiles.ConnUIDs
+	}
+
+	if parseFiles.IsOrig {
+		return parseFiles.TxHosts[0], parseFiles.RxHosts[0], parseFiles.ConnUIDs
+	}
+	return parseFiles.RxHosts[0], parseFiles.TxHosts[0], parseFiles.ConnUIDs
+}
+
+// isCertificateMimeType checks whether the MIME type belongs to an X.509 certificate
+func isCertificateMimeType(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "application/x-x509") || strings.HasPrefix(mimeType, "application/pkix")
+}
+
+func updateFileTransfersByFiles(srcDstPair data.UniqueIPPair, uids []string, direction, category, ioc string,
+	parseFiles *parsetypes.Files, retVals ParseResults) {
+
+	retVals.FileTransferLock.Lock()
+	defer retVals.FileTransferLock.Unlock()
+
+	if _, ok := retVals.FileTransferMap[parseFiles.FUID]; !ok {
+		retVals.FileTransferMap[parseFiles.FUID] = &filetransfer.Input{
+			FUID:      parseFiles.FUID,
+			Hosts:     srcDstPair,
+			UIDs:      make(data.StringSet),
+			Direction: direction,
+			Source:    parseFiles.FileSource,
+			MimeType:  parseFiles.MimeType,
+			Filename:  parseFiles.Filename,
+			Category:  category,
+			MD5:       parseFiles.MD5,
+			SHA1:      parseFiles.SHA1,
+			SHA256:    parseFiles.SHA256,
+			IOC:       ioc,
+			TimeStamp: parseFiles.TimeStamp,
+		}
+	}
+
+	transfer := retVals.FileTransferMap[parseFiles.FUID]
+
+	// ///// UNION CONNECTIONS WHICH CARRIED THE FILE /////
+	for _, uid := range uids {
+		if uid != "" {
+			transfer.UIDs.Insert(uid)
+		}
+	}
+
+	if parseFiles.SeenBytes > transfer.Bytes {
+		transfer.Bytes = parseFiles.SeenBytes
+	}
+}
+
+func updateFileUploadsByFiles(srcDstPair data.UniqueIPPair, uids []string, parseFiles *parsetypes.Files, retVals ParseResults) {
+	srcDstKey := srcDstPair.MapKey()
+
+	retVals.FileUploadLock.Lock()
+	defer retVals.FileUploadLock.Unlock()
+
+	if _, ok := retVals.FileUploadMap[srcDstKey]; !ok {
+		retVals.FileUploadMap[srcDstKey] = &filetransfer.UploadInput{
+			Hosts:     srcDstPair,
+			UIDs:      make(data.StringSet),
+			FirstSeen: parseFiles.TimeStamp,
+			LastSeen:  parseFiles.TimeStamp,
+		}
+	}
+
+	upload := retVals.FileUploadMap[srcDstKey]
+
+	// ///// RECORD THE UPLOADED FILE /////
+	upload.Files++
+	upload.Bytes += parseFiles.SeenBytes
+
+	for _, uid := range uids {
+		if uid != "" {
+			upload.UIDs.Insert(uid)
+		}
+	}
+
+	if parseFiles.TimeStamp < upload.FirstSeen {
+		upload.FirstSeen = parseFiles.TimeStamp
+	}
+	if parseFiles.TimeStamp > upload.LastSeen {
+		upload.LastSeen = parseFiles.TimeStamp
+	}
+}
+
+// updateFileSessions records the server name requested over a connection so the files
+// it carried can be linked to the server
+func updateFileSessions(uid, fqdn, protocol string, retVals ParseResults) {
+	if uid == "" || fqdn == "" {
+		return
+	}
+
+	retVals.FileSessionLock.Lock()
+	defer retVals.FileSessionLock.Unlock()
+
+	// keep the first server name if a connection carried several HTTP requests
+	if _, ok := retVals.FileSessionMap[uid]; !ok {
+		retVals.FileSessionMap[uid] = &filetransfer.Session{FQDN: fqdn, Protocol: protocol}
+	}
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/pkg/filetransfer"
+	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilesEntries(t *testing.T) {
+	internal, _ := util.ParseSubnets([]string{"10.0.0.0/8"})
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	fs := &FSImporter{
+		filter:        filter{internal: internal},
+		log:           logger,
+		fileTransfers: true,
+		iocHashes:     filetransfer.IOCHashes{"0123456789abcdef0123456789abcdef": "known dropper"},
+	}
+	retVals := newParseResults()
+
+	entries := []parsetypes.BroData{
+		&parsetypes.HTTP{UID: "CHTTP1", Source: "10.0.0.5", Destination: "93.184.216.34", Host: "dl.example.com", Method: "GET"},
+		&parsetypes.HTTP{UID: "CHTTP2", Source: "10.0.0.5", Destination: "93.184.216.35", URI: "http://upload.example.com/form", Method: "POST"},
+		// a downloaded executable
+		&parsetypes.Files{TimeStamp: 1622548801, FUID: "FEXE", UID: "CHTTP1", Source: "10.0.0.5", Destination: "93.184.216.34",
+			FileSource: "HTTP", MimeType: "application/x-dosexec", SeenBytes: 2048},
+		// a downloaded image matching an IOC
+		&parsetypes.Files{TimeStamp: 1622548802, FUID: "FIOC", UID: "CHTTP1", Source: "10.0.0.5", Destination: "93.184.216.34",
+			FileSource: "HTTP", MimeType: "image/png", MD5: "0123456789ABCDEF0123456789ABCDEF", SeenBytes: 512},
+		// a downloaded image which isn't recorded
+		&parsetypes.Files{TimeStamp: 1622548803, FUID: "FPNG", UID: "CHTTP1", Source: "10.0.0.5", Destination: "93.184.216.34",
+			FileSource: "HTTP", MimeType: "image/png", SeenBytes: 512},
+		// uploads logged by an older version of Zeek
+		&parsetypes.Files{TimeStamp: 1622548805, FUID: "FUP1", TxHosts: []string{"10.0.0.5"}, RxHosts: []string{"93.184.216.35"},
+			ConnUIDs: []string{"CHTTP2"}, FileSource: "HTTP", IsOrig: true, SeenBytes: 1000},
+		&parsetypes.Files{TimeStamp: 1622548804, FUID: "FUP2", TxHosts: []string{"10.0.0.5"}, RxHosts: []string{"93.184.216.35"},
+			ConnUIDs: []string{"CHTTP2"}, FileSource: "HTTP", IsOrig: true, SeenBytes: 3000},
+		// certificates exchanged during TLS handshakes are skipped
+		&parsetypes.Files{TimeStamp: 1622548806, FUID: "FCERT", UID: "CSSL1", Source: "10.0.0.5", Destination: "93.184.216.36",
+			FileSource: "SSL", MimeType: "application/x-x509-user-cert", IsOrig: true, SeenBytes: 900},
+		// files transferred between internal hosts are filtered
+		&parsetypes.Files{TimeStamp: 1622548807, FUID: "FINT", UID: "CSMB1", Source: "10.0.0.5", Destination: "10.0.0.6",
+			FileSource: "SMB", MimeType: "application/x-dosexec", SeenBytes: 4096},
+	}
+	for _, entry := range entries {
+		fs.parseEntry(entry, retVals, logger)
+	}
+
+	require.Len(t, retVals.FileTransferMap, 2)
+
+	exe := retVals.FileTransferMap["FEXE"]
+	require.NotNil(t, exe)
+	assert.Equal(t, filetransfer.DirectionDownload, exe.Direction)
+	assert.Equal(t, filetransfer.CategoryExecutable, exe.Category)
+	assert.Equal(t, "10.0.0.5", exe.Hosts.SrcIP)
+	assert.Equal(t, "93.184.216.34", exe.Hosts.DstIP)
+	assert.Equal(t, int64(2048), exe.Bytes)
+	assert.True(t, exe.UIDs.Contains("CHTTP1"))
+
+	ioc := retVals.FileTransferMap["FIOC"]
+	require.NotNil(t, ioc)
+	assert.Equal(t, "known dropper", ioc.IOC)
+	assert.Equal(t, "", ioc.Category)
+
+	require.Len(t, retVals.FileUploadMap, 1)
+	for _, upload := range retVals.FileUploadMap {
+		assert.Equal(t, "10.0.0.5", upload.Hosts.SrcIP)
+		assert.Equal(t, "93.184.216.35", upload.Hosts.DstIP)
+		assert.Equal(t, int64(2), upload.Files)
+		assert.Equal(t, int64(4000), upload.Bytes)
+		assert.Equal(t, int64(1622548804), upload.FirstSeen)
+		assert.Equal(t, int64(1622548805), upload.LastSeen)
+		assert.True(t, upload.UIDs.Contains("CHTTP2"))
+	}
+
+	// the HTTP sessions link the files to the servers they were transferred with
+	require.Contains(t, retVals.FileSessionMap, "CHTTP1")
+	assert.Equal(t, filetransfer.Session{FQDN: "dl.example.com", Protocol: "http"}, *retVals.FileSessionMap["CHTTP1"])
+	require.Contains(t, retVals.FileSessionMap, "CHTTP2")
+	assert.Equal(t, "upload.example.com", retVals.FileSessionMap["CHTTP2"].FQDN)
+}
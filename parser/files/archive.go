@@ -0,0 +1,149 @@
+package files
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/activecm/rita/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// isArchive checks whether the file name has the extension of a tar archive,
+// which may be compressed with any of the supported compression formats
+func isArchive(name string) bool {
+	if strings.HasSuffix(name, ".tgz") {
+		return true
+	}
+	for _, suffix := range compressionSuffixes {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	return strings.HasSuffix(name, ".tar")
+}
+
+// walkArchive calls visit with the header and contents of each regular file in a tar archive
+func walkArchive(archivePath string, visit func(header *tar.Header, member io.Reader) error) error {
+	fileHandle, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+
+	reader, closer, err := newDecompressedReader(fileHandle, archivePath)
+	defer closer() // handles closing the underlying fileHandle (and any associate subprocesses)
+	if err != nil {
+		return err
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err = visit(header, tarReader); err != nil {
+			return err
+		}
+	}
+}
+
+// indexArchive indexes each of the logs in a tar archive as a separate file
+func indexArchive(archivePath string, targetDB string, targetCID int,
+	logger *log.Logger, conf *config.Config) ([]*IndexedFile, error) {
+
+	var toReturn []*IndexedFile
+	err := walkArchive(archivePath, func(header *tar.Header, member io.Reader) error {
+		if !isLogFile(header.Name) {
+			return nil
+		}
+
+		indexedFile, err := newIndexedMember(archivePath, header, member, logger, conf)
+		if err != nil {
+			// the member is likely unsupported or empty
+			logger.WithFields(log.Fields{
+				"file":   archivePath,
+				"member": header.Name,
+				"error":  err.Error(),
+			}).Debug("An error was encountered while indexing an archive member.")
+			return nil
+		}
+		indexedFile.TargetDatabase = targetDB
+		indexedFile.CID = targetCID
+		toReturn = append(toReturn, indexedFile)
+		return nil
+	})
+	return toReturn, err
+}
+
+// newIndexedMember parses out the metadata of a log stored in a tar archive
+func newIndexedMember(archivePath string, header *tar.Header, member io.Reader,
+	logger *log.Logger, conf *config.Config) (*IndexedFile, error) {
+
+	toReturn := new(IndexedFile)
+	toReturn.Path = archivePath
+	toReturn.Member = header.Name
+	toReturn.Length = header.Size
+	toReturn.ModTime = header.ModTime
+
+	// hash the start of the member in the same manner as getFileHash, so that a log
+	// is recognized whether or not it was imported from an archive
+	start := make([]byte, 15000)
+	n, err := io.ReadFull(member, start)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return toReturn, err
+	}
+	start = start[:n]
+	toReturn.Hash = fmt.Sprintf("%x", md5.Sum(start))
+
+	stream := ioutil.NopCloser(io.MultiReader(bytes.NewReader(start), member))
+	scanner, closeScanner, err := newLogScanner(stream, header.Name)
+	defer closeScanner() // handles closing any associated subprocesses
+	if err != nil {
+		return toReturn, err
+	}
+
+	err = indexLog(toReturn, scanner, path.Base(header.Name), logger, conf)
+	return toReturn, err
+}
+
+//ScanArchive reads the logs stored in a tar archive, calling handle with a scanner
+//for each of the given indexed files. Every indexed file must come from the same archive.
+//The archive is read once regardless of how many of its members are handled.
+func ScanArchive(indexedFiles []*IndexedFile, handle func(indexedFile *IndexedFile, scanner *bufio.Scanner)) error {
+	if len(indexedFiles) == 0 {
+		return nil
+	}
+
+	members := make(map[string]*IndexedFile, len(indexedFiles))
+	for _, indexedFile := range indexedFiles {
+		members[indexedFile.Member] = indexedFile
+	}
+
+	return walkArchive(indexedFiles[0].Path, func(header *tar.Header, member io.Reader) error {
+		indexedFile, ok := members[header.Name]
+		if !ok {
+			return nil
+		}
+
+		scanner, closeScanner, err := newLogScanner(ioutil.NopCloser(member), header.Name)
+		if err != nil {
+			closeScanner()
+			return err
+		}
+		handle(indexedFile, scanner)
+		closeScanner()
+		return nil
+	})
+}
@@ -0,0 +1,203 @@
+package files
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/config"
+	pt "github.com/activecm/rita/parser/parsetypes"
+	"github.com/klauspost/compress/zstd"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConnLog = "#separator \\x09\n" +
+	"#set_separator\t,\n" +
+	"#empty_field\t(empty)\n" +
+	"#unset_field\t-\n" +
+	"#path\tconn\n" +
+	"#fields\tts\tuid\tid.orig_h\tid.orig_p\tid.resp_h\tid.resp_p\tproto\n" +
+	"#types\ttime\tstring\taddr\tport\taddr\tport\tenum\n" +
+	"1622548800.000000\tCabc\t10.0.0.5\t51000\t93.184.216.34\t443\ttcp\n" +
+	"1622548801.000000\tCdef\t10.0.0.5\t51001\t93.184.216.34\t443\ttcp\n"
+
+func testConfig() *config.Config {
+	conf := &config.Config{}
+	conf.T.Structure.ConnTable = "conn"
+	return conf
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+// writeTar writes an archive containing the given files and an empty directory
+func writeTar(t *testing.T, members map[string][]byte) []byte {
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	require.NoError(t, writer.WriteHeader(&tar.Header{Name: "2021-06-01/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for name, data := range members {
+		require.NoError(t, writer.WriteHeader(&tar.Header{
+			Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data)), ModTime: time.Unix(1622548800, 0),
+		}))
+		_, err := writer.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func readAllLines(t *testing.T, scanner *bufio.Scanner) []string {
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
+
+func TestDecompressedLogScanner(t *testing.T) {
+	dir := t.TempDir()
+	expected := readAllLines(t, bufio.NewScanner(bytes.NewReader([]byte(testConnLog))))
+
+	zstdEncoder, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	logs := map[string][]byte{
+		"conn.log":     []byte(testConnLog),
+		"conn.log.gz":  gzipBytes(t, []byte(testConnLog)),
+		"conn.log.zst": zstdEncoder.EncodeAll([]byte(testConnLog), nil),
+	}
+	// bzip2 and xz streams are created with the system tools when they are available
+	for suffix, command := range map[string]string{".bz2": "bzip2", ".xz": "xz"} {
+		if _, err := exec.LookPath(command); err != nil {
+			continue
+		}
+		cmd := exec.Command(command, "-c")
+		cmd.Stdin = bytes.NewReader([]byte(testConnLog))
+		compressed, err := cmd.Output()
+		require.NoError(t, err)
+		logs["conn.log"+suffix] = compressed
+	}
+
+	for name, data := range logs {
+		filePath := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(filePath, data, 0644))
+
+		fileHandle, err := os.Open(filePath)
+		require.NoError(t, err)
+		scanner, closer, err := GetFileScanner(fileHandle)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, readAllLines(t, scanner), name)
+		closer()
+	}
+}
+
+func TestIndexAndScanArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "zeek.tar.gz")
+	require.NoError(t, ioutil.WriteFile(archive, gzipBytes(t, writeTar(t, map[string][]byte{
+		"2021-06-01/conn.00:00:00-01:00:00.log.gz": gzipBytes(t, []byte(testConnLog)),
+		"2021-06-01/conn.01:00:00-02:00:00.log":    []byte(testConnLog),
+		"2021-06-01/notes.txt":                     []byte("not a log"),
+	})), 0644))
+
+	indexed := TryIndexFiles([]string{archive}, 2, "test", 0, log.New(), testConfig())
+	require.Len(t, indexed, 2)
+	for _, indexedFile := range indexed {
+		assert.Equal(t, archive, indexedFile.Path)
+		assert.Equal(t, "conn", indexedFile.TargetCollection)
+		assert.Equal(t, "test", indexedFile.TargetDatabase)
+		assert.Equal(t, filepath.Join(archive, indexedFile.Member), indexedFile.Name())
+		assert.NotEmpty(t, indexedFile.Hash)
+	}
+	// each member is hashed separately
+	assert.NotEqual(t, indexed[0].Hash, indexed[1].Hash)
+
+	var entries []pt.BroData
+	err := ScanArchive(indexed[:1], func(indexedFile *IndexedFile, scanner *bufio.Scanner) {
+		assert.Equal(t, indexed[0], indexedFile)
+		for scanner.Scan() {
+			// header lines are skipped by the parser
+			entry := ParseTSVLine(scanner.Text(), indexedFile.GetHeader(),
+				indexedFile.GetFieldMap(), indexedFile.GetBroDataFactory(), log.New())
+			if entry != nil {
+				entries = append(entries, entry)
+			}
+		}
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "Cabc", entries[0].(*pt.Conn).UID)
+	assert.Equal(t, int64(1622548800000), entries[0].(*pt.Conn).TimeStampMillis())
+}
+
+func TestGatherLogFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"conn.log",
+		"2021-06-01/conn.log.zst",
+		"2021-06-01/dns.log.xz",
+		"2021-06-02/conn.log.bz2",
+		"nfcapd/nfcapd.202106011200",
+		"archives/2021-06-01.tar.gz",
+		"readme.txt",
+	} {
+		filePath := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+		require.NoError(t, ioutil.WriteFile(filePath, nil, 0644))
+	}
+	logger := log.New()
+
+	assert.Equal(t, []string{filepath.Join(dir, "conn.log")},
+		GatherLogFiles([]string{dir}, GatherOptions{}, logger))
+
+	assert.ElementsMatch(t, []string{
+		filepath.Join(dir, "conn.log"),
+		filepath.Join(dir, "2021-06-01/conn.log.zst"),
+		filepath.Join(dir, "2021-06-01/dns.log.xz"),
+		filepath.Join(dir, "2021-06-02/conn.log.bz2"),
+		filepath.Join(dir, "nfcapd/nfcapd.202106011200"),
+		filepath.Join(dir, "archives/2021-06-01.tar.gz"),
+	}, GatherLogFiles([]string{dir}, GatherOptions{Recursive: true}, logger))
+
+	assert.ElementsMatch(t, []string{
+		filepath.Join(dir, "2021-06-01/conn.log.zst"),
+		filepath.Join(dir, "2021-06-01/dns.log.xz"),
+		filepath.Join(dir, "nfcapd/nfcapd.202106011200"),
+		filepath.Join(dir, "archives/2021-06-01.tar.gz"),
+	}, GatherLogFiles([]string{dir}, GatherOptions{Recursive: true, DatePattern: "2021-06-01"}, logger))
+}
+
+func TestPathDate(t *testing.T) {
+	date, ok := pathDate("/opt/zeek/logs/2021-06-01/conn.00:00:00-01:00:00.log.gz")
+	assert.True(t, ok)
+	assert.Equal(t, "2021-06-01", date)
+
+	// the file name takes precedence over its directory
+	date, ok = pathDate("/var/cache/2021-05-31/nfcapd.202106010005")
+	assert.True(t, ok)
+	assert.Equal(t, "2021-06-01", date)
+
+	_, ok = pathDate("/opt/zeek/logs/current/conn.log")
+	assert.False(t, ok)
+
+	assert.True(t, isArchive("logs.tar"))
+	assert.True(t, isArchive("logs.tar.zst"))
+	assert.True(t, isArchive("logs.tgz"))
+	assert.False(t, isArchive("conn.log.gz"))
+	assert.Error(t, ValidateDatePattern("2021-[06"))
+}
@@ -0,0 +1,331 @@
+package files
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	pt "github.com/activecm/rita/parser/parsetypes"
+
+	jsoniter "github.com/json-iterator/go"
+	log "github.com/sirupsen/logrus"
+)
+
+// eveTimeLayout is the layout Suricata uses for timestamps in EVE logs
+const eveTimeLayout = "2006-01-02T15:04:05.999999999-0700"
+
+type (
+	// eveEvent holds the fields of a Suricata EVE JSON event which map onto Zeek records
+	// https://docs.suricata.io/en/latest/output/eve/eve-json-format.html
+	eveEvent struct {
+		Timestamp string   `json:"timestamp"`
+		FlowID    int64    `json:"flow_id"`
+		EventType string   `json:"event_type"`
+		SrcIP     string   `json:"src_ip"`
+		SrcPort   int      `json:"src_port"`
+		DestIP    string   `json:"dest_ip"`
+		DestPort  int      `json:"dest_port"`
+		Proto     string   `json:"proto"`
+		AppProto  string   `json:"app_proto"`
+		Flow      *eveFlow `json:"flow"`
+		TCP       *eveTCP  `json:"tcp"`
+		DNS       *eveDNS  `json:"dns"`
+		HTTP      *eveHTTP `json:"http"`
+		TLS       *eveTLS  `json:"tls"`
+	}
+
+	// eveFlow holds the counters of a flow event
+	eveFlow struct {
+		PktsToServer  int64  `json:"pkts_toserver"`
+		PktsToClient  int64  `json:"pkts_toclient"`
+		BytesToServer int64  `json:"bytes_toserver"`
+		BytesToClient int64  `json:"bytes_toclient"`
+		Start         string `json:"start"`
+		End           string `json:"end"`
+	}
+
+	// eveTCP holds the TCP flags seen over the course of a flow
+	eveTCP struct {
+		SYN bool `json:"syn"`
+		FIN bool `json:"fin"`
+		RST bool `json:"rst"`
+	}
+
+	// eveDNS holds a DNS transaction. Version 2 logs split each transaction into
+	// query and answer events while version 3 logs request and response events.
+	eveDNS struct {
+		Type    string                   `json:"type"`
+		ID      int64                    `json:"id"`
+		RRName  string                   `json:"rrname"`
+		RRType  string                   `json:"rrtype"`
+		RCode   string                   `json:"rcode"`
+		Queries []eveDNSRecord           `json:"queries"`
+		Answers []eveDNSRecord           `json:"answers"`
+		Grouped map[string][]interface{} `json:"grouped"`
+	}
+
+	// eveDNSRecord holds a question or resource record of a DNS message
+	eveDNSRecord struct {
+		RRName string      `json:"rrname"`
+		RRType string      `json:"rrtype"`
+		TTL    float64     `json:"ttl"`
+		RData  interface{} `json:"rdata"`
+	}
+
+	// eveHTTP holds an HTTP transaction
+	eveHTTP struct {
+		Hostname  string `json:"hostname"`
+		URL       string `json:"url"`
+		UserAgent string `json:"http_user_agent"`
+		Referrer  string `json:"http_refer"`
+		Method    string `json:"http_method"`
+		Protocol  string `json:"protocol"`
+		Status    int64  `json:"status"`
+		Length    int64  `json:"length"`
+	}
+
+	// eveTLS holds the details of a TLS handshake
+	eveTLS struct {
+		Subject  string        `json:"subject"`
+		IssuerDN string        `json:"issuerdn"`
+		SNI      string        `json:"sni"`
+		Version  string        `json:"version"`
+		JA3      *eveTLSFinger `json:"ja3"`
+		JA3S     *eveTLSFinger `json:"ja3s"`
+	}
+
+	// eveTLSFinger holds a JA3 or JA3S fingerprint
+	eveTLSFinger struct {
+		Hash string `json:"hash"`
+	}
+)
+
+//ParseEVELine creates a new BroData from a line of a Suricata EVE JSON log. Flow, dns, http,
+//and tls events are mapped onto conn, dns, http, and ssl records. Nil is returned for any other event.
+func ParseEVELine(lineBuffer []byte, logger *log.Logger) pt.BroData {
+	var event eveEvent
+	err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(lineBuffer, &event)
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Encountered unparsable JSON in log")
+		return nil
+	}
+
+	switch event.EventType {
+	case "flow":
+		if event.Flow != nil {
+			return event.conn()
+		}
+	case "dns":
+		if event.DNS != nil {
+			if record := event.dns(); record != nil {
+				return record
+			}
+		}
+	case "http":
+		if event.HTTP != nil {
+			return event.http()
+		}
+	case "tls":
+		if event.TLS != nil {
+			return event.ssl()
+		}
+	}
+	return nil
+}
+
+// conn maps a flow event onto a Zeek conn record. Suricata counts the bytes of
+// whole packets, so the same counts are used for the payload and IP byte fields.
+func (e eveEvent) conn() *pt.Conn {
+	start := parseEVETime(e.Flow.Start)
+	if start.IsZero() {
+		start = parseEVETime(e.Timestamp)
+	}
+	end := parseEVETime(e.Flow.End)
+
+	var duration float64
+	if !end.IsZero() && end.After(start) {
+		duration = end.Sub(start).Seconds()
+	}
+
+	return &pt.Conn{
+		TimeStamp:       start.Unix(),
+		TimeStampMs:     start.UnixMilli(),
+		UID:             e.uid(),
+		Source:          e.SrcIP,
+		SourcePort:      e.SrcPort,
+		Destination:     e.DestIP,
+		DestinationPort: e.DestPort,
+		Proto:           e.proto(),
+		Service:         e.service(),
+		Duration:        duration,
+		OrigBytes:       e.Flow.BytesToServer,
+		RespBytes:       e.Flow.BytesToClient,
+		ConnState:       e.connState(),
+		OrigPkts:        e.Flow.PktsToServer,
+		OrigIPBytes:     e.Flow.BytesToServer,
+		RespPkts:        e.Flow.PktsToClient,
+		RespIPBytes:     e.Flow.BytesToClient,
+	}
+}
+
+// dns maps a dns response event onto a Zeek dns record. Query events are skipped
+// since the matching response repeats the question, and counting both would count
+// each lookup twice.
+func (e eveEvent) dns() *pt.DNS {
+	switch e.DNS.Type {
+	case "answer", "response":
+	default:
+		return nil
+	}
+
+	record := &pt.DNS{
+		TimeStamp:       parseEVETime(e.Timestamp).Unix(),
+		UID:             e.uid(),
+		Source:          e.SrcIP,
+		SourcePort:      e.SrcPort,
+		Destination:     e.DestIP,
+		DestinationPort: e.DestPort,
+		Proto:           e.proto(),
+		TransID:         e.DNS.ID,
+		Query:           e.DNS.RRName,
+		QTypeName:       e.DNS.RRType,
+		RCodeName:       e.DNS.RCode,
+	}
+
+	// some versions of Suricata log responses in the direction of the packet rather than
+	// the flow, so the addresses are swapped to put the client which made the request first
+	if record.SourcePort == 53 && record.DestinationPort != 53 {
+		record.Source, record.Destination = record.Destination, record.Source
+		record.SourcePort, record.DestinationPort = record.DestinationPort, record.SourcePort
+	}
+
+	if record.Query == "" && len(e.DNS.Queries) > 0 {
+		record.Query = e.DNS.Queries[0].RRName
+		record.QTypeName = e.DNS.Queries[0].RRType
+	}
+
+	for _, answer := range e.DNS.Answers {
+		if rdata, ok := answer.RData.(string); ok {
+			record.Answers = append(record.Answers, rdata)
+			record.TTLs = append(record.TTLs, answer.TTL)
+		}
+	}
+
+	// the grouped answer format is used when the detailed format is disabled
+	if len(e.DNS.Answers) == 0 {
+		for _, answers := range e.DNS.Grouped {
+			for _, answer := range answers {
+				if rdata, ok := answer.(string); ok {
+					record.Answers = append(record.Answers, rdata)
+				}
+			}
+		}
+	}
+
+	return record
+}
+
+// http maps an http event onto a Zeek http record
+func (e eveEvent) http() *pt.HTTP {
+	return &pt.HTTP{
+		TimeStamp:       parseEVETime(e.Timestamp).Unix(),
+		UID:             e.uid(),
+		Source:          e.SrcIP,
+		SourcePort:      e.SrcPort,
+		Destination:     e.DestIP,
+		DestinationPort: e.DestPort,
+		Version:         strings.TrimPrefix(e.HTTP.Protocol, "HTTP/"),
+		Method:          e.HTTP.Method,
+		Host:            e.HTTP.Hostname,
+		URI:             e.HTTP.URL,
+		Referrer:        e.HTTP.Referrer,
+		UserAgent:       e.HTTP.UserAgent,
+		RespLen:         e.HTTP.Length,
+		StatusCode:      e.HTTP.Status,
+	}
+}
+
+// ssl maps a tls event onto a Zeek ssl record
+func (e eveEvent) ssl() *pt.SSL {
+	record := &pt.SSL{
+		TimeStamp:       parseEVETime(e.Timestamp).Unix(),
+		UID:             e.uid(),
+		Source:          e.SrcIP,
+		SourcePort:      e.SrcPort,
+		Destination:     e.DestIP,
+		DestinationPort: e.DestPort,
+		// Suricata writes versions as "TLS 1.2" where Zeek writes "TLSv12"
+		Version:    strings.ReplaceAll(strings.ReplaceAll(e.TLS.Version, "TLS 1.", "TLSv1"), " ", ""),
+		ServerName: e.TLS.SNI,
+		Subject:    e.TLS.Subject,
+		Issuer:     e.TLS.IssuerDN,
+	}
+	if e.TLS.JA3 != nil {
+		record.JA3 = e.TLS.JA3.Hash
+	}
+	if e.TLS.JA3S != nil {
+		record.JA3S = e.TLS.JA3S.Hash
+	}
+	return record
+}
+
+// uid uses the flow ID to link the records of a flow together in the same way Zeek's UIDs do
+func (e eveEvent) uid() string {
+	if e.FlowID == 0 {
+		return ""
+	}
+	return strconv.FormatInt(e.FlowID, 10)
+}
+
+// proto returns the Zeek name of the transport protocol
+func (e eveEvent) proto() string {
+	proto := strings.ToLower(e.Proto)
+	if proto == "ipv6-icmp" {
+		return "icmp"
+	}
+	return proto
+}
+
+// service returns the Zeek name of the application layer protocol
+func (e eveEvent) service() string {
+	switch e.AppProto {
+	case "", "failed", "unknown":
+		return ""
+	case "tls":
+		return "ssl"
+	}
+	return e.AppProto
+}
+
+// connState approximates Zeek's connection state from the packets and TCP flags seen
+func (e eveEvent) connState() string {
+	if e.Flow.PktsToClient == 0 {
+		return "S0"
+	}
+	if e.TCP != nil {
+		if e.TCP.RST {
+			return "RSTO"
+		}
+		if !e.TCP.FIN {
+			return "S1"
+		}
+	}
+	return "SF"
+}
+
+// parseEVETime parses a timestamp from an EVE log. The zero time is returned
+// if the timestamp is missing or malformed.
+func parseEVETime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(eveTimeLayout, value); err == nil {
+		return t.UTC()
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.UTC()
+	}
+	return time.Time{}
+}
@@ -0,0 +1,102 @@
+package files
+
+import (
+	"testing"
+
+	pt "github.com/activecm/rita/parser/parsetypes"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEVEFlow(t *testing.T) {
+	line := `{"timestamp":"2021-06-01T12:00:10.000000+0000","flow_id":1234567890,"event_type":"flow",` +
+		`"src_ip":"10.0.0.5","src_port":51000,"dest_ip":"93.184.216.34","dest_port":443,"proto":"TCP","app_proto":"tls",` +
+		`"flow":{"pkts_toserver":10,"pkts_toclient":8,"bytes_toserver":1200,"bytes_toclient":5000,` +
+		`"start":"2021-06-01T12:00:00.000000+0000","end":"2021-06-01T12:00:05.500000+0000","state":"closed"},` +
+		`"tcp":{"syn":true,"fin":true,"ack":true}}`
+
+	entry := ParseEVELine([]byte(line), log.New())
+	conn, ok := entry.(*pt.Conn)
+	require.True(t, ok)
+
+	assert.Equal(t, int64(1622548800), conn.TimeStamp)
+	assert.Equal(t, "1234567890", conn.UID)
+	assert.Equal(t, "10.0.0.5", conn.Source)
+	assert.Equal(t, 443, conn.DestinationPort)
+	assert.Equal(t, "tcp", conn.Proto)
+	assert.Equal(t, "ssl", conn.Service)
+	assert.Equal(t, 5.5, conn.Duration)
+	assert.Equal(t, int64(1200), conn.OrigIPBytes)
+	assert.Equal(t, int64(5000), conn.RespIPBytes)
+	assert.Equal(t, "SF", conn.ConnState)
+}
+
+func TestParseEVEDNS(t *testing.T) {
+	// version 2 responses may be logged in the direction of the packet
+	answer := `{"timestamp":"2021-06-01T12:00:00.000000+0000","flow_id":1,"event_type":"dns",` +
+		`"src_ip":"8.8.8.8","src_port":53,"dest_ip":"10.0.0.5","dest_port":40000,"proto":"UDP",` +
+		`"dns":{"version":2,"type":"answer","id":42,"rrname":"example.com","rrtype":"A","rcode":"NOERROR",` +
+		`"answers":[{"rrname":"example.com","rrtype":"A","ttl":300,"rdata":"93.184.216.34"}],` +
+		`"grouped":{"A":["93.184.216.34"]}}}`
+
+	dns, ok := ParseEVELine([]byte(answer), log.New()).(*pt.DNS)
+	require.True(t, ok)
+	assert.Equal(t, "10.0.0.5", dns.Source)
+	assert.Equal(t, "8.8.8.8", dns.Destination)
+	assert.Equal(t, "example.com", dns.Query)
+	assert.Equal(t, "A", dns.QTypeName)
+	assert.Equal(t, []string{"93.184.216.34"}, dns.Answers)
+
+	// version 3 responses carry the question in a list
+	response := `{"timestamp":"2021-06-01T12:00:00.000000+0000","flow_id":2,"event_type":"dns",` +
+		`"src_ip":"10.0.0.5","src_port":40001,"dest_ip":"8.8.8.8","dest_port":53,"proto":"UDP",` +
+		`"dns":{"version":3,"type":"response","id":43,"rcode":"NXDOMAIN","queries":[{"rrname":"nope.example.com","rrtype":"TXT"}]}}`
+
+	dns, ok = ParseEVELine([]byte(response), log.New()).(*pt.DNS)
+	require.True(t, ok)
+	assert.Equal(t, "10.0.0.5", dns.Source)
+	assert.Equal(t, "nope.example.com", dns.Query)
+	assert.Equal(t, "TXT", dns.QTypeName)
+	assert.Empty(t, dns.Answers)
+
+	// queries are covered by their responses
+	query := `{"timestamp":"2021-06-01T12:00:00.000000+0000","flow_id":1,"event_type":"dns",` +
+		`"src_ip":"10.0.0.5","src_port":40000,"dest_ip":"8.8.8.8","dest_port":53,"proto":"UDP",` +
+		`"dns":{"type":"query","id":42,"rrname":"example.com","rrtype":"A"}}`
+	assert.Nil(t, ParseEVELine([]byte(query), log.New()))
+}
+
+func TestParseEVEHTTPAndTLS(t *testing.T) {
+	httpLine := `{"timestamp":"2021-06-01T12:00:00.000000+0000","flow_id":3,"event_type":"http",` +
+		`"src_ip":"10.0.0.5","src_port":51001,"dest_ip":"93.184.216.34","dest_port":80,"proto":"TCP",` +
+		`"http":{"hostname":"example.com","url":"/index.html","http_user_agent":"curl/7.58.0",` +
+		`"http_method":"GET","protocol":"HTTP/1.1","status":200,"length":1256}}`
+
+	http, ok := ParseEVELine([]byte(httpLine), log.New()).(*pt.HTTP)
+	require.True(t, ok)
+	assert.Equal(t, "3", http.UID)
+	assert.Equal(t, "example.com", http.Host)
+	assert.Equal(t, "/index.html", http.URI)
+	assert.Equal(t, "curl/7.58.0", http.UserAgent)
+	assert.Equal(t, "GET", http.Method)
+	assert.Equal(t, "1.1", http.Version)
+	assert.Equal(t, int64(200), http.StatusCode)
+
+	tlsLine := `{"timestamp":"2021-06-01T12:00:00.000000+0000","flow_id":4,"event_type":"tls",` +
+		`"src_ip":"10.0.0.5","src_port":51002,"dest_ip":"93.184.216.34","dest_port":443,"proto":"TCP",` +
+		`"tls":{"subject":"CN=example.com","issuerdn":"CN=Example CA","sni":"example.com","version":"TLS 1.2",` +
+		`"ja3":{"hash":"e7d705a3286e19ea42f587b344ee6865","string":"771,..."},"ja3s":{"hash":"f4febc55ea12b31ae17cfb7e614afda8"}}}`
+
+	ssl, ok := ParseEVELine([]byte(tlsLine), log.New()).(*pt.SSL)
+	require.True(t, ok)
+	assert.Equal(t, "example.com", ssl.ServerName)
+	assert.Equal(t, "TLSv12", ssl.Version)
+	assert.Equal(t, "CN=Example CA", ssl.Issuer)
+	assert.Equal(t, "e7d705a3286e19ea42f587b344ee6865", ssl.JA3)
+	assert.Equal(t, "f4febc55ea12b31ae17cfb7e614afda8", ssl.JA3S)
+
+	// unsupported events are skipped
+	alert := `{"timestamp":"2021-06-01T12:00:00.000000+0000","event_type":"alert","alert":{"signature_id":1}}`
+	assert.Nil(t, ParseEVELine([]byte(alert), log.New()))
+}
@@ -1,6 +1,7 @@
 package files
 
 import (
+	"bufio"
 	"crypto/md5"
 	"encoding/json"
 	"errors"
@@ -13,8 +14,10 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/parser/netflow"
 	"github.com/activecm/rita/parser/parsetypes"
 	pt "github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/parser/pcap"
 )
 
 //newIndexedFile takes in a file path and the current resource bundle and opens up the
@@ -45,16 +48,57 @@ func newIndexedFile(filePath string, targetDB string, targetCID int,
 	}
 	toReturn.Hash = fHash
 
+	// packet captures are converted into Zeek style records while they are parsed,
+	// so there is no header to read
+	magic := make([]byte, 4)
+	_, err = io.ReadFull(fileHandle, magic)
+	fileHandle.Seek(0, 0)
+	if err == nil && pcap.IsCapture(magic) {
+		fileHandle.Close()
+		toReturn.SetPcap()
+		toReturn.TargetCollection = conf.T.Structure.ConnTable
+		toReturn.TargetDatabase = targetDB
+		toReturn.CID = targetCID
+		return toReturn, nil
+	}
+
+	// flow captures only produce conn records
+	if err == nil && netflow.IsFlowCapture(magic) {
+		fileHandle.Close()
+		toReturn.SetFlow()
+		toReturn.TargetCollection = conf.T.Structure.ConnTable
+		toReturn.TargetDatabase = targetDB
+		toReturn.CID = targetCID
+		return toReturn, nil
+	}
+
 	scanner, closeScanner, err := GetFileScanner(fileHandle)
 	defer closeScanner() // handles closing the underlying fileHandle (and any associate subprocesses)
 	if err != nil {
 		return toReturn, err
 	}
 
-	header, err := scanTSVHeader(scanner)
+	err = indexLog(toReturn, scanner, filepath.Base(toReturn.Path), logger, conf)
 	if err != nil {
 		return toReturn, err
 	}
+
+	toReturn.TargetDatabase = targetDB
+	toReturn.CID = targetCID
+
+	return toReturn, nil
+}
+
+//indexLog reads the header and first line of a Zeek or Suricata log in order to determine
+//how the log should be parsed and which collection it targets. The file name is used
+//to determine the type of JSON logs which do not name their type.
+func indexLog(toReturn *IndexedFile, scanner *bufio.Scanner, fileName string,
+	logger *log.Logger, conf *config.Config) error {
+
+	header, err := scanTSVHeader(scanner)
+	if err != nil {
+		return err
+	}
 	toReturn.SetHeader(header)
 
 	var broDataFactory func() pt.BroData
@@ -67,18 +111,42 @@ func newIndexedFile(filePath string, targetDB string, targetCID int,
 		// check if "_path" is provided in the JSON data
 		// https://github.com/corelight/json-streaming-logs
 		t := struct {
-			Path string `json:"_path"`
+			Path      string `json:"_path"`
+			EventType string `json:"event_type"`
 		}{}
 		json.Unmarshal(scanner.Bytes(), &t)
+
+		// logs reshaped by a log shipper (e.g. Filebeat) are mapped back onto the Zeek field names
+		var aliases FieldAliases
+		if t.Path == "" {
+			mapping, err := NewJSONMapping(conf.S.JSONMapping)
+			if err != nil {
+				return err
+			}
+			t.Path, aliases = mapping.detect(scanner.Bytes(), fileName)
+			if t.Path != "" {
+				toReturn.SetJSONLogType(mapping, t.Path)
+			}
+		}
+
+		// Suricata EVE logs mix every event type in a single file, so each
+		// line is mapped to a Zeek style record as it is parsed
+		if t.Path == "" && t.EventType != "" {
+			toReturn.SetEVE()
+			toReturn.TargetCollection = conf.T.Structure.ConnTable
+			return nil
+		}
+
+		toReturn.SetFieldAliases(aliases)
 		broDataFactory = pt.NewBroDataFactory(t.Path)
 
 		// otherwise JSON log files only have the type in the filename
 		if broDataFactory == nil {
-			broDataFactory = pt.NewBroDataFactory(filepath.Base(toReturn.Path))
+			broDataFactory = pt.NewBroDataFactory(fileName)
 		}
 	}
 	if broDataFactory == nil {
-		return toReturn, errors.New("could not map file header to parse type")
+		return errors.New("could not map file header to parse type")
 	}
 	toReturn.SetBroDataFactory(broDataFactory)
 
@@ -87,7 +155,7 @@ func newIndexedFile(filePath string, targetDB string, targetCID int,
 	if !toReturn.IsJSON() {
 		fieldMap, err = mapZeekHeaderToParseType(header, broDataFactory, logger)
 		if err != nil {
-			return toReturn, err
+			return err
 		}
 		toReturn.SetFieldMap(fieldMap)
 	}
@@ -95,24 +163,20 @@ func newIndexedFile(filePath string, targetDB string, targetCID int,
 	//parse first line
 	var line parsetypes.BroData
 	if toReturn.IsJSON() {
-		line = ParseJSONLine(scanner.Bytes(), broDataFactory, logger)
+		line = ParseJSONLine(scanner.Bytes(), toReturn.GetFieldAliases(), broDataFactory, logger)
 	} else {
 		line = ParseTSVLine(scanner.Text(), header, fieldMap, broDataFactory, logger)
 	}
 
 	if line == nil {
-		return toReturn, errors.New("could not parse first line of file")
+		return errors.New("could not parse first line of file")
 	}
 
 	toReturn.TargetCollection = line.TargetCollection(&conf.T.Structure)
 	if toReturn.TargetCollection == "" {
-		return toReturn, errors.New("could not find a target collection for file")
+		return errors.New("could not find a target collection for file")
 	}
-
-	toReturn.TargetDatabase = targetDB
-	toReturn.CID = targetCID
-
-	return toReturn, nil
+	return nil
 }
 
 //getFileHash md5's the first 15000 bytes of a file
@@ -135,21 +199,47 @@ func getFileHash(fileHandle *os.File, fInfo os.FileInfo) (string, error) {
 }
 
 //IndexFiles takes in a list of Zeek files, a number of threads, the target database, and target chunk ID and parses
-//some metadata out of the files
+//some metadata out of the files. Exits the program if none of the files could be indexed.
 func IndexFiles(files []string, indexingThreads int, targetDB string, targetCID int,
+	logger *log.Logger, conf *config.Config) []*IndexedFile {
+	indexedFiles := TryIndexFiles(files, indexingThreads, targetDB, targetCID, logger, conf)
+	if len(indexedFiles) == 0 {
+		fmt.Println("\n\t[!] No compatible logs found or all log files provided were empty.")
+		fmt.Println("\t[-] Exiting...")
+		os.Exit(0)
+	}
+	return indexedFiles
+}
+
+//TryIndexFiles is the same as IndexFiles, but returns an empty list rather than exiting
+//if none of the files could be indexed
+func TryIndexFiles(files []string, indexingThreads int, targetDB string, targetCID int,
 	logger *log.Logger, conf *config.Config) []*IndexedFile {
 	n := len(files)
-	output := make([]*IndexedFile, n)
+	// tar archives produce an indexed file for each of their logs
+	output := make([][]*IndexedFile, n)
 	indexingWG := new(sync.WaitGroup)
 
 	for i := 0; i < indexingThreads; i++ {
 		indexingWG.Add(1)
 
-		go func(files []string, indexedFiles []*IndexedFile, targetDB string, targetCID int,
+		go func(files []string, indexedFiles [][]*IndexedFile, targetDB string, targetCID int,
 			logger *log.Logger, conf *config.Config, wg *sync.WaitGroup,
 			start int, jump int, length int) {
 
 			for j := start; j < length; j += jump {
+				if isArchive(files[j]) {
+					members, err := indexArchive(files[j], targetDB, targetCID, logger, conf)
+					if err != nil {
+						logger.WithFields(log.Fields{
+							"file":  files[j],
+							"error": err.Error(),
+						}).Error("An error was encountered while reading an archive.")
+					}
+					indexedFiles[j] = members
+					continue
+				}
+
 				indexedFile, err := newIndexedFile(files[j], targetDB, targetCID, logger, conf)
 				if err != nil {
 					// log file is likely unsupported or empty
@@ -159,7 +249,7 @@ func IndexFiles(files []string, indexingThreads int, targetDB string, targetCID
 					}).Debug("An error was encountered while indexing a file.")
 					continue
 				}
-				indexedFiles[j] = indexedFile
+				indexedFiles[j] = []*IndexedFile{indexedFile}
 			}
 			wg.Done()
 		}(files, output, targetDB, targetCID, logger, conf, indexingWG, i, indexingThreads, n)
@@ -167,20 +257,10 @@ func IndexFiles(files []string, indexingThreads int, targetDB string, targetCID
 
 	indexingWG.Wait()
 
-	// remove all nil values from the slice
-	errCount := 0
+	// flatten the indexed files, skipping the files which could not be indexed
 	indexedFiles := make([]*IndexedFile, 0, len(output))
-	for _, file := range output {
-		if file != nil {
-			indexedFiles = append(indexedFiles, file)
-		} else {
-			errCount++
-		}
-	}
-	if errCount == len(output) {
-		fmt.Println("\n\t[!] No compatible logs found or all log files provided were empty.")
-		fmt.Println("\t[-] Exiting...")
-		os.Exit(0)
+	for _, fileSet := range output {
+		indexedFiles = append(indexedFiles, fileSet...)
 	}
 	return indexedFiles
 }
@@ -0,0 +1,330 @@
+package files
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/activecm/rita/config"
+)
+
+// anyLogType is the FieldAliases key for aliases which apply to every log type
+const anyLogType = "*"
+
+// jsonMappingPresets holds the built in mappings for common log shippers
+var jsonMappingPresets = map[string]config.JSONMappingStaticCfg{
+	// Filebeat's Zeek module
+	// https://www.elastic.co/guide/en/beats/filebeat/current/exported-fields-zeek.html
+	"ecs": {
+		LogTypeRules: []config.JSONLogTypeRuleStaticCfg{
+			{Field: "event.dataset", Match: "zeek.*", TrimPrefix: "zeek."},
+		},
+		FieldAliases: map[string][]config.JSONFieldAliasStaticCfg{
+			anyLogType: {
+				{Field: "ts", From: "@timestamp"},
+				{Field: "uid", From: "zeek.session_id"},
+				{Field: "id.orig_h", From: "source.ip"},
+				{Field: "id.orig_p", From: "source.port"},
+				{Field: "id.resp_h", From: "destination.ip"},
+				{Field: "id.resp_p", From: "destination.port"},
+				{Field: "proto", From: "network.transport"},
+			},
+			"conn": {
+				{Field: "service", From: "network.protocol"},
+				{Field: "duration", From: "event.duration", Scale: 1e-9},
+				{Field: "orig_bytes", From: "source.bytes"},
+				{Field: "resp_bytes", From: "destination.bytes"},
+				{Field: "orig_pkts", From: "source.packets"},
+				{Field: "resp_pkts", From: "destination.packets"},
+				{Field: "orig_ip_bytes", From: "zeek.connection.orig_ip_bytes"},
+				{Field: "orig_ip_bytes", From: "source.bytes"},
+				{Field: "resp_ip_bytes", From: "zeek.connection.resp_ip_bytes"},
+				{Field: "resp_ip_bytes", From: "destination.bytes"},
+				{Field: "conn_state", From: "zeek.connection.state"},
+				{Field: "local_orig", From: "zeek.connection.local_orig"},
+				{Field: "local_resp", From: "zeek.connection.local_resp"},
+				{Field: "missed_bytes", From: "zeek.connection.missed_bytes"},
+				{Field: "history", From: "zeek.connection.history"},
+				{Field: "tunnel_parents", From: "zeek.connection.tunnel_parents"},
+			},
+			"dns": {
+				{Field: "trans_id", From: "dns.id"},
+				{Field: "rtt", From: "zeek.dns.rtt"},
+				{Field: "query", From: "dns.question.name"},
+				{Field: "qclass", From: "zeek.dns.qclass"},
+				{Field: "qclass_name", From: "dns.question.class"},
+				{Field: "qtype", From: "zeek.dns.qtype"},
+				{Field: "qtype_name", From: "dns.question.type"},
+				{Field: "rcode", From: "zeek.dns.rcode"},
+				{Field: "rcode_name", From: "dns.response_code"},
+				{Field: "AA", From: "zeek.dns.AA"},
+				{Field: "TC", From: "zeek.dns.TC"},
+				{Field: "RD", From: "zeek.dns.RD"},
+				{Field: "RA", From: "zeek.dns.RA"},
+				{Field: "answers", From: "zeek.dns.answers"},
+				{Field: "TTLs", From: "zeek.dns.TTLs"},
+				{Field: "rejected", From: "zeek.dns.rejected"},
+			},
+			"http": {
+				{Field: "trans_depth", From: "zeek.http.trans_depth"},
+				{Field: "version", From: "http.version"},
+				{Field: "method", From: "http.request.method"},
+				{Field: "host", From: "url.domain"},
+				{Field: "uri", From: "url.original"},
+				{Field: "referrer", From: "http.request.referrer"},
+				{Field: "user_agent", From: "user_agent.original"},
+				{Field: "request_body_len", From: "http.request.body.bytes"},
+				{Field: "response_body_len", From: "http.response.body.bytes"},
+				{Field: "status_code", From: "http.response.status_code"},
+				{Field: "status_msg", From: "zeek.http.status_msg"},
+				{Field: "username", From: "url.username"},
+				{Field: "orig_fuids", From: "zeek.http.orig_fuids"},
+				{Field: "orig_mime_types", From: "zeek.http.orig_mime_types"},
+				{Field: "resp_fuids", From: "zeek.http.resp_fuids"},
+				{Field: "resp_mime_types", From: "zeek.http.resp_mime_types"},
+			},
+			"ssl": {
+				{Field: "version", From: "zeek.ssl.version"},
+				{Field: "cipher", From: "zeek.ssl.cipher"},
+				{Field: "curve", From: "zeek.ssl.curve"},
+				{Field: "server_name", From: "tls.client.server_name"},
+				{Field: "server_name", From: "zeek.ssl.server_name"},
+				{Field: "resumed", From: "zeek.ssl.resumed"},
+				{Field: "next_protocol", From: "zeek.ssl.next_protocol"},
+				{Field: "established", From: "zeek.ssl.established"},
+				{Field: "cert_chain_fuids", From: "zeek.ssl.server.cert_chain_fuids"},
+				{Field: "client_cert_chain_fuids", From: "zeek.ssl.client.cert_chain_fuids"},
+				{Field: "subject", From: "zeek.ssl.server.subject"},
+				{Field: "issuer", From: "zeek.ssl.server.issuer"},
+				{Field: "client_subject", From: "zeek.ssl.client.subject"},
+				{Field: "client_issuer", From: "zeek.ssl.client.issuer"},
+				{Field: "validation_status", From: "zeek.ssl.validation.status"},
+				{Field: "ja3", From: "tls.client.ja3"},
+				{Field: "ja3s", From: "tls.server.ja3s"},
+			},
+		},
+	},
+	// Corelight exports which carry the log type in "path" rather than "_path".
+	// Nested objects such as "id" are flattened, so no aliases are needed for them.
+	"corelight": {
+		LogTypeRules: []config.JSONLogTypeRuleStaticCfg{
+			{Field: "path", Match: "*"},
+		},
+		FieldAliases: map[string][]config.JSONFieldAliasStaticCfg{
+			anyLogType: {
+				{Field: "ts", From: "@timestamp"},
+				{Field: "ts", From: "_write_ts"},
+			},
+		},
+	},
+}
+
+type (
+	//JSONMapping maps JSON logs which were reshaped by a log shipper back onto the Zeek field names
+	JSONMapping struct {
+		rules   []config.JSONLogTypeRuleStaticCfg
+		aliases map[string]FieldAliases
+	}
+
+	//FieldAliases lists the fields of a JSON log which are filled in from other fields
+	FieldAliases []config.JSONFieldAliasStaticCfg
+)
+
+//NewJSONMapping combines the configured presets, log type rules, and field aliases.
+//The rules and aliases from the config file take precedence over those of the presets.
+func NewJSONMapping(cfg config.JSONMappingStaticCfg) (*JSONMapping, error) {
+	mapping := &JSONMapping{aliases: make(map[string]FieldAliases)}
+
+	sources := []config.JSONMappingStaticCfg{cfg}
+	for _, name := range cfg.Presets {
+		preset, ok := jsonMappingPresets[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown JSON mapping preset %s", name)
+		}
+		sources = append(sources, preset)
+	}
+
+	for _, source := range sources {
+		for _, rule := range source.LogTypeRules {
+			if rule.Field == "" {
+				return nil, fmt.Errorf("JSON log type rule for %s has no field", rule.LogType)
+			}
+			if _, err := path.Match(rule.Match, ""); err != nil {
+				return nil, fmt.Errorf("invalid JSON log type match %s: %v", rule.Match, err)
+			}
+			mapping.rules = append(mapping.rules, rule)
+		}
+		for logType, aliases := range source.FieldAliases {
+			for _, alias := range aliases {
+				if alias.Field == "" || alias.From == "" {
+					return nil, fmt.Errorf("JSON field alias for %s log must set both Field and From", logType)
+				}
+			}
+			mapping.aliases[logType] = append(mapping.aliases[logType], aliases...)
+		}
+	}
+	return mapping, nil
+}
+
+//IsEmpty returns whether the mapping has neither log type rules nor field aliases
+func (m *JSONMapping) IsEmpty() bool {
+	return len(m.rules) == 0 && len(m.aliases) == 0
+}
+
+//LogType finds the Zeek log type of a flattened JSON record using the first matching rule
+func (m *JSONMapping) LogType(record map[string]interface{}) (string, bool) {
+	for _, rule := range m.rules {
+		value, ok := record[rule.Field]
+		if !ok {
+			continue
+		}
+
+		// fields such as tags hold a list of values
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		for _, value := range values {
+			text, ok := value.(string)
+			if !ok {
+				continue
+			}
+			if matched, _ := path.Match(rule.Match, text); !matched {
+				continue
+			}
+			if rule.LogType != "" {
+				return rule.LogType, true
+			}
+			return strings.TrimPrefix(text, rule.TrimPrefix), true
+		}
+	}
+	return "", false
+}
+
+//Aliases returns the field aliases for a log type, followed by those for every log type.
+//Log types are matched by prefix in the same way as NewBroDataFactory.
+func (m *JSONMapping) Aliases(logType string) FieldAliases {
+	var aliasTypes []string
+	for aliasType := range m.aliases {
+		if aliasType != anyLogType && strings.HasPrefix(logType, aliasType) {
+			aliasTypes = append(aliasTypes, aliasType)
+		}
+	}
+	// the most specific log type comes first
+	sort.Slice(aliasTypes, func(i, j int) bool { return len(aliasTypes[i]) > len(aliasTypes[j]) })
+
+	var aliases FieldAliases
+	for _, aliasType := range aliasTypes {
+		aliases = append(aliases, m.aliases[aliasType]...)
+	}
+	return append(aliases, m.aliases[anyLogType]...)
+}
+
+//AppliesTo returns whether any of the aliased fields are found in a flattened JSON record
+func (a FieldAliases) AppliesTo(record map[string]interface{}) bool {
+	for _, alias := range a {
+		if _, ok := record[alias.From]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+//apply fills in the aliased fields of a flattened JSON record
+func (a FieldAliases) apply(record map[string]interface{}) {
+	for _, alias := range a {
+		if _, ok := record[alias.Field]; ok {
+			continue
+		}
+		value, ok := record[alias.From]
+		if !ok {
+			continue
+		}
+		if number, ok := value.(json.Number); ok && alias.Scale != 0 {
+			if scaled, err := number.Float64(); err == nil {
+				value = scaled * alias.Scale
+			}
+		}
+		record[alias.Field] = value
+	}
+}
+
+//detect finds the log type and field aliases for a JSON log which has no "_path" field from
+//its first line. Every line of the file is parsed as this log type, so log shippers must write
+//each log type to its own file. The log type is left empty if it has to be taken from the file
+//name instead. No aliases are returned if none of the aliased fields are present.
+func (m *JSONMapping) detect(lineBuffer []byte, fileName string) (string, FieldAliases) {
+	if m.IsEmpty() {
+		return "", nil
+	}
+
+	record, err := decodeFlatJSON(lineBuffer)
+	if err != nil {
+		return "", nil
+	}
+
+	logType, ok := m.LogType(record)
+	var aliases FieldAliases
+	if ok {
+		aliases = m.Aliases(logType)
+	} else {
+		aliases = m.Aliases(fileName)
+	}
+	if !aliases.AppliesTo(record) {
+		aliases = nil
+	}
+	return logType, aliases
+}
+
+//lineLogType finds the log type of a line of a JSON log using the log type rules
+func (m *JSONMapping) lineLogType(lineBuffer []byte) (string, bool) {
+	record, err := decodeFlatJSON(lineBuffer)
+	if err != nil {
+		return "", false
+	}
+	return m.LogType(record)
+}
+
+//mapLine rewrites a line of a JSON log with the aliased fields filled in
+func (a FieldAliases) mapLine(lineBuffer []byte) ([]byte, error) {
+	record, err := decodeFlatJSON(lineBuffer)
+	if err != nil {
+		return nil, err
+	}
+	a.apply(record)
+	return json.Marshal(record)
+}
+
+//decodeFlatJSON decodes a JSON object, joining the keys of nested objects with dots
+//so that {"id": {"orig_h": "10.0.0.1"}} and {"id.orig_h": "10.0.0.1"} are decoded the same
+func decodeFlatJSON(lineBuffer []byte) (map[string]interface{}, error) {
+	// numbers are decoded as json.Number so that large counters
+	// keep their precision when the record is encoded again
+	decoder := json.NewDecoder(bytes.NewReader(lineBuffer))
+	decoder.UseNumber()
+	var record map[string]interface{}
+	if err := decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+
+	flat := make(map[string]interface{}, len(record))
+	flattenJSON("", record, flat)
+	return flat, nil
+}
+
+//flattenJSON copies the fields of a JSON object into flat, prefixing their keys
+func flattenJSON(prefix string, object map[string]interface{}, flat map[string]interface{}) {
+	for key, value := range object {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenJSON(key, nested, flat)
+			continue
+		}
+		flat[key] = value
+	}
+}
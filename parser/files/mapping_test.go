@@ -0,0 +1,111 @@
+package files
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/activecm/rita/config"
+	pt "github.com/activecm/rita/parser/parsetypes"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// a conn record as written by Filebeat's Zeek module
+const testECSConnLine = `{"@timestamp":"2021-06-01T12:00:00.250Z","event":{"dataset":"zeek.connection","duration":1500000000},` +
+	`"zeek":{"session_id":"CxYz","connection":{"state":"SF","history":"ShADadFf"}},` +
+	`"source":{"ip":"10.0.0.5","port":51000,"bytes":9007199254740993,"packets":4},` +
+	`"destination":{"ip":"93.184.216.34","port":443,"bytes":900,"packets":3},"network":{"transport":"tcp"}}`
+
+func TestIndexECSJSONLog(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "filebeat.json")
+	require.NoError(t, ioutil.WriteFile(logPath, []byte(testECSConnLine+"\n"), 0644))
+
+	conf := testConfig()
+	conf.S.JSONMapping.Presets = []string{"ecs"}
+	indexed := TryIndexFiles([]string{logPath}, 1, "test", 0, log.New(), conf)
+	require.Len(t, indexed, 1)
+	assert.True(t, indexed[0].IsJSON())
+	assert.Equal(t, "conn", indexed[0].TargetCollection)
+	require.NotEmpty(t, indexed[0].GetFieldAliases())
+
+	entry := ParseJSONLine([]byte(testECSConnLine), indexed[0].GetFieldAliases(),
+		indexed[0].GetBroDataFactory(), log.New())
+	conn, ok := entry.(*pt.Conn)
+	require.True(t, ok)
+	assert.Equal(t, int64(1622548800), conn.TimeStamp)
+	assert.Equal(t, int64(1622548800250), conn.TimeStampMillis())
+	assert.Equal(t, "CxYz", conn.UID)
+	assert.Equal(t, "10.0.0.5", conn.Source)
+	assert.Equal(t, 51000, conn.SourcePort)
+	assert.Equal(t, "93.184.216.34", conn.Destination)
+	assert.Equal(t, 443, conn.DestinationPort)
+	assert.Equal(t, "tcp", conn.Proto)
+	assert.InDelta(t, 1.5, conn.Duration, 1e-9)
+	// large counters keep their precision
+	assert.Equal(t, int64(9007199254740993), conn.OrigIPBytes)
+	assert.Equal(t, int64(900), conn.RespIPBytes)
+	assert.Equal(t, "SF", conn.ConnState)
+
+	// the log type of the following lines is checked against the first line
+	assert.Equal(t, "connection", indexed[0].GetJSONLogType())
+	logType, ok := indexed[0].CheckJSONLogType([]byte(testECSConnLine))
+	assert.True(t, ok)
+	assert.Equal(t, "connection", logType)
+	logType, ok = indexed[0].CheckJSONLogType([]byte(`{"event":{"dataset":"zeek.dns"},"dns":{"question":{"name":"example.com"}}}`))
+	assert.False(t, ok)
+	assert.Equal(t, "dns", logType)
+
+	// without a mapping the log type cannot be determined
+	assert.Empty(t, TryIndexFiles([]string{logPath}, 1, "test", 0, log.New(), testConfig()))
+}
+
+func TestJSONMappingRules(t *testing.T) {
+	mapping, err := NewJSONMapping(config.JSONMappingStaticCfg{
+		Presets: []string{"corelight"},
+		LogTypeRules: []config.JSONLogTypeRuleStaticCfg{
+			{Field: "tags", Match: "zeek-dns", LogType: "dns"},
+		},
+		FieldAliases: map[string][]config.JSONFieldAliasStaticCfg{
+			"dns": {{Field: "query", From: "question"}},
+			"*":   {{Field: "uid", From: "session"}},
+		},
+	})
+	require.NoError(t, err)
+
+	// configured rules are checked before those of the presets
+	logType, aliases := mapping.detect([]byte(`{"tags":["beats","zeek-dns"],"path":"conn","question":"example.com"}`), "")
+	assert.Equal(t, "dns", logType)
+	assert.Equal(t, FieldAliases{
+		{Field: "query", From: "question"},
+		{Field: "uid", From: "session"},
+		{Field: "ts", From: "@timestamp"},
+		{Field: "ts", From: "_write_ts"},
+	}, aliases)
+
+	// nested objects are flattened and fields which are already present are kept
+	line := []byte(`{"path":"conn","ts":1622548800.5,"_write_ts":"2021-06-02T00:00:00Z","session":"Cabc",` +
+		`"id":{"orig_h":"10.0.0.5","orig_p":51000,"resp_h":"10.0.0.1","resp_p":53}}`)
+	logType, aliases = mapping.detect(line, "")
+	assert.Equal(t, "conn", logType)
+	entry := ParseJSONLine(line, aliases, pt.NewBroDataFactory(logType), log.New())
+	conn := entry.(*pt.Conn)
+	assert.Equal(t, int64(1622548800), conn.TimeStamp)
+	assert.Equal(t, "Cabc", conn.UID)
+	assert.Equal(t, "10.0.0.5", conn.Source)
+	assert.Equal(t, 53, conn.DestinationPort)
+
+	// logs without any of the aliased fields are parsed as they are
+	logType, aliases = mapping.detect([]byte(`{"ts":1622548800.5,"uid":"Cabc"}`), "conn.log")
+	assert.Empty(t, logType)
+	assert.Empty(t, aliases)
+
+	_, err = NewJSONMapping(config.JSONMappingStaticCfg{Presets: []string{"splunk"}})
+	assert.Error(t, err)
+	_, err = NewJSONMapping(config.JSONMappingStaticCfg{
+		LogTypeRules: []config.JSONLogTypeRuleStaticCfg{{Field: "type", Match: "[conn"}},
+	})
+	assert.Error(t, err)
+}
@@ -3,50 +3,137 @@ package files
 import (
 	"bufio"
 	"bytes"
+	"compress/bzip2"
 	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/activecm/rita/parser/netflow"
 	pt "github.com/activecm/rita/parser/parsetypes"
 	"github.com/activecm/rita/util"
 
 	jsoniter "github.com/json-iterator/go"
+	"github.com/klauspost/compress/zstd"
 	log "github.com/sirupsen/logrus"
 )
 
-// GatherLogFiles reads the files and directories looking for log and gz files
-func GatherLogFiles(paths []string, logger *log.Logger) []string {
+// compressionSuffixes lists the extensions of the compression formats RITA can read
+var compressionSuffixes = []string{".gz", ".zst", ".bz2", ".xz"}
+
+// datePatterns find the dates in file paths. Zeek names its daily log directories
+// YYYY-MM-DD while nfcapd names its files nfcapd.YYYYMMDDhhmm.
+var datePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(\d{4})-(\d{2})-(\d{2})`),
+	regexp.MustCompile(`(?:^|\D)(\d{4})(\d{2})(\d{2})(?:\d{4})?(?:\D|$)`),
+}
+
+//GatherOptions controls how GatherLogFiles searches directories
+type GatherOptions struct {
+	// Recursive searches subdirectories as well. Symbolic links are not followed, so Zeek's
+	// "current" link to its spool directory is not imported.
+	Recursive bool
+	// DatePattern is a glob pattern (e.g. 2023-01-*) which the date in the path of each
+	// file, written as YYYY-MM-DD, must match. Files without a date in their path are skipped.
+	DatePattern string
+}
+
+// GatherLogFiles reads the files and directories looking for log, json, compressed, archive,
+// packet capture, and flow capture files
+func GatherLogFiles(paths []string, opts GatherOptions, logger *log.Logger) []string {
 	var toReturn []string
 
 	for _, path := range paths {
 		if util.IsDir(path) {
-			toReturn = append(toReturn, gatherDir(path, logger)...)
-		} else if strings.HasSuffix(path, ".gz") ||
-			strings.HasSuffix(path, ".log") {
-			toReturn = append(toReturn, path)
+			toReturn = append(toReturn, gatherDir(path, opts, logger)...)
+		} else if isSupportedFile(path) {
+			if matchesDatePattern(path, opts.DatePattern) {
+				toReturn = append(toReturn, path)
+			}
 		} else {
 			logger.WithFields(log.Fields{
 				"path": path,
-			}).Warn("Ignoring non .log or .gz file")
+			}).Warn("Ignoring non .log, .json, compressed, archive, packet capture, or flow capture file")
 		}
 	}
 
 	return toReturn
 }
 
-// gatherDir reads the directory looking for log and .gz files
-func gatherDir(cpath string, logger *log.Logger) []string {
+// isSupportedFile checks whether the file name has an extension RITA can import
+func isSupportedFile(name string) bool {
+	return isLogFile(name) ||
+		isArchive(name) ||
+		isCaptureFile(name) ||
+		netflow.IsFlowFile(name)
+}
+
+// isLogFile checks whether the file name has the extension of a plaintext or compressed log
+func isLogFile(name string) bool {
+	return isCompressed(name) ||
+		strings.HasSuffix(name, ".log") ||
+		strings.HasSuffix(name, ".json")
+}
+
+// isCompressed checks whether the file name has the extension of a supported compression format
+func isCompressed(name string) bool {
+	for _, suffix := range compressionSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCaptureFile checks whether the file name has a packet capture extension
+func isCaptureFile(name string) bool {
+	return strings.HasSuffix(name, ".pcap") ||
+		strings.HasSuffix(name, ".pcapng") ||
+		strings.HasSuffix(name, ".cap")
+}
+
+// gatherDir reads the directory looking for log, .json, compressed, archive, packet capture,
+// and flow capture files
+func gatherDir(cpath string, opts GatherOptions, logger *log.Logger) []string {
 	var toReturn []string
+
+	if opts.Recursive {
+		// WalkDir does not follow symbolic links
+		err := filepath.WalkDir(cpath, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				logger.WithFields(log.Fields{
+					"error": err.Error(),
+					"path":  filePath,
+				}).Error("Error when reading directory")
+				return nil
+			}
+			if entry.Type().IsRegular() && isSupportedFile(entry.Name()) &&
+				matchesDatePattern(filePath, opts.DatePattern) {
+				toReturn = append(toReturn, filePath)
+			}
+			return nil
+		})
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"error": err.Error(),
+				"path":  cpath,
+			}).Error("Error when reading directory")
+		}
+		return toReturn
+	}
+
 	files, err := ioutil.ReadDir(cpath)
 	if err != nil {
 		logger.WithFields(log.Fields{
@@ -62,50 +149,101 @@ func gatherDir(cpath string, logger *log.Logger) []string {
 		// if file.IsDir() && file.Mode() != os.ModeSymlink {
 		// 	toReturn = append(toReturn, readDir(path.Join(cpath, file.Name()), logger)...)
 		// }
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".gz") ||
-			strings.HasSuffix(file.Name(), ".log") {
-			toReturn = append(toReturn, path.Join(cpath, file.Name()))
+		filePath := path.Join(cpath, file.Name())
+		if !file.IsDir() && isSupportedFile(file.Name()) && matchesDatePattern(filePath, opts.DatePattern) {
+			toReturn = append(toReturn, filePath)
 		}
 	}
 	return toReturn
 }
 
+// matchesDatePattern checks whether the date in the file path matches the glob pattern.
+// The last date in the path is used, so that a file's name takes precedence over its directory.
+func matchesDatePattern(filePath string, pattern string) bool {
+	if pattern == "" {
+		return true
+	}
+	date, ok := pathDate(filePath)
+	if !ok {
+		return false
+	}
+	matched, err := path.Match(pattern, date)
+	return err == nil && matched
+}
+
+// pathDate finds the last date in the file path and formats it as YYYY-MM-DD
+func pathDate(filePath string) (string, bool) {
+	components := strings.Split(filepath.ToSlash(filePath), "/")
+	for i := len(components) - 1; i >= 0; i-- {
+		for _, pattern := range datePatterns {
+			matches := pattern.FindAllStringSubmatch(components[i], -1)
+			if len(matches) == 0 {
+				continue
+			}
+			match := matches[len(matches)-1]
+			date := match[1] + "-" + match[2] + "-" + match[3]
+			if _, err := time.Parse("2006-01-02", date); err == nil {
+				return date, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ValidateDatePattern checks that the pattern given for GatherOptions.DatePattern is a valid glob pattern
+func ValidateDatePattern(pattern string) error {
+	_, err := path.Match(pattern, "2006-01-02")
+	return err
+}
+
 // GetFileScanner returns a buffered file scanner for a bro log file, a function to close the
 // underlying stream and any associated processors, as well as any error that may occur while
 // creating the scanner
 func GetFileScanner(fileHandle *os.File) (scanner *bufio.Scanner, closer func() error, err error) {
-	// by default just close out the underlying file handle
-	closer = fileHandle.Close
+	return newLogScanner(fileHandle, fileHandle.Name())
+}
+
+// newLogScanner returns a buffered scanner for a log stream with the given file name,
+// decompressing the stream according to the file's extension
+func newLogScanner(stream io.ReadCloser, name string) (scanner *bufio.Scanner, closer func() error, err error) {
+	// by default just close out the underlying stream
+	closer = stream.Close
 
-	ftype := fileHandle.Name()[len(fileHandle.Name())-3:]
-	if ftype != ".gz" && ftype != "log" {
+	if !isLogFile(name) {
 		return nil, closer, errors.New("filetype not recognized")
 	}
 
-	if ftype == ".gz" {
-		var gzipReader io.Reader
-		gzipReader, closer, err = newGzipReader(fileHandle)
-		if err != nil {
-			return nil, closer, err
-		}
-		scanner = bufio.NewScanner(gzipReader)
-	} else {
-		scanner = bufio.NewScanner(fileHandle)
+	reader, closer, err := newDecompressedReader(stream, name)
+	if err != nil {
+		return nil, closer, err
 	}
 
+	scanner = bufio.NewScanner(reader)
 	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 	return scanner, closer, nil
 }
 
+//newDecompressedReader returns the decompressed byte stream of a file given its name and
+//compressed byte stream. Streams without a compression extension are returned as is.
+func newDecompressedReader(stream io.ReadCloser, name string) (reader io.Reader, closer func() error, err error) {
+	switch {
+	case strings.HasSuffix(name, ".gz"), strings.HasSuffix(name, ".tgz"):
+		return newGzipReader(stream)
+	case strings.HasSuffix(name, ".bz2"):
+		return newBzip2Reader(stream)
+	case strings.HasSuffix(name, ".xz"):
+		return newXzReader(stream)
+	case strings.HasSuffix(name, ".zst"):
+		return newZstdReader(stream)
+	}
+	return stream, stream.Close, nil
+}
+
 //newGzipReader returns an un-gzipped byte stream given a gzip compressed byte stream.
 //This method tries to use the system's pigz or gzip implementation before relying on
 //Golang's gzip package (as it is quite slow). Returns stream to read from, a function to
 //close the underlying stream, and any err that may occur when opening the stream.
 func newGzipReader(fileHandle io.ReadCloser) (reader io.Reader, closer func() error, err error) {
-	// by default just close out the underlying file handle
-	// works for built in gzip library and error cases
-	closer = fileHandle.Close
-
 	var gzipPath string
 	if path, err := exec.LookPath("pigz"); err == nil {
 		gzipPath = path
@@ -115,27 +253,69 @@ func newGzipReader(fileHandle io.ReadCloser) (reader io.Reader, closer func() er
 		// can't find system command, use golang lib, no special closing logic needed other than
 		// to close the underlying file descriptor
 		reader, err = gzip.NewReader(fileHandle)
-		return reader, closer, err
+		return reader, fileHandle.Close, err
 	}
 
+	return newCommandReader(fileHandle, gzipPath)
+}
+
+//newBzip2Reader returns a decompressed byte stream given a bzip2 compressed byte stream.
+//As with gzip, the system's lbzip2, pbzip2, or bzip2 implementation is preferred over
+//Golang's bzip2 package.
+func newBzip2Reader(fileHandle io.ReadCloser) (reader io.Reader, closer func() error, err error) {
+	for _, command := range []string{"lbzip2", "pbzip2", "bzip2"} {
+		if path, err := exec.LookPath(command); err == nil {
+			return newCommandReader(fileHandle, path)
+		}
+	}
+	return bzip2.NewReader(fileHandle), fileHandle.Close, nil
+}
+
+//newXzReader returns a decompressed byte stream given an xz compressed byte stream.
+//Golang does not include an xz implementation, so the system's xz command is required.
+func newXzReader(fileHandle io.ReadCloser) (reader io.Reader, closer func() error, err error) {
+	xzPath, err := exec.LookPath("xz")
+	if err != nil {
+		return nil, fileHandle.Close, errors.New("xz must be installed to read .xz files")
+	}
+	return newCommandReader(fileHandle, xzPath)
+}
+
+//newZstdReader returns a decompressed byte stream given a zstd compressed byte stream
+func newZstdReader(fileHandle io.ReadCloser) (reader io.Reader, closer func() error, err error) {
+	decoder, err := zstd.NewReader(fileHandle)
+	if err != nil {
+		return nil, fileHandle.Close, err
+	}
+	closer = func() error {
+		decoder.Close()
+		return fileHandle.Close()
+	}
+	return decoder, closer, nil
+}
+
+//newCommandReader returns the output of a decompression command (run with -d -c) given
+//the compressed byte stream. Returns stream to read from, a function to close the
+//underlying stream and the subprocess, and any err that may occur when starting the subprocess.
+func newCommandReader(fileHandle io.ReadCloser, commandPath string) (reader io.Reader, closer func() error, err error) {
 	// create the subprocess
 	ctx, cancel := context.WithCancel(context.Background())
-	gzipCommand := exec.CommandContext(ctx, gzipPath, "-d", "-c")
+	command := exec.CommandContext(ctx, commandPath, "-d", "-c")
 
 	// tell the subprocess to read from the given stream
-	gzipCommand.Stdin = fileHandle
+	command.Stdin = fileHandle
 
 	// return/ pipe the output back out to the caller
-	pipeR, err := gzipCommand.StdoutPipe()
+	pipeR, err := command.StdoutPipe()
 	if err != nil {
 		cancel() // essentially a no-op.  makes the linter happy tho.
 		return reader, fileHandle.Close, err
 	}
 
 	var cmdStdErr bytes.Buffer
-	gzipCommand.Stderr = &cmdStdErr
+	command.Stderr = &cmdStdErr
 
-	if err := gzipCommand.Start(); err != nil {
+	if err := command.Start(); err != nil {
 		cancel() // essentially a no-op.  makes the linter happy tho.
 		return reader, fileHandle.Close, err
 	}
@@ -147,7 +327,7 @@ func newGzipReader(fileHandle io.ReadCloser) (reader io.Reader, closer func() er
 		// close the file that was passed in
 		errFile := fileHandle.Close()
 		// wait for the subprocess to finish out
-		errProc := gzipCommand.Wait()
+		errProc := command.Wait()
 
 		// add StdErr to the process error if the command returned a nonzero code
 		if errProc != nil && cmdStdErr.Len() > 0 {
@@ -225,6 +405,7 @@ func mapZeekHeaderToParseType(header *BroHeader, broDataFactory func() pt.BroDat
 	indexMap := ZeekHeaderIndexMap{
 		NthLogFieldExistsInParseType: make([]bool, len(header.Names)),
 		NthLogFieldParseTypeOffset:   make([]int, len(header.Names)),
+		NthLogFieldMillisOffset:      make(map[int]int),
 	}
 
 	// parseTypeFieldInfo and the parseTypeFields map record the names, types, and offsets of the
@@ -237,6 +418,9 @@ func mapZeekHeaderToParseType(header *BroHeader, broDataFactory func() pt.BroDat
 	// parseTypeFields maps from Zeek field names to the associated info as defined by the
 	// broData struct tags
 	parseTypeFields := make(map[string]parseTypeFieldInfo)
+	// millisFields maps from Zeek time field names to the offsets of the fields
+	// which hold the timestamps in milliseconds
+	millisFields := make(map[string]int)
 
 	// walk the fields of the broData, making sure the broData struct has
 	// an equal number of named bro fields and bro types
@@ -245,6 +429,11 @@ func mapZeekHeaderToParseType(header *BroHeader, broDataFactory func() pt.BroDat
 		zeekName := structField.Tag.Get("bro")
 		zeekType := structField.Tag.Get("brotype")
 
+		if millisName := structField.Tag.Get("bromillis"); len(millisName) != 0 {
+			millisFields[millisName] = i
+			continue
+		}
+
 		//If this field is not associated with bro, skip it
 		if len(zeekName) == 0 && len(zeekType) == 0 {
 			continue
@@ -284,57 +473,57 @@ func mapZeekHeaderToParseType(header *BroHeader, broDataFactory func() pt.BroDat
 
 		indexMap.NthLogFieldExistsInParseType[index] = true
 		indexMap.NthLogFieldParseTypeOffset[index] = fieldInfo.parseTypeFieldOffset
+		if millisOffset, ok := millisFields[name]; ok && fieldInfo.zeekType == pt.Time {
+			indexMap.NthLogFieldMillisOffset[index] = millisOffset
+		}
 	}
 
 	return indexMap, nil
 }
 
-//ParseJSONLine creates a new BroData from a line of a Zeek JSON log.
-func ParseJSONLine(lineBuffer []byte, broDataFactory func() pt.BroData,
+//ParseJSONLine creates a new BroData from a line of a Zeek JSON log. If the log was reshaped
+//by a log shipper, the aliased Zeek fields are filled in before the line is unmarshalled.
+//Nil is returned if the line is not valid JSON.
+func ParseJSONLine(lineBuffer []byte, aliases FieldAliases, broDataFactory func() pt.BroData,
 	logger *log.Logger) pt.BroData {
 
+	if len(aliases) > 0 {
+		// unparsable lines are reported below
+		if mapped, err := aliases.mapLine(lineBuffer); err == nil {
+			lineBuffer = mapped
+		}
+	}
+
 	dat := broDataFactory()
 	err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(lineBuffer, dat)
 	if err != nil {
 		logger.WithFields(log.Fields{
 			"error": err.Error(),
 		}).Error("Encountered unparsable JSON in log")
+		return nil
 	}
 	dat.ConvertFromJSON()
 	return dat
 }
 
-func parseTSVField(fieldText string, fieldType string, targetField reflect.Value, logger *log.Logger) {
+//parseTSVField stores the text of a TSV field in the field of a BroData. An error is returned
+//if the text does not hold a value of the field's Zeek type.
+func parseTSVField(fieldText string, fieldType string, targetField reflect.Value) error {
 	switch fieldType {
 	case pt.Time:
 		decimalPointIdx := strings.Index(fieldText, ".")
 		if decimalPointIdx == -1 {
-			logger.WithFields(log.Fields{
-				"error": "no decimal point found in timestamp",
-				"value": fieldText,
-			}).Error("Couldn't convert unix ts")
-			targetField.SetInt(-1)
-			return
+			return fmt.Errorf("no decimal point found in timestamp %q", fieldText)
 		}
 
 		s, err := strconv.Atoi(fieldText[:decimalPointIdx])
 		if err != nil {
-			logger.WithFields(log.Fields{
-				"error": err.Error(),
-				"value": fieldText,
-			}).Error("Couldn't convert unix ts")
-			targetField.SetInt(-1)
-			return
+			return fmt.Errorf("couldn't convert unix ts: %v", err)
 		}
 
 		nanos, err := strconv.Atoi(fieldText[decimalPointIdx+1:])
 		if err != nil {
-			logger.WithFields(log.Fields{
-				"error": err.Error(),
-				"value": fieldText,
-			}).Error("Couldn't convert unix ts")
-			targetField.SetInt(-1)
-			return
+			return fmt.Errorf("couldn't convert unix ts: %v", err)
 		}
 
 		ttim := time.Unix(int64(s), int64(nanos))
@@ -346,28 +535,20 @@ func parseTSVField(fieldText string, fieldType string, targetField reflect.Value
 		fallthrough
 	case pt.Addr:
 		targetField.SetString(fieldText)
+	case pt.Int:
+		fallthrough
 	case pt.Port:
 		fallthrough
 	case pt.Count:
 		intValue, err := strconv.Atoi(fieldText)
 		if err != nil {
-			logger.WithFields(log.Fields{
-				"error": err.Error(),
-				"value": fieldText,
-			}).Error("Couldn't convert port number/ count")
-			targetField.SetInt(-1)
-			return
+			return fmt.Errorf("couldn't convert port number/ count: %v", err)
 		}
 		targetField.SetInt(int64(intValue))
 	case pt.Interval:
 		flt, err := strconv.ParseFloat(fieldText, 64)
 		if err != nil {
-			logger.WithFields(log.Fields{
-				"error": err.Error(),
-				"value": fieldText,
-			}).Error("Couldn't convert float")
-			targetField.SetFloat(-1.0)
-			return
+			return fmt.Errorf("couldn't convert float: %v", err)
 		}
 		targetField.SetFloat(flt)
 	case pt.Bool:
@@ -380,7 +561,11 @@ func parseTSVField(fieldText string, fieldType string, targetField reflect.Value
 		fallthrough
 	case pt.EnumSet:
 		fallthrough
+	case pt.AddrSet:
+		fallthrough
 	case pt.StringVector:
+		fallthrough
+	case pt.AddrVector:
 		tokens := strings.Split(fieldText, ",")
 		tVal := reflect.ValueOf(tokens)
 		targetField.Set(tVal)
@@ -391,24 +576,19 @@ func parseTSVField(fieldText string, fieldType string, targetField reflect.Value
 			var err error
 			floats[i], err = strconv.ParseFloat(val, 64)
 			if err != nil {
-				logger.WithFields(log.Fields{
-					"error": err.Error(),
-					"value": val,
-				}).Error("Couldn't convert float")
-				return
+				return fmt.Errorf("couldn't convert float: %v", err)
 			}
 		}
 		fVal := reflect.ValueOf(floats)
 		targetField.Set(fVal)
 	default:
-		logger.WithFields(log.Fields{
-			"error": "Unhandled type",
-			"value": fieldType,
-		}).Error("Encountered unhandled type in log")
+		return fmt.Errorf("unhandled type %s", fieldType)
 	}
+	return nil
 }
 
-//ParseTSVLine creates a new BroData from a line of a Zeek TSV log.
+//ParseTSVLine creates a new BroData from a line of a Zeek TSV log. Nil is returned for comment
+//lines and for lines which don't have a valid value for each of the fields named in the header.
 //String matching is generally faster than byte matching in Golang for some reason, so we take use a string
 //rather than bytes here.
 func ParseTSVLine(lineString string, header *BroHeader,
@@ -419,6 +599,13 @@ func ParseTSVLine(lineString string, header *BroHeader,
 		return nil
 	}
 
+	if fieldCount := strings.Count(lineString, header.Separator) + 1; fieldCount != len(header.Names) {
+		logger.WithFields(log.Fields{
+			"error": fmt.Sprintf("found %d fields, but the header names %d", fieldCount, len(header.Names)),
+		}).Error("Encountered unparsable TSV in log")
+		return nil
+	}
+
 	dat := broDataFactory()
 	data := reflect.ValueOf(dat).Elem()
 
@@ -432,12 +619,18 @@ func ParseTSVLine(lineString string, header *BroHeader,
 			// fieldMap struct seen below. Now, we map from the field's index in the file header
 			// to the offsets in the broData using the NthLogFieldParseTypeOffset array.
 			if fieldMap.NthLogFieldExistsInParseType[tokenCounter] {
-				parseTSVField(
+				err := parseTSVField(
 					lineString[:tokenEndIdx],
 					header.Types[tokenCounter],
 					data.Field(fieldMap.NthLogFieldParseTypeOffset[tokenCounter]),
-					logger,
 				)
+				if err != nil {
+					logTSVFieldError(header.Names[tokenCounter], lineString[:tokenEndIdx], err, logger)
+					return nil
+				}
+				if millisOffset, ok := fieldMap.NthLogFieldMillisOffset[tokenCounter]; ok {
+					parseTSVMillis(lineString[:tokenEndIdx], data.Field(millisOffset))
+				}
 			}
 		}
 
@@ -451,13 +644,50 @@ func ParseTSVLine(lineString string, header *BroHeader,
 	if tokenCounter < len(header.Names) && /* skip field if there is no matching entry in the names header*/
 		lineString != header.Empty && lineString != header.Unset && /* skip field if it is not set */
 		fieldMap.NthLogFieldExistsInParseType[tokenCounter] { /* skip the field if it is not in the parse struct */
-		parseTSVField(
+		err := parseTSVField(
 			lineString,
 			header.Types[tokenCounter],
 			data.Field(fieldMap.NthLogFieldParseTypeOffset[tokenCounter]),
-			logger,
 		)
+		if err != nil {
+			logTSVFieldError(header.Names[tokenCounter], lineString, err, logger)
+			return nil
+		}
+		if millisOffset, ok := fieldMap.NthLogFieldMillisOffset[tokenCounter]; ok {
+			parseTSVMillis(lineString, data.Field(millisOffset))
+		}
 	}
 
 	return dat
 }
+
+//logTSVFieldError reports a TSV field which could not be parsed
+func logTSVFieldError(name string, fieldText string, err error, logger *log.Logger) {
+	logger.WithFields(log.Fields{
+		"error": err.Error(),
+		"field": name,
+		"value": fieldText,
+	}).Error("Encountered unparsable TSV in log")
+}
+
+//parseTSVMillis keeps the milliseconds of a Zeek timestamp such as 1517336042.090842.
+//Timestamps which cannot be read are rejected by parseTSVField before this is called.
+func parseTSVMillis(fieldText string, targetField reflect.Value) {
+	secondsText, fractionText := fieldText, ""
+	if decimalPointIdx := strings.Index(fieldText, "."); decimalPointIdx != -1 {
+		secondsText, fractionText = fieldText[:decimalPointIdx], fieldText[decimalPointIdx+1:]
+	}
+
+	seconds, err := strconv.ParseInt(secondsText, 10, 64)
+	if err != nil {
+		return
+	}
+
+	// only the first three digits of the fraction are kept
+	fractionText = (fractionText + "000")[:3]
+	millis, err := strconv.ParseInt(fractionText, 10, 64)
+	if err != nil {
+		return
+	}
+	targetField.SetInt(seconds*1000 + millis)
+}
@@ -1,10 +1,11 @@
 package files
 
 import (
+	"path/filepath"
 	"time"
 
 	pt "github.com/activecm/rita/parser/parsetypes"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 //BroHeader contains the parse information contained within the comment lines
@@ -24,23 +25,78 @@ type BroHeader struct {
 type ZeekHeaderIndexMap struct {
 	NthLogFieldExistsInParseType []bool
 	NthLogFieldParseTypeOffset   []int
+	// NthLogFieldMillisOffset maps the indexes of time fields to the offsets of the
+	// parsetype fields which keep the same timestamps in milliseconds
+	NthLogFieldMillisOffset map[int]int
+}
+
+//ParseStats counts what happened to the lines of a file while it was parsed.
+//Every line read is either skipped, imported, filtered, or rejected.
+type ParseStats struct {
+	LinesRead       int64            `bson:"lines_read" json:"lines_read"`
+	LinesSkipped    int64            `bson:"lines_skipped" json:"lines_skipped"` // headers, comments, and unused events
+	RecordsParsed   int64            `bson:"records_parsed" json:"records_parsed"`
+	RecordsFiltered int64            `bson:"records_filtered" json:"records_filtered"`
+	FilteredBy      map[string]int64 `bson:"filtered_by" json:"filtered_by"`
+	RecordsRejected int64            `bson:"records_rejected" json:"records_rejected"`
+	RejectedFor     map[string]int64 `bson:"rejected_for" json:"rejected_for"`
+}
+
+//AddParsed counts a record which was imported
+func (s *ParseStats) AddParsed() {
+	s.RecordsParsed++
+}
+
+//AddFiltered counts a record which was excluded by the given filter rule
+func (s *ParseStats) AddFiltered(rule string) {
+	if s.FilteredBy == nil {
+		s.FilteredBy = make(map[string]int64)
+	}
+	s.RecordsFiltered++
+	s.FilteredBy[rule]++
+}
+
+//AddRejected counts a record which could not be imported for the given reason
+func (s *ParseStats) AddRejected(reason string) {
+	if s.RejectedFor == nil {
+		s.RejectedFor = make(map[string]int64)
+	}
+	s.RecordsRejected++
+	s.RejectedFor[reason]++
 }
 
 //IndexedFile ties a file to a target collection and database
 type IndexedFile struct {
-	ID               bson.ObjectId `bson:"_id,omitempty"`
-	Path             string        `bson:"filepath"`
-	Length           int64         `bson:"length"`
-	ModTime          time.Time     `bson:"modified"`
-	Hash             string        `bson:"hash"`
-	TargetCollection string        `bson:"collection"`
-	TargetDatabase   string        `bson:"database"`
-	CID              int           `bson:"cid"`
-	ParseTime        time.Time     `bson:"time_complete"`
+	ID               primitive.ObjectID `bson:"_id,omitempty"`
+	Path             string             `bson:"filepath"`
+	Member           string             `bson:"member,omitempty"` // path of the log within a tar archive
+	Length           int64              `bson:"length"`
+	ModTime          time.Time          `bson:"modified"`
+	Hash             string             `bson:"hash"`
+	TargetCollection string             `bson:"collection"`
+	TargetDatabase   string             `bson:"database"`
+	CID              int                `bson:"cid"`
+	ParseTime        time.Time          `bson:"time_complete"`
+	Stats            ParseStats         `bson:"stats"`
 	header           *BroHeader
 	broDataFactory   func() pt.BroData
 	fieldMap         ZeekHeaderIndexMap
+	fieldAliases     FieldAliases
+	jsonMapping      *JSONMapping
+	jsonLogType      string
 	json             bool
+	eve              bool
+	pcap             bool
+	flow             bool
+}
+
+//Name returns the path of the file. Logs read from a tar archive are named
+//by joining the path of the archive with the path of the log within it.
+func (i *IndexedFile) Name() string {
+	if i.Member == "" {
+		return i.Path
+	}
+	return filepath.Join(i.Path, i.Member)
 }
 
 //The following functions are for interacting with the private data in
@@ -57,6 +113,36 @@ func (i *IndexedFile) SetJSON() {
 	i.json = true
 }
 
+//IsEVE returns whether the file is a Suricata EVE JSON file
+func (i *IndexedFile) IsEVE() bool {
+	return i.eve
+}
+
+//SetEVE sets the eve flag
+func (i *IndexedFile) SetEVE() {
+	i.eve = true
+}
+
+//IsPcap returns whether the file is a packet capture
+func (i *IndexedFile) IsPcap() bool {
+	return i.pcap
+}
+
+//SetPcap sets the pcap flag
+func (i *IndexedFile) SetPcap() {
+	i.pcap = true
+}
+
+//IsFlow returns whether the file is a NetFlow, IPFIX, or nfcapd flow capture
+func (i *IndexedFile) IsFlow() bool {
+	return i.flow
+}
+
+//SetFlow sets the flow flag
+func (i *IndexedFile) SetFlow() {
+	i.flow = true
+}
+
 //SetHeader sets the broHeader on the indexed file
 func (i *IndexedFile) SetHeader(header *BroHeader) {
 	i.header = header
@@ -90,3 +176,41 @@ func (i *IndexedFile) SetFieldMap(fieldMap ZeekHeaderIndexMap) {
 func (i *IndexedFile) GetFieldMap() ZeekHeaderIndexMap {
 	return i.fieldMap
 }
+
+//SetFieldAliases sets the aliases which map the fields of a reshaped JSON log
+//back onto the Zeek field names
+func (i *IndexedFile) SetFieldAliases(aliases FieldAliases) {
+	i.fieldAliases = aliases
+}
+
+//GetFieldAliases retrieves the aliases which map the fields of a reshaped JSON log
+//back onto the Zeek field names
+func (i *IndexedFile) GetFieldAliases() FieldAliases {
+	return i.fieldAliases
+}
+
+//SetJSONLogType records the log type found by the JSON mapping for the first line of a
+//reshaped JSON log so the log type of the following lines can be checked against it
+func (i *IndexedFile) SetJSONLogType(mapping *JSONMapping, logType string) {
+	i.jsonMapping = mapping
+	i.jsonLogType = logType
+}
+
+//GetJSONLogType retrieves the log type found by the JSON mapping for the first line of a
+//reshaped JSON log. It is empty if the log type was not found by the JSON mapping.
+func (i *IndexedFile) GetJSONLogType() string {
+	return i.jsonLogType
+}
+
+//CheckJSONLogType finds the log type of a line of a reshaped JSON log using the JSON mapping.
+//False is returned along with the line's log type if it differs from that of the first line.
+func (i *IndexedFile) CheckJSONLogType(lineBuffer []byte) (string, bool) {
+	if i.jsonMapping == nil {
+		return "", true
+	}
+	logType, ok := i.jsonMapping.lineLogType(lineBuffer)
+	if !ok || logType == i.jsonLogType {
+		return i.jsonLogType, true
+	}
+	return logType, false
+}
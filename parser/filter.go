@@ -7,6 +7,16 @@ import (
 	"github.com/activecm/rita/util"
 )
 
+// names of the filter rules which may exclude a log entry, as recorded in the import statistics
+const (
+	ruleNeverInclude       = "never_include"
+	ruleNeverIncludeDomain = "never_include_domain"
+	ruleInternalToInternal = "internal_to_internal"
+	ruleExternalToExternal = "external_to_external"
+	ruleExternalToInternal = "external_to_internal"
+	ruleTimeWindow         = "time_window"
+)
+
 // filter provides methods for excluding IP addresses, domains, and determining proxy servers during the import step
 // based on the user configuration
 type filter struct {
@@ -55,6 +65,12 @@ func newFilter(conf *config.Config) (filter, error) {
 //  5. Filtered if the source IP is external and the destination IP is internal and FilterExternalToInternal has been set in the configuration file
 //  6. Not filtered in all other cases
 func (fs *filter) filterConnPair(srcIP net.IP, dstIP net.IP) bool {
+	return fs.connPairFilterRule(srcIP, dstIP) != ""
+}
+
+// connPairFilterRule returns the name of the rule which filters a connection pair
+// as described by filterConnPair, or an empty string if the pair is not filtered
+func (fs *filter) connPairFilterRule(srcIP net.IP, dstIP net.IP) string {
 	// check if on always included list
 	isSrcIncluded := util.ContainsIP(fs.alwaysIncluded, srcIP)
 	isDstIncluded := util.ContainsIP(fs.alwaysIncluded, dstIP)
@@ -65,18 +81,18 @@ func (fs *filter) filterConnPair(srcIP net.IP, dstIP net.IP) bool {
 
 	// if either IP is on the AlwaysInclude list, filter does not apply
 	if isSrcIncluded || isDstIncluded {
-		return false
+		return ""
 	}
 
 	// if either IP is on the NeverInclude list, filter applies
 	if isSrcExcluded || isDstExcluded {
-		return true
+		return ruleNeverInclude
 	}
 
 	// if no internal subnets are defined, filter does not apply
 	// this is was the default behavior before InternalSubnets was added
 	if len(fs.internal) == 0 {
-		return false
+		return ""
 	}
 
 	// check if src and dst are internal
@@ -85,21 +101,21 @@ func (fs *filter) filterConnPair(srcIP net.IP, dstIP net.IP) bool {
 
 	// if both addresses are internal, filter applies
 	if isSrcInternal && isDstInternal {
-		return true
+		return ruleInternalToInternal
 	}
 
 	// if both addresses are external, filter applies
 	if (!isSrcInternal) && (!isDstInternal) {
-		return true
+		return ruleExternalToExternal
 	}
 
 	// filter external to internal traffic if the user has specified to do so
 	if fs.filterExternalToInternal && (!isSrcInternal) && isDstInternal {
-		return true
+		return ruleExternalToInternal
 	}
 
 	// default to not filter the connection pair
-	return false
+	return ""
 }
 
 // filterDNSPair returns true if a DNS connection pair is filtered/excluded.
@@ -113,6 +129,12 @@ func (fs *filter) filterConnPair(srcIP net.IP, dstIP net.IP) bool {
 //  5. Filtered if the source IP is external and the destination IP is internal and FilterExternalToInternal has been set in the configuration file
 //  6. Not filtered in all other cases
 func (fs *filter) filterDNSPair(srcIP net.IP, dstIP net.IP) bool {
+	return fs.dnsPairFilterRule(srcIP, dstIP) != ""
+}
+
+// dnsPairFilterRule returns the name of the rule which filters a DNS connection pair
+// as described by filterDNSPair, or an empty string if the pair is not filtered
+func (fs *filter) dnsPairFilterRule(srcIP net.IP, dstIP net.IP) string {
 	// check if on always included list
 	isSrcIncluded := util.ContainsIP(fs.alwaysIncluded, srcIP)
 	isDstIncluded := util.ContainsIP(fs.alwaysIncluded, dstIP)
@@ -123,18 +145,18 @@ func (fs *filter) filterDNSPair(srcIP net.IP, dstIP net.IP) bool {
 
 	// if either IP is on the AlwaysInclude list, filter does not apply
 	if isSrcIncluded || isDstIncluded {
-		return false
+		return ""
 	}
 
 	// if either IP is on the NeverInclude list, filter applies
 	if isSrcExcluded || isDstExcluded {
-		return true
+		return ruleNeverInclude
 	}
 
 	// if no internal subnets are defined, filter does not apply
 	// this is was the default behavior before InternalSubnets was added
 	if len(fs.internal) == 0 {
-		return false
+		return ""
 	}
 
 	// check if src and dst are internal
@@ -143,16 +165,16 @@ func (fs *filter) filterDNSPair(srcIP net.IP, dstIP net.IP) bool {
 
 	// if both addresses are external, filter applies
 	if (!isSrcInternal) && (!isDstInternal) {
-		return true
+		return ruleExternalToExternal
 	}
 
 	// filter external to internal traffic if the user has specified to do so
 	if fs.filterExternalToInternal && (!isSrcInternal) && isDstInternal {
-		return true
+		return ruleExternalToInternal
 	}
 
 	// default to not filter the connection pair
-	return false
+	return ""
 }
 
 // filterSingleIP returns true if an IP is filtered/excluded.
@@ -161,18 +183,24 @@ func (fs *filter) filterDNSPair(srcIP net.IP, dstIP net.IP) bool {
 //  2. Filtered IP is on the NeverInclude list
 //  3. Not filtered in all other cases
 func (fs *filter) filterSingleIP(IP net.IP) bool {
+	return fs.singleIPFilterRule(IP) != ""
+}
+
+// singleIPFilterRule returns the name of the rule which filters an IP
+// as described by filterSingleIP, or an empty string if the IP is not filtered
+func (fs *filter) singleIPFilterRule(IP net.IP) string {
 	// check if on always included list
 	if util.ContainsIP(fs.alwaysIncluded, IP) {
-		return false
+		return ""
 	}
 
 	// check if on never included list
 	if util.ContainsIP(fs.neverIncluded, IP) {
-		return true
+		return ruleNeverInclude
 	}
 
 	// default to not filter the IP address
-	return false
+	return ""
 }
 
 // filterDomain returns true if a domain is filtered/excluded.
@@ -181,6 +209,12 @@ func (fs *filter) filterSingleIP(IP net.IP) bool {
 //  2. Filtered if domain is on the NeverInclude list
 //  3. Not filtered in all other cases
 func (fs *filter) filterDomain(domain string) bool {
+	return fs.domainFilterRule(domain) != ""
+}
+
+// domainFilterRule returns the name of the rule which filters a domain
+// as described by filterDomain, or an empty string if the domain is not filtered
+func (fs *filter) domainFilterRule(domain string) string {
 	// check if on always included list
 	isDomainIncluded := util.ContainsDomain(fs.alwaysIncludedDomain, domain)
 
@@ -189,16 +223,16 @@ func (fs *filter) filterDomain(domain string) bool {
 
 	// if either IP is on the AlwaysInclude list, filter does not apply
 	if isDomainIncluded {
-		return false
+		return ""
 	}
 
 	// if either IP is on the NeverInclude list, filter applies
 	if isDomainExcluded {
-		return true
+		return ruleNeverIncludeDomain
 	}
 
 	// default to not filter the connection pair
-	return false
+	return ""
 }
 
 func (fs *filter) checkIfInternal(host net.IP) bool {
@@ -240,3 +240,33 @@ func TestFilterSingleIPv6(t *testing.T) {
 		assert.Equal(t, test.out, output, test.msg)
 	}
 }
+
+func TestFilterRules(t *testing.T) {
+	internalNets, _ := util.ParseSubnets([]string{"10.0.0.0/8"})
+	neverInclude, _ := util.ParseSubnets([]string{"10.0.0.2/32"})
+
+	fsTest := &filter{
+		internal:                 internalNets,
+		neverIncluded:            neverInclude,
+		neverIncludedDomain:      []string{"*.example.com"},
+		filterExternalToInternal: true,
+	}
+
+	internal := net.ParseIP("10.0.0.1")
+	internalNever := net.ParseIP("10.0.0.2")
+	external := net.ParseIP("1.1.1.1")
+
+	assert.Equal(t, ruleNeverInclude, fsTest.connPairFilterRule(internal, internalNever))
+	assert.Equal(t, ruleInternalToInternal, fsTest.connPairFilterRule(internal, internal))
+	assert.Equal(t, ruleExternalToExternal, fsTest.connPairFilterRule(external, external))
+	assert.Equal(t, ruleExternalToInternal, fsTest.connPairFilterRule(external, internal))
+	assert.Equal(t, "", fsTest.connPairFilterRule(internal, external))
+
+	// internal to internal DNS traffic is kept
+	assert.Equal(t, "", fsTest.dnsPairFilterRule(internal, internal))
+	assert.Equal(t, ruleExternalToInternal, fsTest.dnsPairFilterRule(external, internal))
+
+	assert.Equal(t, ruleNeverInclude, fsTest.singleIPFilterRule(internalNever))
+	assert.Equal(t, ruleNeverIncludeDomain, fsTest.domainFilterRule("www.example.com"))
+	assert.Equal(t, "", fsTest.domainFilterRule("example.org"))
+}
@@ -1,6 +1,9 @@
 package parser
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"net"
 	"os"
@@ -11,27 +14,35 @@ import (
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/parser/files"
+	"github.com/activecm/rita/parser/netflow"
 	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/parser/pcap"
 	"github.com/activecm/rita/pkg/beacon"
 	"github.com/activecm/rita/pkg/beaconproxy"
 	"github.com/activecm/rita/pkg/beaconsni"
 	"github.com/activecm/rita/pkg/blacklist"
 	"github.com/activecm/rita/pkg/certificate"
 	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/dnstunnel"
 	"github.com/activecm/rita/pkg/explodeddns"
+	"github.com/activecm/rita/pkg/filetransfer"
 	"github.com/activecm/rita/pkg/host"
 	"github.com/activecm/rita/pkg/hostname"
+	"github.com/activecm/rita/pkg/lease"
+	"github.com/activecm/rita/pkg/logon"
 	"github.com/activecm/rita/pkg/remover"
 	"github.com/activecm/rita/pkg/sniconn"
+	"github.com/activecm/rita/pkg/sshconn"
+	"github.com/activecm/rita/pkg/threatscore"
 	"github.com/activecm/rita/pkg/uconn"
 	"github.com/activecm/rita/pkg/uconnproxy"
 	"github.com/activecm/rita/pkg/useragent"
 	"github.com/activecm/rita/resources"
 	"github.com/activecm/rita/util"
 
-	"github.com/globalsign/mgo/bson"
 	"github.com/pbnjay/memory"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 type (
@@ -45,6 +56,31 @@ type (
 		metaDB   *database.MetaDB
 
 		batchSizeBytes int64
+
+		// quarantine collects rejected lines if a quarantine file was set
+		quarantine *quarantine
+
+		// networks assigns the hosts in the logs to the networks of the sensors which recorded them
+		networks networkRules
+
+		// fileTransfers records the files in files.log and links them to the HTTP and TLS sessions which carried them
+		fileTransfers bool
+
+		// iocHashes holds the hashes of known bad files which transferred files are matched against
+		iocHashes filetransfer.IOCHashes
+
+		// window selects the records which are imported by their timestamps
+		window database.TimeWindow
+
+		// resume holds the checkpoint of the interrupted import being resumed
+		resume *database.ImportCheckpoint
+
+		// appendToChunk adds the logs to the chunk in the config instead of replacing it
+		appendToChunk bool
+
+		// stopOnInterrupt stops the import after the current batch when the process is interrupted
+		stopOnInterrupt bool
+		stopping        int32
 	}
 
 	trustedAppTiplet struct {
@@ -64,6 +100,24 @@ func NewFSImporter(res *resources.Resources) (*FSImporter, error) {
 		return &FSImporter{}, err
 	}
 
+	// catch mistakes in the JSON mapping before any files are indexed
+	if _, err := files.NewJSONMapping(res.Config.S.JSONMapping); err != nil {
+		return &FSImporter{}, err
+	}
+
+	networks, err := newNetworkRules(res.Config.S.Networks)
+	if err != nil {
+		return &FSImporter{}, err
+	}
+
+	var iocHashes filetransfer.IOCHashes
+	if res.Config.S.FileTransfer.Enabled {
+		iocHashes, err = filetransfer.LoadIOCHashes(res.Config.S.FileTransfer.IOCHashFiles)
+		if err != nil {
+			return &FSImporter{}, err
+		}
+	}
+
 	return &FSImporter{
 		filter:         newFilter,
 		log:            res.Log,
@@ -71,6 +125,9 @@ func NewFSImporter(res *resources.Resources) (*FSImporter, error) {
 		database:       res.DB,
 		metaDB:         res.MetaDB,
 		batchSizeBytes: batchSize,
+		networks:       networks,
+		fileTransfers:  res.Config.S.FileTransfer.Enabled,
+		iocHashes:      iocHashes,
 	}, nil
 }
 
@@ -79,15 +136,31 @@ var trustedAppReferenceList = [...]trustedAppTiplet{
 	{"tcp", 443, "ssl"},
 }
 
+// SetQuarantineFile sets the file which lines that cannot be imported are appended to
+func (fs *FSImporter) SetQuarantineFile(path string) {
+	fs.quarantine = &quarantine{path: path}
+}
+
+// SetTimeWindow limits the import to the records whose timestamps fall inside the time window
+func (fs *FSImporter) SetTimeWindow(window database.TimeWindow) {
+	fs.window = window
+}
+
+// SetAppendToChunk makes the import add the logs to the chunk in the config rather than
+// replacing the chunk. The current chunk of the database is left alone.
+func (fs *FSImporter) SetAppendToChunk(appendToChunk bool) {
+	fs.appendToChunk = appendToChunk
+}
+
 // GetInternalSubnets returns the internal subnets from the config file
 func (fs *FSImporter) GetInternalSubnets() []*net.IPNet {
 	return fs.internal
 }
 
 // CollectFileDetails reads and hashes the files
-func (fs *FSImporter) CollectFileDetails(importFiles []string, threads int) []*files.IndexedFile {
+func (fs *FSImporter) CollectFileDetails(importFiles []string, opts files.GatherOptions, threads int) []*files.IndexedFile {
 	// find all of the potential bro log paths
-	logFiles := files.GatherLogFiles(importFiles, fs.log)
+	logFiles := files.GatherLogFiles(importFiles, opts, fs.log)
 
 	// hash the files and get their stats
 	return files.IndexFiles(
@@ -95,10 +168,34 @@ func (fs *FSImporter) CollectFileDetails(importFiles []string, threads int) []*f
 	)
 }
 
+// CollectNewFileDetails reads and hashes the files, dropping any which have already been
+// imported into the target database. Unlike CollectFileDetails, it does not exit when none
+// of the files can be used.
+func (fs *FSImporter) CollectNewFileDetails(logFiles []string, threads int) []*files.IndexedFile {
+	indexedFiles := files.TryIndexFiles(
+		logFiles, threads, fs.database.GetSelectedDB(), fs.config.S.Rolling.CurrentChunk, fs.log, fs.config,
+	)
+	return fs.metaDB.FilterOutPreviouslyIndexedFiles(indexedFiles, fs.database.GetSelectedDB())
+}
+
 // Run starts the importing
 func (fs *FSImporter) Run(indexedFiles []*files.IndexedFile, threads int) {
 	start := time.Now()
 
+	if fs.quarantine != nil {
+		defer fs.quarantine.close()
+	}
+
+	if fs.stopOnInterrupt {
+		defer fs.handleInterrupts()()
+	}
+
+	// a batch which was interrupted part way through left staged results behind
+	if err := fs.settleInterruptedBatch(); err != nil {
+		fmt.Printf("\t[!] Failed to settle the unfinished batch of the interrupted import: %v\n", err.Error())
+		return
+	}
+
 	fmt.Println("\t[-] Verifying log files have not been previously parsed into the target dataset ... ")
 	// check list of files against metadatabase records to ensure that the a file
 	// won't be imported into the same database twice.
@@ -111,6 +208,10 @@ func (fs *FSImporter) Run(indexedFiles []*files.IndexedFile, threads int) {
 		} else {
 			fmt.Println("\t[!] All files in this directory have already been parsed into database: ", fs.database.GetSelectedDB())
 		}
+		// the interrupted import may have stopped after parsing its last batch
+		if fs.resume != nil {
+			fs.finishImport(fs.localHostsInChunk(fs.resume.CID))
+		}
 		return
 	}
 
@@ -135,7 +236,8 @@ func (fs *FSImporter) Run(indexedFiles []*files.IndexedFile, threads int) {
 		}
 	}
 
-	if fs.config.S.Rolling.Rolling {
+	// logs added to an earlier chunk leave the current chunk of the database alone
+	if fs.config.S.Rolling.Rolling && !fs.appendToChunk {
 		err := fs.metaDB.SetRollingSettings(fs.database.GetSelectedDB(), fs.config.S.Rolling.CurrentChunk, fs.config.S.Rolling.TotalChunks)
 		if err != nil {
 			fs.log.WithFields(log.Fields{
@@ -144,14 +246,18 @@ func (fs *FSImporter) Run(indexedFiles []*files.IndexedFile, threads int) {
 			}).Error("Could not update rolling database settings for database")
 			fmt.Printf("\t[!] %v", err.Error())
 		}
+	}
 
+	if fs.config.S.Rolling.Rolling {
 		chunkSet, err := fs.metaDB.IsChunkSet(fs.config.S.Rolling.CurrentChunk, fs.database.GetSelectedDB())
 		if err != nil {
 			fmt.Println("\t[!] Could not find CID List entry in metadatabase")
 			return
 		}
 
-		if chunkSet {
+		// the chunk of an import which is being resumed holds the batches which already finished,
+		// and the chunk logs are appended to holds the logs imported before
+		if chunkSet && fs.resume == nil && !fs.appendToChunk {
 			fmt.Println("\t[-] Removing outdated data from rolling dataset ... ")
 			err := fs.removeAnalysisChunk(fs.config.S.Rolling.CurrentChunk)
 			if err != nil {
@@ -161,36 +267,77 @@ func (fs *FSImporter) Run(indexedFiles []*files.IndexedFile, threads int) {
 		}
 	}
 
+	// record the selected time window so the analysis covers the window rather than the observed timestamps
+	err = fs.metaDB.SetTimeWindow(fs.database.GetSelectedDB(), fs.window)
+	if err != nil {
+		fmt.Printf("\t[!] %v", err.Error())
+	}
+
 	// create blacklisted reference Collection if blacklisted module is enabled
 	if fs.config.S.Blacklisted.Enabled {
 		blacklist.BuildBlacklistedCollections(fs.database, fs.config, fs.log)
 	}
 
+	// flow records carry no application layer data, so the modules built from
+	// dns, http, and ssl logs have nothing to analyze
+	var unavailableModules []string
+	if onlyFlowFiles(indexedFiles) {
+		fmt.Println("\t[-] Importing flow records only. SNI beacon, proxy beacon, user agent, DNS, and certificate analyses are unavailable.")
+		unavailableModules = fs.flowUnavailableModules()
+	}
+
 	// batch up the indexed files so as not to read too much in at one time
 	batchedIndexedFiles := batchFilesBySize(indexedFiles, fs.batchSizeBytes)
 
+	// the internal hosts seen across all of the batches are scored once every batch is analyzed
+	localHosts := make(map[string]data.UniqueIP)
+	if fs.resume != nil {
+		localHosts = fs.localHostsInChunk(fs.resume.CID)
+	} else if fs.appendToChunk {
+		localHosts = fs.localHostsInChunk(fs.config.S.Rolling.CurrentChunk)
+	}
+
+	cid := fs.config.S.Rolling.CurrentChunk
+	checkpoint := database.ImportCheckpoint{
+		CID:          cid,
+		TotalBatches: len(batchedIndexedFiles),
+	}
+
 	for i, indexedFileBatch := range batchedIndexedFiles {
 		fmt.Printf("\t[-] Processing batch %d of %d\n", i+1, len(batchedIndexedFiles))
 
+		// mark the batch as unfinished until its files are indexed below
+		checkpoint.BatchInProgress = true
+		fs.metaDB.SetImportCheckpoint(fs.database.GetSelectedDB(), checkpoint)
+
 		// parse in those files!
 		retVals := fs.parseFiles(indexedFileBatch, threads, fs.log)
 
 		// Set chunk before we continue so if process dies, we still verify with a delete if
 		// any data was written out.
-		fs.metaDB.SetChunk(fs.config.S.Rolling.CurrentChunk, fs.database.GetSelectedDB(), true)
+		fs.metaDB.SetChunk(cid, fs.database.GetSelectedDB(), true)
+
+		// the analysis modules write under the chunk ID in the config, so the results of the
+		// batch are staged apart from the earlier batches until the batch is finished
+		fs.config.S.Rolling.CurrentChunk = database.StagedChunk
 
 		// build Hosts table.
 		fs.buildHosts(retVals.HostMap)
 
-		// build Uconns table. Must go before beacons.
-		fs.buildUconns(retVals.UniqueConnMap, retVals.HostMap)
+		if retVals.Spill.hasRuns() {
+			// build Uconns and SNIconns tables from the connections spilled to disk. Must go before beacons.
+			fs.buildSpilledConns(retVals)
+		} else {
+			// build Uconns table. Must go before beacons.
+			fs.buildUconns(retVals.UniqueConnMap, retVals.HostMap)
+
+			// build SNIconns table. Must go before SNI beacons
+			fs.buildSNIConns(retVals.TLSConnMap, retVals.HTTPConnMap, retVals.ZeekUIDMap, retVals.HostMap)
+		}
 
 		// build uconnsProxy table. Must go before proxy beacons
 		fs.buildUconnsProxy(retVals.ProxyUniqueConnMap)
 
-		// build SNIconns table. Must go before SNI beacons
-		fs.buildSNIConns(retVals.TLSConnMap, retVals.HTTPConnMap, retVals.ZeekUIDMap, retVals.HostMap)
-
 		// update ts range for dataset (needs to be run before beacons)
 		minTimestamp, maxTimestamp := fs.updateTimestampRange()
 
@@ -200,6 +347,9 @@ func (fs *FSImporter) Run(indexedFiles []*files.IndexedFile, threads int) {
 		// build or update the exploded DNS table
 		fs.buildHostnames(retVals.HostnameMap)
 
+		// build or update the DNS tunnel table
+		fs.buildDNSTunnels(retVals.DNSTunnelMap)
+
 		// build or update Beacons table
 		fs.buildBeacons(retVals.UniqueConnMap, retVals.HostMap, minTimestamp, maxTimestamp)
 
@@ -213,25 +363,87 @@ func (fs *FSImporter) Run(indexedFiles []*files.IndexedFile, threads int) {
 		fs.buildUserAgent(retVals.UseragentMap, retVals.HostMap)
 
 		// build or update Certificate table
-		fs.buildCertificates(retVals.CertificateMap)
+		fs.buildCertificates(retVals.CertificateMap, retVals.X509Map)
+
+		// build or update the SSH connection table
+		fs.buildSSHConns(retVals.SSHConnMap)
+
+		// build or update the DHCP lease table
+		fs.buildLeases(retVals.LeaseMap)
+
+		// build or update the user logon table
+		fs.buildLogons(retVals.LogonMap)
+
+		// build or update the file transfer and upload tables
+		fs.buildFileTransfers(retVals.FileTransferMap, retVals.FileUploadMap, retVals.FileSessionMap)
 
 		// update blacklisted peers in hosts collection
 		fs.markBlacklistedPeers(retVals.HostMap)
 
-		// record file+database name hash in metadabase to prevent duplicate content
+		fs.config.S.Rolling.CurrentChunk = cid
+
+		for key, entry := range retVals.HostMap {
+			if entry.IsLocal {
+				localHosts[key] = entry.Host
+			}
+		}
+
+		// record file+database name hash in metadabase to prevent duplicate content.
+		// The files are staged along with the results until the batch is committed.
 		fmt.Println("\t[-] Indexing log entries ... ")
+		for _, indexedFile := range indexedFileBatch {
+			indexedFile.CID = database.StagedChunk
+		}
 		err := fs.metaDB.AddNewFilesToIndex(indexedFileBatch)
 		if err != nil {
 			fs.log.Error("Could not update the list of parsed files")
 		}
+		for _, indexedFile := range indexedFileBatch {
+			indexedFile.CID = cid
+		}
+
+		// once the batch is being committed, an interrupted import finishes the commit when it is resumed
+		checkpoint.Committing = true
+		fs.metaDB.SetImportCheckpoint(fs.database.GetSelectedDB(), checkpoint)
+
+		err = fs.commitBatch(cid)
+		if err != nil {
+			fs.log.WithField("error", err.Error()).Error("Could not commit the results of the batch")
+			fmt.Printf("\t[!] Failed to commit batch %d of %d: %v\n", i+1, len(batchedIndexedFiles), err.Error())
+			return
+		}
+
+		checkpoint.BatchesDone = i + 1
+		checkpoint.BatchInProgress = false
+		checkpoint.Committing = false
+		fs.metaDB.SetImportCheckpoint(fs.database.GetSelectedDB(), checkpoint)
 
+		if fs.isStopping() && i+1 < len(batchedIndexedFiles) {
+			fs.log.WithFields(log.Fields{
+				"database":      fs.database.GetSelectedDB(),
+				"batches_done":  checkpoint.BatchesDone,
+				"total_batches": checkpoint.TotalBatches,
+			}).Warn("Import interrupted")
+			fmt.Printf("\t[!] Import stopped after batch %d of %d. Run the import again with --resume to continue.\n",
+				checkpoint.BatchesDone, checkpoint.TotalBatches)
+			return
+		}
 	}
 
-	// mark results as imported and analyzed
-	fmt.Println("\t[-] Updating metadatabase ... ")
-	fs.metaDB.MarkDBAnalyzed(fs.database.GetSelectedDB(), true)
+	fs.metaDB.SetUnavailableModules(fs.database.GetSelectedDB(), unavailableModules)
+	fs.finishImport(localHosts)
+
+	var rejected int64
+	for _, indexedFile := range indexedFiles {
+		rejected += indexedFile.Stats.RecordsRejected
+	}
+	if rejected > 0 {
+		fmt.Printf("\t[!] %d records could not be imported. Run 'rita show-import-stats %s' for details.\n",
+			rejected, fs.database.GetSelectedDB())
+	}
 
 	progTime := time.Now()
+
 	fs.log.WithFields(
 		log.Fields{
 			"current_time": progTime.Format(util.TimeFormat),
@@ -250,11 +462,59 @@ func (fs *FSImporter) Run(indexedFiles []*files.IndexedFile, threads int) {
 	fmt.Println("\t[-] Done!")
 }
 
+// finishImport scores the internal hosts once every batch is analyzed and marks the
+// database as imported, which ends the import checkpoint
+func (fs *FSImporter) finishImport(localHosts map[string]data.UniqueIP) {
+	// update threat scores in hosts collection. Must go after every other analysis
+	fs.buildThreatScores(localHosts)
+
+	// mark results as imported and analyzed
+	fmt.Println("\t[-] Updating metadatabase ... ")
+	fs.metaDB.MarkDBAnalyzed(fs.database.GetSelectedDB(), true)
+
+	err := fs.metaDB.ClearImportCheckpoint(fs.database.GetSelectedDB())
+	if err != nil {
+		fs.log.WithField("error", err.Error()).Error("Could not clear the import checkpoint")
+	}
+}
+
+// onlyFlowFiles checks whether every file being imported is a flow capture
+func onlyFlowFiles(indexedFiles []*files.IndexedFile) bool {
+	for _, indexedFile := range indexedFiles {
+		if !indexedFile.IsFlow() {
+			return false
+		}
+	}
+	return len(indexedFiles) > 0
+}
+
+// flowUnavailableModules lists the collections of the analysis modules
+// which require application layer logs
+func (fs *FSImporter) flowUnavailableModules() []string {
+	return []string{
+		fs.config.T.BeaconSNI.BeaconSNITable,
+		fs.config.T.BeaconProxy.BeaconProxyTable,
+		fs.config.T.UserAgent.UserAgentTable,
+		fs.config.T.DNS.ExplodedDNSTable,
+		fs.config.T.DNS.HostnamesTable,
+		fs.config.T.DNS.DNSTunnelTable,
+		fs.config.T.Cert.CertificateTable,
+		fs.config.T.Structure.SSHConnTable,
+		fs.config.T.Structure.LeaseTable,
+		fs.config.T.Structure.LogonTable,
+		fs.config.T.FileTransfer.FileTransferTable,
+		fs.config.T.FileTransfer.FileUploadTable,
+	}
+}
+
 // batchFilesBySize takes in an slice of indexedFiles and splits the array into
 // subgroups of indexedFiles such that each group has a total size in bytes less than size
 func batchFilesBySize(indexedFiles []*files.IndexedFile, size int64) [][]*files.IndexedFile {
 	// sort the indexed files so we process them in order
 	sort.Slice(indexedFiles, func(i, j int) bool {
+		if indexedFiles[i].Path == indexedFiles[j].Path {
+			return indexedFiles[i].Member < indexedFiles[j].Member
+		}
 		return indexedFiles[i].Path < indexedFiles[j].Path
 	})
 
@@ -320,83 +580,33 @@ func (fs *FSImporter) parseFiles(indexedFiles []*files.IndexedFile, parsingThrea
 
 	parseStartTime := time.Now()
 	retVals := newParseResults()
+	if fs.config.S.Import.MemoryLimit > 0 {
+		retVals.Spill = newSpiller(fs.config.S.Import.SpillDirectory, fs.config.S.Import.MemoryLimit,
+			fs.config.S.BeaconSNI.Enabled, logger)
+	}
+
+	// the logs stored in a tar archive are parsed together so the archive is only read once
+	fileGroups := groupArchiveMembers(indexedFiles)
 
 	//set up parallel parsing
-	n := len(indexedFiles)
+	n := len(fileGroups)
 	parsingWG := new(sync.WaitGroup)
 
 	for i := 0; i < parsingThreads; i++ {
 		parsingWG.Add(1)
 
-		go func(indexedFiles []*files.IndexedFile, logger *log.Logger,
+		go func(fileGroups [][]*files.IndexedFile, logger *log.Logger,
 			wg *sync.WaitGroup, start int, jump int, length int) {
 			//comb over array
 			for j := start; j < length; j += jump {
-
-				// open the file
-				fileHandle, err := os.Open(indexedFiles[j].Path)
-				if err != nil {
-					logger.WithFields(log.Fields{
-						"file":  indexedFiles[j].Path,
-						"error": err.Error(),
-					}).Error("Could not open file for parsing")
-				}
-
-				// read the file
-				fileScanner, closeScanner, err := files.GetFileScanner(fileHandle)
-				if err != nil {
-					logger.WithFields(log.Fields{
-						"file":  indexedFiles[j].Path,
-						"error": err.Error(),
-					}).Error("Could not read from the file")
+				if fileGroups[j][0].Member != "" {
+					fs.parseArchive(fileGroups[j], retVals, logger)
+				} else {
+					fs.parseFile(fileGroups[j][0], retVals, logger)
 				}
-				fmt.Println("\t[-] Parsing " + indexedFiles[j].Path + " -> " + indexedFiles[j].TargetDatabase)
-
-				// This loops through every line of the file
-				for fileScanner.Scan() {
-					// go to next line if there was an issue
-					if fileScanner.Err() != nil {
-						break
-					}
-
-					//parse the line
-					var entry parsetypes.BroData
-					if indexedFiles[j].IsJSON() {
-						entry = files.ParseJSONLine(fileScanner.Bytes(), indexedFiles[j].GetBroDataFactory(), logger)
-					} else {
-						// I've tried to increase performance by avoiding the allocations that result from
-						// scanner.Text() by using .Bytes() with an unsafe cast, but that seemed to hurt performance -LL
-						entry = files.ParseTSVLine(fileScanner.Text(),
-							indexedFiles[j].GetHeader(), indexedFiles[j].GetFieldMap(),
-							indexedFiles[j].GetBroDataFactory(), logger,
-						)
-					}
-
-					if entry == nil {
-						continue
-					}
-
-					switch typedEntry := entry.(type) {
-					case *parsetypes.Conn:
-						parseConnEntry(typedEntry, fs.filter, retVals, logger)
-					case *parsetypes.DNS:
-						parseDNSEntry(typedEntry, fs.filter, retVals, logger)
-					case *parsetypes.HTTP:
-						parseHTTPEntry(typedEntry, fs.filter, retVals, logger)
-					case *parsetypes.OpenConn:
-						parseOpenConnEntry(typedEntry, fs.filter, retVals, logger)
-					case *parsetypes.SSL:
-						parseSSLEntry(typedEntry, fs.filter, retVals, logger)
-					}
-				}
-				indexedFiles[j].ParseTime = time.Now()
-				closeScanner() // handles closing the underlying fileHandle
-				logger.WithFields(log.Fields{
-					"path": indexedFiles[j].Path,
-				}).Info("Finished parsing file")
 			}
 			wg.Done()
-		}(indexedFiles, logger, parsingWG, i, parsingThreads, n)
+		}(fileGroups, logger, parsingWG, i, parsingThreads, n)
 	}
 	parsingWG.Wait()
 	fmt.Println("\t[-] Finished parsing logs in " + util.FormatDuration(
@@ -416,6 +626,262 @@ func (fs *FSImporter) parseFiles(indexedFiles []*files.IndexedFile, parsingThrea
 	return retVals
 }
 
+// groupArchiveMembers groups the logs stored in the same tar archive together.
+// Every other file is placed in a group of its own.
+func groupArchiveMembers(indexedFiles []*files.IndexedFile) [][]*files.IndexedFile {
+	var groups [][]*files.IndexedFile
+	archives := make(map[string]int)
+	for _, indexedFile := range indexedFiles {
+		if indexedFile.Member == "" {
+			groups = append(groups, []*files.IndexedFile{indexedFile})
+			continue
+		}
+		if i, ok := archives[indexedFile.Path]; ok {
+			groups[i] = append(groups[i], indexedFile)
+			continue
+		}
+		archives[indexedFile.Path] = len(groups)
+		groups = append(groups, []*files.IndexedFile{indexedFile})
+	}
+	return groups
+}
+
+// parseFile parses a single log, packet capture, or flow capture
+func (fs *FSImporter) parseFile(indexedFile *files.IndexedFile, retVals ParseResults, logger *log.Logger) {
+	// open the file
+	fileHandle, err := os.Open(indexedFile.Path)
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"file":  indexedFile.Path,
+			"error": err.Error(),
+		}).Error("Could not open file for parsing")
+		return
+	}
+
+	fileNetworks := fs.networks.forFile(indexedFile)
+
+	// packet captures are converted into Zeek style records as they are read
+	if indexedFile.IsPcap() {
+		fmt.Println("\t[-] Parsing " + indexedFile.Path + " -> " + indexedFile.TargetDatabase)
+		err = pcap.ReadCapture(fileHandle, indexedFile.Path, func(entry parsetypes.BroData) {
+			fileNetworks.label(entry)
+			fs.recordOutcome(indexedFile, fs.parseEntry(entry, retVals, logger), "")
+		}, logger)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"file":  indexedFile.Path,
+				"error": err.Error(),
+			}).Error("Could not read packets from the file")
+		}
+		indexedFile.ParseTime = time.Now()
+		fileHandle.Close()
+		logger.WithFields(log.Fields{
+			"path": indexedFile.Path,
+		}).Info("Finished parsing file")
+		return
+	}
+
+	// flow captures are converted into conn records as they are read
+	if indexedFile.IsFlow() {
+		fmt.Println("\t[-] Parsing " + indexedFile.Path + " -> " + indexedFile.TargetDatabase)
+		err = netflow.ReadFlows(fileHandle, indexedFile.Path, func(entry parsetypes.BroData) {
+			fileNetworks.label(entry)
+			fs.recordOutcome(indexedFile, fs.parseEntry(entry, retVals, logger), "")
+		}, logger)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"file":  indexedFile.Path,
+				"error": err.Error(),
+			}).Error("Could not read flows from the file")
+		}
+		indexedFile.ParseTime = time.Now()
+		fileHandle.Close()
+		logger.WithFields(log.Fields{
+			"path": indexedFile.Path,
+		}).Info("Finished parsing file")
+		return
+	}
+
+	// read the file
+	fileScanner, closeScanner, err := files.GetFileScanner(fileHandle)
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"file":  indexedFile.Path,
+			"error": err.Error(),
+		}).Error("Could not read from the file")
+		closeScanner()
+		return
+	}
+	fmt.Println("\t[-] Parsing " + indexedFile.Path + " -> " + indexedFile.TargetDatabase)
+
+	fs.parseLog(indexedFile, fileScanner, retVals, logger)
+	closeScanner() // handles closing the underlying fileHandle
+}
+
+// parseArchive parses the logs stored in a tar archive
+func (fs *FSImporter) parseArchive(indexedFiles []*files.IndexedFile, retVals ParseResults, logger *log.Logger) {
+	err := files.ScanArchive(indexedFiles, func(indexedFile *files.IndexedFile, fileScanner *bufio.Scanner) {
+		fmt.Println("\t[-] Parsing " + indexedFile.Name() + " -> " + indexedFile.TargetDatabase)
+		fs.parseLog(indexedFile, fileScanner, retVals, logger)
+	})
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"file":  indexedFiles[0].Path,
+			"error": err.Error(),
+		}).Error("Could not read from the archive")
+	}
+}
+
+// parseLog parses every line of a Zeek or Suricata log
+func (fs *FSImporter) parseLog(indexedFile *files.IndexedFile, fileScanner *bufio.Scanner, retVals ParseResults, logger *log.Logger) {
+	fileNetworks := fs.networks.forFile(indexedFile)
+
+	// reshaped JSON logs are parsed as the log type of their first line
+	otherLogTypes := make(map[string]int64)
+
+	// This loops through every line of the file
+	for fileScanner.Scan() {
+		// go to next line if there was an issue
+		if fileScanner.Err() != nil {
+			break
+		}
+		indexedFile.Stats.LinesRead++
+
+		//parse the line
+		var entry parsetypes.BroData
+		if indexedFile.IsEVE() {
+			entry = files.ParseEVELine(fileScanner.Bytes(), logger)
+		} else if indexedFile.IsJSON() {
+			if logType, ok := indexedFile.CheckJSONLogType(fileScanner.Bytes()); !ok {
+				otherLogTypes[logType]++
+				fs.recordOutcome(indexedFile, entryOutcome{rejectedFor: rejectLogTypeChanged}, fileScanner.Text())
+				continue
+			}
+			entry = files.ParseJSONLine(fileScanner.Bytes(), indexedFile.GetFieldAliases(),
+				indexedFile.GetBroDataFactory(), logger)
+		} else {
+			// I've tried to increase performance by avoiding the allocations that result from
+			// scanner.Text() by using .Bytes() with an unsafe cast, but that seemed to hurt performance -LL
+			entry = files.ParseTSVLine(fileScanner.Text(),
+				indexedFile.GetHeader(), indexedFile.GetFieldMap(),
+				indexedFile.GetBroDataFactory(), logger,
+			)
+		}
+
+		if entry == nil {
+			if skippableLine(indexedFile, fileScanner.Bytes()) {
+				indexedFile.Stats.LinesSkipped++
+			} else {
+				fs.recordOutcome(indexedFile, entryOutcome{rejectedFor: rejectMalformedLine}, fileScanner.Text())
+			}
+			continue
+		}
+
+		fileNetworks.label(entry)
+		fs.recordOutcome(indexedFile, fs.parseEntry(entry, retVals, logger), fileScanner.Text())
+	}
+	for logType, count := range otherLogTypes {
+		logger.WithFields(log.Fields{
+			"path":           indexedFile.Name(),
+			"log_type":       indexedFile.GetJSONLogType(),
+			"other_log_type": logType,
+			"lines":          count,
+		}).Warn("Skipped lines whose log type differs from the first line of the file. Each log type must be written to its own file")
+	}
+	indexedFile.ParseTime = time.Now()
+	logger.WithFields(log.Fields{
+		"path": indexedFile.Name(),
+	}).Info("Finished parsing file")
+}
+
+//skippableLine returns true if a line which did not produce an entry was left out on purpose
+//rather than because it could not be parsed
+func skippableLine(indexedFile *files.IndexedFile, line []byte) bool {
+	// EVE logs contain many events which are not imported, so only
+	// lines which could not be read at all are rejected
+	if indexedFile.IsEVE() {
+		return json.Valid(line)
+	}
+	// blank lines and the comments of TSV logs don't hold records
+	return len(bytes.TrimSpace(line)) == 0 || (!indexedFile.IsJSON() && bytes.HasPrefix(line, []byte("#")))
+}
+
+//parseEntry passes a parsed log entry on to the matching handler
+func (fs *FSImporter) parseEntry(entry parsetypes.BroData, retVals ParseResults, logger *log.Logger) entryOutcome {
+	if ts, ok := entryTimestamp(entry); ok && !fs.window.Contains(ts) {
+		return entryOutcome{filteredBy: ruleTimeWindow}
+	}
+
+	var outcome entryOutcome
+	switch typedEntry := entry.(type) {
+	case *parsetypes.Conn:
+		outcome = parseConnEntry(typedEntry, fs.filter, retVals, logger)
+	case *parsetypes.DNS:
+		outcome = parseDNSEntry(typedEntry, fs.filter, retVals, logger)
+	case *parsetypes.HTTP:
+		outcome = parseHTTPEntry(typedEntry, fs.filter, retVals, logger)
+		if fs.fileTransfers && outcome == (entryOutcome{}) {
+			updateFileSessions(typedEntry.UID, httpFQDN(typedEntry), "http", retVals)
+		}
+	case *parsetypes.OpenConn:
+		outcome = parseOpenConnEntry(typedEntry, fs.filter, retVals, logger)
+	case *parsetypes.SSL:
+		outcome = parseSSLEntry(typedEntry, fs.filter, retVals, logger)
+		if fs.fileTransfers && outcome == (entryOutcome{}) {
+			updateFileSessions(typedEntry.UID, typedEntry.ServerName, "ssl", retVals)
+		}
+	case *parsetypes.SSH:
+		outcome = parseSSHEntry(typedEntry, fs.filter, retVals, logger)
+	case *parsetypes.X509:
+		outcome = parseX509Entry(typedEntry, retVals)
+	case *parsetypes.DHCP:
+		outcome = parseDHCPEntry(typedEntry, fs.filter, retVals, logger)
+	case *parsetypes.Kerberos:
+		outcome = parseKerberosEntry(typedEntry, fs.filter, retVals, logger)
+	case *parsetypes.NTLM:
+		outcome = parseNTLMEntry(typedEntry, fs.filter, retVals, logger)
+	case *parsetypes.Files:
+		if fs.fileTransfers {
+			outcome = parseFilesEntry(typedEntry, fs.iocHashes, fs.filter, retVals, logger)
+		}
+	}
+
+	// spill the connection details to disk if they take up too much memory
+	if outcome == (entryOutcome{}) {
+		retVals.Spill.add(spillBytes(entry), retVals)
+	}
+	return outcome
+}
+
+// entryTimestamp returns the timestamp of a log entry
+func entryTimestamp(entry parsetypes.BroData) (int64, bool) {
+	switch typedEntry := entry.(type) {
+	case *parsetypes.Conn:
+		return typedEntry.TimeStamp, true
+	case *parsetypes.DNS:
+		return typedEntry.TimeStamp, true
+	case *parsetypes.HTTP:
+		return typedEntry.TimeStamp, true
+	case *parsetypes.OpenConn:
+		return typedEntry.TimeStamp, true
+	case *parsetypes.SSL:
+		return typedEntry.TimeStamp, true
+	case *parsetypes.SSH:
+		return typedEntry.TimeStamp, true
+	case *parsetypes.X509:
+		return typedEntry.TimeStamp, true
+	case *parsetypes.DHCP:
+		return typedEntry.TimeStamp, true
+	case *parsetypes.Kerberos:
+		return typedEntry.TimeStamp, true
+	case *parsetypes.NTLM:
+		return typedEntry.TimeStamp, true
+	case *parsetypes.Files:
+		return typedEntry.TimeStamp, true
+	}
+	return 0, false
+}
+
 // buildExplodedDNS .....
 func (fs *FSImporter) buildExplodedDNS(domainMap map[string]int) {
 
@@ -434,8 +900,75 @@ func (fs *FSImporter) buildExplodedDNS(domainMap map[string]int) {
 	}
 }
 
+// buildDNSTunnels .....
+func (fs *FSImporter) buildDNSTunnels(tunnelMap map[string]*dnstunnel.Input) {
+
+	if fs.config.S.DNS.Enabled {
+		if len(tunnelMap) > 0 {
+			// Set up the database
+			dnsTunnelRepo := dnstunnel.NewMongoRepository(fs.database, fs.config, fs.log)
+			err := dnsTunnelRepo.CreateIndexes()
+			if err != nil {
+				fs.log.Error(err)
+			}
+			dnsTunnelRepo.Upsert(tunnelMap)
+		} else {
+			fmt.Println("\t[!] No DNS tunnel data to analyze")
+		}
+	}
+}
+
+// buildThreatScores scores the internal hosts seen by the import. Hosts scored by earlier
+// imports are rescored as well, since their scores may rest on chunks which were removed.
+func (fs *FSImporter) buildThreatScores(localHosts map[string]data.UniqueIP) {
+
+	if fs.config.S.ThreatScore.Enabled {
+		scoredHosts, err := fs.findLocalHosts(bson.M{"dat.threat_score": bson.M{"$exists": true}})
+		if err != nil {
+			fs.log.WithField("error", err.Error()).Error("Could not find the internal hosts scored by earlier imports")
+		}
+		for key, host := range scoredHosts {
+			localHosts[key] = host
+		}
+
+		if len(localHosts) > 0 {
+			hosts := make([]data.UniqueIP, 0, len(localHosts))
+			for _, host := range localHosts {
+				hosts = append(hosts, host)
+			}
+			threatScoreRepo := threatscore.NewMongoRepository(fs.database, fs.config, fs.log)
+			threatScoreRepo.Score(hosts)
+		} else {
+			fmt.Println("\t[!] No internal hosts to score")
+		}
+	}
+}
+
+// findLocalHosts finds the internal hosts in the host collection which match the selector
+func (fs *FSImporter) findLocalHosts(selector bson.M) (map[string]data.UniqueIP, error) {
+	localHosts := make(map[string]data.UniqueIP)
+
+	cursor, err := fs.database.Collection(fs.config.T.Structure.HostTable).Find(
+		fs.database.Context(), database.MergeBSONMaps(bson.M{"local": true}, selector),
+	)
+	if err != nil {
+		return localHosts, err
+	}
+
+	var hosts []data.UniqueIP
+	err = cursor.All(fs.database.Context(), &hosts)
+	if err != nil {
+		return localHosts, err
+	}
+
+	for _, host := range hosts {
+		localHosts[host.MapKey()] = host
+	}
+	return localHosts, nil
+}
+
 // buildCertificates .....
-func (fs *FSImporter) buildCertificates(certMap map[string]*certificate.Input) {
+func (fs *FSImporter) buildCertificates(certMap map[string]*certificate.Input, x509Map map[string]*certificate.X509) {
 
 	if len(certMap) > 0 {
 		// Set up the database
@@ -444,13 +977,83 @@ func (fs *FSImporter) buildCertificates(certMap map[string]*certificate.Input) {
 		if err != nil {
 			fs.log.Error(err)
 		}
-		certificateRepo.Upsert(certMap)
+		certificateRepo.Upsert(certMap, x509Map)
+	} else {
+		fmt.Println("\t[!] No certificate data to analyze")
+	}
+
+}
+
+// buildSSHConns .....
+func (fs *FSImporter) buildSSHConns(sshMap map[string]*sshconn.Input) {
+
+	if len(sshMap) > 0 {
+		// Set up the database
+		sshConnRepo := sshconn.NewMongoRepository(fs.database, fs.config, fs.log)
+		err := sshConnRepo.CreateIndexes()
+		if err != nil {
+			fs.log.Error(err)
+		}
+		sshConnRepo.Upsert(sshMap)
+	} else {
+		fmt.Println("\t[!] No SSH data to analyze")
+	}
+
+}
+
+// buildLeases .....
+func (fs *FSImporter) buildLeases(leaseMap map[string]*lease.Input) {
+
+	if len(leaseMap) > 0 {
+		// Set up the database
+		leaseRepo := lease.NewMongoRepository(fs.database, fs.config, fs.log)
+		err := leaseRepo.CreateIndexes()
+		if err != nil {
+			fs.log.Error(err)
+		}
+		leaseRepo.Upsert(leaseMap)
+	} else {
+		fmt.Println("\t[!] No DHCP data to analyze")
+	}
+
+}
+
+// buildLogons .....
+func (fs *FSImporter) buildLogons(logonMap map[string]*logon.Input) {
+
+	if len(logonMap) > 0 {
+		// Set up the database
+		logonRepo := logon.NewMongoRepository(fs.database, fs.config, fs.log)
+		err := logonRepo.CreateIndexes()
+		if err != nil {
+			fs.log.Error(err)
+		}
+		logonRepo.Upsert(logonMap)
 	} else {
-		fmt.Println("\t[!] No invalid certificate data to analyze")
+		fmt.Println("\t[!] No Kerberos or NTLM data to analyze")
 	}
 
 }
 
+// buildFileTransfers .....
+func (fs *FSImporter) buildFileTransfers(transferMap map[string]*filetransfer.Input,
+	uploadMap map[string]*filetransfer.UploadInput, sessionMap map[string]*filetransfer.Session) {
+
+	if fs.config.S.FileTransfer.Enabled {
+		if len(transferMap) > 0 || len(uploadMap) > 0 {
+			// Set up the database
+			fileTransferRepo := filetransfer.NewMongoRepository(fs.database, fs.config, fs.log)
+			err := fileTransferRepo.CreateIndexes()
+			if err != nil {
+				fs.log.Error(err)
+			}
+			fileTransferRepo.Upsert(transferMap, uploadMap, sessionMap)
+		} else {
+			fmt.Println("\t[!] No file transfer data to analyze")
+		}
+	}
+}
+
 // removeAnalysisChunk .....
 func (fs *FSImporter) removeAnalysisChunk(cid int) error {
 
@@ -503,6 +1106,33 @@ func (fs *FSImporter) buildSNIConns(tlsMap map[string]*sniconn.TLSInput, httpMap
 	}
 }
 
+// buildSpilledConns merges the connection details which were spilled to disk back into
+// the unique connections and SNI connections and analyzes them a part at a time
+func (fs *FSImporter) buildSpilledConns(retVals ParseResults) {
+	defer retVals.Spill.remove()
+
+	fmt.Println("\t[-] Merging connections spilled to disk ... ")
+	err := retVals.Spill.mergeUconns(retVals.UniqueConnMap, func(uconnMap map[string]*uconn.Input) {
+		fs.buildUconns(uconnMap, retVals.HostMap)
+	})
+	if err != nil {
+		fs.log.WithField("error", err.Error()).Error("Could not merge the unique connections spilled to disk")
+	}
+
+	// only enable SNIconns if a downstream analysis needs it
+	if !fs.config.S.BeaconSNI.Enabled {
+		return
+	}
+	err = retVals.Spill.mergeSNIConns(retVals.TLSConnMap, retVals.HTTPConnMap, retVals.ZeekUIDMap,
+		func(tlsMap map[string]*sniconn.TLSInput, httpMap map[string]*sniconn.HTTPInput, zeekUIDMap map[string]*data.ZeekUIDRecord) {
+			fs.buildSNIConns(tlsMap, httpMap, zeekUIDMap, retVals.HostMap)
+		},
+	)
+	if err != nil {
+		fs.log.WithField("error", err.Error()).Error("Could not merge the SNI connections spilled to disk")
+	}
+}
+
 func (fs *FSImporter) buildUconnsProxy(uconnProxyMap map[string]*uconnproxy.Input) {
 	// non-optional module
 	if len(uconnProxyMap) > 0 {
@@ -586,7 +1216,7 @@ func (fs *FSImporter) buildBeacons(uconnMap map[string]*uconn.Input, hostMap map
 			}
 
 			// send uconns to beacon analysis
-			beaconRepo.Upsert(uconnMap, hostMap, minTimestamp, maxTimestamp)
+			beaconRepo.Upsert(uconnMap, hostMap, minTimestamp, maxTimestamp, fs.window)
 		} else {
 			fmt.Println("\t[!] No Beacon data to analyze")
 		}
@@ -605,7 +1235,7 @@ func (fs *FSImporter) buildProxyBeacons(uconnProxyMap map[string]*uconnproxy.Inp
 			}
 
 			// send proxy uconns to beacon analysis
-			beaconProxyRepo.Upsert(uconnProxyMap, hostMap, minTimestamp, maxTimestamp)
+			beaconProxyRepo.Upsert(uconnProxyMap, hostMap, minTimestamp, maxTimestamp, fs.window)
 		} else {
 			fmt.Println("\t[!] No Proxy Beacon data to analyze")
 		}
@@ -624,7 +1254,7 @@ func (fs *FSImporter) buildSNIBeacons(tlsMap map[string]*sniconn.TLSInput, httpM
 			}
 
 			// send SNI conns to beacon analysis
-			beaconSNIRepo.Upsert(tlsMap, httpMap, hostMap, minTimestamp, maxTimestamp)
+			beaconSNIRepo.Upsert(tlsMap, httpMap, hostMap, minTimestamp, maxTimestamp, fs.window)
 		} else {
 			fmt.Println("\t[!] No TLS or HTTP Beacon data to analyze")
 		}
@@ -651,14 +1281,13 @@ func (fs *FSImporter) buildUserAgent(useragentMap map[string]*useragent.Input, h
 }
 
 func (fs *FSImporter) updateTimestampRange() (int64, int64) {
-	session := fs.database.Session.Copy()
-	defer session.Close()
+	ctx := fs.database.Context()
 
 	// set collection name
 	collectionName := fs.config.T.Structure.UniqueConnTable
 
 	// check if collection already exists
-	names, _ := session.DB(fs.database.GetSelectedDB()).CollectionNames()
+	names, _ := fs.database.CollectionNames()
 
 	exists := false
 	// make sure collection exists
@@ -677,7 +1306,7 @@ func (fs *FSImporter) updateTimestampRange() (int64, int64) {
 	timestampMinQuery := []bson.M{
 		{"$project": bson.M{
 			"_id":     0,
-			"ts":      "$dat.ts",
+			"ts":      uconn.ChunkTimestampBounds,
 			"open_ts": bson.M{"$ifNull": []interface{}{"$open_ts", []interface{}{}}},
 		}},
 		{"$unwind": "$ts"},
@@ -693,7 +1322,7 @@ func (fs *FSImporter) updateTimestampRange() (int64, int64) {
 
 	// get iminimum timestamp
 	// sort by the timestamp, limit it to 1 (only returns first result)
-	err := session.DB(fs.database.GetSelectedDB()).C(collectionName).Pipe(timestampMinQuery).AllowDiskUse().One(&resultMin)
+	err := database.AggregateOne(ctx, fs.database.Collection(collectionName), timestampMinQuery, &resultMin)
 
 	if err != nil {
 		fs.log.WithFields(log.Fields{
@@ -706,7 +1335,7 @@ func (fs *FSImporter) updateTimestampRange() (int64, int64) {
 	timestampMaxQuery := []bson.M{
 		{"$project": bson.M{
 			"_id":     0,
-			"ts":      "$dat.ts",
+			"ts":      uconn.ChunkTimestampBounds,
 			"open_ts": bson.M{"$ifNull": []interface{}{"$open_ts", []interface{}{}}},
 		}},
 		{"$unwind": "$ts"},
@@ -722,7 +1351,7 @@ func (fs *FSImporter) updateTimestampRange() (int64, int64) {
 
 	// get max timestamp
 	// sort by the timestamp, limit it to 1 (only returns first result)
-	err = session.DB(fs.database.GetSelectedDB()).C(collectionName).Pipe(timestampMaxQuery).AllowDiskUse().One(&resultMax)
+	err = database.AggregateOne(ctx, fs.database.Collection(collectionName), timestampMaxQuery, &resultMax)
 
 	if err != nil {
 		fs.log.WithFields(log.Fields{
@@ -731,11 +1360,14 @@ func (fs *FSImporter) updateTimestampRange() (int64, int64) {
 		return 0, 0
 	}
 
+	// the selected time window replaces the observed range
+	resultMin.Timestamp, resultMax.Timestamp = fs.window.Clamp(resultMin.Timestamp, resultMax.Timestamp)
+
 	// since zeek records connections when they close, some connections that started before the ingested
 	// observation period can skew the ts range. We need to cap observation period to the last 24 hours
-	// for accurate beaconing analysis
+	// for accurate beaconing analysis. A window start chosen by the user is kept as it is.
 	tsMinCapped := resultMax.Timestamp - 24*60*60
-	if tsMinCapped > resultMin.Timestamp {
+	if fs.window.Since == 0 && tsMinCapped > resultMin.Timestamp {
 		resultMin.Timestamp = tsMinCapped
 	}
 
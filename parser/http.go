@@ -13,7 +13,7 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func parseHTTPEntry(parseHTTP *parsetypes.HTTP, filter filter, retVals ParseResults, logger *log.Logger) {
+func parseHTTPEntry(parseHTTP *parsetypes.HTTP, filter filter, retVals ParseResults, logger *log.Logger) entryOutcome {
 	// get source destination pair for connection record
 	src := parseHTTP.Source
 	dst := parseHTTP.Destination
@@ -29,44 +29,11 @@ func parseHTTPEntry(parseHTTP *parsetypes.HTTP, filter filter, retVals ParseResu
 			"src": parseHTTP.Source,
 			"dst": parseHTTP.Destination,
 		}).Error("Unable to parse valid ip address pair from http log entry, skipping entry.")
-		return
+		return entryOutcome{rejectedFor: rejectInvalidAddress}
 	}
 
 	// parse host
-	fqdn := parseHTTP.Host
-
-	// host field isn't always populated.
-	// as a second option, parse out the host from the URI.
-	// This isn't the first choice as it will take longer than
-	// just grabbing the fqdn from the host field
-	if fqdn == "" {
-		uri := parseHTTP.URI
-
-		minIndex := 0
-
-		// handle if the URI has :// present (e.g., http://, https://, etc.)
-		if protoIndex := strings.Index(uri, "://"); protoIndex != -1 {
-			minIndex = protoIndex + len("://")
-		}
-		uri = uri[minIndex:]
-
-		maxIndex := len(uri)
-		if portIdx := strings.Index(uri, ":"); portIdx > -1 {
-			// Case for if URI has the port number included (e.g., example.com:443).
-			// This will also handle if the URI has a path appended as the path
-			// appears after the port, so this will just lop off the path too.
-			maxIndex = portIdx
-		} else if pathIdx := strings.Index(uri, "/"); pathIdx > -1 {
-			// Case for if the URI did not have a port but had a path
-			// suffixed to it (e.g., example.com/somecoolpath
-			maxIndex = pathIdx
-		}
-		uri = uri[:maxIndex]
-
-		// at this point, the URI should be parsed down to just an FQDN
-		fqdn = uri
-
-	}
+	fqdn := httpFQDN(parseHTTP)
 
 	// parse method type
 	method := parseHTTP.Method
@@ -87,16 +54,18 @@ func parseHTTPEntry(parseHTTP *parsetypes.HTTP, filter filter, retVals ParseResu
 	// (e.g., beacons), where false positives might arise due to the proxy IP
 	// appearing as a destination, while still allowing for processing that
 	// data for the proxy modules
-	if dstIsProxy {
-		if filter.filterDomain(fqdn) || filter.filterSingleIP(srcIP) {
-			return
-		}
+	filteredBy := filter.domainFilterRule(fqdn)
+	if filteredBy == "" && dstIsProxy {
+		filteredBy = filter.singleIPFilterRule(srcIP)
 		fqdnAsIPAddress := net.ParseIP(fqdn)
-		if fqdnAsIPAddress != nil && filter.checkIfInternal(dstIP) && filter.filterConnPair(srcIP, fqdnAsIPAddress) {
-			return
+		if filteredBy == "" && fqdnAsIPAddress != nil && filter.checkIfInternal(dstIP) {
+			filteredBy = filter.connPairFilterRule(srcIP, fqdnAsIPAddress)
 		}
-	} else if filter.filterDomain(fqdn) || filter.filterConnPair(srcIP, dstIP) {
-		return
+	} else if filteredBy == "" {
+		filteredBy = filter.connPairFilterRule(srcIP, dstIP)
+	}
+	if filteredBy != "" {
+		return entryOutcome{filteredBy: filteredBy}
 	}
 
 	// disambiguate addresses which are not publicly routable
@@ -111,10 +80,12 @@ func parseHTTPEntry(parseHTTP *parsetypes.HTTP, filter filter, retVals ParseResu
 	// check if internal IP is requesting a connection through a proxy
 	if dstIsProxy {
 		updateProxiedUniqueConnectionsByHTTP(srcFQDNPair, dstUniqIP, parseHTTP, retVals)
-		return
+		return entryOutcome{}
 	}
 
 	updateHTTPConnectionsByHTTP(srcIP, dstUniqIP, srcFQDNPair, srcFQDNKey, parseHTTP, filter, retVals)
+
+	return entryOutcome{}
 }
 
 func updateUseragentsByHTTP(srcUniqIP data.UniqueIP, parseHTTP *parsetypes.HTTP, retVals ParseResults) {
@@ -228,3 +199,43 @@ func updateHTTPConnectionsByHTTP(srcIP net.IP, dstUniqIP data.UniqueIP, srcFQDNP
 		)
 	}
 }
+
+// httpFQDN returns the host an HTTP request was sent to
+func httpFQDN(parseHTTP *parsetypes.HTTP) string {
+	fqdn := parseHTTP.Host
+
+	// host field isn't always populated.
+	// as a second option, parse out the host from the URI.
+	// This isn't the first choice as it will take longer than
+	// just grabbing the fqdn from the host field
+	if fqdn == "" {
+		uri := parseHTTP.URI
+
+		minIndex := 0
+
+		// handle if the URI has :// present (e.g., http://, https://, etc.)
+		if protoIndex := strings.Index(uri, "://"); protoIndex != -1 {
+			minIndex = protoIndex + len("://")
+		}
+		uri = uri[minIndex:]
+
+		maxIndex := len(uri)
+		if portIdx := strings.Index(uri, ":"); portIdx > -1 {
+			// Case for if URI has the port number included (e.g., example.com:443).
+			// This will also handle if the URI has a path appended as the path
+			// appears after the port, so this will just lop off the path too.
+			maxIndex = portIdx
+		} else if pathIdx := strings.Index(uri, "/"); pathIdx > -1 {
+			// Case for if the URI did not have a port but had a path
+			// suffixed to it (e.g., example.com/somecoolpath
+			maxIndex = pathIdx
+		}
+		uri = uri[:maxIndex]
+
+		// at this point, the URI should be parsed down to just an FQDN
+		fqdn = uri
+
+	}
+
+	return fqdn
+}
@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"net"
+	"strings"
+
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/logon"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func parseKerberosEntry(parseKerberos *parsetypes.Kerberos, filter filter, retVals ParseResults, logger *log.Logger) entryOutcome {
+	// a failed request doesn't show the account was in use on the host
+	if !parseKerberos.Success {
+		return entryOutcome{}
+	}
+
+	account := kerberosAccount(parseKerberos.Client)
+	if account == "" {
+		return entryOutcome{}
+	}
+
+	return parseLogon(parseKerberos.Source, parseKerberos.UID, parseKerberos.AgentUUID, parseKerberos.AgentHostname,
+		"kerberos", account, parseKerberos.TimeStamp, filter, retVals, logger)
+}
+
+func parseNTLMEntry(parseNTLM *parsetypes.NTLM, filter filter, retVals ParseResults, logger *log.Logger) entryOutcome {
+	// older bro versions record the outcome in the status field instead of the success flag
+	if !parseNTLM.Success && parseNTLM.Status != "SUCCESS" {
+		return entryOutcome{}
+	}
+
+	account := ntlmAccount(parseNTLM.DomainName, parseNTLM.Username)
+	if account == "" {
+		return entryOutcome{}
+	}
+
+	return parseLogon(parseNTLM.Source, parseNTLM.UID, parseNTLM.AgentUUID, parseNTLM.AgentHostname,
+		"ntlm", account, parseNTLM.TimeStamp, filter, retVals, logger)
+}
+
+// parseLogon records an account authenticating from the source of a connection
+func parseLogon(source, uid, agentUUID, agentHostname, protocol, account string, ts int64,
+	filter filter, retVals ParseResults, logger *log.Logger) entryOutcome {
+	srcIP := net.ParseIP(source)
+	if srcIP == nil {
+		logger.WithFields(log.Fields{
+			"uid": uid,
+			"src": source,
+		}).Error("Unable to parse valid ip address from " + protocol + " log entry, skipping entry.")
+		return entryOutcome{rejectedFor: rejectInvalidAddress}
+	}
+
+	// the client usually authenticates against an internal server, so only the client is filtered.
+	// Filtering the pair would discard every logon between internal hosts.
+	if filteredBy := filter.singleIPFilterRule(srcIP); filteredBy != "" {
+		return entryOutcome{filteredBy: filteredBy}
+	}
+
+	srcUniqIP := data.NewUniqueIP(srcIP, agentUUID, agentHostname)
+
+	updateLogonsBySource(srcUniqIP, protocol, account, ts, retVals)
+
+	return entryOutcome{}
+}
+
+func updateLogonsBySource(srcUniqIP data.UniqueIP, protocol, account string, ts int64, retVals ParseResults) {
+	srcKey := srcUniqIP.MapKey()
+
+	retVals.LogonLock.Lock()
+	defer retVals.LogonLock.Unlock()
+
+	if _, ok := retVals.LogonMap[srcKey]; !ok {
+		retVals.LogonMap[srcKey] = &logon.Input{
+			Host:     srcUniqIP,
+			Accounts: make(map[string]*logon.AccountInput),
+		}
+	}
+
+	if _, ok := retVals.LogonMap[srcKey].Accounts[account]; !ok {
+		retVals.LogonMap[srcKey].Accounts[account] = &logon.AccountInput{
+			Protocols: make(data.StringSet),
+			FirstSeen: ts,
+			LastSeen:  ts,
+		}
+	}
+
+	accountInput := retVals.LogonMap[srcKey].Accounts[account]
+
+	// ///// RECORD THE AUTHENTICATION OF THE ACCOUNT /////
+	accountInput.Logons++
+	accountInput.Protocols.Insert(protocol)
+
+	if ts < accountInput.FirstSeen {
+		accountInput.FirstSeen = ts
+	}
+	if ts > accountInput.LastSeen {
+		accountInput.LastSeen = ts
+	}
+}
+
+// kerberosAccount converts a Kerberos client principal such as "jdoe/EXAMPLE.COM" into
+// "jdoe@EXAMPLE.COM". An empty string is returned if the principal doesn't identify a user.
+func kerberosAccount(client string) string {
+	user, realm := client, ""
+	if sep := strings.LastIndex(client, "/"); sep != -1 {
+		user, realm = client[:sep], client[sep+1:]
+	}
+
+	if !isUserAccount(user) {
+		return ""
+	}
+	// Windows doesn't distinguish the case of user names
+	if realm == "" {
+		return strings.ToLower(user)
+	}
+	return strings.ToLower(user) + "@" + strings.ToUpper(realm)
+}
+
+// ntlmAccount names an NTLM user as "EXAMPLE\jdoe". An empty string is returned if the
+// username doesn't identify a user.
+func ntlmAccount(domain, username string) string {
+	if !isUserAccount(username) {
+		return ""
+	}
+	// Windows doesn't distinguish the case of user and domain names
+	if domain == "" || domain == "-" {
+		return strings.ToLower(username)
+	}
+	return strings.ToUpper(domain) + "\\" + strings.ToLower(username)
+}
+
+// isUserAccount determines whether a user name belongs to a person, as opposed to
+// a machine account or an anonymous logon
+func isUserAccount(user string) bool {
+	return user != "" && user != "-" && !strings.HasSuffix(user, "$") && !strings.EqualFold(user, "anonymous")
+}
@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogonEntries(t *testing.T) {
+	internal, _ := util.ParseSubnets([]string{"10.0.0.0/8"})
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	fs := &FSImporter{filter: filter{internal: internal}, log: logger}
+	retVals := newParseResults()
+
+	entries := []parsetypes.BroData{
+		&parsetypes.Kerberos{TimeStamp: 1622548802, Client: "JDoe/example.com", Success: true},
+		&parsetypes.NTLM{TimeStamp: 1622548801, DomainName: "example", Username: "jdoe", Success: true},
+		// older bro versions record the outcome of NTLM authentication in the status field
+		&parsetypes.NTLM{TimeStamp: 1622548805, DomainName: "EXAMPLE", Username: "jdoe", Status: "SUCCESS"},
+		&parsetypes.NTLM{TimeStamp: 1622548803, DomainName: "EXAMPLE", Username: "asmith", Success: true},
+		// failed requests, machine accounts, and anonymous logons don't identify a user
+		&parsetypes.Kerberos{TimeStamp: 1622548804, Client: "mallory/EXAMPLE.COM"},
+		&parsetypes.Kerberos{TimeStamp: 1622548804, Client: "WS01$/EXAMPLE.COM", Success: true},
+		&parsetypes.NTLM{TimeStamp: 1622548804, Username: "ANONYMOUS", Success: true},
+	}
+	for _, entry := range entries {
+		switch typedEntry := entry.(type) {
+		case *parsetypes.Kerberos:
+			typedEntry.Source, typedEntry.Destination = "10.0.0.5", "10.0.0.2"
+		case *parsetypes.NTLM:
+			typedEntry.Source, typedEntry.Destination = "10.0.0.5", "10.0.0.3"
+		}
+		fs.parseEntry(entry, retVals, logger)
+	}
+
+	// an entry with an invalid address is skipped
+	fs.parseEntry(&parsetypes.NTLM{Source: "not an ip", Destination: "10.0.0.3", Username: "jdoe", Success: true}, retVals, logger)
+
+	require.Len(t, retVals.LogonMap, 1)
+	for _, input := range retVals.LogonMap {
+		assert.Equal(t, "10.0.0.5", input.Host.IP)
+		require.Len(t, input.Accounts, 3)
+
+		kerberos := input.Accounts["jdoe@EXAMPLE.COM"]
+		require.NotNil(t, kerberos)
+		assert.Equal(t, int64(1), kerberos.Logons)
+		assert.True(t, kerberos.Protocols.Contains("kerberos"))
+
+		ntlm := input.Accounts[`EXAMPLE\jdoe`]
+		require.NotNil(t, ntlm)
+		assert.Equal(t, int64(2), ntlm.Logons)
+		assert.True(t, ntlm.Protocols.Contains("ntlm"))
+		assert.Equal(t, int64(1622548801), ntlm.FirstSeen)
+		assert.Equal(t, int64(1622548805), ntlm.LastSeen)
+
+		assert.Contains(t, input.Accounts, `EXAMPLE\asmith`)
+	}
+}
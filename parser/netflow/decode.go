@@ -0,0 +1,558 @@
+package netflow
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// message and record layouts
+// https://www.cisco.com/c/en/us/td/docs/net_mgmt/netflow_collection_engine/3-6/user/guide/format.html
+// https://datatracker.ietf.org/doc/html/rfc3954
+// https://datatracker.ietf.org/doc/html/rfc7011
+const (
+	v5HeaderLength    = 24
+	v5RecordLength    = 48
+	v5MaxRecords      = 30
+	v9HeaderLength    = 20
+	ipfixHeaderLength = 16
+	setHeaderLength   = 4
+
+	// set IDs below 256 are reserved for templates
+	v9TemplateSetID           = 0
+	v9OptionsTemplateSetID    = 1
+	ipfixTemplateSetID        = 2
+	ipfixOptionsTemplateSetID = 3
+	minDataSetID              = 256
+
+	// variableLength marks an IPFIX field whose length is given with each record
+	variableLength = 65535
+)
+
+// information elements used to build conn records. NetFlow v9 field types share
+// their numbering with the IPFIX information elements.
+// https://www.iana.org/assignments/ipfix/ipfix.xhtml
+const (
+	ieOctetDeltaCount            uint16 = 1
+	iePacketDeltaCount           uint16 = 2
+	ieProtocol                   uint16 = 4
+	ieTCPControlBits             uint16 = 6
+	ieSourcePort                 uint16 = 7
+	ieSourceIPv4Address          uint16 = 8
+	ieDestinationPort            uint16 = 11
+	ieDestinationIPv4Address     uint16 = 12
+	ieFlowEndSysUpTime           uint16 = 21
+	ieFlowStartSysUpTime         uint16 = 22
+	ieOutBytes                   uint16 = 23
+	ieOutPkts                    uint16 = 24
+	ieSourceIPv6Address          uint16 = 27
+	ieDestinationIPv6Address     uint16 = 28
+	ieOctetTotalCount            uint16 = 85
+	iePacketTotalCount           uint16 = 86
+	ieFlowStartSeconds           uint16 = 150
+	ieFlowEndSeconds             uint16 = 151
+	ieFlowStartMilliseconds      uint16 = 152
+	ieFlowEndMilliseconds        uint16 = 153
+	ieFlowStartMicroseconds      uint16 = 154
+	ieFlowEndMicroseconds        uint16 = 155
+	ieFlowStartNanoseconds       uint16 = 156
+	ieFlowEndNanoseconds         uint16 = 157
+	ieFlowStartDeltaMicroseconds uint16 = 158
+	ieFlowEndDeltaMicroseconds   uint16 = 159
+	ieInitiatorOctets            uint16 = 231
+	ieResponderOctets            uint16 = 232
+	ieInitiatorPackets           uint16 = 298
+	ieResponderPackets           uint16 = 299
+	reverseInformationElementPEN        = 29305 // https://datatracker.ietf.org/doc/html/rfc5103
+	ntpEpochOffset                      = 2208988800
+)
+
+var errTruncated = errors.New("truncated flow message")
+
+type (
+	// templateField describes a single field of a NetFlow v9 or IPFIX template
+	templateField struct {
+		id         uint16
+		enterprise uint32
+		length     uint16
+	}
+
+	// templateKey identifies a template. Template IDs are only unique within the
+	// observation domain (source ID) of the exporter.
+	templateKey struct {
+		version uint16
+		domain  uint32
+		id      uint16
+	}
+
+	// exportContext holds the fields of a message header used to interpret its records
+	exportContext struct {
+		exportTime time.Time
+		sysUpTime  uint32 // milliseconds since the exporter started (NetFlow v9 only)
+	}
+
+	// flowRecord holds the traffic of a flow in one direction, or in both
+	// directions for exporters which report biflows
+	flowRecord struct {
+		srcIP       net.IP
+		dstIP       net.IP
+		srcPort     int
+		dstPort     int
+		proto       uint8
+		start       time.Time
+		end         time.Time
+		bytes       int64
+		pkts        int64
+		tcpFlags    uint8
+		revBytes    int64
+		revPkts     int64
+		revTCPFlags uint8
+		biflow      bool
+	}
+
+	// decoder reads a stream of NetFlow v5, NetFlow v9, and IPFIX messages
+	decoder struct {
+		r               *bufio.Reader
+		name            string
+		logger          *log.Logger
+		templates       map[templateKey][]templateField
+		optionTemplates map[templateKey]bool
+		missingTemplate int // data sets skipped because their template has not been seen
+	}
+)
+
+// newDecoder creates a decoder for the messages in the stream
+func newDecoder(r *bufio.Reader, name string, logger *log.Logger) *decoder {
+	return &decoder{
+		r:               r,
+		name:            name,
+		logger:          logger,
+		templates:       make(map[templateKey][]templateField),
+		optionTemplates: make(map[templateKey]bool),
+	}
+}
+
+// decode reads every message in the stream and adds its flows to the stitcher
+func (d *decoder) decode(s *stitcher) error {
+	defer func() {
+		if d.missingTemplate > 0 {
+			d.logger.WithFields(log.Fields{
+				"file":      d.name,
+				"data_sets": d.missingTemplate,
+			}).Warn("Skipped flow records which were exported before their template")
+		}
+	}()
+
+	for {
+		versionBytes, err := d.r.Peek(2)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch version := binary.BigEndian.Uint16(versionBytes); version {
+		case versionNetFlow5:
+			err = d.decodeV5(s)
+		case versionNetFlow9:
+			err = d.decodeV9(s)
+		case versionIPFIX:
+			err = d.decodeIPFIX(s)
+		default:
+			err = fmt.Errorf("unsupported flow export version %d", version)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// decodeV5 reads a NetFlow v5 message. The records have a fixed layout.
+func (d *decoder) decodeV5(s *stitcher) error {
+	header := make([]byte, v5HeaderLength)
+	if _, err := io.ReadFull(d.r, header); err != nil {
+		return errTruncated
+	}
+	count := int(binary.BigEndian.Uint16(header[2:]))
+	if count > v5MaxRecords {
+		return fmt.Errorf("invalid NetFlow v5 record count %d", count)
+	}
+	ctx := exportContext{
+		exportTime: time.Unix(int64(binary.BigEndian.Uint32(header[8:])), int64(binary.BigEndian.Uint32(header[12:]))),
+		sysUpTime:  binary.BigEndian.Uint32(header[4:]),
+	}
+
+	records := make([]byte, count*v5RecordLength)
+	if _, err := io.ReadFull(d.r, records); err != nil {
+		return errTruncated
+	}
+	for i := 0; i < count; i++ {
+		record := records[i*v5RecordLength : (i+1)*v5RecordLength]
+		s.add(&flowRecord{
+			srcIP:    net.IP(record[0:4]),
+			dstIP:    net.IP(record[4:8]),
+			pkts:     int64(binary.BigEndian.Uint32(record[16:])),
+			bytes:    int64(binary.BigEndian.Uint32(record[20:])),
+			start:    ctx.upTime(binary.BigEndian.Uint32(record[24:])),
+			end:      ctx.upTime(binary.BigEndian.Uint32(record[28:])),
+			srcPort:  int(binary.BigEndian.Uint16(record[32:])),
+			dstPort:  int(binary.BigEndian.Uint16(record[34:])),
+			tcpFlags: record[37],
+			proto:    record[38],
+		})
+	}
+	return nil
+}
+
+// decodeV9 reads a NetFlow v9 message. The header does not give the length of the
+// message, so flow sets are read until the next message header or the end of the stream.
+// Set IDs 2 through 255 are reserved, so the version number of the next header cannot
+// be mistaken for a set ID.
+func (d *decoder) decodeV9(s *stitcher) error {
+	header := make([]byte, v9HeaderLength)
+	if _, err := io.ReadFull(d.r, header); err != nil {
+		return errTruncated
+	}
+	ctx := exportContext{
+		sysUpTime:  binary.BigEndian.Uint32(header[4:]),
+		exportTime: time.Unix(int64(binary.BigEndian.Uint32(header[8:])), 0),
+	}
+	domain := binary.BigEndian.Uint32(header[16:])
+
+	for {
+		idBytes, err := d.r.Peek(2)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		id := binary.BigEndian.Uint16(idBytes)
+		if id > v9OptionsTemplateSetID && id < minDataSetID {
+			return nil
+		}
+
+		body, err := d.readSet()
+		if err != nil {
+			return err
+		}
+		d.decodeSet(versionNetFlow9, domain, id, body, ctx, s)
+	}
+}
+
+// decodeIPFIX reads an IPFIX message
+func (d *decoder) decodeIPFIX(s *stitcher) error {
+	header := make([]byte, ipfixHeaderLength)
+	if _, err := io.ReadFull(d.r, header); err != nil {
+		return errTruncated
+	}
+	length := int(binary.BigEndian.Uint16(header[2:]))
+	if length < ipfixHeaderLength {
+		return fmt.Errorf("invalid IPFIX message length %d", length)
+	}
+	ctx := exportContext{
+		exportTime: time.Unix(int64(binary.BigEndian.Uint32(header[4:])), 0),
+	}
+	domain := binary.BigEndian.Uint32(header[12:])
+
+	message := make([]byte, length-ipfixHeaderLength)
+	if _, err := io.ReadFull(d.r, message); err != nil {
+		return errTruncated
+	}
+	for len(message) >= setHeaderLength {
+		id := binary.BigEndian.Uint16(message)
+		setLength := int(binary.BigEndian.Uint16(message[2:]))
+		if setLength < setHeaderLength || setLength > len(message) {
+			return errTruncated
+		}
+		d.decodeSet(versionIPFIX, domain, id, message[setHeaderLength:setLength], ctx, s)
+		message = message[setLength:]
+	}
+	return nil
+}
+
+// readSet reads a NetFlow v9 flow set from the stream and returns its body
+func (d *decoder) readSet() ([]byte, error) {
+	header := make([]byte, setHeaderLength)
+	if _, err := io.ReadFull(d.r, header); err != nil {
+		return nil, errTruncated
+	}
+	length := int(binary.BigEndian.Uint16(header[2:]))
+	if length < setHeaderLength {
+		return nil, fmt.Errorf("invalid flow set length %d", length)
+	}
+	body := make([]byte, length-setHeaderLength)
+	if _, err := io.ReadFull(d.r, body); err != nil {
+		return nil, errTruncated
+	}
+	return body, nil
+}
+
+// decodeSet handles the templates or data records in a single set
+func (d *decoder) decodeSet(version uint16, domain uint32, id uint16, body []byte, ctx exportContext, s *stitcher) {
+	switch {
+	case version == versionNetFlow9 && id == v9TemplateSetID,
+		version == versionIPFIX && id == ipfixTemplateSetID:
+		d.decodeTemplates(version, domain, body, false)
+	case version == versionNetFlow9 && id == v9OptionsTemplateSetID,
+		version == versionIPFIX && id == ipfixOptionsTemplateSetID:
+		d.decodeTemplates(version, domain, body, true)
+	case id >= minDataSetID:
+		key := templateKey{version: version, domain: domain, id: id}
+		if d.optionTemplates[key] {
+			// options data describes the exporter rather than any traffic
+			return
+		}
+		fields, ok := d.templates[key]
+		if !ok {
+			d.missingTemplate++
+			return
+		}
+		d.decodeData(fields, body, ctx, s)
+	}
+}
+
+// decodeTemplates records the templates defined in a template set. Option templates
+// are only recorded so that their data sets may be recognized and skipped.
+func (d *decoder) decodeTemplates(version uint16, domain uint32, body []byte, options bool) {
+	for len(body) >= 4 {
+		key := templateKey{version: version, domain: domain, id: binary.BigEndian.Uint16(body)}
+		if key.id < minDataSetID {
+			// the rest of the set is padding
+			return
+		}
+
+		if options {
+			if version == versionNetFlow9 {
+				// NetFlow v9 gives the lengths of the scope and option fields in bytes
+				if len(body) < 6 {
+					return
+				}
+				fieldBytes := int(binary.BigEndian.Uint16(body[2:])) + int(binary.BigEndian.Uint16(body[4:]))
+				if len(body) < 6+fieldBytes {
+					return
+				}
+				d.optionTemplates[key] = true
+				body = body[6+fieldBytes:]
+				continue
+			}
+			if len(body) < 6 {
+				return
+			}
+			fieldCount := int(binary.BigEndian.Uint16(body[2:]))
+			_, rest, ok := readFieldSpecifiers(version, body[6:], fieldCount)
+			if !ok {
+				return
+			}
+			d.optionTemplates[key] = true
+			body = rest
+			continue
+		}
+
+		fieldCount := int(binary.BigEndian.Uint16(body[2:]))
+		fields, rest, ok := readFieldSpecifiers(version, body[4:], fieldCount)
+		if !ok {
+			return
+		}
+		if fieldCount == 0 {
+			// IPFIX withdraws a template by sending it without any fields
+			delete(d.templates, key)
+		} else {
+			d.templates[key] = fields
+		}
+		body = rest
+	}
+}
+
+// readFieldSpecifiers reads the field specifiers of a template
+func readFieldSpecifiers(version uint16, body []byte, count int) ([]templateField, []byte, bool) {
+	fields := make([]templateField, 0, count)
+	for i := 0; i < count; i++ {
+		if len(body) < 4 {
+			return nil, nil, false
+		}
+		field := templateField{
+			id:     binary.BigEndian.Uint16(body),
+			length: binary.BigEndian.Uint16(body[2:]),
+		}
+		body = body[4:]
+
+		// IPFIX marks enterprise specific information elements with the high bit
+		if version == versionIPFIX && field.id&0x8000 != 0 {
+			if len(body) < 4 {
+				return nil, nil, false
+			}
+			field.id &= 0x7fff
+			field.enterprise = binary.BigEndian.Uint32(body)
+			body = body[4:]
+		}
+		fields = append(fields, field)
+	}
+	return fields, body, true
+}
+
+// decodeData reads the records of a data set using its template
+func (d *decoder) decodeData(fields []templateField, body []byte, ctx exportContext, s *stitcher) {
+	minLength := 0
+	for _, field := range fields {
+		if field.length == variableLength {
+			minLength++
+		} else {
+			minLength += int(field.length)
+		}
+	}
+	if minLength == 0 {
+		return
+	}
+
+	// anything shorter than a record at the end of the set is padding
+	for len(body) >= minLength {
+		record := &flowRecord{}
+		for _, field := range fields {
+			length := int(field.length)
+			if field.length == variableLength {
+				if len(body) < 1 {
+					return
+				}
+				length = int(body[0])
+				body = body[1:]
+				if length == 255 {
+					if len(body) < 2 {
+						return
+					}
+					length = int(binary.BigEndian.Uint16(body))
+					body = body[2:]
+				}
+			}
+			if len(body) < length {
+				return
+			}
+			record.setField(field, body[:length], ctx)
+			body = body[length:]
+		}
+
+		if record.srcIP == nil || record.dstIP == nil {
+			continue
+		}
+		if record.start.IsZero() {
+			record.start = record.end
+		}
+		if record.end.IsZero() {
+			record.end = record.start
+		}
+		if record.start.IsZero() {
+			record.start, record.end = ctx.exportTime, ctx.exportTime
+		}
+		s.add(record)
+	}
+}
+
+// setField stores the value of a single field in the flow record
+func (f *flowRecord) setField(field templateField, value []byte, ctx exportContext) {
+	if field.enterprise == reverseInformationElementPEN {
+		switch field.id {
+		case ieOctetDeltaCount, ieOctetTotalCount:
+			f.revBytes = int64(readUint(value))
+			f.biflow = true
+		case iePacketDeltaCount, iePacketTotalCount:
+			f.revPkts = int64(readUint(value))
+			f.biflow = true
+		case ieTCPControlBits:
+			f.revTCPFlags = uint8(readUint(value))
+		}
+		return
+	}
+	if field.enterprise != 0 {
+		return
+	}
+
+	switch field.id {
+	case ieOctetDeltaCount, ieOctetTotalCount, ieInitiatorOctets:
+		f.bytes = int64(readUint(value))
+	case iePacketDeltaCount, iePacketTotalCount, ieInitiatorPackets:
+		f.pkts = int64(readUint(value))
+	case ieOutBytes, ieResponderOctets:
+		f.revBytes = int64(readUint(value))
+		f.biflow = true
+	case ieOutPkts, ieResponderPackets:
+		f.revPkts = int64(readUint(value))
+		f.biflow = true
+	case ieProtocol:
+		f.proto = uint8(readUint(value))
+	case ieTCPControlBits:
+		f.tcpFlags = uint8(readUint(value))
+	case ieSourcePort:
+		f.srcPort = int(readUint(value))
+	case ieDestinationPort:
+		f.dstPort = int(readUint(value))
+	case ieSourceIPv4Address, ieSourceIPv6Address:
+		f.srcIP = readIP(value)
+	case ieDestinationIPv4Address, ieDestinationIPv6Address:
+		f.dstIP = readIP(value)
+	case ieFlowStartSysUpTime:
+		f.start = ctx.upTime(uint32(readUint(value)))
+	case ieFlowEndSysUpTime:
+		f.end = ctx.upTime(uint32(readUint(value)))
+	case ieFlowStartSeconds:
+		f.start = time.Unix(int64(readUint(value)), 0)
+	case ieFlowEndSeconds:
+		f.end = time.Unix(int64(readUint(value)), 0)
+	case ieFlowStartMilliseconds:
+		f.start = time.Unix(0, int64(readUint(value))*int64(time.Millisecond))
+	case ieFlowEndMilliseconds:
+		f.end = time.Unix(0, int64(readUint(value))*int64(time.Millisecond))
+	case ieFlowStartMicroseconds, ieFlowStartNanoseconds:
+		f.start = readNTPTime(value)
+	case ieFlowEndMicroseconds, ieFlowEndNanoseconds:
+		f.end = readNTPTime(value)
+	case ieFlowStartDeltaMicroseconds:
+		f.start = ctx.exportTime.Add(-time.Duration(readUint(value)) * time.Microsecond)
+	case ieFlowEndDeltaMicroseconds:
+		f.end = ctx.exportTime.Add(-time.Duration(readUint(value)) * time.Microsecond)
+	}
+}
+
+// upTime converts a time given in milliseconds since the exporter started into a timestamp
+func (ctx exportContext) upTime(millis uint32) time.Time {
+	// the subtraction wraps around along with the exporter's uptime counter
+	age := time.Duration(ctx.sysUpTime-millis) * time.Millisecond
+	return ctx.exportTime.Add(-age)
+}
+
+// readUint reads a big endian unsigned integer. Exporters may shorten integer
+// fields, so any length up to 8 bytes is accepted.
+func readUint(value []byte) uint64 {
+	var result uint64
+	for i := 0; i < len(value) && i < 8; i++ {
+		result = result<<8 | uint64(value[i])
+	}
+	return result
+}
+
+// readIP copies an IPv4 or IPv6 address out of the record
+func readIP(value []byte) net.IP {
+	if len(value) != net.IPv4len && len(value) != net.IPv6len {
+		return nil
+	}
+	ip := make(net.IP, len(value))
+	copy(ip, value)
+	return ip
+}
+
+// readNTPTime reads a timestamp in the 64 bit NTP format used by the
+// microsecond and nanosecond IPFIX timestamps
+func readNTPTime(value []byte) time.Time {
+	if len(value) != 8 {
+		return time.Time{}
+	}
+	seconds := int64(binary.BigEndian.Uint32(value)) - ntpEpochOffset
+	fraction := uint64(binary.BigEndian.Uint32(value[4:]))
+	return time.Unix(seconds, int64(fraction*uint64(time.Second)>>32))
+}
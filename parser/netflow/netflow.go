@@ -0,0 +1,96 @@
+// Package netflow synthesizes Zeek style conn records from flow exports so that sites
+// which only export flow records can be imported without Zeek. NetFlow v5, NetFlow v9,
+// and IPFIX messages are decoded natively, while nfcapd files are read through nfdump.
+// The unidirectional flows reported by most exporters are stitched back together into
+// connections. Flow records carry no application layer data, so only conn records are
+// produced.
+package netflow
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/activecm/rita/parser/parsetypes"
+	log "github.com/sirupsen/logrus"
+)
+
+// flow export versions as found in the first two bytes of each message
+const (
+	versionNetFlow5 uint16 = 5
+	versionNetFlow9 uint16 = 9
+	versionIPFIX    uint16 = 10
+)
+
+// nfcapdMagic begins every file written by nfcapd. It is stored little endian.
+const nfcapdMagic uint16 = 0xA50C
+
+var errNotFlowCapture = errors.New("not a NetFlow, IPFIX, or nfcapd file")
+
+// IsFlowFile checks whether the file name is one used for flow captures. nfcapd names
+// its files nfcapd.YYYYMMDDhhmm and writes to nfcapd.current.* while collecting.
+func IsFlowFile(name string) bool {
+	base := filepath.Base(name)
+	if strings.HasPrefix(base, "nfcapd.") {
+		return !strings.HasPrefix(base, "nfcapd.current")
+	}
+	return strings.HasSuffix(base, ".ipfix") ||
+		strings.HasSuffix(base, ".netflow") ||
+		strings.HasSuffix(base, ".nf")
+}
+
+// IsFlowCapture reports whether the given magic bytes (the first four bytes of a file)
+// identify an nfcapd file or a NetFlow v5, NetFlow v9, or IPFIX message stream
+func IsFlowCapture(magic []byte) bool {
+	if len(magic) < 4 {
+		return false
+	}
+	if binary.LittleEndian.Uint16(magic) == nfcapdMagic {
+		return true
+	}
+
+	version := binary.BigEndian.Uint16(magic)
+	// the second field is the record count for NetFlow and the message length for IPFIX
+	second := binary.BigEndian.Uint16(magic[2:])
+	switch version {
+	case versionNetFlow5:
+		return second >= 1 && second <= v5MaxRecords
+	case versionNetFlow9:
+		return second >= 1
+	case versionIPFIX:
+		return second >= ipfixHeaderLength
+	}
+	return false
+}
+
+// ReadFlows reads every flow from an nfcapd file or a NetFlow/IPFIX message stream and
+// passes the synthesized conn records to emit. The name of the file seeds the connection UIDs.
+func ReadFlows(r io.Reader, name string, emit func(parsetypes.BroData), logger *log.Logger) error {
+	buffered := bufio.NewReaderSize(r, 1<<16)
+	magic, err := buffered.Peek(4)
+	if err != nil || !IsFlowCapture(magic) {
+		return errNotFlowCapture
+	}
+
+	stitcher := newStitcher(name, emit)
+
+	// nfdump reads the file on its own, so the name must be the path to the file
+	if binary.LittleEndian.Uint16(magic) == nfcapdMagic {
+		err = readNfcapd(name, stitcher)
+		stitcher.flush()
+		return err
+	}
+
+	err = newDecoder(buffered, name, logger).decode(stitcher)
+	stitcher.flush()
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"file":  name,
+			"error": err.Error(),
+		}).Error("Stopped reading corrupt flow capture")
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package netflow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/parser/parsetypes"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testExport = time.Unix(1600000000, 0).UTC()
+
+// testFlow describes a flow written into the test exports
+type testFlow struct {
+	src, dst         string
+	srcPort, dstPort uint16
+	proto            uint8
+	flags            uint8
+	pkts, bytes      uint32
+	first, last      uint32 // milliseconds of exporter uptime
+}
+
+// v5Message builds a NetFlow v5 message exported at testExport after uptime milliseconds
+func v5Message(uptime uint32, flows []testFlow) []byte {
+	msg := make([]byte, v5HeaderLength+len(flows)*v5RecordLength)
+	binary.BigEndian.PutUint16(msg, versionNetFlow5)
+	binary.BigEndian.PutUint16(msg[2:], uint16(len(flows)))
+	binary.BigEndian.PutUint32(msg[4:], uptime)
+	binary.BigEndian.PutUint32(msg[8:], uint32(testExport.Unix()))
+
+	for i, f := range flows {
+		record := msg[v5HeaderLength+i*v5RecordLength:]
+		copy(record, net.ParseIP(f.src).To4())
+		copy(record[4:], net.ParseIP(f.dst).To4())
+		binary.BigEndian.PutUint32(record[16:], f.pkts)
+		binary.BigEndian.PutUint32(record[20:], f.bytes)
+		binary.BigEndian.PutUint32(record[24:], f.first)
+		binary.BigEndian.PutUint32(record[28:], f.last)
+		binary.BigEndian.PutUint16(record[32:], f.srcPort)
+		binary.BigEndian.PutUint16(record[34:], f.dstPort)
+		record[37] = f.flags
+		record[38] = f.proto
+	}
+	return msg
+}
+
+// set wraps a body in a flow set header, padding it to a multiple of four bytes
+func set(id uint16, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	header := make([]byte, setHeaderLength)
+	binary.BigEndian.PutUint16(header, id)
+	binary.BigEndian.PutUint16(header[2:], uint16(setHeaderLength+len(body)))
+	return append(header, body...)
+}
+
+// fields writes field specifiers given as pairs of field types and lengths
+func fields(specifiers ...uint16) []byte {
+	var buf []byte
+	for _, value := range specifiers {
+		buf = appendUint16(buf, value)
+	}
+	return buf
+}
+
+func appendUint16(buf []byte, value uint16) []byte {
+	return append(buf, byte(value>>8), byte(value))
+}
+
+func appendUint32(buf []byte, value uint32) []byte {
+	return append(appendUint16(buf, uint16(value>>16)), byte(value>>8), byte(value))
+}
+
+func appendUint64(buf []byte, value uint64) []byte {
+	return appendUint32(appendUint32(buf, uint32(value>>32)), uint32(value))
+}
+
+// v9Message builds a NetFlow v9 message exported at testExport after uptime milliseconds
+func v9Message(uptime uint32, sets ...[]byte) []byte {
+	msg := make([]byte, v9HeaderLength)
+	binary.BigEndian.PutUint16(msg, versionNetFlow9)
+	binary.BigEndian.PutUint16(msg[2:], uint16(len(sets)))
+	binary.BigEndian.PutUint32(msg[4:], uptime)
+	binary.BigEndian.PutUint32(msg[8:], uint32(testExport.Unix()))
+	binary.BigEndian.PutUint32(msg[16:], 1)
+	for _, s := range sets {
+		msg = append(msg, s...)
+	}
+	return msg
+}
+
+// ipfixMessage builds an IPFIX message exported at testExport
+func ipfixMessage(sets ...[]byte) []byte {
+	msg := make([]byte, ipfixHeaderLength)
+	for _, s := range sets {
+		msg = append(msg, s...)
+	}
+	binary.BigEndian.PutUint16(msg, versionIPFIX)
+	binary.BigEndian.PutUint16(msg[2:], uint16(len(msg)))
+	binary.BigEndian.PutUint32(msg[4:], uint32(testExport.Unix()))
+	binary.BigEndian.PutUint32(msg[12:], 1)
+	return msg
+}
+
+func readTestFlows(t *testing.T, data []byte) []*parsetypes.Conn {
+	var conns []*parsetypes.Conn
+	err := ReadFlows(bytes.NewReader(data), "test.netflow", func(entry parsetypes.BroData) {
+		conn, ok := entry.(*parsetypes.Conn)
+		require.True(t, ok)
+		conns = append(conns, conn)
+	}, log.New())
+	require.NoError(t, err)
+	return conns
+}
+
+func TestIsFlowCapture(t *testing.T) {
+	assert.True(t, IsFlowCapture(v5Message(0, []testFlow{{src: "10.0.0.1", dst: "10.0.0.2"}})))
+	assert.True(t, IsFlowCapture(v9Message(0, set(v9TemplateSetID, nil))))
+	assert.False(t, IsFlowCapture(v9Message(0)), "v9 messages without any sets are not flow captures")
+	assert.True(t, IsFlowCapture(ipfixMessage()))
+	assert.True(t, IsFlowCapture([]byte{0x0c, 0xa5, 0x01, 0x00}))
+	assert.False(t, IsFlowCapture([]byte("#separator \\x09")))
+	assert.False(t, IsFlowCapture([]byte{0, 5}))
+
+	assert.True(t, IsFlowFile("/data/nfcapd.202001011200"))
+	assert.True(t, IsFlowFile("export.ipfix"))
+	assert.False(t, IsFlowFile("/data/nfcapd.current.1234"))
+	assert.False(t, IsFlowFile("conn.log"))
+}
+
+func TestReadNetFlowV5(t *testing.T) {
+	// the two directions of a TCP connection are reported as separate flows
+	conns := readTestFlows(t, v5Message(10000, []testFlow{
+		{"93.184.216.34", "10.0.0.1", 443, 50000, protoTCP, tcpSYN | tcpACK | tcpFIN, 8, 6000, 4100, 6000},
+		{"10.0.0.1", "93.184.216.34", 50000, 443, protoTCP, tcpSYN | tcpACK | tcpFIN, 10, 800, 4000, 6000},
+	}))
+	require.Len(t, conns, 1)
+
+	conn := conns[0]
+	assert.Equal(t, "10.0.0.1", conn.Source)
+	assert.Equal(t, 50000, conn.SourcePort)
+	assert.Equal(t, "93.184.216.34", conn.Destination)
+	assert.Equal(t, 443, conn.DestinationPort)
+	assert.Equal(t, "tcp", conn.Proto)
+	assert.Equal(t, testExport.Add(-6*time.Second).Unix(), conn.TimeStamp)
+	assert.InDelta(t, 2.0, conn.Duration, 0.001)
+	assert.Equal(t, int64(10), conn.OrigPkts)
+	assert.Equal(t, int64(800), conn.OrigIPBytes)
+	assert.Equal(t, int64(8), conn.RespPkts)
+	assert.Equal(t, int64(6000), conn.RespIPBytes)
+	assert.Equal(t, "SF", conn.ConnState)
+	assert.True(t, strings.HasPrefix(conn.UID, "C"))
+}
+
+func TestReadNetFlowV9(t *testing.T) {
+	template := fields(256, 9,
+		ieSourceIPv4Address, 4, ieDestinationIPv4Address, 4,
+		ieSourcePort, 2, ieDestinationPort, 2, ieProtocol, 1,
+		ieOctetDeltaCount, 4, iePacketDeltaCount, 4,
+		ieFlowStartSysUpTime, 4, ieFlowEndSysUpTime, 4,
+	)
+
+	record := append(net.ParseIP("10.0.0.2").To4(), net.ParseIP("10.0.0.53").To4()...)
+	record = appendUint16(record, 5353)
+	record = appendUint16(record, 53)
+	record = append(record, protoUDP)
+	record = appendUint32(record, 120)
+	record = appendUint32(record, 2)
+	record = appendUint32(record, 9000)
+	record = appendUint32(record, 9500)
+
+	// data exported before its template cannot be read
+	stream := v9Message(5000, set(256, record))
+	// the template and data are sent in separate messages
+	stream = append(stream, v9Message(10000, set(v9TemplateSetID, template))...)
+	stream = append(stream, v9Message(10000, set(256, record))...)
+
+	conns := readTestFlows(t, stream)
+	require.Len(t, conns, 1)
+
+	conn := conns[0]
+	assert.Equal(t, "10.0.0.2", conn.Source)
+	assert.Equal(t, 5353, conn.SourcePort)
+	assert.Equal(t, "10.0.0.53", conn.Destination)
+	assert.Equal(t, 53, conn.DestinationPort)
+	assert.Equal(t, "udp", conn.Proto)
+	assert.Equal(t, testExport.Add(-time.Second).Unix(), conn.TimeStamp)
+	assert.InDelta(t, 0.5, conn.Duration, 0.001)
+	assert.Equal(t, int64(2), conn.OrigPkts)
+	assert.Equal(t, int64(120), conn.OrigBytes)
+	assert.Equal(t, int64(0), conn.RespPkts)
+	assert.Equal(t, "S0", conn.ConnState)
+}
+
+func TestReadIPFIXBiflow(t *testing.T) {
+	template := fields(300, 11,
+		ieSourceIPv6Address, 16, ieDestinationIPv6Address, 16,
+		ieSourcePort, 2, ieDestinationPort, 2, ieProtocol, 1,
+		ieFlowStartMilliseconds, 8, ieFlowEndMilliseconds, 8,
+		ieOctetDeltaCount, 8, iePacketDeltaCount, 8,
+	)
+	// reverse counters use the enterprise bit and the reverse information element PEN
+	template = append(template, fields(0x8000|ieOctetDeltaCount, 8)...)
+	template = appendUint32(template, reverseInformationElementPEN)
+	template = append(template, fields(0x8000|iePacketDeltaCount, 8)...)
+	template = appendUint32(template, reverseInformationElementPEN)
+
+	start := testExport.Add(-30 * time.Second)
+	record := append(net.ParseIP("2001:db8::1").To16(), net.ParseIP("2001:db8::2").To16()...)
+	record = appendUint16(record, 40000)
+	record = appendUint16(record, 22)
+	record = append(record, protoTCP)
+	record = appendUint64(record, uint64(start.UnixNano()/int64(time.Millisecond)))
+	record = appendUint64(record, uint64(start.Add(10*time.Second).UnixNano()/int64(time.Millisecond)))
+	record = appendUint64(record, 4000)
+	record = appendUint64(record, 40)
+	record = appendUint64(record, 9000)
+	record = appendUint64(record, 30)
+
+	// options data describes the exporter and is skipped
+	options := fields(400, 1, 1, 149, 4)
+
+	conns := readTestFlows(t, ipfixMessage(
+		set(ipfixTemplateSetID, template),
+		set(ipfixOptionsTemplateSetID, options),
+		set(400, []byte{0, 0, 0, 1}),
+		set(300, record),
+	))
+	require.Len(t, conns, 1)
+
+	conn := conns[0]
+	assert.Equal(t, "2001:db8::1", conn.Source)
+	assert.Equal(t, "2001:db8::2", conn.Destination)
+	assert.Equal(t, 22, conn.DestinationPort)
+	assert.Equal(t, start.Unix(), conn.TimeStamp)
+	assert.InDelta(t, 10.0, conn.Duration, 0.001)
+	assert.Equal(t, int64(4000), conn.OrigIPBytes)
+	assert.Equal(t, int64(40), conn.OrigPkts)
+	assert.Equal(t, int64(9000), conn.RespIPBytes)
+	assert.Equal(t, int64(30), conn.RespPkts)
+	// the exporter did not report TCP flags
+	assert.Equal(t, "OTH", conn.ConnState)
+}
+
+func TestStitcher(t *testing.T) {
+	var conns []*parsetypes.Conn
+	s := newStitcher("test", func(entry parsetypes.BroData) {
+		conns = append(conns, entry.(*parsetypes.Conn))
+	})
+
+	flow := func(src, dst string, srcPort, dstPort int, start time.Time, flags uint8) *flowRecord {
+		return &flowRecord{
+			srcIP: net.ParseIP(src), dstIP: net.ParseIP(dst),
+			srcPort: srcPort, dstPort: dstPort, proto: protoTCP,
+			start: start, end: start.Add(time.Second),
+			pkts: 1, bytes: 60, tcpFlags: flags,
+		}
+	}
+
+	// an unanswered SYN is replaced by a retry from the same port
+	s.add(flow("10.0.0.1", "10.0.0.2", 50000, 80, testExport, tcpSYN))
+	s.add(flow("10.0.0.1", "10.0.0.2", 50000, 80, testExport.Add(3*time.Second), tcpSYN))
+	require.Len(t, conns, 1)
+	assert.Equal(t, "S0", conns[0].ConnState)
+
+	// the retry is answered with a reset
+	s.add(flow("10.0.0.2", "10.0.0.1", 80, 50000, testExport.Add(3*time.Second), tcpRST|tcpACK))
+	require.Len(t, conns, 2)
+	assert.Equal(t, "10.0.0.1", conns[1].Source)
+	assert.Equal(t, "REJ", conns[1].ConnState)
+	assert.NotEqual(t, conns[0].UID, conns[1].UID)
+
+	// reverse flows which are too far apart are not joined
+	s.add(flow("10.0.0.1", "10.0.0.3", 50001, 443, testExport, tcpSYN|tcpACK))
+	s.add(flow("10.0.0.3", "10.0.0.1", 443, 50001, testExport.Add(time.Hour), tcpSYN|tcpACK))
+	s.flush()
+	require.Len(t, conns, 4)
+	assert.Equal(t, "10.0.0.1", conns[2].Source)
+	assert.Equal(t, "10.0.0.3", conns[3].Source)
+}
+
+func TestReadNfdumpCSV(t *testing.T) {
+	output := strings.Join([]string{
+		"ts,te,td,sa,da,sp,dp,pr,flg,fwd,stos,ipkt,ibyt,opkt,obyt,in,out",
+		"2020-09-13 12:26:40,2020-09-13 12:26:45,5.000,10.0.0.2,10.0.0.1,443,50000,TCP,...AP.SF,0,0,10,9000,0,0,0,0",
+		"2020-09-13 12:26:40,2020-09-13 12:26:45,5.000,10.0.0.1,10.0.0.2,50000,443,TCP,...AP.SF,0,0,12,1500,0,0,0,0",
+		"2020-09-13 12:30:00,2020-09-13 12:30:00,0.000,10.0.0.1,10.0.0.53,5353,53,UDP,......,0,0,1,70,1,130,0,0",
+		"Summary",
+		"flows,bytes,packets,avg_bps,avg_pps,avg_bpp",
+		"3,10700,24,0,0,0",
+	}, "\n")
+
+	var conns []*parsetypes.Conn
+	s := newStitcher("nfcapd.202009131225", func(entry parsetypes.BroData) {
+		conns = append(conns, entry.(*parsetypes.Conn))
+	})
+	require.NoError(t, readNfdumpCSV(strings.NewReader(output), s))
+	s.flush()
+	require.Len(t, conns, 2)
+
+	// the flows are tied, so the side using the higher port is the originator
+	assert.Equal(t, "10.0.0.1", conns[0].Source)
+	assert.Equal(t, 443, conns[0].DestinationPort)
+	assert.Equal(t, time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC).Unix(), conns[0].TimeStamp)
+	assert.InDelta(t, 5.0, conns[0].Duration, 0.001)
+	assert.Equal(t, int64(1500), conns[0].OrigIPBytes)
+	assert.Equal(t, int64(9000), conns[0].RespIPBytes)
+	assert.Equal(t, "SF", conns[0].ConnState)
+
+	// the output counters are set for biflows
+	assert.Equal(t, "udp", conns[1].Proto)
+	assert.Equal(t, int64(70), conns[1].OrigIPBytes)
+	assert.Equal(t, int64(130), conns[1].RespIPBytes)
+	assert.Equal(t, "SF", conns[1].ConnState)
+
+	assert.Error(t, readNfdumpCSV(strings.NewReader("ts,te\n"), s), "required columns are missing")
+}
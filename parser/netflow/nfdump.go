@@ -0,0 +1,161 @@
+package netflow
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nfdumpTimeLayout is the layout nfdump uses for timestamps in its CSV output
+const nfdumpTimeLayout = "2006-01-02 15:04:05"
+
+// columns which must be present in nfdump's CSV output
+var nfdumpRequiredColumns = []string{"ts", "te", "sa", "da", "sp", "dp", "pr"}
+
+// readNfcapd reads an nfcapd file by running it through nfdump. The format of
+// nfcapd files changes between nfdump releases, so nfdump is relied upon to decode it.
+func readNfcapd(path string, s *stitcher) error {
+	nfdump, err := exec.LookPath("nfdump")
+	if err != nil {
+		return errors.New("nfdump must be installed to read nfcapd files")
+	}
+
+	cmd := exec.Command(nfdump, "-r", path, "-o", "csv")
+	// nfdump prints timestamps in the local timezone
+	cmd.Env = append(os.Environ(), "TZ=UTC")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	parseErr := readNfdumpCSV(stdout, s)
+	// let nfdump finish writing even if its output could not be parsed
+	io.Copy(ioutil.Discard, stdout)
+
+	if err = cmd.Wait(); err != nil {
+		return fmt.Errorf("nfdump failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseErr
+}
+
+// readNfdumpCSV reads the flows printed by nfdump -o csv
+func readNfdumpCSV(r io.Reader, s *stitcher) error {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		return scanner.Err()
+	}
+
+	columns := make(map[string]int)
+	for i, name := range strings.Split(strings.TrimSpace(scanner.Text()), ",") {
+		columns[name] = i
+	}
+	for _, name := range nfdumpRequiredColumns {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("nfdump output is missing the %s column", name)
+		}
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// the flows are followed by a summary of the file
+		if line == "" || strings.HasPrefix(line, "Summary") {
+			break
+		}
+
+		values := strings.Split(line, ",")
+		value := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(values) {
+				return strings.TrimSpace(values[i])
+			}
+			return ""
+		}
+
+		record := &flowRecord{
+			srcIP: net.ParseIP(value("sa")),
+			dstIP: net.ParseIP(value("da")),
+			proto: nfdumpProto(value("pr")),
+		}
+		if record.srcIP == nil || record.dstIP == nil {
+			continue
+		}
+
+		var err error
+		if record.start, err = time.Parse(nfdumpTimeLayout, value("ts")); err != nil {
+			continue
+		}
+		if record.end, err = time.Parse(nfdumpTimeLayout, value("te")); err != nil {
+			record.end = record.start
+		}
+		record.srcPort = int(parseCount(value("sp")))
+		record.dstPort = int(parseCount(value("dp")))
+		record.tcpFlags = nfdumpFlags(value("flg"))
+		record.pkts = parseCount(value("ipkt"))
+		record.bytes = parseCount(value("ibyt"))
+		record.revPkts = parseCount(value("opkt"))
+		record.revBytes = parseCount(value("obyt"))
+		// the output counters are only set by exporters which report biflows
+		record.biflow = record.revPkts > 0 || record.revBytes > 0
+
+		s.add(record)
+	}
+	return scanner.Err()
+}
+
+// nfdumpProto converts the protocol name printed by nfdump into its protocol number
+func nfdumpProto(name string) uint8 {
+	switch strings.ToUpper(name) {
+	case "TCP":
+		return protoTCP
+	case "UDP":
+		return protoUDP
+	case "ICMP":
+		return protoICMP
+	case "ICMP6", "IPV6-ICMP":
+		return protoICMPv6
+	}
+	return uint8(parseCount(name))
+}
+
+// nfdumpFlags converts the TCP flags printed by nfdump (e.g. ".AP.SF") into a bitmask
+func nfdumpFlags(flags string) uint8 {
+	var result uint8
+	for _, flag := range flags {
+		switch flag {
+		case 'F':
+			result |= tcpFIN
+		case 'S':
+			result |= tcpSYN
+		case 'R':
+			result |= tcpRST
+		case 'A':
+			result |= tcpACK
+		}
+	}
+	return result
+}
+
+// parseCount parses a counter from nfdump's output. Large values may be
+// printed in floating point notation.
+func parseCount(value string) int64 {
+	if count, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return count
+	}
+	if count, err := strconv.ParseFloat(value, 64); err == nil {
+		return int64(count)
+	}
+	return 0
+}
@@ -0,0 +1,298 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/activecm/rita/parser/parsetypes"
+)
+
+const (
+	// stitchWindow is how far apart the two halves of a connection may be reported
+	// and still be joined together. Exporters report each direction separately, but
+	// usually in the same or the following export.
+	stitchWindow = time.Minute
+
+	sweepIntervalFlows = 10000
+)
+
+// transport protocol numbers
+const (
+	protoICMP   uint8 = 1
+	protoTCP    uint8 = 6
+	protoUDP    uint8 = 17
+	protoICMPv6 uint8 = 58
+)
+
+// TCP flags as reported in the cumulative flags of a flow
+const (
+	tcpFIN uint8 = 0x01
+	tcpSYN uint8 = 0x02
+	tcpRST uint8 = 0x04
+	tcpACK uint8 = 0x10
+)
+
+// flowKey identifies the traffic sent in one direction between two endpoints
+type flowKey struct {
+	srcIP   [16]byte
+	dstIP   [16]byte
+	srcPort uint16
+	dstPort uint16
+	proto   uint8
+}
+
+// reverse returns the key for the traffic flowing in the opposite direction
+func (k flowKey) reverse() flowKey {
+	return flowKey{
+		srcIP:   k.dstIP,
+		dstIP:   k.srcIP,
+		srcPort: k.dstPort,
+		dstPort: k.srcPort,
+		proto:   k.proto,
+	}
+}
+
+// stitcher joins unidirectional flows with the flows reporting the opposite direction
+// of the same connection and passes the resulting conn records on to emit
+type stitcher struct {
+	emit       func(parsetypes.BroData)
+	pending    map[flowKey]*flowRecord
+	uidSeed    uint64
+	uidCounter uint64
+	flowCount  int
+	now        time.Time
+}
+
+// newStitcher creates a stitcher which passes each conn record to emit.
+// The seed is used to generate connection UIDs.
+func newStitcher(seed string, emit func(parsetypes.BroData)) *stitcher {
+	hash := fnv.New64a()
+	hash.Write([]byte(seed))
+	return &stitcher{
+		emit:    emit,
+		pending: make(map[flowKey]*flowRecord),
+		uidSeed: hash.Sum64(),
+	}
+}
+
+// add handles a single flow. Biflows are emitted immediately, while unidirectional
+// flows are held until the opposite direction is seen or the flow expires.
+func (s *stitcher) add(f *flowRecord) {
+	if f.end.After(s.now) {
+		s.now = f.end
+	}
+	s.flowCount++
+	if s.flowCount%sweepIntervalFlows == 0 {
+		s.sweep()
+	}
+
+	if f.biflow {
+		s.finish(f)
+		return
+	}
+
+	key := f.key()
+	if reverse, ok := s.pending[key.reverse()]; ok && overlaps(reverse, f) {
+		delete(s.pending, key.reverse())
+		s.finish(merge(reverse, f))
+		return
+	}
+
+	// a new flow in the same direction means the earlier one was never answered
+	if earlier, ok := s.pending[key]; ok {
+		delete(s.pending, key)
+		s.finish(earlier)
+	}
+	s.pending[key] = f
+}
+
+// sweep emits the flows which are too old to be joined with another flow
+func (s *stitcher) sweep() {
+	var expired []*flowRecord
+	for key, f := range s.pending {
+		if s.now.Sub(f.end) > stitchWindow {
+			expired = append(expired, f)
+			delete(s.pending, key)
+		}
+	}
+	sortFlows(expired)
+	for _, f := range expired {
+		s.finish(f)
+	}
+}
+
+// flush emits all of the flows which are still waiting to be joined
+func (s *stitcher) flush() {
+	remaining := make([]*flowRecord, 0, len(s.pending))
+	for key, f := range s.pending {
+		remaining = append(remaining, f)
+		delete(s.pending, key)
+	}
+	sortFlows(remaining)
+	for _, f := range remaining {
+		s.finish(f)
+	}
+}
+
+// sortFlows orders flows by their start time so records are emitted in a stable order
+func sortFlows(flows []*flowRecord) {
+	sort.Slice(flows, func(i, j int) bool {
+		if flows[i].start.Equal(flows[j].start) {
+			return flows[i].srcPort < flows[j].srcPort
+		}
+		return flows[i].start.Before(flows[j].start)
+	})
+}
+
+// finish emits the conn record for a flow
+func (s *stitcher) finish(f *flowRecord) {
+	s.uidCounter++
+	s.emit(f.connRecord(s.newUID(f)))
+}
+
+// overlaps checks whether two flows were active at around the same time
+func overlaps(a, b *flowRecord) bool {
+	return !a.start.After(b.end.Add(stitchWindow)) && !b.start.After(a.end.Add(stitchWindow))
+}
+
+// merge joins two flows reporting opposite directions of a connection. The flow which
+// started first is taken to be the originator. Flow timestamps are often too coarse to
+// tell, in which case the side using the higher (likely ephemeral) port is chosen.
+func merge(a, b *flowRecord) *flowRecord {
+	orig, resp := a, b
+	if b.start.Before(a.start) || (b.start.Equal(a.start) && b.srcPort > b.dstPort) {
+		orig, resp = b, a
+	}
+
+	merged := *orig
+	merged.revBytes = resp.bytes
+	merged.revPkts = resp.pkts
+	merged.revTCPFlags = resp.tcpFlags
+	merged.biflow = true
+	if resp.end.After(merged.end) {
+		merged.end = resp.end
+	}
+	return &merged
+}
+
+// key returns the key for the traffic reported by the flow
+func (f *flowRecord) key() flowKey {
+	key := flowKey{
+		srcPort: uint16(f.srcPort),
+		dstPort: uint16(f.dstPort),
+		proto:   f.proto,
+	}
+	copy(key.srcIP[:], f.srcIP.To16())
+	copy(key.dstIP[:], f.dstIP.To16())
+	return key
+}
+
+// newUID generates a Zeek style connection UID. UIDs are derived from the file,
+// the connection tuple, and the start time so that they are stable across imports.
+func (s *stitcher) newUID(f *flowRecord) string {
+	const base62 = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	key := f.key()
+	hash := fnv.New64a()
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], s.uidSeed)
+	hash.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], s.uidCounter)
+	hash.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], uint64(f.start.UnixNano()))
+	hash.Write(buf[:])
+	hash.Write(key.srcIP[:])
+	hash.Write(key.dstIP[:])
+	binary.BigEndian.PutUint16(buf[:2], key.srcPort)
+	binary.BigEndian.PutUint16(buf[2:4], key.dstPort)
+	buf[4] = key.proto
+	hash.Write(buf[:5])
+
+	value := hash.Sum64()
+	uid := []byte{'C'}
+	for value > 0 {
+		uid = append(uid, base62[value%62])
+		value /= 62
+	}
+	return string(uid)
+}
+
+// connRecord builds the conn log entry for a flow. Flow exporters count the bytes
+// of whole packets, so the same counts are used for the payload and IP byte fields.
+func (f *flowRecord) connRecord(uid string) *parsetypes.Conn {
+	var duration float64
+	if f.end.After(f.start) {
+		duration = f.end.Sub(f.start).Seconds()
+	}
+
+	return &parsetypes.Conn{
+		TimeStamp:       f.start.Unix(),
+		TimeStampMs:     f.start.UnixMilli(),
+		UID:             uid,
+		Source:          f.srcIP.String(),
+		SourcePort:      f.srcPort,
+		Destination:     f.dstIP.String(),
+		DestinationPort: f.dstPort,
+		Proto:           protoName(f.proto),
+		Duration:        duration,
+		OrigBytes:       f.bytes,
+		RespBytes:       f.revBytes,
+		ConnState:       f.connState(),
+		OrigPkts:        f.pkts,
+		OrigIPBytes:     f.bytes,
+		RespPkts:        f.revPkts,
+		RespIPBytes:     f.revBytes,
+	}
+}
+
+// connState approximates Zeek's conn_state field from the cumulative TCP flags of each direction
+// https://docs.zeek.org/en/master/scripts/base/protocols/conn/main.zeek.html
+func (f *flowRecord) connState() string {
+	if f.proto != protoTCP {
+		if f.revPkts > 0 {
+			return "SF"
+		}
+		return "S0"
+	}
+
+	origSyn := f.tcpFlags&tcpSYN != 0
+	synAck := f.revTCPFlags&tcpSYN != 0 && f.revTCPFlags&tcpACK != 0
+
+	switch {
+	case f.tcpFlags == 0 && f.revTCPFlags == 0:
+		// the exporter does not report TCP flags
+		return "OTH"
+	case origSyn && !synAck && f.revTCPFlags&tcpRST != 0:
+		return "REJ"
+	case origSyn && !synAck && f.revPkts == 0:
+		return "S0"
+	case !origSyn && !synAck:
+		return "OTH"
+	case f.tcpFlags&tcpRST != 0:
+		return "RSTO"
+	case f.revTCPFlags&tcpRST != 0:
+		return "RSTR"
+	case f.tcpFlags&tcpFIN != 0 && f.revTCPFlags&tcpFIN != 0:
+		return "SF"
+	case f.tcpFlags&tcpFIN != 0:
+		return "S2"
+	case f.revTCPFlags&tcpFIN != 0:
+		return "S3"
+	}
+	return "S1"
+}
+
+// protoName returns the Zeek name of the transport protocol
+func protoName(proto uint8) string {
+	switch proto {
+	case protoTCP:
+		return "tcp"
+	case protoUDP:
+		return "udp"
+	case protoICMP, protoICMPv6:
+		return "icmp"
+	}
+	return "unknown_transport"
+}
@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/parser/files"
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/google/uuid"
+)
+
+// networkNamespace is used to derive the UUIDs of networks which are only given a name,
+// so the same name always results in the same network across imports
+var networkNamespace = uuid.MustParse("188700cf-cbed-4c85-aa54-181acd1f4e29")
+
+// networkRule assigns the hosts in the logs it matches to a network
+type networkRule struct {
+	name      string
+	uuid      string
+	directory string
+	fileGlob  string
+	nodeName  string
+	vlan      int
+}
+
+// networkRules holds the rules for assigning hosts to networks in the order they are checked
+type networkRules []networkRule
+
+// newNetworkRules validates the configured network rules
+func newNetworkRules(networks []config.NetworkStaticCfg) (networkRules, error) {
+	rules := make(networkRules, 0, len(networks))
+	for _, network := range networks {
+		if network.Name == "" {
+			return nil, fmt.Errorf("every network requires a name")
+		}
+		if network.Directory == "" && network.FileGlob == "" && network.NodeName == "" {
+			// only conn logs record the VLAN, so the rest of the sensor's logs couldn't be matched
+			if network.VLAN != 0 {
+				return nil, fmt.Errorf("network %s only matches a VLAN, which is only recorded in conn logs. "+
+					"Add a directory, file glob, or node name to match the sensor's other logs", network.Name)
+			}
+			return nil, fmt.Errorf("network %s has no directory, file glob, or node name to match", network.Name)
+		}
+
+		rule := networkRule{
+			name:     network.Name,
+			fileGlob: network.FileGlob,
+			nodeName: network.NodeName,
+			vlan:     network.VLAN,
+		}
+
+		if network.UUID != "" {
+			id, err := uuid.Parse(network.UUID)
+			if err != nil {
+				return nil, fmt.Errorf("network %s has an invalid UUID: %v", network.Name, err)
+			}
+			rule.uuid = id.String()
+		} else {
+			rule.uuid = uuid.NewSHA1(networkNamespace, []byte(network.Name)).String()
+		}
+
+		if network.Directory != "" {
+			directory, err := filepath.Abs(network.Directory)
+			if err != nil {
+				return nil, fmt.Errorf("network %s has an invalid directory: %v", network.Name, err)
+			}
+			rule.directory = directory
+		}
+
+		for _, pattern := range []string{network.FileGlob, network.NodeName} {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("network %s has an invalid pattern %q: %v", network.Name, pattern, err)
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// forFile returns the rules whose directory and file glob match the given file
+func (rules networkRules) forFile(indexedFile *files.IndexedFile) networkRules {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	path, err := filepath.Abs(indexedFile.Path)
+	if err != nil {
+		path = indexedFile.Path
+	}
+	name := indexedFile.Name()
+
+	var matched networkRules
+	for _, rule := range rules {
+		if rule.directory != "" && !strings.HasPrefix(path, rule.directory+string(filepath.Separator)) {
+			continue
+		}
+		// archive members are also matched by the name of their archive
+		if rule.fileGlob != "" && !globMatch(rule.fileGlob, name) && !globMatch(rule.fileGlob, filepath.Base(name)) &&
+			!globMatch(rule.fileGlob, filepath.Base(indexedFile.Path)) {
+			continue
+		}
+		matched = append(matched, rule)
+	}
+	return matched
+}
+
+// label assigns the hosts of a log entry to the network of the first rule it matches.
+// Entries which were already assigned to a network by the sensor are left alone.
+// Only conn entries record the VLAN, so rules with a VLAN never match other entries.
+func (rules networkRules) label(entry parsetypes.BroData) {
+	if len(rules) == 0 {
+		return
+	}
+
+	switch typedEntry := entry.(type) {
+	case *parsetypes.Conn:
+		rules.assign(&typedEntry.AgentUUID, &typedEntry.AgentHostname,
+			typedEntry.NodeName, typedEntry.SystemName, typedEntry.VLAN)
+	case *parsetypes.OpenConn:
+		rules.assign(&typedEntry.AgentUUID, &typedEntry.AgentHostname,
+			typedEntry.NodeName, typedEntry.SystemName, typedEntry.VLAN)
+	case *parsetypes.DNS:
+		rules.assign(&typedEntry.AgentUUID, &typedEntry.AgentHostname,
+			typedEntry.NodeName, typedEntry.SystemName, 0)
+	case *parsetypes.HTTP:
+		rules.assign(&typedEntry.AgentUUID, &typedEntry.AgentHostname,
+			typedEntry.NodeName, typedEntry.SystemName, 0)
+	case *parsetypes.SSL:
+		rules.assign(&typedEntry.AgentUUID, &typedEntry.AgentHostname,
+			typedEntry.NodeName, typedEntry.SystemName, 0)
+	case *parsetypes.SSH:
+		rules.assign(&typedEntry.AgentUUID, &typedEntry.AgentHostname,
+			typedEntry.NodeName, typedEntry.SystemName, 0)
+	case *parsetypes.DHCP:
+		rules.assign(&typedEntry.AgentUUID, &typedEntry.AgentHostname,
+			typedEntry.NodeName, typedEntry.SystemName, 0)
+	case *parsetypes.Kerberos:
+		rules.assign(&typedEntry.AgentUUID, &typedEntry.AgentHostname,
+			typedEntry.NodeName, typedEntry.SystemName, 0)
+	case *parsetypes.NTLM:
+		rules.assign(&typedEntry.AgentUUID, &typedEntry.AgentHostname,
+			typedEntry.NodeName, typedEntry.SystemName, 0)
+	case *parsetypes.Files:
+		rules.assign(&typedEntry.AgentUUID, &typedEntry.AgentHostname,
+			typedEntry.NodeName, typedEntry.SystemName, 0)
+	}
+}
+
+// assign sets the agent fields of an entry from the first rule matching its node name and VLAN
+func (rules networkRules) assign(agentUUID, agentName *string, nodeName, systemName string, vlan int) {
+	if *agentUUID != "" {
+		return
+	}
+
+	for _, rule := range rules {
+		if rule.nodeName != "" && !globMatch(rule.nodeName, nodeName) && !globMatch(rule.nodeName, systemName) {
+			continue
+		}
+		if rule.vlan != 0 && rule.vlan != vlan {
+			continue
+		}
+		*agentUUID = rule.uuid
+		*agentName = rule.name
+		return
+	}
+}
+
+// globMatch checks if a value matches a validated glob pattern
+func globMatch(pattern, value string) bool {
+	if value == "" {
+		return false
+	}
+	matched, _ := filepath.Match(pattern, value)
+	return matched
+}
@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/parser/files"
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkRules(t *testing.T) {
+	siteA := filepath.Join("/opt", "zeek", "site-a")
+	rules, err := newNetworkRules([]config.NetworkStaticCfg{
+		{Name: "branch-vlan", VLAN: 20, Directory: siteA},
+		{Name: "site-a", Directory: siteA},
+		{Name: "site-b", UUID: "6a4e6d43-3d27-4f3b-9f0e-2f8f7d1c8e55", FileGlob: "*site-b*"},
+		{Name: "site-c", NodeName: "site-c-*"},
+	})
+	require.NoError(t, err)
+
+	siteAFile := &files.IndexedFile{Path: filepath.Join(siteA, "conn.log")}
+	siteBFile := &files.IndexedFile{Path: "/tmp/zeek-site-b.tar", Member: "2021-06-01/conn.log"}
+	otherFile := &files.IndexedFile{Path: "/opt/zeek/site-ab/conn.log"}
+
+	// the first matching rule is used
+	conn := &parsetypes.Conn{VLAN: 20}
+	rules.forFile(siteAFile).label(conn)
+	assert.Equal(t, "branch-vlan", conn.AgentHostname)
+	assert.Equal(t, uuid.NewSHA1(networkNamespace, []byte("branch-vlan")).String(), conn.AgentUUID)
+
+	dns := &parsetypes.DNS{}
+	rules.forFile(siteAFile).label(dns)
+	assert.Equal(t, "site-a", dns.AgentHostname)
+
+	ssl := &parsetypes.SSL{}
+	rules.forFile(siteBFile).label(ssl)
+	assert.Equal(t, "site-b", ssl.AgentHostname)
+	assert.Equal(t, "6a4e6d43-3d27-4f3b-9f0e-2f8f7d1c8e55", ssl.AgentUUID)
+
+	// node names are matched against both of the cluster fields
+	http := &parsetypes.HTTP{SystemName: "site-c-sensor2"}
+	rules.forFile(otherFile).label(http)
+	assert.Equal(t, "site-c", http.AgentHostname)
+
+	unmatched := &parsetypes.Conn{NodeName: "worker-1"}
+	rules.forFile(otherFile).label(unmatched)
+	assert.Empty(t, unmatched.AgentHostname)
+	assert.Empty(t, unmatched.AgentUUID)
+
+	// networks set by the sensor are kept
+	agent := &parsetypes.Conn{AgentUUID: "c7f1a1f6-0c8f-4c51-8d5c-5bd0f5c1b1a7", AgentHostname: "agent"}
+	rules.forFile(siteAFile).label(agent)
+	assert.Equal(t, "agent", agent.AgentHostname)
+
+	for _, invalid := range []config.NetworkStaticCfg{
+		{Directory: siteA},
+		{Name: "site-a"},
+		{Name: "site-a", UUID: "not-a-uuid", Directory: siteA},
+		{Name: "site-a", FileGlob: "[site-a"},
+		// only conn logs record the VLAN
+		{Name: "branch-vlan", VLAN: 20},
+	} {
+		_, err := newNetworkRules([]config.NetworkStaticCfg{invalid})
+		assert.Error(t, err, invalid.Name)
+	}
+}
+
+func TestParseClusterFields(t *testing.T) {
+	connLog := "#separator \\x09\n" +
+		"#set_separator\t,\n" +
+		"#empty_field\t(empty)\n" +
+		"#unset_field\t-\n" +
+		"#path\tconn\n" +
+		"#fields\tts\tuid\tid.orig_h\tid.orig_p\tid.resp_h\tid.resp_p\tproto\tvlan\t_node_name\n" +
+		"#types\ttime\tstring\taddr\tport\taddr\tport\tenum\tint\tstring\n" +
+		"1622548800.000000\tCabc\t10.0.0.5\t51000\t93.184.216.34\t443\ttcp\t20\tworker-1\n"
+
+	logPath := filepath.Join(t.TempDir(), "conn.log")
+	require.NoError(t, ioutil.WriteFile(logPath, []byte(connLog), 0644))
+	conf := &config.Config{}
+	conf.T.Structure.ConnTable = "conn"
+	indexed := files.TryIndexFiles([]string{logPath}, 1, "test", 0, log.New(), conf)
+	require.Len(t, indexed, 1)
+
+	lines := strings.Split(strings.TrimSpace(connLog), "\n")
+	entry := files.ParseTSVLine(lines[len(lines)-1], indexed[0].GetHeader(), indexed[0].GetFieldMap(),
+		indexed[0].GetBroDataFactory(), log.New())
+	conn, ok := entry.(*parsetypes.Conn)
+	require.True(t, ok)
+	assert.Equal(t, 20, conn.VLAN)
+	assert.Equal(t, "worker-1", conn.NodeName)
+
+	rules, err := newNetworkRules([]config.NetworkStaticCfg{{Name: "site-a", NodeName: "worker-*", VLAN: 20}})
+	require.NoError(t, err)
+	rules.forFile(indexed[0]).label(conn)
+	assert.Equal(t, "site-a", conn.AgentHostname)
+}
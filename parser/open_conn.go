@@ -14,7 +14,7 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func parseOpenConnEntry(parseConn *parsetypes.OpenConn, filter filter, retVals ParseResults, logger *log.Logger) {
+func parseOpenConnEntry(parseConn *parsetypes.OpenConn, filter filter, retVals ParseResults, logger *log.Logger) entryOutcome {
 	// get source destination pair for connection record
 	src := parseConn.Source
 	dst := parseConn.Destination
@@ -30,15 +30,15 @@ func parseOpenConnEntry(parseConn *parsetypes.OpenConn, filter filter, retVals P
 			"src": parseConn.Source,
 			"dst": parseConn.Destination,
 		}).Error("Unable to parse valid ip address pair from open_conn log entry, skipping entry.")
-		return
+		return entryOutcome{rejectedFor: rejectInvalidAddress}
 	}
 
 	// Run conn pair through filter to filter out certain connections
-	ignore := filter.filterConnPair(srcIP, dstIP)
+	filteredBy := filter.connPairFilterRule(srcIP, dstIP)
 
 	// If connection pair is not subject to filtering, process
-	if ignore {
-		return
+	if filteredBy != "" {
+		return entryOutcome{filteredBy: filteredBy}
 	}
 
 	// disambiConnguate addresses which are not publicly routable
@@ -72,6 +72,7 @@ func parseOpenConnEntry(parseConn *parsetypes.OpenConn, filter filter, retVals P
 
 	updateCertificatesByOpenConn(dstKey, tuple, retVals)
 
+	return entryOutcome{}
 }
 
 func updateUniqueConnectionsByOpenConn(srcIP, dstIP net.IP, srcDstPair data.UniqueIPPair, srcDstKey string,
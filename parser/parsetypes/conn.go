@@ -2,17 +2,19 @@ package parsetypes
 
 import (
 	"github.com/activecm/rita/config"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // Conn provides a data structure for zeek's connection data
 type Conn struct {
 	// ID is the id coming out of mongodb
-	ID bson.ObjectId `bson:"_id,omitempty"`
+	ID primitive.ObjectID `bson:"_id,omitempty"`
 	// TimeStamp of this connection
 	TimeStamp int64 `bson:"ts" bro:"ts" brotype:"time" json:"-"`
 	// TimeStampGeneric is used when reading from json files
 	TimeStampGeneric interface{} `bson:"-" json:"ts"`
+	// TimeStampMs is the timestamp of this connection in milliseconds
+	TimeStampMs int64 `bson:"-" bromillis:"ts" json:"-"`
 	// UID is the Unique Id for this connection (generated by Bro)
 	UID string `bson:"uid" bro:"uid" brotype:"string" json:"uid"`
 	// Source is the source address for this connection
@@ -57,6 +59,12 @@ type Conn struct {
 	AgentHostname string `bson:"agent_hostname" bro:"agent_hostname" brotype:"string" json:"agent_hostname"`
 	// AgentUUID identifies which sensor recorded this event. Only set when combining logs from multiple sensors.
 	AgentUUID string `bson:"agent_uuid" bro:"agent_uuid" brotype:"string" json:"agent_uuid"`
+	// NodeName names the Zeek cluster node which wrote this event
+	NodeName string `bson:"_node_name" bro:"_node_name" brotype:"string" json:"_node_name"`
+	// SystemName names the host which the Zeek cluster node writing this event ran on
+	SystemName string `bson:"_system_name" bro:"_system_name" brotype:"string" json:"_system_name"`
+	// VLAN is the outer VLAN tag of this connection
+	VLAN int `bson:"vlan" bro:"vlan" brotype:"int" json:"vlan"`
 }
 
 //TargetCollection returns the mongo collection this entry should be inserted
@@ -67,4 +75,14 @@ func (line *Conn) TargetCollection(config *config.StructureTableCfg) string {
 //ConvertFromJSON performs any extra conversions necessary when reading from JSON
 func (line *Conn) ConvertFromJSON() {
 	line.TimeStamp = convertTimestamp(line.TimeStampGeneric)
+	line.TimeStampMs = convertTimestampMillis(line.TimeStampGeneric)
+}
+
+//TimeStampMillis returns the timestamp of this connection in milliseconds,
+//falling back to whole seconds for sources without sub-second precision
+func (line *Conn) TimeStampMillis() int64 {
+	if line.TimeStampMs != 0 {
+		return line.TimeStampMs
+	}
+	return line.TimeStamp * 1000
 }
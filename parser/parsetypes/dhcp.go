@@ -0,0 +1,56 @@
+package parsetypes
+
+import (
+	"github.com/activecm/rita/config"
+)
+
+// DHCP provides a data structure for zeek's dhcp data
+type DHCP struct {
+	// TimeStamp of the first message in the DHCP exchange
+	TimeStamp int64 `bson:"ts" bro:"ts" brotype:"time" json:"-"`
+	// TimeStampGeneric is used when reading from json files
+	TimeStampGeneric interface{} `bson:"-" json:"ts"`
+	// UIDs are the Unique Ids of the connections which carried the DHCP exchange
+	UIDs []string `bson:"uids" bro:"uids" brotype:"set[string]" json:"uids"`
+	// ClientAddr : IP address of the client
+	ClientAddr string `bson:"client_addr" bro:"client_addr" brotype:"addr" json:"client_addr"`
+	// ServerAddr : IP address of the server which handed out the address
+	ServerAddr string `bson:"server_addr" bro:"server_addr" brotype:"addr" json:"server_addr"`
+	// MAC : Client's hardware address
+	MAC string `bson:"mac" bro:"mac" brotype:"string" json:"mac"`
+	// HostName : Name given by the client in the Hostname option
+	HostName string `bson:"host_name" bro:"host_name" brotype:"string" json:"host_name"`
+	// ClientFQDN : FQDN given by the client in the Client FQDN option
+	ClientFQDN string `bson:"client_fqdn" bro:"client_fqdn" brotype:"string" json:"client_fqdn"`
+	// Domain : Domain given by the server
+	Domain string `bson:"domain" bro:"domain" brotype:"string" json:"domain"`
+	// RequestedAddr : IP address requested by the client
+	RequestedAddr string `bson:"requested_addr" bro:"requested_addr" brotype:"addr" json:"requested_addr"`
+	// AssignedAddr : IP address assigned by the server
+	AssignedAddr string `bson:"assigned_addr" bro:"assigned_addr" brotype:"addr" json:"assigned_addr"`
+	// LeaseTime : IP address lease interval in seconds
+	LeaseTime float64 `bson:"lease_time" bro:"lease_time" brotype:"interval" json:"lease_time"`
+	// MsgTypes : The DHCP message types seen in the exchange
+	MsgTypes []string `bson:"msg_types" bro:"msg_types" brotype:"vector[string]" json:"msg_types"`
+	// AssignedIP : IP address assigned by the server.
+	// Note: only present in older bro versions which log each acknowledgement.
+	AssignedIP string `bson:"assigned_ip" bro:"assigned_ip" brotype:"addr" json:"assigned_ip"`
+	// AgentHostname names which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentHostname string `bson:"agent_hostname" bro:"agent_hostname" brotype:"string" json:"agent_hostname"`
+	// AgentUUID identifies which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentUUID string `bson:"agent_uuid" bro:"agent_uuid" brotype:"string" json:"agent_uuid"`
+	// NodeName names the Zeek cluster node which wrote this event
+	NodeName string `bson:"_node_name" bro:"_node_name" brotype:"string" json:"_node_name"`
+	// SystemName names the host which the Zeek cluster node writing this event ran on
+	SystemName string `bson:"_system_name" bro:"_system_name" brotype:"string" json:"_system_name"`
+}
+
+//TargetCollection returns the mongo collection this entry should be inserted
+func (line *DHCP) TargetCollection(config *config.StructureTableCfg) string {
+	return config.DHCPTable
+}
+
+//ConvertFromJSON performs any extra conversions necessary when reading from JSON
+func (line *DHCP) ConvertFromJSON() {
+	line.TimeStamp = convertTimestamp(line.TimeStampGeneric)
+}
@@ -2,13 +2,13 @@ package parsetypes
 
 import (
 	"github.com/activecm/rita/config"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // DNS provides a data structure for entries in the zeek DNS log
 type DNS struct {
 	// ID contains the id set by mongodb
-	ID bson.ObjectId `bson:"_id,omitempty"`
+	ID primitive.ObjectID `bson:"_id,omitempty"`
 	// TimeStamp of this connection
 	TimeStamp int64 `bson:"ts" bro:"ts" brotype:"time" json:"-"`
 	// TimeStampGeneric is used when reading from json files
@@ -64,6 +64,10 @@ type DNS struct {
 	AgentHostname string `bson:"agent_hostname" bro:"agent_hostname" brotype:"string" json:"agent_hostname"`
 	// AgentUUID identifies which sensor recorded this event. Only set when combining logs from multiple sensors.
 	AgentUUID string `bson:"agent_uuid" bro:"agent_uuid" brotype:"string" json:"agent_uuid"`
+	// NodeName names the Zeek cluster node which wrote this event
+	NodeName string `bson:"_node_name" bro:"_node_name" brotype:"string" json:"_node_name"`
+	// SystemName names the host which the Zeek cluster node writing this event ran on
+	SystemName string `bson:"_system_name" bro:"_system_name" brotype:"string" json:"_system_name"`
 }
 
 //TargetCollection returns the mongo collection this entry should be inserted
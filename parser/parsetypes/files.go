@@ -0,0 +1,93 @@
+package parsetypes
+
+import (
+	"github.com/activecm/rita/config"
+)
+
+// Files provides a data structure for zeek's files data
+type Files struct {
+	// TimeStamp of the first time the file was seen
+	TimeStamp int64 `bson:"ts" bro:"ts" brotype:"time" json:"-"`
+	// TimeStampGeneric is used when reading from json files
+	TimeStampGeneric interface{} `bson:"-" json:"ts"`
+	// FUID is the Unique Id for this file (generated by Bro)
+	FUID string `bson:"fuid" bro:"fuid" brotype:"string" json:"fuid"`
+	// UID is the Unique Id of the connection which carried the file.
+	// Note: only present in newer zeek versions.
+	UID string `bson:"uid" bro:"uid" brotype:"string" json:"uid"`
+	// Source is the source address of the connection which carried the file.
+	// Note: only present in newer zeek versions.
+	Source string `bson:"id_orig_h" bro:"id.orig_h" brotype:"addr" json:"id.orig_h"`
+	// SourcePort is the source port of the connection which carried the file.
+	// Note: only present in newer zeek versions.
+	SourcePort int `bson:"id_orig_p" bro:"id.orig_p" brotype:"port" json:"id.orig_p"`
+	// Destination is the destination of the connection which carried the file.
+	// Note: only present in newer zeek versions.
+	Destination string `bson:"id_resp_h" bro:"id.resp_h" brotype:"addr" json:"id.resp_h"`
+	// DestinationPort is the port at the destination host.
+	// Note: only present in newer zeek versions.
+	DestinationPort int `bson:"id_resp_p" bro:"id.resp_p" brotype:"port" json:"id.resp_p"`
+	// TxHosts : Hosts which sourced the file.
+	// Note: only present in older bro and zeek versions.
+	TxHosts []string `bson:"tx_hosts" bro:"tx_hosts" brotype:"set[addr]" json:"tx_hosts"`
+	// RxHosts : Hosts which received the file.
+	// Note: only present in older bro and zeek versions.
+	RxHosts []string `bson:"rx_hosts" bro:"rx_hosts" brotype:"set[addr]" json:"rx_hosts"`
+	// ConnUIDs : Unique Ids of the connections which carried the file.
+	// Note: only present in older bro and zeek versions.
+	ConnUIDs []string `bson:"conn_uids" bro:"conn_uids" brotype:"set[string]" json:"conn_uids"`
+	// FileSource : Protocol analyzer which found the file, e.g. HTTP, SMTP, or FTP_DATA
+	FileSource string `bson:"source" bro:"source" brotype:"string" json:"source"`
+	// Depth : Depth of the file within the protocol, e.g. the number of the HTTP request
+	Depth int `bson:"depth" bro:"depth" brotype:"count" json:"depth"`
+	// Analyzers : File analyzers which ran on the file
+	Analyzers []string `bson:"analyzers" bro:"analyzers" brotype:"set[string]" json:"analyzers"`
+	// MimeType : MIME type of the file as detected by its contents
+	MimeType string `bson:"mime_type" bro:"mime_type" brotype:"string" json:"mime_type"`
+	// Filename : Name of the file if the protocol gave one
+	Filename string `bson:"filename" bro:"filename" brotype:"string" json:"filename"`
+	// Duration : Time between the first and last data seen for the file
+	Duration float64 `bson:"duration" bro:"duration" brotype:"interval" json:"duration"`
+	// LocalOrig : Flag to indicate if the file came from a local host
+	LocalOrig bool `bson:"local_orig" bro:"local_orig" brotype:"bool" json:"local_orig"`
+	// IsOrig : Flag to indicate if the originator of the connection sent the file
+	IsOrig bool `bson:"is_orig" bro:"is_orig" brotype:"bool" json:"is_orig"`
+	// SeenBytes : Number of bytes of the file Zeek saw
+	SeenBytes int64 `bson:"seen_bytes" bro:"seen_bytes" brotype:"count" json:"seen_bytes"`
+	// TotalBytes : Size of the file if the protocol gave one
+	TotalBytes int64 `bson:"total_bytes" bro:"total_bytes" brotype:"count" json:"total_bytes"`
+	// MissingBytes : Number of bytes of the file Zeek missed
+	MissingBytes int64 `bson:"missing_bytes" bro:"missing_bytes" brotype:"count" json:"missing_bytes"`
+	// OverflowBytes : Number of bytes of the file which were out of order and not analyzed
+	OverflowBytes int64 `bson:"overflow_bytes" bro:"overflow_bytes" brotype:"count" json:"overflow_bytes"`
+	// TimedOut : Flag to indicate if Zeek stopped analyzing the file due to inactivity
+	TimedOut bool `bson:"timedout" bro:"timedout" brotype:"bool" json:"timedout"`
+	// ParentFUID : Unique Id of the file which contained this file, e.g. an archive
+	ParentFUID string `bson:"parent_fuid" bro:"parent_fuid" brotype:"string" json:"parent_fuid"`
+	// MD5 : MD5 hash of the file
+	MD5 string `bson:"md5" bro:"md5" brotype:"string" json:"md5"`
+	// SHA1 : SHA1 hash of the file
+	SHA1 string `bson:"sha1" bro:"sha1" brotype:"string" json:"sha1"`
+	// SHA256 : SHA256 hash of the file
+	SHA256 string `bson:"sha256" bro:"sha256" brotype:"string" json:"sha256"`
+	// Extracted : Local filename of the file if Zeek extracted it
+	Extracted string `bson:"extracted" bro:"extracted" brotype:"string" json:"extracted"`
+	// AgentHostname names which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentHostname string `bson:"agent_hostname" bro:"agent_hostname" brotype:"string" json:"agent_hostname"`
+	// AgentUUID identifies which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentUUID string `bson:"agent_uuid" bro:"agent_uuid" brotype:"string" json:"agent_uuid"`
+	// NodeName names the Zeek cluster node which wrote this event
+	NodeName string `bson:"_node_name" bro:"_node_name" brotype:"string" json:"_node_name"`
+	// SystemName names the host which the Zeek cluster node writing this event ran on
+	SystemName string `bson:"_system_name" bro:"_system_name" brotype:"string" json:"_system_name"`
+}
+
+//TargetCollection returns the mongo collection this entry should be inserted
+func (line *Files) TargetCollection(config *config.StructureTableCfg) string {
+	return config.FilesTable
+}
+
+//ConvertFromJSON performs any extra conversions necessary when reading from JSON
+func (line *Files) ConvertFromJSON() {
+	line.TimeStamp = convertTimestamp(line.TimeStampGeneric)
+}
@@ -0,0 +1,67 @@
+package parsetypes
+
+import (
+	"github.com/activecm/rita/config"
+)
+
+// Kerberos provides a data structure for zeek's kerberos data
+type Kerberos struct {
+	// TimeStamp of the request
+	TimeStamp int64 `bson:"ts" bro:"ts" brotype:"time" json:"-"`
+	// TimeStampGeneric is used when reading from json files
+	TimeStampGeneric interface{} `bson:"-" json:"ts"`
+	// UID is the Unique Id for this connection (generated by Bro)
+	UID string `bson:"uid" bro:"uid" brotype:"string" json:"uid"`
+	// Source is the source address for this connection
+	Source string `bson:"id_orig_h" bro:"id.orig_h" brotype:"addr" json:"id.orig_h"`
+	// SourcePort is the source port of this connection
+	SourcePort int `bson:"id_orig_p" bro:"id.orig_p" brotype:"port" json:"id.orig_p"`
+	// Destination is the destination of the connection
+	Destination string `bson:"id_resp_h" bro:"id.resp_h" brotype:"addr" json:"id.resp_h"`
+	// DestinationPort is the port at the destination host
+	DestinationPort int `bson:"id_resp_p" bro:"id.resp_p" brotype:"port" json:"id.resp_p"`
+	// RequestType : Request type, Authentication Service ("AS") or Ticket Granting Service ("TGS")
+	RequestType string `bson:"request_type" bro:"request_type" brotype:"string" json:"request_type"`
+	// Client : Client principal, e.g. "jdoe/EXAMPLE.COM"
+	Client string `bson:"client" bro:"client" brotype:"string" json:"client"`
+	// Service : Service principal
+	Service string `bson:"service" bro:"service" brotype:"string" json:"service"`
+	// Success : Flag to indicate if the request was successful
+	Success bool `bson:"success" bro:"success" brotype:"bool" json:"success"`
+	// ErrorMsg : Error message if the request failed
+	ErrorMsg string `bson:"error_msg" bro:"error_msg" brotype:"string" json:"error_msg"`
+	// From : Ticket valid from
+	From int64 `bson:"from" bro:"from" brotype:"time" json:"-"`
+	// FromGeneric is used when reading from json files
+	FromGeneric interface{} `bson:"-" json:"from"`
+	// Till : Ticket valid until
+	Till int64 `bson:"till" bro:"till" brotype:"time" json:"-"`
+	// TillGeneric is used when reading from json files
+	TillGeneric interface{} `bson:"-" json:"till"`
+	// Cipher : Ticket encryption type
+	Cipher string `bson:"cipher" bro:"cipher" brotype:"string" json:"cipher"`
+	// Forwardable : Flag to indicate if the ticket can be forwarded
+	Forwardable bool `bson:"forwardable" bro:"forwardable" brotype:"bool" json:"forwardable"`
+	// Renewable : Flag to indicate if the ticket can be renewed
+	Renewable bool `bson:"renewable" bro:"renewable" brotype:"bool" json:"renewable"`
+	// AgentHostname names which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentHostname string `bson:"agent_hostname" bro:"agent_hostname" brotype:"string" json:"agent_hostname"`
+	// AgentUUID identifies which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentUUID string `bson:"agent_uuid" bro:"agent_uuid" brotype:"string" json:"agent_uuid"`
+	// NodeName names the Zeek cluster node which wrote this event
+	NodeName string `bson:"_node_name" bro:"_node_name" brotype:"string" json:"_node_name"`
+	// SystemName names the host which the Zeek cluster node writing this event ran on
+	SystemName string `bson:"_system_name" bro:"_system_name" brotype:"string" json:"_system_name"`
+}
+
+//TargetCollection returns the mongo collection this entry should be inserted
+func (line *Kerberos) TargetCollection(config *config.StructureTableCfg) string {
+	return config.KerberosTable
+}
+
+//ConvertFromJSON performs any extra conversions necessary when reading from JSON
+func (line *Kerberos) ConvertFromJSON() {
+	line.TimeStamp = convertTimestamp(line.TimeStampGeneric)
+	line.From = convertTimestamp(line.FromGeneric)
+	line.Till = convertTimestamp(line.TillGeneric)
+}
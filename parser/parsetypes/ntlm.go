@@ -0,0 +1,58 @@
+package parsetypes
+
+import (
+	"github.com/activecm/rita/config"
+)
+
+// NTLM provides a data structure for zeek's ntlm data
+type NTLM struct {
+	// TimeStamp of the authentication
+	TimeStamp int64 `bson:"ts" bro:"ts" brotype:"time" json:"-"`
+	// TimeStampGeneric is used when reading from json files
+	TimeStampGeneric interface{} `bson:"-" json:"ts"`
+	// UID is the Unique Id for this connection (generated by Bro)
+	UID string `bson:"uid" bro:"uid" brotype:"string" json:"uid"`
+	// Source is the source address for this connection
+	Source string `bson:"id_orig_h" bro:"id.orig_h" brotype:"addr" json:"id.orig_h"`
+	// SourcePort is the source port of this connection
+	SourcePort int `bson:"id_orig_p" bro:"id.orig_p" brotype:"port" json:"id.orig_p"`
+	// Destination is the destination of the connection
+	Destination string `bson:"id_resp_h" bro:"id.resp_h" brotype:"addr" json:"id.resp_h"`
+	// DestinationPort is the port at the destination host
+	DestinationPort int `bson:"id_resp_p" bro:"id.resp_p" brotype:"port" json:"id.resp_p"`
+	// Username : Username given by the client
+	Username string `bson:"username" bro:"username" brotype:"string" json:"username"`
+	// Hostname : Hostname given by the client
+	Hostname string `bson:"hostname" bro:"hostname" brotype:"string" json:"hostname"`
+	// DomainName : Domain name given by the client
+	DomainName string `bson:"domainname" bro:"domainname" brotype:"string" json:"domainname"`
+	// ServerNBComputerName : NetBIOS name given by the server in a CHALLENGE
+	ServerNBComputerName string `bson:"server_nb_computer_name" bro:"server_nb_computer_name" brotype:"string" json:"server_nb_computer_name"`
+	// ServerDNSComputerName : DNS name given by the server in a CHALLENGE
+	ServerDNSComputerName string `bson:"server_dns_computer_name" bro:"server_dns_computer_name" brotype:"string" json:"server_dns_computer_name"`
+	// ServerTreeName : Tree name given by the server in a CHALLENGE
+	ServerTreeName string `bson:"server_tree_name" bro:"server_tree_name" brotype:"string" json:"server_tree_name"`
+	// Success : Flag to indicate if the authentication was successful
+	Success bool `bson:"success" bro:"success" brotype:"bool" json:"success"`
+	// Status : Status of the authentication.
+	// Note: only present in older bro versions which don't record the success flag.
+	Status string `bson:"status" bro:"status" brotype:"string" json:"status"`
+	// AgentHostname names which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentHostname string `bson:"agent_hostname" bro:"agent_hostname" brotype:"string" json:"agent_hostname"`
+	// AgentUUID identifies which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentUUID string `bson:"agent_uuid" bro:"agent_uuid" brotype:"string" json:"agent_uuid"`
+	// NodeName names the Zeek cluster node which wrote this event
+	NodeName string `bson:"_node_name" bro:"_node_name" brotype:"string" json:"_node_name"`
+	// SystemName names the host which the Zeek cluster node writing this event ran on
+	SystemName string `bson:"_system_name" bro:"_system_name" brotype:"string" json:"_system_name"`
+}
+
+//TargetCollection returns the mongo collection this entry should be inserted
+func (line *NTLM) TargetCollection(config *config.StructureTableCfg) string {
+	return config.NTLMTable
+}
+
+//ConvertFromJSON performs any extra conversions necessary when reading from JSON
+func (line *NTLM) ConvertFromJSON() {
+	line.TimeStamp = convertTimestamp(line.TimeStampGeneric)
+}
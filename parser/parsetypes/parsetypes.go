@@ -1,6 +1,7 @@
 package parsetypes
 
 import (
+	"math"
 	"strings"
 	"time"
 
@@ -41,10 +42,57 @@ func NewBroDataFactory(fileType string) func() BroData {
 		return func() BroData {
 			return &SSL{}
 		}
+	} else if strings.HasPrefix(fileType, "ssh") {
+		return func() BroData {
+			return &SSH{}
+		}
+	} else if strings.HasPrefix(fileType, "x509") {
+		return func() BroData {
+			return &X509{}
+		}
+	} else if strings.HasPrefix(fileType, "dhcp") {
+		return func() BroData {
+			return &DHCP{}
+		}
+	} else if strings.HasPrefix(fileType, "files") {
+		return func() BroData {
+			return &Files{}
+		}
+	} else if strings.HasPrefix(fileType, "kerberos") {
+		return func() BroData {
+			return &Kerberos{}
+		}
+	} else if strings.HasPrefix(fileType, "ntlm") {
+		return func() BroData {
+			return &NTLM{}
+		}
 	}
 	return nil
 }
 
+// convertTimestampMillis handles a timestamp in multiple formats and converts
+// it to a Unix timestamp in milliseconds
+func convertTimestampMillis(timestamp interface{}) int64 {
+	switch input := timestamp.(type) {
+	case int:
+		return int64(input) * 1000
+	case int32:
+		return int64(input) * 1000
+	case int64:
+		return input * 1000
+	case float32:
+		return int64(math.Floor(float64(input) * 1000))
+	case float64:
+		return int64(math.Floor(input * 1000))
+	case string:
+		t, err := time.Parse(time.RFC3339, input)
+		if err == nil {
+			return t.UTC().UnixNano() / int64(time.Millisecond)
+		}
+	}
+	return 0
+}
+
 // convertTimestamp handles a timestamp in multiple formats and converts
 // it to a Unix timestamp
 func convertTimestamp(timestamp interface{}) int64 {
@@ -163,9 +211,15 @@ const (
 	// ENUM_SET is a SET which contains ENUMs
 	EnumSet = "set[enum]"
 
+	// ADDR_SET is a SET which contains ADDRs
+	AddrSet = "set[addr]"
+
 	// STRING_VECTOR is a VECTOR which contains STRINGs
 	StringVector = "vector[string]"
 
+	// ADDR_VECTOR is a VECTOR which contains ADDRs
+	AddrVector = "vector[addr]"
+
 	// INTERVAL_VECTOR is a VECTOR which contains INTERVALs
 	IntervalVector = "vector[interval]"
 
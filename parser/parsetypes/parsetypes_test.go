@@ -8,8 +8,8 @@ import (
 
 func TestNewBroDataFactory(t *testing.T) {
 
-	testCasesIn := []string{"conn", "http", "dns", "httpa", "http_a", "http_eth0", "httpasdf12345=-ASDF?", "open_conn", "ASDF"}
-	testCasesOut := []BroData{&Conn{}, &HTTP{}, &DNS{}, &HTTP{}, &HTTP{}, &HTTP{}, &HTTP{}, &OpenConn{}, nil}
+	testCasesIn := []string{"conn", "http", "dns", "httpa", "http_a", "http_eth0", "httpasdf12345=-ASDF?", "open_conn", "ssh", "x509", "dhcp", "kerberos", "ntlm", "files", "ASDF"}
+	testCasesOut := []BroData{&Conn{}, &HTTP{}, &DNS{}, &HTTP{}, &HTTP{}, &HTTP{}, &HTTP{}, &OpenConn{}, &SSH{}, &X509{}, &DHCP{}, &Kerberos{}, &NTLM{}, &Files{}, nil}
 	for i := range testCasesIn {
 		factory := NewBroDataFactory(testCasesIn[i])
 		if factory == nil {
@@ -38,3 +38,21 @@ func TestConvertTimestamp(t *testing.T) {
 		require.Equal(t, testCase.expected, actual, "input: %v", testCase.input)
 	}
 }
+
+func TestConvertTimestampMillis(t *testing.T) {
+	testCases := []struct {
+		input    interface{}
+		expected int64
+	}{
+		{1517336042.090842, 1517336042090},
+		{1517336042, 1517336042000},
+		{"2018-01-30T18:14:02.25Z", 1517336042250},
+		{"", 0},
+		{nil, 0},
+	}
+
+	for _, testCase := range testCases {
+		actual := convertTimestampMillis(testCase.input)
+		require.Equal(t, testCase.expected, actual, "input: %v", testCase.input)
+	}
+}
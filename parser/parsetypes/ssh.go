@@ -0,0 +1,71 @@
+package parsetypes
+
+import (
+	"github.com/activecm/rita/config"
+)
+
+// SSH provides a data structure for zeek's ssh data
+type SSH struct {
+	// TimeStamp of this connection
+	TimeStamp int64 `bson:"ts" bro:"ts" brotype:"time" json:"-"`
+	// TimeStampGeneric is used when reading from json files
+	TimeStampGeneric interface{} `bson:"-" json:"ts"`
+	// UID is the Unique Id for this connection (generated by Bro)
+	UID string `bson:"uid" bro:"uid" brotype:"string" json:"uid"`
+	// Source is the source address for this connection
+	Source string `bson:"id_orig_h" bro:"id.orig_h" brotype:"addr" json:"id.orig_h"`
+	// SourcePort is the source port of this connection
+	SourcePort int `bson:"id_orig_p" bro:"id.orig_p" brotype:"port" json:"id.orig_p"`
+	// Destination is the destination of the connection
+	Destination string `bson:"id_resp_h" bro:"id.resp_h" brotype:"addr" json:"id.resp_h"`
+	// DestinationPort is the port at the destination host
+	DestinationPort int `bson:"id_resp_p" bro:"id.resp_p" brotype:"port" json:"id.resp_p"`
+	// Version : SSH major version (1 or 2)
+	Version int `bson:"version" bro:"version" brotype:"count" json:"version"`
+	// AuthSuccess : Flag to indicate if the login succeeded. Unset if the outcome is unknown.
+	AuthSuccess bool `bson:"auth_success" bro:"auth_success" brotype:"bool" json:"auth_success"`
+	// AuthAttempts : The number of authentication attempts Zeek observed
+	AuthAttempts int `bson:"auth_attempts" bro:"auth_attempts" brotype:"count" json:"auth_attempts"`
+	// Direction : Direction of the connection (INBOUND or OUTBOUND)
+	Direction string `bson:"direction" bro:"direction" brotype:"enum" json:"direction"`
+	// Client : The client's version string
+	Client string `bson:"client" bro:"client" brotype:"string" json:"client"`
+	// Server : The server's version string
+	Server string `bson:"server" bro:"server" brotype:"string" json:"server"`
+	// CipherAlg : The encryption algorithm in use
+	CipherAlg string `bson:"cipher_alg" bro:"cipher_alg" brotype:"string" json:"cipher_alg"`
+	// MACAlg : The signing (MAC) algorithm in use
+	MACAlg string `bson:"mac_alg" bro:"mac_alg" brotype:"string" json:"mac_alg"`
+	// CompressionAlg : The compression algorithm in use
+	CompressionAlg string `bson:"compression_alg" bro:"compression_alg" brotype:"string" json:"compression_alg"`
+	// KexAlg : The key exchange algorithm in use
+	KexAlg string `bson:"kex_alg" bro:"kex_alg" brotype:"string" json:"kex_alg"`
+	// HostKeyAlg : The server host key's algorithm
+	HostKeyAlg string `bson:"host_key_alg" bro:"host_key_alg" brotype:"string" json:"host_key_alg"`
+	// HostKey : The server's key fingerprint
+	HostKey string `bson:"host_key" bro:"host_key" brotype:"string" json:"host_key"`
+	// HASSH : HASSH fingerprint of the client's key exchange algorithms.
+	// Note: only present when the hassh package is installed or in newer zeek versions.
+	HASSH string `bson:"hassh" bro:"hassh" brotype:"string" json:"hassh"`
+	// HASSHServer : HASSH fingerprint of the server's key exchange algorithms.
+	// Note: only present when the hassh package is installed or in newer zeek versions.
+	HASSHServer string `bson:"hasshServer" bro:"hasshServer" brotype:"string" json:"hasshServer"`
+	// AgentHostname names which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentHostname string `bson:"agent_hostname" bro:"agent_hostname" brotype:"string" json:"agent_hostname"`
+	// AgentUUID identifies which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentUUID string `bson:"agent_uuid" bro:"agent_uuid" brotype:"string" json:"agent_uuid"`
+	// NodeName names the Zeek cluster node which wrote this event
+	NodeName string `bson:"_node_name" bro:"_node_name" brotype:"string" json:"_node_name"`
+	// SystemName names the host which the Zeek cluster node writing this event ran on
+	SystemName string `bson:"_system_name" bro:"_system_name" brotype:"string" json:"_system_name"`
+}
+
+//TargetCollection returns the mongo collection this entry should be inserted
+func (line *SSH) TargetCollection(config *config.StructureTableCfg) string {
+	return config.SSHTable
+}
+
+//ConvertFromJSON performs any extra conversions necessary when reading from JSON
+func (line *SSH) ConvertFromJSON() {
+	line.TimeStamp = convertTimestamp(line.TimeStampGeneric)
+}
@@ -72,6 +72,9 @@ type SSL struct {
 	Logged bool `bson:"logged" bro:"logged" brotype:"bool" json:"logged"`
 	// CertChainFuids
 	CertChainFuids []string `bson:"cert_chain_fuids" bro:"cert_chain_fuids" brotype:"vector[string]" json:"cert_chain_fuids"`
+	// CertChainFps : SHA256 fingerprints of the certificates offered by the server.
+	// Note: may not be present in older bro versions.
+	CertChainFps []string `bson:"cert_chain_fps" bro:"cert_chain_fps" brotype:"vector[string]" json:"cert_chain_fps"`
 	// ClientCertChainFuids
 	ClientCertChainFuids []string `bson:"client_cert_chain_fuids"  bro:"client_cert_chain_fuids" brotype:"vector[string]" json:"client_cert_chain_fuids"`
 	// Subject
@@ -94,6 +97,10 @@ type SSL struct {
 	AgentHostname string `bson:"agent_hostname" bro:"agent_hostname" brotype:"string" json:"agent_hostname"`
 	// AgentUUID identifies which sensor recorded this event. Only set when combining logs from multiple sensors.
 	AgentUUID string `bson:"agent_uuid" bro:"agent_uuid" brotype:"string" json:"agent_uuid"`
+	// NodeName names the Zeek cluster node which wrote this event
+	NodeName string `bson:"_node_name" bro:"_node_name" brotype:"string" json:"_node_name"`
+	// SystemName names the host which the Zeek cluster node writing this event ran on
+	SystemName string `bson:"_system_name" bro:"_system_name" brotype:"string" json:"_system_name"`
 }
 
 //TargetCollection returns the mongo collection this entry should be inserted
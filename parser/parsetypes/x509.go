@@ -0,0 +1,84 @@
+package parsetypes
+
+import (
+	"github.com/activecm/rita/config"
+)
+
+// X509 provides a data structure for zeek's x509 certificate data
+type X509 struct {
+	// TimeStamp of when the certificate was seen
+	TimeStamp int64 `bson:"ts" bro:"ts" brotype:"time" json:"-"`
+	// TimeStampGeneric is used when reading from json files
+	TimeStampGeneric interface{} `bson:"-" json:"ts"`
+	// ID is the file ID of the certificate. The ssl log refers to certificates by this ID
+	// in cert_chain_fuids.
+	ID string `bson:"id" bro:"id" brotype:"string" json:"id"`
+	// Fingerprint is the SHA256 fingerprint of the certificate. The ssl log refers to
+	// certificates by their fingerprint in cert_chain_fps.
+	// Note: may not be present in older bro versions.
+	Fingerprint string `bson:"fingerprint" bro:"fingerprint" brotype:"string" json:"fingerprint"`
+	// Version : Version number of the certificate
+	Version int `bson:"certificate_version" bro:"certificate.version" brotype:"count" json:"certificate.version"`
+	// Serial : Serial number of the certificate
+	Serial string `bson:"certificate_serial" bro:"certificate.serial" brotype:"string" json:"certificate.serial"`
+	// Subject : Subject of the certificate
+	Subject string `bson:"certificate_subject" bro:"certificate.subject" brotype:"string" json:"certificate.subject"`
+	// Issuer : Issuer of the certificate
+	Issuer string `bson:"certificate_issuer" bro:"certificate.issuer" brotype:"string" json:"certificate.issuer"`
+	// NotValidBefore : Timestamp before which the certificate is not valid
+	NotValidBefore int64 `bson:"certificate_not_valid_before" bro:"certificate.not_valid_before" brotype:"time" json:"-"`
+	// NotValidBeforeGeneric is used when reading from json files
+	NotValidBeforeGeneric interface{} `bson:"-" json:"certificate.not_valid_before"`
+	// NotValidAfter : Timestamp after which the certificate is not valid
+	NotValidAfter int64 `bson:"certificate_not_valid_after" bro:"certificate.not_valid_after" brotype:"time" json:"-"`
+	// NotValidAfterGeneric is used when reading from json files
+	NotValidAfterGeneric interface{} `bson:"-" json:"certificate.not_valid_after"`
+	// KeyAlgorithm : Name of the key algorithm
+	KeyAlgorithm string `bson:"certificate_key_alg" bro:"certificate.key_alg" brotype:"string" json:"certificate.key_alg"`
+	// SignatureAlgorithm : Name of the signature algorithm
+	SignatureAlgorithm string `bson:"certificate_sig_alg" bro:"certificate.sig_alg" brotype:"string" json:"certificate.sig_alg"`
+	// KeyType : Key type, if the key is parseable by openssl (either rsa, dsa or ec)
+	KeyType string `bson:"certificate_key_type" bro:"certificate.key_type" brotype:"string" json:"certificate.key_type"`
+	// KeyLength : Key length in bits
+	KeyLength int `bson:"certificate_key_length" bro:"certificate.key_length" brotype:"count" json:"certificate.key_length"`
+	// Exponent : Exponent, if RSA
+	Exponent string `bson:"certificate_exponent" bro:"certificate.exponent" brotype:"string" json:"certificate.exponent"`
+	// Curve : Curve, if EC
+	Curve string `bson:"certificate_curve" bro:"certificate.curve" brotype:"string" json:"certificate.curve"`
+	// SANDNS : List of DNS entries in the subject alternative name extension
+	SANDNS []string `bson:"san_dns" bro:"san.dns" brotype:"vector[string]" json:"san.dns"`
+	// SANURI : List of URI entries in the subject alternative name extension
+	SANURI []string `bson:"san_uri" bro:"san.uri" brotype:"vector[string]" json:"san.uri"`
+	// SANEmail : List of email entries in the subject alternative name extension
+	SANEmail []string `bson:"san_email" bro:"san.email" brotype:"vector[string]" json:"san.email"`
+	// SANIP : List of IP entries in the subject alternative name extension
+	SANIP []string `bson:"san_ip" bro:"san.ip" brotype:"vector[addr]" json:"san.ip"`
+	// CA : Flag to indicate if the certificate belongs to a certificate authority
+	CA bool `bson:"basic_constraints_ca" bro:"basic_constraints.ca" brotype:"bool" json:"basic_constraints.ca"`
+	// HostCert : Flag to indicate if the certificate was sent by a server
+	// Note: may not be present in older bro versions.
+	HostCert bool `bson:"host_cert" bro:"host_cert" brotype:"bool" json:"host_cert"`
+	// ClientCert : Flag to indicate if the certificate was sent by a client
+	// Note: may not be present in older bro versions.
+	ClientCert bool `bson:"client_cert" bro:"client_cert" brotype:"bool" json:"client_cert"`
+	// AgentHostname names which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentHostname string `bson:"agent_hostname" bro:"agent_hostname" brotype:"string" json:"agent_hostname"`
+	// AgentUUID identifies which sensor recorded this event. Only set when combining logs from multiple sensors.
+	AgentUUID string `bson:"agent_uuid" bro:"agent_uuid" brotype:"string" json:"agent_uuid"`
+	// NodeName names the Zeek cluster node which wrote this event
+	NodeName string `bson:"_node_name" bro:"_node_name" brotype:"string" json:"_node_name"`
+	// SystemName names the host which the Zeek cluster node writing this event ran on
+	SystemName string `bson:"_system_name" bro:"_system_name" brotype:"string" json:"_system_name"`
+}
+
+//TargetCollection returns the mongo collection this entry should be inserted
+func (line *X509) TargetCollection(config *config.StructureTableCfg) string {
+	return config.X509Table
+}
+
+//ConvertFromJSON performs any extra conversions necessary when reading from JSON
+func (line *X509) ConvertFromJSON() {
+	line.TimeStamp = convertTimestamp(line.TimeStampGeneric)
+	line.NotValidBefore = convertTimestamp(line.NotValidBeforeGeneric)
+	line.NotValidAfter = convertTimestamp(line.NotValidAfterGeneric)
+}
@@ -0,0 +1,584 @@
+// Package pcap synthesizes Zeek style log records from raw packet captures so that
+// pcap and pcapng files can be imported without running Zeek first. Packets are
+// grouped into connections which produce conn records, while the DNS, HTTP, and
+// TLS payloads carried by those connections produce dns, http, and ssl records.
+package pcap
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/activecm/rita/parser/parsetypes"
+	log "github.com/sirupsen/logrus"
+)
+
+// inactivity timeouts after which a connection is considered finished.
+// These match Zeek's defaults.
+const (
+	tcpInactivityTimeout    = 5 * time.Minute
+	udpInactivityTimeout    = 1 * time.Minute
+	icmpInactivityTimeout   = 1 * time.Minute
+	tcpCloseTimeout         = 5 * time.Second
+	sweepIntervalPackets    = 10000
+	maxHandshakeBufferBytes = 64 * 1024
+)
+
+// application layer protocols detected on a connection
+const (
+	appUnknown = iota
+	appNone
+	appDNS
+	appHTTP
+	appTLS
+)
+
+// flowKey identifies a connection by its originator and responder endpoints
+type flowKey struct {
+	origIP   [16]byte
+	respIP   [16]byte
+	origPort uint16
+	respPort uint16
+	proto    uint8
+}
+
+// reverse returns the key for traffic flowing from the responder to the originator
+func (k flowKey) reverse() flowKey {
+	return flowKey{
+		origIP:   k.respIP,
+		respIP:   k.origIP,
+		origPort: k.respPort,
+		respPort: k.origPort,
+		proto:    k.proto,
+	}
+}
+
+// endpointState tracks the traffic sent by one side of a connection
+type endpointState struct {
+	pkts        int64
+	ipBytes     int64
+	bytes       int64
+	missedBytes int64
+	syn         bool
+	fin         bool
+	rst         bool
+	seqSet      bool
+	nextSeq     uint32
+}
+
+// flow holds the state of a single connection
+type flow struct {
+	key      flowKey
+	uid      string
+	origIP   string
+	respIP   string
+	origPort int
+	respPort int
+	proto    uint8
+	start    time.Time
+	last     time.Time
+	history  []byte
+	orig     endpointState
+	resp     endpointState
+	synAck   bool
+	app      int
+
+	dns  *dnsState
+	http *httpState
+	tls  *tlsState
+}
+
+// Converter groups packets into connections and synthesizes Zeek style
+// conn, dns, http, and ssl records from them
+type Converter struct {
+	emit        func(parsetypes.BroData)
+	flows       map[flowKey]*flow
+	uidSeed     uint64
+	uidCounter  uint64
+	packetCount int
+	now         time.Time
+}
+
+// NewConverter creates a Converter which passes each synthesized record to emit.
+// The seed is used to generate connection UIDs.
+func NewConverter(seed string, emit func(parsetypes.BroData)) *Converter {
+	hash := fnv.New64a()
+	hash.Write([]byte(seed))
+	return &Converter{
+		emit:    emit,
+		flows:   make(map[flowKey]*flow),
+		uidSeed: hash.Sum64(),
+	}
+}
+
+// ReadCapture reads every packet from a pcap or pcapng stream and passes the
+// synthesized records to emit. The name of the capture seeds the connection UIDs.
+func ReadCapture(r io.Reader, name string, emit func(parsetypes.BroData), logger *log.Logger) error {
+	packetReader, err := NewPacketReader(r)
+	if err != nil {
+		return err
+	}
+
+	converter := NewConverter(name, emit)
+	for {
+		pkt, err := packetReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"file":  name,
+				"error": err.Error(),
+			}).Error("Stopped reading corrupt packet capture")
+			break
+		}
+		converter.AddPacket(pkt)
+	}
+	converter.Flush()
+	return nil
+}
+
+// AddPacket adds a single captured frame to its connection
+func (c *Converter) AddPacket(pkt Packet) {
+	decoded, ok := decodePacket(pkt)
+	if !ok {
+		return
+	}
+
+	if decoded.ts.After(c.now) {
+		c.now = decoded.ts
+	}
+
+	c.packetCount++
+	if c.packetCount%sweepIntervalPackets == 0 {
+		c.sweep()
+	}
+
+	key := flowKey{
+		origPort: decoded.srcPort,
+		respPort: decoded.dstPort,
+		proto:    decoded.proto,
+	}
+	copy(key.origIP[:], decoded.src.To16())
+	copy(key.respIP[:], decoded.dst.To16())
+
+	// ICMP messages and their replies use different types, so group them by address pair
+	if decoded.proto == protoICMP || decoded.proto == protoICMPv6 {
+		key.origPort, key.respPort = 0, 0
+	}
+
+	fromOrig := true
+	f, ok := c.flows[key]
+	if !ok {
+		if f, ok = c.flows[key.reverse()]; ok {
+			fromOrig = false
+		}
+	}
+
+	// a new connection reusing the tuple of an old one starts a new flow
+	if f != nil && c.isNewConnection(f, decoded, fromOrig) {
+		c.finish(f)
+		f = nil
+	}
+
+	if f == nil {
+		// a SYN-ACK seen without the SYN means the packet came from the responder
+		if decoded.proto == protoTCP && decoded.tcpFlags&(tcpSYN|tcpACK) == tcpSYN|tcpACK {
+			key = key.reverse()
+			fromOrig = false
+			f = c.newFlow(key, decoded.ts, decoded.dst, decoded.src, int(decoded.dstPort), int(decoded.srcPort))
+		} else {
+			fromOrig = true
+			f = c.newFlow(key, decoded.ts, decoded.src, decoded.dst, int(decoded.srcPort), int(decoded.dstPort))
+		}
+		c.flows[key] = f
+	}
+
+	f.last = decoded.ts
+
+	endpoint := &f.orig
+	if !fromOrig {
+		endpoint = &f.resp
+	}
+	endpoint.pkts++
+	endpoint.ipBytes += int64(decoded.ipLen)
+
+	switch decoded.proto {
+	case protoTCP:
+		c.addTCP(f, endpoint, decoded, fromOrig)
+	case protoUDP:
+		if len(decoded.payload) > 0 {
+			endpoint.bytes += int64(len(decoded.payload))
+			c.addUDPPayload(f, decoded, fromOrig)
+		}
+	default:
+		endpoint.bytes += int64(len(decoded.payload))
+	}
+}
+
+// isNewConnection determines whether a packet belongs to a new connection
+// which reuses the 5-tuple of an existing flow
+func (c *Converter) isNewConnection(f *flow, decoded decodedPacket, fromOrig bool) bool {
+	if decoded.ts.Sub(f.last) > inactivityTimeout(f) {
+		return true
+	}
+	if f.proto == protoTCP && fromOrig && decoded.tcpFlags&(tcpSYN|tcpACK) == tcpSYN {
+		return f.closed()
+	}
+	return false
+}
+
+func (c *Converter) newFlow(key flowKey, ts time.Time, orig, resp net.IP, origPort, respPort int) *flow {
+	c.uidCounter++
+	return &flow{
+		key:      key,
+		uid:      c.newUID(key, ts),
+		origIP:   orig.String(),
+		respIP:   resp.String(),
+		origPort: origPort,
+		respPort: respPort,
+		proto:    key.proto,
+		start:    ts,
+		last:     ts,
+	}
+}
+
+// newUID generates a Zeek style connection UID. UIDs are derived from the capture,
+// the connection tuple, and the start time so that they are stable across imports.
+func (c *Converter) newUID(key flowKey, ts time.Time) string {
+	const base62 = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	hash := fnv.New64a()
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], c.uidSeed)
+	hash.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], c.uidCounter)
+	hash.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], uint64(ts.UnixNano()))
+	hash.Write(buf[:])
+	hash.Write(key.origIP[:])
+	hash.Write(key.respIP[:])
+	binary.BigEndian.PutUint16(buf[:2], key.origPort)
+	binary.BigEndian.PutUint16(buf[2:4], key.respPort)
+	buf[4] = key.proto
+	hash.Write(buf[:5])
+
+	value := hash.Sum64()
+	uid := []byte{'C'}
+	for value > 0 {
+		uid = append(uid, base62[value%62])
+		value /= 62
+	}
+	return string(uid)
+}
+
+// addTCP updates the connection state with a TCP segment and passes
+// any new payload on to the application layer parsers
+func (c *Converter) addTCP(f *flow, endpoint *endpointState, decoded decodedPacket, fromOrig bool) {
+	flags := decoded.tcpFlags
+
+	letter := func(upper byte) {
+		if fromOrig {
+			f.history = appendHistory(f.history, upper)
+		} else {
+			f.history = appendHistory(f.history, upper+('a'-'A'))
+		}
+	}
+
+	if flags&tcpSYN != 0 {
+		endpoint.syn = true
+		endpoint.seqSet = true
+		endpoint.nextSeq = decoded.tcpSeq + 1
+		if flags&tcpACK != 0 {
+			f.synAck = true
+			letter('H')
+		} else {
+			letter('S')
+		}
+	}
+	if flags&tcpFIN != 0 {
+		endpoint.fin = true
+		letter('F')
+	}
+	if flags&tcpRST != 0 {
+		endpoint.rst = true
+		letter('R')
+	}
+	if flags&tcpACK != 0 && flags&tcpSYN == 0 && len(decoded.payload) == 0 {
+		letter('A')
+	}
+
+	if len(decoded.payload) == 0 {
+		return
+	}
+	letter('D')
+
+	payload := decoded.payload
+	seq := decoded.tcpSeq
+	gap := false
+
+	if !endpoint.seqSet {
+		endpoint.seqSet = true
+		endpoint.nextSeq = seq
+	}
+
+	if diff := int32(seq - endpoint.nextSeq); diff < 0 {
+		// retransmission, only keep any data past what has already been seen
+		overlap := int(-diff)
+		if overlap >= len(payload) {
+			return
+		}
+		payload = payload[overlap:]
+	} else if diff > 0 {
+		// data was not captured
+		endpoint.missedBytes += int64(diff)
+		gap = true
+	}
+
+	endpoint.bytes += int64(len(payload))
+	endpoint.nextSeq = seq + uint32(len(decoded.payload))
+
+	c.addTCPPayload(f, decoded.ts, payload, fromOrig, gap)
+}
+
+// appendHistory appends a history letter to the connection history unless it is
+// already present. Zeek records each letter once per direction.
+func appendHistory(history []byte, letter byte) []byte {
+	for _, b := range history {
+		if b == letter {
+			return history
+		}
+	}
+	return append(history, letter)
+}
+
+// addTCPPayload detects the application layer protocol of a TCP stream
+// and passes the payload on to the matching parser
+func (c *Converter) addTCPPayload(f *flow, ts time.Time, payload []byte, fromOrig bool, gap bool) {
+	if f.app == appUnknown {
+		switch {
+		case fromOrig && looksLikeTLS(payload):
+			f.app = appTLS
+			f.tls = &tlsState{}
+		case fromOrig && looksLikeHTTPRequest(payload):
+			f.app = appHTTP
+			f.http = &httpState{}
+		case isDNSPort(f.respPort):
+			f.app = appDNS
+			f.dns = newDNSState()
+		default:
+			f.app = appNone
+		}
+	}
+
+	switch f.app {
+	case appTLS:
+		f.tls.add(payload, fromOrig, gap, ts)
+	case appHTTP:
+		for _, record := range f.http.add(f, payload, fromOrig, gap, ts) {
+			c.emit(record)
+		}
+	case appDNS:
+		// DNS over TCP prefixes each message with its length. Only messages
+		// which fit within a single segment are parsed.
+		if gap || len(payload) < 2 {
+			return
+		}
+		msgLen := int(binary.BigEndian.Uint16(payload[0:2]))
+		if msgLen > len(payload)-2 {
+			return
+		}
+		c.addDNSMessage(f, payload[2:2+msgLen], fromOrig, ts)
+	}
+}
+
+// addUDPPayload passes UDP payloads on to the DNS parser
+func (c *Converter) addUDPPayload(f *flow, decoded decodedPacket, fromOrig bool) {
+	if f.app == appUnknown {
+		if isDNSPort(f.respPort) || isDNSPort(f.origPort) {
+			f.app = appDNS
+			f.dns = newDNSState()
+		} else {
+			f.app = appNone
+		}
+	}
+	if f.app == appDNS {
+		c.addDNSMessage(f, decoded.payload, fromOrig, decoded.ts)
+	}
+}
+
+func (c *Converter) addDNSMessage(f *flow, payload []byte, fromOrig bool, ts time.Time) {
+	msg, ok := parseDNSMessage(payload)
+	if !ok {
+		return
+	}
+	if record := f.dns.add(f, msg, ts); record != nil {
+		c.emit(record)
+	}
+}
+
+// inactivityTimeout returns how long a connection may be idle before it is considered finished
+func inactivityTimeout(f *flow) time.Duration {
+	switch f.proto {
+	case protoTCP:
+		if f.closed() {
+			return tcpCloseTimeout
+		}
+		return tcpInactivityTimeout
+	case protoUDP:
+		return udpInactivityTimeout
+	}
+	return icmpInactivityTimeout
+}
+
+// closed reports whether a TCP connection has been torn down
+func (f *flow) closed() bool {
+	return (f.orig.fin && f.resp.fin) || f.orig.rst || f.resp.rst
+}
+
+// sweep finishes any connections which have been inactive for longer than their timeout
+func (c *Converter) sweep() {
+	var expired []*flow
+	for _, f := range c.flows {
+		if c.now.Sub(f.last) > inactivityTimeout(f) {
+			expired = append(expired, f)
+		}
+	}
+	sortFlows(expired)
+	for _, f := range expired {
+		c.finish(f)
+	}
+}
+
+// Flush finishes all of the connections which are still being tracked
+func (c *Converter) Flush() {
+	remaining := make([]*flow, 0, len(c.flows))
+	for _, f := range c.flows {
+		remaining = append(remaining, f)
+	}
+	sortFlows(remaining)
+	for _, f := range remaining {
+		c.finish(f)
+	}
+}
+
+// sortFlows orders flows by their start time so records are emitted in a stable order
+func sortFlows(flows []*flow) {
+	sort.Slice(flows, func(i, j int) bool {
+		if flows[i].start.Equal(flows[j].start) {
+			return flows[i].uid < flows[j].uid
+		}
+		return flows[i].start.Before(flows[j].start)
+	})
+}
+
+// finish emits the records for a connection and stops tracking it
+func (c *Converter) finish(f *flow) {
+	delete(c.flows, f.key)
+
+	if f.dns != nil {
+		for _, record := range f.dns.flush() {
+			c.emit(record)
+		}
+	}
+	if f.http != nil {
+		for _, record := range f.http.flush() {
+			c.emit(record)
+		}
+	}
+	if f.tls != nil {
+		if record := f.tls.record(f); record != nil {
+			c.emit(record)
+		}
+	}
+
+	c.emit(f.connRecord())
+}
+
+// connRecord builds the conn log entry for a connection
+func (f *flow) connRecord() *parsetypes.Conn {
+	return &parsetypes.Conn{
+		TimeStamp:       f.start.Unix(),
+		TimeStampMs:     f.start.UnixMilli(),
+		UID:             f.uid,
+		Source:          f.origIP,
+		SourcePort:      f.origPort,
+		Destination:     f.respIP,
+		DestinationPort: f.respPort,
+		Proto:           protoName(f.proto),
+		Service:         f.service(),
+		Duration:        f.last.Sub(f.start).Seconds(),
+		OrigBytes:       f.orig.bytes,
+		RespBytes:       f.resp.bytes,
+		ConnState:       f.connState(),
+		MissedBytes:     f.orig.missedBytes + f.resp.missedBytes,
+		History:         string(f.history),
+		OrigPkts:        f.orig.pkts,
+		OrigIPBytes:     f.orig.ipBytes,
+		RespPkts:        f.resp.pkts,
+		RespIPBytes:     f.resp.ipBytes,
+	}
+}
+
+// service returns the Zeek service name for the application layer protocol
+func (f *flow) service() string {
+	switch f.app {
+	case appDNS:
+		if f.dns.seen {
+			return "dns"
+		}
+	case appHTTP:
+		return "http"
+	case appTLS:
+		if f.tls.clientHelloSeen {
+			return "ssl"
+		}
+	}
+	return ""
+}
+
+// connState summarizes the connection in the same manner as Zeek's conn_state field
+// https://docs.zeek.org/en/master/scripts/base/protocols/conn/main.zeek.html
+func (f *flow) connState() string {
+	if f.proto != protoTCP {
+		if f.resp.pkts > 0 {
+			return "SF"
+		}
+		return "S0"
+	}
+
+	switch {
+	case f.orig.syn && !f.synAck && f.resp.rst:
+		return "REJ"
+	case f.orig.syn && !f.synAck && f.resp.pkts == 0:
+		return "S0"
+	case !f.orig.syn && !f.synAck:
+		return "OTH"
+	case f.orig.rst:
+		return "RSTO"
+	case f.resp.rst:
+		return "RSTR"
+	case f.orig.fin && f.resp.fin:
+		return "SF"
+	case f.orig.fin:
+		return "S2"
+	case f.resp.fin:
+		return "S3"
+	}
+	return "S1"
+}
+
+func protoName(proto uint8) string {
+	switch proto {
+	case protoTCP:
+		return "tcp"
+	case protoUDP:
+		return "udp"
+	case protoICMP, protoICMPv6:
+		return "icmp"
+	}
+	return "unknown_transport"
+}
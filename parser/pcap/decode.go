@@ -0,0 +1,264 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+)
+
+// IP protocol numbers
+const (
+	protoICMP   uint8 = 1
+	protoTCP    uint8 = 6
+	protoUDP    uint8 = 17
+	protoICMPv6 uint8 = 58
+)
+
+// TCP flags
+const (
+	tcpFIN uint8 = 0x01
+	tcpSYN uint8 = 0x02
+	tcpRST uint8 = 0x04
+	tcpACK uint8 = 0x10
+)
+
+// ethertypes
+const (
+	etherTypeIPv4  uint16 = 0x0800
+	etherTypeIPv6  uint16 = 0x86dd
+	etherTypeVLAN  uint16 = 0x8100
+	etherTypeQinQ  uint16 = 0x88a8
+	etherTypeQinQ2 uint16 = 0x9100
+)
+
+// decodedPacket holds the network and transport layer details of a packet
+type decodedPacket struct {
+	ts       time.Time
+	src      net.IP
+	dst      net.IP
+	proto    uint8
+	srcPort  uint16
+	dstPort  uint16
+	ipLen    int
+	tcpFlags uint8
+	tcpSeq   uint32
+	payload  []byte
+}
+
+// decodePacket strips the link layer header from a frame and decodes the IP and
+// transport headers. Returns false if the packet does not hold IPv4 or IPv6 traffic.
+func decodePacket(pkt Packet) (decodedPacket, bool) {
+	data := pkt.Data
+	var etherType uint16
+
+	switch pkt.LinkType {
+	case linkTypeEthernet:
+		if len(data) < 14 {
+			return decodedPacket{}, false
+		}
+		etherType = binary.BigEndian.Uint16(data[12:14])
+		data = data[14:]
+		// strip any 802.1Q tags
+		for etherType == etherTypeVLAN || etherType == etherTypeQinQ || etherType == etherTypeQinQ2 {
+			if len(data) < 4 {
+				return decodedPacket{}, false
+			}
+			etherType = binary.BigEndian.Uint16(data[2:4])
+			data = data[4:]
+		}
+	case linkTypeLinuxSLL:
+		if len(data) < 16 {
+			return decodedPacket{}, false
+		}
+		etherType = binary.BigEndian.Uint16(data[14:16])
+		data = data[16:]
+	case linkTypeLinuxSLL2:
+		if len(data) < 20 {
+			return decodedPacket{}, false
+		}
+		etherType = binary.BigEndian.Uint16(data[0:2])
+		data = data[20:]
+	case linkTypeNull:
+		if len(data) < 4 {
+			return decodedPacket{}, false
+		}
+		// the address family is stored in the byte order of the capturing host
+		family := binary.LittleEndian.Uint32(data[0:4])
+		if family > 0xffff {
+			family = binary.BigEndian.Uint32(data[0:4])
+		}
+		switch family {
+		case 2:
+			etherType = etherTypeIPv4
+		case 24, 28, 30:
+			etherType = etherTypeIPv6
+		}
+		data = data[4:]
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		if len(data) < 1 {
+			return decodedPacket{}, false
+		}
+		switch data[0] >> 4 {
+		case 4:
+			etherType = etherTypeIPv4
+		case 6:
+			etherType = etherTypeIPv6
+		}
+	default:
+		return decodedPacket{}, false
+	}
+
+	decoded := decodedPacket{ts: pkt.Timestamp}
+	var transport []byte
+	var ok bool
+
+	switch etherType {
+	case etherTypeIPv4:
+		transport, ok = decoded.decodeIPv4(data)
+	case etherTypeIPv6:
+		transport, ok = decoded.decodeIPv6(data)
+	}
+	if !ok {
+		return decodedPacket{}, false
+	}
+
+	return decoded, decoded.decodeTransport(transport)
+}
+
+// decodeIPv4 fills in the IP details and returns the transport layer bytes
+func (d *decodedPacket) decodeIPv4(data []byte) ([]byte, bool) {
+	if len(data) < 20 {
+		return nil, false
+	}
+	headerLen := int(data[0]&0x0f) * 4
+	totalLen := int(binary.BigEndian.Uint16(data[2:4]))
+	if headerLen < 20 || len(data) < headerLen {
+		return nil, false
+	}
+
+	// trim any link layer padding, totalLen may be 0 with TCP segmentation offload
+	if totalLen >= headerLen && totalLen < len(data) {
+		data = data[:totalLen]
+	}
+	if totalLen == 0 {
+		totalLen = len(data)
+	}
+
+	d.src = net.IP(append([]byte(nil), data[12:16]...))
+	d.dst = net.IP(append([]byte(nil), data[16:20]...))
+	d.proto = data[9]
+	d.ipLen = totalLen
+
+	// only the first fragment carries the transport header
+	fragOffset := binary.BigEndian.Uint16(data[6:8]) & 0x1fff
+	if fragOffset != 0 {
+		return nil, true
+	}
+
+	return data[headerLen:], true
+}
+
+// decodeIPv6 fills in the IP details and returns the transport layer bytes
+func (d *decodedPacket) decodeIPv6(data []byte) ([]byte, bool) {
+	if len(data) < 40 {
+		return nil, false
+	}
+	payloadLen := int(binary.BigEndian.Uint16(data[4:6]))
+	if payloadLen > 0 && 40+payloadLen < len(data) {
+		data = data[:40+payloadLen]
+	}
+
+	d.src = net.IP(append([]byte(nil), data[8:24]...))
+	d.dst = net.IP(append([]byte(nil), data[24:40]...))
+	d.ipLen = len(data)
+	if payloadLen > 0 {
+		d.ipLen = 40 + payloadLen
+	}
+
+	nextHeader := data[6]
+	data = data[40:]
+
+	// walk the extension headers
+	for {
+		switch nextHeader {
+		case 0, 43, 60: // hop-by-hop, routing, destination options
+			if len(data) < 8 {
+				return nil, false
+			}
+			extLen := (int(data[1]) + 1) * 8
+			if len(data) < extLen {
+				return nil, false
+			}
+			nextHeader = data[0]
+			data = data[extLen:]
+		case 44: // fragment
+			if len(data) < 8 {
+				return nil, false
+			}
+			fragOffset := binary.BigEndian.Uint16(data[2:4]) >> 3
+			nextHeader = data[0]
+			data = data[8:]
+			if fragOffset != 0 {
+				d.proto = nextHeader
+				return nil, true
+			}
+		case 51: // authentication header
+			if len(data) < 8 {
+				return nil, false
+			}
+			extLen := (int(data[1]) + 2) * 4
+			if len(data) < extLen {
+				return nil, false
+			}
+			nextHeader = data[0]
+			data = data[extLen:]
+		default:
+			d.proto = nextHeader
+			return data, true
+		}
+	}
+}
+
+// decodeTransport fills in the ports, flags, and payload from the TCP, UDP, or ICMP
+// header. Fragments without a transport header are accepted with zeroed ports.
+func (d *decodedPacket) decodeTransport(data []byte) bool {
+	if data == nil {
+		return true
+	}
+
+	switch d.proto {
+	case protoTCP:
+		if len(data) < 20 {
+			return false
+		}
+		d.srcPort = binary.BigEndian.Uint16(data[0:2])
+		d.dstPort = binary.BigEndian.Uint16(data[2:4])
+		d.tcpSeq = binary.BigEndian.Uint32(data[4:8])
+		d.tcpFlags = data[13]
+		headerLen := int(data[12]>>4) * 4
+		if headerLen < 20 || headerLen > len(data) {
+			return false
+		}
+		d.payload = data[headerLen:]
+	case protoUDP:
+		if len(data) < 8 {
+			return false
+		}
+		d.srcPort = binary.BigEndian.Uint16(data[0:2])
+		d.dstPort = binary.BigEndian.Uint16(data[2:4])
+		udpLen := int(binary.BigEndian.Uint16(data[4:6]))
+		if udpLen >= 8 && udpLen < len(data) {
+			data = data[:udpLen]
+		}
+		d.payload = data[8:]
+	case protoICMP, protoICMPv6:
+		if len(data) < 4 {
+			return false
+		}
+		// like Zeek, report the ICMP type and code in place of the ports
+		d.srcPort = uint16(data[0])
+		d.dstPort = uint16(data[1])
+		d.payload = data[4:]
+	}
+	return true
+}
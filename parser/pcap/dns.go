@@ -0,0 +1,316 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/parser/parsetypes"
+)
+
+// dnsMessage holds the fields of a DNS message which are recorded in the dns log
+type dnsMessage struct {
+	id       uint16
+	response bool
+	aa       bool
+	tc       bool
+	rd       bool
+	ra       bool
+	z        int64
+	rcode    int64
+	query    string
+	qtype    int64
+	qclass   int64
+	hasQuery bool
+	answers  []string
+	ttls     []float64
+}
+
+// pendingQuery is a DNS query which is waiting on a response
+type pendingQuery struct {
+	record *parsetypes.DNS
+	ts     time.Time
+}
+
+// dnsState matches DNS queries to their responses within a connection
+type dnsState struct {
+	seen    bool
+	pending map[uint16]pendingQuery
+}
+
+func newDNSState() *dnsState {
+	return &dnsState{pending: make(map[uint16]pendingQuery)}
+}
+
+// isDNSPort reports whether DNS is expected on the given port
+func isDNSPort(port int) bool {
+	return port == 53 || port == 5353 || port == 5355
+}
+
+// add records a DNS message, returning a finished dns record once the response
+// for a query is seen
+func (d *dnsState) add(f *flow, msg dnsMessage, ts time.Time) *parsetypes.DNS {
+	d.seen = true
+
+	if !msg.response {
+		var previous *parsetypes.DNS
+		// a repeated transaction id without a response finishes the earlier query
+		if earlier, ok := d.pending[msg.id]; ok {
+			previous = earlier.record
+		}
+		record := newDNSRecord(f, msg, ts)
+		record.RD = msg.rd
+		record.Z = msg.z
+		d.pending[msg.id] = pendingQuery{record: record, ts: ts}
+		return previous
+	}
+
+	var record *parsetypes.DNS
+	if query, ok := d.pending[msg.id]; ok {
+		delete(d.pending, msg.id)
+		record = query.record
+		record.RTT = ts.Sub(query.ts).Seconds()
+	} else {
+		record = newDNSRecord(f, msg, ts)
+		record.RD = msg.rd
+		record.Z = msg.z
+	}
+
+	record.RCode = msg.rcode
+	record.RCodeName = dnsRCodeName(msg.rcode)
+	record.AA = msg.aa
+	record.TC = msg.tc
+	record.RA = msg.ra
+	record.Answers = msg.answers
+	record.TTLs = msg.ttls
+	record.Rejected = msg.rcode == 5
+	return record
+}
+
+// flush returns the queries which never received a response
+func (d *dnsState) flush() []*parsetypes.DNS {
+	records := make([]*parsetypes.DNS, 0, len(d.pending))
+	for id, query := range d.pending {
+		records = append(records, query.record)
+		delete(d.pending, id)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].TimeStamp == records[j].TimeStamp {
+			return records[i].TransID < records[j].TransID
+		}
+		return records[i].TimeStamp < records[j].TimeStamp
+	})
+	return records
+}
+
+func newDNSRecord(f *flow, msg dnsMessage, ts time.Time) *parsetypes.DNS {
+	record := &parsetypes.DNS{
+		TimeStamp:       ts.Unix(),
+		UID:             f.uid,
+		Source:          f.origIP,
+		SourcePort:      f.origPort,
+		Destination:     f.respIP,
+		DestinationPort: f.respPort,
+		Proto:           protoName(f.proto),
+		TransID:         int64(msg.id),
+	}
+	if msg.hasQuery {
+		record.Query = msg.query
+		record.QClass = msg.qclass
+		record.QClassName = dnsClassName(msg.qclass)
+		record.QType = msg.qtype
+		record.QTypeName = dnsTypeName(msg.qtype)
+	}
+	return record
+}
+
+// parseDNSMessage decodes the header, the first question, and the answer section of a DNS message
+// https://datatracker.ietf.org/doc/html/rfc1035#section-4.1
+func parseDNSMessage(msg []byte) (dnsMessage, bool) {
+	if len(msg) < 12 {
+		return dnsMessage{}, false
+	}
+
+	flags := binary.BigEndian.Uint16(msg[2:4])
+	parsed := dnsMessage{
+		id:       binary.BigEndian.Uint16(msg[0:2]),
+		response: flags&0x8000 != 0,
+		aa:       flags&0x0400 != 0,
+		tc:       flags&0x0200 != 0,
+		rd:       flags&0x0100 != 0,
+		ra:       flags&0x0080 != 0,
+		z:        int64(flags>>4) & 0x7,
+		rcode:    int64(flags & 0x000f),
+	}
+
+	// only standard queries are recorded
+	if opcode := (flags >> 11) & 0xf; opcode != 0 {
+		return dnsMessage{}, false
+	}
+
+	qdCount := int(binary.BigEndian.Uint16(msg[4:6]))
+	anCount := int(binary.BigEndian.Uint16(msg[6:8]))
+
+	offset := 12
+	for i := 0; i < qdCount; i++ {
+		name, next, ok := readDNSName(msg, offset)
+		if !ok || next+4 > len(msg) {
+			return dnsMessage{}, false
+		}
+		if i == 0 {
+			parsed.hasQuery = true
+			parsed.query = name
+			parsed.qtype = int64(binary.BigEndian.Uint16(msg[next : next+2]))
+			parsed.qclass = int64(binary.BigEndian.Uint16(msg[next+2 : next+4]))
+		}
+		offset = next + 4
+	}
+
+	for i := 0; i < anCount; i++ {
+		_, next, ok := readDNSName(msg, offset)
+		if !ok || next+10 > len(msg) {
+			break
+		}
+		rrType := binary.BigEndian.Uint16(msg[next : next+2])
+		ttl := binary.BigEndian.Uint32(msg[next+4 : next+8])
+		rdLen := int(binary.BigEndian.Uint16(msg[next+8 : next+10]))
+		rdStart := next + 10
+		if rdStart+rdLen > len(msg) {
+			break
+		}
+
+		if answer, ok := formatDNSAnswer(msg, rrType, rdStart, rdLen); ok {
+			parsed.answers = append(parsed.answers, answer)
+			parsed.ttls = append(parsed.ttls, float64(ttl))
+		}
+		offset = rdStart + rdLen
+	}
+
+	return parsed, true
+}
+
+// readDNSName reads a possibly compressed domain name starting at offset. Returns the name
+// and the offset immediately following the name in the message.
+func readDNSName(msg []byte, offset int) (string, int, bool) {
+	var labels []string
+	next := -1
+	// bound the number of compression pointers followed to avoid loops
+	for jumps := 0; jumps < 64; {
+		if offset >= len(msg) {
+			return "", 0, false
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if next == -1 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, true
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(msg) {
+				return "", 0, false
+			}
+			if next == -1 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:offset+2]) & 0x3fff)
+			jumps++
+		case length&0xc0 != 0:
+			return "", 0, false
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, false
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+	return "", 0, false
+}
+
+// formatDNSAnswer renders resource record data in the same manner as Zeek's dns log
+func formatDNSAnswer(msg []byte, rrType uint16, start, length int) (string, bool) {
+	rdata := msg[start : start+length]
+	switch rrType {
+	case 1: // A
+		if length != 4 {
+			return "", false
+		}
+		return net.IP(rdata).String(), true
+	case 28: // AAAA
+		if length != 16 {
+			return "", false
+		}
+		return net.IP(rdata).String(), true
+	case 2, 5, 12: // NS, CNAME, PTR
+		name, _, ok := readDNSName(msg, start)
+		return name, ok
+	case 15: // MX
+		if length < 3 {
+			return "", false
+		}
+		name, _, ok := readDNSName(msg, start+2)
+		return name, ok
+	case 16: // TXT
+		var texts []string
+		for i := 0; i < len(rdata); {
+			textLen := int(rdata[i])
+			if i+1+textLen > len(rdata) {
+				break
+			}
+			texts = append(texts, string(rdata[i+1:i+1+textLen]))
+			i += 1 + textLen
+		}
+		text := strings.Join(texts, " ")
+		return "TXT " + strconv.Itoa(len(text)) + " " + text, true
+	case 41: // OPT pseudo records are not answers
+		return "", false
+	}
+	return "<unknown type=" + strconv.Itoa(int(rrType)) + ">", true
+}
+
+var dnsTypeNames = map[int64]string{
+	1: "A", 2: "NS", 5: "CNAME", 6: "SOA", 10: "NULL", 12: "PTR", 13: "HINFO", 15: "MX",
+	16: "TXT", 17: "RP", 18: "AFSDB", 24: "SIG", 25: "KEY", 28: "AAAA", 29: "LOC", 33: "SRV",
+	35: "NAPTR", 39: "DNAME", 41: "OPT", 43: "DS", 46: "RRSIG", 47: "NSEC", 48: "DNSKEY",
+	50: "NSEC3", 51: "NSEC3PARAM", 52: "TLSA", 64: "SVCB", 65: "HTTPS", 99: "SPF",
+	249: "TKEY", 250: "TSIG", 251: "IXFR", 252: "AXFR", 255: "*", 256: "URI", 257: "CAA",
+}
+
+func dnsTypeName(qtype int64) string {
+	if name, ok := dnsTypeNames[qtype]; ok {
+		return name
+	}
+	return "query-" + strconv.FormatInt(qtype, 10)
+}
+
+func dnsClassName(qclass int64) string {
+	switch qclass {
+	case 1:
+		return "C_INTERNET"
+	case 3:
+		return "C_CHAOS"
+	case 4:
+		return "C_HESIOD"
+	case 254:
+		return "C_NONE"
+	case 255:
+		return "C_ANY"
+	}
+	return "qclass-" + strconv.FormatInt(qclass, 10)
+}
+
+var dnsRCodeNames = []string{
+	"NOERROR", "FORMERR", "SERVFAIL", "NXDOMAIN", "NOTIMP", "REFUSED",
+	"YXDOMAIN", "YXRRSET", "NXRRSET", "NOTAUTH", "NOTZONE",
+}
+
+func dnsRCodeName(rcode int64) string {
+	if rcode >= 0 && int(rcode) < len(dnsRCodeNames) {
+		return dnsRCodeNames[rcode]
+	}
+	return "rcode-" + strconv.FormatInt(rcode, 10)
+}
@@ -0,0 +1,256 @@
+package pcap
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/parser/parsetypes"
+)
+
+// maxHTTPHeaderBytes bounds the amount of data buffered while waiting for the end of a header
+const maxHTTPHeaderBytes = 16 * 1024
+
+var httpMethods = []string{
+	"GET", "POST", "HEAD", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE", "PATCH",
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK", "SEARCH",
+}
+
+// httpDirection tracks the parsing of one side of an HTTP connection
+type httpDirection struct {
+	buf []byte
+	// bodyRemaining is the number of body bytes to skip before the next header
+	bodyRemaining int64
+	// failed is set once the stream can no longer be followed
+	failed bool
+}
+
+// httpState pairs the HTTP requests and responses seen on a connection
+type httpState struct {
+	orig    httpDirection
+	resp    httpDirection
+	depth   int64
+	pending []*parsetypes.HTTP
+}
+
+// looksLikeHTTPRequest reports whether the payload begins with an HTTP request line
+func looksLikeHTTPRequest(payload []byte) bool {
+	space := bytes.IndexByte(payload, ' ')
+	if space <= 0 || space > 16 {
+		return false
+	}
+	method := string(payload[:space])
+	for _, candidate := range httpMethods {
+		if method == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// add parses the HTTP headers carried by a TCP payload. Returns the records for
+// any requests which have been answered.
+func (h *httpState) add(f *flow, payload []byte, fromOrig bool, gap bool, ts time.Time) []*parsetypes.HTTP {
+	direction := &h.orig
+	if !fromOrig {
+		direction = &h.resp
+	}
+
+	// missing data makes it impossible to find the start of the next message
+	if gap {
+		direction.failed = true
+	}
+	if direction.failed {
+		return nil
+	}
+
+	direction.buf = append(direction.buf, payload...)
+
+	var finished []*parsetypes.HTTP
+	for len(direction.buf) > 0 {
+		// skip over message bodies
+		if direction.bodyRemaining > 0 {
+			skip := direction.bodyRemaining
+			if skip > int64(len(direction.buf)) {
+				skip = int64(len(direction.buf))
+			}
+			direction.buf = direction.buf[skip:]
+			direction.bodyRemaining -= skip
+			continue
+		}
+
+		headerEnd := bytes.Index(direction.buf, []byte("\r\n\r\n"))
+		if headerEnd == -1 {
+			if len(direction.buf) > maxHTTPHeaderBytes {
+				direction.failed = true
+				direction.buf = nil
+			}
+			break
+		}
+
+		header := string(direction.buf[:headerEnd])
+		direction.buf = direction.buf[headerEnd+4:]
+
+		var ok bool
+		if fromOrig {
+			ok = h.addRequest(f, header, ts)
+		} else {
+			var record *parsetypes.HTTP
+			record, ok = h.addResponse(header)
+			if record != nil {
+				finished = append(finished, record)
+			}
+		}
+		if !ok {
+			direction.failed = true
+			direction.buf = nil
+			break
+		}
+	}
+
+	// release the buffer once it has been fully consumed
+	if len(direction.buf) == 0 {
+		direction.buf = nil
+	}
+	return finished
+}
+
+// addRequest parses a request header and queues the request until its response is seen
+func (h *httpState) addRequest(f *flow, header string, ts time.Time) bool {
+	lines := strings.Split(header, "\r\n")
+	requestLine := strings.SplitN(lines[0], " ", 3)
+	if len(requestLine) != 3 || !strings.HasPrefix(requestLine[2], "HTTP/") {
+		return false
+	}
+
+	h.depth++
+	record := &parsetypes.HTTP{
+		TimeStamp:       ts.Unix(),
+		UID:             f.uid,
+		Source:          f.origIP,
+		SourcePort:      f.origPort,
+		Destination:     f.respIP,
+		DestinationPort: f.respPort,
+		TransDepth:      h.depth,
+		Method:          requestLine[0],
+		URI:             requestLine[1],
+		Version:         strings.TrimPrefix(requestLine[2], "HTTP/"),
+	}
+
+	fields := parseHTTPHeaderFields(lines[1:])
+	record.Host = fields["host"]
+	record.UserAgent = fields["user-agent"]
+	record.Referrer = fields["referer"]
+
+	bodyLen, ok := httpBodyLength(fields)
+	if !ok {
+		return false
+	}
+	record.ReqLen = bodyLen
+	h.orig.bodyRemaining = bodyLen
+
+	h.pending = append(h.pending, record)
+	return true
+}
+
+// addResponse parses a response header and pairs it with the oldest unanswered request
+func (h *httpState) addResponse(header string) (*parsetypes.HTTP, bool) {
+	lines := strings.Split(header, "\r\n")
+	statusLine := strings.SplitN(lines[0], " ", 3)
+	if len(statusLine) < 2 || !strings.HasPrefix(statusLine[0], "HTTP/") {
+		return nil, false
+	}
+	statusCode, err := strconv.ParseInt(statusLine[1], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	statusMsg := ""
+	if len(statusLine) == 3 {
+		statusMsg = statusLine[2]
+	}
+
+	// informational responses precede the final response
+	if statusCode >= 100 && statusCode < 200 {
+		if len(h.pending) > 0 {
+			h.pending[0].InfoCode = statusCode
+			h.pending[0].InfoMsg = statusMsg
+		}
+		return nil, true
+	}
+
+	var record *parsetypes.HTTP
+	if len(h.pending) > 0 {
+		record = h.pending[0]
+		h.pending = h.pending[1:]
+	}
+
+	fields := parseHTTPHeaderFields(lines[1:])
+
+	// responses to HEAD requests along with 204 and 304 responses never have a body
+	noBody := statusCode == 204 || statusCode == 304 || (record != nil && record.Method == "HEAD")
+
+	// a successful CONNECT turns the connection into a tunnel
+	tunnel := record != nil && record.Method == "CONNECT" && statusCode >= 200 && statusCode < 300
+
+	bodyLen := int64(0)
+	ok := true
+	if !noBody && !tunnel {
+		bodyLen, ok = httpBodyLength(fields)
+	}
+
+	if record != nil {
+		record.StatusCode = statusCode
+		record.StatusMsg = statusMsg
+		record.RespLen = bodyLen
+	}
+
+	if tunnel {
+		h.orig.failed = true
+		h.resp.failed = true
+		return record, true
+	}
+
+	h.resp.bodyRemaining = bodyLen
+	return record, ok
+}
+
+// flush returns the requests which never received a response
+func (h *httpState) flush() []*parsetypes.HTTP {
+	remaining := h.pending
+	h.pending = nil
+	return remaining
+}
+
+// parseHTTPHeaderFields maps lower cased header names to their values
+func parseHTTPHeaderFields(lines []string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range lines {
+		colon := strings.IndexByte(line, ':')
+		if colon <= 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(line[:colon]))
+		if _, exists := fields[name]; !exists {
+			fields[name] = strings.TrimSpace(line[colon+1:])
+		}
+	}
+	return fields
+}
+
+// httpBodyLength returns the length of the message body. Returns false if the body
+// length cannot be determined from the headers, such as with chunked encoding.
+func httpBodyLength(fields map[string]string) (int64, bool) {
+	if encoding, ok := fields["transfer-encoding"]; ok && !strings.EqualFold(encoding, "identity") {
+		return 0, false
+	}
+	value, ok := fields["content-length"]
+	if !ok {
+		return 0, true
+	}
+	length, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || length < 0 {
+		return 0, false
+	}
+	return length, true
+}
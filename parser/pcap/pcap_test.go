@@ -0,0 +1,320 @@
+package pcap
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/activecm/rita/parser/parsetypes"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testStart = time.Unix(1600000000, 0).UTC()
+
+type testPacket struct {
+	ts   time.Time
+	data []byte
+}
+
+// ethernetIPv4 wraps a transport layer segment in IPv4 and Ethernet headers
+func ethernetIPv4(src, dst string, proto uint8, transport []byte) []byte {
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(transport)))
+	ip[8] = 64
+	ip[9] = proto
+	copy(ip[12:16], net.ParseIP(src).To4())
+	copy(ip[16:20], net.ParseIP(dst).To4())
+
+	frame := make([]byte, 14)
+	binary.BigEndian.PutUint16(frame[12:14], etherTypeIPv4)
+	frame = append(frame, ip...)
+	return append(frame, transport...)
+}
+
+func udpSegment(srcPort, dstPort uint16, payload []byte) []byte {
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[0:2], srcPort)
+	binary.BigEndian.PutUint16(udp[2:4], dstPort)
+	binary.BigEndian.PutUint16(udp[4:6], uint16(8+len(payload)))
+	return append(udp, payload...)
+}
+
+func tcpSegment(srcPort, dstPort uint16, seq uint32, flags uint8, payload []byte) []byte {
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp[0:2], srcPort)
+	binary.BigEndian.PutUint16(tcp[2:4], dstPort)
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	return append(tcp, payload...)
+}
+
+// writePcap serializes the packets as a little endian, microsecond resolution pcap file
+func writePcap(packets []testPacket) []byte {
+	var buf bytes.Buffer
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:4], pcapMagicMicros)
+	binary.LittleEndian.PutUint16(header[4:6], 2)
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], 65535)
+	binary.LittleEndian.PutUint32(header[20:24], linkTypeEthernet)
+	buf.Write(header)
+
+	for _, pkt := range packets {
+		record := make([]byte, 16)
+		binary.LittleEndian.PutUint32(record[0:4], uint32(pkt.ts.Unix()))
+		binary.LittleEndian.PutUint32(record[4:8], uint32(pkt.ts.Nanosecond()/1000))
+		binary.LittleEndian.PutUint32(record[8:12], uint32(len(pkt.data)))
+		binary.LittleEndian.PutUint32(record[12:16], uint32(len(pkt.data)))
+		buf.Write(record)
+		buf.Write(pkt.data)
+	}
+	return buf.Bytes()
+}
+
+// writePcapngBlock serializes a little endian pcapng block, padding the body to 32 bits
+func writePcapngBlock(buf *bytes.Buffer, blockType uint32, body []byte) {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	length := uint32(12 + len(body))
+	binary.Write(buf, binary.LittleEndian, blockType)
+	binary.Write(buf, binary.LittleEndian, length)
+	buf.Write(body)
+	binary.Write(buf, binary.LittleEndian, length)
+}
+
+// writePcapng serializes the packets as a pcapng file with nanosecond timestamps
+func writePcapng(packets []testPacket) []byte {
+	var buf bytes.Buffer
+
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:4], pcapngBOMagic)
+	binary.LittleEndian.PutUint16(shb[4:6], 1)
+	binary.LittleEndian.PutUint64(shb[8:16], 0xffffffffffffffff)
+	writePcapngBlock(&buf, pcapngSHBType, shb)
+
+	idb := make([]byte, 8)
+	binary.LittleEndian.PutUint16(idb[0:2], uint16(linkTypeEthernet))
+	// if_tsresol option set to nanoseconds followed by opt_endofopt
+	idb = append(idb, 9, 0, 1, 0, 9, 0, 0, 0, 0, 0, 0, 0)
+	writePcapngBlock(&buf, pcapngIDBType, idb)
+
+	for _, pkt := range packets {
+		ts := uint64(pkt.ts.UnixNano())
+		epb := make([]byte, 20)
+		binary.LittleEndian.PutUint32(epb[4:8], uint32(ts>>32))
+		binary.LittleEndian.PutUint32(epb[8:12], uint32(ts))
+		binary.LittleEndian.PutUint32(epb[12:16], uint32(len(pkt.data)))
+		binary.LittleEndian.PutUint32(epb[16:20], uint32(len(pkt.data)))
+		writePcapngBlock(&buf, pcapngEPBType, append(epb, pkt.data...))
+	}
+	return buf.Bytes()
+}
+
+// readTestCapture converts the capture and returns the emitted records
+func readTestCapture(t *testing.T, capture []byte) []parsetypes.BroData {
+	var records []parsetypes.BroData
+	err := ReadCapture(bytes.NewReader(capture), "test.pcap", func(entry parsetypes.BroData) {
+		records = append(records, entry)
+	}, log.New())
+	require.NoError(t, err)
+	return records
+}
+
+func dnsTestPackets() []testPacket {
+	query := []byte{
+		0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		0x00, 0x01, 0x00, 0x01,
+	}
+	response := []byte{
+		0x12, 0x34, 0x81, 0x80, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+		7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		0x00, 0x01, 0x00, 0x01,
+		0xc0, 0x0c, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x2c, 0x00, 0x04,
+		93, 184, 216, 34,
+	}
+	return []testPacket{
+		{testStart, ethernetIPv4("10.0.0.1", "8.8.8.8", protoUDP, udpSegment(40000, 53, query))},
+		{testStart.Add(20 * time.Millisecond), ethernetIPv4("8.8.8.8", "10.0.0.1", protoUDP, udpSegment(53, 40000, response))},
+	}
+}
+
+// tcpTestPackets builds a complete TCP session carrying the given client and server payloads
+func tcpTestPackets(dstPort uint16, request, response []byte) []testPacket {
+	const syn, ack, fin, psh = 0x02, 0x10, 0x01, 0x08
+	client, server := "10.0.0.1", "10.0.0.2"
+	clientSeq, serverSeq := uint32(1000), uint32(5000)
+	at := func(ms int) time.Time { return testStart.Add(time.Duration(ms) * time.Millisecond) }
+
+	toServer := func(seq uint32, flags uint8, payload []byte) []byte {
+		return ethernetIPv4(client, server, protoTCP, tcpSegment(50000, dstPort, seq, flags, payload))
+	}
+	toClient := func(seq uint32, flags uint8, payload []byte) []byte {
+		return ethernetIPv4(server, client, protoTCP, tcpSegment(dstPort, 50000, seq, flags, payload))
+	}
+
+	reqEnd := clientSeq + 1 + uint32(len(request))
+	respEnd := serverSeq + 1 + uint32(len(response))
+	return []testPacket{
+		{at(0), toServer(clientSeq, syn, nil)},
+		{at(1), toClient(serverSeq, syn|ack, nil)},
+		{at(2), toServer(clientSeq+1, ack, nil)},
+		{at(3), toServer(clientSeq+1, psh|ack, request)},
+		{at(4), toClient(serverSeq+1, psh|ack, response)},
+		{at(5), toServer(reqEnd, fin|ack, nil)},
+		{at(6), toClient(respEnd, fin|ack, nil)},
+		{at(7), toServer(reqEnd+1, ack, nil)},
+	}
+}
+
+// testClientHello builds a TLS 1.2 ClientHello record carrying GREASE values
+func testClientHello() []byte {
+	extension := func(extType uint16, data []byte) []byte {
+		ext := make([]byte, 4)
+		binary.BigEndian.PutUint16(ext[0:2], extType)
+		binary.BigEndian.PutUint16(ext[2:4], uint16(len(data)))
+		return append(ext, data...)
+	}
+
+	var extensions []byte
+	extensions = append(extensions, extension(0x0a0a, nil)...)
+	serverName := []byte{0x00, 0x0e, 0x00, 0x00, 0x0b}
+	serverName = append(serverName, "example.com"...)
+	extensions = append(extensions, extension(0, serverName)...)
+	extensions = append(extensions, extension(10, []byte{0x00, 0x06, 0x0a, 0x0a, 0x00, 0x1d, 0x00, 0x17})...)
+	extensions = append(extensions, extension(11, []byte{0x01, 0x00})...)
+
+	hello := []byte{0x03, 0x03}
+	hello = append(hello, make([]byte, 32)...)
+	hello = append(hello, 0x00)
+	hello = append(hello, 0x00, 0x06, 0x0a, 0x0a, 0x13, 0x01, 0xc0, 0x2f)
+	hello = append(hello, 0x01, 0x00)
+	hello = append(hello, byte(len(extensions)>>8), byte(len(extensions)))
+	hello = append(hello, extensions...)
+
+	handshake := []byte{tlsClientHello, 0, byte(len(hello) >> 8), byte(len(hello))}
+	handshake = append(handshake, hello...)
+
+	record := []byte{tlsRecordHandshake, 0x03, 0x01, byte(len(handshake) >> 8), byte(len(handshake))}
+	return append(record, handshake...)
+}
+
+func TestPcapReader(t *testing.T) {
+	packets := dnsTestPackets()
+	reader, err := NewPacketReader(bytes.NewReader(writePcap(packets)))
+	require.NoError(t, err)
+
+	for _, expected := range packets {
+		pkt, err := reader.Next()
+		require.NoError(t, err)
+		assert.Equal(t, linkTypeEthernet, pkt.LinkType)
+		assert.True(t, expected.ts.Equal(pkt.Timestamp))
+		assert.Equal(t, expected.data, pkt.Data)
+	}
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestPcapngReader(t *testing.T) {
+	packets := []testPacket{
+		{testStart.Add(123456789 * time.Nanosecond), dnsTestPackets()[0].data},
+	}
+	reader, err := NewPacketReader(bytes.NewReader(writePcapng(packets)))
+	require.NoError(t, err)
+
+	pkt, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, linkTypeEthernet, pkt.LinkType)
+	assert.True(t, packets[0].ts.Equal(pkt.Timestamp), "nanosecond timestamps should be preserved")
+	assert.Equal(t, packets[0].data, pkt.Data)
+
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestIsCapture(t *testing.T) {
+	assert.True(t, IsCapture(writePcap(nil)))
+	assert.True(t, IsCapture(writePcapng(nil)))
+	assert.False(t, IsCapture([]byte("#separator \\x09")))
+	assert.False(t, IsCapture([]byte{0xd4}))
+}
+
+func TestReadCaptureDNS(t *testing.T) {
+	records := readTestCapture(t, writePcap(dnsTestPackets()))
+	require.Len(t, records, 2)
+
+	dns, ok := records[0].(*parsetypes.DNS)
+	require.True(t, ok)
+	assert.Equal(t, "10.0.0.1", dns.Source)
+	assert.Equal(t, "8.8.8.8", dns.Destination)
+	assert.Equal(t, "udp", dns.Proto)
+	assert.Equal(t, "example.com", dns.Query)
+	assert.Equal(t, "A", dns.QTypeName)
+	assert.Equal(t, "NOERROR", dns.RCodeName)
+	assert.Equal(t, []string{"93.184.216.34"}, dns.Answers)
+	assert.Equal(t, []float64{300}, dns.TTLs)
+	assert.InDelta(t, 0.02, dns.RTT, 0.0001)
+
+	conn, ok := records[1].(*parsetypes.Conn)
+	require.True(t, ok)
+	assert.Equal(t, dns.UID, conn.UID)
+	assert.Equal(t, "dns", conn.Service)
+	assert.Equal(t, "SF", conn.ConnState)
+	assert.Equal(t, int64(1), conn.OrigPkts)
+	assert.Equal(t, int64(1), conn.RespPkts)
+}
+
+func TestReadCaptureHTTP(t *testing.T) {
+	request := []byte("GET /index.html HTTP/1.1\r\nHost: example.com\r\nUser-Agent: test-agent\r\n\r\n")
+	response := []byte("HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello")
+	records := readTestCapture(t, writePcap(tcpTestPackets(80, request, response)))
+	require.Len(t, records, 2)
+
+	http, ok := records[0].(*parsetypes.HTTP)
+	require.True(t, ok)
+	assert.Equal(t, "GET", http.Method)
+	assert.Equal(t, "example.com", http.Host)
+	assert.Equal(t, "/index.html", http.URI)
+	assert.Equal(t, "test-agent", http.UserAgent)
+	assert.Equal(t, int64(200), http.StatusCode)
+	assert.Equal(t, int64(5), http.RespLen)
+	assert.Equal(t, 80, http.DestinationPort)
+
+	conn, ok := records[1].(*parsetypes.Conn)
+	require.True(t, ok)
+	assert.Equal(t, http.UID, conn.UID)
+	assert.Equal(t, "tcp", conn.Proto)
+	assert.Equal(t, "http", conn.Service)
+	assert.Equal(t, "SF", conn.ConnState)
+	assert.Equal(t, int64(len(request)), conn.OrigBytes)
+	assert.Equal(t, int64(len(response)), conn.RespBytes)
+}
+
+func TestReadCaptureTLS(t *testing.T) {
+	records := readTestCapture(t, writePcapng(tcpTestPackets(443, testClientHello(), nil)))
+	require.Len(t, records, 2)
+
+	ssl, ok := records[0].(*parsetypes.SSL)
+	require.True(t, ok)
+	assert.Equal(t, "example.com", ssl.ServerName)
+	assert.False(t, ssl.Established)
+
+	// GREASE values are left out of the fingerprint
+	sum := md5.Sum([]byte("771,4865-49199,0-10-11,29-23,0"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), ssl.JA3)
+
+	conn, ok := records[1].(*parsetypes.Conn)
+	require.True(t, ok)
+	assert.Equal(t, ssl.UID, conn.UID)
+	assert.Equal(t, "ssl", conn.Service)
+}
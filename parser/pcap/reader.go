@@ -0,0 +1,323 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Supported link layer header types
+// https://www.tcpdump.org/linktypes.html
+const (
+	linkTypeNull      uint32 = 0
+	linkTypeEthernet  uint32 = 1
+	linkTypeRaw       uint32 = 101
+	linkTypeLinuxSLL  uint32 = 113
+	linkTypeIPv4      uint32 = 228
+	linkTypeIPv6      uint32 = 229
+	linkTypeLinuxSLL2 uint32 = 276
+)
+
+// file format magic numbers
+const (
+	pcapMagicMicros uint32 = 0xa1b2c3d4
+	pcapMagicNanos  uint32 = 0xa1b23c4d
+	pcapngSHBType   uint32 = 0x0a0d0d0a
+	pcapngBOMagic   uint32 = 0x1a2b3c4d
+)
+
+// pcapng block types
+const (
+	pcapngIDBType uint32 = 0x00000001
+	pcapngOPBType uint32 = 0x00000002
+	pcapngSPBType uint32 = 0x00000003
+	pcapngEPBType uint32 = 0x00000006
+)
+
+// maxPacketSize guards against allocating huge buffers when reading corrupt captures
+const maxPacketSize = 256 * 1024
+
+// maxBlockSize guards against allocating huge buffers when reading corrupt pcapng blocks
+const maxBlockSize = 16 * 1024 * 1024
+
+var errNotCapture = errors.New("not a pcap or pcapng file")
+
+// Packet is a single frame read from a capture file
+type Packet struct {
+	Timestamp time.Time
+	LinkType  uint32
+	Data      []byte
+}
+
+// PacketReader reads the packets stored in a capture file one at a time.
+// Next returns io.EOF once all of the packets have been read.
+type PacketReader interface {
+	Next() (Packet, error)
+}
+
+// IsCapture reports whether the given magic bytes (the first four bytes of a file)
+// identify a pcap or pcapng file
+func IsCapture(magic []byte) bool {
+	if len(magic) < 4 {
+		return false
+	}
+	le := binary.LittleEndian.Uint32(magic)
+	be := binary.BigEndian.Uint32(magic)
+	return le == pcapMagicMicros || le == pcapMagicNanos || be == pcapMagicMicros ||
+		be == pcapMagicNanos || le == pcapngSHBType
+}
+
+// NewPacketReader detects whether the stream holds a pcap or pcapng capture
+// and returns a reader for its packets
+func NewPacketReader(r io.Reader) (PacketReader, error) {
+	buffered := bufio.NewReaderSize(r, 1<<16)
+	magic, err := buffered.Peek(4)
+	if err != nil {
+		return nil, errNotCapture
+	}
+
+	if binary.LittleEndian.Uint32(magic) == pcapngSHBType {
+		return newPcapngReader(buffered)
+	}
+	if IsCapture(magic) {
+		return newPcapReader(buffered)
+	}
+	return nil, errNotCapture
+}
+
+// pcapReader reads the classic libpcap file format
+// https://wiki.wireshark.org/Development/LibpcapFileFormat
+type pcapReader struct {
+	r         io.Reader
+	order     binary.ByteOrder
+	nanos     bool
+	linkType  uint32
+	recHeader [16]byte
+}
+
+func newPcapReader(r io.Reader) (*pcapReader, error) {
+	var header [24]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("could not read pcap header: %v", err)
+	}
+
+	reader := &pcapReader{r: r}
+	switch {
+	case binary.LittleEndian.Uint32(header[0:4]) == pcapMagicMicros:
+		reader.order = binary.LittleEndian
+	case binary.LittleEndian.Uint32(header[0:4]) == pcapMagicNanos:
+		reader.order, reader.nanos = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(header[0:4]) == pcapMagicMicros:
+		reader.order = binary.BigEndian
+	case binary.BigEndian.Uint32(header[0:4]) == pcapMagicNanos:
+		reader.order, reader.nanos = binary.BigEndian, true
+	default:
+		return nil, errNotCapture
+	}
+
+	// the upper 4 bits of the link type field may contain the FCS length
+	reader.linkType = reader.order.Uint32(header[20:24]) & 0x0fffffff
+	return reader, nil
+}
+
+func (p *pcapReader) Next() (Packet, error) {
+	if _, err := io.ReadFull(p.r, p.recHeader[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Packet{}, io.EOF
+		}
+		return Packet{}, err
+	}
+
+	secs := int64(p.order.Uint32(p.recHeader[0:4]))
+	frac := int64(p.order.Uint32(p.recHeader[4:8]))
+	capLen := p.order.Uint32(p.recHeader[8:12])
+
+	if capLen > maxPacketSize {
+		return Packet{}, fmt.Errorf("invalid pcap record length %d", capLen)
+	}
+
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		// a truncated final record is common when a capture is cut short
+		return Packet{}, io.EOF
+	}
+
+	if !p.nanos {
+		frac *= int64(time.Microsecond)
+	}
+
+	return Packet{
+		Timestamp: time.Unix(secs, frac).UTC(),
+		LinkType:  p.linkType,
+		Data:      data,
+	}, nil
+}
+
+// pcapngInterface holds the settings from an Interface Description Block
+type pcapngInterface struct {
+	linkType uint32
+	// tsUnitsPerSec is the number of timestamp units per second
+	tsUnitsPerSec uint64
+}
+
+// pcapngReader reads the pcapng file format
+// https://www.ietf.org/archive/id/draft-tuexen-opsawg-pcapng-05.html
+type pcapngReader struct {
+	r          io.Reader
+	order      binary.ByteOrder
+	interfaces []pcapngInterface
+}
+
+func newPcapngReader(r io.Reader) (*pcapngReader, error) {
+	reader := &pcapngReader{r: r, order: binary.LittleEndian}
+	return reader, nil
+}
+
+func (p *pcapngReader) Next() (Packet, error) {
+	for {
+		var blockHeader [8]byte
+		if _, err := io.ReadFull(p.r, blockHeader[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return Packet{}, io.EOF
+			}
+			return Packet{}, err
+		}
+
+		blockType := p.order.Uint32(blockHeader[0:4])
+
+		// a section header block may switch the byte order of the remainder of the file
+		if blockType == pcapngSHBType {
+			var bom [4]byte
+			if _, err := io.ReadFull(p.r, bom[:]); err != nil {
+				return Packet{}, io.EOF
+			}
+			if binary.LittleEndian.Uint32(bom[:]) == pcapngBOMagic {
+				p.order = binary.LittleEndian
+			} else if binary.BigEndian.Uint32(bom[:]) == pcapngBOMagic {
+				p.order = binary.BigEndian
+			} else {
+				return Packet{}, errors.New("invalid pcapng byte order magic")
+			}
+			blockLen := p.order.Uint32(blockHeader[4:8])
+			if blockLen < 16 || blockLen > maxBlockSize {
+				return Packet{}, fmt.Errorf("invalid pcapng section header length %d", blockLen)
+			}
+			// interface ids are scoped to a section
+			p.interfaces = p.interfaces[:0]
+			if _, err := io.CopyN(io.Discard, p.r, int64(blockLen-12)); err != nil {
+				return Packet{}, io.EOF
+			}
+			continue
+		}
+
+		blockLen := p.order.Uint32(blockHeader[4:8])
+		if blockLen < 12 || blockLen > maxBlockSize || blockLen%4 != 0 {
+			return Packet{}, fmt.Errorf("invalid pcapng block length %d", blockLen)
+		}
+
+		// read the block body along with the trailing length field
+		body := make([]byte, blockLen-8)
+		if _, err := io.ReadFull(p.r, body); err != nil {
+			return Packet{}, io.EOF
+		}
+		body = body[:len(body)-4]
+
+		switch blockType {
+		case pcapngIDBType:
+			p.readInterface(body)
+		case pcapngEPBType:
+			if pkt, ok := p.readEnhancedPacket(body); ok {
+				return pkt, nil
+			}
+		case pcapngOPBType:
+			if pkt, ok := p.readObsoletePacket(body); ok {
+				return pkt, nil
+			}
+		case pcapngSPBType:
+			// simple packet blocks do not carry a timestamp and cannot be
+			// placed in time, so they are skipped
+		}
+	}
+}
+
+func (p *pcapngReader) readInterface(body []byte) {
+	if len(body) < 8 {
+		return
+	}
+	iface := pcapngInterface{
+		linkType:      uint32(p.order.Uint16(body[0:2])),
+		tsUnitsPerSec: 1000000,
+	}
+
+	// walk the options looking for if_tsresol
+	options := body[8:]
+	for len(options) >= 4 {
+		code := p.order.Uint16(options[0:2])
+		length := int(p.order.Uint16(options[2:4]))
+		if code == 0 || 4+length > len(options) {
+			break
+		}
+		if code == 9 && length >= 1 {
+			resolution := options[4]
+			if resolution&0x80 == 0 && resolution <= 19 {
+				iface.tsUnitsPerSec = uint64(math.Pow10(int(resolution)))
+			} else if resolution&0x80 != 0 && resolution&0x7f < 64 {
+				iface.tsUnitsPerSec = uint64(1) << (resolution & 0x7f)
+			}
+		}
+		// options are padded to 32 bits
+		options = options[4+(length+3)&^3:]
+	}
+
+	p.interfaces = append(p.interfaces, iface)
+}
+
+func (p *pcapngReader) readEnhancedPacket(body []byte) (Packet, bool) {
+	if len(body) < 20 {
+		return Packet{}, false
+	}
+	ifaceID := p.order.Uint32(body[0:4])
+	tsHigh := uint64(p.order.Uint32(body[4:8]))
+	tsLow := uint64(p.order.Uint32(body[8:12]))
+	capLen := int(p.order.Uint32(body[12:16]))
+	return p.newPacket(ifaceID, tsHigh<<32|tsLow, body[20:], capLen)
+}
+
+func (p *pcapngReader) readObsoletePacket(body []byte) (Packet, bool) {
+	if len(body) < 20 {
+		return Packet{}, false
+	}
+	ifaceID := uint32(p.order.Uint16(body[0:2]))
+	tsHigh := uint64(p.order.Uint32(body[4:8]))
+	tsLow := uint64(p.order.Uint32(body[8:12]))
+	capLen := int(p.order.Uint32(body[12:16]))
+	return p.newPacket(ifaceID, tsHigh<<32|tsLow, body[20:], capLen)
+}
+
+func (p *pcapngReader) newPacket(ifaceID uint32, ts uint64, data []byte, capLen int) (Packet, bool) {
+	if int(ifaceID) >= len(p.interfaces) || capLen > len(data) {
+		return Packet{}, false
+	}
+	iface := p.interfaces[ifaceID]
+
+	secs := ts / iface.tsUnitsPerSec
+	remainder := ts % iface.tsUnitsPerSec
+
+	// avoid overflowing when scaling sub-nanosecond resolutions
+	var nanos uint64
+	if iface.tsUnitsPerSec > uint64(time.Second) {
+		nanos = remainder / (iface.tsUnitsPerSec / uint64(time.Second))
+	} else {
+		nanos = remainder * uint64(time.Second) / iface.tsUnitsPerSec
+	}
+
+	return Packet{
+		Timestamp: time.Unix(int64(secs), int64(nanos)).UTC(),
+		LinkType:  iface.linkType,
+		Data:      data[:capLen],
+	}, true
+}
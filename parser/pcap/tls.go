@@ -0,0 +1,460 @@
+package pcap
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/parser/parsetypes"
+)
+
+// TLS record content types
+const (
+	tlsRecordChangeCipherSpec uint8 = 20
+	tlsRecordAlert            uint8 = 21
+	tlsRecordHandshake        uint8 = 22
+	tlsRecordApplicationData  uint8 = 23
+)
+
+// TLS handshake message types
+const (
+	tlsClientHello       uint8 = 1
+	tlsServerHello       uint8 = 2
+	tlsCertificate       uint8 = 11
+	tlsServerKeyExchange uint8 = 12
+	tlsClientKeyExchange uint8 = 16
+)
+
+// TLS extension types
+const (
+	tlsExtServerName        uint16 = 0
+	tlsExtSupportedGroups   uint16 = 10
+	tlsExtECPointFormats    uint16 = 11
+	tlsExtALPN              uint16 = 16
+	tlsExtPreSharedKey      uint16 = 41
+	tlsExtSupportedVersions uint16 = 43
+	tlsExtKeyShare          uint16 = 51
+)
+
+// tlsMaxRecordLength is the largest record payload allowed, including the expansion from encryption
+const tlsMaxRecordLength = 16384 + 2048
+
+// tlsDirection tracks the parsing of one side of a TLS connection
+type tlsDirection struct {
+	records   []byte
+	handshake []byte
+	// encrypted is set once a ChangeCipherSpec or application data record is seen
+	encrypted bool
+	failed    bool
+}
+
+// tlsState collects the details of a TLS handshake needed for the ssl log
+type tlsState struct {
+	orig tlsDirection
+	resp tlsDirection
+
+	clientHelloSeen bool
+	serverHelloSeen bool
+	ts              time.Time
+
+	serverName       string
+	sessionID        []byte
+	ja3              string
+	ja3s             string
+	version          uint16
+	cipher           uint16
+	curve            string
+	nextProtocol     string
+	resumed          bool
+	keyExchangeSeen  bool
+	subject          string
+	issuer           string
+	validationStatus string
+}
+
+// looksLikeTLS reports whether the payload begins with a TLS handshake record
+func looksLikeTLS(payload []byte) bool {
+	return len(payload) >= 5 && payload[0] == tlsRecordHandshake && payload[1] == 3
+}
+
+// add parses the TLS records carried by a TCP payload
+func (t *tlsState) add(payload []byte, fromOrig bool, gap bool, ts time.Time) {
+	direction := &t.orig
+	if !fromOrig {
+		direction = &t.resp
+	}
+
+	// the record boundaries are lost if data is missing
+	if gap {
+		direction.failed = true
+	}
+	// nothing further is recorded once both sides have finished the handshake
+	if direction.failed || (t.orig.encrypted && t.resp.encrypted) {
+		direction.records = nil
+		return
+	}
+
+	direction.records = append(direction.records, payload...)
+
+	for len(direction.records) >= 5 {
+		contentType := direction.records[0]
+		length := int(binary.BigEndian.Uint16(direction.records[3:5]))
+		if direction.records[1] != 3 || length > tlsMaxRecordLength {
+			direction.failed = true
+			break
+		}
+		if len(direction.records) < 5+length {
+			break
+		}
+		fragment := direction.records[5 : 5+length]
+		direction.records = direction.records[5+length:]
+
+		switch contentType {
+		case tlsRecordChangeCipherSpec, tlsRecordApplicationData:
+			direction.encrypted = true
+		case tlsRecordHandshake:
+			// handshake messages are encrypted after the ChangeCipherSpec
+			if !direction.encrypted {
+				direction.handshake = append(direction.handshake, fragment...)
+				t.readHandshake(direction, fromOrig, ts)
+			}
+		case tlsRecordAlert:
+		default:
+			direction.failed = true
+		}
+		if direction.failed {
+			break
+		}
+	}
+
+	if direction.failed || len(direction.records) > maxHandshakeBufferBytes {
+		direction.failed = true
+		direction.records = nil
+		direction.handshake = nil
+	} else if len(direction.records) == 0 {
+		direction.records = nil
+	}
+}
+
+// readHandshake parses the complete handshake messages buffered for a direction
+func (t *tlsState) readHandshake(direction *tlsDirection, fromOrig bool, ts time.Time) {
+	for len(direction.handshake) >= 4 {
+		msgType := direction.handshake[0]
+		length := int(direction.handshake[1])<<16 | int(direction.handshake[2])<<8 | int(direction.handshake[3])
+		if length > maxHandshakeBufferBytes {
+			direction.failed = true
+			return
+		}
+		if len(direction.handshake) < 4+length {
+			return
+		}
+		body := direction.handshake[4 : 4+length]
+		direction.handshake = direction.handshake[4+length:]
+
+		switch {
+		case fromOrig && msgType == tlsClientHello && !t.clientHelloSeen:
+			t.readClientHello(body, ts)
+		case fromOrig && msgType == tlsClientKeyExchange:
+			t.keyExchangeSeen = true
+		case !fromOrig && msgType == tlsServerHello:
+			t.readServerHello(body)
+		case !fromOrig && msgType == tlsCertificate && t.subject == "":
+			t.readCertificate(body, ts)
+		case !fromOrig && msgType == tlsServerKeyExchange:
+			// ECDHE parameters begin with the curve type followed by the named curve
+			if len(body) >= 3 && body[0] == 3 {
+				t.curve = tlsCurveName(binary.BigEndian.Uint16(body[1:3]))
+			}
+		}
+	}
+	if len(direction.handshake) == 0 {
+		direction.handshake = nil
+	}
+}
+
+// readClientHello records the server name and JA3 fingerprint of the client
+// https://github.com/salesforce/ja3
+func (t *tlsState) readClientHello(body []byte, ts time.Time) {
+	r := tlsReader{data: body}
+	version := r.uint16()
+	r.skip(32)
+	t.sessionID = append([]byte(nil), r.vector8()...)
+	cipherData := r.vector16()
+	r.vector8()
+	if r.failed {
+		return
+	}
+
+	t.clientHelloSeen = true
+	t.ts = ts
+
+	var ciphers, extensions, groups, pointFormats []string
+	for i := 0; i+1 < len(cipherData); i += 2 {
+		if cipher := binary.BigEndian.Uint16(cipherData[i:]); !isGREASE(cipher) {
+			ciphers = append(ciphers, strconv.Itoa(int(cipher)))
+		}
+	}
+
+	extData := tlsReader{data: r.vector16()}
+	for !extData.failed && len(extData.data) >= 4 {
+		extType := extData.uint16()
+		ext := tlsReader{data: extData.vector16()}
+		if extData.failed {
+			break
+		}
+		if isGREASE(extType) {
+			continue
+		}
+		extensions = append(extensions, strconv.Itoa(int(extType)))
+
+		switch extType {
+		case tlsExtServerName:
+			names := tlsReader{data: ext.vector16()}
+			for !names.failed && len(names.data) > 0 {
+				nameType := names.uint8()
+				name := names.vector16()
+				if !names.failed && nameType == 0 {
+					t.serverName = string(name)
+					break
+				}
+			}
+		case tlsExtSupportedGroups:
+			list := ext.vector16()
+			for i := 0; i+1 < len(list); i += 2 {
+				if group := binary.BigEndian.Uint16(list[i:]); !isGREASE(group) {
+					groups = append(groups, strconv.Itoa(int(group)))
+				}
+			}
+		case tlsExtECPointFormats:
+			for _, format := range ext.vector8() {
+				pointFormats = append(pointFormats, strconv.Itoa(int(format)))
+			}
+		}
+	}
+
+	t.ja3 = tlsFingerprint(
+		strconv.Itoa(int(version)),
+		strings.Join(ciphers, "-"),
+		strings.Join(extensions, "-"),
+		strings.Join(groups, "-"),
+		strings.Join(pointFormats, "-"),
+	)
+}
+
+// readServerHello records the negotiated parameters and JA3S fingerprint of the server
+// https://github.com/salesforce/ja3
+func (t *tlsState) readServerHello(body []byte) {
+	r := tlsReader{data: body}
+	version := r.uint16()
+	r.skip(32)
+	sessionID := r.vector8()
+	cipher := r.uint16()
+	r.uint8()
+	if r.failed {
+		return
+	}
+
+	t.serverHelloSeen = true
+	t.version = version
+	t.cipher = cipher
+	t.resumed = len(sessionID) > 0 && bytes.Equal(sessionID, t.sessionID)
+
+	var extensions []string
+	extData := tlsReader{data: r.vector16()}
+	for !extData.failed && len(extData.data) >= 4 {
+		extType := extData.uint16()
+		ext := tlsReader{data: extData.vector16()}
+		if extData.failed {
+			break
+		}
+		extensions = append(extensions, strconv.Itoa(int(extType)))
+
+		switch extType {
+		case tlsExtSupportedVersions:
+			// TLS 1.3 reports the negotiated version in an extension
+			if selected := ext.uint16(); !ext.failed {
+				t.version = selected
+			}
+		case tlsExtPreSharedKey:
+			t.resumed = true
+		case tlsExtKeyShare:
+			if group := ext.uint16(); !ext.failed {
+				t.curve = tlsCurveName(group)
+			}
+		case tlsExtALPN:
+			protocols := tlsReader{data: ext.vector16()}
+			if protocol := protocols.vector8(); !protocols.failed {
+				t.nextProtocol = string(protocol)
+			}
+		}
+	}
+
+	t.ja3s = tlsFingerprint(
+		strconv.Itoa(int(version)),
+		strconv.Itoa(int(cipher)),
+		strings.Join(extensions, "-"),
+	)
+}
+
+// readCertificate records the subject and issuer of the server's certificate. Only
+// problems which can be detected without a trust store are reported in the validation status.
+func (t *tlsState) readCertificate(body []byte, ts time.Time) {
+	r := tlsReader{data: body}
+	certs := tlsReader{data: r.vector24()}
+	der := certs.vector24()
+	if r.failed || certs.failed {
+		return
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return
+	}
+
+	t.subject = cert.Subject.String()
+	t.issuer = cert.Issuer.String()
+
+	switch {
+	case ts.After(cert.NotAfter):
+		t.validationStatus = "certificate has expired"
+	case ts.Before(cert.NotBefore):
+		t.validationStatus = "certificate is not yet valid"
+	case bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil:
+		t.validationStatus = "self signed certificate"
+	}
+}
+
+// record returns the ssl record for the connection or nil if no ClientHello was seen
+func (t *tlsState) record(f *flow) *parsetypes.SSL {
+	if !t.clientHelloSeen {
+		return nil
+	}
+
+	record := &parsetypes.SSL{
+		TimeStamp:             t.ts.Unix(),
+		UID:                   f.uid,
+		Source:                f.origIP,
+		SourcePort:            f.origPort,
+		Destination:           f.respIP,
+		DestinationPort:       f.respPort,
+		ServerName:            t.serverName,
+		ClientKeyExchangeSeen: t.keyExchangeSeen,
+		JA3:                   t.ja3,
+	}
+
+	if t.serverHelloSeen {
+		record.VersionNum = int(t.version)
+		record.Version = tlsVersionName(t.version)
+		record.Cipher = tls.CipherSuiteName(t.cipher)
+		record.Curve = t.curve
+		record.NextProtocol = t.nextProtocol
+		record.Resumed = t.resumed
+		record.Established = t.orig.encrypted && t.resp.encrypted
+		record.Subject = t.subject
+		record.Issuer = t.issuer
+		record.ValidationStatus = t.validationStatus
+		record.JA3S = t.ja3s
+	}
+	return record
+}
+
+// tlsFingerprint joins the fingerprint fields and returns their md5 hash
+func tlsFingerprint(fields ...string) string {
+	sum := md5.Sum([]byte(strings.Join(fields, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// isGREASE reports whether the value is reserved by RFC 8701. GREASE values
+// are ignored when computing JA3 fingerprints.
+func isGREASE(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case 0x0300:
+		return "SSLv3"
+	case 0x0301:
+		return "TLSv10"
+	case 0x0302:
+		return "TLSv11"
+	case 0x0303:
+		return "TLSv12"
+	case 0x0304:
+		return "TLSv13"
+	}
+	return "unknown-" + strconv.Itoa(int(version))
+}
+
+func tlsCurveName(group uint16) string {
+	switch group {
+	case 23:
+		return "secp256r1"
+	case 24:
+		return "secp384r1"
+	case 25:
+		return "secp521r1"
+	case 29:
+		return "x25519"
+	case 30:
+		return "x448"
+	}
+	return "unknown-" + strconv.Itoa(int(group))
+}
+
+// tlsReader reads the length prefixed fields used throughout the TLS handshake.
+// Once a read runs past the end of the data, failed is set and all further reads return zero values.
+type tlsReader struct {
+	data   []byte
+	failed bool
+}
+
+func (r *tlsReader) take(n int) []byte {
+	if r.failed || n > len(r.data) {
+		r.failed = true
+		return nil
+	}
+	value := r.data[:n]
+	r.data = r.data[n:]
+	return value
+}
+
+func (r *tlsReader) skip(n int) {
+	r.take(n)
+}
+
+func (r *tlsReader) uint8() uint8 {
+	if value := r.take(1); value != nil {
+		return value[0]
+	}
+	return 0
+}
+
+func (r *tlsReader) uint16() uint16 {
+	if value := r.take(2); value != nil {
+		return binary.BigEndian.Uint16(value)
+	}
+	return 0
+}
+
+func (r *tlsReader) vector8() []byte {
+	return r.take(int(r.uint8()))
+}
+
+func (r *tlsReader) vector16() []byte {
+	return r.take(int(r.uint16()))
+}
+
+func (r *tlsReader) vector24() []byte {
+	length := r.take(3)
+	if length == nil {
+		return nil
+	}
+	return r.take(int(length[0])<<16 | int(length[1])<<8 | int(length[2]))
+}
@@ -5,9 +5,14 @@ import (
 
 	"github.com/activecm/rita/pkg/certificate"
 	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/dnstunnel"
+	"github.com/activecm/rita/pkg/filetransfer"
 	"github.com/activecm/rita/pkg/host"
 	"github.com/activecm/rita/pkg/hostname"
+	"github.com/activecm/rita/pkg/lease"
+	"github.com/activecm/rita/pkg/logon"
 	"github.com/activecm/rita/pkg/sniconn"
+	"github.com/activecm/rita/pkg/sshconn"
 	"github.com/activecm/rita/pkg/uconn"
 	"github.com/activecm/rita/pkg/uconnproxy"
 	"github.com/activecm/rita/pkg/useragent"
@@ -29,14 +34,33 @@ type ParseResults struct {
 	UseragentLock       *sync.Mutex
 	CertificateMap      map[string]*certificate.Input
 	CertificateLock     *sync.Mutex
+	X509Map             map[string]*certificate.X509
+	X509Lock            *sync.Mutex
 	ExplodedDNSMap      map[string]int
 	ExplodedDNSLock     *sync.Mutex
+	DNSTunnelMap        map[string]*dnstunnel.Input
+	DNSTunnelLock       *sync.Mutex
 	TLSConnMap          map[string]*sniconn.TLSInput
 	TLSConnLock         *sync.Mutex
 	HTTPConnMap         map[string]*sniconn.HTTPInput
 	HTTPConnLock        *sync.Mutex
 	ZeekUIDMap          map[string]*data.ZeekUIDRecord
 	ZeekUIDLock         *sync.Mutex
+	SSHConnMap          map[string]*sshconn.Input
+	SSHConnLock         *sync.Mutex
+	LeaseMap            map[string]*lease.Input
+	LeaseLock           *sync.Mutex
+	LogonMap            map[string]*logon.Input
+	LogonLock           *sync.Mutex
+	FileTransferMap     map[string]*filetransfer.Input
+	FileTransferLock    *sync.Mutex
+	FileUploadMap       map[string]*filetransfer.UploadInput
+	FileUploadLock      *sync.Mutex
+	FileSessionMap      map[string]*filetransfer.Session
+	FileSessionLock     *sync.Mutex
+	// Spill writes connection details out to disk once they take up too much memory.
+	// It is nil when every connection detail is kept in memory.
+	Spill *spiller
 }
 
 // newParseResults instantiates a ParseResults struct
@@ -54,13 +78,29 @@ func newParseResults() ParseResults {
 		UseragentLock:       new(sync.Mutex),
 		CertificateMap:      make(map[string]*certificate.Input),
 		CertificateLock:     new(sync.Mutex),
+		X509Map:             make(map[string]*certificate.X509),
+		X509Lock:            new(sync.Mutex),
 		ExplodedDNSMap:      make(map[string]int),
 		ExplodedDNSLock:     new(sync.Mutex),
+		DNSTunnelMap:        make(map[string]*dnstunnel.Input),
+		DNSTunnelLock:       new(sync.Mutex),
 		TLSConnMap:          make(map[string]*sniconn.TLSInput),
 		TLSConnLock:         new(sync.Mutex),
 		HTTPConnMap:         make(map[string]*sniconn.HTTPInput),
 		HTTPConnLock:        new(sync.Mutex),
 		ZeekUIDMap:          make(map[string]*data.ZeekUIDRecord),
 		ZeekUIDLock:         new(sync.Mutex),
+		SSHConnMap:          make(map[string]*sshconn.Input),
+		SSHConnLock:         new(sync.Mutex),
+		LeaseMap:            make(map[string]*lease.Input),
+		LeaseLock:           new(sync.Mutex),
+		LogonMap:            make(map[string]*logon.Input),
+		LogonLock:           new(sync.Mutex),
+		FileTransferMap:     make(map[string]*filetransfer.Input),
+		FileTransferLock:    new(sync.Mutex),
+		FileUploadMap:       make(map[string]*filetransfer.UploadInput),
+		FileUploadLock:      new(sync.Mutex),
+		FileSessionMap:      make(map[string]*filetransfer.Session),
+		FileSessionLock:     new(sync.Mutex),
 	}
 }
@@ -0,0 +1,589 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/sniconn"
+	"github.com/activecm/rita/pkg/uconn"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// connSpillBytes estimates the memory used by a parsed conn record: its timestamp
+	// and bytes in the unique connection along with its Zeek UID record
+	connSpillBytes = 128
+
+	// sniSpillBytes estimates the memory used by the timestamp and Zeek UID which a
+	// parsed ssl or http record adds to its SNI connection
+	sniSpillBytes = 64
+)
+
+// spiller keeps the memory used by the connection details gathered while parsing under
+// a limit. Once the limit is reached, the timestamps, byte counts, and Zeek UIDs gathered
+// so far are written to files on disk which are sorted by their map keys. The unique
+// connections and SNI connections stay in memory without these details so that
+// the parser still finds them, and the details are merged back in a part at a time when
+// the connections are analyzed.
+type spiller struct {
+	baseDir    string
+	dir        string
+	limit      int64
+	sniEnabled bool
+	log        *log.Logger
+
+	// pending estimates the memory used by the details gathered since the last spill
+	pending int64
+	lock    sync.Mutex
+	failed  bool
+
+	uconnRuns []string
+	tlsRuns   []string
+	httpRuns  []string
+	uidRuns   []string
+}
+
+// spillRecord is a single map entry written to disk
+type spillRecord struct {
+	Key   string
+	Uconn *uconn.Input
+	TLS   *sniconn.TLSInput
+	HTTP  *sniconn.HTTPInput
+	UID   *data.ZeekUIDRecord
+}
+
+// newSpiller creates a spiller which writes to a new directory inside of baseDir once the
+// details take up more than limitMB megabytes. The Zeek UID records are only written out
+// if they are needed by the SNI connection analysis.
+func newSpiller(baseDir string, limitMB int, sniEnabled bool, logger *log.Logger) *spiller {
+	return &spiller{
+		baseDir:    baseDir,
+		limit:      int64(limitMB) * (1 << 20),
+		sniEnabled: sniEnabled,
+		log:        logger,
+	}
+}
+
+// spillBytes estimates the memory which a parsed log entry adds to the connection maps
+func spillBytes(entry parsetypes.BroData) int64 {
+	switch entry.(type) {
+	case *parsetypes.Conn:
+		return connSpillBytes
+	case *parsetypes.SSL, *parsetypes.HTTP:
+		return sniSpillBytes
+	}
+	return 0
+}
+
+// add records the memory used by a parsed log entry and spills the connection
+// details to disk if the limit has been reached
+func (s *spiller) add(bytes int64, retVals ParseResults) {
+	if s == nil || bytes == 0 {
+		return
+	}
+	if atomic.AddInt64(&s.pending, bytes) < s.limit {
+		return
+	}
+	s.spill(retVals)
+}
+
+// hasRuns checks whether any connection details were written to disk
+func (s *spiller) hasRuns() bool {
+	return s != nil && len(s.uconnRuns) > 0
+}
+
+// remove deletes the files written to disk
+func (s *spiller) remove() {
+	if s == nil || s.dir == "" {
+		return
+	}
+	if err := os.RemoveAll(s.dir); err != nil {
+		s.log.WithFields(log.Fields{
+			"path":  s.dir,
+			"error": err.Error(),
+		}).Error("Could not remove the connection details spilled to disk")
+	}
+}
+
+// spill writes the connection details held in memory to disk and removes them from the maps
+func (s *spiller) spill(retVals ParseResults) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// another parsing thread may have spilled the details while this one was waiting
+	if s.failed || atomic.LoadInt64(&s.pending) < s.limit {
+		return
+	}
+
+	retVals.UniqueConnLock.Lock()
+	defer retVals.UniqueConnLock.Unlock()
+	retVals.TLSConnLock.Lock()
+	defer retVals.TLSConnLock.Unlock()
+	retVals.HTTPConnLock.Lock()
+	defer retVals.HTTPConnLock.Unlock()
+	retVals.ZeekUIDLock.Lock()
+	defer retVals.ZeekUIDLock.Unlock()
+
+	err := s.writeRuns(retVals)
+	if err != nil {
+		// keep the details in memory rather than losing them
+		s.failed = true
+		s.log.WithFields(log.Fields{
+			"path":  s.dir,
+			"error": err.Error(),
+		}).Error("Could not spill connection details to disk, keeping them in memory")
+		return
+	}
+
+	for _, input := range retVals.UniqueConnMap {
+		clearUconnDetails(input)
+	}
+	for _, input := range retVals.TLSConnMap {
+		clearTLSDetails(input)
+	}
+	for _, input := range retVals.HTTPConnMap {
+		clearHTTPDetails(input)
+	}
+	for uid := range retVals.ZeekUIDMap {
+		delete(retVals.ZeekUIDMap, uid)
+	}
+
+	atomic.StoreInt64(&s.pending, 0)
+}
+
+// writeRuns writes a sorted run of each connection map to disk
+func (s *spiller) writeRuns(retVals ParseResults) error {
+	if s.dir == "" {
+		dir, err := ioutil.TempDir(s.baseDir, "rita-spill-")
+		if err != nil {
+			return err
+		}
+		s.dir = dir
+	}
+
+	run := len(s.uconnRuns)
+	paths := make([]string, 4)
+	for i, name := range []string{"uconn", "tls", "http", "uid"} {
+		paths[i] = filepath.Join(s.dir, fmt.Sprintf("%s-%04d.gob", name, run))
+	}
+
+	var uconnKeys []string
+	for key, input := range retVals.UniqueConnMap {
+		if input.ConnectionCount > 0 {
+			uconnKeys = append(uconnKeys, key)
+		}
+	}
+	err := writeRun(paths[0], uconnKeys, func(key string) spillRecord {
+		// open connections are tracked in memory until they close
+		spilled := *retVals.UniqueConnMap[key]
+		spilled.ConnStateMap = nil
+		return spillRecord{Key: key, Uconn: &spilled}
+	})
+	if err != nil {
+		return err
+	}
+
+	var tlsKeys []string
+	for key, input := range retVals.TLSConnMap {
+		if input.ConnectionCount > 0 {
+			tlsKeys = append(tlsKeys, key)
+		}
+	}
+	err = writeRun(paths[1], tlsKeys, func(key string) spillRecord {
+		return spillRecord{Key: key, TLS: retVals.TLSConnMap[key]}
+	})
+	if err != nil {
+		return err
+	}
+
+	var httpKeys []string
+	for key, input := range retVals.HTTPConnMap {
+		if input.ConnectionCount > 0 {
+			httpKeys = append(httpKeys, key)
+		}
+	}
+	err = writeRun(paths[2], httpKeys, func(key string) spillRecord {
+		return spillRecord{Key: key, HTTP: retVals.HTTPConnMap[key]}
+	})
+	if err != nil {
+		return err
+	}
+
+	var uids []string
+	if s.sniEnabled {
+		for uid := range retVals.ZeekUIDMap {
+			uids = append(uids, uid)
+		}
+	}
+	err = writeRun(paths[3], uids, func(uid string) spillRecord {
+		return spillRecord{Key: uid, UID: retVals.ZeekUIDMap[uid]}
+	})
+	if err != nil {
+		return err
+	}
+
+	s.uconnRuns = append(s.uconnRuns, paths[0])
+	s.tlsRuns = append(s.tlsRuns, paths[1])
+	s.httpRuns = append(s.httpRuns, paths[2])
+	s.uidRuns = append(s.uidRuns, paths[3])
+	return nil
+}
+
+// writeRun writes the records for the given keys to a file in sorted order
+func writeRun(path string, keys []string, record func(key string) spillRecord) error {
+	sort.Strings(keys)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	encoder := gob.NewEncoder(writer)
+
+	for _, key := range keys {
+		rec := record(key)
+		if err := encoder.Encode(&rec); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// runReader reads the records of a run in order
+type runReader struct {
+	file    *os.File
+	decoder *gob.Decoder
+	next    *spillRecord
+}
+
+// openRuns opens the given runs and reads their first records
+func openRuns(paths []string) ([]*runReader, error) {
+	var readers []*runReader
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			closeRuns(readers)
+			return nil, err
+		}
+		reader := &runReader{file: file, decoder: gob.NewDecoder(bufio.NewReader(file))}
+		readers = append(readers, reader)
+		if err := reader.advance(); err != nil {
+			closeRuns(readers)
+			return nil, err
+		}
+	}
+	return readers, nil
+}
+
+// closeRuns closes the files of the given runs
+func closeRuns(readers []*runReader) {
+	for _, reader := range readers {
+		reader.file.Close()
+	}
+}
+
+// advance reads the next record of the run. next is nil once the run is finished.
+func (r *runReader) advance() error {
+	var record spillRecord
+	err := r.decoder.Decode(&record)
+	if err == io.EOF {
+		r.next = nil
+		return nil
+	}
+	if err != nil {
+		r.next = nil
+		return err
+	}
+	r.next = &record
+	return nil
+}
+
+// mergeKey merges the records of each run which belong to the given key. Since the
+// runs and the keys are sorted, the records of earlier keys have already been read.
+func mergeKey(readers []*runReader, key string, merge func(*spillRecord)) error {
+	for _, reader := range readers {
+		for reader.next != nil && reader.next.Key <= key {
+			if reader.next.Key == key {
+				merge(reader.next)
+			}
+			if err := reader.advance(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mergeUconns merges the spilled details back into the unique connections and passes
+// them to build a part at a time. The details are removed again once a part is built.
+func (s *spiller) mergeUconns(uconnMap map[string]*uconn.Input, build func(map[string]*uconn.Input)) error {
+	readers, err := openRuns(s.uconnRuns)
+	if err != nil {
+		return err
+	}
+	defer closeRuns(readers)
+
+	keys := make([]string, 0, len(uconnMap))
+	for key := range uconnMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	part := make(map[string]*uconn.Input)
+	var partBytes int64
+	for i, key := range keys {
+		input := uconnMap[key]
+		err := mergeKey(readers, key, func(record *spillRecord) {
+			mergeUconnDetails(input, record.Uconn)
+		})
+		if err != nil {
+			return err
+		}
+
+		part[key] = input
+		partBytes += int64(len(input.TsList)) * connSpillBytes
+
+		if partBytes >= s.limit || i == len(keys)-1 {
+			build(part)
+			for _, built := range part {
+				clearUconnDetails(built)
+			}
+			part = make(map[string]*uconn.Input)
+			partBytes = 0
+		}
+	}
+	return nil
+}
+
+// mergeSNIConns merges the spilled details back into the SNI connections and passes
+// them to build a part at a time along with the Zeek UID records they refer to.
+// The details are removed again once a part is built.
+func (s *spiller) mergeSNIConns(tlsMap map[string]*sniconn.TLSInput, httpMap map[string]*sniconn.HTTPInput,
+	zeekUIDMap map[string]*data.ZeekUIDRecord,
+	build func(map[string]*sniconn.TLSInput, map[string]*sniconn.HTTPInput, map[string]*data.ZeekUIDRecord)) error {
+
+	tlsReaders, err := openRuns(s.tlsRuns)
+	if err != nil {
+		return err
+	}
+	defer closeRuns(tlsReaders)
+
+	httpReaders, err := openRuns(s.httpRuns)
+	if err != nil {
+		return err
+	}
+	defer closeRuns(httpReaders)
+
+	keySet := make(data.StringSet, len(tlsMap)+len(httpMap))
+	for key := range tlsMap {
+		keySet.Insert(key)
+	}
+	for key := range httpMap {
+		keySet.Insert(key)
+	}
+	keys := keySet.Items()
+	sort.Strings(keys)
+
+	tlsPart := make(map[string]*sniconn.TLSInput)
+	httpPart := make(map[string]*sniconn.HTTPInput)
+	var partBytes int64
+	for i, key := range keys {
+		if input, ok := tlsMap[key]; ok {
+			err := mergeKey(tlsReaders, key, func(record *spillRecord) {
+				mergeTLSDetails(input, record.TLS)
+			})
+			if err != nil {
+				return err
+			}
+			tlsPart[key] = input
+			partBytes += int64(len(input.Timestamps)) * sniSpillBytes
+		}
+
+		if input, ok := httpMap[key]; ok {
+			err := mergeKey(httpReaders, key, func(record *spillRecord) {
+				mergeHTTPDetails(input, record.HTTP)
+			})
+			if err != nil {
+				return err
+			}
+			httpPart[key] = input
+			partBytes += int64(len(input.Timestamps)) * sniSpillBytes
+		}
+
+		if partBytes >= s.limit || i == len(keys)-1 {
+			uidPart, err := s.findZeekUIDs(tlsPart, httpPart, zeekUIDMap)
+			if err != nil {
+				return err
+			}
+			build(tlsPart, httpPart, uidPart)
+			for _, built := range tlsPart {
+				clearTLSDetails(built)
+			}
+			for _, built := range httpPart {
+				clearHTTPDetails(built)
+			}
+			tlsPart = make(map[string]*sniconn.TLSInput)
+			httpPart = make(map[string]*sniconn.HTTPInput)
+			partBytes = 0
+		}
+	}
+	return nil
+}
+
+// findZeekUIDs gathers the Zeek UID records which the given SNI connections refer to
+// from the runs on disk and the records still held in memory
+func (s *spiller) findZeekUIDs(tlsMap map[string]*sniconn.TLSInput, httpMap map[string]*sniconn.HTTPInput,
+	zeekUIDMap map[string]*data.ZeekUIDRecord) (map[string]*data.ZeekUIDRecord, error) {
+
+	needed := make(data.StringSet)
+	for _, input := range tlsMap {
+		for _, uid := range input.ZeekUIDs {
+			needed.Insert(uid)
+		}
+	}
+	for _, input := range httpMap {
+		for _, uid := range input.ZeekUIDs {
+			needed.Insert(uid)
+		}
+	}
+
+	found := make(map[string]*data.ZeekUIDRecord)
+	if len(needed) == 0 {
+		return found, nil
+	}
+
+	// later runs hold newer records, so they replace those of earlier runs
+	for _, path := range s.uidRuns {
+		readers, err := openRuns([]string{path})
+		if err != nil {
+			return nil, err
+		}
+		reader := readers[0]
+		for reader.next != nil {
+			if needed.Contains(reader.next.Key) {
+				found[reader.next.Key] = reader.next.UID
+			}
+			if err := reader.advance(); err != nil {
+				closeRuns(readers)
+				return nil, err
+			}
+		}
+		closeRuns(readers)
+	}
+
+	for uid := range needed {
+		if record, ok := zeekUIDMap[uid]; ok {
+			found[uid] = record
+		}
+	}
+	return found, nil
+}
+
+// mergeUconnDetails adds the details of a spilled unique connection to one held in memory.
+// The flags and open connections kept in memory are already up to date.
+func mergeUconnDetails(dst, src *uconn.Input) {
+	dst.ConnectionCount += src.ConnectionCount
+	dst.TotalBytes += src.TotalBytes
+	dst.TotalDuration += src.TotalDuration
+	if src.MaxDuration > dst.MaxDuration {
+		dst.MaxDuration = src.MaxDuration
+	}
+	dst.TsList = append(dst.TsList, src.TsList...)
+	dst.OrigBytesList = append(dst.OrigBytesList, src.OrigBytesList...)
+	for tuple := range src.Tuples {
+		dst.Tuples.Insert(tuple)
+	}
+	dst.InvalidCertFlag = dst.InvalidCertFlag || src.InvalidCertFlag
+	dst.UPPSFlag = dst.UPPSFlag || src.UPPSFlag
+}
+
+// mergeTLSDetails adds the details of a spilled TLS connection to one held in memory.
+// The certificate status kept in memory is the most recent one.
+func mergeTLSDetails(dst, src *sniconn.TLSInput) {
+	dst.ConnectionCount += src.ConnectionCount
+	dst.Timestamps = append(dst.Timestamps, src.Timestamps...)
+	for key, ip := range src.RespondingIPs {
+		dst.RespondingIPs[key] = ip
+	}
+	for port := range src.RespondingPorts {
+		dst.RespondingPorts.Insert(port)
+	}
+	for subject := range src.Subjects {
+		dst.Subjects.Insert(subject)
+	}
+	for ja3 := range src.JA3s {
+		dst.JA3s.Insert(ja3)
+	}
+	for ja3s := range src.JA3Ss {
+		dst.JA3Ss.Insert(ja3s)
+	}
+	dst.ZeekUIDs = append(dst.ZeekUIDs, src.ZeekUIDs...)
+}
+
+// mergeHTTPDetails adds the details of a spilled HTTP connection to one held in memory
+func mergeHTTPDetails(dst, src *sniconn.HTTPInput) {
+	dst.ConnectionCount += src.ConnectionCount
+	dst.Timestamps = append(dst.Timestamps, src.Timestamps...)
+	for key, ip := range src.RespondingIPs {
+		dst.RespondingIPs[key] = ip
+	}
+	for port := range src.RespondingPorts {
+		dst.RespondingPorts.Insert(port)
+	}
+	for method := range src.Methods {
+		dst.Methods.Insert(method)
+	}
+	for userAgent := range src.UserAgents {
+		dst.UserAgents.Insert(userAgent)
+	}
+	dst.ZeekUIDs = append(dst.ZeekUIDs, src.ZeekUIDs...)
+}
+
+// clearUconnDetails removes the per connection details of a unique connection.
+// The flags, tuples, and open connections are kept for the parser.
+func clearUconnDetails(input *uconn.Input) {
+	input.ConnectionCount = 0
+	input.TotalBytes = 0
+	input.TotalDuration = 0
+	input.TsList = nil
+	input.OrigBytesList = nil
+}
+
+// clearTLSDetails removes the per connection details of a TLS connection
+func clearTLSDetails(input *sniconn.TLSInput) {
+	input.ConnectionCount = 0
+	input.Timestamps = []int64{}
+	input.RespondingIPs = make(data.UniqueIPSet)
+	input.RespondingPorts = make(data.IntSet)
+	input.Subjects = make(data.StringSet)
+	input.JA3s = make(data.StringSet)
+	input.JA3Ss = make(data.StringSet)
+	input.ZeekUIDs = nil
+}
+
+// clearHTTPDetails removes the per connection details of an HTTP connection
+func clearHTTPDetails(input *sniconn.HTTPInput) {
+	input.ConnectionCount = 0
+	input.Timestamps = []int64{}
+	input.RespondingIPs = make(data.UniqueIPSet)
+	input.RespondingPorts = make(data.IntSet)
+	input.Methods = make(data.StringSet)
+	input.UserAgents = make(data.StringSet)
+	input.ZeekUIDs = nil
+}
@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/sniconn"
+	"github.com/activecm/rita/pkg/uconn"
+	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// spillTestEntries returns conn and ssl records between a few pairs of hosts
+func spillTestEntries() []parsetypes.BroData {
+	var entries []parsetypes.BroData
+	for i := 0; i < 30; i++ {
+		src := fmt.Sprintf("10.0.0.%d", i%3+1)
+		uid := fmt.Sprintf("C%d", i)
+		entries = append(entries, &parsetypes.Conn{
+			TimeStamp:       int64(1622548800 + i),
+			TimeStampMs:     int64(1622548800+i)*1000 + 250,
+			UID:             uid,
+			Source:          src,
+			SourcePort:      50000 + i,
+			Destination:     "93.184.216.34",
+			DestinationPort: 443,
+			Proto:           "tcp",
+			Service:         "ssl",
+			Duration:        float64(i),
+			OrigIPBytes:     int64(100 + i),
+			RespIPBytes:     200,
+		})
+		entries = append(entries, &parsetypes.SSL{
+			TimeStamp:       int64(1622548800 + i),
+			UID:             uid,
+			Source:          src,
+			SourcePort:      50000 + i,
+			Destination:     "93.184.216.34",
+			DestinationPort: 443,
+			ServerName:      "example.com",
+			JA3:             fmt.Sprintf("ja3-%d", i%2),
+		})
+	}
+	return entries
+}
+
+func TestSpillAndMergeConnections(t *testing.T) {
+	internal, _ := util.ParseSubnets([]string{"10.0.0.0/8"})
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	fs := &FSImporter{filter: filter{internal: internal}, log: logger}
+
+	inMemory := newParseResults()
+	spilled := newParseResults()
+	spilled.Spill = newSpiller(t.TempDir(), 1, true, logger)
+	// spill after every few records
+	spilled.Spill.limit = 5 * connSpillBytes
+
+	for _, entry := range spillTestEntries() {
+		fs.parseEntry(entry, inMemory, logger)
+		fs.parseEntry(entry, spilled, logger)
+	}
+	require.True(t, spilled.Spill.hasRuns())
+	require.Greater(t, len(spilled.Spill.uconnRuns), 1)
+	assert.Len(t, spilled.UniqueConnMap, len(inMemory.UniqueConnMap))
+
+	// the parts are copied since their details are removed once they are built
+	merged := make(map[string]uconn.Input)
+	err := spilled.Spill.mergeUconns(spilled.UniqueConnMap, func(part map[string]*uconn.Input) {
+		for key, input := range part {
+			copied := *input
+			copied.TsList = append([]int64{}, input.TsList...)
+			copied.OrigBytesList = append([]int64{}, input.OrigBytesList...)
+			merged[key] = copied
+		}
+	})
+	require.NoError(t, err)
+	require.Len(t, merged, len(inMemory.UniqueConnMap))
+	for key, expected := range inMemory.UniqueConnMap {
+		actual := merged[key]
+		assert.Equal(t, expected.Hosts, actual.Hosts)
+		assert.Equal(t, expected.ConnectionCount, actual.ConnectionCount)
+		assert.Equal(t, expected.TotalBytes, actual.TotalBytes)
+		assert.Equal(t, expected.TotalDuration, actual.TotalDuration)
+		assert.Equal(t, expected.MaxDuration, actual.MaxDuration)
+		assert.ElementsMatch(t, expected.TsList, actual.TsList)
+		assert.ElementsMatch(t, expected.OrigBytesList, actual.OrigBytesList)
+		assert.Equal(t, expected.Tuples, actual.Tuples)
+		assert.Equal(t, expected.UPPSFlag, actual.UPPSFlag)
+		// the details are removed once they are built
+		assert.Empty(t, spilled.UniqueConnMap[key].TsList)
+	}
+
+	mergedTLS := make(map[string]sniconn.TLSInput)
+	zeekUIDs := make(map[string]*data.ZeekUIDRecord)
+	err = spilled.Spill.mergeSNIConns(spilled.TLSConnMap, spilled.HTTPConnMap, spilled.ZeekUIDMap,
+		func(tlsMap map[string]*sniconn.TLSInput, httpMap map[string]*sniconn.HTTPInput, zeekUIDMap map[string]*data.ZeekUIDRecord) {
+			for key, input := range tlsMap {
+				copied := *input
+				copied.Timestamps = append([]int64{}, input.Timestamps...)
+				copied.ZeekUIDs = append([]string{}, input.ZeekUIDs...)
+				mergedTLS[key] = copied
+			}
+			for uid, record := range zeekUIDMap {
+				zeekUIDs[uid] = record
+			}
+		},
+	)
+	require.NoError(t, err)
+	require.Len(t, mergedTLS, len(inMemory.TLSConnMap))
+	for key, expected := range inMemory.TLSConnMap {
+		actual := mergedTLS[key]
+		assert.Equal(t, expected.ConnectionCount, actual.ConnectionCount)
+		assert.ElementsMatch(t, expected.Timestamps, actual.Timestamps)
+		assert.Equal(t, expected.RespondingIPs, actual.RespondingIPs)
+		assert.Equal(t, expected.RespondingPorts, actual.RespondingPorts)
+		assert.Equal(t, expected.JA3s, actual.JA3s)
+
+		sort.Strings(expected.ZeekUIDs)
+		sort.Strings(actual.ZeekUIDs)
+		assert.Equal(t, expected.ZeekUIDs, actual.ZeekUIDs)
+	}
+	assert.Equal(t, inMemory.ZeekUIDMap, zeekUIDs)
+
+	spilled.Spill.remove()
+	assert.NoDirExists(t, spilled.Spill.dir)
+}
+
+func TestSpillRunRoundTrip(t *testing.T) {
+	input := &uconn.Input{Tuples: data.StringSet{"443:tcp:ssl": {}, "80:tcp:http": {}}}
+	rec := spillRecord{Key: "a", Uconn: input}
+	path := filepath.Join(t.TempDir(), "run.gob")
+	require.NoError(t, writeRun(path, []string{"a"}, func(string) spillRecord { return rec }))
+
+	readers, err := openRuns([]string{path})
+	require.NoError(t, err)
+	defer closeRuns(readers)
+	require.NotNil(t, readers[0].next)
+	assert.Equal(t, input.Tuples, readers[0].next.Uconn.Tuples)
+	require.NoError(t, readers[0].advance())
+	assert.Nil(t, readers[0].next)
+}
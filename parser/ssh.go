@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"net"
+
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/sshconn"
+	"github.com/activecm/rita/pkg/useragent"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func parseSSHEntry(parseSSH *parsetypes.SSH, filter filter, retVals ParseResults, logger *log.Logger) entryOutcome {
+	// parse source and destination
+	srcIP := net.ParseIP(parseSSH.Source)
+	dstIP := net.ParseIP(parseSSH.Destination)
+
+	// verify that both addresses were parsed successfully
+	if (srcIP == nil) || (dstIP == nil) {
+		logger.WithFields(log.Fields{
+			"uid": parseSSH.UID,
+			"src": parseSSH.Source,
+			"dst": parseSSH.Destination,
+		}).Error("Unable to parse valid ip address pair from ssh log entry, skipping entry.")
+		return entryOutcome{rejectedFor: rejectInvalidAddress}
+	}
+
+	// Run conn pair through filter to filter out certain connections
+	if filteredBy := filter.connPairFilterRule(srcIP, dstIP); filteredBy != "" {
+		return entryOutcome{filteredBy: filteredBy}
+	}
+
+	srcUniqIP := data.NewUniqueIP(srcIP, parseSSH.AgentUUID, parseSSH.AgentHostname)
+	dstUniqIP := data.NewUniqueIP(dstIP, parseSSH.AgentUUID, parseSSH.AgentHostname)
+	srcDstPair := data.NewUniqueIPPair(srcUniqIP, dstUniqIP)
+
+	updateUseragentsBySSH(srcUniqIP, dstUniqIP, parseSSH, retVals)
+
+	updateSSHConnectionsBySSH(srcIP, dstIP, srcDstPair, parseSSH, filter, retVals)
+
+	return entryOutcome{}
+}
+
+func updateUseragentsBySSH(srcUniqIP, dstUniqIP data.UniqueIP, parseSSH *parsetypes.SSH, retVals ParseResults) {
+	// HASSH fingerprints are only logged if Zeek has the hassh package installed
+	if parseSSH.HASSH == "" {
+		return
+	}
+
+	retVals.UseragentLock.Lock()
+	defer retVals.UseragentLock.Unlock()
+
+	if _, ok := retVals.UseragentMap[parseSSH.HASSH]; !ok {
+		retVals.UseragentMap[parseSSH.HASSH] = &useragent.Input{
+			Name:     parseSSH.HASSH,
+			HASSH:    true,
+			OrigIps:  make(data.UniqueIPSet),
+			Requests: make(data.StringSet),
+		}
+	}
+
+	// ///// INCREMENT USERAGENT COUNTER /////
+	retVals.UseragentMap[parseSSH.HASSH].Seen++
+
+	// ///// UNION SOURCE HOST INTO USERAGENT ORIGINATING HOSTS /////
+	retVals.UseragentMap[parseSSH.HASSH].OrigIps.Insert(srcUniqIP)
+
+	// ///// UNION DESTINATION HOST INTO USERAGENT DESTINATIONS /////
+	retVals.UseragentMap[parseSSH.HASSH].Requests.Insert(dstUniqIP.IP)
+}
+
+func updateSSHConnectionsBySSH(srcIP, dstIP net.IP, srcDstPair data.UniqueIPPair,
+	parseSSH *parsetypes.SSH, filter filter, retVals ParseResults) {
+
+	srcDstKey := srcDstPair.MapKey()
+
+	retVals.SSHConnLock.Lock()
+	defer retVals.SSHConnLock.Unlock()
+
+	if _, ok := retVals.SSHConnMap[srcDstKey]; !ok {
+		retVals.SSHConnMap[srcDstKey] = &sshconn.Input{
+			Hosts:          srcDstPair,
+			IsLocalSrc:     filter.checkIfInternal(srcIP),
+			IsLocalDst:     filter.checkIfInternal(dstIP),
+			ClientVersions: make(data.StringSet),
+			ServerVersions: make(data.StringSet),
+			HASSHs:         make(data.StringSet),
+			HASSHServers:   make(data.StringSet),
+			DstPorts:       make(data.IntSet),
+			FirstSeen:      parseSSH.TimeStamp,
+			LastSeen:       parseSSH.TimeStamp,
+		}
+	}
+	sshConn := retVals.SSHConnMap[srcDstKey]
+
+	// ///// INCREMENT THE SESSION COUNT AND AUTHENTICATION COUNTERS /////
+	sshConn.Sessions++
+	sshConn.AuthAttempts += int64(parseSSH.AuthAttempts)
+	if parseSSH.AuthSuccess {
+		sshConn.AuthSuccesses++
+	} else if parseSSH.AuthAttempts > 0 {
+		sshConn.FailedSessions++
+	}
+
+	// ///// UNION VERSION STRINGS AND HASSH FINGERPRINTS INTO THE SSH CONNECTION /////
+	if parseSSH.Client != "" {
+		sshConn.ClientVersions.Insert(parseSSH.Client)
+	}
+	if parseSSH.Server != "" {
+		sshConn.ServerVersions.Insert(parseSSH.Server)
+	}
+	if parseSSH.HASSH != "" {
+		sshConn.HASSHs.Insert(parseSSH.HASSH)
+	}
+	if parseSSH.HASSHServer != "" {
+		sshConn.HASSHServers.Insert(parseSSH.HASSHServer)
+	}
+
+	// ///// UNION DESTINATION PORT INTO THE SSH CONNECTION /////
+	sshConn.DstPorts.Insert(parseSSH.DestinationPort)
+
+	// ///// EXTEND THE TIME RANGE OF THE SSH CONNECTION /////
+	if parseSSH.TimeStamp < sshConn.FirstSeen {
+		sshConn.FirstSeen = parseSSH.TimeStamp
+	}
+	if parseSSH.TimeStamp > sshConn.LastSeen {
+		sshConn.LastSeen = parseSSH.TimeStamp
+	}
+}
@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSSHEntries(t *testing.T) {
+	internal, _ := util.ParseSubnets([]string{"10.0.0.0/8"})
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	fs := &FSImporter{filter: filter{internal: internal}, log: logger}
+	retVals := newParseResults()
+
+	sessions := []*parsetypes.SSH{
+		{TimeStamp: 1622548802, AuthAttempts: 3},
+		{TimeStamp: 1622548801, AuthAttempts: 2},
+		{TimeStamp: 1622548803, AuthAttempts: 1, AuthSuccess: true, Client: "SSH-2.0-OpenSSH_8.2", HASSH: "ec7378c1"},
+		// the outcome of a session without authentication attempts is unknown
+		{TimeStamp: 1622548804, Client: "SSH-2.0-libssh_0.9.6"},
+	}
+	for _, session := range sessions {
+		session.Source = "203.0.113.5"
+		session.Destination = "10.0.0.22"
+		session.DestinationPort = 22
+		session.Server = "SSH-2.0-OpenSSH_7.4"
+		fs.parseEntry(session, retVals, logger)
+	}
+
+	// an entry with an invalid address is skipped
+	fs.parseEntry(&parsetypes.SSH{Source: "not an ip", Destination: "10.0.0.22"}, retVals, logger)
+
+	require.Len(t, retVals.SSHConnMap, 1)
+	for _, sshConn := range retVals.SSHConnMap {
+		assert.Equal(t, "203.0.113.5", sshConn.Hosts.SrcIP)
+		assert.False(t, sshConn.IsLocalSrc)
+		assert.True(t, sshConn.IsLocalDst)
+		assert.Equal(t, int64(4), sshConn.Sessions)
+		assert.Equal(t, int64(6), sshConn.AuthAttempts)
+		assert.Equal(t, int64(1), sshConn.AuthSuccesses)
+		assert.Equal(t, int64(2), sshConn.FailedSessions)
+		assert.Len(t, sshConn.ClientVersions, 2)
+		assert.Len(t, sshConn.ServerVersions, 1)
+		assert.Len(t, sshConn.HASSHs, 1)
+		assert.Equal(t, int64(1622548801), sshConn.FirstSeen)
+		assert.Equal(t, int64(1622548804), sshConn.LastSeen)
+	}
+
+	// HASSH fingerprints are analyzed alongside the other user agents
+	require.Contains(t, retVals.UseragentMap, "ec7378c1")
+	hassh := retVals.UseragentMap["ec7378c1"]
+	assert.True(t, hassh.HASSH)
+	assert.Equal(t, int64(1), hassh.Seen)
+	assert.Len(t, hassh.OrigIps, 1)
+	assert.Contains(t, hassh.Requests, "10.0.0.22")
+}
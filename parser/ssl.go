@@ -15,7 +15,7 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func parseSSLEntry(parseSSL *parsetypes.SSL, filter filter, retVals ParseResults, logger *log.Logger) {
+func parseSSLEntry(parseSSL *parsetypes.SSL, filter filter, retVals ParseResults, logger *log.Logger) entryOutcome {
 	src := parseSSL.Source
 	dst := parseSSL.Destination
 	certStatus := parseSSL.ValidationStatus
@@ -31,7 +31,7 @@ func parseSSLEntry(parseSSL *parsetypes.SSL, filter filter, retVals ParseResults
 			"src": parseSSL.Source,
 			"dst": parseSSL.Destination,
 		}).Error("Unable to parse valid ip address pair from ssl log entry, skipping entry.")
-		return
+		return entryOutcome{rejectedFor: rejectInvalidAddress}
 	}
 
 	// get fqdn
@@ -51,9 +51,12 @@ func parseSSLEntry(parseSSL *parsetypes.SSL, filter filter, retVals ParseResults
 
 	// create uconn and cert records
 	// Run conn pair through filter to filter out certain connections
-	ignore := filter.filterDomain(fqdn) || filter.filterConnPair(srcIP, dstIP)
-	if ignore {
-		return
+	filteredBy := filter.domainFilterRule(fqdn)
+	if filteredBy == "" {
+		filteredBy = filter.connPairFilterRule(srcIP, dstIP)
+	}
+	if filteredBy != "" {
+		return entryOutcome{filteredBy: filteredBy}
 	}
 
 	updateUseragentsBySSL(srcUniqIP, parseSSL, retVals)
@@ -71,6 +74,10 @@ func parseSSLEntry(parseSSL *parsetypes.SSL, filter filter, retVals ParseResults
 		// the unique connection record may have been created before the certificate record was seen
 		copyServiceTuplesFromUconnToCerts(dstKey, srcDstKey, retVals)
 	}
+
+	updateCertificatePresentationsBySSL(dstUniqIP, dstKey, parseSSL, retVals)
+
+	return entryOutcome{}
 }
 
 func updateUseragentsBySSL(srcUniqIP data.UniqueIP, parseSSL *parsetypes.SSL, retVals ParseResults) {
@@ -241,12 +248,7 @@ func updateCertificatesBySSL(srcUniqIP data.UniqueIP, dstUniqIP data.UniqueIP, d
 
 	if _, ok := retVals.CertificateMap[dstKey]; !ok {
 		// create new uconn record if it does not exist
-		retVals.CertificateMap[dstKey] = &certificate.Input{
-			Host:         dstUniqIP,
-			OrigIps:      make(data.UniqueIPSet),
-			InvalidCerts: make(data.StringSet),
-			Tuples:       make(data.StringSet),
-		}
+		retVals.CertificateMap[dstKey] = newCertificateInput(dstUniqIP)
 	}
 
 	// ///// INCREMENT CONNECTION COUNTER FOR DESTINATION WITH INVALID CERTIFICATE /////
@@ -259,6 +261,56 @@ func updateCertificatesBySSL(srcUniqIP data.UniqueIP, dstUniqIP data.UniqueIP, d
 	retVals.CertificateMap[dstKey].OrigIps.Insert(srcUniqIP)
 }
 
+func updateCertificatePresentationsBySSL(dstUniqIP data.UniqueIP, dstKey string, parseSSL *parsetypes.SSL, retVals ParseResults) {
+	// the leaf certificate is listed first. Resumed sessions don't send certificates.
+	var certRef string
+	if len(parseSSL.CertChainFps) > 0 {
+		certRef = parseSSL.CertChainFps[0]
+	} else if len(parseSSL.CertChainFuids) > 0 {
+		certRef = parseSSL.CertChainFuids[0]
+	}
+	if certRef == "" {
+		return
+	}
+
+	retVals.CertificateLock.Lock()
+	defer retVals.CertificateLock.Unlock()
+
+	if _, ok := retVals.CertificateMap[dstKey]; !ok {
+		retVals.CertificateMap[dstKey] = newCertificateInput(dstUniqIP)
+	}
+
+	presented := retVals.CertificateMap[dstKey].Presented
+	if _, ok := presented[certRef]; !ok {
+		presented[certRef] = &certificate.Presentation{
+			FirstSeen: parseSSL.TimeStamp,
+			LastSeen:  parseSSL.TimeStamp,
+		}
+	}
+
+	// ///// INCREMENT THE NUMBER OF TIMES THE SERVER PRESENTED THE CERTIFICATE /////
+	presented[certRef].Seen++
+
+	// ///// EXTEND THE TIME RANGE THE SERVER PRESENTED THE CERTIFICATE IN /////
+	if parseSSL.TimeStamp < presented[certRef].FirstSeen {
+		presented[certRef].FirstSeen = parseSSL.TimeStamp
+	}
+	if parseSSL.TimeStamp > presented[certRef].LastSeen {
+		presented[certRef].LastSeen = parseSSL.TimeStamp
+	}
+}
+
+// newCertificateInput creates the certificate record for a server
+func newCertificateInput(host data.UniqueIP) *certificate.Input {
+	return &certificate.Input{
+		Host:         host,
+		OrigIps:      make(data.UniqueIPSet),
+		InvalidCerts: make(data.StringSet),
+		Tuples:       make(data.StringSet),
+		Presented:    make(map[string]*certificate.Presentation),
+	}
+}
+
 func copyServiceTuplesFromUconnToCerts(dstKey, srcDstKey string, retVals ParseResults) {
 	retVals.UniqueConnLock.Lock()
 	retVals.CertificateLock.Lock()
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/activecm/rita/parser/files"
+)
+
+// reasons a log entry may be rejected, as recorded in the import statistics
+const (
+	rejectInvalidAddress = "invalid_address"
+	rejectMalformedLine  = "malformed_line"
+	rejectLogTypeChanged = "log_type_changed"
+)
+
+// entryOutcome describes what happened to a log entry when it was parsed.
+// An entry which was neither filtered nor rejected was imported.
+type entryOutcome struct {
+	filteredBy  string
+	rejectedFor string
+}
+
+// quarantine collects the rejected lines of the imported logs in a file so they
+// can be inspected later. The file is only created once a line is rejected.
+type quarantine struct {
+	path    string
+	file    *os.File
+	encoder *json.Encoder
+	lock    sync.Mutex
+}
+
+// quarantinedLine is written to the quarantine file for each rejected line
+type quarantinedLine struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+	Line   string `json:"line"`
+}
+
+// write appends a rejected line to the quarantine file
+func (q *quarantine) write(fileName string, reason string, line string) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.file == nil {
+		file, err := os.OpenFile(q.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		q.file = file
+		q.encoder = json.NewEncoder(file)
+		q.encoder.SetEscapeHTML(false)
+	}
+	return q.encoder.Encode(quarantinedLine{File: fileName, Reason: reason, Line: line})
+}
+
+// close closes the quarantine file if any lines were written to it
+func (q *quarantine) close() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.file == nil {
+		return nil
+	}
+	err := q.file.Close()
+	q.file = nil
+	return err
+}
+
+// recordOutcome counts a parsed entry in the statistics of the file it came from.
+// Rejected lines are written to the quarantine file if one was set.
+func (fs *FSImporter) recordOutcome(indexedFile *files.IndexedFile, outcome entryOutcome, line string) {
+	switch {
+	case outcome.rejectedFor != "":
+		indexedFile.Stats.AddRejected(outcome.rejectedFor)
+		if fs.quarantine != nil && line != "" {
+			if err := fs.quarantine.write(indexedFile.Name(), outcome.rejectedFor, line); err != nil {
+				fs.log.WithField("error", err.Error()).Error("Could not write to the quarantine file")
+			}
+		}
+	case outcome.filteredBy != "":
+		indexedFile.Stats.AddFiltered(outcome.filteredBy)
+	default:
+		indexedFile.Stats.AddParsed()
+	}
+}
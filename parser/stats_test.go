@@ -0,0 +1,183 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/parser/files"
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testStatsConnLog = "#separator \\x09\n" +
+	"#set_separator\t,\n" +
+	"#empty_field\t(empty)\n" +
+	"#unset_field\t-\n" +
+	"#path\tconn\n" +
+	"#fields\tts\tuid\tid.orig_h\tid.orig_p\tid.resp_h\tid.resp_p\tproto\n" +
+	"#types\ttime\tstring\taddr\tport\taddr\tport\tenum\n" +
+	"1622548800.000000\tCabc\t10.0.0.5\t51000\t93.184.216.34\t443\ttcp\n" +
+	"1622548801.000000\tCdef\t10.0.0.5\t51001\t10.0.0.6\t443\ttcp\n" +
+	"1622548802.000000\tCghi\t10.0.0.5\t51002\tnot-an-ip\t443\ttcp\n"
+
+func TestParseLogStats(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "conn.log")
+	require.NoError(t, ioutil.WriteFile(logPath, []byte(testStatsConnLog), 0644))
+
+	conf := &config.Config{}
+	conf.T.Structure.ConnTable = "conn"
+	indexed := files.TryIndexFiles([]string{logPath}, 1, "test", 0, log.New(), conf)
+	require.Len(t, indexed, 1)
+
+	internal, _ := util.ParseSubnets([]string{"10.0.0.0/8"})
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	quarantinePath := filepath.Join(dir, "quarantine.json")
+	fs := &FSImporter{
+		filter: filter{internal: internal},
+		log:    logger,
+	}
+	fs.SetQuarantineFile(quarantinePath)
+
+	fs.parseLog(indexed[0], bufio.NewScanner(strings.NewReader(testStatsConnLog)), newParseResults(), logger)
+	require.NoError(t, fs.quarantine.close())
+
+	stats := indexed[0].Stats
+	assert.Equal(t, int64(10), stats.LinesRead)
+	assert.Equal(t, int64(7), stats.LinesSkipped)
+	assert.Equal(t, int64(1), stats.RecordsParsed)
+	assert.Equal(t, int64(1), stats.RecordsFiltered)
+	assert.Equal(t, map[string]int64{ruleInternalToInternal: 1}, stats.FilteredBy)
+	assert.Equal(t, int64(1), stats.RecordsRejected)
+	assert.Equal(t, map[string]int64{rejectInvalidAddress: 1}, stats.RejectedFor)
+
+	contents, err := ioutil.ReadFile(quarantinePath)
+	require.NoError(t, err)
+	var quarantined quarantinedLine
+	require.NoError(t, json.Unmarshal(contents, &quarantined))
+	assert.Equal(t, quarantinedLine{
+		File:   logPath,
+		Reason: rejectInvalidAddress,
+		Line:   "1622548802.000000\tCghi\t10.0.0.5\t51002\tnot-an-ip\t443\ttcp",
+	}, quarantined)
+}
+
+func TestParseLogTimeWindow(t *testing.T) {
+	conf := &config.Config{}
+	conf.T.Structure.ConnTable = "conn"
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "conn.log")
+	require.NoError(t, ioutil.WriteFile(logPath, []byte(testStatsConnLog), 0644))
+	indexed := files.TryIndexFiles([]string{logPath}, 1, "test", 0, log.New(), conf)
+	require.Len(t, indexed, 1)
+
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	fs := &FSImporter{log: logger}
+	fs.SetTimeWindow(database.TimeWindow{Since: 1622548801})
+
+	retVals := newParseResults()
+	fs.parseLog(indexed[0], bufio.NewScanner(strings.NewReader(testStatsConnLog)), retVals, logger)
+
+	// records outside of the window are filtered before they are checked for valid addresses
+	stats := indexed[0].Stats
+	assert.Equal(t, int64(1), stats.RecordsParsed)
+	assert.Equal(t, map[string]int64{ruleTimeWindow: 1}, stats.FilteredBy)
+	assert.Equal(t, int64(1), stats.RecordsRejected)
+}
+
+func TestParseEntryTimeWindow(t *testing.T) {
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	fs := &FSImporter{log: logger}
+	fs.SetTimeWindow(database.TimeWindow{Since: 1622548800, Until: 1622552400})
+
+	// certificates and leases are held to the window like connections
+	entries := []struct {
+		entry    parsetypes.BroData
+		inWindow bool
+	}{
+		{&parsetypes.X509{TimeStamp: 1622548800, ID: "F1", Fingerprint: "fp1"}, true},
+		{&parsetypes.X509{TimeStamp: 1622552400, ID: "F2", Fingerprint: "fp2"}, false},
+		{&parsetypes.DHCP{TimeStamp: 1622548799, MAC: "00:11:22:33:44:55", AssignedAddr: "10.0.0.5", MsgTypes: []string{"ACK"}}, false},
+		{&parsetypes.DHCP{TimeStamp: 1622552399, MAC: "00:11:22:33:44:55", AssignedAddr: "10.0.0.5", MsgTypes: []string{"ACK"}}, true},
+	}
+	for _, test := range entries {
+		outcome := fs.parseEntry(test.entry, newParseResults(), logger)
+		if test.inWindow {
+			assert.Empty(t, outcome.filteredBy, "%#v", test.entry)
+		} else {
+			assert.Equal(t, ruleTimeWindow, outcome.filteredBy, "%#v", test.entry)
+		}
+	}
+}
+
+func TestParseLogMalformedLines(t *testing.T) {
+	conf := &config.Config{}
+	conf.T.Structure.ConnTable = "conn"
+	dir := t.TempDir()
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+
+	// a truncated line and a line with a port which isn't a number
+	tsvLog := testStatsConnLog +
+		"1622548803.000000\tCjkl\t10.0.0.5\n" +
+		"1622548804.000000\tCmno\t10.0.0.5\thigh\t93.184.216.34\t443\ttcp\n"
+	tsvPath := filepath.Join(dir, "conn.log")
+	require.NoError(t, ioutil.WriteFile(tsvPath, []byte(tsvLog), 0644))
+
+	jsonLog := `{"ts":1622548800.0,"uid":"Cabc","id.orig_h":"10.0.0.5","id.resp_h":"93.184.216.34","proto":"tcp"}` + "\n" +
+		`{"ts":1622548801.0,"uid":"Cdef","id.orig_h":` + "\n\n"
+	jsonPath := filepath.Join(dir, "conn.json")
+	require.NoError(t, ioutil.WriteFile(jsonPath, []byte(jsonLog), 0644))
+
+	indexed := files.TryIndexFiles([]string{tsvPath, jsonPath}, 1, "test", 0, log.New(), conf)
+	require.Len(t, indexed, 2)
+
+	internal, _ := util.ParseSubnets([]string{"10.0.0.0/8"})
+	for _, indexedFile := range indexed {
+		quarantinePath := filepath.Join(dir, filepath.Base(indexedFile.Path)+".quarantine")
+		fs := &FSImporter{filter: filter{internal: internal}, log: logger}
+		fs.SetQuarantineFile(quarantinePath)
+
+		contents, err := ioutil.ReadFile(indexedFile.Path)
+		require.NoError(t, err)
+		fs.parseLog(indexedFile, bufio.NewScanner(strings.NewReader(string(contents))), newParseResults(), logger)
+		require.NoError(t, fs.quarantine.close())
+
+		// the malformed lines are rejected with their raw text rather than imported as partial records
+		contents, err = ioutil.ReadFile(quarantinePath)
+		require.NoError(t, err)
+		var malformed []string
+		for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+			var quarantined quarantinedLine
+			require.NoError(t, json.Unmarshal([]byte(line), &quarantined))
+			if quarantined.Reason == rejectMalformedLine {
+				malformed = append(malformed, quarantined.Line)
+			}
+		}
+
+		if indexedFile.IsJSON() {
+			assert.Equal(t, []string{`{"ts":1622548801.0,"uid":"Cdef","id.orig_h":`}, malformed)
+			// the blank line is skipped
+			assert.Equal(t, int64(1), indexedFile.Stats.LinesSkipped)
+		} else {
+			assert.Equal(t, []string{
+				"1622548803.000000\tCjkl\t10.0.0.5",
+				"1622548804.000000\tCmno\t10.0.0.5\thigh\t93.184.216.34\t443\ttcp",
+			}, malformed)
+			assert.Equal(t, int64(7), indexedFile.Stats.LinesSkipped)
+		}
+		assert.Equal(t, int64(len(malformed)), indexedFile.Stats.RejectedFor[rejectMalformedLine])
+	}
+}
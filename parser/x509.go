@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/pkg/certificate"
+)
+
+func parseX509Entry(parseX509 *parsetypes.X509, retVals ParseResults) entryOutcome {
+	// certificates sent by clients are not presented by servers
+	if parseX509.ClientCert && !parseX509.HostCert {
+		return entryOutcome{}
+	}
+
+	// older logs don't record the fingerprint, so the certificate is identified
+	// by its issuer and serial number instead
+	fingerprint := parseX509.Fingerprint
+	if fingerprint == "" {
+		if parseX509.Serial == "" {
+			return entryOutcome{}
+		}
+		fingerprint = parseX509.Issuer + "/" + parseX509.Serial
+	}
+
+	updateX509ByX509(fingerprint, parseX509, retVals)
+
+	return entryOutcome{}
+}
+
+func updateX509ByX509(fingerprint string, parseX509 *parsetypes.X509, retVals ParseResults) {
+	retVals.X509Lock.Lock()
+	defer retVals.X509Lock.Unlock()
+
+	// the same certificate may be logged once for each connection it was seen in
+	if _, ok := retVals.X509Map[fingerprint]; !ok {
+		var sans []string
+		for _, group := range [][]string{parseX509.SANDNS, parseX509.SANIP, parseX509.SANURI, parseX509.SANEmail} {
+			sans = append(sans, group...)
+		}
+
+		retVals.X509Map[fingerprint] = &certificate.X509{
+			Fingerprint: fingerprint,
+			Subject:     parseX509.Subject,
+			Issuer:      parseX509.Issuer,
+			SANs:        sans,
+			NotBefore:   parseX509.NotValidBefore,
+			NotAfter:    parseX509.NotValidAfter,
+			KeyType:     parseX509.KeyType,
+			KeyLength:   parseX509.KeyLength,
+		}
+	}
+
+	// ///// LINK THE FILE ID OF THE CERTIFICATE TO ITS DETAILS /////
+	if parseX509.ID != "" {
+		retVals.X509Map[parseX509.ID] = retVals.X509Map[fingerprint]
+	}
+}
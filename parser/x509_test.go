@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/parser/files"
+	"github.com/activecm/rita/parser/parsetypes"
+	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseX509Log(t *testing.T) {
+	x509Log := "#separator \\x09\n" +
+		"#set_separator\t,\n" +
+		"#empty_field\t(empty)\n" +
+		"#unset_field\t-\n" +
+		"#path\tx509\n" +
+		"#fields\tts\tid\tfingerprint\tcertificate.serial\tcertificate.subject\tcertificate.issuer\t" +
+		"certificate.not_valid_before\tcertificate.not_valid_after\tcertificate.key_type\tcertificate.key_length\t" +
+		"san.dns\tsan.ip\thost_cert\n" +
+		"#types\ttime\tstring\tstring\tstring\tstring\tstring\ttime\ttime\tstring\tcount\tvector[string]\tvector[addr]\tbool\n" +
+		"1622548800.000000\tFabc\tfp1\t01\tCN=example.com\tCN=example.com\t1622505600.000000\t1622764800.000000\t" +
+		"rsa\t2048\texample.com,www.example.com\t93.184.216.34\tT\n"
+
+	logPath := filepath.Join(t.TempDir(), "x509.log")
+	require.NoError(t, ioutil.WriteFile(logPath, []byte(x509Log), 0644))
+	conf := &config.Config{}
+	conf.T.Structure.X509Table = "x509"
+	indexed := files.TryIndexFiles([]string{logPath}, 1, "test", 0, log.New(), conf)
+	require.Len(t, indexed, 1)
+
+	lines := strings.Split(strings.TrimSpace(x509Log), "\n")
+	entry := files.ParseTSVLine(lines[len(lines)-1], indexed[0].GetHeader(), indexed[0].GetFieldMap(),
+		indexed[0].GetBroDataFactory(), log.New())
+	cert, ok := entry.(*parsetypes.X509)
+	require.True(t, ok)
+	assert.Equal(t, "fp1", cert.Fingerprint)
+	assert.Equal(t, int64(1622764800), cert.NotValidAfter)
+	assert.Equal(t, 2048, cert.KeyLength)
+	assert.Equal(t, []string{"example.com", "www.example.com"}, cert.SANDNS)
+	assert.Equal(t, []string{"93.184.216.34"}, cert.SANIP)
+	assert.True(t, cert.HostCert)
+}
+
+func TestLinkCertificatesToServers(t *testing.T) {
+	internal, _ := util.ParseSubnets([]string{"10.0.0.0/8"})
+	logger := log.New()
+	logger.SetOutput(ioutil.Discard)
+	fs := &FSImporter{filter: filter{internal: internal}, log: logger}
+	retVals := newParseResults()
+
+	// an older x509 log without fingerprints
+	fs.parseEntry(&parsetypes.X509{ID: "F1", Serial: "01", Subject: "CN=a", Issuer: "CN=ca"}, retVals, logger)
+	fs.parseEntry(&parsetypes.X509{ID: "F2", Serial: "01", Subject: "CN=a", Issuer: "CN=ca"}, retVals, logger)
+	for i, fuid := range []string{"F1", "F2"} {
+		fs.parseEntry(&parsetypes.SSL{
+			TimeStamp:        int64(1622548800 + i),
+			UID:              "C1",
+			Source:           "10.0.0.1",
+			SourcePort:       50000,
+			Destination:      "93.184.216.34",
+			DestinationPort:  443,
+			ValidationStatus: "ok",
+			CertChainFuids:   []string{fuid, "Fca"},
+		}, retVals, logger)
+	}
+
+	// a resumed session doesn't present a certificate
+	fs.parseEntry(&parsetypes.SSL{
+		Source: "10.0.0.1", Destination: "93.184.216.35", DestinationPort: 443, Resumed: true,
+	}, retVals, logger)
+
+	// a certificate seen outside of the imported time window is dropped
+	fs.SetTimeWindow(database.TimeWindow{Until: 1622548800})
+	fs.parseEntry(&parsetypes.X509{TimeStamp: 1622548800, ID: "F3", Fingerprint: "fp3"}, retVals, logger)
+	assert.NotContains(t, retVals.X509Map, "F3")
+
+	require.Len(t, retVals.CertificateMap, 1)
+	for _, input := range retVals.CertificateMap {
+		assert.Equal(t, "93.184.216.34", input.Host.IP)
+		assert.Empty(t, input.InvalidCerts)
+		require.Len(t, input.Presented, 2)
+		assert.Same(t, retVals.X509Map["F1"], retVals.X509Map["F2"])
+		assert.Equal(t, "CN=ca/01", retVals.X509Map["F1"].Fingerprint)
+	}
+}
@@ -0,0 +1,137 @@
+package beacon
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/uconn"
+	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// medianInterval returns the median nonzero interval between sorted timestamps
+func medianInterval(tsList []int64) int64 {
+	var diff []int64
+	for i := 1; i < len(tsList); i++ {
+		if interval := tsList[i] - tsList[i-1]; interval > 0 {
+			diff = append(diff, interval)
+		}
+	}
+	sort.Sort(util.SortableInt64(diff))
+	return diff[util.Round(.5*float64(len(diff)-1))]
+}
+
+func TestGetPeriodicityScore(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	start := int64(1600000000)
+	day := int64(86400)
+
+	// a strict beacon every minute
+	var strict []int64
+	for ts := start; ts < start+day; ts += 60 {
+		strict = append(strict, ts)
+	}
+
+	// a beacon sleeping 300 seconds minus up to 50% jitter
+	var jittered []int64
+	for ts := start; ts < start+day; ts += 300 - rng.Int63n(150) {
+		jittered = append(jittered, ts)
+	}
+
+	// bursts of five connections a few seconds apart every 30 minutes
+	var bursty []int64
+	for ts := start; ts < start+day; ts += 1800 {
+		burst := ts
+		for i := 0; i < 5; i++ {
+			burst += 1 + rng.Int63n(10)
+			bursty = append(bursty, burst)
+		}
+	}
+
+	// connections at random times
+	var random []int64
+	for i := 0; i < 500; i++ {
+		random = append(random, start+rng.Int63n(day))
+	}
+	sort.Sort(util.SortableInt64(random))
+
+	strictPeriod, strictScore := getPeriodicityScore(strict, medianInterval(strict))
+	_, jitteredScore := getPeriodicityScore(jittered, medianInterval(jittered))
+	burstyPeriod, burstyScore := getPeriodicityScore(bursty, medianInterval(bursty))
+	_, randomScore := getPeriodicityScore(random, medianInterval(random))
+
+	assert.Equal(t, int64(60), strictPeriod)
+	assert.InDelta(t, 1.0, strictScore, 0.01)
+	assert.InDelta(t, 1800, burstyPeriod, 30)
+	assert.Greater(t, jitteredScore, randomScore)
+	assert.Greater(t, burstyScore, randomScore)
+}
+
+func TestAnalyzeSubSecondBeacon(t *testing.T) {
+	conf := &config.Config{}
+	conf.T.Beacon.BeaconTable = "beacon"
+
+	var changes []database.BulkChanges
+	a := newAnalyzer(1600000000, 1600000100, database.TimeWindow{}, 0, nil, conf, log.New(),
+		func(update database.BulkChanges) { changes = append(changes, update) }, func() {})
+	a.start()
+
+	// a connection every 250 milliseconds, which would collapse into zero
+	// second intervals without millisecond timestamps
+	input := &uconn.Input{ConnectionCount: 40, TotalBytes: 4000}
+	for i := int64(0); i < 40; i++ {
+		input.TsList = append(input.TsList, 1600000000000+i*250)
+		input.OrigBytesList = append(input.OrigBytesList, 100)
+	}
+	a.collect(input)
+	a.close()
+
+	require.Len(t, changes, 1)
+	update := changes[0]["beacon"][0].Update.(bson.M)["$set"].(bson.M)
+	assert.Equal(t, 0.25, update["ts.mode"])
+	assert.Equal(t, int64(39), update["ts.mode_count"])
+	assert.Equal(t, []float64{0.25}, update["ts.intervals"])
+	assert.Equal(t, 0.0, update["ts.dispersion"])
+	assert.Equal(t, int64(1600000000), update["ts.first"])
+	assert.Equal(t, int64(1600000009), update["ts.last"])
+	assert.Equal(t, 1.0, update["ts.score"])
+}
+
+func TestExcludedHistogramBuckets(t *testing.T) {
+	// a beacon every minute over a day, except during two maintenance windows
+	// which were left out of the import
+	min, max := int64(0), int64(24*3600)
+	window := database.TimeWindow{Exclude: []database.Range{
+		{Min: 4 * 3600, Max: 6 * 3600},
+		{Min: 16 * 3600, Max: 18 * 3600},
+	}}
+	var tsList []int64
+	for ts := min; ts < max; ts += 60 {
+		if window.Contains(ts) {
+			tsList = append(tsList, ts)
+		}
+	}
+
+	_, _, _, totalBars, longestRun, histScore := getTsHistogramScore(min, max, tsList, window, 0.05, 1, 24)
+	assert.Equal(t, 20, totalBars)
+	assert.Equal(t, 20, longestRun)
+	assert.Equal(t, 1.0, histScore)
+
+	// without the window the gaps lower the scores
+	_, _, _, gapBars, gapRun, gapScore := getTsHistogramScore(min, max, tsList, database.TimeWindow{}, 0.05, 1, 24)
+	assert.Equal(t, 20, gapBars)
+	assert.Equal(t, 10, gapRun)
+	assert.Less(t, gapScore, histScore)
+
+	// the beacon stopped after 16 hours
+	durScore := getDurationScore(min, max, 0, 16*3600, window, totalBars, 0, 6, 48)
+	assert.Equal(t, 0.7, durScore)
+	durScore = getDurationScore(min, max, 0, 16*3600, database.TimeWindow{}, totalBars, 0, 6, 48)
+	assert.Equal(t, 0.667, durScore)
+}
@@ -6,7 +6,7 @@ import (
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/pkg/uconn"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 type (
@@ -23,6 +23,25 @@ type (
 	}
 )
 
+// millisTimestampsQuery gathers the connection timestamps of each chunk in milliseconds.
+// Chunks imported before timestamps were kept in milliseconds only hold whole seconds.
+var millisTimestampsQuery = bson.M{
+	"$map": bson.M{
+		"input": "$dat",
+		"as":    "chunk",
+		"in": bson.M{
+			"$ifNull": []interface{}{
+				"$$chunk.ts_ms",
+				bson.M{"$map": bson.M{
+					"input": "$$chunk.ts",
+					"as":    "ts",
+					"in":    bson.M{"$multiply": []interface{}{"$$ts", 1000}},
+				}},
+			},
+		},
+	},
+}
+
 // newDissector creates a new dissector for gathering data
 func newDissector(connLimit int64, chunk int, db *database.DB, conf *config.Config, dissectedCallback func(*uconn.Input), closedCallback func()) *dissector {
 	return &dissector{
@@ -52,8 +71,7 @@ func (d *dissector) close() {
 func (d *dissector) start() {
 	d.dissectWg.Add(1)
 	go func() {
-		ssn := d.db.Session.Copy()
-		defer ssn.Close()
+		ctx := d.db.Context()
 
 		for datum := range d.dissectChannel {
 
@@ -78,7 +96,7 @@ func (d *dissector) start() {
 				{"$match": matchNoStrobeKey},
 				{"$limit": 1},
 				{"$project": bson.M{
-					"ts":     "$dat.ts",
+					"ts":     millisTimestampsQuery,
 					"bytes":  "$dat.bytes",
 					"count":  "$dat.count",
 					"tbytes": "$dat.tbytes",
@@ -138,7 +156,7 @@ func (d *dissector) start() {
 				TBytes      int64   `bson:"tbytes"`
 			}
 
-			_ = ssn.DB(d.db.GetSelectedDB()).C(d.conf.T.Structure.UniqueConnTable).Pipe(uconnFindQuery).AllowDiskUse().One(&res)
+			_ = database.AggregateOne(ctx, d.db.Collection(d.conf.T.Structure.UniqueConnTable), uconnFindQuery, &res)
 
 			// Check for errors and parse results
 			// this is here because it will still return an empty document even if there are no results
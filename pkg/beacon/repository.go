@@ -1,6 +1,7 @@
 package beacon
 
 import (
+	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/pkg/data"
 	"github.com/activecm/rita/pkg/host"
 	"github.com/activecm/rita/pkg/uconn"
@@ -9,46 +10,52 @@ import (
 // Repository for beacon collection
 type Repository interface {
 	CreateIndexes() error
-	Upsert(uconnMap map[string]*uconn.Input, hostMap map[string]*host.Input, minTimestamp, maxTimestamp int64)
+	Upsert(uconnMap map[string]*uconn.Input, hostMap map[string]*host.Input, minTimestamp, maxTimestamp int64, window database.TimeWindow)
 }
 
 // TSData ...
+// The intervals are in seconds with millisecond precision. Datasets analyzed
+// by earlier versions store whole seconds, which are read the same way.
 type TSData struct {
-	Score      float64 `bson:"score"`
-	Range      int64   `bson:"range"`
-	Mode       int64   `bson:"mode"`
-	ModeCount  int64   `bson:"mode_count"`
-	Skew       float64 `bson:"skew"`
-	Dispersion int64   `bson:"dispersion"`
+	Score      float64 `bson:"score" json:"score"`
+	Range      float64 `bson:"range" json:"range"`
+	Mode       float64 `bson:"mode" json:"mode"`
+	ModeCount  int64   `bson:"mode_count" json:"mode_count"`
+	Skew       float64 `bson:"skew" json:"skew"`
+	Dispersion float64 `bson:"dispersion" json:"dispersion"`
+	Period     float64 `bson:"period" json:"period"`
+	First      int64   `bson:"first" json:"first"`
+	Last       int64   `bson:"last" json:"last"`
 }
 
 // DSData ...
 type DSData struct {
-	Score      float64 `bson:"score"`
-	Skew       float64 `bson:"skew"`
-	Dispersion int64   `bson:"dispersion"`
-	Range      int64   `bson:"range"`
-	Mode       int64   `bson:"mode"`
-	ModeCount  int64   `bson:"mode_count"`
+	Score      float64 `bson:"score" json:"score"`
+	Skew       float64 `bson:"skew" json:"skew"`
+	Dispersion int64   `bson:"dispersion" json:"dispersion"`
+	Range      int64   `bson:"range" json:"range"`
+	Mode       int64   `bson:"mode" json:"mode"`
+	ModeCount  int64   `bson:"mode_count" json:"mode_count"`
 }
 
 // Result represents a beacon between two hosts. Contains information
 // on connection delta times and the amount of data transferred
 type Result struct {
 	data.UniqueIPPair `bson:",inline"`
-	Connections       int64   `bson:"connection_count"`
-	AvgBytes          float64 `bson:"avg_bytes"`
-	TotalBytes        int64   `bson:"total_bytes"`
-	Ts                TSData  `bson:"ts"`
-	Ds                DSData  `bson:"ds"`
-	DurScore          float64 `bson:"duration_score"`
-	HistScore         float64 `bson:"hist_score"`
-	Score             float64 `bson:"score"`
+	Connections       int64   `bson:"connection_count" json:"connection_count"`
+	AvgBytes          float64 `bson:"avg_bytes" json:"avg_bytes"`
+	TotalBytes        int64   `bson:"total_bytes" json:"total_bytes"`
+	Ts                TSData  `bson:"ts" json:"ts"`
+	Ds                DSData  `bson:"ds" json:"ds"`
+	DurScore          float64 `bson:"duration_score" json:"duration_score"`
+	HistScore         float64 `bson:"hist_score" json:"hist_score"`
+	PeriodicityScore  float64 `bson:"periodicity_score" json:"periodicity_score"`
+	Score             float64 `bson:"score" json:"score"`
 }
 
 // StrobeResult represents a unique connection with a large amount
 // of connections between the hosts
 type StrobeResult struct {
 	data.UniqueIPPair `bson:",inline"`
-	ConnectionCount   int64 `bson:"connection_count"`
+	ConnectionCount   int64 `bson:"connection_count" json:"connection_count"`
 }
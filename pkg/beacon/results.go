@@ -1,35 +1,105 @@
 package beacon
 
 import (
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/filter"
 	"github.com/activecm/rita/resources"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
-//Results finds beacons in the database greater than a given cutoffScore
-func Results(res *resources.Resources, cutoffScore float64) ([]Result, error) {
-	ssn := res.DB.Session.Copy()
-	defer ssn.Close()
+//beaconFields names the beacon collection fields used for filtering
+var beaconFields = filter.Fields{
+	Src:            "src",
+	SrcNetworkName: "src_network_name",
+	Dst:            "dst",
+	DstNetworkName: "dst_network_name",
+	Score:          "score",
+	Conns:          "connection_count",
+	FirstSeen:      "ts.first",
+	LastSeen:       "ts.last",
+}
 
+//Results finds beacons in the database greater than a given cutoffScore
+//which match the given filter
+func Results(res *resources.Resources, cutoffScore float64, filt filter.Filter) ([]Result, error) {
 	var beacons []Result
 
-	beaconQuery := bson.M{"score": bson.M{"$gt": cutoffScore}}
+	beaconQuery, err := ResultsQuery(res, cutoffScore, filt)
+	if err != nil {
+		return beacons, err
+	}
 
-	err := ssn.DB(res.DB.GetSelectedDB()).C(res.Config.T.Beacon.BeaconTable).Find(beaconQuery).Sort("-score").All(&beacons)
+	err = database.AggregateAll(res.DB.Context(), res.DB.Collection(res.Config.T.Beacon.BeaconTable), beaconQuery, &beacons)
 
 	return beacons, err
 }
 
-//StrobeResults finds strobes (beacons with an immense number of connections) in the database.
-//The results will be sorted by connection count ordered by sortDir (-1 or 1).
-//limit and noLimit control how many results are returned.
-func StrobeResults(res *resources.Resources, sortDir, limit int, noLimit bool) ([]StrobeResult, error) {
-	ssn := res.DB.Session.Copy()
-	defer ssn.Close()
+//ResultsQuery builds the aggregation pipeline used by Results, sorted by score
+func ResultsQuery(res *resources.Resources, cutoffScore float64, filt filter.Filter) ([]bson.M, error) {
+	filterPredicate, err := filt.Predicate(beaconFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// the time window can't be applied to results analyzed by earlier versions
+	collection := res.DB.Collection(res.Config.T.Beacon.BeaconTable)
+	err = filt.CheckTimeFields(res.DB.Context(), collection, beaconFields)
+	if err != nil {
+		return nil, err
+	}
+
+	beaconQuery := []bson.M{
+		{"$match": bson.M{"$and": []bson.M{
+			{"score": bson.M{"$gt": cutoffScore}},
+			filterPredicate,
+		}}},
+		{"$sort": bson.M{"score": -1}},
+	}
+
+	return beaconQuery, nil
+}
 
+//StrobeResults finds strobes (beacons with an immense number of connections) in the database
+//which match the given filter. The results will be sorted by connection count ordered by
+//sortDir (-1 or 1). limit and noLimit control how many results are returned.
+func StrobeResults(res *resources.Resources, sortDir, limit int, noLimit bool, filt filter.Filter) ([]StrobeResult, error) {
 	var strobes []StrobeResult
 
+	strobeQuery, err := StrobeResultsQuery(sortDir, filt)
+	if err != nil {
+		return strobes, err
+	}
+
+	if !noLimit {
+		strobeQuery = append(strobeQuery, bson.M{"$limit": limit})
+	}
+
+	err = database.AggregateAll(res.DB.Context(), res.DB.Collection(res.Config.T.Structure.UniqueConnTable), strobeQuery, &strobes)
+
+	return strobes, err
+}
+
+//StrobeResultsQuery builds the aggregation pipeline used by StrobeResults, sorted by
+//connection count ordered by sortDir
+func StrobeResultsQuery(sortDir int, filt filter.Filter) ([]bson.M, error) {
+	hostPredicate, err := filt.Predicate(filter.Fields{
+		Src:            "src",
+		SrcNetworkName: "src_network_name",
+		Dst:            "dst",
+		DstNetworkName: "dst_network_name",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the connection count is summed across chunks, so it is filtered after grouping
+	connsPredicate, err := filt.Predicate(filter.Fields{Conns: "connection_count"})
+	if err != nil {
+		return nil, err
+	}
+
 	strobeQuery := []bson.M{
-		{"$match": bson.M{"strobe": true}},
+		{"$match": bson.M{"$and": []bson.M{{"strobe": true}, hostPredicate}}},
 		{"$unwind": "$dat"},
 		{"$project": bson.M{
 			"src":              1,
@@ -50,15 +120,9 @@ func StrobeResults(res *resources.Resources, sortDir, limit int, noLimit bool) (
 			"dst_network_name": bson.M{"$first": "$dst_network_name"},
 			"connection_count": bson.M{"$sum": "$conns"},
 		}},
+		{"$match": connsPredicate},
 		{"$sort": bson.M{"connection_count": sortDir}},
 	}
 
-	if !noLimit {
-		strobeQuery = append(strobeQuery, bson.M{"$limit": limit})
-	}
-
-	err := ssn.DB(res.DB.GetSelectedDB()).C(res.Config.T.Structure.UniqueConnTable).Pipe(strobeQuery).AllowDiskUse().All(&strobes)
-
-	return strobes, err
-
+	return strobeQuery, nil
 }
@@ -6,8 +6,8 @@ import (
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/pkg/uconn"
-	"github.com/globalsign/mgo/bson"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 type (
@@ -60,9 +60,6 @@ func (s *siphon) close() {
 func (s *siphon) start() {
 	s.siphonWg.Add(1)
 	go func() {
-		ssn := s.db.Session.Copy()
-		defer ssn.Close()
-
 		for data := range s.siphonChannel {
 
 			// check if uconn has become a strobe
@@ -80,7 +77,6 @@ func (s *siphon) start() {
 						Selector: database.MergeBSONMaps(data.Hosts.BSONKey(), bson.M{
 							"dat": bson.M{"$elemMatch": bson.M{
 								"cid":   s.chunk,
-								"ts":    bson.M{"$exists": true},
 								"bytes": bson.M{"$exists": true},
 							}},
 						}),
@@ -89,8 +85,9 @@ func (s *siphon) start() {
 							// this must be done as uconns unsets its strobe flag if the current chunk doesnt meet
 							// the strobe limit
 							"$set": bson.M{"strobe": true},
-							// remove the bytes and ts arrays for the current chunk in the uconn document
-							"$unset": bson.M{"dat.$.ts": "", "dat.$.bytes": ""},
+							// remove the bytes and timestamp arrays for the current chunk in the uconn document.
+							// Chunks imported by earlier versions hold whole second timestamps in ts.
+							"$unset": bson.M{"dat.$.ts": "", "dat.$.ts_ms": "", "dat.$.bytes": ""},
 						},
 					}},
 
@@ -1,14 +1,15 @@
 package beacon
 
 import (
+	"context"
 	"sync"
 
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/pkg/data"
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/bson"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type (
@@ -55,16 +56,15 @@ func (s *summarizer) start() {
 	s.summaryWg.Add(1)
 	go func() {
 
-		ssn := s.db.Session.Copy()
-		defer ssn.Close()
+		ctx := s.db.Context()
 
 		for datum := range s.summaryChannel {
-			beaconCollection := ssn.DB(s.db.GetSelectedDB()).C(s.conf.T.Beacon.BeaconTable)
-			hostCollection := ssn.DB(s.db.GetSelectedDB()).C(s.conf.T.Structure.HostTable)
+			beaconCollection := s.db.Collection(s.conf.T.Beacon.BeaconTable)
+			hostCollection := s.db.Collection(s.conf.T.Structure.HostTable)
 
-			maxBeaconSelector, maxBeaconQuery, err := maxBeaconUpdate(datum, beaconCollection, hostCollection, s.chunk)
+			maxBeaconSelector, maxBeaconQuery, err := maxBeaconUpdate(ctx, datum, beaconCollection, hostCollection, s.chunk)
 			if err != nil {
-				if err != mgo.ErrNotFound {
+				if err != mongo.ErrNoDocuments {
 					s.log.WithFields(log.Fields{
 						"Module": "beacon",
 						"Data":   datum,
@@ -88,7 +88,7 @@ func (s *summarizer) start() {
 }
 
 // maxBeaconUpdate finds the highest scoring beacon from this import session for a particular host
-func maxBeaconUpdate(datum data.UniqueIP, beaconColl, hostColl *mgo.Collection, chunk int) (bson.M, bson.M, error) {
+func maxBeaconUpdate(ctx context.Context, datum data.UniqueIP, beaconColl, hostColl *mongo.Collection, chunk int) (bson.M, bson.M, error) {
 
 	var maxBeaconIP struct {
 		Dst   data.UniqueIP `bson:"dst"`
@@ -96,7 +96,7 @@ func maxBeaconUpdate(datum data.UniqueIP, beaconColl, hostColl *mgo.Collection,
 	}
 
 	mbdstQuery := maxBeaconPipeline(datum)
-	err := beaconColl.Pipe(mbdstQuery).One(&maxBeaconIP)
+	err := database.AggregateOne(ctx, beaconColl, mbdstQuery, &maxBeaconIP)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -107,19 +107,16 @@ func maxBeaconUpdate(datum data.UniqueIP, beaconColl, hostColl *mgo.Collection,
 		bson.M{"dat": bson.M{"$elemMatch": bson.M{"mbdst.ip": bson.M{"$exists": true}}}},
 	)
 
-	nExistingEntries, err := hostColl.Find(hostWithDatEntrySelector).Count()
+	existingEntry, err := database.FindDatEntry(ctx, hostColl, hostWithDatEntrySelector)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	if nExistingEntries > 0 {
-		updateQuery := bson.M{
-			"$set": bson.M{
-				"dat.$.mbdst":            maxBeaconIP.Dst,
-				"dat.$.max_beacon_score": maxBeaconIP.Score,
-				"dat.$.cid":              chunk,
-			},
-		}
+	if existingEntry != nil {
+		updateQuery := database.MoveDatEntry(existingEntry, bson.M{
+			"mbdst":            maxBeaconIP.Dst,
+			"max_beacon_score": maxBeaconIP.Score,
+		}, chunk)
 		return hostWithDatEntrySelector, updateQuery, nil
 	}
 
@@ -10,8 +10,8 @@ import (
 	"github.com/activecm/rita/pkg/uconnproxy"
 	"github.com/activecm/rita/util"
 
-	"github.com/globalsign/mgo/bson"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 type (
@@ -20,6 +20,7 @@ type (
 	analyzer struct {
 		tsMin            int64                      // min timestamp for the whole dataset
 		tsMax            int64                      // max timestamp for the whole dataset
+		window           database.TimeWindow        // time window of the import, used to skip the excluded ranges
 		chunk            int                        //current chunk (0 if not on rolling analysis)
 		db               *database.DB               // provides access to MongoDB
 		conf             *config.Config             // contains details needed to access MongoDB
@@ -31,12 +32,16 @@ type (
 	}
 )
 
+// periodicityMaxBins caps the length of the connection series used for the periodicity score
+const periodicityMaxBins int64 = 4096
+
 // newAnalyzer creates a new analyzer for calculating the beacon statistics of proxied unique connections
-func newAnalyzer(min int64, max int64, chunk int, db *database.DB, conf *config.Config, log *log.Logger,
+func newAnalyzer(min int64, max int64, window database.TimeWindow, chunk int, db *database.DB, conf *config.Config, log *log.Logger,
 	analyzedCallback func(database.BulkChanges), closedCallback func()) *analyzer {
 	return &analyzer{
 		tsMin:            min,
 		tsMax:            max,
+		window:           window,
 		chunk:            chunk,
 		db:               db,
 		conf:             conf,
@@ -154,15 +159,19 @@ func (a *analyzer) start() {
 			tsScore := math.Ceil(((tsSkewScore+tsMadmScore)/2.0)*1000) / 1000
 
 			// calculate histogram score
-			bucketDivs, freqList, freqCount, totalBars, longestRun, histScore := getTsHistogramScore(a.tsMin, a.tsMax, entry.TsList, a.conf.S.BeaconProxy.HistBimodalBucketSize, a.conf.S.BeaconProxy.HistBimodalOutlierRemoval, a.conf.S.BeaconProxy.HistBimodalMinHoursSeen)
+			bucketDivs, freqList, freqCount, totalBars, longestRun, histScore := getTsHistogramScore(a.tsMin, a.tsMax, entry.TsList, a.window, a.conf.S.BeaconProxy.HistBimodalBucketSize, a.conf.S.BeaconProxy.HistBimodalOutlierRemoval, a.conf.S.BeaconProxy.HistBimodalMinHoursSeen)
 
 			// calculate duration score
-			durScore := getDurationScore(a.tsMin, a.tsMax, entry.TsList[0], entry.TsList[tsLength], totalBars, longestRun, a.conf.S.BeaconProxy.DurMinHoursSeen, a.conf.S.BeaconProxy.DurConsistencyIdealHoursSeen)
+			durScore := getDurationScore(a.tsMin, a.tsMax, entry.TsList[0], entry.TsList[tsLength], a.window, totalBars, longestRun, a.conf.S.BeaconProxy.DurMinHoursSeen, a.conf.S.BeaconProxy.DurConsistencyIdealHoursSeen)
+
+			// calculate periodicity score
+			period, periodicityScore := getPeriodicityScore(entry.TsList, tsMid)
 
 			// calculate overall beacon score
 			score := math.Ceil(((tsScore*a.conf.S.BeaconProxy.TsWeight)+
 				(durScore*a.conf.S.BeaconProxy.DurWeight)+
-				(histScore*a.conf.S.BeaconProxy.HistWeight))*1000) / 1000
+				(histScore*a.conf.S.BeaconProxy.HistWeight)+
+				(periodicityScore*a.conf.S.BeaconProxy.PeriodicityWeight))*1000) / 1000
 
 			// copy variables to be used by bulk callback to prevent capturing by reference
 			pairSelector := entry.Hosts.BSONKey()
@@ -179,11 +188,15 @@ func (a *analyzer) start() {
 					"ts.dispersion":      tsMadm,
 					"ts.skew":            tsSkew,
 					"ts.score":           tsScore,
+					"ts.period":          period,
+					"ts.first":           entry.TsList[0],
+					"ts.last":            entry.TsList[tsLength],
 					"duration_score":     durScore,
 					"bucket_divs":        bucketDivs,
 					"freq_list":          freqList,
 					"freq_count":         freqCount,
 					"hist_score":         histScore,
+					"periodicity_score":  periodicityScore,
 					"score":              score,
 					"cid":                a.chunk,
 				},
@@ -248,29 +261,45 @@ func countAndRemoveConsecutiveDuplicates(numberList []int64) ([]int64, map[int64
 }
 
 // getTsHistogramScore calculates two potential scores based on the histogram of connections for the
-// host pair and takes the max of the two scores.
-func getTsHistogramScore(min int64, max int64, tsList []int64, bimodalBucketSize float64, bimodalOutlierRemoval int, bimodalMinHoursSeen int) ([]int64, []int, map[int]int, int, int, float64) {
+// host pair and takes the max of the two scores. Buckets which overlap a range excluded from the
+// import are left out of the scores.
+func getTsHistogramScore(min int64, max int64, tsList []int64, window database.TimeWindow, bimodalBucketSize float64, bimodalOutlierRemoval int, bimodalMinHoursSeen int) ([]int64, []int, map[int]int, int, int, float64) {
 
 	// get bucket list
 	// we currently look at a 24 hour period
 	bucketDivs := createBuckets(min, max, 24)
 
 	// use timestamps to get freqencies for buckets
-	freqList, freqCount, total, totalBars, longestRun := createHistogram(bucketDivs, tsList, bimodalBucketSize)
+	freqList := createHistogram(bucketDivs, tsList)
+
+	// the connections of the excluded ranges were not imported, so the buckets
+	// overlapping them would look like gaps in the beacon
+	scoredFreqList := make([]int, 0, len(freqList))
+	for i, freq := range freqList {
+		if !window.Excludes(bucketDivs[i], bucketDivs[i+1]) {
+			scoredFreqList = append(scoredFreqList, freq)
+		}
+	}
+	if len(scoredFreqList) == 0 {
+		return bucketDivs, freqList, make(map[int]int), 0, 0, 0
+	}
+
+	// get histogram frequency counts
+	freqCount, total, totalBars, longestRun := getFrequencyCounts(scoredFreqList, bimodalBucketSize)
 
 	// calculate first potential score
 	// coefficient of variation will help score histograms that have jitter in the number of
 	// connections but where the overall graph would still look relatively flat and consistent
 
 	// calculate mean
-	freqMean := float64(total) / float64(len(freqList))
+	freqMean := float64(total) / float64(len(scoredFreqList))
 
 	// calculate standard deviation
 	sd := float64(0)
-	for j := 0; j < len(freqList); j++ {
-		sd += math.Pow(float64(freqList[j])-freqMean, 2)
+	for j := 0; j < len(scoredFreqList); j++ {
+		sd += math.Pow(float64(scoredFreqList[j])-freqMean, 2)
 	}
-	sd = math.Sqrt(sd / float64(len(freqList)))
+	sd = math.Sqrt(sd / float64(len(scoredFreqList)))
 
 	// calculate coefficient of variation
 	cv := sd / freqMean
@@ -349,7 +378,7 @@ func createBuckets(min int64, max int64, size int64) []int64 {
 }
 
 // createHistogram
-func createHistogram(bucketDivs []int64, tsList []int64, bimodalBucketSize float64) ([]int, map[int]int, int, int, int) {
+func createHistogram(bucketDivs []int64, tsList []int64) []int {
 	i := 0
 	bucket := bucketDivs[i+1]
 
@@ -381,11 +410,7 @@ func createHistogram(bucketDivs []int64, tsList []int64, bimodalBucketSize float
 		freqList[i]++
 	}
 
-	// get histogram frequency counts
-	freqCount, total, totalBars, longestRun := getFrequencyCounts(freqList, bimodalBucketSize)
-
-	return freqList, freqCount, total, totalBars, longestRun
-
+	return freqList
 }
 
 func getFrequencyCounts(freqList []int, bimodalBucketSize float64) (map[int]int, int, int, int) {
@@ -470,7 +495,7 @@ func getFrequencyCounts(freqList []int, bimodalBucketSize float64) (map[int]int,
 }
 
 // getDurationScore
-func getDurationScore(min int64, max int64, tsListMin int64, tsListMax int64, totalBars int, longestRun int, minHoursSeen, consistencyIdealHoursSeen int) float64 {
+func getDurationScore(min int64, max int64, tsListMin int64, tsListMax int64, window database.TimeWindow, totalBars int, longestRun int, minHoursSeen, consistencyIdealHoursSeen int) float64 {
 	// Duration will only be calculated if more than the yaml-defined  threshold (default: 6) hours are
 	// represented in the connection frequency histogram
 	// Duration Score will take the maximum of two potential subscores:
@@ -481,12 +506,19 @@ func getDurationScore(min int64, max int64, tsListMin int64, tsListMax int64, to
 	// [ longest run of consecutive hours seen] / [ 12 hours* ]
 	// note: consecutive includes wrap around from start to end of dataset
 	// *ideal number of consecutive hours can be adjusted in the rita yaml file (default: 12)
+	// the ranges excluded from the import are left out of both timespans
 
 	durScore := 0.0
 
 	if totalBars > minHoursSeen {
 
-		coverageScore := math.Ceil((float64(tsListMax-tsListMin)/(float64(max)-float64(min)))*1000) / 1000
+		connSpan := tsListMax - tsListMin - window.ExcludedDuration(tsListMin, tsListMax)
+		datasetSpan := max - min - window.ExcludedDuration(min, max)
+
+		coverageScore := 0.0
+		if datasetSpan > 0 {
+			coverageScore = math.Ceil((float64(connSpan)/float64(datasetSpan))*1000) / 1000
+		}
 		if coverageScore > 1.0 {
 			coverageScore = 1.0
 		}
@@ -501,3 +533,80 @@ func getDurationScore(min int64, max int64, tsListMin int64, tsListMax int64, to
 
 	return durScore
 }
+
+// getPeriodicityScore measures how strongly connections recur on a fixed period. The
+// timestamps are binned into a connection count series and the tallest autocorrelation
+// peak past the central lobe is taken as the score, with its lag as the detected period.
+// Unlike the interval statistics, this peak survives heavy jitter and schedules which
+// sleep and then burst, since only the repetition of the overall pattern matters.
+func getPeriodicityScore(tsList []int64, medianInterval int64) (int64, float64) {
+	span := tsList[len(tsList)-1] - tsList[0]
+
+	// bins are half of the median interval wide so that a perfect beacon alternates
+	// between full and empty bins, but the series is capped in length to bound the cost
+	binWidth := medianInterval / 2
+	if binWidth < 1 {
+		binWidth = 1
+	}
+	if span/binWidth >= periodicityMaxBins {
+		binWidth = span/periodicityMaxBins + 1
+	}
+
+	numBins := int(span/binWidth) + 1
+	if numBins < 4 {
+		return 0, 0
+	}
+
+	series := make([]float64, numBins)
+	for _, ts := range tsList {
+		series[(ts-tsList[0])/binWidth]++
+	}
+
+	// center the series so the autocorrelation measures covariance
+	mean := float64(len(tsList)) / float64(numBins)
+	for i := range series {
+		series[i] -= mean
+	}
+
+	acf := util.Autocorrelation(series)
+
+	// every bin holds the same number of connections
+	if acf[0] == 0 {
+		return binWidth, 1
+	}
+
+	// skip the central lobe around lag zero, which only reflects connections
+	// landing in neighboring bins
+	lag := 1
+	for lag < numBins/2 && acf[lag] > 0 {
+		lag++
+	}
+
+	// only consider lags where at least half of the series overlaps
+	firstLag := lag
+	bestCorrelation := 0.0
+	for ; lag <= numBins/2; lag++ {
+		bestCorrelation = math.Max(bestCorrelation, acf[lag]/acf[0])
+	}
+	if bestCorrelation == 0 {
+		return 0, 0
+	}
+
+	// multiples of the period correlate nearly as well as the period itself, so
+	// take the first peak that comes close to the strongest correlation
+	bestLag := firstLag
+	for bestLag < numBins/2 && acf[bestLag]/acf[0] < 0.9*bestCorrelation {
+		bestLag++
+	}
+	for bestLag < numBins/2 && acf[bestLag+1] > acf[bestLag] {
+		bestLag++
+	}
+	bestCorrelation = acf[bestLag] / acf[0]
+
+	periodicityScore := math.Ceil(bestCorrelation*1000) / 1000
+	if periodicityScore > 1.0 {
+		periodicityScore = 1.0
+	}
+
+	return int64(bestLag) * binWidth, periodicityScore
+}
@@ -6,7 +6,7 @@ import (
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/pkg/uconnproxy"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 type (
@@ -51,8 +51,7 @@ func (d *dissector) close() {
 func (d *dissector) start() {
 	d.dissectWg.Add(1)
 	go func() {
-		ssn := d.db.Session.Copy()
-		defer ssn.Close()
+		ctx := d.db.Context()
 
 		for datum := range d.dissectChannel {
 
@@ -109,7 +108,7 @@ func (d *dissector) start() {
 				TsFull []int64 `bson:"ts_full"`
 			}
 
-			_ = ssn.DB(d.db.GetSelectedDB()).C(d.conf.T.Structure.UniqueConnProxyTable).Pipe(uconnProxyFindQuery).AllowDiskUse().One(&res)
+			_ = database.AggregateOne(ctx, d.db.Collection(d.conf.T.Structure.UniqueConnProxyTable), uconnProxyFindQuery, &res)
 
 			// Check for errors and parse results
 			// this is here because it will still return an empty document even if there are no results
@@ -11,7 +11,6 @@ import (
 	"github.com/activecm/rita/pkg/uconnproxy"
 	"github.com/activecm/rita/util"
 
-	"github.com/globalsign/mgo"
 	"github.com/vbauerster/mpb"
 	"github.com/vbauerster/mpb/decor"
 
@@ -34,14 +33,11 @@ func NewMongoRepository(db *database.DB, conf *config.Config, logger *log.Logger
 }
 
 func (r *repo) CreateIndexes() error {
-	session := r.database.Session.Copy()
-	defer session.Close()
-
 	// set collection name
 	collectionName := r.config.T.BeaconProxy.BeaconProxyTable
 
 	// check if collection already exists
-	names, _ := session.DB(r.database.GetSelectedDB()).CollectionNames()
+	names, _ := r.database.CollectionNames()
 
 	// if collection exists, we don't need to do anything else
 	for _, name := range names {
@@ -51,7 +47,7 @@ func (r *repo) CreateIndexes() error {
 	}
 
 	// set desired indexes
-	indexes := []mgo.Index{
+	indexes := []database.Index{
 		{Key: []string{"-score"}},
 		{Key: []string{"src", "fqdn", "src_network_uuid"}, Unique: true},
 		{Key: []string{"src", "src_network_uuid"}},
@@ -71,11 +67,7 @@ func (r *repo) CreateIndexes() error {
 
 // Upsert derives beacon statistics from the given unique proxy connections and creates
 // summaries for the given local hosts. The results are pushed to MongoDB.
-func (r *repo) Upsert(uconnProxyMap map[string]*uconnproxy.Input, hostMap map[string]*host.Input, minTimestamp, maxTimestamp int64) {
-
-	session := r.database.Session.Copy()
-	defer session.Close()
-
+func (r *repo) Upsert(uconnProxyMap map[string]*uconnproxy.Input, hostMap map[string]*host.Input, minTimestamp, maxTimestamp int64, window database.TimeWindow) {
 	// Create the workers
 
 	// stage 6 - write out results
@@ -91,6 +83,7 @@ func (r *repo) Upsert(uconnProxyMap map[string]*uconnproxy.Input, hostMap map[st
 	analyzerWorker := newAnalyzer(
 		minTimestamp,
 		maxTimestamp,
+		window,
 		r.config.S.Rolling.CurrentChunk,
 		r.database,
 		r.config,
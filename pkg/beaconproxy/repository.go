@@ -1,10 +1,11 @@
 package beaconproxy
 
 import (
+	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/pkg/data"
 	"github.com/activecm/rita/pkg/host"
 	"github.com/activecm/rita/pkg/uconnproxy"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type (
@@ -12,38 +13,42 @@ type (
 	// Repository for host collection
 	Repository interface {
 		CreateIndexes() error
-		Upsert(uconnProxyMap map[string]*uconnproxy.Input, hostMap map[string]*host.Input, minTimestamp, maxTimestamp int64)
+		Upsert(uconnProxyMap map[string]*uconnproxy.Input, hostMap map[string]*host.Input, minTimestamp, maxTimestamp int64, window database.TimeWindow)
 	}
 
 	//TSData ...
 	TSData struct {
-		Score      float64 `bson:"score"`
-		Range      int64   `bson:"range"`
-		Mode       int64   `bson:"mode"`
-		ModeCount  int64   `bson:"mode_count"`
-		Skew       float64 `bson:"skew"`
-		Dispersion int64   `bson:"dispersion"`
+		Score      float64 `bson:"score" json:"score"`
+		Range      int64   `bson:"range" json:"range"`
+		Mode       int64   `bson:"mode" json:"mode"`
+		ModeCount  int64   `bson:"mode_count" json:"mode_count"`
+		Skew       float64 `bson:"skew" json:"skew"`
+		Dispersion int64   `bson:"dispersion" json:"dispersion"`
+		Period     int64   `bson:"period" json:"period"`
+		First      int64   `bson:"first" json:"first"`
+		Last       int64   `bson:"last" json:"last"`
 	}
 
 	//Result represents a beacon proxy between a source IP and
 	// an fqdn.
 	Result struct {
-		FQDN           string        `bson:"fqdn"`
-		SrcIP          string        `bson:"src"`
-		SrcNetworkName string        `bson:"src_network_name"`
-		SrcNetworkUUID bson.Binary   `bson:"src_network_uuid"`
-		Connections    int64         `bson:"connection_count"`
-		Ts             TSData        `bson:"ts"`
-		DurScore       float64       `bson:"duration_score"`
-		HistScore      float64       `bson:"hist_score"`
-		Score          float64       `bson:"score"`
-		Proxy          data.UniqueIP `bson:"proxy"`
+		FQDN             string           `bson:"fqdn" json:"fqdn"`
+		SrcIP            string           `bson:"src" json:"src"`
+		SrcNetworkName   string           `bson:"src_network_name" json:"src_network_name"`
+		SrcNetworkUUID   primitive.Binary `bson:"src_network_uuid" json:"-"`
+		Connections      int64            `bson:"connection_count" json:"connection_count"`
+		Ts               TSData           `bson:"ts" json:"ts"`
+		DurScore         float64          `bson:"duration_score" json:"duration_score"`
+		HistScore        float64          `bson:"hist_score" json:"hist_score"`
+		PeriodicityScore float64          `bson:"periodicity_score" json:"periodicity_score"`
+		Score            float64          `bson:"score" json:"score"`
+		Proxy            data.UniqueIP    `bson:"proxy" json:"proxy"`
 	}
 
 	//StrobeResult represents a unique connection with a large amount
 	//of connections between the hosts
 	StrobeResult struct {
 		data.UniqueSrcFQDNPair `bson:",inline"`
-		ConnectionCount        int64 `bson:"connection_count"`
+		ConnectionCount        int64 `bson:"connection_count" json:"connection_count"`
 	}
 )
@@ -1,20 +1,59 @@
 package beaconproxy
 
 import (
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/filter"
 	"github.com/activecm/rita/resources"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
-//Results finds beacons FQDN in the database greater than a given cutoffScore
-func Results(res *resources.Resources, cutoffScore float64) ([]Result, error) {
-	ssn := res.DB.Session.Copy()
-	defer ssn.Close()
+//beaconProxyFields names the beacon proxy collection fields used for filtering
+var beaconProxyFields = filter.Fields{
+	Src:            "src",
+	SrcNetworkName: "src_network_name",
+	Score:          "score",
+	Conns:          "connection_count",
+	FirstSeen:      "ts.first",
+	LastSeen:       "ts.last",
+	FQDN:           "fqdn",
+}
 
+//Results finds beacons FQDN in the database greater than a given cutoffScore
+//which match the given filter
+func Results(res *resources.Resources, cutoffScore float64, filt filter.Filter) ([]Result, error) {
 	var beaconsProxy []Result
 
-	BeaconProxyQuery := bson.M{"score": bson.M{"$gt": cutoffScore}}
+	beaconProxyQuery, err := ResultsQuery(res, cutoffScore, filt)
+	if err != nil {
+		return beaconsProxy, err
+	}
 
-	err := ssn.DB(res.DB.GetSelectedDB()).C(res.Config.T.BeaconProxy.BeaconProxyTable).Find(BeaconProxyQuery).Sort("-score").All(&beaconsProxy)
+	err = database.AggregateAll(res.DB.Context(), res.DB.Collection(res.Config.T.BeaconProxy.BeaconProxyTable), beaconProxyQuery, &beaconsProxy)
 
 	return beaconsProxy, err
 }
+
+//ResultsQuery builds the aggregation pipeline used by Results, sorted by score
+func ResultsQuery(res *resources.Resources, cutoffScore float64, filt filter.Filter) ([]bson.M, error) {
+	filterPredicate, err := filt.Predicate(beaconProxyFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// the time window can't be applied to results analyzed by earlier versions
+	collection := res.DB.Collection(res.Config.T.BeaconProxy.BeaconProxyTable)
+	err = filt.CheckTimeFields(res.DB.Context(), collection, beaconProxyFields)
+	if err != nil {
+		return nil, err
+	}
+
+	beaconProxyQuery := []bson.M{
+		{"$match": bson.M{"$and": []bson.M{
+			{"score": bson.M{"$gt": cutoffScore}},
+			filterPredicate,
+		}}},
+		{"$sort": bson.M{"score": -1}},
+	}
+
+	return beaconProxyQuery, nil
+}
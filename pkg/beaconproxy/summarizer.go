@@ -1,14 +1,15 @@
 package beaconproxy
 
 import (
+	"context"
 	"sync"
 
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/pkg/data"
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/bson"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type (
@@ -55,18 +56,17 @@ func (s *summarizer) start() {
 	s.summaryWg.Add(1)
 	go func() {
 
-		ssn := s.db.Session.Copy()
-		defer ssn.Close()
+		ctx := s.db.Context()
 
 		for datum := range s.summaryChannel {
-			proxyBeaconCollection := ssn.DB(s.db.GetSelectedDB()).C(s.conf.T.BeaconProxy.BeaconProxyTable)
-			hostCollection := ssn.DB(s.db.GetSelectedDB()).C(s.conf.T.Structure.HostTable)
+			proxyBeaconCollection := s.db.Collection(s.conf.T.BeaconProxy.BeaconProxyTable)
+			hostCollection := s.db.Collection(s.conf.T.Structure.HostTable)
 
-			maxProxyBeaconSelector, maxProxyBeaconQuery, err := maxProxyBeaconUpdate(
+			maxProxyBeaconSelector, maxProxyBeaconQuery, err := maxProxyBeaconUpdate(ctx,
 				datum, proxyBeaconCollection, hostCollection, s.chunk,
 			)
 			if err != nil {
-				if err != mgo.ErrNotFound {
+				if err != mongo.ErrNoDocuments {
 					s.log.WithFields(log.Fields{
 						"Module": "beaconsProxy",
 						"Data":   datum,
@@ -90,7 +90,7 @@ func (s *summarizer) start() {
 }
 
 // maxProxyBeaconUpdate finds the highest scoring proxy beacon from this import session for a particular host
-func maxProxyBeaconUpdate(datum data.UniqueIP, beaconProxyColl, hostColl *mgo.Collection, chunk int) (bson.M, bson.M, error) {
+func maxProxyBeaconUpdate(ctx context.Context, datum data.UniqueIP, beaconProxyColl, hostColl *mongo.Collection, chunk int) (bson.M, bson.M, error) {
 
 	var maxBeaconProxy struct {
 		Fqdn  string  `bson:"fqdn"`
@@ -98,7 +98,7 @@ func maxProxyBeaconUpdate(datum data.UniqueIP, beaconProxyColl, hostColl *mgo.Co
 	}
 
 	mbdstQuery := maxProxyBeaconPipeline(datum)
-	err := beaconProxyColl.Pipe(mbdstQuery).One(&maxBeaconProxy)
+	err := database.AggregateOne(ctx, beaconProxyColl, mbdstQuery, &maxBeaconProxy)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -109,19 +109,16 @@ func maxProxyBeaconUpdate(datum data.UniqueIP, beaconProxyColl, hostColl *mgo.Co
 		bson.M{"dat": bson.M{"$elemMatch": bson.M{"mbproxy": bson.M{"$exists": true}}}},
 	)
 
-	nExistingEntries, err := hostColl.Find(hostWithDatEntrySelector).Count()
+	existingEntry, err := database.FindDatEntry(ctx, hostColl, hostWithDatEntrySelector)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	if nExistingEntries > 0 {
-		updateQuery := bson.M{
-			"$set": bson.M{
-				"dat.$.mbproxy":                maxBeaconProxy.Fqdn,
-				"dat.$.max_beacon_proxy_score": maxBeaconProxy.Score,
-				"dat.$.cid":                    chunk,
-			},
-		}
+	if existingEntry != nil {
+		updateQuery := database.MoveDatEntry(existingEntry, bson.M{
+			"mbproxy":                maxBeaconProxy.Fqdn,
+			"max_beacon_proxy_score": maxBeaconProxy.Score,
+		}, chunk)
 		return hostWithDatEntrySelector, updateQuery, nil
 	}
 
@@ -6,7 +6,7 @@ import (
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/pkg/data"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 type (
@@ -52,8 +52,7 @@ func (d *dissector) close() {
 func (d *dissector) start() {
 	d.dissectWg.Add(1)
 	go func() {
-		ssn := d.db.Session.Copy()
-		defer ssn.Close()
+		ctx := d.db.Context()
 
 		for datum := range d.dissectChannel {
 
@@ -177,7 +176,7 @@ func (d *dissector) start() {
 				RespondingIPs []data.UniqueIP `bson:"responding_ips"`
 			}
 
-			_ = ssn.DB(d.db.GetSelectedDB()).C(d.conf.T.Structure.SNIConnTable).Pipe(sniconnFindQuery).AllowDiskUse().One(&res)
+			_ = database.AggregateOne(ctx, d.db.Collection(d.conf.T.Structure.SNIConnTable), sniconnFindQuery, &res)
 
 			// Check for errors and parse results
 			// this is here because it will still return an empty document even if there are no results
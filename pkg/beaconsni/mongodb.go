@@ -10,7 +10,6 @@ import (
 	"github.com/activecm/rita/pkg/host"
 	"github.com/activecm/rita/pkg/sniconn"
 	"github.com/activecm/rita/util"
-	"github.com/globalsign/mgo"
 	"github.com/vbauerster/mpb"
 	"github.com/vbauerster/mpb/decor"
 
@@ -34,15 +33,11 @@ func NewMongoRepository(db *database.DB, conf *config.Config, logger *log.Logger
 
 // CreateIndexes creates indexes for the beaconSNI collection
 func (r *repo) CreateIndexes() error {
-
-	session := r.database.Session.Copy()
-	defer session.Close()
-
 	// set collection name
 	collectionName := r.config.T.BeaconSNI.BeaconSNITable
 
 	// check if collection already exists
-	names, _ := session.DB(r.database.GetSelectedDB()).CollectionNames()
+	names, _ := r.database.CollectionNames()
 
 	// if collection exists, we don't need to do anything else
 	for _, name := range names {
@@ -52,7 +47,7 @@ func (r *repo) CreateIndexes() error {
 	}
 
 	// set desired indexes
-	indexes := []mgo.Index{
+	indexes := []database.Index{
 		{Key: []string{"-score"}},
 		{Key: []string{"src", "fqdn", "src_network_uuid"}, Unique: true},
 		{Key: []string{"src", "src_network_uuid"}},
@@ -72,7 +67,7 @@ func (r *repo) CreateIndexes() error {
 
 // Upsert calculates beacon statistics given SNI connection data in MongoDB. Summaries are
 // created for the given local hosts in MongoDB.
-func (r *repo) Upsert(tlsMap map[string]*sniconn.TLSInput, httpMap map[string]*sniconn.HTTPInput, hostMap map[string]*host.Input, minTimestamp, maxTimestamp int64) {
+func (r *repo) Upsert(tlsMap map[string]*sniconn.TLSInput, httpMap map[string]*sniconn.HTTPInput, hostMap map[string]*host.Input, minTimestamp, maxTimestamp int64, window database.TimeWindow) {
 	selectors := make(map[string]data.UniqueSrcFQDNPair)
 	for tlsKey, tlsValue := range tlsMap {
 		selectors[tlsKey] = tlsValue.Hosts
@@ -94,6 +89,7 @@ func (r *repo) Upsert(tlsMap map[string]*sniconn.TLSInput, httpMap map[string]*s
 	analyzerWorker := newAnalyzer(
 		minTimestamp,
 		maxTimestamp,
+		window,
 		r.config.S.Rolling.CurrentChunk,
 		r.database,
 		r.config,
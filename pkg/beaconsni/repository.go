@@ -1,6 +1,7 @@
 package beaconsni
 
 import (
+	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/pkg/data"
 	"github.com/activecm/rita/pkg/host"
 	"github.com/activecm/rita/pkg/sniconn"
@@ -9,7 +10,7 @@ import (
 // Repository for beaconsni collection
 type Repository interface {
 	CreateIndexes() error
-	Upsert(tlsMap map[string]*sniconn.TLSInput, httpMap map[string]*sniconn.HTTPInput, hostMap map[string]*host.Input, minTimestamp, maxTimestamp int64)
+	Upsert(tlsMap map[string]*sniconn.TLSInput, httpMap map[string]*sniconn.HTTPInput, hostMap map[string]*host.Input, minTimestamp, maxTimestamp int64, window database.TimeWindow)
 }
 
 type dissectorResults struct {
@@ -27,34 +28,38 @@ type dissectorResults struct {
 // Contains information on connection delta times and the amount of data transferred
 type Result struct {
 	data.UniqueSrcFQDNPair `bson:",inline"`
-	Connections            int64   `bson:"connection_count"`
-	AvgBytes               float64 `bson:"avg_bytes"`
-	TotalBytes             int64   `bson:"total_bytes"`
-	Ts                     TSData  `bson:"ts"`
-	Ds                     DSData  `bson:"ds"`
-	DurScore               float64 `bson:"duration_score"`
-	HistScore              float64 `bson:"hist_score"`
-	Score                  float64 `bson:"score"`
+	Connections            int64   `bson:"connection_count" json:"connection_count"`
+	AvgBytes               float64 `bson:"avg_bytes" json:"avg_bytes"`
+	TotalBytes             int64   `bson:"total_bytes" json:"total_bytes"`
+	Ts                     TSData  `bson:"ts" json:"ts"`
+	Ds                     DSData  `bson:"ds" json:"ds"`
+	DurScore               float64 `bson:"duration_score" json:"duration_score"`
+	HistScore              float64 `bson:"hist_score" json:"hist_score"`
+	PeriodicityScore       float64 `bson:"periodicity_score" json:"periodicity_score"`
+	Score                  float64 `bson:"score" json:"score"`
 	// ResolvedIPs            []data.UniqueIP // Requires lookup on SNIconn collection
 }
 
 // TSData ...
 type TSData struct {
-	Score      float64 `bson:"score"`
-	Range      int64   `bson:"range"`
-	Mode       int64   `bson:"mode"`
-	ModeCount  int64   `bson:"mode_count"`
-	Skew       float64 `bson:"skew"`
-	Dispersion int64   `bson:"dispersion"`
-	Duration   float64 `bson:"duration"`
+	Score      float64 `bson:"score" json:"score"`
+	Range      int64   `bson:"range" json:"range"`
+	Mode       int64   `bson:"mode" json:"mode"`
+	ModeCount  int64   `bson:"mode_count" json:"mode_count"`
+	Skew       float64 `bson:"skew" json:"skew"`
+	Dispersion int64   `bson:"dispersion" json:"dispersion"`
+	Period     int64   `bson:"period" json:"period"`
+	First      int64   `bson:"first" json:"first"`
+	Last       int64   `bson:"last" json:"last"`
+	Duration   float64 `bson:"duration" json:"duration"`
 }
 
 // DSData ...
 type DSData struct {
-	Score      float64 `bson:"score"`
-	Skew       float64 `bson:"skew"`
-	Dispersion int64   `bson:"dispersion"`
-	Range      int64   `bson:"range"`
-	Mode       int64   `bson:"mode"`
-	ModeCount  int64   `bson:"mode_count"`
+	Score      float64 `bson:"score" json:"score"`
+	Skew       float64 `bson:"skew" json:"skew"`
+	Dispersion int64   `bson:"dispersion" json:"dispersion"`
+	Range      int64   `bson:"range" json:"range"`
+	Mode       int64   `bson:"mode" json:"mode"`
+	ModeCount  int64   `bson:"mode_count" json:"mode_count"`
 }
@@ -1,23 +1,59 @@
 package beaconsni
 
 import (
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/filter"
 	"github.com/activecm/rita/resources"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
-//Results finds SNI beacons in the database greater than a given cutoffScore
-func Results(res *resources.Resources, cutoffScore float64) ([]Result, error) {
-	ssn := res.DB.Session.Copy()
-	defer ssn.Close()
+//beaconSNIFields names the beacon SNI collection fields used for filtering
+var beaconSNIFields = filter.Fields{
+	Src:            "src",
+	SrcNetworkName: "src_network_name",
+	Score:          "score",
+	Conns:          "connection_count",
+	FirstSeen:      "ts.first",
+	LastSeen:       "ts.last",
+	FQDN:           "fqdn",
+}
 
+//Results finds SNI beacons in the database greater than a given cutoffScore
+//which match the given filter
+func Results(res *resources.Resources, cutoffScore float64, filt filter.Filter) ([]Result, error) {
 	var beaconsSNI []Result
 
-	beaconSNIQuery := bson.M{"score": bson.M{"$gt": cutoffScore}}
-
-	err := ssn.DB(res.DB.GetSelectedDB()).C(res.Config.T.BeaconSNI.BeaconSNITable).Find(beaconSNIQuery).Sort("-score").All(&beaconsSNI)
+	beaconSNIQuery, err := ResultsQuery(res, cutoffScore, filt)
 	if err != nil {
 		return beaconsSNI, err
 	}
 
+	err = database.AggregateAll(res.DB.Context(), res.DB.Collection(res.Config.T.BeaconSNI.BeaconSNITable), beaconSNIQuery, &beaconsSNI)
+
 	return beaconsSNI, err
 }
+
+//ResultsQuery builds the aggregation pipeline used by Results, sorted by score
+func ResultsQuery(res *resources.Resources, cutoffScore float64, filt filter.Filter) ([]bson.M, error) {
+	filterPredicate, err := filt.Predicate(beaconSNIFields)
+	if err != nil {
+		return nil, err
+	}
+
+	// the time window can't be applied to results analyzed by earlier versions
+	collection := res.DB.Collection(res.Config.T.BeaconSNI.BeaconSNITable)
+	err = filt.CheckTimeFields(res.DB.Context(), collection, beaconSNIFields)
+	if err != nil {
+		return nil, err
+	}
+
+	beaconSNIQuery := []bson.M{
+		{"$match": bson.M{"$and": []bson.M{
+			{"score": bson.M{"$gt": cutoffScore}},
+			filterPredicate,
+		}}},
+		{"$sort": bson.M{"score": -1}},
+	}
+
+	return beaconSNIQuery, nil
+}
@@ -1,14 +1,15 @@
 package beaconsni
 
 import (
+	"context"
 	"sync"
 
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/pkg/data"
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/bson"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type (
@@ -55,18 +56,17 @@ func (s *summarizer) start() {
 	s.summaryWg.Add(1)
 	go func() {
 
-		ssn := s.db.Session.Copy()
-		defer ssn.Close()
+		ctx := s.db.Context()
 
 		for datum := range s.summaryChannel {
-			beaconSNICollection := ssn.DB(s.db.GetSelectedDB()).C(s.conf.T.BeaconSNI.BeaconSNITable)
-			hostCollection := ssn.DB(s.db.GetSelectedDB()).C(s.conf.T.Structure.HostTable)
+			beaconSNICollection := s.db.Collection(s.conf.T.BeaconSNI.BeaconSNITable)
+			hostCollection := s.db.Collection(s.conf.T.Structure.HostTable)
 
-			maxSNIBeaconSelector, maxSNIBeaconQuery, err := maxSNIBeaconUpdate(
+			maxSNIBeaconSelector, maxSNIBeaconQuery, err := maxSNIBeaconUpdate(ctx,
 				datum, beaconSNICollection, hostCollection, s.chunk,
 			)
 			if err != nil {
-				if err != mgo.ErrNotFound {
+				if err != mongo.ErrNoDocuments {
 					s.log.WithFields(log.Fields{
 						"Module": "beaconSNI",
 						"Data":   datum,
@@ -90,7 +90,7 @@ func (s *summarizer) start() {
 }
 
 // maxSNIBeaconUpdate finds the highest scoring sni beacon from this import session for a particular host
-func maxSNIBeaconUpdate(datum data.UniqueIP, beaconSNIColl, hostColl *mgo.Collection, chunk int) (bson.M, bson.M, error) {
+func maxSNIBeaconUpdate(ctx context.Context, datum data.UniqueIP, beaconSNIColl, hostColl *mongo.Collection, chunk int) (bson.M, bson.M, error) {
 
 	var maxBeaconSNI struct {
 		Fqdn  string  `bson:"fqdn"`
@@ -98,7 +98,7 @@ func maxSNIBeaconUpdate(datum data.UniqueIP, beaconSNIColl, hostColl *mgo.Collec
 	}
 
 	mbdstQuery := maxSNIBeaconPipeline(datum)
-	err := beaconSNIColl.Pipe(mbdstQuery).One(&maxBeaconSNI)
+	err := database.AggregateOne(ctx, beaconSNIColl, mbdstQuery, &maxBeaconSNI)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -109,19 +109,16 @@ func maxSNIBeaconUpdate(datum data.UniqueIP, beaconSNIColl, hostColl *mgo.Collec
 		bson.M{"dat": bson.M{"$elemMatch": bson.M{"mbsni": bson.M{"$exists": true}}}},
 	)
 
-	nExistingEntries, err := hostColl.Find(hostWithDatEntrySelector).Count()
+	existingEntry, err := database.FindDatEntry(ctx, hostColl, hostWithDatEntrySelector)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	if nExistingEntries > 0 {
-		updateQuery := bson.M{
-			"$set": bson.M{
-				"dat.$.mbsni":                maxBeaconSNI.Fqdn,
-				"dat.$.max_beacon_sni_score": maxBeaconSNI.Score,
-				"dat.$.cid":                  chunk,
-			},
-		}
+	if existingEntry != nil {
+		updateQuery := database.MoveDatEntry(existingEntry, bson.M{
+			"mbsni":                maxBeaconSNI.Fqdn,
+			"max_beacon_sni_score": maxBeaconSNI.Score,
+		}, chunk)
 		return hostWithDatEntrySelector, updateQuery, nil
 	}
 
@@ -1,14 +1,15 @@
 package blacklist
 
 import (
+	"context"
 	"sync"
 
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/pkg/data"
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/bson"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type (
@@ -54,8 +55,7 @@ func (a *analyzer) close() {
 func (a *analyzer) start() {
 	a.analysisWg.Add(1)
 	go func() {
-		ssn := a.db.Session.Copy()
-		defer ssn.Close()
+		ctx := a.db.Context()
 
 		for blacklistedIP := range a.analysisChannel {
 			blDstUconns, err := a.getUniqueConnsforBLDestination(blacklistedIP)
@@ -74,8 +74,8 @@ func (a *analyzer) start() {
 			}
 
 			for _, blUconnData := range blDstUconns { // update sources which contacted the blacklisted destination
-				blDstForSrcExists, err := blHostRecordExists(
-					ssn.DB(a.db.GetSelectedDB()).C(a.conf.T.Structure.HostTable), blUconnData.Host, blacklistedIP,
+				blDstForSrcRecord, err := blHostRecord(
+					ctx, a.db.Collection(a.conf.T.Structure.HostTable), blUconnData.Host, blacklistedIP,
 				)
 				if err != nil {
 					a.log.WithFields(log.Fields{
@@ -84,14 +84,14 @@ func (a *analyzer) start() {
 					}).Error(err)
 				}
 				srcHostUpdate := appendBlacklistedDstQuery(
-					a.chunk, blacklistedIP, blUconnData, blDstForSrcExists,
+					a.chunk, blacklistedIP, blUconnData, blDstForSrcRecord,
 				)
 
 				a.analyzedCallback(database.BulkChanges{a.conf.T.Structure.HostTable: []database.BulkChange{srcHostUpdate}})
 			}
 			for _, blUconnData := range blSrcUconns { // update destinations which were contacted by the blacklisted source
-				blSrcForDstExists, err := blHostRecordExists(
-					ssn.DB(a.db.GetSelectedDB()).C(a.conf.T.Structure.HostTable), blUconnData.Host, blacklistedIP,
+				blSrcForDstRecord, err := blHostRecord(
+					ctx, a.db.Collection(a.conf.T.Structure.HostTable), blUconnData.Host, blacklistedIP,
 				)
 				if err != nil {
 					a.log.WithFields(log.Fields{
@@ -101,7 +101,7 @@ func (a *analyzer) start() {
 				}
 
 				newBLSrcForDstUpdate := appendBlacklistedSrcQuery(
-					a.chunk, blacklistedIP, blUconnData, blSrcForDstExists,
+					a.chunk, blacklistedIP, blUconnData, blSrcForDstRecord,
 				)
 				a.analyzedCallback(database.BulkChanges{a.conf.T.Structure.HostTable: []database.BulkChange{newBLSrcForDstUpdate}})
 			}
@@ -112,25 +112,24 @@ func (a *analyzer) start() {
 	}()
 }
 
-// blHostRecordExists checks if a the hostEntryIP has previously been marked as the peer of the given blacklistedIP
-func blHostRecordExists(hostCollection *mgo.Collection, hostEntryIP, blacklistedIP data.UniqueIP) (bool, error) {
+// blHostRecord returns the record marking the hostEntryIP as the peer of the given blacklistedIP,
+// or nil if the hostEntryIP has not previously been marked
+func blHostRecord(ctx context.Context, hostCollection *mongo.Collection, hostEntryIP, blacklistedIP data.UniqueIP) (bson.Raw, error) {
 	entryKey := hostEntryIP.BSONKey()
 	entryKey["dat"] = bson.M{"$elemMatch": blacklistedIP.PrefixedBSONKey("bl")}
 
-	nExistingEntries, err := hostCollection.Find(entryKey).Count()
-
-	return nExistingEntries != 0, err
+	return database.FindDatEntry(ctx, hostCollection, entryKey)
 }
 
 // appendBlacklistedDstQuery adds a blacklist record to a host which contacted by a blacklisted destination
-func appendBlacklistedDstQuery(chunk int, blacklistedDst data.UniqueIP, srcConnData connectionPeer, existsFlag bool) database.BulkChange {
+func appendBlacklistedDstQuery(chunk int, blacklistedDst data.UniqueIP, srcConnData connectionPeer, existingRecord bson.Raw) database.BulkChange {
 	var output database.BulkChange
 	output.Upsert = true
 
 	// create query
 	query := bson.M{}
 
-	if !existsFlag {
+	if existingRecord == nil {
 
 		query["$push"] = bson.M{
 			"dat": bson.M{
@@ -147,13 +146,11 @@ func appendBlacklistedDstQuery(chunk int, blacklistedDst data.UniqueIP, srcConnD
 
 	} else {
 
-		query["$set"] = bson.M{
-			"dat.$.bl_conn_count":  srcConnData.Connections,
-			"dat.$.bl_total_bytes": srcConnData.TotalBytes,
-			"dat.$.bl_out_count":   1,
-			"dat.$.cid":            chunk,
-		}
-		output.Update = query
+		output.Update = database.MoveDatEntry(existingRecord, bson.M{
+			"bl_conn_count":  srcConnData.Connections,
+			"bl_total_bytes": srcConnData.TotalBytes,
+			"bl_out_count":   1,
+		}, chunk)
 
 		// create selector for output
 		output.Selector = database.MergeBSONMaps(
@@ -166,14 +163,14 @@ func appendBlacklistedDstQuery(chunk int, blacklistedDst data.UniqueIP, srcConnD
 }
 
 // appendBlacklistedSrcQuery adds a blacklist record to a host which was contacted by a blacklisted source
-func appendBlacklistedSrcQuery(chunk int, blacklistedSrc data.UniqueIP, dstConnData connectionPeer, existsFlag bool) database.BulkChange {
+func appendBlacklistedSrcQuery(chunk int, blacklistedSrc data.UniqueIP, dstConnData connectionPeer, existingRecord bson.Raw) database.BulkChange {
 	var output database.BulkChange
 	output.Upsert = true
 
 	// create query
 	query := bson.M{}
 
-	if !existsFlag {
+	if existingRecord == nil {
 
 		query["$push"] = bson.M{
 			"dat": bson.M{
@@ -190,13 +187,11 @@ func appendBlacklistedSrcQuery(chunk int, blacklistedSrc data.UniqueIP, dstConnD
 
 	} else {
 
-		query["$set"] = bson.M{
-			"dat.$.bl_conn_count":  dstConnData.Connections,
-			"dat.$.bl_total_bytes": dstConnData.TotalBytes,
-			"dat.$.bl_in_count":    1,
-			"dat.$.cid":            chunk,
-		}
-		output.Update = query
+		output.Update = database.MoveDatEntry(existingRecord, bson.M{
+			"bl_conn_count":  dstConnData.Connections,
+			"bl_total_bytes": dstConnData.TotalBytes,
+			"bl_in_count":    1,
+		}, chunk)
 
 		// create selector for output
 		output.Selector = database.MergeBSONMaps(
@@ -211,8 +206,7 @@ func appendBlacklistedSrcQuery(chunk int, blacklistedSrc data.UniqueIP, dstConnD
 // getUniqueConnsforBLDestination returns the IP addresses that contacted a given blacklisted IP along with the number
 // of connections and bytes sent
 func (a *analyzer) getUniqueConnsforBLDestination(blDestinationIP data.UniqueIP) ([]connectionPeer, error) {
-	ssn := a.db.Session.Copy()
-	defer ssn.Close()
+	ctx := a.db.Context()
 
 	var blIPs []connectionPeer
 
@@ -245,10 +239,7 @@ func (a *analyzer) getUniqueConnsforBLDestination(blDestinationIP data.UniqueIP)
 		}},
 	}
 
-	err := ssn.DB(a.db.GetSelectedDB()).C(a.conf.T.Structure.UniqueConnTable).Pipe(blIPQuery).AllowDiskUse().All(&blIPs)
-	if err == mgo.ErrNotFound {
-		err = nil
-	}
+	err := database.AggregateAll(ctx, a.db.Collection(a.conf.T.Structure.UniqueConnTable), blIPQuery, &blIPs)
 
 	return blIPs, err
 }
@@ -256,8 +247,7 @@ func (a *analyzer) getUniqueConnsforBLDestination(blDestinationIP data.UniqueIP)
 // getUniqueConnsforBLSource returns the IP addresses that a given blacklisted IP contacted along with the number
 // of connections and bytes sent
 func (a *analyzer) getUniqueConnsforBLSource(blSourceIP data.UniqueIP) ([]connectionPeer, error) {
-	ssn := a.db.Session.Copy()
-	defer ssn.Close()
+	ctx := a.db.Context()
 
 	var blIPs []connectionPeer
 
@@ -284,10 +274,7 @@ func (a *analyzer) getUniqueConnsforBLSource(blSourceIP data.UniqueIP) ([]connec
 		}},
 	}
 
-	err := ssn.DB(a.db.GetSelectedDB()).C(a.conf.T.Structure.UniqueConnTable).Pipe(blIPQuery).AllowDiskUse().All(&blIPs)
-	if err == mgo.ErrNotFound {
-		err = nil
-	}
+	err := database.AggregateAll(ctx, a.db.Collection(a.conf.T.Structure.UniqueConnTable), blIPQuery, &blIPs)
 
 	return blIPs, err
 }
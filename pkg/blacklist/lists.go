@@ -0,0 +1,168 @@
+package blacklist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	//ipEntryType is the entry type of blacklists holding IP addresses
+	ipEntryType entryType = "ip"
+	//hostnameEntryType is the entry type of blacklists holding hostnames
+	hostnameEntryType entryType = "hostname"
+
+	//feodoURL serves the IP addresses of the botnet C2 servers tracked by Feodo Tracker
+	feodoURL = "https://feodotracker.abuse.ch/downloads/ipblocklist.txt"
+
+	//insertBatchSize is the number of entries inserted into the reference collections at once
+	insertBatchSize = 100000
+
+	//fetchTimeout bounds how long downloading a blacklist may take
+	fetchTimeout = 5 * time.Minute
+)
+
+//fetchClient downloads the blacklists served over HTTP
+var fetchClient = &http.Client{Timeout: fetchTimeout}
+
+//entryValidators checks the entries of each entry type before they are stored
+var entryValidators = map[entryType]func(string) error{
+	ipEntryType:       validateIP,
+	hostnameEntryType: validateHostname,
+}
+
+//sourceList is a blacklist holding one entry per line. Empty lines and lines
+//starting with # are skipped.
+type sourceList struct {
+	meta listMetadata
+	open func() (io.ReadCloser, error)
+}
+
+//newLineSeparatedList creates a blacklist of the given entry type which is read with open.
+//The entries are fetched again once cacheTime seconds have passed since the last fetch.
+func newLineSeparatedList(entries entryType, name string, cacheTime int64,
+	open func() (io.ReadCloser, error)) *sourceList {
+	return &sourceList{
+		meta: listMetadata{
+			Name:      name,
+			Types:     []entryType{entries},
+			CacheTime: cacheTime,
+		},
+		open: open,
+	}
+}
+
+//newFeodoList creates the Feodo Tracker blacklist, which is fetched once a day
+func newFeodoList() *sourceList {
+	return newLineSeparatedList(ipEntryType, "feodo tracker", 86400, func() (io.ReadCloser, error) {
+		return fetchURL(feodoURL)
+	})
+}
+
+//fetchURL downloads a blacklist. An error is returned if the server does not
+//respond successfully, so that an error page is not read as a blacklist.
+func fetchURL(url string) (io.ReadCloser, error) {
+	resp, err := fetchClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("could not download blacklist %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+//shouldFetch returns true if the cached entries of the list have expired
+func (l *sourceList) shouldFetch() bool {
+	return time.Now().Unix() >= l.meta.LastUpdate+l.meta.CacheTime
+}
+
+//fetch reads the entries of the list and passes them to insert in batches of batchSize.
+//Entries which are not valid for the list's entry type are reported to handleErr.
+func (l *sourceList) fetch(batchSize int, insert func(entryType, []interface{}) error, handleErr func(error)) error {
+	reader, err := l.open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	entries := l.meta.Types[0]
+	validate := entryValidators[entries]
+	batch := make([]interface{}, 0, batchSize)
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		if err := validate(line); err != nil {
+			handleErr(fmt.Errorf("invalid %s %q in blacklist %s: %v", entries, line, l.meta.Name, err))
+			continue
+		}
+
+		batch = append(batch, referenceEntry{
+			Index:     line,
+			List:      l.meta.Name,
+			ExtraData: make(map[string]interface{}),
+		})
+		if len(batch) == batchSize {
+			if err := insert(entries, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(batch) != 0 {
+		return insert(entries, batch)
+	}
+	return nil
+}
+
+//validateIP checks that an entry holds an IP address
+func validateIP(ip string) error {
+	if net.ParseIP(ip) == nil {
+		return errors.New("failed to parse ip address")
+	}
+	return nil
+}
+
+//validateHostname checks that an entry holds a hostname made up of valid labels
+func validateHostname(hostname string) error {
+	if len(hostname) > 253 || len(hostname) < 1 {
+		return errors.New("hostnames must be less than 254 characters long")
+	}
+
+	specialCharIdx := strings.IndexFunc(hostname, func(r rune) bool {
+		return !((r >= 'A' && r <= 'Z') ||
+			(r >= 'a' && r <= 'z') ||
+			(r >= '0' && r <= '9') ||
+			r == '-' || r == '.' || r == '_')
+	})
+	if specialCharIdx != -1 {
+		return fmt.Errorf("invalid char %c found in hostname label", hostname[specialCharIdx])
+	}
+
+	for _, label := range strings.Split(hostname, ".") {
+		if len(label) > 63 || len(label) < 1 {
+			return errors.New("hostname labels must be between 1 and 63 characters long")
+		}
+		if label[0] == '-' {
+			return errors.New("hostnames labels must not start with a minus sign")
+		}
+		if label[len(label)-1] == '-' {
+			return errors.New("hostname labels must not end with a minus sign")
+		}
+	}
+	return nil
+}
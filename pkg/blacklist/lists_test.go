@@ -0,0 +1,70 @@
+package blacklist
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchLineSeparatedList(t *testing.T) {
+	source := newLineSeparatedList(ipEntryType, "test", 0, func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("192.168.0.1\n\n#127.0.0.1\n10.10.10.10\nnot-an-ip\n10.10.10.11\n")), nil
+	})
+	assert.True(t, source.shouldFetch())
+
+	var batches [][]string
+	var errs []error
+	err := source.fetch(2, func(entries entryType, batch []interface{}) error {
+		assert.Equal(t, ipEntryType, entries)
+		var indexes []string
+		for _, entry := range batch {
+			assert.Equal(t, "test", entry.(referenceEntry).List)
+			indexes = append(indexes, entry.(referenceEntry).Index)
+		}
+		batches = append(batches, indexes)
+		return nil
+	}, func(err error) { errs = append(errs, err) })
+	require.NoError(t, err)
+
+	// comments and blank lines are skipped and invalid entries are reported
+	assert.Equal(t, [][]string{{"192.168.0.1", "10.10.10.10"}, {"10.10.10.11"}}, batches)
+	assert.Len(t, errs, 1)
+}
+
+func TestValidateHostname(t *testing.T) {
+	assert.NoError(t, validateHostname("evil-domain.example.com"))
+	assert.NoError(t, validateHostname("_dmarc.example.com"))
+	assert.Error(t, validateHostname(""))
+	assert.Error(t, validateHostname("bad..example.com"))
+	assert.Error(t, validateHostname("-bad.example.com"))
+	assert.Error(t, validateHostname("bad/path.example.com"))
+	assert.Error(t, validateHostname(strings.Repeat("a", 64)+".com"))
+}
+
+func TestFetchURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/list.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("10.10.10.10\n"))
+	}))
+	defer server.Close()
+
+	reader, err := fetchURL(server.URL + "/list.txt")
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(reader)
+	reader.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "10.10.10.10\n", string(contents))
+
+	// error pages are not read as blacklists
+	_, err = fetchURL(server.URL + "/missing.txt")
+	assert.Error(t, err)
+}
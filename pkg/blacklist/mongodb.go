@@ -10,8 +10,7 @@ import (
 	"github.com/vbauerster/mpb"
 	"github.com/vbauerster/mpb/decor"
 
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -33,24 +32,15 @@ func NewMongoRepository(db *database.DB, conf *config.Config, logger *log.Logger
 
 // CreateIndexes sets up the indices needed to find hosts which contacted unsafe hosts
 func (r *repo) CreateIndexes() error {
-	session := r.database.Session.Copy()
-	defer session.Close()
-
-	coll := session.DB(r.database.GetSelectedDB()).C(r.config.T.Structure.HostTable)
+	coll := r.database.Collection(r.config.T.Structure.HostTable)
 
 	// create hosts collection
 	// Desired indexes
-	indexes := []mgo.Index{
+	indexes := []database.Index{
 		{Key: []string{"dat.bl.ip", "dat.bl.network_uuid"}},
 	}
 
-	for _, index := range indexes {
-		err := coll.EnsureIndex(index)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return database.EnsureIndexes(r.database.Context(), coll, indexes)
 }
 
 // Upsert creates threat intel records in the host collection for the hosts which
@@ -82,12 +72,11 @@ func (r *repo) Upsert() {
 	// NOTE: we cannot use the (hostMap map[string]*host.Input)
 	// since we are creating peer statistic summaries for the entire
 	// observation period not just this import session
-	session := r.database.Session.Copy()
-	defer session.Close()
+	ctx := r.database.Context()
+	hostColl := r.database.Collection(r.config.T.Structure.HostTable)
+	unsafeHostsQuery := bson.M{"blacklisted": true}
 
-	unsafeHostsQuery := session.DB(r.database.GetSelectedDB()).C(r.config.T.Structure.HostTable).Find(bson.M{"blacklisted": true})
-
-	numUnsafeHosts, err := unsafeHostsQuery.Count()
+	numUnsafeHosts, err := hostColl.CountDocuments(ctx, unsafeHostsQuery)
 	if err != nil {
 		r.log.WithFields(log.Fields{
 			"Module": "bl_updater",
@@ -108,13 +97,27 @@ func (r *repo) Upsert() {
 		mpb.AppendDecorators(decor.Percentage()),
 	)
 
-	var unsafeHost data.UniqueIP
-	unsafeHostIter := unsafeHostsQuery.Iter()
-	for unsafeHostIter.Next(&unsafeHost) {
+	unsafeHostIter, err := hostColl.Find(ctx, unsafeHostsQuery)
+	if err != nil {
+		r.log.WithFields(log.Fields{
+			"Module": "bl_updater",
+		}).Error(err)
+		p.Wait()
+		return
+	}
+
+	for unsafeHostIter.Next(ctx) {
+		var unsafeHost data.UniqueIP
+		if err := unsafeHostIter.Decode(&unsafeHost); err != nil {
+			r.log.WithFields(log.Fields{
+				"Module": "bl_updater",
+			}).Error(err)
+			continue
+		}
 		analyzerWorker.collect(unsafeHost)
 		bar.IncrBy(1)
 	}
-	if err := unsafeHostIter.Close(); err != nil {
+	if err := unsafeHostIter.Close(ctx); err != nil {
 		r.log.WithFields(log.Fields{
 			"Module": "bl_updater",
 		}).Error(err)
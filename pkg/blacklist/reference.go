@@ -0,0 +1,154 @@
+package blacklist
+
+import (
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/util"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const listsCollection string = "lists"
+
+type (
+	//referenceDB stores the blacklist reference collections using RITA's database connection.
+	//The entries of each entry type are stored in a collection named after the type.
+	referenceDB struct {
+		db       *database.DB
+		database string
+	}
+
+	//entryType names the kind of data held by the entries of a blacklist
+	entryType string
+
+	//listMetadata describes a blacklist source registered in the lists collection
+	listMetadata struct {
+		Name       string      `bson:"name"`
+		Types      []entryType `bson:"types"`
+		LastUpdate int64       `bson:"lastupdate"` // unix timestamp of the latest fetch of the list
+		CacheTime  int64       `bson:"cachetime"`  // seconds the entries are kept before the list is fetched again
+	}
+
+	//referenceEntry is an entry of a blacklist as stored in the reference collections
+	referenceEntry struct {
+		Index     string                 `bson:"index"`
+		List      string                 `bson:"list"`
+		ExtraData map[string]interface{} `bson:"extradata"`
+	}
+)
+
+//newReferenceDB returns a handle for the blacklist reference collections in the given database
+func newReferenceDB(db *database.DB, blacklistDB string) *referenceDB {
+	return &referenceDB{
+		db:       db,
+		database: blacklistDB,
+	}
+}
+
+//collection returns a handle to a collection in the blacklist database
+func (r *referenceDB) collection(name string) *mongo.Collection {
+	return r.db.Client.Database(r.database).Collection(name)
+}
+
+//registeredLists retrieves all of the lists registered with the database
+func (r *referenceDB) registeredLists() ([]listMetadata, error) {
+	var lists []listMetadata
+	ctx := r.db.Context()
+
+	cursor, err := r.collection(listsCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return lists, err
+	}
+	err = cursor.All(ctx, &lists)
+	return lists, err
+}
+
+//registerList registers a new blacklist source with the database
+func (r *referenceDB) registerList(l listMetadata) error {
+	ctx := r.db.Context()
+
+	//get the existing collections
+	collectionNames, err := r.db.Client.Database(r.database).ListCollectionNames(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+
+	//create listsCollection if it doesn't exist
+	if !util.StringInSlice(listsCollection, collectionNames) {
+		err = r.db.Client.Database(r.database).CreateCollection(ctx, listsCollection)
+		if err != nil {
+			return err
+		}
+
+		err = database.EnsureIndexes(ctx, r.collection(listsCollection), []database.Index{
+			{Key: []string{"name"}, Unique: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	//insert the new list
+	_, err = r.collection(listsCollection).InsertOne(ctx, l)
+	if err != nil {
+		return err
+	}
+
+	//create the collections for the types of entries this list produces
+	for _, entries := range l.Types {
+		if util.StringInSlice(string(entries), collectionNames) {
+			continue
+		}
+
+		err = r.db.Client.Database(r.database).CreateCollection(ctx, string(entries))
+		if err != nil {
+			return err
+		}
+		err = database.EnsureIndexes(ctx, r.collection(string(entries)), []database.Index{
+			{Key: []string{"$hashed:index"}},
+			{Key: []string{"index", "list"}, Unique: true},
+		})
+		if err != nil {
+			return err
+		}
+		collectionNames = append(collectionNames, string(entries))
+	}
+	return nil
+}
+
+//removeList removes an existing blacklist source from the database
+func (r *referenceDB) removeList(l listMetadata) error {
+	err := r.clearCache(l)
+	if err != nil {
+		return err
+	}
+	_, err = r.collection(listsCollection).DeleteOne(r.db.Context(), bson.M{"name": l.Name})
+	return err
+}
+
+//updateListMetadata updates the metadata of an existing blacklist
+func (r *referenceDB) updateListMetadata(l listMetadata) error {
+	_, err := r.collection(listsCollection).ReplaceOne(r.db.Context(), bson.M{"name": l.Name}, l)
+	return err
+}
+
+//clearCache clears old entries for a given list
+func (r *referenceDB) clearCache(l listMetadata) error {
+	for _, entries := range l.Types {
+		_, err := r.collection(string(entries)).DeleteMany(r.db.Context(), bson.M{"list": l.Name})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//insertEntries inserts a batch of entries from a list into the collection of their entry type
+func (r *referenceDB) insertEntries(entries entryType, batch []interface{}) error {
+	// keep inserting the rest of the entries if the unique index rejects a repeated entry
+	insertOptions := options.InsertMany().SetOrdered(false)
+	_, err := r.collection(string(entries)).InsertMany(r.db.Context(), batch, insertOptions)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	return nil
+}
@@ -20,19 +20,19 @@ type connectionPeer struct {
 // IPResult represtes a blacklisted IP and summary data
 // about the connections involving that IP
 type IPResult struct {
-	Host              data.UniqueIP   `bson:",inline"`
-	Connections       int             `bson:"conn_count"`
-	UniqueConnections int             `bson:"uconn_count"`
-	TotalBytes        int             `bson:"total_bytes"`
-	Peers             []data.UniqueIP `bson:"peers"`
+	Host              data.UniqueIP   `bson:",inline" json:"host"`
+	Connections       int             `bson:"conn_count" json:"conn_count"`
+	UniqueConnections int             `bson:"uconn_count" json:"uconn_count"`
+	TotalBytes        int             `bson:"total_bytes" json:"total_bytes"`
+	Peers             []data.UniqueIP `bson:"peers" json:"peers"`
 }
 
 // HostnameResult represents a blacklisted hostname and summary
 // data about the connections made to that hostname
 type HostnameResult struct {
-	Host              string          `bson:"host"`
-	Connections       int             `bson:"conn_count"`
-	UniqueConnections int             `bson:"uconn_count"`
-	TotalBytes        int             `bson:"total_bytes"`
-	ConnectedHosts    []data.UniqueIP `bson:"sources,omitempty"`
+	Host              string          `bson:"host" json:"host"`
+	Connections       int             `bson:"conn_count" json:"conn_count"`
+	UniqueConnections int             `bson:"uconn_count" json:"uconn_count"`
+	TotalBytes        int             `bson:"total_bytes" json:"total_bytes"`
+	ConnectedHosts    []data.UniqueIP `bson:"sources,omitempty" json:"sources,omitempty"`
 }
@@ -1,8 +1,9 @@
 package blacklist
 
 import (
+	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/resources"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 //HostnameResults finds blacklisted hostnames in the database and the IPs of the
@@ -10,10 +11,23 @@ import (
 //descending order keyed on of {uconn_count, conn_count, total_bytes} depending on the value
 //of sort. limit and noLimit control how many results are returned.
 func HostnameResults(res *resources.Resources, sort string, limit int, noLimit bool) ([]HostnameResult, error) {
-	ssn := res.DB.Session.Copy()
-	defer ssn.Close()
+	blHostsQuery := HostnameResultsQuery(sort)
 
-	blHostsQuery := []bson.M{
+	if !noLimit {
+		blHostsQuery = append(blHostsQuery, bson.M{"$limit": limit})
+	}
+
+	var blHosts []HostnameResult
+
+	err := database.AggregateAll(res.DB.Context(), res.DB.Collection(res.Config.T.DNS.HostnamesTable), blHostsQuery, &blHosts)
+
+	return blHosts, err
+}
+
+//HostnameResultsQuery builds the aggregation pipeline used by HostnameResults,
+//sorted in descending order by sort
+func HostnameResultsQuery(sort string) []bson.M {
+	return []bson.M{
 		// find blacklisted hostnames and the IPs associated with them
 		{"$match": bson.M{"blacklisted": true}},
 		{"$project": bson.M{
@@ -96,16 +110,6 @@ func HostnameResults(res *resources.Resources, sort string, limit int, noLimit b
 		}},
 		{"$sort": bson.M{sort: -1}},
 	}
-
-	if !noLimit {
-		blHostsQuery = append(blHostsQuery, bson.M{"$limit": limit})
-	}
-
-	var blHosts []HostnameResult
-
-	err := ssn.DB(res.DB.GetSelectedDB()).C(res.Config.T.DNS.HostnamesTable).Pipe(blHostsQuery).AllowDiskUse().All(&blHosts)
-
-	return blHosts, err
 }
 
 //SrcIPResults finds blacklisted source IPs in the database and the IPs of the
@@ -116,6 +120,12 @@ func SrcIPResults(res *resources.Resources, sort string, limit int, noLimit bool
 	return ipResults(res, sort, limit, noLimit, true)
 }
 
+//SrcIPResultsQuery builds the aggregation pipeline used by SrcIPResults,
+//sorted in descending order by sort
+func SrcIPResultsQuery(sort string) []bson.M {
+	return ipResultsQuery(sort, true)
+}
+
 //DstIPResults finds blacklisted destination IPs in the database and the IPs of the
 //hosts which connected to the blacklisted IP. The results will be sorted in
 //descending order keyed on of {uconn_count, conn_count, total_bytes} depending on the value
@@ -124,13 +134,31 @@ func DstIPResults(res *resources.Resources, sort string, limit int, noLimit bool
 	return ipResults(res, sort, limit, false, noLimit)
 }
 
+//DstIPResultsQuery builds the aggregation pipeline used by DstIPResults,
+//sorted in descending order by sort
+func DstIPResultsQuery(sort string) []bson.M {
+	return ipResultsQuery(sort, false)
+}
+
 //ipResults implements SrcIPResults and DstIPResults. Set sourceDestFlag to true
 //to find blacklisted source IPs. Set sourceDestFlag to false to find blacklisted
 //destination IPs.
 func ipResults(res *resources.Resources, sort string, limit int, noLimit bool, sourceDestFlag bool) ([]IPResult, error) {
-	ssn := res.DB.Session.Copy()
-	defer ssn.Close()
+	blIPQuery := ipResultsQuery(sort, sourceDestFlag)
+
+	if !noLimit {
+		blIPQuery = append(blIPQuery, bson.M{"$limit": limit})
+	}
+
+	var blIPs []IPResult
+
+	err := database.AggregateAll(res.DB.Context(), res.DB.Collection(res.Config.T.Structure.HostTable), blIPQuery, &blIPs)
+
+	return blIPs, err
+}
 
+//ipResultsQuery builds the aggregation pipeline used by ipResults
+func ipResultsQuery(sort string, sourceDestFlag bool) []bson.M {
 	var hostMatch bson.M
 	var blHostField string
 	var blPeerField string
@@ -153,9 +181,7 @@ func ipResults(res *resources.Resources, sort string, limit int, noLimit bool, s
 			}}
 	}
 
-	var blIPs []IPResult
-
-	blIPQuery := []bson.M{
+	return []bson.M{
 		// find blacklisted source/ destination hosts
 		{"$match": hostMatch},
 		// only select ip info from hosts collection
@@ -256,13 +282,4 @@ func ipResults(res *resources.Resources, sort string, limit int, noLimit bool, s
 		}},
 		{"$sort": bson.M{sort: -1}},
 	}
-
-	if !noLimit {
-		blIPQuery = append(blIPQuery, bson.M{"$limit": limit})
-	}
-
-	err := ssn.DB(res.DB.GetSelectedDB()).C(res.Config.T.Structure.HostTable).Pipe(blIPQuery).AllowDiskUse().All(&blIPs)
-
-	return blIPs, err
-
 }
@@ -1,15 +1,10 @@
 package blacklist
 
 import (
-	"fmt"
 	"io"
-	"net/http"
 	"os"
+	"time"
 
-	ritaBL "github.com/activecm/rita-bl"
-	ritaBLdb "github.com/activecm/rita-bl/database"
-	"github.com/activecm/rita-bl/list"
-	"github.com/activecm/rita-bl/sources/lists"
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	log "github.com/sirupsen/logrus"
@@ -44,69 +39,105 @@ func BuildBlacklistedCollections(db *database.DB, conf *config.Config, logger *l
 func buildBlacklistReferenceCollection(db *database.DB, conf *config.Config, logger *log.Logger) {
 
 	/***************** create new blacklist collection *********************/
-	var err error
-	var blDatabase ritaBLdb.Handle
-
 	// set current dataset name
 	currentDB := db.GetSelectedDB()
 
-	// User option set in yaml file, if enabled
-	// RITA will verify the MongoDB certificate's hostname and validity
-	// otherwise run a normal request to create a database
-	if conf.S.MongoDB.TLS.Enabled {
-		blDatabase, err = ritaBLdb.NewSecureMongoDB(
-			conf.S.MongoDB.ConnectionString,
-			conf.R.MongoDB.AuthMechanismParsed,
-			conf.S.Blacklisted.BlacklistDatabase,
-			conf.R.MongoDB.TLS.TLSConfig,
-		)
-	} else {
-		blDatabase, err = ritaBLdb.NewMongoDB(
-			conf.S.MongoDB.ConnectionString,
-			conf.R.MongoDB.AuthMechanismParsed,
-			conf.S.Blacklisted.BlacklistDatabase,
-		)
-	}
+	// the blacklist database shares RITA's connection to MongoDB
+	blDatabase := newReferenceDB(db, conf.S.Blacklisted.BlacklistDatabase)
+
+	//update the lists
+	updateLists(blDatabase, getSourceLists(conf), func(err error) { //error handler
+		logger.WithFields(log.Fields{
+			"db": currentDB,
+		}).Error(err)
+	})
+
+}
 
+//updateLists fetches the blacklist sources whose cached entries expired into the reference
+//collections. Lists which are no longer configured are removed along with their entries.
+func updateLists(blDatabase *referenceDB, sources []*sourceList, handleErr func(error)) {
+	//get the existing lists from the db
+	remoteMetas, err := blDatabase.registeredLists()
 	if err != nil {
-		logger.Error(err)
-		fmt.Println("\t[!] Could not connect to blacklist database")
+		handleErr(err)
 		return
 	}
 
-	// Creates new rita bl blacklist structure
-	ritaBL := ritaBL.NewBlacklist(
-		blDatabase,
-		func(err error) { //error handler
-			logger.WithFields(log.Fields{
-				"db": currentDB,
-			}).Error(err)
-		},
-	)
+	registered := make(map[string]listMetadata)
+	for _, remoteMeta := range remoteMetas {
+		registered[remoteMeta.Name] = remoteMeta
+	}
 
-	//send blacklist source lists
-	ritaBL.SetLists(getSourceLists(conf)...)
+	configured := make(map[string]bool)
+	for _, source := range sources {
+		configured[source.meta.Name] = true
+	}
 
-	//update the lists
-	ritaBL.Update()
+	//remove the lists which are no longer configured
+	for _, remoteMeta := range remoteMetas {
+		if configured[remoteMeta.Name] {
+			continue
+		}
+		if err := blDatabase.removeList(remoteMeta); err != nil {
+			handleErr(err)
+		}
+	}
 
+	for _, source := range sources {
+		remoteMeta, exists := registered[source.meta.Name]
+		if exists {
+			source.meta.LastUpdate = remoteMeta.LastUpdate
+		}
+		if !source.shouldFetch() {
+			continue
+		}
+
+		if exists {
+			//delete all existing entries and re-add the list
+			err = blDatabase.clearCache(source.meta)
+		} else {
+			//register the list with an expired cache so the list
+			//is fetched again if the import fails
+			preWriteMeta := source.meta
+			preWriteMeta.LastUpdate = 0
+			preWriteMeta.CacheTime = 0
+			err = blDatabase.registerList(preWriteMeta)
+		}
+		if err != nil {
+			handleErr(err)
+			continue
+		}
+
+		err = source.fetch(insertBatchSize, blDatabase.insertEntries, handleErr)
+		if err != nil {
+			handleErr(err)
+			continue
+		}
+
+		//mark the cache as valid
+		source.meta.LastUpdate = time.Now().Unix()
+		if err := blDatabase.updateListMetadata(source.meta); err != nil {
+			handleErr(err)
+		}
+	}
 }
 
 //getSourceLists gathers the blacklists to check against
-func getSourceLists(conf *config.Config) []list.List {
+func getSourceLists(conf *config.Config) []*sourceList {
 	//build up the lists
-	var blacklists []list.List
+	var blacklists []*sourceList
 	if conf.S.Blacklisted.UseFeodo {
-		blacklists = append(blacklists, lists.NewFeodoList())
+		blacklists = append(blacklists, newFeodoList())
 	}
 	//use custom lists
 	ipLists := buildCustomBlacklists(
-		list.BlacklistedIPType,
+		ipEntryType,
 		conf.S.Blacklisted.IPBlacklists,
 	)
 
 	hostLists := buildCustomBlacklists(
-		list.BlacklistedHostnameType,
+		hostnameEntryType,
 		conf.S.Blacklisted.HostnameBlacklists,
 	)
 
@@ -117,11 +148,11 @@ func getSourceLists(conf *config.Config) []list.List {
 }
 
 //buildCustomBlacklists gathers a custom blacklist from a url or file path
-func buildCustomBlacklists(entryType list.BlacklistedEntryType, paths []string) []list.List {
-	var blacklists []list.List
+func buildCustomBlacklists(entries entryType, paths []string) []*sourceList {
+	var blacklists []*sourceList
 	for _, path := range paths {
-		newList := lists.NewLineSeparatedList(
-			entryType,
+		newList := newLineSeparatedList(
+			entries,
 			path,
 			0, // Always reload the data
 			tryOpenFileThenURL(path),
@@ -142,10 +173,6 @@ func tryOpenFileThenURL(path string) func() (io.ReadCloser, error) {
 			}
 			return file, nil
 		}
-		resp, err := http.Get(path)
-		if err != nil {
-			return nil, err
-		}
-		return resp.Body, nil
+		return fetchURL(path)
 	}
 }
@@ -1,11 +1,12 @@
 package certificate
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 type (
@@ -18,12 +19,15 @@ type (
 		closedCallback   func()                     // called when .close() is called and no more calls to analyzedCallback will be made
 		analysisChannel  chan *Input                // holds unanalyzed data
 		analysisWg       sync.WaitGroup             // wait for analysis to finish
+		x509Map          map[string]*X509           // certificate details keyed by fingerprint and file ID
+		serverCounts     map[string]int             // number of servers presenting each certificate
 	}
 )
 
 // newAnalyzer creates a new analyzer for recording connections that were made
-// with invalid certificates
-func newAnalyzer(chunk int, db *database.DB, conf *config.Config, analyzedCallback func(database.BulkChanges), closedCallback func()) *analyzer {
+// with invalid certificates and the certificates presented by each server
+func newAnalyzer(chunk int, db *database.DB, conf *config.Config, x509Map map[string]*X509, serverCounts map[string]int,
+	analyzedCallback func(database.BulkChanges), closedCallback func()) *analyzer {
 	return &analyzer{
 		chunk:            chunk,
 		db:               db,
@@ -31,6 +35,8 @@ func newAnalyzer(chunk int, db *database.DB, conf *config.Config, analyzedCallba
 		analyzedCallback: analyzedCallback,
 		closedCallback:   closedCallback,
 		analysisChannel:  make(chan *Input),
+		x509Map:          x509Map,
+		serverCounts:     serverCounts,
 	}
 }
 
@@ -50,38 +56,47 @@ func (a *analyzer) close() {
 func (a *analyzer) start() {
 	a.analysisWg.Add(1)
 	go func() {
-		ssn := a.db.Session.Copy()
-		defer ssn.Close()
-
 		for datum := range a.analysisChannel {
-			// cap the list to an arbitrary amount (hopefully smaller than the 16 MB document size cap)
-			// anything approaching this limit will cause performance issues in software that depends on rita
-			// anything tuncated over this limit won't be visible as an IP connecting to an invalid cert
-			origIPs := datum.OrigIps.Items()
-			if len(origIPs) > 200003 {
-				origIPs = origIPs[:200003]
+			dat := bson.M{"cid": a.chunk}
+
+			if len(datum.InvalidCerts) > 0 {
+				// cap the list to an arbitrary amount (hopefully smaller than the 16 MB document size cap)
+				// anything approaching this limit will cause performance issues in software that depends on rita
+				// anything tuncated over this limit won't be visible as an IP connecting to an invalid cert
+				origIPs := datum.OrigIps.Items()
+				if len(origIPs) > 200003 {
+					origIPs = origIPs[:200003]
+				}
+
+				tuples := datum.Tuples.Items()
+				if len(tuples) > 20 {
+					tuples = tuples[:20]
+				}
+
+				invalidCerts := datum.InvalidCerts.Items()
+				if len(invalidCerts) > 10 {
+					invalidCerts = invalidCerts[:10]
+				}
+
+				dat["seen"] = datum.Seen
+				dat["orig_ips"] = origIPs
+				dat["tuples"] = tuples
+				dat["icodes"] = invalidCerts
 			}
 
-			tuples := datum.Tuples.Items()
-			if len(tuples) > 20 {
-				tuples = tuples[:20]
+			if certs := a.certificates(datum); len(certs) > 0 {
+				dat["certs"] = certs
 			}
 
-			invalidCerts := datum.InvalidCerts.Items()
-			if len(invalidCerts) > 10 {
-				invalidCerts = invalidCerts[:10]
+			// the server presented a valid certificate which is missing from the x509 log
+			if len(dat) == 1 {
+				continue
 			}
 
 			// create certificateQuery
 			certificateQuery := bson.M{
 				"$push": bson.M{
-					"dat": bson.M{
-						"seen":     datum.Seen,
-						"orig_ips": origIPs,
-						"tuples":   tuples,
-						"icodes":   invalidCerts,
-						"cid":      a.chunk,
-					},
+					"dat": dat,
 				},
 				"$set": bson.M{
 					"cid":          a.chunk,
@@ -102,3 +117,27 @@ func (a *analyzer) start() {
 		a.analysisWg.Done()
 	}()
 }
+
+// certificates returns the details and risks of the certificates presented by a server,
+// riskiest first
+func (a *analyzer) certificates(datum *Input) []*CertView {
+	var certs []*CertView
+	for fingerprint, cert := range linkCertificates(datum.Presented, a.x509Map) {
+		cert.ServerCount = a.serverCounts[fingerprint]
+		assess(cert)
+		certs = append(certs, cert)
+	}
+
+	sort.Slice(certs, func(i, j int) bool {
+		if certs[i].Score == certs[j].Score {
+			return certs[i].Seen > certs[j].Seen
+		}
+		return certs[i].Score > certs[j].Score
+	})
+
+	// cap the list in case a server presents a new certificate for every connection
+	if len(certs) > 100 {
+		certs = certs[:100]
+	}
+	return certs
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/util"
-	"github.com/globalsign/mgo"
 	"github.com/vbauerster/mpb"
 	"github.com/vbauerster/mpb/decor"
 
@@ -19,7 +18,7 @@ type repo struct {
 	log      *log.Logger
 }
 
-// NewMongoRepository bundles the given resources for updating MongoDB with invalid certificate data
+// NewMongoRepository bundles the given resources for updating MongoDB with certificate data
 func NewMongoRepository(db *database.DB, conf *config.Config, logger *log.Logger) Repository {
 	return &repo{
 		database: db,
@@ -30,14 +29,11 @@ func NewMongoRepository(db *database.DB, conf *config.Config, logger *log.Logger
 
 // CreateIndexes creates indexes for the certificate collection
 func (r *repo) CreateIndexes() error {
-	session := r.database.Session.Copy()
-	defer session.Close()
-
 	// set collection name
 	collectionName := r.config.T.Cert.CertificateTable
 
 	// check if collection already exists
-	names, _ := session.DB(r.database.GetSelectedDB()).CollectionNames()
+	names, _ := r.database.CollectionNames()
 
 	// if collection exists, we don't need to do anything else
 	for _, name := range names {
@@ -46,9 +42,10 @@ func (r *repo) CreateIndexes() error {
 		}
 	}
 
-	indexes := []mgo.Index{
+	indexes := []database.Index{
 		{Key: []string{"ip", "network_uuid"}, Unique: true},
 		{Key: []string{"dat.seen"}},
+		{Key: []string{"dat.certs.fingerprint"}},
 	}
 
 	// create collection
@@ -61,7 +58,7 @@ func (r *repo) CreateIndexes() error {
 }
 
 // Upsert records the given certificate data in MongoDB
-func (r *repo) Upsert(certMap map[string]*Input) {
+func (r *repo) Upsert(certMap map[string]*Input, x509Map map[string]*X509) {
 	// Create the workers
 	writerWorker := database.NewBulkWriter(r.database, r.config, r.log, true, "certificate")
 
@@ -69,6 +66,8 @@ func (r *repo) Upsert(certMap map[string]*Input) {
 		r.config.S.Rolling.CurrentChunk,
 		r.database,
 		r.config,
+		x509Map,
+		countServers(certMap, x509Map),
 		writerWorker.Collect,
 		writerWorker.Close,
 	)
@@ -83,7 +82,7 @@ func (r *repo) Upsert(certMap map[string]*Input) {
 	p := mpb.New(mpb.WithWidth(20))
 	bar := p.AddBar(int64(len(certMap)),
 		mpb.PrependDecorators(
-			decor.Name("\t[-] Invalid Cert Analysis:", decor.WC{W: 30, C: decor.DidentRight}),
+			decor.Name("\t[-] Certificate Analysis:", decor.WC{W: 30, C: decor.DidentRight}),
 			decor.CountersNoUnit(" %d / %d ", decor.WCSyncWidth),
 		),
 		mpb.AppendDecorators(decor.Percentage()),
@@ -3,19 +3,14 @@
 package certificate
 
 import (
-	"io/ioutil"
 	"os"
 	"testing"
 
 	"github.com/activecm/rita/pkg/data"
 	"github.com/activecm/rita/resources"
 	"github.com/activecm/rita/util"
-	"github.com/globalsign/mgo/dbtest"
 )
 
-// Server holds the dbtest DBServer
-var Server dbtest.DBServer
-
 // Set the test database
 var testTargetDB = "tmp_test_db"
 
@@ -51,16 +46,12 @@ func init() {
 }
 
 func TestUpsert(t *testing.T) {
-	testRepo.Upsert(testCertificate)
+	testRepo.Upsert(testCertificate, nil)
 
 }
 
 // TestMain wraps all tests with the needed initialized mock DB and fixtures
 func TestMain(m *testing.M) {
-	// Store temporary databases files in a temporary directory
-	tempDir, _ := ioutil.TempDir("", "testing")
-	Server.SetPath(tempDir)
-
 	// Set the main session variable to the temporary MongoDB instance
 	res := resources.InitTestResources()
 
@@ -69,8 +60,8 @@ func TestMain(m *testing.M) {
 	// Run the test suite
 	retCode := m.Run()
 
-	// Shut down the temporary server and removes data on disk.
-	Server.Stop()
+	// Disconnect from the test MongoDB server
+	res.DB.Close()
 
 	// call with result of m.Run()
 	os.Exit(retCode)
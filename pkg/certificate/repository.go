@@ -7,7 +7,7 @@ import (
 // Repository for uconn collection
 type Repository interface {
 	CreateIndexes() error
-	Upsert(useragentMap map[string]*Input)
+	Upsert(certMap map[string]*Input, x509Map map[string]*X509)
 }
 
 // Input ....
@@ -17,6 +17,52 @@ type Input struct {
 	OrigIps      data.UniqueIPSet
 	InvalidCerts data.StringSet
 	Tuples       data.StringSet
+	// Presented holds the certificates the server presented, keyed by the
+	// fingerprint or file ID the ssl log refers to each certificate by
+	Presented map[string]*Presentation
+}
+
+// Presentation records when a server presented a certificate
+type Presentation struct {
+	Seen      int64
+	FirstSeen int64
+	LastSeen  int64
+}
+
+// X509 holds the details of a certificate from the x509 log
+type X509 struct {
+	Fingerprint string
+	Subject     string
+	Issuer      string
+	SANs        []string
+	NotBefore   int64
+	NotAfter    int64
+	KeyType     string
+	KeyLength   int
+}
+
+// CertView is a certificate presented by a server as stored in the certs array of a dat subdocument
+type CertView struct {
+	Fingerprint string   `bson:"fingerprint" json:"fingerprint"`
+	Subject     string   `bson:"subject" json:"subject"`
+	Issuer      string   `bson:"issuer" json:"issuer"`
+	SANs        []string `bson:"sans" json:"sans"`
+	NotBefore   int64    `bson:"not_before" json:"not_before"`
+	NotAfter    int64    `bson:"not_after" json:"not_after"`
+	KeyType     string   `bson:"key_type" json:"key_type"`
+	KeyLength   int      `bson:"key_length" json:"key_length"`
+	Seen        int64    `bson:"seen" json:"seen"`
+	FirstSeen   int64    `bson:"first_seen" json:"first_seen"`
+	LastSeen    int64    `bson:"last_seen" json:"last_seen"`
+	ServerCount int      `bson:"server_count" json:"server_count"`
+	Risks       []string `bson:"risks" json:"risks"`
+	Score       float64  `bson:"score" json:"score"`
+}
+
+// Result is a certificate along with the servers which presented it (for reporting)
+type Result struct {
+	CertView `bson:",inline"`
+	Servers  []data.UniqueIP `bson:"servers" json:"servers"`
 }
 
 // AnalysisView (for reporting)
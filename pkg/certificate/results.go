@@ -0,0 +1,90 @@
+package certificate
+
+import (
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/resources"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+//Results returns the certificates presented by servers matching the given filter, riskiest first.
+//The risks are assessed again over every import session since a certificate may only show
+//some of its risks in each session. limit and noLimit control how many results are returned.
+func Results(res *resources.Resources, limit int, noLimit bool, filt filter.Filter) ([]Result, error) {
+	ctx := res.DB.Context()
+
+	var certResults []Result
+
+	// the servers are filtered once they are grouped so every server presenting a certificate is listed
+	filterPredicate, err := filt.Predicate(filter.Fields{Dst: "servers.ip", DstNetworkName: "servers.network_name"})
+	if err != nil {
+		return certResults, err
+	}
+
+	scorePredicate, err := filt.Predicate(filter.Fields{Score: "score"})
+	if err != nil {
+		return certResults, err
+	}
+
+	certQuery := []bson.M{
+		{"$match": bson.M{"dat.certs": bson.M{"$exists": true}}},
+		{"$project": bson.M{"ip": 1, "network_uuid": 1, "network_name": 1, "certs": "$dat.certs"}},
+		// each dat subdocument holds a list of certificates
+		{"$unwind": "$certs"},
+		{"$unwind": "$certs"},
+		{"$group": bson.M{
+			"_id":        "$certs.fingerprint",
+			"subject":    bson.M{"$first": "$certs.subject"},
+			"issuer":     bson.M{"$first": "$certs.issuer"},
+			"sans":       bson.M{"$first": "$certs.sans"},
+			"not_before": bson.M{"$first": "$certs.not_before"},
+			"not_after":  bson.M{"$first": "$certs.not_after"},
+			"key_type":   bson.M{"$first": "$certs.key_type"},
+			"key_length": bson.M{"$first": "$certs.key_length"},
+			"seen":       bson.M{"$sum": "$certs.seen"},
+			"first_seen": bson.M{"$min": "$certs.first_seen"},
+			"last_seen":  bson.M{"$max": "$certs.last_seen"},
+			"servers": bson.M{"$addToSet": bson.M{
+				"ip":           "$ip",
+				"network_uuid": "$network_uuid",
+				"network_name": "$network_name",
+			}},
+		}},
+		{"$match": filterPredicate},
+		{"$addFields": bson.M{"score": scoreQuery()}},
+		{"$match": scorePredicate},
+		{"$sort": bson.D{{Key: "score", Value: -1}, {Key: "seen", Value: -1}}},
+		{"$project": bson.M{
+			"_id":         0,
+			"fingerprint": "$_id",
+			"subject":     1,
+			"issuer":      1,
+			"sans":        1,
+			"not_before":  1,
+			"not_after":   1,
+			"key_type":    1,
+			"key_length":  1,
+			"seen":        1,
+			"first_seen":  1,
+			"last_seen":   1,
+			"servers":     1,
+		}},
+	}
+
+	if !noLimit {
+		certQuery = append(certQuery, bson.M{"$limit": limit})
+	}
+
+	err = database.AggregateAll(ctx, res.DB.Collection(res.Config.T.Cert.CertificateTable), certQuery, &certResults)
+	if err != nil {
+		return nil, err
+	}
+
+	// fill in the risks behind each score
+	for i := range certResults {
+		certResults[i].ServerCount = len(certResults[i].Servers)
+		assess(&certResults[i].CertView)
+	}
+
+	return certResults, nil
+}
@@ -0,0 +1,166 @@
+package certificate
+
+import (
+	"math"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// the risky patterns a certificate may show
+const (
+	RiskSelfSigned    = "self_signed"    // the subject and issuer are the same
+	RiskShortLived    = "short_lived"    // the validity window is very short
+	RiskFreshlyIssued = "freshly_issued" // the certificate was first seen soon after it became valid
+	RiskExpired       = "expired"        // the certificate was presented outside of its validity window
+	RiskManyIPs       = "many_ips"       // the same certificate was presented by many servers
+)
+
+const (
+	shortLivedSeconds    = 7 * 24 * 60 * 60
+	freshlyIssuedSeconds = 3 * 24 * 60 * 60
+	manyIPsThreshold     = 10
+)
+
+// riskWeights sum to one, so a certificate showing every pattern scores one
+var riskWeights = map[string]float64{
+	RiskSelfSigned:    0.3,
+	RiskExpired:       0.25,
+	RiskShortLived:    0.15,
+	RiskFreshlyIssued: 0.15,
+	RiskManyIPs:       0.15,
+}
+
+// linkCertificates looks up the certificates presented by a server in the x509 log and
+// returns their details keyed by fingerprint. Presentations which the ssl log referred to
+// by different file IDs are merged. Certificates missing from the x509 log are skipped.
+func linkCertificates(presented map[string]*Presentation, x509Map map[string]*X509) map[string]*CertView {
+	certs := make(map[string]*CertView)
+	for ref, presentation := range presented {
+		details, ok := x509Map[ref]
+		if !ok {
+			continue
+		}
+
+		cert, ok := certs[details.Fingerprint]
+		if !ok {
+			cert = &CertView{
+				Fingerprint: details.Fingerprint,
+				Subject:     details.Subject,
+				Issuer:      details.Issuer,
+				SANs:        details.SANs,
+				NotBefore:   details.NotBefore,
+				NotAfter:    details.NotAfter,
+				KeyType:     details.KeyType,
+				KeyLength:   details.KeyLength,
+				FirstSeen:   presentation.FirstSeen,
+				LastSeen:    presentation.LastSeen,
+			}
+			certs[details.Fingerprint] = cert
+		}
+
+		cert.Seen += presentation.Seen
+		if presentation.FirstSeen < cert.FirstSeen {
+			cert.FirstSeen = presentation.FirstSeen
+		}
+		if presentation.LastSeen > cert.LastSeen {
+			cert.LastSeen = presentation.LastSeen
+		}
+	}
+	return certs
+}
+
+// countServers returns how many servers presented each certificate, keyed by fingerprint
+func countServers(certMap map[string]*Input, x509Map map[string]*X509) map[string]int {
+	serverCounts := make(map[string]int)
+	for _, datum := range certMap {
+		for fingerprint := range linkCertificates(datum.Presented, x509Map) {
+			serverCounts[fingerprint]++
+		}
+	}
+	return serverCounts
+}
+
+// assess fills in the risky patterns shown by a certificate and its score.
+// The score is the sum of the weights of each pattern.
+func assess(cert *CertView) {
+	risks := []string{}
+
+	if cert.Subject != "" && cert.Subject == cert.Issuer {
+		risks = append(risks, RiskSelfSigned)
+	}
+
+	// older logs may not record the validity window
+	if cert.NotBefore > 0 && cert.NotAfter > 0 {
+		if cert.NotAfter-cert.NotBefore < shortLivedSeconds {
+			risks = append(risks, RiskShortLived)
+		}
+		if cert.FirstSeen >= cert.NotBefore && cert.FirstSeen-cert.NotBefore < freshlyIssuedSeconds {
+			risks = append(risks, RiskFreshlyIssued)
+		}
+		if cert.FirstSeen < cert.NotBefore || cert.LastSeen > cert.NotAfter {
+			risks = append(risks, RiskExpired)
+		}
+	}
+
+	if cert.ServerCount >= manyIPsThreshold {
+		risks = append(risks, RiskManyIPs)
+	}
+
+	sort.Strings(risks)
+	cert.Risks = risks
+
+	score := 0.0
+	for _, risk := range risks {
+		score += riskWeights[risk]
+	}
+	cert.Score = math.Round(math.Min(score, 1)*1000) / 1000
+}
+
+// scoreQuery builds an aggregation expression which computes the score assess gives a certificate
+// grouped by fingerprint, so the results can be filtered and sorted by score in MongoDB
+func scoreQuery() bson.M {
+	validityKnown := bson.M{"$and": []interface{}{
+		bson.M{"$gt": []interface{}{"$not_before", 0}},
+		bson.M{"$gt": []interface{}{"$not_after", 0}},
+	}}
+
+	risks := map[string]bson.M{
+		// strings sort above null and the empty string
+		RiskSelfSigned: {"$and": []interface{}{
+			bson.M{"$gt": []interface{}{"$subject", ""}},
+			bson.M{"$eq": []interface{}{"$subject", "$issuer"}},
+		}},
+		RiskShortLived: {"$and": []interface{}{
+			validityKnown,
+			bson.M{"$lt": []interface{}{bson.M{"$subtract": []interface{}{"$not_after", "$not_before"}}, shortLivedSeconds}},
+		}},
+		RiskFreshlyIssued: {"$and": []interface{}{
+			validityKnown,
+			bson.M{"$gte": []interface{}{"$first_seen", "$not_before"}},
+			bson.M{"$lt": []interface{}{bson.M{"$subtract": []interface{}{"$first_seen", "$not_before"}}, freshlyIssuedSeconds}},
+		}},
+		RiskExpired: {"$and": []interface{}{
+			validityKnown,
+			bson.M{"$or": []interface{}{
+				bson.M{"$lt": []interface{}{"$first_seen", "$not_before"}},
+				bson.M{"$gt": []interface{}{"$last_seen", "$not_after"}},
+			}},
+		}},
+		RiskManyIPs: {"$gte": []interface{}{bson.M{"$size": "$servers"}, manyIPsThreshold}},
+	}
+
+	// add the weights in the same order as assess so the sums match exactly
+	names := make([]string, 0, len(risks))
+	for risk := range risks {
+		names = append(names, risk)
+	}
+	sort.Strings(names)
+
+	weights := make([]interface{}, 0, len(names))
+	for _, risk := range names {
+		weights = append(weights, bson.M{"$cond": []interface{}{risks[risk], riskWeights[risk], 0}})
+	}
+
+	return bson.M{"$round": []interface{}{bson.M{"$min": []interface{}{bson.M{"$add": weights}, 1}}, 3}}
+}
@@ -0,0 +1,70 @@
+package certificate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const day = 24 * 60 * 60
+
+func TestLinkCertificates(t *testing.T) {
+	cert := &X509{Fingerprint: "fp1", Subject: "CN=a", Issuer: "CN=ca", NotBefore: 100, NotAfter: 100 + 365*day}
+	x509Map := map[string]*X509{"fp1": cert, "F1": cert, "F2": cert}
+	presented := map[string]*Presentation{
+		"F1":      {Seen: 2, FirstSeen: 200, LastSeen: 300},
+		"F2":      {Seen: 3, FirstSeen: 150, LastSeen: 250},
+		"missing": {Seen: 1, FirstSeen: 100, LastSeen: 100},
+	}
+
+	certs := linkCertificates(presented, x509Map)
+	require.Len(t, certs, 1)
+	assert.Equal(t, int64(5), certs["fp1"].Seen)
+	assert.Equal(t, int64(150), certs["fp1"].FirstSeen)
+	assert.Equal(t, int64(300), certs["fp1"].LastSeen)
+	assert.Equal(t, "CN=ca", certs["fp1"].Issuer)
+}
+
+func TestAssess(t *testing.T) {
+	testCases := []struct {
+		name  string
+		cert  CertView
+		risks []string
+		score float64
+	}{
+		{
+			name: "ordinary",
+			cert: CertView{Subject: "CN=a", Issuer: "CN=ca", NotBefore: 0 + day, NotAfter: 90 * day,
+				FirstSeen: 30 * day, LastSeen: 31 * day, ServerCount: 2},
+			risks: []string{},
+			score: 0,
+		},
+		{
+			name: "self signed and short lived",
+			cert: CertView{Subject: "CN=a", Issuer: "CN=a", NotBefore: 10 * day, NotAfter: 12 * day,
+				FirstSeen: 11 * day, LastSeen: 11 * day, ServerCount: 1},
+			risks: []string{RiskFreshlyIssued, RiskSelfSigned, RiskShortLived},
+			score: 0.6,
+		},
+		{
+			name: "expired on many servers",
+			cert: CertView{Subject: "CN=a", Issuer: "CN=ca", NotBefore: day, NotAfter: 90 * day,
+				FirstSeen: 89 * day, LastSeen: 91 * day, ServerCount: manyIPsThreshold},
+			risks: []string{RiskExpired, RiskManyIPs},
+			score: 0.4,
+		},
+		{
+			name:  "missing validity window",
+			cert:  CertView{Subject: "CN=a", Issuer: "CN=ca", FirstSeen: day, LastSeen: day},
+			risks: []string{},
+			score: 0,
+		},
+	}
+
+	for _, testCase := range testCases {
+		assess(&testCase.cert)
+		assert.Equal(t, testCase.risks, testCase.cert.Risks, testCase.name)
+		assert.Equal(t, testCase.score, testCase.cert.Score, testCase.name)
+	}
+}
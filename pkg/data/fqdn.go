@@ -3,7 +3,7 @@ package data
 import (
 	"strings"
 
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 //UniqueSrcFQDNPair is used to make a tuple of
@@ -11,7 +11,7 @@ import (
 // was attempting to communicate
 type UniqueSrcFQDNPair struct {
 	UniqueSrcIP `bson:",inline"`
-	FQDN        string `bson:"fqdn"`
+	FQDN        string `bson:"fqdn" json:"fqdn"`
 }
 
 //NewUniqueSrcFQDNPair binds a pair of UniqueIPs and an FQDN
@@ -34,7 +34,7 @@ func (p UniqueSrcFQDNPair) MapKey() string {
 
 	builder.Grow(len(p.SrcIP) + srcUUIDLen + len(p.FQDN))
 	builder.WriteString(p.SrcIP)
-	builder.WriteByte(p.SrcNetworkUUID.Kind)
+	builder.WriteByte(p.SrcNetworkUUID.Subtype)
 	builder.Write(p.SrcNetworkUUID.Data)
 
 	builder.WriteString(p.FQDN)
@@ -6,8 +6,10 @@ import (
 	"strings"
 
 	"github.com/activecm/rita/util"
-	"github.com/globalsign/mgo/bson"
 	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 //UniqueIP binds an IP to an optional Network UUID and Network Name.
@@ -15,9 +17,9 @@ import (
 //appearing on distinct physical networks. The Network Name should
 //not be considered when determining equality.
 type UniqueIP struct {
-	IP          string      `bson:"ip"`
-	NetworkUUID bson.Binary `bson:"network_uuid"`
-	NetworkName string      `bson:"network_name"`
+	IP          string           `bson:"ip" json:"ip"`
+	NetworkUUID primitive.Binary `bson:"network_uuid" json:"-"`
+	NetworkName string           `bson:"network_name" json:"network_name"`
 }
 
 //NewUniqueIP returns a new UniqueIP. If the given ip is publicly routable, the resulting UniqueIP's
@@ -49,9 +51,9 @@ func NewUniqueIP(ip net.IP, agentUUID, agentName string) UniqueIP {
 		return u
 	}
 
-	u.NetworkUUID = bson.Binary{
-		Kind: bson.BinaryUUID,
-		Data: id[:],
+	u.NetworkUUID = primitive.Binary{
+		Subtype: bsontype.BinaryUUID,
+		Data:    id[:],
 	}
 	u.NetworkName = agentName
 	return u
@@ -60,7 +62,7 @@ func NewUniqueIP(ip net.IP, agentUUID, agentName string) UniqueIP {
 //Equal checks if two UniqueIPs have the same IP and network UUID
 func (u UniqueIP) Equal(ip UniqueIP) bool {
 	return (u.IP == ip.IP &&
-		u.NetworkUUID.Kind == ip.NetworkUUID.Kind &&
+		u.NetworkUUID.Subtype == ip.NetworkUUID.Subtype &&
 		bytes.Equal(u.NetworkUUID.Data, ip.NetworkUUID.Data))
 }
 
@@ -69,7 +71,7 @@ func (u UniqueIP) MapKey() string {
 	var builder strings.Builder
 	builder.Grow(len(u.IP) + 1 + len(u.NetworkUUID.Data))
 	builder.WriteString(u.IP)
-	builder.WriteByte(u.NetworkUUID.Kind)
+	builder.WriteByte(u.NetworkUUID.Subtype)
 	builder.Write(u.NetworkUUID.Data)
 
 	return builder.String()
@@ -96,9 +98,9 @@ func (u UniqueIP) PrefixedBSONKey(prefix string) bson.M {
 
 //UniqueSrcIP is a unique IP which acts as the source in an IP pair
 type UniqueSrcIP struct {
-	SrcIP          string      `bson:"src"`
-	SrcNetworkUUID bson.Binary `bson:"src_network_uuid"`
-	SrcNetworkName string      `bson:"src_network_name"`
+	SrcIP          string           `bson:"src" json:"src"`
+	SrcNetworkUUID primitive.Binary `bson:"src_network_uuid" json:"-"`
+	SrcNetworkName string           `bson:"src_network_name" json:"src_network_name"`
 }
 
 //AsSrc returns the UniqueIP in the UniqueSrcIP format
@@ -131,9 +133,9 @@ func (u UniqueSrcIP) BSONKey() bson.M {
 
 //UniqueDstIP is a unique IP which acts as the destination in an IP Pair
 type UniqueDstIP struct {
-	DstIP          string      `bson:"dst"`
-	DstNetworkUUID bson.Binary `bson:"dst_network_uuid"`
-	DstNetworkName string      `bson:"dst_network_name"`
+	DstIP          string           `bson:"dst" json:"dst"`
+	DstNetworkUUID primitive.Binary `bson:"dst_network_uuid" json:"-"`
+	DstNetworkName string           `bson:"dst_network_name" json:"dst_network_name"`
 }
 
 //AsDst returns the UniqueIP in the UniqueDstIP format
@@ -196,9 +198,9 @@ func (p UniqueIPPair) MapKey() string {
 	builder.Grow(len(p.SrcIP) + srcUUIDLen + len(p.DstIP) + dstUUIDLen)
 	builder.WriteString(p.SrcIP)
 	builder.WriteString(p.DstIP)
-	builder.WriteByte(p.SrcNetworkUUID.Kind)
+	builder.WriteByte(p.SrcNetworkUUID.Subtype)
 	builder.Write(p.SrcNetworkUUID.Data)
-	builder.WriteByte(p.DstNetworkUUID.Kind)
+	builder.WriteByte(p.DstNetworkUUID.Subtype)
 	builder.Write(p.DstNetworkUUID.Data)
 
 	return builder.String()
@@ -1,5 +1,10 @@
 package data
 
+import (
+	"bytes"
+	"encoding/gob"
+)
+
 type StringSet map[string]struct{}
 
 //Items returns the strings in the set as a slice.
@@ -65,3 +70,43 @@ func (s Int64Set) Contains(intVal int64) bool {
 	_, ok := s[intVal]
 	return ok
 }
+
+//GobEncode encodes the set as a list of strings since gob cannot encode empty structs
+func (s StringSet) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(s.Items())
+	return buf.Bytes(), err
+}
+
+//GobDecode decodes a set encoded by GobEncode
+func (s *StringSet) GobDecode(encoded []byte) error {
+	var items []string
+	if err := gob.NewDecoder(bytes.NewReader(encoded)).Decode(&items); err != nil {
+		return err
+	}
+	*s = make(StringSet, len(items))
+	for _, str := range items {
+		s.Insert(str)
+	}
+	return nil
+}
+
+//GobEncode encodes the set as a list of integers since gob cannot encode empty structs
+func (s IntSet) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(s.Items())
+	return buf.Bytes(), err
+}
+
+//GobDecode decodes a set encoded by GobEncode
+func (s *IntSet) GobDecode(encoded []byte) error {
+	var items []int
+	if err := gob.NewDecoder(bytes.NewReader(encoded)).Decode(&items); err != nil {
+		return err
+	}
+	*s = make(IntSet, len(items))
+	for _, intVal := range items {
+		s.Insert(intVal)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package dnstunnel
+
+import (
+	"sync"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// maxStoredSubdomains is the number of subdomains each import session stores for a domain.
+// This keeps the documents of domains with huge numbers of subdomains under MongoDB's size limit.
+const maxStoredSubdomains = 2000
+
+type (
+	//analyzer : structure for dns tunnel analysis
+	analyzer struct {
+		chunk            int                        //current chunk (0 if not on rolling analysis)
+		conf             *config.Config             // contains details needed to access MongoDB
+		analyzedCallback func(database.BulkChanges) // called on each analyzed result
+		closedCallback   func()                     // called when .close() is called and no more calls to analyzedCallback will be made
+		analysisChannel  chan *Input                // holds unanalyzed data
+		analysisWg       sync.WaitGroup             // wait for analysis to finish
+	}
+)
+
+// newAnalyzer creates a new collector for summarizing queries by registered domain
+func newAnalyzer(chunk int, conf *config.Config, analyzedCallback func(database.BulkChanges), closedCallback func()) *analyzer {
+	return &analyzer{
+		chunk:            chunk,
+		conf:             conf,
+		analyzedCallback: analyzedCallback,
+		closedCallback:   closedCallback,
+		analysisChannel:  make(chan *Input),
+	}
+}
+
+// collect sends a domain to be analyzed
+func (a *analyzer) collect(data *Input) {
+	a.analysisChannel <- data
+}
+
+// close waits for the collector to finish
+func (a *analyzer) close() {
+	close(a.analysisChannel)
+	a.analysisWg.Wait()
+	a.closedCallback()
+}
+
+// start kicks off a new analysis thread
+func (a *analyzer) start() {
+	a.analysisWg.Add(1)
+	go func() {
+		for datum := range a.analysisChannel {
+
+			// find the client which queried the most unique subdomains
+			maxClientSubdomains := 0
+			for _, subdomains := range datum.ClientSubdomains {
+				if len(subdomains) > maxClientSubdomains {
+					maxClientSubdomains = len(subdomains)
+				}
+			}
+
+			// keep a sample of the subdomains so they can be counted across sessions
+			subdomains := datum.Subdomains.Items()
+			if len(subdomains) > maxStoredSubdomains {
+				subdomains = subdomains[:maxStoredSubdomains]
+			}
+
+			// each import session pushes its totals so that the statistics can be
+			// combined across sessions and chunks when the results are gathered
+			update := bson.M{
+				"$set": bson.M{
+					"cid": a.chunk,
+				},
+				"$push": bson.M{
+					"dat": bson.M{
+						"queries":               datum.QueryCount,
+						"unique_subdomains":     len(datum.Subdomains),
+						"subdomains":            subdomains,
+						"max_client_subdomains": maxClientSubdomains,
+						"entropy_sum":           datum.EntropySum,
+						"label_length_sum":      datum.LabelLengthSum,
+						"max_label_length":      datum.MaxLabelLength,
+						"txt":                   datum.TXTCount,
+						"null":                  datum.NULLCount,
+						"cname":                 datum.CNAMECount,
+						"query_bytes":           datum.QueryBytes,
+						"answer_bytes":          datum.AnswerBytes,
+						"cid":                   a.chunk,
+					},
+				},
+			}
+
+			a.analyzedCallback(database.BulkChanges{
+				a.conf.T.DNS.DNSTunnelTable: []database.BulkChange{{
+					Selector: bson.M{"domain": datum.Domain},
+					Update:   update,
+					Upsert:   true,
+				}},
+			})
+		}
+		a.analysisWg.Done()
+	}()
+}
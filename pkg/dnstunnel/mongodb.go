@@ -0,0 +1,104 @@
+package dnstunnel
+
+import (
+	"runtime"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/vbauerster/mpb"
+	"github.com/vbauerster/mpb/decor"
+)
+
+type repo struct {
+	database *database.DB
+	config   *config.Config
+	log      *log.Logger
+}
+
+// NewMongoRepository bundles the given resources for updating MongoDB with DNS tunnel data
+func NewMongoRepository(db *database.DB, conf *config.Config, logger *log.Logger) Repository {
+	return &repo{
+		database: db,
+		config:   conf,
+		log:      logger,
+	}
+}
+
+// CreateIndexes creates indexes for the dnsTunnel collection
+func (r *repo) CreateIndexes() error {
+	// set collection name
+	collectionName := r.config.T.DNS.DNSTunnelTable
+
+	// check if collection already exists
+	names, _ := r.database.CollectionNames()
+
+	// if collection exists, we don't need to do anything else
+	for _, name := range names {
+		if name == collectionName {
+			return nil
+		}
+	}
+
+	// set desired indexes
+	indexes := []database.Index{
+		{Key: []string{"domain"}, Unique: true},
+		{Key: []string{"dat.cid"}},
+	}
+
+	// create collection
+	err := r.database.CreateCollection(collectionName, indexes)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Upsert records the given DNS tunnel statistics in MongoDB
+func (r *repo) Upsert(tunnelMap map[string]*Input) {
+
+	// Create the workers
+	writerWorker := database.NewBulkWriter(r.database, r.config, r.log, true, "dnstunnel")
+
+	analyzerWorker := newAnalyzer(
+		r.config.S.Rolling.CurrentChunk,
+		r.config,
+		writerWorker.Collect,
+		writerWorker.Close,
+	)
+
+	// kick off the threaded goroutines
+	for i := 0; i < util.Max(1, runtime.NumCPU()/2); i++ {
+		analyzerWorker.start()
+		writerWorker.Start()
+	}
+
+	// progress bar for troubleshooting
+	p := mpb.New(mpb.WithWidth(20))
+	bar := p.AddBar(int64(len(tunnelMap)),
+		mpb.PrependDecorators(
+			decor.Name("\t[-] DNS Tunnel Analysis:", decor.WC{W: 30, C: decor.DidentRight}),
+			decor.CountersNoUnit(" %d / %d ", decor.WCSyncWidth),
+		),
+		mpb.AppendDecorators(decor.Percentage()),
+	)
+
+	// loop over map entries
+	for _, entry := range tunnelMap {
+		//Mongo Index key is limited to a size of 1024 https://docs.mongodb.com/v3.4/reference/limits/#index-limitations
+		//  so if the key is too large, we should cut it back, this is rough but
+		//  works. Figured 800 allows some wiggle room, while also not being too large
+		if len(entry.Domain) > 1024 {
+			entry.Domain = entry.Domain[:800]
+		}
+		analyzerWorker.collect(entry)
+		bar.IncrBy(1)
+	}
+
+	p.Wait()
+
+	// start the closing cascade (this will also close the other channels)
+	analyzerWorker.close()
+}
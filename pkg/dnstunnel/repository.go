@@ -0,0 +1,48 @@
+package dnstunnel
+
+import (
+	"github.com/activecm/rita/pkg/data"
+)
+
+type (
+	// Repository for dnsTunnel collection
+	Repository interface {
+		CreateIndexes() error
+		Upsert(tunnelMap map[string]*Input)
+	}
+
+	//Input holds the query statistics gathered for a registered domain
+	//and the subdomains queried beneath it
+	Input struct {
+		Domain           string                    // registered domain (e.g. example.com)
+		QueryCount       int64                     // number of queries for the domain and its subdomains
+		Subdomains       data.StringSet            // unique subdomains queried beneath the domain
+		ClientSubdomains map[string]data.StringSet // unique subdomains queried by each client, keyed by the client's MapKey
+		EntropySum       float64                   // sum of the Shannon entropy of each query's subdomain
+		LabelLengthSum   int64                     // sum of the length of the longest label in each query
+		MaxLabelLength   int64                     // length of the longest label seen in any query
+		TXTCount         int64                     // number of TXT queries
+		NULLCount        int64                     // number of NULL queries
+		CNAMECount       int64                     // number of CNAME queries
+		QueryBytes       int64                     // total length of the queried names
+		AnswerBytes      int64                     // total length of the answers received
+	}
+
+	//Result represents a registered domain along with the statistics
+	//used to score it as a potential DNS tunnel
+	Result struct {
+		Domain              string  `bson:"domain" json:"domain"`
+		Queries             int64   `bson:"queries" json:"queries"`
+		UniqueSubdomains    int64   `bson:"unique_subdomains" json:"unique_subdomains"`
+		MaxClientSubdomains int64   `bson:"max_client_subdomains" json:"max_client_subdomains"`
+		AvgEntropy          float64 `bson:"avg_entropy" json:"avg_entropy"`
+		AvgLabelLength      float64 `bson:"avg_label_length" json:"avg_label_length"`
+		MaxLabelLength      int64   `bson:"max_label_length" json:"max_label_length"`
+		TXTCount            int64   `bson:"txt" json:"txt"`
+		NULLCount           int64   `bson:"null" json:"null"`
+		CNAMECount          int64   `bson:"cname" json:"cname"`
+		QTypeRatio          float64 `bson:"qtype_ratio" json:"qtype_ratio"`
+		BytesPerQuery       float64 `bson:"bytes_per_query" json:"bytes_per_query"`
+		Score               float64 `bson:"score" json:"score"`
+	}
+)
@@ -0,0 +1,170 @@
+package dnstunnel
+
+import (
+	"math"
+
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/resources"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// minQueries is the number of queries a domain needs before it is scored.
+	// Fewer queries don't provide enough evidence for the statistics to be meaningful.
+	minQueries = 10
+
+	// the ranges over which each subscore climbs from zero to one
+	entropyFloor          = 2.5 // bits per character of an ordinary hostname
+	entropyCeiling        = 4.0 // bits per character of base32 or hex encoded data
+	labelLengthFloor      = 12.0
+	labelLengthCeiling    = 40.0 // labels are limited to 63 characters
+	bytesPerQueryFloor    = 60.0
+	bytesPerQueryCeiling  = 200.0
+	clientSubdomainsScale = 3.0 // log10 of the unique subdomains per client which scores one
+)
+
+//Results returns registered domains which match the given filter scored by how likely
+//they are to be used for DNS tunneling. limit and noLimit control how many results are returned.
+func Results(res *resources.Resources, limit int, noLimit bool, filt filter.Filter) ([]Result, error) {
+	var tunnelResults []Result
+
+	tunnelQuery, err := ResultsQuery(filt)
+	if err != nil {
+		return tunnelResults, err
+	}
+
+	if !noLimit {
+		tunnelQuery = append(tunnelQuery, bson.M{"$limit": limit})
+	}
+
+	err = database.AggregateAll(res.DB.Context(), res.DB.Collection(res.Config.T.DNS.DNSTunnelTable), tunnelQuery, &tunnelResults)
+
+	return tunnelResults, err
+}
+
+//ResultsQuery builds the aggregation pipeline used by Results, sorted by score
+func ResultsQuery(filt filter.Filter) ([]bson.M, error) {
+	filterPredicate, err := filt.Predicate(filter.Fields{FQDN: "domain"})
+	if err != nil {
+		return nil, err
+	}
+
+	scorePredicate, err := filt.Predicate(filter.Fields{Score: "score"})
+	if err != nil {
+		return nil, err
+	}
+
+	tunnelQuery := []bson.M{
+		{"$match": filterPredicate},
+		{"$unwind": "$dat"},
+		{"$group": bson.M{
+			"_id":                   "$domain",
+			"queries":               bson.M{"$sum": "$dat.queries"},
+			"subdomains":            bson.M{"$push": "$dat.subdomains"},
+			"session_subdomains":    bson.M{"$max": "$dat.unique_subdomains"},
+			"max_client_subdomains": bson.M{"$max": "$dat.max_client_subdomains"},
+			"entropy_sum":           bson.M{"$sum": "$dat.entropy_sum"},
+			"label_length_sum":      bson.M{"$sum": "$dat.label_length_sum"},
+			"max_label_length":      bson.M{"$max": "$dat.max_label_length"},
+			"txt":                   bson.M{"$sum": "$dat.txt"},
+			"null":                  bson.M{"$sum": "$dat.null"},
+			"cname":                 bson.M{"$sum": "$dat.cname"},
+			"query_bytes":           bson.M{"$sum": "$dat.query_bytes"},
+			"answer_bytes":          bson.M{"$sum": "$dat.answer_bytes"},
+		}},
+		{"$match": bson.M{"queries": bson.M{"$gte": minQueries}}},
+		{"$project": bson.M{
+			"_id":                   0,
+			"domain":                "$_id",
+			"queries":               1,
+			"unique_subdomains":     uniqueSubdomainsQuery(),
+			"max_client_subdomains": 1,
+			"max_label_length":      1,
+			"txt":                   1,
+			"null":                  1,
+			"cname":                 1,
+			"avg_entropy":           bson.M{"$divide": []interface{}{"$entropy_sum", "$queries"}},
+			"avg_label_length":      bson.M{"$divide": []interface{}{"$label_length_sum", "$queries"}},
+			"bytes_per_query": bson.M{"$divide": []interface{}{
+				bson.M{"$add": []interface{}{"$query_bytes", "$answer_bytes"}}, "$queries",
+			}},
+		}},
+		{"$addFields": bson.M{"qtype_ratio": qtypeRatioQuery(), "score": scoreQuery()}},
+		{"$match": scorePredicate},
+		{"$sort": bson.D{{Key: "score", Value: -1}, {Key: "domain", Value: 1}}},
+	}
+
+	return tunnelQuery, nil
+}
+
+// scoreResult fills in the derived qtype ratio and the overall score of a result.
+// The score is the average of five subscores, each scaled between zero and one:
+// query name entropy, label length, the ratio of TXT, NULL, and CNAME queries,
+// the number of unique subdomains queried by a single client, and bytes per query.
+// Results are scored in MongoDB by scoreQuery, which mirrors this function.
+func scoreResult(result *Result) {
+	result.QTypeRatio = float64(result.TXTCount+result.NULLCount+result.CNAMECount) / float64(result.Queries)
+
+	entropyScore := scale(result.AvgEntropy, entropyFloor, entropyCeiling)
+	labelLengthScore := scale(result.AvgLabelLength, labelLengthFloor, labelLengthCeiling)
+	qtypeScore := scale(result.QTypeRatio, 0, 1)
+	subdomainScore := 0.0
+	if result.MaxClientSubdomains > 0 {
+		subdomainScore = scale(math.Log10(float64(result.MaxClientSubdomains)), 0, clientSubdomainsScale)
+	}
+	bytesScore := scale(result.BytesPerQuery, bytesPerQueryFloor, bytesPerQueryCeiling)
+
+	result.Score = math.Ceil(((entropyScore+labelLengthScore+qtypeScore+subdomainScore+bytesScore)/5.0)*1000) / 1000
+}
+
+// scale maps value onto [0, 1], where floor and below maps to zero and ceiling and above maps to one
+func scale(value, floor, ceiling float64) float64 {
+	return math.Max(0, math.Min(1, (value-floor)/(ceiling-floor)))
+}
+
+// scoreQuery builds an aggregation expression which computes the score scoreResult gives
+// a result, so the results can be filtered and sorted by score in MongoDB
+func scoreQuery() bson.M {
+	subdomainScore := bson.M{"$cond": []interface{}{
+		bson.M{"$gt": []interface{}{"$max_client_subdomains", 0}},
+		scaleQuery(bson.M{"$log10": "$max_client_subdomains"}, 0, clientSubdomainsScale),
+		0,
+	}}
+
+	subscores := bson.M{"$add": []interface{}{
+		scaleQuery("$avg_entropy", entropyFloor, entropyCeiling),
+		scaleQuery("$avg_label_length", labelLengthFloor, labelLengthCeiling),
+		scaleQuery(qtypeRatioQuery(), 0, 1),
+		subdomainScore,
+		scaleQuery("$bytes_per_query", bytesPerQueryFloor, bytesPerQueryCeiling),
+	}}
+	score := bson.M{"$divide": []interface{}{subscores, 5.0}}
+	return bson.M{"$divide": []interface{}{bson.M{"$ceil": bson.M{"$multiply": []interface{}{score, 1000}}}, 1000}}
+}
+
+// uniqueSubdomainsQuery builds an aggregation expression which counts the subdomains stored
+// across every import session. Each session stores a limited number of subdomains, so the
+// count is never lower than the number of unique subdomains seen in a single session.
+func uniqueSubdomainsQuery() bson.M {
+	storedSubdomains := bson.M{"$reduce": bson.M{
+		"input":        "$subdomains",
+		"initialValue": []string{},
+		"in":           bson.M{"$setUnion": []interface{}{"$$value", bson.M{"$ifNull": []interface{}{"$$this", []string{}}}}},
+	}}
+	return bson.M{"$max": []interface{}{bson.M{"$size": storedSubdomains}, "$session_subdomains"}}
+}
+
+// qtypeRatioQuery builds an aggregation expression which computes the qtype ratio of a result
+func qtypeRatioQuery() bson.M {
+	return bson.M{"$divide": []interface{}{
+		bson.M{"$add": []interface{}{"$txt", "$null", "$cname"}}, "$queries",
+	}}
+}
+
+// scaleQuery builds an aggregation expression which scales a value the same way as scale
+func scaleQuery(value interface{}, floor, ceiling float64) bson.M {
+	return bson.M{"$max": []interface{}{0, bson.M{"$min": []interface{}{1, bson.M{"$divide": []interface{}{
+		bson.M{"$subtract": []interface{}{value, floor}}, ceiling - floor,
+	}}}}}}
+}
@@ -0,0 +1,34 @@
+package dnstunnel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreResult(t *testing.T) {
+	ordinary := Result{
+		Domain:              "example.com",
+		Queries:             500,
+		MaxClientSubdomains: 3,
+		AvgEntropy:          2.1,
+		AvgLabelLength:      6,
+		BytesPerQuery:       45,
+	}
+	scoreResult(&ordinary)
+
+	tunnel := Result{
+		Domain:              "tunnel.example",
+		Queries:             5000,
+		MaxClientSubdomains: 4800,
+		AvgEntropy:          4.3,
+		AvgLabelLength:      52,
+		TXTCount:            5000,
+		BytesPerQuery:       240,
+	}
+	scoreResult(&tunnel)
+
+	assert.InDelta(t, 0.032, ordinary.Score, 0.0001)
+	assert.Equal(t, 1.0, tunnel.Score)
+	assert.Equal(t, 1.0, tunnel.QTypeRatio)
+}
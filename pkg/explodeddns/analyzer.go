@@ -7,7 +7,7 @@ import (
 
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 type (
@@ -53,14 +53,13 @@ func (a *analyzer) close() {
 func (a *analyzer) start() {
 	a.analysisWg.Add(1)
 	go func() {
-		ssn := a.db.Session.Copy()
-		defer ssn.Close()
+		ctx := a.db.Context()
 		for data := range a.analysisChannel {
 
 			// check if this query string has already been parsed to add to the subdomain count by checking
 			// if the whole string is already in the hostname table.
-			nHostnameEntries, _ := ssn.DB(a.db.GetSelectedDB()).C(a.conf.T.DNS.HostnamesTable).
-				Find(bson.M{"host": data.name}).Count()
+			nHostnameEntries, _ := a.db.Collection(a.conf.T.DNS.HostnamesTable).
+				CountDocuments(ctx, bson.M{"host": data.name})
 
 			// flag to keep track of whether we need to increment the subs count
 			alreadyCountedSubsFlag := false
@@ -91,8 +90,11 @@ func (a *analyzer) start() {
 
 				var existingEntries []dns
 
-				_ = ssn.DB(a.db.GetSelectedDB()).C(a.conf.T.DNS.ExplodedDNSTable).
-					Find(bson.M{"domain": entry}).All(&existingEntries)
+				existingIter, err := a.db.Collection(a.conf.T.DNS.ExplodedDNSTable).
+					Find(ctx, bson.M{"domain": entry})
+				if err == nil {
+					_ = existingIter.All(ctx, &existingEntries)
+				}
 
 				// if this is a brand NEW domain string and isn't in the exploded dns table:
 				if len(existingEntries) <= 0 {
@@ -6,7 +6,6 @@ import (
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/util"
-	"github.com/globalsign/mgo"
 	"github.com/vbauerster/mpb"
 	"github.com/vbauerster/mpb/decor"
 
@@ -30,14 +29,11 @@ func NewMongoRepository(db *database.DB, conf *config.Config, logger *log.Logger
 
 // CreateIndexes creates indexes for the explodedDns collection
 func (r *repo) CreateIndexes() error {
-	session := r.database.Session.Copy()
-	defer session.Close()
-
 	// set collection name
 	collectionName := r.config.T.DNS.ExplodedDNSTable
 
 	// check if collection already exists
-	names, _ := session.DB(r.database.GetSelectedDB()).CollectionNames()
+	names, _ := r.database.CollectionNames()
 
 	// if collection exists, we don't need to do anything else
 	for _, name := range names {
@@ -47,7 +43,7 @@ func (r *repo) CreateIndexes() error {
 	}
 
 	// set desired indexes
-	indexes := []mgo.Index{
+	indexes := []database.Index{
 		{Key: []string{"domain"}, Unique: true},
 		// {Key: []string{"visited"}},
 		{Key: []string{"subdomain_count"}},
@@ -24,7 +24,7 @@ type dns struct {
 // for that hostname, and how many times that hostname and its subdomains
 // were looked up.
 type Result struct {
-	Domain         string `bson:"domain"`
-	SubdomainCount int64  `bson:"subdomain_count"`
-	Visited        int64  `bson:"visited"`
+	Domain         string `bson:"domain" json:"domain"`
+	SubdomainCount int64  `bson:"subdomain_count" json:"subdomain_count"`
+	Visited        int64  `bson:"visited" json:"visited"`
 }
@@ -1,19 +1,40 @@
 package explodeddns
 
 import (
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/filter"
 	"github.com/activecm/rita/resources"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
-//Results returns hostnames and their subdomain/ lookup statistics from the database.
-//limit and noLimit control how many results are returned.
-func Results(res *resources.Resources, limit int, noLimit bool) ([]Result, error) {
-	ssn := res.DB.Session.Copy()
-	defer ssn.Close()
-
+//Results returns hostnames and their subdomain/ lookup statistics from the database
+//which match the given filter. limit and noLimit control how many results are returned.
+func Results(res *resources.Resources, limit int, noLimit bool, filt filter.Filter) ([]Result, error) {
 	var explodedDNSResults []Result
 
+	explodedDNSQuery, err := ResultsQuery(filt)
+	if err != nil {
+		return explodedDNSResults, err
+	}
+
+	if !noLimit {
+		explodedDNSQuery = append(explodedDNSQuery, bson.M{"$limit": limit})
+	}
+
+	err = database.AggregateAll(res.DB.Context(), res.DB.Collection(res.Config.T.DNS.ExplodedDNSTable), explodedDNSQuery, &explodedDNSResults)
+
+	return explodedDNSResults, err
+}
+
+//ResultsQuery builds the aggregation pipeline used by Results, sorted by subdomain count
+func ResultsQuery(filt filter.Filter) ([]bson.M, error) {
+	filterPredicate, err := filt.Predicate(filter.Fields{FQDN: "domain"})
+	if err != nil {
+		return nil, err
+	}
+
 	explodedDNSQuery := []bson.M{
+		bson.M{"$match": filterPredicate},
 		bson.M{"$unwind": "$dat"},
 		bson.M{"$project": bson.M{"domain": 1, "subdomain_count": 1, "visited": "$dat.visited"}},
 		bson.M{"$group": bson.M{
@@ -31,12 +52,5 @@ func Results(res *resources.Resources, limit int, noLimit bool) ([]Result, error
 		bson.M{"$sort": bson.M{"subdomain_count": -1}},
 	}
 
-	if !noLimit {
-		explodedDNSQuery = append(explodedDNSQuery, bson.M{"$limit": limit})
-	}
-
-	err := ssn.DB(res.DB.GetSelectedDB()).C(res.Config.T.DNS.ExplodedDNSTable).Pipe(explodedDNSQuery).AllowDiskUse().All(&explodedDNSResults)
-
-	return explodedDNSResults, err
-
+	return explodedDNSQuery, nil
 }
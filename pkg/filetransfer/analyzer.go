@@ -0,0 +1,174 @@
+package filetransfer
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/data"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type (
+	//analyzer is a structure for file transfer analysis
+	analyzer struct {
+		chunk            int                        // current chunk (0 if not on rolling analysis)
+		conf             *config.Config             // contains details needed to access MongoDB
+		sessionMap       map[string]*Session        // server names requested over each connection, keyed by Zeek UID
+		analyzedCallback func(database.BulkChanges) // called on each analyzed result
+		closedCallback   func()                     // called when .close() is called and no more calls to analyzedCallback will be made
+		transferChannel  chan *Input                // holds unanalyzed files
+		uploadChannel    chan *UploadInput          // holds unanalyzed uploads
+		analysisWg       sync.WaitGroup             // wait for analysis to finish
+	}
+)
+
+// newAnalyzer creates a new analyzer for recording suspicious files and uploads
+func newAnalyzer(chunk int, conf *config.Config, sessionMap map[string]*Session,
+	analyzedCallback func(database.BulkChanges), closedCallback func()) *analyzer {
+	return &analyzer{
+		chunk:            chunk,
+		conf:             conf,
+		sessionMap:       sessionMap,
+		analyzedCallback: analyzedCallback,
+		closedCallback:   closedCallback,
+		transferChannel:  make(chan *Input),
+		uploadChannel:    make(chan *UploadInput),
+	}
+}
+
+// collectTransfer gathers files for analysis
+func (a *analyzer) collectTransfer(datum *Input) {
+	a.transferChannel <- datum
+}
+
+// collectUpload gathers uploads for analysis
+func (a *analyzer) collectUpload(datum *UploadInput) {
+	a.uploadChannel <- datum
+}
+
+// close waits for the analyzer to finish
+func (a *analyzer) close() {
+	close(a.transferChannel)
+	close(a.uploadChannel)
+	a.analysisWg.Wait()
+	a.closedCallback()
+}
+
+// start kicks off a new analysis thread
+func (a *analyzer) start() {
+	a.analysisWg.Add(2)
+	go func() {
+		for datum := range a.transferChannel {
+			session := resolveSession(datum.UIDs, a.sessionMap)
+			a.analyzedCallback(database.BulkChanges{
+				a.conf.T.FileTransfer.FileTransferTable: []database.BulkChange{{
+					Selector: bson.M{"fuid": datum.FUID},
+					Update:   transferQuery(datum, session, a.chunk),
+					Upsert:   true,
+				}},
+			})
+		}
+
+		a.analysisWg.Done()
+	}()
+
+	go func() {
+		for datum := range a.uploadChannel {
+			a.analyzedCallback(database.BulkChanges{
+				a.conf.T.FileTransfer.FileUploadTable: []database.BulkChange{{
+					Selector: datum.Hosts.BSONKey(),
+					Update:   uploadQuery(datum, resolveFQDNs(datum.UIDs, a.sessionMap), a.chunk),
+					Upsert:   true,
+				}},
+			})
+		}
+
+		a.analysisWg.Done()
+	}()
+}
+
+// transferQuery returns a mgo query which records the given file in the fileTransfer collection
+func transferQuery(datum *Input, session Session, chunk int) bson.M {
+	protocol := session.Protocol
+	if protocol == "" {
+		protocol = datum.Source
+	}
+
+	return bson.M{
+		"$set": bson.M{
+			"src":              datum.Hosts.SrcIP,
+			"src_network_uuid": datum.Hosts.SrcNetworkUUID,
+			"src_network_name": datum.Hosts.SrcNetworkName,
+			"dst":              datum.Hosts.DstIP,
+			"dst_network_uuid": datum.Hosts.DstNetworkUUID,
+			"dst_network_name": datum.Hosts.DstNetworkName,
+			"ts":               datum.TimeStamp,
+			"fqdn":             session.FQDN,
+			"protocol":         protocol,
+			"direction":        datum.Direction,
+			"mime_type":        datum.MimeType,
+			"filename":         datum.Filename,
+			"category":         datum.Category,
+			"bytes":            datum.Bytes,
+			"md5":              datum.MD5,
+			"sha1":             datum.SHA1,
+			"sha256":           datum.SHA256,
+			"ioc":              datum.IOC,
+			"cid":              chunk,
+		},
+	}
+}
+
+// uploadQuery returns a mgo query which pushes the given uploads into the fileUpload collection
+func uploadQuery(datum *UploadInput, fqdns []string, chunk int) bson.M {
+	return bson.M{
+		"$push": bson.M{
+			"dat": bson.M{
+				"bytes":      datum.Bytes,
+				"files":      datum.Files,
+				"fqdns":      fqdns,
+				"first_seen": datum.FirstSeen,
+				"last_seen":  datum.LastSeen,
+				"cid":        chunk,
+			},
+		},
+		"$set": bson.M{
+			"cid":              chunk,
+			"src_network_name": datum.Hosts.SrcNetworkName,
+			"dst_network_name": datum.Hosts.DstNetworkName,
+		},
+	}
+}
+
+// resolveSession returns the session of the first connection which carried the file
+// and had a server name. The UIDs are sorted so the same session is always picked.
+func resolveSession(uids data.StringSet, sessionMap map[string]*Session) Session {
+	sortedUIDs := uids.Items()
+	sort.Strings(sortedUIDs)
+	for _, uid := range sortedUIDs {
+		if session, ok := sessionMap[uid]; ok {
+			return *session
+		}
+	}
+	return Session{}
+}
+
+// resolveFQDNs returns the server names requested over the given connections
+func resolveFQDNs(uids data.StringSet, sessionMap map[string]*Session) []string {
+	fqdns := make(data.StringSet)
+	for uid := range uids {
+		if session, ok := sessionMap[uid]; ok && session.FQDN != "" {
+			fqdns.Insert(session.FQDN)
+		}
+	}
+
+	// capped in order to prevent hitting the MongoDB document size limits
+	items := fqdns.Items()
+	sort.Strings(items)
+	if len(items) > 10 {
+		items = items[:10]
+	}
+	return items
+}
@@ -0,0 +1,97 @@
+package filetransfer
+
+import (
+	"path"
+	"strings"
+)
+
+// the categories of files which are recorded when they are downloaded
+const (
+	CategoryExecutable = "executable"
+	CategoryScript     = "script"
+	CategoryArchive    = "archive"
+)
+
+// mimeCategories maps the MIME types Zeek detects from file contents onto file categories
+var mimeCategories = map[string]string{
+	"application/x-dosexec":                   CategoryExecutable,
+	"application/x-executable":                CategoryExecutable,
+	"application/x-mach-o-executable":         CategoryExecutable,
+	"application/x-sharedlib":                 CategoryExecutable,
+	"application/x-msdownload":                CategoryExecutable,
+	"application/x-ms-installer":              CategoryExecutable,
+	"application/x-msi":                       CategoryExecutable,
+	"application/java-archive":                CategoryExecutable,
+	"application/x-java-applet":               CategoryExecutable,
+	"application/vnd.android.package-archive": CategoryExecutable,
+	"application/x-elf":                       CategoryExecutable,
+	"text/x-shellscript":                      CategoryScript,
+	"text/x-python":                           CategoryScript,
+	"text/x-perl":                             CategoryScript,
+	"text/x-ruby":                             CategoryScript,
+	"text/x-msdos-batch":                      CategoryScript,
+	"application/x-powershell":                CategoryScript,
+	"application/hta":                         CategoryScript,
+	"application/zip":                         CategoryArchive,
+	"application/x-rar":                       CategoryArchive,
+	"application/x-rar-compressed":            CategoryArchive,
+	"application/x-7z-compressed":             CategoryArchive,
+	"application/gzip":                        CategoryArchive,
+	"application/x-gzip":                      CategoryArchive,
+	"application/x-bzip2":                     CategoryArchive,
+	"application/x-xz":                        CategoryArchive,
+	"application/x-tar":                       CategoryArchive,
+	"application/vnd.ms-cab-compressed":       CategoryArchive,
+	"application/x-iso9660-image":             CategoryArchive,
+}
+
+// extensionCategories maps filename extensions onto file categories. Zeek can't
+// always detect the MIME type of scripts since they are plain text.
+var extensionCategories = map[string]string{
+	".exe":  CategoryExecutable,
+	".dll":  CategoryExecutable,
+	".scr":  CategoryExecutable,
+	".sys":  CategoryExecutable,
+	".msi":  CategoryExecutable,
+	".jar":  CategoryExecutable,
+	".apk":  CategoryExecutable,
+	".elf":  CategoryExecutable,
+	".ps1":  CategoryScript,
+	".psm1": CategoryScript,
+	".vbs":  CategoryScript,
+	".vbe":  CategoryScript,
+	".jse":  CategoryScript,
+	".wsf":  CategoryScript,
+	".hta":  CategoryScript,
+	".bat":  CategoryScript,
+	".cmd":  CategoryScript,
+	".sh":   CategoryScript,
+	".py":   CategoryScript,
+	".pl":   CategoryScript,
+	".zip":  CategoryArchive,
+	".rar":  CategoryArchive,
+	".7z":   CategoryArchive,
+	".gz":   CategoryArchive,
+	".tgz":  CategoryArchive,
+	".bz2":  CategoryArchive,
+	".xz":   CategoryArchive,
+	".tar":  CategoryArchive,
+	".cab":  CategoryArchive,
+	".iso":  CategoryArchive,
+}
+
+// Category classifies a file as an executable, script, or archive by its MIME type,
+// falling back to the extension of its filename. An empty string is returned if
+// the file doesn't fall into any of the categories.
+func Category(mimeType, filename string) string {
+	if category, ok := mimeCategories[strings.ToLower(mimeType)]; ok {
+		return category
+	}
+
+	// the filename may be a path or URL as reported by the protocol
+	if category, ok := extensionCategories[strings.ToLower(path.Ext(filename))]; ok {
+		return category
+	}
+
+	return ""
+}
@@ -0,0 +1,31 @@
+package filetransfer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategory(t *testing.T) {
+	testCases := []struct {
+		mimeType string
+		filename string
+		category string
+	}{
+		{"application/x-dosexec", "", CategoryExecutable},
+		{"application/x-dosexec", "update.txt", CategoryExecutable},
+		{"", "/downloads/Setup.EXE", CategoryExecutable},
+		{"text/plain", "invoice.ps1", CategoryScript},
+		{"text/x-shellscript", "", CategoryScript},
+		{"application/zip", "", CategoryArchive},
+		{"application/octet-stream", "backup.tar.gz", CategoryArchive},
+		// scripts served with web pages are too common to report
+		{"application/javascript", "app.js", ""},
+		{"text/html", "index.html", ""},
+		{"", "", ""},
+	}
+
+	for _, test := range testCases {
+		assert.Equal(t, test.category, Category(test.mimeType, test.filename), "%s %s", test.mimeType, test.filename)
+	}
+}
@@ -0,0 +1,94 @@
+package filetransfer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IOCHashes maps the lower case MD5, SHA1, or SHA256 hashes of known bad files
+// onto a description of each indicator
+type IOCHashes map[string]string
+
+// hashLengths holds the number of hex digits in MD5, SHA1, and SHA256 hashes
+var hashLengths = map[int]bool{32: true, 40: true, 64: true}
+
+// LoadIOCHashes reads the given IOC files. Each line of a file holds a hash, optionally
+// followed by a description separated by whitespace or a comma. Blank lines and lines
+// starting with # are ignored. The name of the file is used if a hash has no description.
+func LoadIOCHashes(paths []string) (IOCHashes, error) {
+	hashes := make(IOCHashes)
+	for _, iocPath := range paths {
+		err := hashes.load(iocPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return hashes, nil
+}
+
+// load adds the hashes in the given IOC file
+func (h IOCHashes) load(iocPath string) error {
+	iocFile, err := os.Open(iocPath)
+	if err != nil {
+		return fmt.Errorf("could not open IOC hash file: %v", err)
+	}
+	defer iocFile.Close()
+
+	scanner := bufio.NewScanner(iocFile)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash, description := line, filepath.Base(iocPath)
+		if sep := strings.IndexAny(line, " \t,"); sep != -1 {
+			hash = line[:sep]
+			if rest := strings.Trim(line[sep:], " \t,"); rest != "" {
+				description = rest
+			}
+		}
+
+		if !isHexHash(hash) {
+			return fmt.Errorf("%s line %d: %q is not an MD5, SHA1, or SHA256 hash", iocPath, lineNumber, hash)
+		}
+		h[strings.ToLower(hash)] = description
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("could not read IOC hash file: %v", err)
+	}
+	return nil
+}
+
+// Match returns the description of the first of the given hashes which is a known
+// IOC. Empty hashes are skipped.
+func (h IOCHashes) Match(hashes ...string) (string, bool) {
+	for _, hash := range hashes {
+		if hash == "" {
+			continue
+		}
+		if description, ok := h[strings.ToLower(hash)]; ok {
+			return description, true
+		}
+	}
+	return "", false
+}
+
+// isHexHash checks whether the given string looks like an MD5, SHA1, or SHA256 hash
+func isHexHash(hash string) bool {
+	if !hashLengths[len(hash)] {
+		return false
+	}
+	for _, r := range hash {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
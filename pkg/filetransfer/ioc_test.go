@@ -0,0 +1,52 @@
+package filetransfer
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadIOCHashes(t *testing.T) {
+	dir := t.TempDir()
+	iocPath := filepath.Join(dir, "bad-hashes.txt")
+	contents := "# hashes from the latest incident\n" +
+		"\n" +
+		"0123456789ABCDEF0123456789ABCDEF\n" +
+		"0123456789abcdef0123456789abcdef01234567, Emotet loader\n" +
+		"0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef\tCobalt Strike beacon\n"
+	require.NoError(t, ioutil.WriteFile(iocPath, []byte(contents), 0644))
+
+	hashes, err := LoadIOCHashes([]string{iocPath})
+	require.NoError(t, err)
+	assert.Len(t, hashes, 3)
+
+	// hashes without a description are named after the file they were listed in
+	description, ok := hashes.Match("", "", "0123456789abcdef0123456789abcdef")
+	assert.True(t, ok)
+	assert.Equal(t, "bad-hashes.txt", description)
+
+	description, ok = hashes.Match("", "0123456789ABCDEF0123456789ABCDEF01234567")
+	assert.True(t, ok)
+	assert.Equal(t, "Emotet loader", description)
+
+	description, ok = hashes.Match("ffffffffffffffffffffffffffffffff", "",
+		"0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	assert.True(t, ok)
+	assert.Equal(t, "Cobalt Strike beacon", description)
+
+	_, ok = hashes.Match("ffffffffffffffffffffffffffffffff")
+	assert.False(t, ok)
+}
+
+func TestLoadIOCHashesErrors(t *testing.T) {
+	_, err := LoadIOCHashes([]string{filepath.Join(t.TempDir(), "missing.txt")})
+	assert.Error(t, err)
+
+	iocPath := filepath.Join(t.TempDir(), "bad-hashes.txt")
+	require.NoError(t, ioutil.WriteFile(iocPath, []byte("evil.example.com\n"), 0644))
+	_, err = LoadIOCHashes([]string{iocPath})
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package filetransfer
+
+import (
+	"runtime"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/vbauerster/mpb"
+	"github.com/vbauerster/mpb/decor"
+)
+
+type repo struct {
+	database *database.DB
+	config   *config.Config
+	log      *log.Logger
+}
+
+// NewMongoRepository bundles the given resources for updating MongoDB with file transfer data
+func NewMongoRepository(db *database.DB, conf *config.Config, logger *log.Logger) Repository {
+	return &repo{
+		database: db,
+		config:   conf,
+		log:      logger,
+	}
+}
+
+// CreateIndexes creates indexes for the fileTransfer and fileUpload collections
+func (r *repo) CreateIndexes() error {
+	// set desired indexes
+	collections := map[string][]database.Index{
+		r.config.T.FileTransfer.FileTransferTable: {
+			{Key: []string{"fuid"}, Unique: true},
+			{Key: []string{"src", "src_network_uuid"}},
+			{Key: []string{"fqdn"}},
+			{Key: []string{"md5"}},
+			{Key: []string{"sha1"}},
+			{Key: []string{"sha256"}},
+		},
+		r.config.T.FileTransfer.FileUploadTable: {
+			{Key: []string{"src", "src_network_uuid", "dst", "dst_network_uuid"}, Unique: true},
+			{Key: []string{"src", "src_network_uuid"}},
+		},
+	}
+
+	// check which collections already exist
+	names, _ := r.database.CollectionNames()
+
+	for collectionName, indexes := range collections {
+		// if collection exists, we don't need to do anything else
+		if util.StringInSlice(collectionName, names) {
+			continue
+		}
+
+		// create collection
+		err := r.database.CreateCollection(collectionName, indexes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Upsert records the given file transfer data in MongoDB. The sessions are used to look up
+// the server names which files were transferred from and to.
+func (r *repo) Upsert(transferMap map[string]*Input, uploadMap map[string]*UploadInput, sessionMap map[string]*Session) {
+	// Create the workers
+	writerWorker := database.NewBulkWriter(r.database, r.config, r.log, true, "filetransfer")
+
+	analyzerWorker := newAnalyzer(
+		r.config.S.Rolling.CurrentChunk,
+		r.config,
+		sessionMap,
+		writerWorker.Collect,
+		writerWorker.Close,
+	)
+
+	// kick off the threaded goroutines
+	for i := 0; i < util.Max(1, runtime.NumCPU()/2); i++ {
+		analyzerWorker.start()
+		writerWorker.Start()
+	}
+
+	// progress bar for troubleshooting
+	p := mpb.New(mpb.WithWidth(20))
+	bar := p.AddBar(int64(len(transferMap)+len(uploadMap)),
+		mpb.PrependDecorators(
+			decor.Name("\t[-] File Transfer Analysis:", decor.WC{W: 30, C: decor.DidentRight}),
+			decor.CountersNoUnit(" %d / %d ", decor.WCSyncWidth),
+		),
+		mpb.AppendDecorators(decor.Percentage()),
+	)
+
+	// loop over map entries
+	for _, value := range transferMap {
+		analyzerWorker.collectTransfer(value)
+		bar.IncrBy(1)
+	}
+
+	for _, value := range uploadMap {
+		analyzerWorker.collectUpload(value)
+		bar.IncrBy(1)
+	}
+
+	p.Wait()
+
+	// start the closing cascade (this will also close the other channels)
+	analyzerWorker.close()
+}
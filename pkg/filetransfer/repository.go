@@ -0,0 +1,85 @@
+package filetransfer
+
+import (
+	"github.com/activecm/rita/pkg/data"
+)
+
+// the directions a file may be transferred in, relative to the host which opened the connection
+const (
+	DirectionDownload = "download" // the server sent the file to the source host
+	DirectionUpload   = "upload"   // the source host sent the file to the server
+)
+
+// Repository for fileTransfer and fileUpload collections
+type Repository interface {
+	CreateIndexes() error
+	Upsert(transferMap map[string]*Input, uploadMap map[string]*UploadInput, sessionMap map[string]*Session)
+}
+
+// Session holds the server name requested over a connection which may carry files.
+// Files are linked to their sessions by the Zeek UID of the connection.
+type Session struct {
+	FQDN     string
+	Protocol string
+}
+
+// Input holds a file which is recorded individually because it may be malicious
+type Input struct {
+	FUID      string
+	Hosts     data.UniqueIPPair
+	UIDs      data.StringSet
+	Direction string
+	Source    string // protocol analyzer which found the file
+	MimeType  string
+	Filename  string
+	Category  string
+	Bytes     int64
+	MD5       string
+	SHA1      string
+	SHA256    string
+	IOC       string // description of the IOC the file's hash matched
+	TimeStamp int64
+}
+
+// UploadInput holds the files a source host uploaded to a server
+type UploadInput struct {
+	Hosts     data.UniqueIPPair
+	UIDs      data.StringSet
+	Bytes     int64
+	Files     int64
+	FirstSeen int64
+	LastSeen  int64
+}
+
+// Result represents a file transferred between two hosts along with
+// the reasons it was reported
+type Result struct {
+	data.UniqueIPPair `bson:",inline"`
+	FUID              string   `bson:"fuid" json:"fuid"`
+	TimeStamp         int64    `bson:"ts" json:"ts"`
+	FQDN              string   `bson:"fqdn" json:"fqdn"`
+	Protocol          string   `bson:"protocol" json:"protocol"`
+	Direction         string   `bson:"direction" json:"direction"`
+	MimeType          string   `bson:"mime_type" json:"mime_type"`
+	Filename          string   `bson:"filename" json:"filename"`
+	Category          string   `bson:"category" json:"category"`
+	Bytes             int64    `bson:"bytes" json:"bytes"`
+	MD5               string   `bson:"md5" json:"md5"`
+	SHA1              string   `bson:"sha1" json:"sha1"`
+	SHA256            string   `bson:"sha256" json:"sha256"`
+	IOC               string   `bson:"ioc" json:"ioc"`
+	FQDNSources       int64    `bson:"-" json:"fqdn_sources"`
+	FQDNFirstSeen     int64    `bson:"-" json:"fqdn_first_seen"`
+	Detections        []string `bson:"-" json:"detections"`
+}
+
+// UploadResult represents the files a source host uploaded over the dataset
+type UploadResult struct {
+	data.UniqueSrcIP `bson:",inline"`
+	Bytes            int64    `bson:"bytes" json:"bytes"`
+	Files            int64    `bson:"files" json:"files"`
+	Destinations     []string `bson:"destinations" json:"destinations"`
+	FQDNs            []string `bson:"fqdns" json:"fqdns"`
+	FirstSeen        int64    `bson:"first_seen" json:"first_seen"`
+	LastSeen         int64    `bson:"last_seen" json:"last_seen"`
+}
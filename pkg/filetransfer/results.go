@@ -0,0 +1,247 @@
+package filetransfer
+
+import (
+	"sort"
+
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/resources"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// the detections which may be reported for a file
+const (
+	DetectionIOC      = "ioc"       // the file's hash matched a local IOC file
+	DetectionRareFQDN = "rare_fqdn" // the file was downloaded from a server few hosts visit
+	DetectionNewFQDN  = "new_fqdn"  // the file was downloaded from a server first visited near the end of the dataset
+)
+
+const (
+	// rareFQDNSources is the most internal hosts which may visit a server for it to be rare
+	rareFQDNSources = 2
+
+	// newFQDNWindow is how close to the end of the dataset a server must be first visited to be new (24 hours)
+	newFQDNWindow = 24 * 60 * 60
+)
+
+// fqdnStats summarizes the hosts which visited a server over HTTP or TLS
+type fqdnStats struct {
+	FQDN      string `bson:"_id"`
+	Sources   int64  `bson:"sources"`
+	FirstSeen int64  `bson:"first_seen"`
+}
+
+//Results returns the executables, scripts, and archives downloaded from rare or newly seen servers
+//along with every file which matched an IOC hash. limit and noLimit control how many results are returned.
+func Results(res *resources.Resources, limit int, noLimit bool, filt filter.Filter) ([]Result, error) {
+	ctx := res.DB.Context()
+
+	var transferResults []Result
+
+	filterPredicate, err := filt.Predicate(filter.Fields{
+		Src:            "src",
+		SrcNetworkName: "src_network_name",
+		Dst:            "dst",
+		DstNetworkName: "dst_network_name",
+		FirstSeen:      "ts",
+		LastSeen:       "ts",
+		FQDN:           "fqdn",
+	})
+	if err != nil {
+		return transferResults, err
+	}
+
+	transferQuery := []bson.M{
+		{"$match": filterPredicate},
+		{"$project": bson.M{"_id": 0, "cid": 0}},
+	}
+
+	err = database.AggregateAll(ctx, res.DB.Collection(res.Config.T.FileTransfer.FileTransferTable), transferQuery, &transferResults)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := serverStats(res, transferResults)
+	if err != nil {
+		return nil, err
+	}
+
+	datasetStart, datasetEnd, _ := res.MetaDB.GetTSRange(res.DB.GetSelectedDB())
+
+	// keep the files which were detected once the servers they came from are known
+	detected := transferResults[:0]
+	for _, result := range transferResults {
+		if stat, ok := stats[result.FQDN]; ok {
+			result.FQDNSources = stat.Sources
+			result.FQDNFirstSeen = stat.FirstSeen
+		}
+		detect(&result, datasetStart, datasetEnd)
+		if len(result.Detections) > 0 {
+			detected = append(detected, result)
+		}
+	}
+	transferResults = detected
+
+	sort.SliceStable(transferResults, func(i, j int) bool {
+		iIOC, jIOC := transferResults[i].IOC != "", transferResults[j].IOC != ""
+		if iIOC != jIOC {
+			return iIOC
+		}
+		if len(transferResults[i].Detections) != len(transferResults[j].Detections) {
+			return len(transferResults[i].Detections) > len(transferResults[j].Detections)
+		}
+		return transferResults[i].TimeStamp > transferResults[j].TimeStamp
+	})
+
+	if !noLimit && len(transferResults) > limit {
+		transferResults = transferResults[:limit]
+	}
+
+	return transferResults, nil
+}
+
+// serverStats counts the internal hosts which visited each server that files were transferred with
+// and finds when each server was first visited. Every host is counted regardless of the filter.
+func serverStats(res *resources.Resources, transfers []Result) (map[string]fqdnStats, error) {
+	fqdns := make([]string, 0, len(transfers))
+	for _, transfer := range transfers {
+		if transfer.FQDN != "" {
+			fqdns = append(fqdns, transfer.FQDN)
+		}
+	}
+
+	stats := make(map[string]fqdnStats)
+	if len(fqdns) == 0 {
+		return stats, nil
+	}
+
+	// flatten merges the timestamp lists gathered from each dat subdocument
+	flatten := func(field string) bson.M {
+		return bson.M{"$reduce": bson.M{
+			"input":        bson.M{"$ifNull": []interface{}{field, []interface{}{}}},
+			"initialValue": []interface{}{},
+			"in":           bson.M{"$concatArrays": []string{"$$value", "$$this"}},
+		}}
+	}
+
+	statsQuery := []bson.M{
+		{"$match": bson.M{"fqdn": bson.M{"$in": fqdns}}},
+		{"$project": bson.M{
+			"fqdn": 1,
+			"first_seen": bson.M{"$min": bson.M{"$concatArrays": []bson.M{
+				flatten("$dat.tls.ts"), flatten("$dat.http.ts"),
+			}}},
+		}},
+		// each document holds the connections from a single source to the server
+		{"$group": bson.M{
+			"_id":        "$fqdn",
+			"sources":    bson.M{"$sum": 1},
+			"first_seen": bson.M{"$min": "$first_seen"},
+		}},
+	}
+
+	var fqdnResults []fqdnStats
+	err := database.AggregateAll(res.DB.Context(), res.DB.Collection(res.Config.T.Structure.SNIConnTable), statsQuery, &fqdnResults)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stat := range fqdnResults {
+		stats[stat.FQDN] = stat
+	}
+	return stats, nil
+}
+
+// detect lists the reasons the file should be reported. Servers are only considered new
+// if the dataset spans more than the new server window.
+func detect(result *Result, datasetStart, datasetEnd int64) {
+	result.Detections = []string{}
+
+	if result.IOC != "" {
+		result.Detections = append(result.Detections, DetectionIOC)
+	}
+
+	// only downloads of risky files are checked against the servers they came from
+	if result.Direction != DirectionDownload || result.Category == "" || result.FQDN == "" {
+		return
+	}
+
+	if result.FQDNSources > 0 && result.FQDNSources <= rareFQDNSources {
+		result.Detections = append(result.Detections, DetectionRareFQDN)
+	}
+
+	if datasetEnd-datasetStart > newFQDNWindow && result.FQDNFirstSeen > 0 &&
+		result.FQDNFirstSeen >= datasetEnd-newFQDNWindow {
+		result.Detections = append(result.Detections, DetectionNewFQDN)
+	}
+}
+
+//UploadResults returns the source hosts which uploaded at least minBytes over the dataset.
+//limit and noLimit control how many results are returned.
+func UploadResults(res *resources.Resources, limit int, noLimit bool, filt filter.Filter, minBytes int64) ([]UploadResult, error) {
+	ctx := res.DB.Context()
+
+	var uploadResults []UploadResult
+
+	filterPredicate, err := filt.Predicate(filter.Fields{
+		Src:            "src",
+		SrcNetworkName: "src_network_name",
+		Dst:            "dst",
+		DstNetworkName: "dst_network_name",
+	})
+	if err != nil {
+		return uploadResults, err
+	}
+
+	uploadQuery := []bson.M{
+		{"$match": filterPredicate},
+		{"$unwind": "$dat"},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"src":              "$src",
+				"src_network_uuid": "$src_network_uuid",
+			},
+			"src_network_name": bson.M{"$last": "$src_network_name"},
+			"bytes":            bson.M{"$sum": "$dat.bytes"},
+			"files":            bson.M{"$sum": "$dat.files"},
+			"destinations":     bson.M{"$addToSet": "$dst"},
+			"fqdns":            bson.M{"$push": "$dat.fqdns"},
+			"first_seen":       bson.M{"$min": "$dat.first_seen"},
+			"last_seen":        bson.M{"$max": "$dat.last_seen"},
+		}},
+		{"$match": bson.M{"bytes": bson.M{"$gte": minBytes}}},
+		{"$project": bson.M{
+			"_id":              0,
+			"src":              "$_id.src",
+			"src_network_uuid": "$_id.src_network_uuid",
+			"src_network_name": 1,
+			"bytes":            1,
+			"files":            1,
+			"destinations":     1,
+			"fqdns": bson.M{"$reduce": bson.M{
+				"input":        "$fqdns",
+				"initialValue": []string{},
+				"in":           bson.M{"$setUnion": []string{"$$value", "$$this"}},
+			}},
+			"first_seen": 1,
+			"last_seen":  1,
+		}},
+		{"$sort": bson.M{"bytes": -1}},
+	}
+
+	if !noLimit {
+		uploadQuery = append(uploadQuery, bson.M{"$limit": limit})
+	}
+
+	err = database.AggregateAll(ctx, res.DB.Collection(res.Config.T.FileTransfer.FileUploadTable), uploadQuery, &uploadResults)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := range uploadResults {
+		sort.Strings(uploadResults[idx].Destinations)
+		sort.Strings(uploadResults[idx].FQDNs)
+	}
+
+	return uploadResults, nil
+}
@@ -0,0 +1,75 @@
+package filetransfer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetect(t *testing.T) {
+	// a dataset spanning two days
+	const start, end = 1622505600, 1622678400
+
+	download := func(sources, firstSeen int64) Result {
+		return Result{
+			Direction: DirectionDownload, Category: CategoryExecutable, FQDN: "dl.example.com",
+			FQDNSources: sources, FQDNFirstSeen: firstSeen,
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		result     Result
+		end        int64
+		detections []string
+	}{
+		{
+			name:       "popular server",
+			result:     download(50, start),
+			end:        end,
+			detections: []string{},
+		},
+		{
+			name:       "rare server",
+			result:     download(1, start),
+			end:        end,
+			detections: []string{DetectionRareFQDN},
+		},
+		{
+			name:       "rare server first seen near the end of the dataset",
+			result:     download(2, end-3600),
+			end:        end,
+			detections: []string{DetectionRareFQDN, DetectionNewFQDN},
+		},
+		{
+			name:       "every server is new in a short dataset",
+			result:     download(10, start+3600),
+			end:        start + 7200,
+			detections: []string{},
+		},
+		{
+			name: "upload to a rare server",
+			result: Result{
+				Direction: DirectionUpload, Category: CategoryArchive, FQDN: "dl.example.com", FQDNSources: 1,
+			},
+			end:        end,
+			detections: []string{},
+		},
+		{
+			name: "image matching an IOC",
+			result: Result{
+				Direction: DirectionDownload, IOC: "known dropper", FQDN: "dl.example.com", FQDNSources: 1,
+			},
+			end:        end,
+			detections: []string{DetectionIOC},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			result := test.result
+			detect(&result, start, test.end)
+			assert.Equal(t, test.detections, result.Detections)
+		})
+	}
+}
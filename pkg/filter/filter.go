@@ -0,0 +1,242 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type (
+	//Filter narrows down the results returned by an analysis module.
+	//The zero value matches every result.
+	Filter struct {
+		Src      []string // IP addresses, CIDR ranges, or network names of the source host
+		Dst      []string // IP addresses, CIDR ranges, or network names of the destination host
+		MinScore float64  // minimum score (inclusive)
+		MinConns int64    // minimum number of connections (inclusive)
+		Since    int64    // unix timestamp the results must have activity at or after
+		Until    int64    // unix timestamp the results must have activity at or before
+		FQDN     string   // glob pattern (e.g. *.example.com) the fully qualified domain name must match
+	}
+
+	//Fields names the document fields in which an analysis module stores each filtered value.
+	//Filters for fields left empty are not applied.
+	Fields struct {
+		Src            string // IP address of the source host
+		SrcNetworkName string // network name of the source host
+		Dst            string // IP address of the destination host
+		DstNetworkName string // network name of the destination host
+		Score          string
+		Conns          string
+		FirstSeen      string // earliest timestamp of the result
+		LastSeen       string // latest timestamp of the result
+		Timestamps     string // array of timestamps, used when first and last seen are not available
+		FQDN           string
+	}
+)
+
+//Predicate translates the filter into a MongoDB query predicate over the given fields.
+//An empty document is returned if no filters apply.
+func (f Filter) Predicate(fields Fields) (bson.M, error) {
+	var predicates []bson.M
+
+	if fields.Src != "" && len(f.Src) > 0 {
+		hostPredicate, err := hostsPredicate(f.Src, fields.Src, fields.SrcNetworkName)
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, hostPredicate)
+	}
+
+	if fields.Dst != "" && len(f.Dst) > 0 {
+		hostPredicate, err := hostsPredicate(f.Dst, fields.Dst, fields.DstNetworkName)
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, hostPredicate)
+	}
+
+	if fields.Score != "" && f.MinScore > 0 {
+		predicates = append(predicates, bson.M{fields.Score: bson.M{"$gte": f.MinScore}})
+	}
+
+	if fields.Conns != "" && f.MinConns > 0 {
+		predicates = append(predicates, bson.M{fields.Conns: bson.M{"$gte": f.MinConns}})
+	}
+
+	if f.Since > 0 || f.Until > 0 {
+		if fields.FirstSeen != "" && fields.LastSeen != "" {
+			// keep results which were active at some point during the window
+			if f.Since > 0 {
+				predicates = append(predicates, bson.M{fields.LastSeen: bson.M{"$gte": f.Since}})
+			}
+			if f.Until > 0 {
+				predicates = append(predicates, bson.M{fields.FirstSeen: bson.M{"$lte": f.Until}})
+			}
+		} else if fields.Timestamps != "" {
+			// keep results with at least one timestamp in the window
+			window := bson.M{}
+			if f.Since > 0 {
+				window["$gte"] = f.Since
+			}
+			if f.Until > 0 {
+				window["$lte"] = f.Until
+			}
+			predicates = append(predicates, bson.M{fields.Timestamps: bson.M{"$elemMatch": window}})
+		}
+	}
+
+	if fields.FQDN != "" && f.FQDN != "" {
+		predicates = append(predicates, bson.M{fields.FQDN: globRegex(f.FQDN)})
+	}
+
+	switch len(predicates) {
+	case 0:
+		return bson.M{}, nil
+	case 1:
+		return predicates[0], nil
+	}
+	return bson.M{"$and": predicates}, nil
+}
+
+//CheckTimeFields returns an error if the filter has a time window and some documents in the
+//collection lack the first seen field, since they would silently be left out of the results.
+//Results analyzed by earlier versions of RITA do not record when they were first and last seen.
+func (f Filter) CheckTimeFields(ctx context.Context, collection *mongo.Collection, fields Fields) error {
+	if (f.Since == 0 && f.Until == 0) || fields.FirstSeen == "" {
+		return nil
+	}
+
+	missing, err := collection.CountDocuments(
+		ctx, bson.M{fields.FirstSeen: bson.M{"$exists": false}}, options.Count().SetLimit(1),
+	)
+	if err != nil {
+		return err
+	}
+	if missing > 0 {
+		return fmt.Errorf("the %s collection was analyzed by an earlier version of RITA which did not record "+
+			"when each result was first and last seen. Re-import the dataset to filter it by time", collection.Name())
+	}
+	return nil
+}
+
+//ParseTime parses the start or end of a time window given as a unix timestamp,
+//an RFC 3339 timestamp (2006-01-02T15:04:05Z07:00), or a date (2006-01-02, UTC)
+func ParseTime(value string) (int64, error) {
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return unix, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Unix(), nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t.Unix(), nil
+	}
+	return 0, fmt.Errorf("invalid time %q: expected a unix timestamp, RFC 3339 timestamp, or YYYY-MM-DD date", value)
+}
+
+// hostsPredicate matches documents where the host stored in ipField (or its network
+// name stored in nameField) matches any of the given IPs, CIDR ranges, or network names
+func hostsPredicate(hosts []string, ipField, nameField string) (bson.M, error) {
+	var predicates []bson.M
+	for _, host := range hosts {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+
+		if ip := net.ParseIP(host); ip != nil {
+			predicates = append(predicates, bson.M{ipField: ip.String()})
+			continue
+		}
+
+		if _, ipNet, err := net.ParseCIDR(host); err == nil {
+			pattern, err := cidrPattern(ipNet)
+			if err != nil {
+				return nil, err
+			}
+			predicates = append(predicates, bson.M{ipField: primitive.Regex{Pattern: pattern}})
+			continue
+		}
+
+		if nameField == "" {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", host)
+		}
+		predicates = append(predicates, bson.M{nameField: host})
+	}
+
+	if len(predicates) == 1 {
+		return predicates[0], nil
+	}
+	return bson.M{"$or": predicates}, nil
+}
+
+// cidrPattern builds a regular expression matching the string form of every IPv4 address
+// in the given network. IP addresses are stored as strings, so a range query is not possible.
+func cidrPattern(ipNet *net.IPNet) (string, error) {
+	ip := ipNet.IP.To4()
+	if ip == nil {
+		ones, bits := ipNet.Mask.Size()
+		if ones == bits {
+			return "^" + regexp.QuoteMeta(ipNet.IP.String()) + "$", nil
+		}
+		return "", fmt.Errorf("IPv6 ranges are not supported: %s", ipNet.String())
+	}
+	ones, _ := ipNet.Mask.Size()
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	octet := 0
+	// octets entirely covered by the prefix must match exactly
+	for ; octet < ones/8; octet++ {
+		if octet > 0 {
+			pattern.WriteString(`\.`)
+		}
+		pattern.WriteString(strconv.Itoa(int(ip[octet])))
+	}
+	if octet == 4 {
+		pattern.WriteString("$")
+		return pattern.String(), nil
+	}
+
+	// the octet split by the prefix may take any value in its range
+	if remaining := ones % 8; remaining > 0 {
+		if octet > 0 {
+			pattern.WriteString(`\.`)
+		}
+		low := int(ip[octet])
+		high := low | (0xff >> remaining)
+		values := make([]string, 0, high-low+1)
+		for value := low; value <= high; value++ {
+			values = append(values, strconv.Itoa(value))
+		}
+		pattern.WriteString("(?:" + strings.Join(values, "|") + ")")
+		octet++
+		if octet == 4 {
+			pattern.WriteString("$")
+			return pattern.String(), nil
+		}
+	}
+
+	if octet > 0 {
+		pattern.WriteString(`\.`)
+	}
+	return pattern.String(), nil
+}
+
+// globRegex converts a glob pattern using * and ? wildcards into a case insensitive regular expression
+func globRegex(glob string) primitive.Regex {
+	pattern := regexp.QuoteMeta(glob)
+	pattern = strings.ReplaceAll(pattern, `\*`, ".*")
+	pattern = strings.ReplaceAll(pattern, `\?`, ".")
+	return primitive.Regex{Pattern: "^" + pattern + "$", Options: "i"}
+}
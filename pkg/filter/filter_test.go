@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"net"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCIDRPattern(t *testing.T) {
+	tables := []struct {
+		cidr     string
+		matching []string
+		missing  []string
+	}{
+		{"10.0.0.0/8", []string{"10.0.0.1", "10.255.3.4"}, []string{"110.0.0.1", "100.0.0.1", "11.0.0.1"}},
+		{"172.16.0.0/12", []string{"172.16.0.1", "172.31.255.255"}, []string{"172.15.0.1", "172.32.0.1", "172.160.0.1"}},
+		{"192.168.1.0/24", []string{"192.168.1.0", "192.168.1.254"}, []string{"192.168.10.1", "192.168.2.1"}},
+		{"192.168.1.128/25", []string{"192.168.1.128", "192.168.1.255"}, []string{"192.168.1.127", "192.168.1.12"}},
+		{"8.8.8.8/32", []string{"8.8.8.8"}, []string{"8.8.8.80", "8.8.8.9"}},
+		{"2001:db8::1/128", []string{"2001:db8::1"}, []string{"2001:db8::10"}},
+	}
+
+	for _, test := range tables {
+		_, ipNet, err := net.ParseCIDR(test.cidr)
+		require.Nil(t, err)
+		pattern, err := cidrPattern(ipNet)
+		require.Nil(t, err)
+		re := regexp.MustCompile(pattern)
+		for _, ip := range test.matching {
+			assert.True(t, re.MatchString(ip), "%s should contain %s", test.cidr, ip)
+		}
+		for _, ip := range test.missing {
+			assert.False(t, re.MatchString(ip), "%s should not contain %s", test.cidr, ip)
+		}
+	}
+
+	_, ipNet, _ := net.ParseCIDR("2001:db8::/32")
+	_, err := cidrPattern(ipNet)
+	assert.NotNil(t, err)
+}
+
+func TestGlobRegex(t *testing.T) {
+	re := globRegex("*.example.com")
+	assert.Equal(t, "i", re.Options)
+	compiled := regexp.MustCompile("(?i)" + re.Pattern)
+	assert.True(t, compiled.MatchString("www.Example.com"))
+	assert.False(t, compiled.MatchString("example.com.evil.net"))
+	assert.False(t, compiled.MatchString("wwwexample.com"))
+}
+
+func TestPredicate(t *testing.T) {
+	fields := Fields{
+		Src:            "src",
+		SrcNetworkName: "src_network_name",
+		Score:          "score",
+		FirstSeen:      "ts.first",
+		LastSeen:       "ts.last",
+	}
+
+	predicate, err := Filter{}.Predicate(fields)
+	require.Nil(t, err)
+	assert.Equal(t, bson.M{}, predicate)
+
+	predicate, err = Filter{Src: []string{"10.0.0.1", "Office"}}.Predicate(fields)
+	require.Nil(t, err)
+	assert.Equal(t, bson.M{"$or": []bson.M{{"src": "10.0.0.1"}, {"src_network_name": "Office"}}}, predicate)
+
+	// filters on fields the results don't have are not applied
+	predicate, err = Filter{MinScore: 0.8, Dst: []string{"10.0.0.1"}, MinConns: 5, Since: 100, Until: 200}.Predicate(fields)
+	require.Nil(t, err)
+	assert.Equal(t, bson.M{"$and": []bson.M{
+		{"score": bson.M{"$gte": 0.8}},
+		{"ts.last": bson.M{"$gte": int64(100)}},
+		{"ts.first": bson.M{"$lte": int64(200)}},
+	}}, predicate)
+
+	predicate, err = Filter{Since: 100}.Predicate(Fields{Timestamps: "dat.ts"})
+	require.Nil(t, err)
+	assert.Equal(t, bson.M{"dat.ts": bson.M{"$elemMatch": bson.M{"$gte": int64(100)}}}, predicate)
+
+	predicate, err = Filter{Src: []string{"10.0.0.0/8"}}.Predicate(Fields{Src: "ip"})
+	require.Nil(t, err)
+	assert.Equal(t, bson.M{"ip": primitive.Regex{Pattern: `^10\.`}}, predicate)
+
+	// network names can't be matched if the results don't record them
+	_, err = Filter{Src: []string{"Office"}}.Predicate(Fields{Src: "ip"})
+	assert.NotNil(t, err)
+}
+
+func TestParseTime(t *testing.T) {
+	tables := []struct {
+		in  string
+		out int64
+	}{
+		{"1600000000", 1600000000},
+		{"2020-09-13T12:26:40Z", 1600000000},
+		{"2020-09-13", 1599955200},
+	}
+	for _, test := range tables {
+		out, err := ParseTime(test.in)
+		require.Nil(t, err)
+		assert.Equal(t, test.out, out)
+	}
+
+	_, err := ParseTime("yesterday")
+	assert.NotNil(t, err)
+}
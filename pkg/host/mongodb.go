@@ -7,7 +7,6 @@ import (
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/util"
 
-	"github.com/globalsign/mgo"
 	"github.com/vbauerster/mpb"
 	"github.com/vbauerster/mpb/decor"
 
@@ -31,29 +30,21 @@ func NewMongoRepository(db *database.DB, conf *config.Config, logger *log.Logger
 
 // CreateIndexes creates indexes for the host collection
 func (r *repo) CreateIndexes() error {
-	session := r.database.Session.Copy()
-	defer session.Close()
-
-	coll := session.DB(r.database.GetSelectedDB()).C(r.config.T.Structure.HostTable)
+	coll := r.database.Collection(r.config.T.Structure.HostTable)
 
 	// create hosts collection
 	// Desired indexes
-	indexes := []mgo.Index{
+	indexes := []database.Index{
 		{Key: []string{"ip", "network_uuid"}, Unique: true},
 		{Key: []string{"local"}},
 		{Key: []string{"ipv4_binary"}},
 		{Key: []string{"dat.mdip.ip", "dat.mdip.network_uuid"}},
 		{Key: []string{"dat.mbdst.ip", "dat.mbdst.network_uuid"}},
 		{Key: []string{"dat.mbproxy"}},
+		{Key: []string{"dat.threat_score"}},
 	}
 
-	for _, index := range indexes {
-		err := coll.EnsureIndex(index)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return database.EnsureIndexes(r.database.Context(), coll, indexes)
 }
 
 // Upsert records the given host data in MongoDB
@@ -3,19 +3,14 @@
 package host
 
 import (
-	"io/ioutil"
 	"os"
 	"testing"
 
 	"github.com/activecm/rita/pkg/data"
 	"github.com/activecm/rita/resources"
 	"github.com/activecm/rita/util"
-	"github.com/globalsign/mgo/dbtest"
 )
 
-// Server holds the dbtest DBServer
-var Server dbtest.DBServer
-
 // Set the test database
 var testTargetDB = "tmp_test_db"
 
@@ -41,10 +36,6 @@ func TestUpsert(t *testing.T) {
 
 // TestMain wraps all tests with the needed initialized mock DB and fixtures
 func TestMain(m *testing.M) {
-	// Store temporary databases files in a temporary directory
-	tempDir, _ := ioutil.TempDir("", "testing")
-	Server.SetPath(tempDir)
-
 	// Set the main session variable to the temporary MongoDB instance
 	res := resources.InitTestResources()
 
@@ -53,8 +44,8 @@ func TestMain(m *testing.M) {
 	// Run the test suite
 	retCode := m.Run()
 
-	// Shut down the temporary server and removes data on disk.
-	Server.Stop()
+	// Disconnect from the test MongoDB server
+	res.DB.Close()
 
 	// call with result of m.Run()
 	os.Exit(retCode)
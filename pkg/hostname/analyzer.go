@@ -1,15 +1,16 @@
 package hostname
 
 import (
+	"context"
 	"strings"
 	"sync"
 
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type (
@@ -55,8 +56,7 @@ func (a *analyzer) close() {
 func (a *analyzer) start() {
 	a.analysisWg.Add(1)
 	go func() {
-		ssn := a.db.Session.Copy()
-		defer ssn.Close()
+		ctx := a.db.Context()
 
 		for datum := range a.analysisChannel {
 
@@ -68,7 +68,7 @@ func (a *analyzer) start() {
 
 			mainUpdate := mainQuery(datum, a.chunk)
 
-			blUpdate, err := blQuery(datum, ssn, a.conf.S.Blacklisted.BlacklistDatabase) // TODO: Move to BL package
+			blUpdate, err := blQuery(ctx, datum, a.db.Client, a.conf.S.Blacklisted.BlacklistDatabase) // TODO: Move to BL package
 			if err != nil {
 				a.log.WithFields(log.Fields{
 					"Module": "hostname",
@@ -112,9 +112,9 @@ func mainQuery(datum *Input, chunk int) bson.M {
 }
 
 // blQuery marks the given hostname as blacklisted or not
-func blQuery(datum *Input, ssn *mgo.Session, blDB string) (bson.M, error) {
+func blQuery(ctx context.Context, datum *Input, client *mongo.Client, blDB string) (bson.M, error) {
 	// check if blacklisted destination
-	blCount, err := ssn.DB(blDB).C("hostname").Find(bson.M{"index": datum.Host}).Count()
+	blCount, err := client.Database(blDB).Collection("hostname").CountDocuments(ctx, bson.M{"index": datum.Host})
 	blacklisted := blCount > 0
 
 	return bson.M{
@@ -20,6 +20,6 @@ type (
 
 	// FQDN Results for show-ip-dns-fqdns
 	FQDNResult struct {
-		Hostname string `bson:"_id"`
+		Hostname string `bson:"_id" json:"hostname"`
 	}
 )
@@ -1,15 +1,15 @@
 package hostname
 
 import (
+	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/pkg/data"
 	"github.com/activecm/rita/resources"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 // IPResults returns the IP addresses the hostname was seen resolving to in the dataset
 func IPResults(res *resources.Resources, hostname string) ([]data.UniqueIP, error) {
-	ssn := res.DB.Session.Copy()
-	defer ssn.Close()
+	ctx := res.DB.Context()
 
 	ipsForHostnameQuery := []bson.M{
 		{"$match": bson.M{
@@ -39,14 +39,13 @@ func IPResults(res *resources.Resources, hostname string) ([]data.UniqueIP, erro
 	}
 
 	var ipResults []data.UniqueIP
-	err := ssn.DB(res.DB.GetSelectedDB()).C(res.Config.T.DNS.HostnamesTable).Pipe(ipsForHostnameQuery).AllowDiskUse().All(&ipResults)
+	err := database.AggregateAll(ctx, res.DB.Collection(res.Config.T.DNS.HostnamesTable), ipsForHostnameQuery, &ipResults)
 	return ipResults, err
 }
 
 // FQDNResults returns the FQDNs the IP address was seen resolving to in the dataset
 func FQDNResults(res *resources.Resources, hostIP string) ([]*FQDNResult, error) {
-	ssn := res.DB.Session.Copy()
-	defer ssn.Close()
+	ctx := res.DB.Context()
 
 	fqdnsForHostnameQuery := []bson.M{
 		{"$match": bson.M{
@@ -55,10 +54,9 @@ func FQDNResults(res *resources.Resources, hostIP string) ([]*FQDNResult, error)
 		{"$group": bson.M{
 			"_id": "$host",
 		}},
-		
 	}
 
 	var fqdnResults []*FQDNResult
-	err := ssn.DB(res.DB.GetSelectedDB()).C(res.Config.T.DNS.HostnamesTable).Pipe(fqdnsForHostnameQuery).AllowDiskUse().All(&fqdnResults)
+	err := database.AggregateAll(ctx, res.DB.Collection(res.Config.T.DNS.HostnamesTable), fqdnsForHostnameQuery, &fqdnResults)
 	return fqdnResults, err
-}
\ No newline at end of file
+}
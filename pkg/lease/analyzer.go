@@ -0,0 +1,86 @@
+package lease
+
+import (
+	"sync"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type (
+	//analyzer is a structure for DHCP lease analysis
+	analyzer struct {
+		chunk            int                        // current chunk (0 if not on rolling analysis)
+		conf             *config.Config             // contains details needed to access MongoDB
+		analyzedCallback func(database.BulkChanges) // called on each analyzed result
+		closedCallback   func()                     // called when .close() is called and no more calls to analyzedCallback will be made
+		analysisChannel  chan *Input                // holds unanalyzed data
+		analysisWg       sync.WaitGroup             // wait for analysis to finish
+	}
+)
+
+// newAnalyzer creates a new analyzer for recording which devices held each IP address
+func newAnalyzer(chunk int, conf *config.Config, analyzedCallback func(database.BulkChanges), closedCallback func()) *analyzer {
+	return &analyzer{
+		chunk:            chunk,
+		conf:             conf,
+		analyzedCallback: analyzedCallback,
+		closedCallback:   closedCallback,
+		analysisChannel:  make(chan *Input),
+	}
+}
+
+// collect gathers DHCP lease records for analysis
+func (a *analyzer) collect(datum *Input) {
+	a.analysisChannel <- datum
+}
+
+// close waits for the analyzer to finish
+func (a *analyzer) close() {
+	close(a.analysisChannel)
+	a.analysisWg.Wait()
+	a.closedCallback()
+}
+
+// start kicks off a new analysis thread
+func (a *analyzer) start() {
+	a.analysisWg.Add(1)
+	go func() {
+		for datum := range a.analysisChannel {
+			a.analyzedCallback(database.BulkChanges{
+				a.conf.T.Structure.LeaseTable: []database.BulkChange{{
+					Selector: datum.Host.BSONKey(),
+					Update:   leaseQuery(datum, a.chunk),
+					Upsert:   true,
+				}},
+			})
+		}
+
+		a.analysisWg.Done()
+	}()
+}
+
+// leaseQuery returns a mgo query which pushes the leases of the given datum into the lease collection
+func leaseQuery(datum *Input, chunk int) bson.M {
+	var leases []bson.M
+	for _, lease := range mergeGrants(datum.Grants) {
+		leases = append(leases, bson.M{
+			"mac":       lease.MAC,
+			"host_name": lease.Hostname,
+			"start":     lease.Start,
+			"end":       lease.End,
+			"cid":       chunk,
+		})
+	}
+
+	return bson.M{
+		"$push": bson.M{
+			"dat": bson.M{"$each": leases},
+		},
+		"$set": bson.M{
+			"cid":          chunk,
+			"network_name": datum.Host.NetworkName,
+		},
+	}
+}
@@ -0,0 +1,98 @@
+package lease
+
+import (
+	"runtime"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/vbauerster/mpb"
+	"github.com/vbauerster/mpb/decor"
+)
+
+type repo struct {
+	database *database.DB
+	config   *config.Config
+	log      *log.Logger
+}
+
+// NewMongoRepository bundles the given resources for updating MongoDB with DHCP lease data
+func NewMongoRepository(db *database.DB, conf *config.Config, logger *log.Logger) Repository {
+	return &repo{
+		database: db,
+		config:   conf,
+		log:      logger,
+	}
+}
+
+// CreateIndexes creates indexes for the lease collection
+func (r *repo) CreateIndexes() error {
+	// set collection name
+	collectionName := r.config.T.Structure.LeaseTable
+
+	// check if collection already exists
+	names, _ := r.database.CollectionNames()
+
+	// if collection exists, we don't need to do anything else
+	for _, name := range names {
+		if name == collectionName {
+			return nil
+		}
+	}
+
+	// set desired indexes
+	indexes := []database.Index{
+		{Key: []string{"ip", "network_uuid"}, Unique: true},
+		{Key: []string{"dat.mac"}},
+		{Key: []string{"dat.host_name"}},
+	}
+
+	// create collection
+	err := r.database.CreateCollection(collectionName, indexes)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Upsert records the given DHCP lease data in MongoDB
+func (r *repo) Upsert(leaseMap map[string]*Input) {
+	// Create the workers
+	writerWorker := database.NewBulkWriter(r.database, r.config, r.log, true, "lease")
+
+	analyzerWorker := newAnalyzer(
+		r.config.S.Rolling.CurrentChunk,
+		r.config,
+		writerWorker.Collect,
+		writerWorker.Close,
+	)
+
+	// kick off the threaded goroutines
+	for i := 0; i < util.Max(1, runtime.NumCPU()/2); i++ {
+		analyzerWorker.start()
+		writerWorker.Start()
+	}
+
+	// progress bar for troubleshooting
+	p := mpb.New(mpb.WithWidth(20))
+	bar := p.AddBar(int64(len(leaseMap)),
+		mpb.PrependDecorators(
+			decor.Name("\t[-] DHCP Lease Analysis:", decor.WC{W: 30, C: decor.DidentRight}),
+			decor.CountersNoUnit(" %d / %d ", decor.WCSyncWidth),
+		),
+		mpb.AppendDecorators(decor.Percentage()),
+	)
+
+	// loop over map entries
+	for _, value := range leaseMap {
+		analyzerWorker.collect(value)
+		bar.IncrBy(1)
+	}
+
+	p.Wait()
+
+	// start the closing cascade (this will also close the other channels)
+	analyzerWorker.close()
+}
@@ -0,0 +1,44 @@
+package lease
+
+import (
+	"github.com/activecm/rita/pkg/data"
+)
+
+// Repository for lease collection
+type Repository interface {
+	CreateIndexes() error
+	Upsert(leaseMap map[string]*Input)
+}
+
+// Input holds the DHCP acknowledgements which granted an IP address to devices
+type Input struct {
+	Host   data.UniqueIP
+	Grants []Grant
+}
+
+// Grant records a DHCP server granting an IP address to a device
+type Grant struct {
+	MAC      string
+	Hostname string
+	Start    int64
+	End      int64
+}
+
+// Lease is a span of time during which a device held an IP address
+type Lease struct {
+	MAC      string `bson:"mac" json:"mac"`
+	Hostname string `bson:"host_name" json:"host_name"`
+	Start    int64  `bson:"start" json:"start"`
+	End      int64  `bson:"end" json:"end"`
+}
+
+// String names the device which held the lease by its hostname and MAC address
+func (l Lease) String() string {
+	switch {
+	case l.Hostname == "":
+		return l.MAC
+	case l.MAC == "":
+		return l.Hostname
+	}
+	return l.Hostname + "/" + l.MAC
+}
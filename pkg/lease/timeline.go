@@ -0,0 +1,143 @@
+package lease
+
+import (
+	"math"
+	"sort"
+
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/resources"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultLeaseTime is the length of a lease in seconds when the DHCP log doesn't record it
+const DefaultLeaseTime = 24 * 60 * 60
+
+// Timeline records which devices held each IP address over time
+type Timeline struct {
+	leases   map[string][]Lease // keyed by data.UniqueIP.MapKey()
+	from, to int64              // the time span of the dataset
+}
+
+// LoadTimeline reads the leases of every IP address in the given database
+func LoadTimeline(res *resources.Resources, db string) (*Timeline, error) {
+	ctx := res.DB.Context()
+
+	timeline := &Timeline{leases: make(map[string][]Lease), from: 0, to: math.MaxInt64}
+
+	// every lease is considered if the time span of the dataset isn't recorded
+	if from, to, err := res.MetaDB.GetTSRange(db); err == nil && (from != 0 || to != 0) {
+		timeline.from, timeline.to = from, to
+	}
+
+	cursor, err := res.DB.Collection(res.Config.T.Structure.LeaseTable).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var entry struct {
+			IP          string           `bson:"ip"`
+			NetworkUUID primitive.Binary `bson:"network_uuid"`
+			Dat         []Lease          `bson:"dat"`
+		}
+		if err := cursor.Decode(&entry); err != nil {
+			return nil, err
+		}
+
+		// the leases recorded by separate chunks are merged in case a lease spans them
+		grants := make([]Grant, 0, len(entry.Dat))
+		for _, lease := range entry.Dat {
+			grants = append(grants, Grant(lease))
+		}
+		host := data.UniqueIP{IP: entry.IP, NetworkUUID: entry.NetworkUUID}
+		timeline.leases[host.MapKey()] = mergeGrants(grants)
+	}
+
+	return timeline, cursor.Err()
+}
+
+// Empty determines whether no leases were recorded
+func (t *Timeline) Empty() bool {
+	return len(t.leases) == 0
+}
+
+// Devices returns the leases of the devices which held the IP addresses of the hosts at any
+// point between from and to, in the order the devices received the addresses. The time span
+// of the dataset is used if from and to are both zero since the time of the activity isn't known.
+func (t *Timeline) Devices(hosts []data.UniqueIP, from, to int64) []Lease {
+	if t == nil {
+		return nil
+	}
+	if from == 0 && to == 0 {
+		from, to = t.from, t.to
+	}
+
+	var held []Lease
+	for _, host := range hosts {
+		seen := make(map[string]bool)
+		for _, lease := range t.leases[host.MapKey()] {
+			if lease.Start > to || lease.End < from {
+				continue
+			}
+			// a device may have held the address several times during the span
+			if device := lease.String(); !seen[device] {
+				seen[device] = true
+				held = append(held, lease)
+			}
+		}
+	}
+	return held
+}
+
+// Names lists the devices which held the IP addresses of the hosts, as described by Devices
+func (t *Timeline) Names(hosts []data.UniqueIP, from, to int64) []string {
+	var names []string
+	for _, lease := range t.Devices(hosts, from, to) {
+		names = append(names, lease.String())
+	}
+	return names
+}
+
+// mergeGrants turns the grants of an IP address into the leases held by each device in order.
+// A renewal by the same device extends its lease, while a grant to another device cuts the
+// previous lease short.
+func mergeGrants(grants []Grant) []Lease {
+	sort.SliceStable(grants, func(i, j int) bool {
+		return grants[i].Start < grants[j].Start
+	})
+
+	var leases []Lease
+	for _, grant := range grants {
+		if len(leases) > 0 {
+			last := &leases[len(leases)-1]
+
+			if sameDevice(*last, grant) && grant.Start <= last.End {
+				if grant.End > last.End {
+					last.End = grant.End
+				}
+				// renewals don't always carry the client's hostname
+				if last.Hostname == "" {
+					last.Hostname = grant.Hostname
+				}
+				continue
+			}
+
+			if grant.Start < last.End {
+				last.End = grant.Start
+			}
+		}
+		leases = append(leases, Lease(grant))
+	}
+	return leases
+}
+
+// sameDevice determines whether a grant went to the device which held a lease.
+// Devices are identified by their MAC address, or by their hostname if the MAC address is unknown.
+func sameDevice(lease Lease, grant Grant) bool {
+	if lease.MAC != "" && grant.MAC != "" {
+		return lease.MAC == grant.MAC
+	}
+	return lease.Hostname != "" && lease.Hostname == grant.Hostname
+}
@@ -0,0 +1,52 @@
+package lease
+
+import (
+	"testing"
+
+	"github.com/activecm/rita/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeGrants(t *testing.T) {
+	grants := []Grant{
+		// a renewal by the same device extends its lease and fills in its hostname
+		{MAC: "00:11:22:33:44:55", Start: 1000, End: 2000},
+		{MAC: "00:11:22:33:44:55", Hostname: "laptop", Start: 1500, End: 2500},
+		// a grant to another device cuts the previous lease short
+		{MAC: "66:77:88:99:aa:bb", Start: 2200, End: 3200},
+		// the same device returning after another device held the address starts a new lease
+		{MAC: "00:11:22:33:44:55", Start: 4000, End: 5000},
+	}
+
+	assert.Equal(t, []Lease{
+		{MAC: "00:11:22:33:44:55", Hostname: "laptop", Start: 1000, End: 2200},
+		{MAC: "66:77:88:99:aa:bb", Start: 2200, End: 3200},
+		{MAC: "00:11:22:33:44:55", Start: 4000, End: 5000},
+	}, mergeGrants(grants))
+}
+
+func TestTimelineDevices(t *testing.T) {
+	host := data.UniqueIP{IP: "10.0.0.5"}
+	timeline := &Timeline{
+		leases: map[string][]Lease{
+			host.MapKey(): {
+				{MAC: "00:11:22:33:44:55", Hostname: "laptop", Start: 1000, End: 2200},
+				{MAC: "66:77:88:99:aa:bb", Start: 2200, End: 3200},
+				{MAC: "00:11:22:33:44:55", Hostname: "laptop", Start: 4000, End: 5000},
+			},
+		},
+		from: 0,
+		to:   6000,
+	}
+
+	hosts := []data.UniqueIP{host, {IP: "10.0.0.6"}}
+	assert.Equal(t, []string{"laptop/00:11:22:33:44:55"}, timeline.Names(hosts, 1200, 1800))
+	assert.Equal(t, []string{"66:77:88:99:aa:bb", "laptop/00:11:22:33:44:55"}, timeline.Names(hosts, 2500, 4500))
+
+	// every device is listed once if the time of the activity isn't known
+	assert.Equal(t, []string{"laptop/00:11:22:33:44:55", "66:77:88:99:aa:bb"}, timeline.Names(hosts, 0, 0))
+
+	// no devices are listed without leases
+	var none *Timeline
+	assert.Empty(t, none.Names(hosts, 0, 0))
+}
@@ -0,0 +1,98 @@
+package logon
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type (
+	//analyzer is a structure for user logon analysis
+	analyzer struct {
+		chunk            int                        // current chunk (0 if not on rolling analysis)
+		conf             *config.Config             // contains details needed to access MongoDB
+		analyzedCallback func(database.BulkChanges) // called on each analyzed result
+		closedCallback   func()                     // called when .close() is called and no more calls to analyzedCallback will be made
+		analysisChannel  chan *Input                // holds unanalyzed data
+		analysisWg       sync.WaitGroup             // wait for analysis to finish
+	}
+)
+
+// newAnalyzer creates a new analyzer for recording which accounts authenticated from each host
+func newAnalyzer(chunk int, conf *config.Config, analyzedCallback func(database.BulkChanges), closedCallback func()) *analyzer {
+	return &analyzer{
+		chunk:            chunk,
+		conf:             conf,
+		analyzedCallback: analyzedCallback,
+		closedCallback:   closedCallback,
+		analysisChannel:  make(chan *Input),
+	}
+}
+
+// collect gathers logon records for analysis
+func (a *analyzer) collect(datum *Input) {
+	a.analysisChannel <- datum
+}
+
+// close waits for the analyzer to finish
+func (a *analyzer) close() {
+	close(a.analysisChannel)
+	a.analysisWg.Wait()
+	a.closedCallback()
+}
+
+// start kicks off a new analysis thread
+func (a *analyzer) start() {
+	a.analysisWg.Add(1)
+	go func() {
+		for datum := range a.analysisChannel {
+			a.analyzedCallback(database.BulkChanges{
+				a.conf.T.Structure.LogonTable: []database.BulkChange{{
+					Selector: datum.Host.BSONKey(),
+					Update:   logonQuery(datum, a.chunk),
+					Upsert:   true,
+				}},
+			})
+		}
+
+		a.analysisWg.Done()
+	}()
+}
+
+// logonQuery returns a mgo query which pushes the accounts of the given datum into the logon collection
+func logonQuery(datum *Input, chunk int) bson.M {
+	names := make([]string, 0, len(datum.Accounts))
+	for name := range datum.Accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var accounts []bson.M
+	for _, name := range names {
+		account := datum.Accounts[name]
+		protocols := account.Protocols.Items()
+		sort.Strings(protocols)
+
+		accounts = append(accounts, bson.M{
+			"account":    name,
+			"protocols":  protocols,
+			"logons":     account.Logons,
+			"first_seen": account.FirstSeen,
+			"last_seen":  account.LastSeen,
+			"cid":        chunk,
+		})
+	}
+
+	return bson.M{
+		"$push": bson.M{
+			"dat": bson.M{"$each": accounts},
+		},
+		"$set": bson.M{
+			"cid":          chunk,
+			"network_name": datum.Host.NetworkName,
+		},
+	}
+}
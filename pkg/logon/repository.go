@@ -0,0 +1,34 @@
+package logon
+
+import (
+	"github.com/activecm/rita/pkg/data"
+)
+
+// Repository for logon collection
+type Repository interface {
+	CreateIndexes() error
+	Upsert(logonMap map[string]*Input)
+}
+
+// Input holds the accounts which authenticated from a host, keyed by account name
+type Input struct {
+	Host     data.UniqueIP
+	Accounts map[string]*AccountInput
+}
+
+// AccountInput records the successful authentications of an account from a host
+type AccountInput struct {
+	Protocols data.StringSet
+	Logons    int64
+	FirstSeen int64
+	LastSeen  int64
+}
+
+// Account summarizes the authentications of an account from a host
+type Account struct {
+	Name      string   `bson:"account" json:"account"`
+	Protocols []string `bson:"protocols" json:"protocols"`
+	Logons    int64    `bson:"logons" json:"logons"`
+	FirstSeen int64    `bson:"first_seen" json:"first_seen"`
+	LastSeen  int64    `bson:"last_seen" json:"last_seen"`
+}
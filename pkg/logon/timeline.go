@@ -0,0 +1,112 @@
+package logon
+
+import (
+	"sort"
+
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Timeline records which accounts authenticated from each host during the dataset
+type Timeline struct {
+	accounts map[string][]Account // keyed by data.UniqueIP.MapKey()
+}
+
+// LoadTimeline reads the accounts which authenticated from every host in the given database
+func LoadTimeline(res *resources.Resources, db string) (*Timeline, error) {
+	ctx := res.DB.Context()
+
+	timeline := &Timeline{accounts: make(map[string][]Account)}
+
+	logonColl := res.DB.WithSelectedDB(db).Collection(res.Config.T.Structure.LogonTable)
+	cursor, err := logonColl.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var entry struct {
+			IP          string           `bson:"ip"`
+			NetworkUUID primitive.Binary `bson:"network_uuid"`
+			Dat         []Account        `bson:"dat"`
+		}
+		if err := cursor.Decode(&entry); err != nil {
+			return nil, err
+		}
+
+		host := data.UniqueIP{IP: entry.IP, NetworkUUID: entry.NetworkUUID}
+		timeline.accounts[host.MapKey()] = mergeAccounts(entry.Dat)
+	}
+
+	return timeline, cursor.Err()
+}
+
+// Empty determines whether no logons were recorded
+func (t *Timeline) Empty() bool {
+	return len(t.accounts) == 0
+}
+
+// Accounts returns the accounts which authenticated from the hosts, in the order they were first seen
+func (t *Timeline) Accounts(hosts []data.UniqueIP) []Account {
+	if t == nil {
+		return nil
+	}
+
+	var accounts []Account
+	for _, host := range hosts {
+		accounts = append(accounts, t.accounts[host.MapKey()]...)
+	}
+	// an account may have authenticated from several of the hosts
+	return mergeAccounts(accounts)
+}
+
+// Names lists the accounts which authenticated from the hosts, as described by Accounts
+func (t *Timeline) Names(hosts []data.UniqueIP) []string {
+	var names []string
+	for _, account := range t.Accounts(hosts) {
+		names = append(names, account.Name)
+	}
+	return names
+}
+
+// mergeAccounts combines the records of each account, such as those made by separate chunks,
+// and orders the accounts by when they were first seen
+func mergeAccounts(records []Account) []Account {
+	var accounts []Account
+	index := make(map[string]int)
+	for _, record := range records {
+		idx, ok := index[record.Name]
+		if !ok {
+			index[record.Name] = len(accounts)
+			record.Protocols = append([]string(nil), record.Protocols...)
+			accounts = append(accounts, record)
+			continue
+		}
+
+		account := &accounts[idx]
+		account.Logons += record.Logons
+		if record.FirstSeen < account.FirstSeen {
+			account.FirstSeen = record.FirstSeen
+		}
+		if record.LastSeen > account.LastSeen {
+			account.LastSeen = record.LastSeen
+		}
+		for _, protocol := range record.Protocols {
+			if !util.StringInSlice(protocol, account.Protocols) {
+				account.Protocols = append(account.Protocols, protocol)
+			}
+		}
+	}
+
+	for idx := range accounts {
+		sort.Strings(accounts[idx].Protocols)
+	}
+	sort.SliceStable(accounts, func(i, j int) bool {
+		return accounts[i].FirstSeen < accounts[j].FirstSeen
+	})
+	return accounts
+}
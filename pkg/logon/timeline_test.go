@@ -0,0 +1,47 @@
+package logon
+
+import (
+	"testing"
+
+	"github.com/activecm/rita/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeAccounts(t *testing.T) {
+	// records of the same account made by separate chunks are combined
+	records := []Account{
+		{Name: `EXAMPLE\jdoe`, Protocols: []string{"ntlm"}, Logons: 2, FirstSeen: 2000, LastSeen: 3000},
+		{Name: "asmith@EXAMPLE.COM", Protocols: []string{"kerberos"}, Logons: 1, FirstSeen: 1500, LastSeen: 1500},
+		{Name: `EXAMPLE\jdoe`, Protocols: []string{"kerberos", "ntlm"}, Logons: 3, FirstSeen: 1000, LastSeen: 2500},
+	}
+
+	assert.Equal(t, []Account{
+		{Name: `EXAMPLE\jdoe`, Protocols: []string{"kerberos", "ntlm"}, Logons: 5, FirstSeen: 1000, LastSeen: 3000},
+		{Name: "asmith@EXAMPLE.COM", Protocols: []string{"kerberos"}, Logons: 1, FirstSeen: 1500, LastSeen: 1500},
+	}, mergeAccounts(records))
+}
+
+func TestTimelineNames(t *testing.T) {
+	first := data.UniqueIP{IP: "10.0.0.5"}
+	second := data.UniqueIP{IP: "10.0.0.6"}
+	timeline := &Timeline{
+		accounts: map[string][]Account{
+			first.MapKey(): {
+				{Name: `EXAMPLE\jdoe`, Protocols: []string{"ntlm"}, Logons: 1, FirstSeen: 1000, LastSeen: 1000},
+			},
+			second.MapKey(): {
+				{Name: "asmith@EXAMPLE.COM", Protocols: []string{"kerberos"}, Logons: 1, FirstSeen: 500, LastSeen: 500},
+				{Name: `EXAMPLE\jdoe`, Protocols: []string{"ntlm"}, Logons: 1, FirstSeen: 2000, LastSeen: 2000},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{`EXAMPLE\jdoe`}, timeline.Names([]data.UniqueIP{first}))
+	// an account which authenticated from several of the hosts is listed once
+	assert.Equal(t, []string{"asmith@EXAMPLE.COM", `EXAMPLE\jdoe`}, timeline.Names([]data.UniqueIP{first, second}))
+	assert.Empty(t, timeline.Names([]data.UniqueIP{{IP: "10.0.0.7"}}))
+
+	// no accounts are listed without logons
+	var none *Timeline
+	assert.Empty(t, none.Names([]data.UniqueIP{first}))
+}
@@ -7,7 +7,7 @@ import (
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/util"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -35,7 +35,7 @@ func (r *remover) Remove(cid int) error {
 	// first we need to use the entries being removed from hostnames to reduce the
 	// subdomain count in exploded dns. This is done so we don't have to keep Unique
 	// long lists of subdomains, and is the only special-case deletion
-	err := r.reduceDNSSubCount(cid)
+	err := r.reduceDNSSubCount(cid, bson.M{"cid": cid})
 	if err != nil {
 		return fmt.Errorf("\t[!] Failed to remove update exploded dns collection for removal: %v", err)
 	}
@@ -47,9 +47,84 @@ func (r *remover) Remove(cid int) error {
 	return nil
 }
 
-func (r *remover) reduceDNSSubCount(cid int) error {
-	ssn := r.database.Session.Copy()
-	defer ssn.Close()
+// RemoveStaged rolls back the results which an unfinished batch of the import into the
+// given chunk wrote under the staged chunk ID. Documents which existed before the batch
+// keep their earlier subdocuments and are returned to the chunk, while documents the
+// batch created are removed. Subdocuments which the batch moved out of earlier chunks
+// are restored. Results derived from the batch, such as beacons, are removed
+// as well and are rebuilt when the batch is imported again.
+func (r *remover) RemoveStaged(staged int, cid int) error {
+	ctx := r.database.Context()
+
+	fmt.Println("	[-] Removing the results of the unfinished batch")
+
+	// the hostnames which only hold staged subdocuments were first seen in the batch,
+	// so they added to the subdomain counts in exploded dns
+	err := r.reduceDNSSubCount(staged, bson.M{
+		"cid": staged,
+		"dat": bson.M{"$not": bson.M{"$elemMatch": bson.M{"cid": bson.M{"$ne": staged}}}},
+	})
+	if err != nil {
+		return fmt.Errorf("\t[!] Failed to remove update exploded dns collection for removal: %v", err)
+	}
+
+	for _, module := range r.modules() {
+		coll := r.database.Collection(module)
+
+		// subdocuments which the batch moved from earlier chunks return to those chunks
+		err := database.RestoreStagedDatEntries(ctx, coll)
+		if err != nil {
+			return err
+		}
+
+		_, err = coll.UpdateMany(ctx, bson.M{"dat.cid": staged}, bson.M{"$pull": bson.M{"dat": bson.M{"cid": staged}}})
+		if err != nil {
+			return err
+		}
+
+		_, err = coll.DeleteMany(ctx, bson.M{
+			"cid": staged,
+			"$or": []bson.M{{"dat": bson.M{"$exists": false}}, {"dat": bson.M{"$size": 0}}},
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = coll.UpdateMany(ctx, bson.M{"cid": staged}, bson.M{"$set": bson.M{"cid": cid}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CommitStaged moves the results which a finished batch wrote under the staged chunk ID
+// into the given chunk. It may be run again if it is interrupted.
+func (r *remover) CommitStaged(staged int, cid int) error {
+	ctx := r.database.Context()
+
+	for _, module := range r.modules() {
+		coll := r.database.Collection(module)
+
+		_, err := coll.UpdateMany(ctx, bson.M{"cid": staged}, bson.M{"$set": bson.M{"cid": cid}})
+		if err != nil {
+			return err
+		}
+
+		err = database.CommitStagedDatEntries(ctx, coll, staged, cid)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reduceDNSSubCount lowers the subdomain counts in exploded dns for the hostnames
+// matching the selector, which are about to be removed
+func (r *remover) reduceDNSSubCount(cid int, hostnameSelector bson.M) error {
+	ctx := r.database.Context()
 
 	//Create the workers
 	writerWorker := newUpdater(
@@ -79,11 +154,19 @@ func (r *remover) reduceDNSSubCount(cid int) error {
 		Host string `bson:"host"`
 	}
 
-	hostnamesIter := ssn.DB(r.database.GetSelectedDB()).C(r.config.T.DNS.HostnamesTable).Find(bson.M{"cid": cid}).Iter()
+	hostnamesIter, err := r.database.Collection(r.config.T.DNS.HostnamesTable).Find(ctx, hostnameSelector)
+	if err != nil {
+		analyzerWorker.close()
+		return err
+	}
 
-	for hostnamesIter.Next(&res) {
+	for hostnamesIter.Next(ctx) {
+		if err := hostnamesIter.Decode(&res); err != nil {
+			continue
+		}
 		analyzerWorker.collect(res.Host)
 	}
+	hostnamesIter.Close(ctx)
 
 	// start the closing cascade (this will also close the other channels)
 	analyzerWorker.close()
@@ -95,23 +178,6 @@ func (r *remover) reduceDNSSubCount(cid int) error {
 // within current documents
 func (r *remover) removeOutdatedCIDs(cid int) error {
 
-	// list of all analysis modules (including hostnames and exploded dns, because
-	// they still need to have this done, the previous loop was for updating existing
-	// documents due to to the special case in how that data is updated and stored.
-	modules := []string{
-		r.config.T.Beacon.BeaconTable,
-		r.config.T.BeaconProxy.BeaconProxyTable,
-		r.config.T.BeaconSNI.BeaconSNITable,
-		r.config.T.Structure.HostTable,
-		r.config.T.Structure.UniqueConnTable,
-		r.config.T.Structure.UniqueConnProxyTable,
-		r.config.T.Structure.SNIConnTable,
-		r.config.T.DNS.ExplodedDNSTable,
-		r.config.T.DNS.HostnamesTable,
-		r.config.T.Cert.CertificateTable,
-		r.config.T.UserAgent.UserAgentTable,
-	}
-
 	//Create the workers
 	writerWorker := newCIDRemover(
 		cid,
@@ -126,7 +192,7 @@ func (r *remover) removeOutdatedCIDs(cid int) error {
 	}
 
 	// loop over map entries
-	for _, entry := range modules {
+	for _, entry := range r.modules() {
 		writerWorker.collectCIDRemover(entry)
 	}
 
@@ -135,3 +201,28 @@ func (r *remover) removeOutdatedCIDs(cid int) error {
 
 	return nil
 }
+
+// modules lists the collections of all analysis modules (including hostnames and exploded
+// dns, because they still need to have this done, the reduceDNSSubCount loop was for updating
+// existing documents due to to the special case in how that data is updated and stored.
+func (r *remover) modules() []string {
+	return []string{
+		r.config.T.Beacon.BeaconTable,
+		r.config.T.BeaconProxy.BeaconProxyTable,
+		r.config.T.BeaconSNI.BeaconSNITable,
+		r.config.T.Structure.HostTable,
+		r.config.T.Structure.UniqueConnTable,
+		r.config.T.Structure.UniqueConnProxyTable,
+		r.config.T.Structure.SNIConnTable,
+		r.config.T.DNS.ExplodedDNSTable,
+		r.config.T.DNS.HostnamesTable,
+		r.config.T.DNS.DNSTunnelTable,
+		r.config.T.Cert.CertificateTable,
+		r.config.T.UserAgent.UserAgentTable,
+		r.config.T.Structure.SSHConnTable,
+		r.config.T.Structure.LeaseTable,
+		r.config.T.Structure.LogonTable,
+		r.config.T.FileTransfer.FileTransferTable,
+		r.config.T.FileTransfer.FileUploadTable,
+	}
+}
@@ -0,0 +1,135 @@
+// +build integration
+
+package remover
+
+import (
+	"os"
+	"testing"
+
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/resources"
+	"github.com/activecm/rita/util"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Set the test database
+var testTargetDB = "tmp_test_db"
+
+var testRes *resources.Resources
+
+var testHost = data.UniqueIP{
+	IP:          "10.0.0.1",
+	NetworkUUID: util.UnknownPrivateNetworkUUID,
+	NetworkName: util.UnknownPrivateNetworkName,
+}
+
+var testPeer = data.UniqueIP{
+	IP:          "1.1.1.1",
+	NetworkUUID: util.PublicNetworkUUID,
+	NetworkName: util.PublicNetworkName,
+}
+
+// insertHostWithEarlierChunk stores a host whose mdip and beacon summaries were written by chunk 0
+func insertHostWithEarlierChunk(t *testing.T) {
+	hostColl := testRes.DB.Collection(testRes.Config.T.Structure.HostTable)
+	require.NoError(t, hostColl.Drop(testRes.DB.Context()))
+
+	_, err := hostColl.InsertOne(testRes.DB.Context(), database.MergeBSONMaps(testHost.BSONKey(), bson.M{
+		"cid": 0,
+		"dat": []bson.M{
+			{"mdip": testPeer, "max_duration": 10.0, "cid": 0},
+			{"mbdst": testPeer, "max_beacon_score": 0.5, "cid": 0},
+		},
+	}))
+	require.NoError(t, err)
+}
+
+// stageHostUpdates updates the summaries of the host the way the summarizers do while a batch is staged
+func stageHostUpdates(t *testing.T) {
+	ctx := testRes.DB.Context()
+	hostColl := testRes.DB.Collection(testRes.Config.T.Structure.HostTable)
+
+	for field, values := range map[string]bson.M{
+		"mdip":  {"mdip": testPeer, "max_duration": 20.0},
+		"mbdst": {"mbdst": testPeer, "max_beacon_score": 0.9},
+	} {
+		selector := database.MergeBSONMaps(
+			testHost.BSONKey(),
+			bson.M{"dat": bson.M{"$elemMatch": bson.M{field: bson.M{"$exists": true}}}},
+		)
+		entry, err := database.FindDatEntry(ctx, hostColl, selector)
+		require.NoError(t, err)
+		require.NotNil(t, entry)
+
+		_, err = hostColl.UpdateOne(ctx, selector, database.MoveDatEntry(entry, values, database.StagedChunk))
+		require.NoError(t, err)
+	}
+}
+
+// hostDat returns the dat array of the test host
+func hostDat(t *testing.T) []bson.M {
+	var host struct {
+		Dat []bson.M `bson:"dat"`
+	}
+	hostColl := testRes.DB.Collection(testRes.Config.T.Structure.HostTable)
+	require.NoError(t, hostColl.FindOne(testRes.DB.Context(), testHost.BSONKey()).Decode(&host))
+	return host.Dat
+}
+
+func TestRemoveStagedRestoresEarlierChunk(t *testing.T) {
+	insertHostWithEarlierChunk(t)
+	stageHostUpdates(t)
+
+	repo := NewMongoRemover(testRes.DB, testRes.Config, testRes.Log)
+	require.NoError(t, repo.RemoveStaged(database.StagedChunk, 1))
+
+	dat := hostDat(t)
+	require.Len(t, dat, 2)
+	for _, entry := range dat {
+		require.EqualValues(t, 0, entry["cid"])
+		require.NotContains(t, entry, "prev")
+		if _, ok := entry["mdip"]; ok {
+			require.EqualValues(t, 10.0, entry["max_duration"])
+		} else {
+			require.EqualValues(t, 0.5, entry["max_beacon_score"])
+		}
+	}
+}
+
+func TestCommitStagedMovesEarlierChunk(t *testing.T) {
+	insertHostWithEarlierChunk(t)
+	stageHostUpdates(t)
+
+	repo := NewMongoRemover(testRes.DB, testRes.Config, testRes.Log)
+	require.NoError(t, repo.CommitStaged(database.StagedChunk, 1))
+
+	dat := hostDat(t)
+	require.Len(t, dat, 2)
+	for _, entry := range dat {
+		require.EqualValues(t, 1, entry["cid"])
+		require.NotContains(t, entry, "prev")
+		if _, ok := entry["mdip"]; ok {
+			require.EqualValues(t, 20.0, entry["max_duration"])
+		} else {
+			require.EqualValues(t, 0.9, entry["max_beacon_score"])
+		}
+	}
+}
+
+// TestMain wraps all tests with the needed initialized mock DB and fixtures
+func TestMain(m *testing.M) {
+	// Set the main session variable to the temporary MongoDB instance
+	testRes = resources.InitTestResources()
+	testRes.DB.SelectDB(testTargetDB)
+
+	// Run the test suite
+	retCode := m.Run()
+
+	// Disconnect from the test MongoDB server
+	testRes.DB.Close()
+
+	// call with result of m.Run()
+	os.Exit(retCode)
+}
@@ -3,6 +3,8 @@ package remover
 // Repository ....
 type Repository interface {
 	Remove(int) error
+	RemoveStaged(staged int, cid int) error
+	CommitStaged(staged int, cid int) error
 }
 
 //update ....
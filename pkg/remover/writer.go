@@ -6,8 +6,8 @@ import (
 
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
-	"github.com/globalsign/mgo/bson"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 type (
@@ -70,18 +70,16 @@ func (w *writer) closeUpdater() {
 func (w *writer) startCIDRemover() {
 	w.writeWg.Add(1)
 	go func() {
-		ssn := w.db.Session.Copy()
-		defer ssn.Close()
+		ctx := w.db.Context()
 
 		for data := range w.cidRemoverChannel {
 
 			//delete the ENTIRE record if it hasn't been updated since the chunk we are trying to remove
-			info, err := ssn.DB(w.db.GetSelectedDB()).C(data).RemoveAll(bson.M{"cid": w.cid})
-			if err != nil ||
-				((info.Updated == 0) && (info.Removed == 0) && (info.Matched != 0)) {
+			removeInfo, err := w.db.Collection(data).DeleteMany(ctx, bson.M{"cid": w.cid})
+			if err != nil {
 				w.log.WithFields(log.Fields{
 					"Module":  "remover",
-					"Info":    info,
+					"Info":    removeInfo,
 					"Data":    data,
 					"Message": "failed to delete whole document",
 				}).Error(err)
@@ -89,9 +87,9 @@ func (w *writer) startCIDRemover() {
 
 			// this ONLY deletes a specific chunk's DATA from a record that HAS been updated recently and doesn't need to be completely
 			// removed - only the target chunk's stats should be removed from it
-			info, err = ssn.DB(w.db.GetSelectedDB()).C(data).UpdateAll(bson.M{"dat.cid": w.cid}, bson.M{"$pull": bson.M{"dat": bson.M{"cid": w.cid}}})
+			info, err := w.db.Collection(data).UpdateMany(ctx, bson.M{"dat.cid": w.cid}, bson.M{"$pull": bson.M{"dat": bson.M{"cid": w.cid}}})
 			if err != nil ||
-				((info.Updated == 0) && (info.Removed == 0) && (info.Matched != 0)) {
+				((info.ModifiedCount == 0) && (info.MatchedCount != 0)) {
 				w.log.WithFields(log.Fields{
 					"Module":  "remover",
 					"Info":    info,
@@ -109,12 +107,11 @@ func (w *writer) startCIDRemover() {
 func (w *writer) startUpdater() {
 	w.writeWg.Add(1)
 	go func() {
-		ssn := w.db.Session.Copy()
-		defer ssn.Close()
+		ctx := w.db.Context()
 
 		for data := range w.updaterChannel {
 
-			err := ssn.DB(w.db.GetSelectedDB()).C(data.collection).Update(data.selector, data.query)
+			_, err := w.db.Collection(data.collection).UpdateOne(ctx, data.selector, data.query)
 			if err != nil {
 				fmt.Println(err, data)
 			}
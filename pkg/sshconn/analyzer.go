@@ -0,0 +1,99 @@
+package sshconn
+
+import (
+	"sync"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type (
+	//analyzer is a structure for SSH connection analysis
+	analyzer struct {
+		chunk            int                        // current chunk (0 if not on rolling analysis)
+		conf             *config.Config             // contains details needed to access MongoDB
+		analyzedCallback func(database.BulkChanges) // called on each analyzed result
+		closedCallback   func()                     // called when .close() is called and no more calls to analyzedCallback will be made
+		analysisChannel  chan *Input                // holds unanalyzed data
+		analysisWg       sync.WaitGroup             // wait for analysis to finish
+	}
+)
+
+// newAnalyzer creates a new analyzer for recording SSH sessions between pairs of hosts
+func newAnalyzer(chunk int, conf *config.Config, analyzedCallback func(database.BulkChanges), closedCallback func()) *analyzer {
+	return &analyzer{
+		chunk:            chunk,
+		conf:             conf,
+		analyzedCallback: analyzedCallback,
+		closedCallback:   closedCallback,
+		analysisChannel:  make(chan *Input),
+	}
+}
+
+// collect gathers SSH connection records for analysis
+func (a *analyzer) collect(datum *Input) {
+	a.analysisChannel <- datum
+}
+
+// close waits for the analyzer to finish
+func (a *analyzer) close() {
+	close(a.analysisChannel)
+	a.analysisWg.Wait()
+	a.closedCallback()
+}
+
+// start kicks off a new analysis thread
+func (a *analyzer) start() {
+	a.analysisWg.Add(1)
+	go func() {
+		for datum := range a.analysisChannel {
+			a.analyzedCallback(database.BulkChanges{
+				a.conf.T.Structure.SSHConnTable: []database.BulkChange{{
+					Selector: datum.Hosts.BSONKey(),
+					Update:   sshConnQuery(datum, a.chunk),
+					Upsert:   true,
+				}},
+			})
+		}
+
+		a.analysisWg.Done()
+	}()
+}
+
+// sshConnQuery returns a mgo query which inserts the given datum into the SSHconn collection.
+// The version strings and fingerprints are capped in order to prevent hitting the MongoDB document size limits.
+func sshConnQuery(datum *Input, chunk int) bson.M {
+	capped := func(items []string) []string {
+		if len(items) > 10 {
+			return items[:10]
+		}
+		return items
+	}
+
+	return bson.M{
+		"$push": bson.M{
+			"dat": bson.M{
+				"sessions":        datum.Sessions,
+				"auth_attempts":   datum.AuthAttempts,
+				"auth_successes":  datum.AuthSuccesses,
+				"failed_sessions": datum.FailedSessions,
+				"client_versions": capped(datum.ClientVersions.Items()),
+				"server_versions": capped(datum.ServerVersions.Items()),
+				"hassh":           capped(datum.HASSHs.Items()),
+				"hassh_server":    capped(datum.HASSHServers.Items()),
+				"dst_ports":       datum.DstPorts.Items(),
+				"first_seen":      datum.FirstSeen,
+				"last_seen":       datum.LastSeen,
+				"cid":             chunk,
+			},
+		},
+		"$set": bson.M{
+			"cid":              chunk,
+			"src_network_name": datum.Hosts.SrcNetworkName,
+			"dst_network_name": datum.Hosts.DstNetworkName,
+			"src_local":        datum.IsLocalSrc,
+			"dst_local":        datum.IsLocalDst,
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package sshconn
+
+import (
+	"github.com/activecm/rita/pkg/data"
+)
+
+// Repository for SSHconn collection
+type Repository interface {
+	CreateIndexes() error
+	Upsert(sshMap map[string]*Input)
+}
+
+// Input holds the SSH sessions between a pair of hosts
+type Input struct {
+	Hosts          data.UniqueIPPair
+	IsLocalSrc     bool
+	IsLocalDst     bool
+	Sessions       int64
+	AuthAttempts   int64
+	AuthSuccesses  int64 // sessions in which the client logged in
+	FailedSessions int64 // sessions in which every authentication attempt failed
+	ClientVersions data.StringSet
+	ServerVersions data.StringSet
+	HASSHs         data.StringSet
+	HASSHServers   data.StringSet
+	DstPorts       data.IntSet
+	FirstSeen      int64
+	LastSeen       int64
+}
+
+// Result represents the SSH sessions between a pair of hosts along with
+// the scores used to detect brute force, password spraying, and beaconing
+type Result struct {
+	data.UniqueIPPair `bson:",inline"`
+	Sessions          int64    `bson:"sessions" json:"sessions"`
+	AuthAttempts      int64    `bson:"auth_attempts" json:"auth_attempts"`
+	AuthSuccesses     int64    `bson:"auth_successes" json:"auth_successes"`
+	FailedSessions    int64    `bson:"failed_sessions" json:"failed_sessions"`
+	ClientVersions    []string `bson:"client_versions" json:"client_versions"`
+	ServerVersions    []string `bson:"server_versions" json:"server_versions"`
+	HASSHs            []string `bson:"hassh" json:"hassh"`
+	HASSHServers      []string `bson:"hassh_server" json:"hassh_server"`
+	DstPorts          []int    `bson:"dst_ports" json:"dst_ports"`
+	FirstSeen         int64    `bson:"first_seen" json:"first_seen"`
+	LastSeen          int64    `bson:"last_seen" json:"last_seen"`
+	SprayTargets      int64    `bson:"spray_targets" json:"spray_targets"`
+	BeaconScore       float64  `bson:"beacon_score" json:"beacon_score"`
+	BruteForceScore   float64  `bson:"-" json:"brute_force_score"`
+	SprayScore        float64  `bson:"-" json:"spray_score"`
+	Detections        []string `bson:"-" json:"detections"`
+	Score             float64  `bson:"-" json:"score"`
+}
@@ -0,0 +1,231 @@
+package sshconn
+
+import (
+	"math"
+
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/resources"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// the detections which may be reported for a pair of hosts
+const (
+	DetectionBruteForce         = "brute_force"          // many failed logins to a single server
+	DetectionSpray              = "spray"                // failed logins from the source to many servers
+	DetectionBeacon             = "beacon"               // the connections between the hosts beacon
+	DetectionLoginAfterFailures = "login_after_failures" // a login succeeded after a brute force or spray
+)
+
+const (
+	// the ranges over which each score climbs from zero to one
+	failedAttemptsFloor   = 10.0
+	failedAttemptsCeiling = 100.0
+	sprayTargetsFloor     = 3.0
+	sprayTargetsCeiling   = 20.0
+
+	// beaconDetectionScore is the beacon score at which SSH connections are reported as a beacon
+	beaconDetectionScore = 0.7
+)
+
+//Results returns the SSH sessions between pairs of hosts matching the given filter, scored by how
+//likely they are to be a brute force, password spray, or automated SSH beacon.
+//limit and noLimit control how many results are returned.
+func Results(res *resources.Resources, limit int, noLimit bool, filt filter.Filter) ([]Result, error) {
+	ctx := res.DB.Context()
+
+	var sshResults []Result
+
+	filterPredicate, err := filt.Predicate(filter.Fields{
+		Src:            "src",
+		SrcNetworkName: "src_network_name",
+		Dst:            "dst",
+		DstNetworkName: "dst_network_name",
+	})
+	if err != nil {
+		return sshResults, err
+	}
+
+	scorePredicate, err := filt.Predicate(filter.Fields{Score: "score"})
+	if err != nil {
+		return sshResults, err
+	}
+
+	// flatten merges the lists gathered from each dat subdocument
+	flatten := func(field string) bson.M {
+		return bson.M{"$reduce": bson.M{
+			"input":        field,
+			"initialValue": []string{},
+			"in":           bson.M{"$setUnion": []string{"$$value", "$$this"}},
+		}}
+	}
+
+	sshQuery := []bson.M{
+		{"$match": filterPredicate},
+		{"$unwind": "$dat"},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"src":              "$src",
+				"src_network_uuid": "$src_network_uuid",
+				"dst":              "$dst",
+				"dst_network_uuid": "$dst_network_uuid",
+			},
+			"src_network_name": bson.M{"$last": "$src_network_name"},
+			"dst_network_name": bson.M{"$last": "$dst_network_name"},
+			"sessions":         bson.M{"$sum": "$dat.sessions"},
+			"auth_attempts":    bson.M{"$sum": "$dat.auth_attempts"},
+			"auth_successes":   bson.M{"$sum": "$dat.auth_successes"},
+			"failed_sessions":  bson.M{"$sum": "$dat.failed_sessions"},
+			"client_versions":  bson.M{"$push": "$dat.client_versions"},
+			"server_versions":  bson.M{"$push": "$dat.server_versions"},
+			"hassh":            bson.M{"$push": "$dat.hassh"},
+			"hassh_server":     bson.M{"$push": "$dat.hassh_server"},
+			"dst_ports":        bson.M{"$push": "$dat.dst_ports"},
+			"first_seen":       bson.M{"$min": "$dat.first_seen"},
+			"last_seen":        bson.M{"$max": "$dat.last_seen"},
+		}},
+		// SSH connections which beacon are found by the beacon analysis of the conn log
+		{"$lookup": bson.M{
+			"from": res.Config.T.Beacon.BeaconTable,
+			"let": bson.M{
+				"src":              "$_id.src",
+				"src_network_uuid": "$_id.src_network_uuid",
+				"dst":              "$_id.dst",
+				"dst_network_uuid": "$_id.dst_network_uuid",
+			},
+			"pipeline": []bson.M{
+				{"$match": bson.M{"$expr": bson.M{
+					"$and": []bson.M{
+						{"$eq": []string{"$src", "$$src"}},
+						{"$eq": []string{"$src_network_uuid", "$$src_network_uuid"}},
+						{"$eq": []string{"$dst", "$$dst"}},
+						{"$eq": []string{"$dst_network_uuid", "$$dst_network_uuid"}},
+					},
+				}}},
+				{"$project": bson.M{"score": 1}},
+			},
+			"as": "beacon",
+		}},
+		// every server the source failed to log in to is counted, regardless of the destination filter
+		{"$lookup": bson.M{
+			"from": res.Config.T.Structure.SSHConnTable,
+			"let": bson.M{
+				"src":              "$_id.src",
+				"src_network_uuid": "$_id.src_network_uuid",
+			},
+			"pipeline": []bson.M{
+				{"$match": bson.M{
+					"$expr": bson.M{"$and": []bson.M{
+						{"$eq": []string{"$src", "$$src"}},
+						{"$eq": []string{"$src_network_uuid", "$$src_network_uuid"}},
+					}},
+					"dat.failed_sessions": bson.M{"$gt": 0},
+				}},
+				{"$count": "targets"},
+			},
+			"as": "spray",
+		}},
+		{"$project": bson.M{
+			"_id":              0,
+			"src":              "$_id.src",
+			"src_network_uuid": "$_id.src_network_uuid",
+			"src_network_name": 1,
+			"dst":              "$_id.dst",
+			"dst_network_uuid": "$_id.dst_network_uuid",
+			"dst_network_name": 1,
+			"sessions":         1,
+			"auth_attempts":    1,
+			"auth_successes":   1,
+			"failed_sessions":  1,
+			"client_versions":  flatten("$client_versions"),
+			"server_versions":  flatten("$server_versions"),
+			"hassh":            flatten("$hassh"),
+			"hassh_server":     flatten("$hassh_server"),
+			"dst_ports":        flatten("$dst_ports"),
+			"first_seen":       1,
+			"last_seen":        1,
+			"beacon_score":     bson.M{"$ifNull": []interface{}{bson.M{"$max": "$beacon.score"}, 0}},
+			"spray_targets":    bson.M{"$ifNull": []interface{}{bson.M{"$arrayElemAt": []interface{}{"$spray.targets", 0}}, 0}},
+		}},
+		{"$addFields": bson.M{"score": scoreQuery()}},
+		{"$match": scorePredicate},
+		{"$sort": bson.D{{Key: "score", Value: -1}, {Key: "failed_sessions", Value: -1}}},
+	}
+
+	if !noLimit {
+		sshQuery = append(sshQuery, bson.M{"$limit": limit})
+	}
+
+	err = database.AggregateAll(ctx, res.DB.Collection(res.Config.T.Structure.SSHConnTable), sshQuery, &sshResults)
+	if err != nil {
+		return nil, err
+	}
+
+	// fill in the subscores and detections behind each score
+	for i := range sshResults {
+		scoreResult(&sshResults[i])
+	}
+
+	return sshResults, nil
+}
+
+// scoreResult fills in the scores and detections of a result. The brute force score climbs with
+// the failed login attempts to the server, the spray score climbs with the number of servers the
+// source failed to log in to, and the beacon score is taken from the beacon analysis.
+// The overall score is the highest of the three.
+func scoreResult(result *Result) {
+	failedAttempts := math.Max(float64(result.AuthAttempts-result.AuthSuccesses), float64(result.FailedSessions))
+	result.BruteForceScore = scale(failedAttempts, failedAttemptsFloor, failedAttemptsCeiling)
+	result.SprayScore = scale(float64(result.SprayTargets), sprayTargetsFloor, sprayTargetsCeiling)
+
+	detections := []string{}
+	if result.BruteForceScore > 0 {
+		detections = append(detections, DetectionBruteForce)
+	}
+	if result.SprayScore > 0 && result.FailedSessions > 0 {
+		detections = append(detections, DetectionSpray)
+	}
+	if len(detections) > 0 && result.AuthSuccesses > 0 {
+		detections = append(detections, DetectionLoginAfterFailures)
+	}
+	if result.BeaconScore >= beaconDetectionScore {
+		detections = append(detections, DetectionBeacon)
+	}
+	result.Detections = detections
+
+	score := math.Max(result.BruteForceScore, result.BeaconScore)
+	// the spray score only applies to the servers the source failed to log in to
+	if result.FailedSessions > 0 {
+		score = math.Max(score, result.SprayScore)
+	}
+	result.Score = math.Ceil(score*1000) / 1000
+}
+
+// scale maps value onto [0, 1], where floor and below maps to zero and ceiling and above maps to one
+func scale(value, floor, ceiling float64) float64 {
+	return math.Max(0, math.Min(1, (value-floor)/(ceiling-floor)))
+}
+
+// scoreQuery builds an aggregation expression which computes the overall score scoreResult gives
+// a result, so the results can be filtered and sorted by score in MongoDB
+func scoreQuery() bson.M {
+	failedAttempts := bson.M{"$max": []interface{}{
+		bson.M{"$subtract": []interface{}{"$auth_attempts", "$auth_successes"}}, "$failed_sessions",
+	}}
+	bruteForceScore := scaleQuery(failedAttempts, failedAttemptsFloor, failedAttemptsCeiling)
+	sprayScore := bson.M{"$cond": []interface{}{
+		bson.M{"$gt": []interface{}{"$failed_sessions", 0}},
+		scaleQuery("$spray_targets", sprayTargetsFloor, sprayTargetsCeiling),
+		0,
+	}}
+
+	score := bson.M{"$max": []interface{}{bruteForceScore, "$beacon_score", sprayScore}}
+	return bson.M{"$divide": []interface{}{bson.M{"$ceil": bson.M{"$multiply": []interface{}{score, 1000}}}, 1000}}
+}
+
+// scaleQuery builds an aggregation expression which scales a value the same way as scale
+func scaleQuery(value interface{}, floor, ceiling float64) bson.M {
+	return bson.M{"$max": []interface{}{0, bson.M{"$min": []interface{}{1, bson.M{"$divide": []interface{}{
+		bson.M{"$subtract": []interface{}{value, floor}}, ceiling - floor,
+	}}}}}}
+}
@@ -0,0 +1,56 @@
+package sshconn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreResult(t *testing.T) {
+	testCases := []struct {
+		name       string
+		result     Result
+		detections []string
+		score      float64
+	}{
+		{
+			name:       "interactive logins",
+			result:     Result{Sessions: 5, AuthAttempts: 6, AuthSuccesses: 5, FailedSessions: 0},
+			detections: []string{},
+			score:      0,
+		},
+		{
+			name:       "brute force",
+			result:     Result{Sessions: 200, AuthAttempts: 600, FailedSessions: 200},
+			detections: []string{DetectionBruteForce},
+			score:      1,
+		},
+		{
+			name:       "spray followed by a login",
+			result:     Result{Sessions: 2, AuthAttempts: 3, AuthSuccesses: 1, FailedSessions: 1, SprayTargets: 20},
+			detections: []string{DetectionSpray, DetectionLoginAfterFailures},
+			score:      1,
+		},
+		{
+			name:       "spraying source with a successful login only",
+			result:     Result{Sessions: 1, AuthAttempts: 1, AuthSuccesses: 1, SprayTargets: 20},
+			detections: []string{},
+			score:      0,
+		},
+		{
+			name:       "beacon",
+			result:     Result{Sessions: 500, AuthAttempts: 500, AuthSuccesses: 500, BeaconScore: 0.85},
+			detections: []string{DetectionBeacon},
+			score:      0.85,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			result := test.result
+			scoreResult(&result)
+			assert.Equal(t, test.detections, result.Detections)
+			assert.InDelta(t, test.score, result.Score, 0.001)
+		})
+	}
+}
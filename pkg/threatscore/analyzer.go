@@ -0,0 +1,317 @@
+package threatscore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/util"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// the counts at which the count based factors score one
+	blacklistedPeersCeiling = 3.0
+	rareSignaturesCeiling   = 3.0
+	invalidCertsCeiling     = 3.0
+	strobesCeiling          = 3.0
+
+	// the total connection duration (in seconds) over which the long connection factor
+	// climbs from zero to one
+	longConnFloor   = 3600.0  // 1 hour
+	longConnCeiling = 86400.0 // 24 hours
+)
+
+type (
+	//analyzer calculates the threat score of internal hosts
+	analyzer struct {
+		chunk            int                        // current chunk (0 if not on rolling analysis)
+		db               *database.DB               // provides access to MongoDB
+		conf             *config.Config             // contains details needed to access MongoDB
+		log              *log.Logger                // main logger for RITA
+		analyzedCallback func(database.BulkChanges) // called on each analyzed result
+		closedCallback   func()                     // called when .close() is called and no more calls to analyzedCallback will be made
+		analysisChannel  chan data.UniqueIP         // holds unanalyzed data
+		analysisWg       sync.WaitGroup             // wait for analysis to finish
+	}
+
+	//hostStats holds the results of the other analysis modules for a single host
+	hostStats struct {
+		beaconScore      float64 // highest beacon, proxy beacon, or SNI beacon score
+		beaconTarget     string  // destination of the highest scoring beacon
+		blacklistedPeers int64   // number of blacklisted hosts contacted
+		maxDuration      float64 // longest total connection duration with a single peer
+		maxDurationPeer  string  // peer of the longest total connection duration
+		rareSignatures   int64   // number of rare user agents and JA3 hashes used
+		invalidCerts     int64   // number of servers contacted which presented invalid certificates
+		strobes          int64   // number of peers contacted often enough to be considered a strobe
+	}
+
+	//hostDat holds the summaries the other analysis modules store in a host's dat array
+	hostDat struct {
+		MaxBeaconScore      float64       `bson:"max_beacon_score"`
+		MaxBeaconDst        data.UniqueIP `bson:"mbdst"`
+		MaxBeaconProxyScore float64       `bson:"max_beacon_proxy_score"`
+		MaxBeaconProxyFQDN  string        `bson:"mbproxy"`
+		MaxBeaconSNIScore   float64       `bson:"max_beacon_sni_score"`
+		MaxBeaconSNIFQDN    string        `bson:"mbsni"`
+		MaxDuration         float64       `bson:"max_duration"`
+		MaxDurationPeer     data.UniqueIP `bson:"mdip"`
+		Blacklisted         data.UniqueIP `bson:"bl"`
+		BlacklistedOutCount int64         `bson:"bl_out_count"`
+		RareSignature       string        `bson:"rsig"`
+	}
+)
+
+// newAnalyzer creates a new analyzer for computing host threat scores
+func newAnalyzer(chunk int, db *database.DB, conf *config.Config, log *log.Logger, analyzedCallback func(database.BulkChanges), closedCallback func()) *analyzer {
+	return &analyzer{
+		chunk:            chunk,
+		db:               db,
+		conf:             conf,
+		log:              log,
+		analyzedCallback: analyzedCallback,
+		closedCallback:   closedCallback,
+		analysisChannel:  make(chan data.UniqueIP),
+	}
+}
+
+// collect gathers an internal host for analysis
+func (a *analyzer) collect(datum data.UniqueIP) {
+	a.analysisChannel <- datum
+}
+
+// close waits for the analyzer to finish
+func (a *analyzer) close() {
+	close(a.analysisChannel)
+	a.analysisWg.Wait()
+	a.closedCallback()
+}
+
+// start kicks off a new analysis thread
+func (a *analyzer) start() {
+	a.analysisWg.Add(1)
+	go func() {
+
+		ctx := a.db.Context()
+
+		for datum := range a.analysisChannel {
+			hostCollection := a.db.Collection(a.conf.T.Structure.HostTable)
+
+			stats, err := a.gatherStats(ctx, datum, hostCollection)
+			if err != nil {
+				a.log.WithFields(log.Fields{
+					"Module": "threatscore",
+					"Data":   datum,
+				}).Error(err)
+				continue
+			}
+
+			score, factors := scoreHost(stats, a.conf.S.ThreatScore)
+
+			threatScoreSelector, threatScoreQuery, err := threatScoreUpdate(ctx, datum, score, factors, hostCollection, a.chunk)
+			if err != nil {
+				a.log.WithFields(log.Fields{
+					"Module": "threatscore",
+					"Data":   datum,
+				}).Error(err)
+				continue
+			}
+
+			a.analyzedCallback(database.BulkChanges{
+				a.conf.T.Structure.HostTable: []database.BulkChange{{
+					Selector: threatScoreSelector,
+					Update:   threatScoreQuery,
+					Upsert:   true,
+				}},
+			})
+		}
+		a.analysisWg.Done()
+	}()
+}
+
+// gatherStats collects the results of the other analysis modules for a host
+func (a *analyzer) gatherStats(ctx context.Context, datum data.UniqueIP, hostColl *mongo.Collection) (hostStats, error) {
+	// the beacon, long connection, blacklist, and user agent modules summarize
+	// their results for each internal host in the host's dat array
+	var hostDoc struct {
+		Dat []hostDat `bson:"dat"`
+	}
+	err := hostColl.FindOne(ctx, datum.BSONKey(), options.FindOne().SetProjection(bson.M{"dat": 1})).Decode(&hostDoc)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return hostStats{}, err
+	}
+	stats := datStats(hostDoc.Dat)
+
+	// the certificate collection is keyed by the server presenting the invalid certificate
+	stats.invalidCerts, err = a.db.Collection(a.conf.T.Cert.CertificateTable).CountDocuments(ctx,
+		bson.M{"dat": bson.M{"$elemMatch": datum.PrefixedBSONKey("orig_ips")}},
+	)
+	if err != nil {
+		return stats, err
+	}
+
+	stats.strobes, err = a.db.Collection(a.conf.T.Structure.UniqueConnTable).CountDocuments(ctx, bson.M{
+		"src":              datum.IP,
+		"src_network_uuid": datum.NetworkUUID,
+		"strobe":           true,
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// datStats combines the summaries stored in a host's dat array. Peers and signatures
+// are counted once even though they are summarized again for every chunk.
+func datStats(dats []hostDat) hostStats {
+	var stats hostStats
+	blacklisted := make(data.StringSet)
+	rareSignatures := make(data.StringSet)
+	for _, dat := range dats {
+		if dat.MaxBeaconScore > stats.beaconScore {
+			stats.beaconScore = dat.MaxBeaconScore
+			stats.beaconTarget = dat.MaxBeaconDst.IP
+		}
+		if dat.MaxBeaconProxyScore > stats.beaconScore {
+			stats.beaconScore = dat.MaxBeaconProxyScore
+			stats.beaconTarget = dat.MaxBeaconProxyFQDN
+		}
+		if dat.MaxBeaconSNIScore > stats.beaconScore {
+			stats.beaconScore = dat.MaxBeaconSNIScore
+			stats.beaconTarget = dat.MaxBeaconSNIFQDN
+		}
+		if dat.MaxDuration > stats.maxDuration {
+			stats.maxDuration = dat.MaxDuration
+			stats.maxDurationPeer = dat.MaxDurationPeer.IP
+		}
+		if dat.BlacklistedOutCount > 0 {
+			blacklisted.Insert(dat.Blacklisted.MapKey())
+		}
+		if dat.RareSignature != "" {
+			rareSignatures.Insert(dat.RareSignature)
+		}
+	}
+	stats.blacklistedPeers = int64(len(blacklisted))
+	stats.rareSignatures = int64(len(rareSignatures))
+	return stats
+}
+
+// scoreHost combines the results of the other analysis modules into a single threat score.
+// Each factor is scaled between zero and one and multiplied by its configured weight.
+// The factors which contributed to the score are returned, highest contribution first.
+func scoreHost(stats hostStats, weights config.ThreatScoreStaticCfg) (float64, []Factor) {
+	factors := []Factor{
+		{
+			Name:   "beacon",
+			Value:  stats.beaconScore,
+			Score:  weights.BeaconWeight * math.Min(1, stats.beaconScore),
+			Detail: fmt.Sprintf("beacon score %.3f to %s", stats.beaconScore, stats.beaconTarget),
+		},
+		{
+			Name:   "blacklist",
+			Value:  float64(stats.blacklistedPeers),
+			Score:  weights.BlacklistWeight * scale(float64(stats.blacklistedPeers), 0, blacklistedPeersCeiling),
+			Detail: fmt.Sprintf("%d blacklisted peers", stats.blacklistedPeers),
+		},
+		{
+			Name:  "long_connection",
+			Value: stats.maxDuration,
+			Score: weights.LongConnWeight * scale(stats.maxDuration, longConnFloor, longConnCeiling),
+			Detail: fmt.Sprintf("connected to %s for %s", stats.maxDurationPeer,
+				util.FormatDuration(time.Duration(stats.maxDuration*float64(time.Second)).Truncate(time.Second))),
+		},
+		{
+			Name:   "rare_signature",
+			Value:  float64(stats.rareSignatures),
+			Score:  weights.RareSignatureWeight * scale(float64(stats.rareSignatures), 0, rareSignaturesCeiling),
+			Detail: fmt.Sprintf("%d rare user agents or JA3 hashes", stats.rareSignatures),
+		},
+		{
+			Name:   "invalid_certificate",
+			Value:  float64(stats.invalidCerts),
+			Score:  weights.InvalidCertWeight * scale(float64(stats.invalidCerts), 0, invalidCertsCeiling),
+			Detail: fmt.Sprintf("%d servers with invalid certificates", stats.invalidCerts),
+		},
+		{
+			Name:   "strobe",
+			Value:  float64(stats.strobes),
+			Score:  weights.StrobeWeight * scale(float64(stats.strobes), 0, strobesCeiling),
+			Detail: fmt.Sprintf("%d strobes", stats.strobes),
+		},
+	}
+
+	// only keep the factors which contributed to the score
+	var contributing []Factor
+	total := 0.0
+	for _, factor := range factors {
+		if factor.Score <= 0 {
+			continue
+		}
+		total += factor.Score
+		factor.Score = math.Ceil(factor.Score*1000) / 1000
+		contributing = append(contributing, factor)
+	}
+
+	sort.SliceStable(contributing, func(i, j int) bool {
+		return contributing[i].Score > contributing[j].Score
+	})
+
+	return math.Ceil(math.Min(1, total)*1000) / 1000, contributing
+}
+
+// scale maps value onto [0, 1], where floor and below maps to zero and ceiling and above maps to one
+func scale(value, floor, ceiling float64) float64 {
+	return math.Max(0, math.Min(1, (value-floor)/(ceiling-floor)))
+}
+
+// threatScoreUpdate formats a MongoDB update which records the threat score of a host
+// in its dat array, replacing the threat score recorded by a previous import
+func threatScoreUpdate(ctx context.Context, datum data.UniqueIP, score float64, factors []Factor, hostColl *mongo.Collection, chunk int) (bson.M, bson.M, error) {
+	if factors == nil {
+		factors = []Factor{}
+	}
+
+	hostSelector := datum.BSONKey()
+	hostWithDatEntrySelector := database.MergeBSONMaps(
+		hostSelector,
+		bson.M{"dat": bson.M{"$elemMatch": bson.M{"threat_score": bson.M{"$exists": true}}}},
+	)
+
+	existingEntry, err := database.FindDatEntry(ctx, hostColl, hostWithDatEntrySelector)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if existingEntry != nil {
+		updateQuery := database.MoveDatEntry(existingEntry, bson.M{
+			"threat_score":   score,
+			"threat_factors": factors,
+		}, chunk)
+		return hostWithDatEntrySelector, updateQuery, nil
+	}
+
+	insertQuery := bson.M{
+		"$push": bson.M{
+			"dat": bson.M{
+				"$each": []bson.M{{
+					"threat_score":   score,
+					"threat_factors": factors,
+					"cid":            chunk,
+				}},
+			},
+		},
+	}
+
+	return hostSelector, insertQuery, nil
+}
@@ -0,0 +1,91 @@
+package threatscore
+
+import (
+	"testing"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testWeights = config.ThreatScoreStaticCfg{
+	Enabled:             true,
+	BeaconWeight:        0.3,
+	BlacklistWeight:     0.25,
+	LongConnWeight:      0.15,
+	RareSignatureWeight: 0.1,
+	InvalidCertWeight:   0.1,
+	StrobeWeight:        0.1,
+}
+
+func TestScoreHostNoFindings(t *testing.T) {
+	score, factors := scoreHost(hostStats{}, testWeights)
+
+	assert.Equal(t, 0.0, score)
+	assert.Empty(t, factors)
+}
+
+func TestScoreHostEveryFactor(t *testing.T) {
+	stats := hostStats{
+		beaconScore:      1,
+		beaconTarget:     "1.2.3.4",
+		blacklistedPeers: 3,
+		maxDuration:      2 * longConnCeiling,
+		maxDurationPeer:  "5.6.7.8",
+		rareSignatures:   10,
+		invalidCerts:     3,
+		strobes:          3,
+	}
+
+	score, factors := scoreHost(stats, testWeights)
+
+	assert.Equal(t, 1.0, score)
+	require.Len(t, factors, 6)
+	// factors are sorted by their contribution
+	assert.Equal(t, "beacon", factors[0].Name)
+	assert.Equal(t, "beacon score 1.000 to 1.2.3.4", factors[0].Detail)
+	assert.Equal(t, "blacklist", factors[1].Name)
+	assert.Equal(t, "long_connection", factors[2].Name)
+	assert.Equal(t, "connected to 5.6.7.8 for 2d0s", factors[2].Detail)
+}
+
+func TestScoreHostPartial(t *testing.T) {
+	stats := hostStats{
+		beaconScore:      0.5,
+		beaconTarget:     "example.com",
+		blacklistedPeers: 1,
+		maxDuration:      longConnFloor, // not long enough to contribute
+	}
+
+	score, factors := scoreHost(stats, testWeights)
+
+	// 0.3 * 0.5 + 0.25 * (1 / 3)
+	assert.Equal(t, 0.234, score)
+	require.Len(t, factors, 2)
+	assert.Equal(t, "beacon", factors[0].Name)
+	assert.Equal(t, 0.15, factors[0].Score)
+	assert.Equal(t, "blacklist", factors[1].Name)
+	assert.Equal(t, 0.084, factors[1].Score)
+}
+
+func TestDatStatsCountsPeersOnce(t *testing.T) {
+	peer := data.UniqueIP{IP: "1.2.3.4", NetworkUUID: util.PublicNetworkUUID}
+	otherNetwork := data.UniqueIP{IP: "1.2.3.4", NetworkUUID: util.UnknownPrivateNetworkUUID}
+
+	// the blacklist and user agent modules add a summary for each chunk
+	stats := datStats([]hostDat{
+		{Blacklisted: peer, BlacklistedOutCount: 2},
+		{Blacklisted: peer, BlacklistedOutCount: 5},
+		{Blacklisted: otherNetwork, BlacklistedOutCount: 1},
+		{RareSignature: "curl/7.1"},
+		{RareSignature: "curl/7.1"},
+		{MaxBeaconScore: 0.8, MaxBeaconDst: peer},
+	})
+
+	assert.Equal(t, int64(2), stats.blacklistedPeers)
+	assert.Equal(t, int64(1), stats.rareSignatures)
+	assert.Equal(t, 0.8, stats.beaconScore)
+	assert.Equal(t, "1.2.3.4", stats.beaconTarget)
+}
@@ -0,0 +1,72 @@
+package threatscore
+
+import (
+	"runtime"
+
+	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/util"
+	"github.com/vbauerster/mpb"
+	"github.com/vbauerster/mpb/decor"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type repo struct {
+	database *database.DB
+	config   *config.Config
+	log      *log.Logger
+}
+
+// NewMongoRepository bundles the given resources for updating MongoDB with host threat scores
+func NewMongoRepository(db *database.DB, conf *config.Config, logger *log.Logger) Repository {
+	return &repo{
+		database: db,
+		config:   conf,
+		log:      logger,
+	}
+}
+
+// Score calculates the threat score of the given internal hosts from the results
+// of the other analysis modules. The results are stored in the host collection.
+func (r *repo) Score(hosts []data.UniqueIP) {
+
+	//Create the workers
+	writerWorker := database.NewBulkWriter(r.database, r.config, r.log, true, "threatscore")
+
+	analyzerWorker := newAnalyzer(
+		r.config.S.Rolling.CurrentChunk,
+		r.database,
+		r.config,
+		r.log,
+		writerWorker.Collect,
+		writerWorker.Close,
+	)
+
+	//kick off the threaded goroutines
+	for i := 0; i < util.Max(1, runtime.NumCPU()/2); i++ {
+		analyzerWorker.start()
+		writerWorker.Start()
+	}
+
+	// progress bar for troubleshooting
+	p := mpb.New(mpb.WithWidth(20))
+	bar := p.AddBar(int64(len(hosts)),
+		mpb.PrependDecorators(
+			decor.Name("\t[-] Threat Score Analysis:", decor.WC{W: 30, C: decor.DidentRight}),
+			decor.CountersNoUnit(" %d / %d ", decor.WCSyncWidth),
+		),
+		mpb.AppendDecorators(decor.Percentage()),
+	)
+
+	// loop over the hosts
+	for _, host := range hosts {
+		analyzerWorker.collect(host)
+		bar.IncrBy(1)
+	}
+	p.Wait()
+
+	// start the closing cascade (this will also close the other channels)
+	analyzerWorker.close()
+}
@@ -0,0 +1,27 @@
+package threatscore
+
+import (
+	"github.com/activecm/rita/pkg/data"
+)
+
+type (
+	// Repository for the threat score entries in the host collection
+	Repository interface {
+		Score(hosts []data.UniqueIP)
+	}
+
+	//Factor records how much one analysis module contributed to a host's threat score
+	Factor struct {
+		Name   string  `bson:"name" json:"name"`     // name of the contributing analysis (e.g. beacon)
+		Value  float64 `bson:"value" json:"value"`   // raw measurement taken from the analysis
+		Score  float64 `bson:"score" json:"score"`   // weighted contribution to the threat score
+		Detail string  `bson:"detail" json:"detail"` // human readable explanation of the measurement
+	}
+
+	//Result represents an internal host ranked by its threat score
+	Result struct {
+		Host    data.UniqueIP `bson:"host" json:"host"`
+		Score   float64       `bson:"threat_score" json:"threat_score"`
+		Factors []Factor      `bson:"threat_factors" json:"threat_factors"`
+	}
+)
@@ -0,0 +1,68 @@
+package threatscore
+
+import (
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/resources"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+//Results returns internal hosts which match the given filter sorted by their threat score
+//along with the factors which contributed to the score. Hosts with a score of zero are omitted.
+//limit and noLimit control how many results are returned.
+func Results(res *resources.Resources, limit int, noLimit bool, filt filter.Filter) ([]Result, error) {
+	var threatResults []Result
+
+	threatQuery, err := ResultsQuery(filt)
+	if err != nil {
+		return threatResults, err
+	}
+
+	if !noLimit {
+		threatQuery = append(threatQuery, bson.M{"$limit": limit})
+	}
+
+	err = database.AggregateAll(res.DB.Context(), res.DB.Collection(res.Config.T.Structure.HostTable), threatQuery, &threatResults)
+
+	return threatResults, err
+}
+
+//ResultsQuery builds the aggregation pipeline used by Results, sorted by threat score
+func ResultsQuery(filt filter.Filter) ([]bson.M, error) {
+	hostPredicate, err := filt.Predicate(filter.Fields{Src: "ip", SrcNetworkName: "network_name"})
+	if err != nil {
+		return nil, err
+	}
+
+	// the host's dat array holds summaries from other modules as well, so the
+	// score is filtered once the threat score entry has been unwound
+	scorePredicate, err := filt.Predicate(filter.Fields{Score: "dat.threat_score"})
+	if err != nil {
+		return nil, err
+	}
+
+	threatQuery := []bson.M{
+		{"$match": bson.M{"$and": []bson.M{
+			{"dat.threat_score": bson.M{"$gt": 0}},
+			hostPredicate,
+		}}},
+		{"$unwind": "$dat"},
+		{"$match": bson.M{"$and": []bson.M{
+			{"dat.threat_score": bson.M{"$gt": 0}},
+			scorePredicate,
+		}}},
+		{"$project": bson.M{
+			"_id": 0,
+			"host": bson.M{
+				"ip":           "$ip",
+				"network_uuid": "$network_uuid",
+				"network_name": "$network_name",
+			},
+			"threat_score":   "$dat.threat_score",
+			"threat_factors": "$dat.threat_factors",
+		}},
+		{"$sort": bson.M{"threat_score": -1}},
+	}
+
+	return threatQuery, nil
+}
@@ -1,14 +1,15 @@
 package uconn
 
 import (
+	"context"
 	"sync"
 
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/pkg/data"
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/bson"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type (
@@ -56,10 +57,9 @@ func (a *analyzer) close() {
 func (a *analyzer) start() {
 	a.analysisWg.Add(1)
 	go func() {
-		ssn := a.db.Session.Copy()
-		defer ssn.Close()
+		ctx := a.db.Context()
 
-		uconnColl := ssn.DB(a.db.GetSelectedDB()).C(a.conf.T.Structure.UniqueConnTable)
+		uconnColl := a.db.Collection(a.conf.T.Structure.UniqueConnTable)
 
 		for datum := range a.analysisChannel {
 
@@ -76,7 +76,7 @@ func (a *analyzer) start() {
 			// statistics together with the statistics for the current chunk of imports and the
 			// the open connection statistics. Then, the rollUpQuery formats an update to the
 			// top-level connection statistics fields in the unique connection doc.
-			rollUpUpdate, err := rollUpQuery(datum, openCount, openTBytes, openDur, uconnColl)
+			rollUpUpdate, err := rollUpQuery(ctx, datum, openCount, openTBytes, openDur, uconnColl)
 			if err != nil {
 				a.log.WithFields(log.Fields{
 					"Module": "host",
@@ -111,12 +111,16 @@ func mainQuery(datum *Input, strobeLimit int64, chunk int) bson.M {
 	// of connections in the current datum, don't store bytes and ts.
 	// it will not qualify to be downgraded to a beacon until this chunk is
 	// outdated and removed. If only importing once - still just a strobe.
-	ts := datum.TsList
+	tsMillis := datum.TsList
 	bytes := datum.OrigBytesList
 
+	// the first and last timestamps are kept in whole seconds even for strobes so that
+	// the time span of the connections is known without storing every timestamp twice
+	firstSeen, lastSeen := timestampBounds(datum.TsList)
+
 	isStrobe := datum.ConnectionCount >= strobeLimit
 	if isStrobe {
-		ts = []int64{}
+		tsMillis = []int64{}
 		bytes = []int64{}
 	}
 
@@ -132,21 +136,54 @@ func mainQuery(datum *Input, strobeLimit int64, chunk int) bson.M {
 		"$push": bson.M{
 			"dat": bson.M{
 				"$each": []bson.M{{
-					"count":  datum.ConnectionCount,
-					"bytes":  bytes,
-					"ts":     ts,
-					"tuples": tuples,
-					"icerts": datum.InvalidCertFlag,
-					"maxdur": datum.MaxDuration,
-					"tbytes": datum.TotalBytes,
-					"tdur":   datum.TotalDuration,
-					"cid":    chunk,
+					"count":      datum.ConnectionCount,
+					"bytes":      bytes,
+					"ts_ms":      tsMillis,
+					"first_seen": firstSeen,
+					"last_seen":  lastSeen,
+					"tuples":     tuples,
+					"icerts":     datum.InvalidCertFlag,
+					"maxdur":     datum.MaxDuration,
+					"tbytes":     datum.TotalBytes,
+					"tdur":       datum.TotalDuration,
+					"cid":        chunk,
 				}},
 			},
 		},
 	}
 }
 
+// timestampBounds returns the earliest and latest of the given millisecond timestamps in whole seconds
+func timestampBounds(tsMillis []int64) (int64, int64) {
+	if len(tsMillis) == 0 {
+		return 0, 0
+	}
+	first, last := tsMillis[0], tsMillis[0]
+	for _, ts := range tsMillis[1:] {
+		if ts < first {
+			first = ts
+		}
+		if ts > last {
+			last = ts
+		}
+	}
+	return first / 1000, last / 1000
+}
+
+// ChunkTimestampBounds gathers the first and last timestamps of each chunk of a unique connection
+// in whole seconds. Chunks imported by earlier versions hold every timestamp in ts instead.
+var ChunkTimestampBounds = bson.M{
+	"$map": bson.M{
+		"input": "$dat",
+		"as":    "chunk",
+		"in": bson.M{"$cond": []interface{}{
+			bson.M{"$gt": []interface{}{"$$chunk.first_seen", 0}},
+			[]interface{}{"$$chunk.first_seen", "$$chunk.last_seen"},
+			bson.M{"$ifNull": []interface{}{"$$chunk.ts", []interface{}{}}},
+		}},
+	},
+}
+
 // openConnectionsQuery records information about connections that are still open between two hosts.
 // Additionally this function returns the summary details of the open connections
 func openConnectionsQuery(datum *Input) (query bson.M, connCount, totalBytes int64, duration float64) {
@@ -200,7 +237,7 @@ func openConnectionsQuery(datum *Input) (query bson.M, connCount, totalBytes int
 }
 
 // rollUpQuery updates the top level summary fields which aggregate over rhe chunked fields
-func rollUpQuery(datum *Input, openCount, openTotalBytes int64, openDuration float64, uconnColl *mgo.Collection) (bson.M, error) {
+func rollUpQuery(ctx context.Context, datum *Input, openCount, openTotalBytes int64, openDuration float64, uconnColl *mongo.Collection) (bson.M, error) {
 	//existingQuery gathers the previously existing summary details for the connection pair
 	existingQuery := []bson.M{
 		{"$match": datum.Hosts.BSONKey()},
@@ -253,8 +290,8 @@ func rollUpQuery(datum *Input, openCount, openTotalBytes int64, openDuration flo
 		TotalDuration float64  `bson:"tdur"`
 	}
 	var rollUpRes rollUpResult
-	err := uconnColl.Pipe(existingQuery).AllowDiskUse().One(&rollUpRes)
-	if err != nil && err != mgo.ErrNotFound {
+	err := database.AggregateOne(ctx, uconnColl, existingQuery, &rollUpRes)
+	if err != nil && err != mongo.ErrNoDocuments {
 		return bson.M{}, err
 	}
 
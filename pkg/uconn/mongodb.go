@@ -10,7 +10,6 @@ import (
 	"github.com/activecm/rita/pkg/host"
 	"github.com/activecm/rita/util"
 
-	"github.com/globalsign/mgo"
 	"github.com/vbauerster/mpb"
 	"github.com/vbauerster/mpb/decor"
 
@@ -34,15 +33,11 @@ func NewMongoRepository(db *database.DB, conf *config.Config, logger *log.Logger
 
 // CreateIndexes creates indexes for the uconn collection
 func (r *repo) CreateIndexes() error {
-
-	session := r.database.Session.Copy()
-	defer session.Close()
-
 	// set collection name
 	collectionName := r.config.T.Structure.UniqueConnTable
 
 	// check if collection already exists
-	names, _ := session.DB(r.database.GetSelectedDB()).CollectionNames()
+	names, _ := r.database.CollectionNames()
 
 	// if collection exists, we don't need to do anything else
 	for _, name := range names {
@@ -51,7 +46,7 @@ func (r *repo) CreateIndexes() error {
 		}
 	}
 
-	indexes := []mgo.Index{
+	indexes := []database.Index{
 		{Key: []string{"src", "dst", "src_network_uuid", "dst_network_uuid"}, Unique: true},
 		{Key: []string{"src", "src_network_uuid"}},
 		{Key: []string{"dst", "dst_network_uuid"}},
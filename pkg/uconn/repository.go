@@ -20,7 +20,7 @@ type Input struct {
 	TotalBytes         int64
 	MaxDuration        float64
 	TotalDuration      float64
-	TsList             []int64
+	TsList             []int64 // connection timestamps in milliseconds
 	UniqueTsListLength int64
 	OrigBytesList      []int64
 	Tuples             data.StringSet
@@ -33,12 +33,12 @@ type Input struct {
 // the longest connection between those hosts.
 type LongConnResult struct {
 	data.UniqueIPPair `bson:",inline"`
-	ConnectionCount   int64    `bson:"count"`
-	TotalBytes        int64    `bson:"tbytes"`
-	TotalDuration     float64  `bson:"tdur"`
-	MaxDuration       float64  `bson:"maxdur"`
-	Tuples            []string `bson:"tuples"`
-	Open              bool     `bson:"open"`
+	ConnectionCount   int64    `bson:"count" json:"count"`
+	TotalBytes        int64    `bson:"tbytes" json:"tbytes"`
+	TotalDuration     float64  `bson:"tdur" json:"tdur"`
+	MaxDuration       float64  `bson:"maxdur" json:"maxdur"`
+	Tuples            []string `bson:"tuples" json:"tuples"`
+	Open              bool     `bson:"open" json:"open"`
 }
 
 // OpenConnResult represents a pair of hosts that currently
@@ -48,10 +48,10 @@ type LongConnResult struct {
 // the user wants to look for that connection in their zeek logs
 type OpenConnResult struct {
 	data.UniqueIPPair `bson:",inline"`
-	Bytes             int     `bson:"bytes"`
-	Duration          float64 `bson:"duration"`
-	Tuple             string  `bson:"tuple"`
-	UID               string  `bson:"uid"`
+	Bytes             int     `bson:"bytes" json:"bytes"`
+	Duration          float64 `bson:"duration" json:"duration"`
+	Tuple             string  `bson:"tuple" json:"tuple"`
+	UID               string  `bson:"uid" json:"uid"`
 }
 
 // ConnState is used to determine if a particular
@@ -1,21 +1,61 @@
 package uconn
 
 import (
+	"github.com/activecm/rita/database"
+	"github.com/activecm/rita/pkg/filter"
 	"github.com/activecm/rita/resources"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 // LongConnResults returns long connections longer than the given thresh in
-// seconds. The results will be sorted, descending by duration.
+// seconds which match the given filter. The results will be sorted, descending by duration.
 // limit and noLimit control how many results are returned.
-func LongConnResults(res *resources.Resources, thresh int, limit int, noLimit bool) ([]LongConnResult, error) {
-	ssn := res.DB.Session.Copy()
-	defer ssn.Close()
-
+func LongConnResults(res *resources.Resources, thresh int, limit int, noLimit bool, filt filter.Filter) ([]LongConnResult, error) {
 	var longConnResults []LongConnResult
 
+	longConnQuery, err := LongConnResultsQuery(thresh, filt)
+	if err != nil {
+		return longConnResults, err
+	}
+
+	if !noLimit {
+		longConnQuery = append(longConnQuery, bson.M{"$limit": limit})
+	}
+
+	err = database.AggregateAll(res.DB.Context(), res.DB.Collection(res.Config.T.Structure.UniqueConnTable), longConnQuery, &longConnResults)
+
+	return longConnResults, err
+}
+
+//LongConnResultsQuery builds the aggregation pipeline used by LongConnResults, sorted by duration
+func LongConnResultsQuery(thresh int, filt filter.Filter) ([]bson.M, error) {
+	filterPredicate, err := filt.Predicate(filter.Fields{
+		Src:            "src",
+		SrcNetworkName: "src_network_name",
+		Dst:            "dst",
+		DstNetworkName: "dst_network_name",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the first and last timestamps of each chunk are kept for strobes as well, while
+	// chunks imported by earlier versions only hold the timestamps of non-strobes
+	windowPredicate, err := filt.Predicate(filter.Fields{FirstSeen: "dat.first_seen", LastSeen: "dat.last_seen"})
+	if err != nil {
+		return nil, err
+	}
+	legacyWindowPredicate, err := filt.Predicate(filter.Fields{Timestamps: "dat.ts"})
+	if err != nil {
+		return nil, err
+	}
+
 	longConnQuery := []bson.M{
-		{"$match": bson.M{"dat.maxdur": bson.M{"$gt": thresh}}},
+		{"$match": bson.M{"$and": []bson.M{
+			{"dat.maxdur": bson.M{"$gt": thresh}},
+			filterPredicate,
+			{"$or": []bson.M{windowPredicate, legacyWindowPredicate}},
+		}}},
 		{"$project": bson.M{
 			"src":              1,
 			"src_network_uuid": 1,
@@ -65,21 +105,13 @@ func LongConnResults(res *resources.Resources, thresh int, limit int, noLimit bo
 		{"$sort": bson.M{"tdur": -1, "maxdur": -1}},
 	}
 
-	if !noLimit {
-		longConnQuery = append(longConnQuery, bson.M{"$limit": limit})
-	}
-
-	err := ssn.DB(res.DB.GetSelectedDB()).C(res.Config.T.Structure.UniqueConnTable).Pipe(longConnQuery).AllowDiskUse().All(&longConnResults)
-
-	return longConnResults, err
-
+	return longConnQuery, nil
 }
 
 // OpenConnResults returns open connections. The results will be sorted, descending by duration.
 // limit and noLimit control how many results are returned.
 func OpenConnResults(res *resources.Resources, thresh int, limit int, noLimit bool) ([]OpenConnResult, error) {
-	ssn := res.DB.Session.Copy()
-	defer ssn.Close()
+	ctx := res.DB.Context()
 
 	var openConnResults []OpenConnResult
 
@@ -118,7 +150,7 @@ func OpenConnResults(res *resources.Resources, thresh int, limit int, noLimit bo
 		openConnQuery = append(openConnQuery, bson.M{"$limit": limit})
 	}
 
-	err := ssn.DB(res.DB.GetSelectedDB()).C(res.Config.T.Structure.UniqueConnTable).Pipe(openConnQuery).AllowDiskUse().All(&openConnResults)
+	err := database.AggregateAll(ctx, res.DB.Collection(res.Config.T.Structure.UniqueConnTable), openConnQuery, &openConnResults)
 
 	return openConnResults, err
 
@@ -1,14 +1,15 @@
 package uconn
 
 import (
+	"context"
 	"sync"
 
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/pkg/data"
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/bson"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type (
@@ -55,18 +56,17 @@ func (s *summarizer) start() {
 	s.summaryWg.Add(1)
 	go func() {
 
-		ssn := s.db.Session.Copy()
-		defer ssn.Close()
+		ctx := s.db.Context()
 
 		for datum := range s.summaryChannel {
-			uconnCollection := ssn.DB(s.db.GetSelectedDB()).C(s.conf.T.Structure.UniqueConnTable)
-			hostCollection := ssn.DB(s.db.GetSelectedDB()).C(s.conf.T.Structure.HostTable)
+			uconnCollection := s.db.Collection(s.conf.T.Structure.UniqueConnTable)
+			hostCollection := s.db.Collection(s.conf.T.Structure.HostTable)
 
 			hostUpdates := []database.BulkChange{}
 
-			maxTotalDurUpdate, err := maxTotalDurationUpdate(datum, uconnCollection, hostCollection, s.chunk)
+			maxTotalDurUpdate, err := maxTotalDurationUpdate(ctx, datum, uconnCollection, hostCollection, s.chunk)
 			if err != nil {
-				if err != mgo.ErrNotFound {
+				if err != mongo.ErrNoDocuments {
 					s.log.WithFields(log.Fields{
 						"Module": "uconns",
 						"Data":   datum,
@@ -78,7 +78,7 @@ func (s *summarizer) start() {
 				hostUpdates = append(hostUpdates, maxTotalDurUpdate)
 			}
 
-			invalidCertUpdates, err := invalidCertUpdates(datum, uconnCollection, hostCollection, s.chunk)
+			invalidCertUpdates, err := invalidCertUpdates(ctx, datum, uconnCollection, hostCollection, s.chunk)
 			if err != nil {
 				s.log.WithFields(log.Fields{
 					"Module": "uconns",
@@ -98,7 +98,7 @@ func (s *summarizer) start() {
 	}()
 }
 
-func maxTotalDurationUpdate(datum data.UniqueIP, uconnColl, hostColl *mgo.Collection, chunk int) (database.BulkChange, error) {
+func maxTotalDurationUpdate(ctx context.Context, datum data.UniqueIP, uconnColl, hostColl *mongo.Collection, chunk int) (database.BulkChange, error) {
 	var maxDurIP struct {
 		Peer        data.UniqueIP `bson:"peer"`
 		MaxTotalDur float64       `bson:"tdur"`
@@ -106,7 +106,7 @@ func maxTotalDurationUpdate(datum data.UniqueIP, uconnColl, hostColl *mgo.Collec
 
 	mdipQuery := maxTotalDurationPipeline(datum)
 
-	err := uconnColl.Pipe(mdipQuery).One(&maxDurIP)
+	err := database.AggregateOne(ctx, uconnColl, mdipQuery, &maxDurIP)
 	if err != nil {
 		return database.BulkChange{}, err
 	}
@@ -117,19 +117,16 @@ func maxTotalDurationUpdate(datum data.UniqueIP, uconnColl, hostColl *mgo.Collec
 		bson.M{"dat": bson.M{"$elemMatch": bson.M{"mdip": bson.M{"$exists": true}}}},
 	)
 
-	nExistingEntries, err := hostColl.Find(hostWithDatEntrySelector).Count()
+	existingEntry, err := database.FindDatEntry(ctx, hostColl, hostWithDatEntrySelector)
 	if err != nil {
 		return database.BulkChange{}, err
 	}
 
-	if nExistingEntries > 0 {
-		updateQuery := bson.M{
-			"$set": bson.M{
-				"dat.$.mdip":         maxDurIP.Peer,
-				"dat.$.max_duration": maxDurIP.MaxTotalDur,
-				"dat.$.cid":          chunk,
-			},
-		}
+	if existingEntry != nil {
+		updateQuery := database.MoveDatEntry(existingEntry, bson.M{
+			"mdip":         maxDurIP.Peer,
+			"max_duration": maxDurIP.MaxTotalDur,
+		}, chunk)
 		return database.BulkChange{Selector: hostWithDatEntrySelector, Update: updateQuery, Upsert: true}, nil
 	}
 
@@ -208,30 +205,34 @@ func maxTotalDurationPipeline(host data.UniqueIP) []bson.M {
 	}
 }
 
-func invalidCertUpdates(datum data.UniqueIP, uconnColl *mgo.Collection, hostColl *mgo.Collection, chunk int) ([]database.BulkChange, error) {
+func invalidCertUpdates(ctx context.Context, datum data.UniqueIP, uconnColl *mongo.Collection, hostColl *mongo.Collection, chunk int) ([]database.BulkChange, error) {
 
 	var updates []database.BulkChange
 
 	icertQuery := invalidCertPipeline(datum, chunk)
-	var icertPeer data.UniqueIP
-	icertPeerIter := uconnColl.Pipe(icertQuery).Iter()
-	for icertPeerIter.Next(&icertPeer) {
+	icertPeerIter, err := uconnColl.Aggregate(ctx, icertQuery)
+	if err != nil {
+		return updates, err
+	}
+	defer icertPeerIter.Close(ctx)
+
+	for icertPeerIter.Next(ctx) {
+		var icertPeer data.UniqueIP
+		if err := icertPeerIter.Decode(&icertPeer); err != nil {
+			return updates, err
+		}
 		hostEntryExistsSelector := datum.BSONKey()
 		hostEntryExistsSelector["dat"] = bson.M{"$elemMatch": icertPeer.PrefixedBSONKey("icdst")}
-		nExistingEntries, err := hostColl.Find(hostEntryExistsSelector).Count()
+		existingEntry, err := database.FindDatEntry(ctx, hostColl, hostEntryExistsSelector)
 		if err != nil {
 			return updates, err
 		}
 
-		if nExistingEntries > 0 {
+		if existingEntry != nil {
 			updates = append(updates, database.BulkChange{
 				Selector: hostEntryExistsSelector,
-				Update: bson.M{
-					"$set": bson.M{
-						"dat.$.cid": chunk,
-					},
-				},
-				Upsert: true,
+				Update:   database.MoveDatEntry(existingEntry, bson.M{}, chunk),
+				Upsert:   true,
 			})
 		} else {
 			updates = append(updates, database.BulkChange{
@@ -249,7 +250,7 @@ func invalidCertUpdates(datum data.UniqueIP, uconnColl *mgo.Collection, hostColl
 			})
 		}
 	}
-	if err := icertPeerIter.Close(); err != nil {
+	if err := icertPeerIter.Err(); err != nil {
 		return updates, err
 	}
 
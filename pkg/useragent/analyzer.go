@@ -6,7 +6,7 @@ import (
 
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 // rareSignatureOrigIPsCutoff determines the cutoff for marking a particular IP as having used
@@ -30,7 +30,7 @@ type (
 )
 
 // newAnalyzer creates a new analyzer for recording connections that were made
-// with HTTP useragents and TLS JA3 hashes
+// with HTTP useragents, TLS JA3 hashes, and SSH HASSH fingerprints
 func newAnalyzer(chunk int, db *database.DB, conf *config.Config, analyzedCallback func(database.BulkChanges), closedCallback func()) *analyzer {
 	return &analyzer{
 		chunk:            chunk,
@@ -59,9 +59,6 @@ func (a *analyzer) close() {
 func (a *analyzer) start() {
 	a.analysisWg.Add(1)
 	go func() {
-		ssn := a.db.Session.Copy()
-		defer ssn.Close()
-
 		for datum := range a.analysisChannel {
 			useragentsSelector := bson.M{"user_agent": datum.Name}
 			useragentsQuery := useragentsQuery(datum, a.chunk)
@@ -101,6 +98,6 @@ func useragentsQuery(datum *Input, chunk int) bson.M {
 			},
 		},
 		"$set":         bson.M{"cid": chunk},
-		"$setOnInsert": bson.M{"ja3": datum.JA3},
+		"$setOnInsert": bson.M{"ja3": datum.JA3, "hassh": datum.HASSH},
 	}
 }
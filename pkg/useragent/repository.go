@@ -18,11 +18,12 @@ type Input struct {
 	OrigIps  data.UniqueIPSet
 	Requests data.StringSet
 	JA3      bool
+	HASSH    bool
 }
 
 // Result represents a user agent and how many times that user agent
 // was seen in the dataset
 type Result struct {
-	UserAgent string `bson:"user_agent"`
-	TimesUsed int64  `bson:"seen"`
+	UserAgent string `bson:"user_agent" json:"user_agent"`
+	TimesUsed int64  `bson:"seen" json:"seen"`
 }
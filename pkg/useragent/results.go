@@ -1,8 +1,9 @@
 package useragent
 
 import (
+	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/resources"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 //Results returns useragents sorted by how many times each useragent was
@@ -10,12 +11,23 @@ import (
 //sorted in descending (sortDirection=-1) or ascending order (sortDirection=1).
 //limit and noLimit control how many results are returned.
 func Results(res *resources.Resources, sortDirection, limit int, noLimit bool) ([]Result, error) {
-	ssn := res.DB.Session.Copy()
-	defer ssn.Close()
-
 	var useragentResults []Result
 
-	useragentQuery := []bson.M{
+	useragentQuery := ResultsQuery(sortDirection)
+
+	if !noLimit {
+		useragentQuery = append(useragentQuery, bson.M{"$limit": limit})
+	}
+
+	err := database.AggregateAll(res.DB.Context(), res.DB.Collection(res.Config.T.UserAgent.UserAgentTable), useragentQuery, &useragentResults)
+
+	return useragentResults, err
+}
+
+//ResultsQuery builds the aggregation pipeline used by Results, sorted by how many times
+//each useragent was seen ordered by sortDirection
+func ResultsQuery(sortDirection int) []bson.M {
+	return []bson.M{
 		{"$project": bson.M{"user_agent": 1, "seen": "$dat.seen"}},
 		{"$unwind": "$seen"},
 		{"$group": bson.M{
@@ -29,13 +41,4 @@ func Results(res *resources.Resources, sortDirection, limit int, noLimit bool) (
 		}},
 		{"$sort": bson.M{"seen": sortDirection}},
 	}
-
-	if !noLimit {
-		useragentQuery = append(useragentQuery, bson.M{"$limit": limit})
-	}
-
-	err := ssn.DB(res.DB.GetSelectedDB()).C(res.Config.T.UserAgent.UserAgentTable).Pipe(useragentQuery).AllowDiskUse().All(&useragentResults)
-
-	return useragentResults, err
-
 }
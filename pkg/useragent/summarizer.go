@@ -1,14 +1,15 @@
 package useragent
 
 import (
+	"context"
 	"sync"
 
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 	"github.com/activecm/rita/pkg/data"
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/bson"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type (
@@ -55,14 +56,13 @@ func (s *summarizer) start() {
 	s.summaryWg.Add(1)
 	go func() {
 
-		ssn := s.db.Session.Copy()
-		defer ssn.Close()
+		ctx := s.db.Context()
 
 		for datum := range s.summaryChannel {
-			useragentCollection := ssn.DB(s.db.GetSelectedDB()).C(s.conf.T.UserAgent.UserAgentTable)
-			hostCollection := ssn.DB(s.db.GetSelectedDB()).C(s.conf.T.Structure.HostTable)
+			useragentCollection := s.db.Collection(s.conf.T.UserAgent.UserAgentTable)
+			hostCollection := s.db.Collection(s.conf.T.Structure.HostTable)
 
-			rareSignatures, err := getRareSignaturesForIP(useragentCollection, datum, s.chunk)
+			rareSignatures, err := getRareSignaturesForIP(ctx, useragentCollection, datum, s.chunk)
 			if err != nil {
 				s.log.WithFields(log.Fields{
 					"Module": "useragent",
@@ -74,7 +74,7 @@ func (s *summarizer) start() {
 				continue // nothing to update
 			}
 
-			rareSignatureUpdates, err := rareSignatureUpdates(datum, rareSignatures, hostCollection, s.chunk)
+			rareSignatureUpdates, err := rareSignatureUpdates(ctx, datum, rareSignatures, hostCollection, s.chunk)
 			if err != nil {
 				s.log.WithFields(log.Fields{
 					"Module": "useragent",
@@ -137,7 +137,7 @@ db.getCollection('useragent').aggregate([
 
 ])
 */
-func getRareSignaturesForIP(useragentCollection *mgo.Collection, host data.UniqueIP, chunk int) ([]string, error) {
+func getRareSignaturesForIP(ctx context.Context, useragentCollection *mongo.Collection, host data.UniqueIP, chunk int) ([]string, error) {
 	query := []bson.M{
 		{"$match": bson.M{
 			"dat": bson.M{
@@ -182,13 +182,21 @@ func getRareSignaturesForIP(useragentCollection *mgo.Collection, host data.Uniqu
 	}
 
 	var aggResults []string
-	var aggResult Result
-	aggIter := useragentCollection.Pipe(query).Iter()
-	for aggIter.Next(&aggResult) {
+	aggIter, err := useragentCollection.Aggregate(ctx, query)
+	if err != nil {
+		return []string{}, err
+	}
+	defer aggIter.Close(ctx)
+
+	for aggIter.Next(ctx) {
+		var aggResult Result
+		if err := aggIter.Decode(&aggResult); err != nil {
+			return []string{}, err
+		}
 		aggResults = append(aggResults, aggResult.UserAgent)
 
 	}
-	if aggIter.Err() != nil && aggIter.Err() != mgo.ErrNotFound {
+	if aggIter.Err() != nil {
 		return []string{}, aggIter.Err()
 	}
 	return aggResults, nil
@@ -197,26 +205,29 @@ func getRareSignaturesForIP(useragentCollection *mgo.Collection, host data.Uniqu
 // rareSignatureUpdates formats a MongoDB update for an internal host which either inserts a
 // new rare signature host records into that host's dat array in the host collection or updates the
 // existing records in the host's dat array for each rare signature with the current chunk id.
-func rareSignatureUpdates(rareSigIP data.UniqueIP, newSignatures []string, hostCollection *mgo.Collection, chunk int) ([]database.BulkChange, error) {
+func rareSignatureUpdates(ctx context.Context, rareSigIP data.UniqueIP, newSignatures []string, hostCollection *mongo.Collection, chunk int) ([]database.BulkChange, error) {
 	var updates []database.BulkChange
 
 	existingRareSignaturesQuery := []bson.M{
 		{"$match": rareSigIP.BSONKey()},
 		{"$unwind": "$dat"},
 		{"$match": bson.M{"dat.rsig": bson.M{"$exists": true}}},
-		{"$project": bson.M{"user_agent": "$dat.rsig"}},
+		{"$project": bson.M{"user_agent": "$dat.rsig", "entry": "$dat"}},
 	}
 
-	var existingSigs []Result
-	err := hostCollection.Pipe(existingRareSignaturesQuery).AllowDiskUse().All(&existingSigs)
+	var existingSigs []struct {
+		UserAgent string   `bson:"user_agent"`
+		Entry     bson.Raw `bson:"entry"`
+	}
+	err := database.AggregateAll(ctx, hostCollection, existingRareSignaturesQuery, &existingSigs)
 	if err != nil {
 		return updates, err
 	}
 
 	// place existing signatures in a map to make the cross lookup fast
-	existingSigsMap := make(map[string]struct{})
+	existingSigsMap := make(map[string]bson.Raw)
 	for _, sig := range existingSigs {
-		existingSigsMap[sig.UserAgent] = struct{}{}
+		existingSigsMap[sig.UserAgent] = sig.Entry
 	}
 
 	// generate an update for each existing rare signature.
@@ -226,17 +237,13 @@ func rareSignatureUpdates(rareSigIP data.UniqueIP, newSignatures []string, hostC
 	// Normally, we could use array filters, but the bulk api doesn't
 	// support updates with array filters.
 	for _, sig := range newSignatures {
-		if _, ok := existingSigsMap[sig]; ok {
+		if existingEntry, ok := existingSigsMap[sig]; ok {
 			updates = append(updates, database.BulkChange{
 				Selector: database.MergeBSONMaps(
 					rareSigIP.BSONKey(),
 					bson.M{"dat.rsig": sig},
 				),
-				Update: bson.M{
-					"$set": bson.M{
-						"dat.$.cid": chunk,
-					},
-				},
+				Update: database.MoveDatEntry(existingEntry, bson.M{}, chunk),
 				Upsert: true,
 			})
 		}
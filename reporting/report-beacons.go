@@ -6,11 +6,14 @@ import (
 	"os"
 
 	"github.com/activecm/rita/pkg/beacon"
+	"github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/pkg/lease"
+	"github.com/activecm/rita/pkg/logon"
 	"github.com/activecm/rita/reporting/templates"
 	"github.com/activecm/rita/resources"
 )
 
-func printBeacons(db string, showNetNames bool, res *resources.Resources, logsGeneratedAt string) error {
+func printBeacons(db string, showNetNames bool, devices *lease.Timeline, users *logon.Timeline, res *resources.Resources, logsGeneratedAt string) error {
 	var w string
 	f, err := os.Create("beacons.html")
 	if err != nil {
@@ -30,7 +33,7 @@ func printBeacons(db string, showNetNames bool, res *resources.Resources, logsGe
 		return err
 	}
 
-	data, err := beacon.Results(res, 0)
+	data, err := beacon.Results(res, 0, filter.Filter{})
 	if err != nil {
 		return err
 	}
@@ -38,27 +41,34 @@ func printBeacons(db string, showNetNames bool, res *resources.Resources, logsGe
 	if len(data) == 0 {
 		w = ""
 	} else {
-		w, err = getBeaconWriter(data, showNetNames)
+		w, err = getBeaconWriter(data, showNetNames, devices, users)
 		if err != nil {
 			return err
 		}
 	}
 
-	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt})
+	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt, ShowDevices: devices != nil, ShowUsers: users != nil})
 }
 
-func getBeaconWriter(beacons []beacon.Result, showNetNames bool) (string, error) {
+func getBeaconWriter(beacons []beacon.Result, showNetNames bool, devices *lease.Timeline, users *logon.Timeline) (string, error) {
 	tmpl := "<tr>"
 
 	tmpl += "<td>{{printf \"%.3f\" .Score}}</td>"
 
 	if showNetNames {
-		tmpl += "<td>{{.SrcNetworkName}}</td><td>{{.DstNetworkName}}</td><td>{{.SrcIP}}</td><td>{{.DstIP}}</td>"
+		tmpl += "<td>{{.SrcNetworkName}}</td><td>{{.DstNetworkName}}</td><td>{{.SrcIP}}</td>"
 	} else {
-		tmpl += "<td>{{.SrcIP}}</td><td>{{.DstIP}}</td>"
+		tmpl += "<td>{{.SrcIP}}</td>"
 	}
+	if devices != nil {
+		tmpl += "<td>{{.Devices}}</td>"
+	}
+	if users != nil {
+		tmpl += "<td>{{.Users}}</td>"
+	}
+	tmpl += "<td>{{.DstIP}}</td>"
 	tmpl += "<td>{{.Connections}}</td><td>{{printf \"%.3f\" .AvgBytes}}</td><td>{{.TotalBytes}}</td><td>{{printf \"%.3f\" .Ts.Score}}</td>"
-	tmpl += "<td>{{printf \"%.3f\" .Ds.Score}}</td><td>{{printf \"%.3f\" .DurScore}}</td><td>{{printf \"%.3f\" .HistScore}}</td><td>{{.Ts.Mode}}</td>"
+	tmpl += "<td>{{printf \"%.3f\" .Ds.Score}}</td><td>{{printf \"%.3f\" .DurScore}}</td><td>{{printf \"%.3f\" .HistScore}}</td><td>{{printf \"%.3f\" .PeriodicityScore}}</td><td>{{.Ts.Mode}}</td>"
 	tmpl += "</tr>\n"
 
 	out, err := template.New("beacon").Parse(tmpl)
@@ -69,7 +79,17 @@ func getBeaconWriter(beacons []beacon.Result, showNetNames bool) (string, error)
 	w := new(bytes.Buffer)
 
 	for _, result := range beacons {
-		err = out.Execute(w, result)
+		row := struct {
+			beacon.Result
+			Devices string
+			Users   string
+		}{
+			result,
+			sourceDevices(devices, result.Ts.First, result.Ts.Last, result.UniqueSrcIP.Unpair()),
+			sourceUsers(users, result.UniqueSrcIP.Unpair()),
+		}
+
+		err = out.Execute(w, row)
 		if err != nil {
 			return "", err
 		}
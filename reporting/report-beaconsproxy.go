@@ -6,11 +6,14 @@ import (
 	"os"
 
 	"github.com/activecm/rita/pkg/beaconproxy"
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/pkg/lease"
 	"github.com/activecm/rita/reporting/templates"
 	"github.com/activecm/rita/resources"
 )
 
-func printBeaconsProxy(db string, showNetNames bool, res *resources.Resources, logsGeneratedAt string) error {
+func printBeaconsProxy(db string, showNetNames bool, devices *lease.Timeline, res *resources.Resources, logsGeneratedAt string) error {
 	var w string
 	f, err := os.Create("beaconsproxy.html")
 	if err != nil {
@@ -30,7 +33,7 @@ func printBeaconsProxy(db string, showNetNames bool, res *resources.Resources, l
 		return err
 	}
 
-	data, err := beaconproxy.Results(res, 0)
+	data, err := beaconproxy.Results(res, 0, filter.Filter{})
 	if err != nil {
 		return err
 	}
@@ -38,16 +41,16 @@ func printBeaconsProxy(db string, showNetNames bool, res *resources.Resources, l
 	if len(data) == 0 {
 		w = ""
 	} else {
-		w, err = getBeaconProxyWriter(data, showNetNames)
+		w, err = getBeaconProxyWriter(data, showNetNames, devices)
 		if err != nil {
 			return err
 		}
 	}
 
-	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt})
+	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt, ShowDevices: devices != nil})
 }
 
-func getBeaconProxyWriter(beaconsProxy []beaconproxy.Result, showNetNames bool) (string, error) {
+func getBeaconProxyWriter(beaconsProxy []beaconproxy.Result, showNetNames bool, devices *lease.Timeline) (string, error) {
 	tmpl := "<tr>"
 
 	tmpl += "<td>{{printf \"%.3f\" .Score}}</td>"
@@ -56,7 +59,13 @@ func getBeaconProxyWriter(beaconsProxy []beaconproxy.Result, showNetNames bool)
 		tmpl += "<td>{{.SrcNetworkName}}</td>"
 	}
 
-	tmpl += "<td>{{.SrcIP}}</td><td>{{.FQDN}}</td>"
+	tmpl += "<td>{{.SrcIP}}</td>"
+
+	if devices != nil {
+		tmpl += "<td>{{.Devices}}</td>"
+	}
+
+	tmpl += "<td>{{.FQDN}}</td>"
 
 	if showNetNames {
 		tmpl += "<td>{{.Proxy.NetworkName}}</td>"
@@ -65,7 +74,7 @@ func getBeaconProxyWriter(beaconsProxy []beaconproxy.Result, showNetNames bool)
 	tmpl += "<td>{{.Proxy.IP}}</td>"
 
 	tmpl += "<td>{{.Connections}}</td><td>{{printf \"%.3f\" .Ts.Score}}</td>"
-	tmpl += "<td>{{printf \"%.3f\" .DurScore}}</td><td>{{printf \"%.3f\" .HistScore}}</td><td>{{.Ts.Mode}}</td>"
+	tmpl += "<td>{{printf \"%.3f\" .DurScore}}</td><td>{{printf \"%.3f\" .HistScore}}</td><td>{{printf \"%.3f\" .PeriodicityScore}}</td><td>{{.Ts.Mode}}</td>"
 	tmpl += "</tr>\n"
 
 	out, err := template.New("beaconproxy").Parse(tmpl)
@@ -76,7 +85,13 @@ func getBeaconProxyWriter(beaconsProxy []beaconproxy.Result, showNetNames bool)
 	w := new(bytes.Buffer)
 
 	for _, result := range beaconsProxy {
-		err = out.Execute(w, result)
+		source := data.UniqueIP{IP: result.SrcIP, NetworkUUID: result.SrcNetworkUUID}
+		row := struct {
+			beaconproxy.Result
+			Devices string
+		}{result, sourceDevices(devices, result.Ts.First, result.Ts.Last, source)}
+
+		err = out.Execute(w, row)
 		if err != nil {
 			return "", err
 		}
@@ -6,11 +6,13 @@ import (
 	"os"
 
 	"github.com/activecm/rita/pkg/beaconsni"
+	"github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/pkg/lease"
 	"github.com/activecm/rita/reporting/templates"
 	"github.com/activecm/rita/resources"
 )
 
-func printBeaconsSNI(db string, showNetNames bool, res *resources.Resources, logsGeneratedAt string) error {
+func printBeaconsSNI(db string, showNetNames bool, devices *lease.Timeline, res *resources.Resources, logsGeneratedAt string) error {
 	var w string
 	f, err := os.Create("beaconssni.html")
 	if err != nil {
@@ -30,7 +32,7 @@ func printBeaconsSNI(db string, showNetNames bool, res *resources.Resources, log
 		return err
 	}
 
-	data, err := beaconsni.Results(res, 0)
+	data, err := beaconsni.Results(res, 0, filter.Filter{})
 	if err != nil {
 		return err
 	}
@@ -38,27 +40,31 @@ func printBeaconsSNI(db string, showNetNames bool, res *resources.Resources, log
 	if len(data) == 0 {
 		w = ""
 	} else {
-		w, err = getBeaconSNIWriter(data, showNetNames)
+		w, err = getBeaconSNIWriter(data, showNetNames, devices)
 		if err != nil {
 			return err
 		}
 	}
 
-	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt})
+	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt, ShowDevices: devices != nil})
 }
 
-func getBeaconSNIWriter(beaconsSNI []beaconsni.Result, showNetNames bool) (string, error) {
+func getBeaconSNIWriter(beaconsSNI []beaconsni.Result, showNetNames bool, devices *lease.Timeline) (string, error) {
 	tmpl := "<tr>"
 
 	tmpl += "<td>{{printf \"%.3f\" .Score}}</td>"
 
 	if showNetNames {
-		tmpl += "<td>{{.SrcNetworkName}}</td><td>{{.SrcIP}}</td><td>{{.FQDN}}</td>"
+		tmpl += "<td>{{.SrcNetworkName}}</td><td>{{.SrcIP}}</td>"
 	} else {
-		tmpl += "<td>{{.SrcIP}}</td><td>{{.FQDN}}</td>"
+		tmpl += "<td>{{.SrcIP}}</td>"
 	}
+	if devices != nil {
+		tmpl += "<td>{{.Devices}}</td>"
+	}
+	tmpl += "<td>{{.FQDN}}</td>"
 	tmpl += "<td>{{.Connections}}</td><td>{{printf \"%.3f\" .AvgBytes}}</td><td>{{.TotalBytes}}</td><td>{{printf \"%.3f\" .Ts.Score}}</td>"
-	tmpl += "<td>{{printf \"%.3f\" .Ds.Score}}</td><td>{{printf \"%.3f\" .DurScore}}</td><td>{{printf \"%.3f\" .HistScore}}</td><td>{{.Ts.Mode}}</td>"
+	tmpl += "<td>{{printf \"%.3f\" .Ds.Score}}</td><td>{{printf \"%.3f\" .DurScore}}</td><td>{{printf \"%.3f\" .HistScore}}</td><td>{{printf \"%.3f\" .PeriodicityScore}}</td><td>{{.Ts.Mode}}</td>"
 	tmpl += "</tr>\n"
 
 	out, err := template.New("beaconsni").Parse(tmpl)
@@ -69,7 +75,12 @@ func getBeaconSNIWriter(beaconsSNI []beaconsni.Result, showNetNames bool) (strin
 	w := new(bytes.Buffer)
 
 	for _, result := range beaconsSNI {
-		err = out.Execute(w, result)
+		row := struct {
+			beaconsni.Result
+			Devices string
+		}{result, sourceDevices(devices, result.Ts.First, result.Ts.Last, result.Unpair())}
+
+		err = out.Execute(w, row)
 		if err != nil {
 			return "", err
 		}
@@ -5,11 +5,13 @@ import (
 	"os"
 
 	"github.com/activecm/rita/pkg/blacklist"
+	"github.com/activecm/rita/pkg/lease"
+	"github.com/activecm/rita/pkg/logon"
 	"github.com/activecm/rita/reporting/templates"
 	"github.com/activecm/rita/resources"
 )
 
-func printBLDestIPs(db string, showNetNames bool, res *resources.Resources, logsGeneratedAt string) error {
+func printBLDestIPs(db string, showNetNames bool, devices *lease.Timeline, users *logon.Timeline, res *resources.Resources, logsGeneratedAt string) error {
 	f, err := os.Create("bl-dest-ips.html")
 	if err != nil {
 		return err
@@ -33,10 +35,10 @@ func printBLDestIPs(db string, showNetNames bool, res *resources.Resources, logs
 		return err
 	}
 
-	w, err := getBLIPWriter(data, showNetNames)
+	w, err := getBLIPWriter(data, showNetNames, devices, users, true)
 	if err != nil {
 		return err
 	}
 
-	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt})
+	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt, ShowDevices: devices != nil, ShowUsers: users != nil})
 }
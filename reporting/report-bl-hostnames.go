@@ -8,11 +8,12 @@ import (
 	"strings"
 
 	"github.com/activecm/rita/pkg/blacklist"
+	"github.com/activecm/rita/pkg/lease"
 	"github.com/activecm/rita/reporting/templates"
 	"github.com/activecm/rita/resources"
 )
 
-func printBLHostnames(db string, showNetNames bool, res *resources.Resources, logsGeneratedAt string) error {
+func printBLHostnames(db string, showNetNames bool, devices *lease.Timeline, res *resources.Resources, logsGeneratedAt string) error {
 	f, err := os.Create("bl-hostnames.html")
 	if err != nil {
 		return err
@@ -29,19 +30,24 @@ func printBLHostnames(db string, showNetNames bool, res *resources.Resources, lo
 		return err
 	}
 
-	w, err := getBLHostnameWriter(data, showNetNames)
+	w, err := getBLHostnameWriter(data, showNetNames, devices)
 	if err != nil {
 		return err
 	}
 
-	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt})
+	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt, ShowDevices: devices != nil})
 }
 
-func getBLHostnameWriter(results []blacklist.HostnameResult, showNetNames bool) (string, error) {
+func getBLHostnameWriter(results []blacklist.HostnameResult, showNetNames bool, devices *lease.Timeline) (string, error) {
+	var devicesCell string
+	if devices != nil {
+		devicesCell = "<td>{{.Devices}}</td>"
+	}
+
 	tmpl := "<tr><td>{{.Host}}</td><td>{{.Connections}}</td><td>{{.UniqueConnections}}</td>" +
 		"<td>{{.TotalBytes}}</td>" +
 		"<td>{{range $idx, $host := .ConnectedHostStrs}}{{if $idx}}, {{end}}{{ $host }}{{end}}</td>" +
-		"</tr>\n"
+		devicesCell + "</tr>\n"
 
 	out, err := template.New("blhostname").Parse(tmpl)
 	if err != nil {
@@ -72,7 +78,8 @@ func getBLHostnameWriter(results []blacklist.HostnameResult, showNetNames bool)
 		formattedResult := struct {
 			blacklist.HostnameResult
 			ConnectedHostStrs []string
-		}{result, connectedHostStrs}
+			Devices           string
+		}{result, connectedHostStrs, sourceDevices(devices, 0, 0, result.ConnectedHosts...)}
 
 		err := out.Execute(w, formattedResult)
 		if err != nil {
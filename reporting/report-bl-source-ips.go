@@ -8,11 +8,14 @@ import (
 	"strings"
 
 	"github.com/activecm/rita/pkg/blacklist"
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/lease"
+	"github.com/activecm/rita/pkg/logon"
 	"github.com/activecm/rita/reporting/templates"
 	"github.com/activecm/rita/resources"
 )
 
-func printBLSourceIPs(db string, showNetNames bool, res *resources.Resources, logsGeneratedAt string) error {
+func printBLSourceIPs(db string, showNetNames bool, devices *lease.Timeline, users *logon.Timeline, res *resources.Resources, logsGeneratedAt string) error {
 	f, err := os.Create("bl-source-ips.html")
 	if err != nil {
 		return err
@@ -36,26 +39,37 @@ func printBLSourceIPs(db string, showNetNames bool, res *resources.Resources, lo
 		return err
 	}
 
-	w, err := getBLIPWriter(data, showNetNames)
+	w, err := getBLIPWriter(data, showNetNames, devices, users, false)
 	if err != nil {
 		return err
 	}
 
-	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt})
+	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt, ShowDevices: devices != nil, ShowUsers: users != nil})
 }
 
-func getBLIPWriter(results []blacklist.IPResult, showNetNames bool) (string, error) {
+// getBLIPWriter writes the rows of the blacklisted IP pages. The devices and users are looked up
+// for the blacklisted hosts themselves or, if peersAreSources is set, for their peers.
+func getBLIPWriter(results []blacklist.IPResult, showNetNames bool, devices *lease.Timeline, users *logon.Timeline,
+	peersAreSources bool) (string, error) {
+	var annotationCells string
+	if devices != nil {
+		annotationCells = "<td>{{.Devices}}</td>"
+	}
+	if users != nil {
+		annotationCells += "<td>{{.Users}}</td>"
+	}
+
 	var tmpl string
 	if showNetNames {
 		tmpl = "<tr><td>{{.Host.IP}}</td><td>{{.Host.NetworkName}}</td><td>{{.Connections}}</td><td>{{.UniqueConnections}}</td>" +
 			"<td>{{.TotalBytes}}</td>" +
 			"<td>{{range $idx, $host := .ConnectedHostStrs}}{{if $idx}}, {{end}}{{ $host }}{{end}}</td>" +
-			"</tr>\n"
+			annotationCells + "</tr>\n"
 	} else {
 		tmpl = "<tr><td>{{.Host.IP}}</td><td>{{.Connections}}</td><td>{{.UniqueConnections}}</td>" +
 			"<td>{{.TotalBytes}}</td>" +
 			"<td>{{range $idx, $host := .ConnectedHostStrs}}{{if $idx}}, {{end}}{{ $host }}{{end}}</td>" +
-			"</tr>\n"
+			annotationCells + "</tr>\n"
 	}
 
 	out, err := template.New("blip").Parse(tmpl)
@@ -84,10 +98,17 @@ func getBLIPWriter(results []blacklist.IPResult, showNetNames bool) (string, err
 		}
 		sort.Strings(connectedHostStrs)
 
+		sources := []data.UniqueIP{result.Host}
+		if peersAreSources {
+			sources = result.Peers
+		}
+
 		formattedResult := struct {
 			blacklist.IPResult
 			ConnectedHostStrs []string
-		}{result, connectedHostStrs}
+			Devices           string
+			Users             string
+		}{result, connectedHostStrs, sourceDevices(devices, 0, 0, sources...), sourceUsers(users, sources...)}
 
 		err := out.Execute(w, formattedResult)
 		if err != nil {
@@ -0,0 +1,103 @@
+package reporting
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/pkg/certificate"
+	"github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/reporting/templates"
+	"github.com/activecm/rita/resources"
+)
+
+func printCertificates(db string, showNetNames bool, res *resources.Resources, logsGeneratedAt string) error {
+	f, err := os.Create("certificates.html")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	res.DB.SelectDB(db)
+
+	limit := 1000
+
+	data, err := certificate.Results(res, limit, false, filter.Filter{})
+	if err != nil {
+		return err
+	}
+
+	out, err := template.New("certificates.html").Parse(templates.CertificatesTempl)
+	if err != nil {
+		return err
+	}
+
+	w, err := getCertificatesWriter(data, showNetNames)
+	if err != nil {
+		return err
+	}
+
+	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt})
+}
+
+func getCertificatesWriter(results []certificate.Result, showNetNames bool) (string, error) {
+	tmpl := "<tr><td>{{printf \"%.3f\" .Score}}</td><td>{{.Risks}}</td><td>{{.Subject}}</td><td>{{.Issuer}}</td>"
+	tmpl += "<td>{{.SANs}}</td><td>{{.NotBefore}}</td><td>{{.NotAfter}}</td><td>{{.Key}}</td>"
+	tmpl += "<td>{{.Servers}}</td><td>{{.Seen}}</td><td>{{.Fingerprint}}</td></tr>\n"
+
+	out, err := template.New("certificates").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	w := new(bytes.Buffer)
+
+	for _, result := range results {
+		var servers []string
+		for _, server := range result.Servers {
+			if showNetNames {
+				servers = append(servers, server.NetworkName+": "+server.IP)
+			} else {
+				servers = append(servers, server.IP)
+			}
+		}
+
+		row := struct {
+			certificate.Result
+			Risks, SANs, NotBefore, NotAfter, Key, Servers string
+		}{
+			Result:    result,
+			Risks:     strings.Join(result.Risks, " "),
+			SANs:      strings.Join(result.SANs, " "),
+			NotBefore: formatCertificateTime(result.NotBefore),
+			NotAfter:  formatCertificateTime(result.NotAfter),
+			Key:       strings.TrimSpace(result.KeyType + " " + formatKeyLength(result.KeyLength)),
+			Servers:   strings.Join(servers, " "),
+		}
+
+		err := out.Execute(w, row)
+		if err != nil {
+			return "", err
+		}
+	}
+	return w.String(), nil
+}
+
+// formatCertificateTime formats the start or end of a certificate's validity window
+func formatCertificateTime(ts int64) string {
+	if ts == 0 {
+		return ""
+	}
+	return time.Unix(ts, 0).UTC().Format(time.RFC1123)
+}
+
+// formatKeyLength formats the key length of a certificate in bits
+func formatKeyLength(keyLength int) string {
+	if keyLength == 0 {
+		return ""
+	}
+	return strconv.Itoa(keyLength)
+}
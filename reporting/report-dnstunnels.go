@@ -0,0 +1,62 @@
+package reporting
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+
+	"github.com/activecm/rita/pkg/dnstunnel"
+	"github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/reporting/templates"
+	"github.com/activecm/rita/resources"
+)
+
+func printDNSTunnels(db string, showNetNames bool, res *resources.Resources, logsGeneratedAt string) error {
+	f, err := os.Create("dns-tunnels.html")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	res.DB.SelectDB(db)
+
+	limit := 1000
+
+	data, err := dnstunnel.Results(res, limit, false, filter.Filter{})
+	if err != nil {
+		return err
+	}
+
+	out, err := template.New("dns-tunnels.html").Parse(templates.DNSTunnelsTempl)
+	if err != nil {
+		return err
+	}
+
+	w, err := getDNSTunnelsWriter(data)
+	if err != nil {
+		return err
+	}
+
+	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt})
+}
+
+func getDNSTunnelsWriter(results []dnstunnel.Result) (string, error) {
+	tmpl := "<tr><td>{{printf \"%.3f\" .Score}}</td><td>{{.Domain}}</td><td>{{.Queries}}</td><td>{{.UniqueSubdomains}}</td>"
+	tmpl += "<td>{{.MaxClientSubdomains}}</td><td>{{printf \"%.3f\" .AvgEntropy}}</td><td>{{printf \"%.3f\" .AvgLabelLength}}</td>"
+	tmpl += "<td>{{.MaxLabelLength}}</td><td>{{printf \"%.3f\" .QTypeRatio}}</td><td>{{printf \"%.3f\" .BytesPerQuery}}</td></tr>\n"
+
+	out, err := template.New("dnstunnels").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	w := new(bytes.Buffer)
+
+	for _, result := range results {
+		err := out.Execute(w, result)
+		if err != nil {
+			return "", err
+		}
+	}
+	return w.String(), nil
+}
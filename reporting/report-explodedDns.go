@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/activecm/rita/pkg/explodeddns"
+	"github.com/activecm/rita/pkg/filter"
 	"github.com/activecm/rita/reporting/templates"
 	"github.com/activecm/rita/resources"
 )
@@ -21,7 +22,7 @@ func printDNS(db string, showNetNames bool, res *resources.Resources, logsGenera
 
 	limit := 1000
 
-	data, err := explodeddns.Results(res, limit, false)
+	data, err := explodeddns.Results(res, limit, false, filter.Filter{})
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,96 @@
+package reporting
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/activecm/rita/pkg/filetransfer"
+	"github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/pkg/lease"
+	"github.com/activecm/rita/pkg/logon"
+	"github.com/activecm/rita/reporting/templates"
+	"github.com/activecm/rita/resources"
+)
+
+func printFileTransfers(db string, showNetNames bool, devices *lease.Timeline, users *logon.Timeline,
+	res *resources.Resources, logsGeneratedAt string) error {
+	f, err := os.Create("file-transfers.html")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var fileTransfersTempl string
+	if showNetNames {
+		fileTransfersTempl = templates.FileTransfersNetNamesTempl
+	} else {
+		fileTransfersTempl = templates.FileTransfersTempl
+	}
+
+	out, err := template.New("file-transfers.html").Parse(fileTransfersTempl)
+	if err != nil {
+		return err
+	}
+
+	res.DB.SelectDB(db)
+
+	limit := 1000
+
+	data, err := filetransfer.Results(res, limit, false, filter.Filter{})
+	if err != nil {
+		return err
+	}
+
+	w, err := getFileTransfersWriter(data, showNetNames, devices, users)
+	if err != nil {
+		return err
+	}
+
+	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt,
+		ShowDevices: devices != nil, ShowUsers: users != nil})
+}
+
+func getFileTransfersWriter(results []filetransfer.Result, showNetNames bool, devices *lease.Timeline, users *logon.Timeline) (string, error) {
+	tmpl := "<tr><td>{{.DetectionStr}}</td><td>{{.TimeStr}}</td>"
+	if showNetNames {
+		tmpl += "<td>{{.SrcNetworkName}}</td><td>{{.DstNetworkName}}</td>"
+	}
+	tmpl += "<td>{{.SrcIP}}</td>"
+	if devices != nil {
+		tmpl += "<td>{{.Devices}}</td>"
+	}
+	if users != nil {
+		tmpl += "<td>{{.Users}}</td>"
+	}
+	tmpl += "<td>{{.DstIP}}</td><td>{{.FQDN}}</td><td>{{.Direction}}</td><td>{{.Category}}</td><td>{{.MimeType}}</td>"
+	tmpl += "<td>{{.Filename}}</td><td>{{.Bytes}}</td><td>{{.FQDNSources}}</td><td>{{.SHA256}}</td><td>{{.IOC}}</td></tr>\n"
+
+	out, err := template.New("filetransfers").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	w := new(bytes.Buffer)
+
+	for _, result := range results {
+		row := struct {
+			filetransfer.Result
+			DetectionStr, TimeStr, Devices, Users string
+		}{
+			Result:       result,
+			DetectionStr: strings.Join(result.Detections, " "),
+			TimeStr:      time.Unix(result.TimeStamp, 0).UTC().Format(time.RFC3339),
+			Devices:      sourceDevices(devices, result.TimeStamp, result.TimeStamp, result.UniqueSrcIP.Unpair()),
+			Users:        sourceUsers(users, result.UniqueSrcIP.Unpair()),
+		}
+
+		err := out.Execute(w, row)
+		if err != nil {
+			return "", err
+		}
+	}
+	return w.String(), nil
+}
@@ -7,13 +7,16 @@ import (
 	"strings"
 	"time"
 
+	"github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/pkg/lease"
+	"github.com/activecm/rita/pkg/logon"
 	"github.com/activecm/rita/pkg/uconn"
 	"github.com/activecm/rita/reporting/templates"
 	"github.com/activecm/rita/resources"
 	"github.com/activecm/rita/util"
 )
 
-func printLongConns(db string, showNetNames bool, res *resources.Resources, logsGeneratedAt string) error {
+func printLongConns(db string, showNetNames bool, devices *lease.Timeline, users *logon.Timeline, res *resources.Resources, logsGeneratedAt string) error {
 	f, err := os.Create("long-conns.html")
 	if err != nil {
 		return err
@@ -35,25 +38,33 @@ func printLongConns(db string, showNetNames bool, res *resources.Resources, logs
 	res.DB.SelectDB(db)
 
 	thresh := 60 // 1 minute
-	data, err := uconn.LongConnResults(res, thresh, 1000, false)
+	data, err := uconn.LongConnResults(res, thresh, 1000, false, filter.Filter{})
 	if err != nil {
 		return err
 	}
 
-	w, err := getLongConnWriter(data, showNetNames)
+	w, err := getLongConnWriter(data, showNetNames, devices, users)
 	if err != nil {
 		return err
 	}
 
-	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt})
+	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt, ShowDevices: devices != nil, ShowUsers: users != nil})
 }
 
-func getLongConnWriter(conns []uconn.LongConnResult, showNetNames bool) (string, error) {
+func getLongConnWriter(conns []uconn.LongConnResult, showNetNames bool, devices *lease.Timeline, users *logon.Timeline) (string, error) {
+	var annotationCells string
+	if devices != nil {
+		annotationCells = "<td>{{.Devices}}</td>"
+	}
+	if users != nil {
+		annotationCells += "<td>{{.Users}}</td>"
+	}
+
 	var tmpl string
 	if showNetNames {
-		tmpl = "<tr><td>{{.SrcNetworkName}}</td><td>{{.DstNetworkName}}</td><td>{{.SrcIP}}</td><td>{{.DstIP}}</td><td>{{.TupleStr}}</td><td>{{.TotalDurationStr}}</td><td>{{.MaxDurationStr}}</td><td>{{.ConnectionCount}}</td><td>{{.TotalBytes}}</td><td>{{.State}}</td></tr>\n"
+		tmpl = "<tr><td>{{.SrcNetworkName}}</td><td>{{.DstNetworkName}}</td><td>{{.SrcIP}}</td>" + annotationCells + "<td>{{.DstIP}}</td><td>{{.TupleStr}}</td><td>{{.TotalDurationStr}}</td><td>{{.MaxDurationStr}}</td><td>{{.ConnectionCount}}</td><td>{{.TotalBytes}}</td><td>{{.State}}</td></tr>\n"
 	} else {
-		tmpl = "<tr><td>{{.SrcIP}}</td><td>{{.DstIP}}</td><td>{{.TupleStr}}</td><td>{{.TotalDurationStr}}</td><td>{{.MaxDurationStr}}</td><td>{{.ConnectionCount}}</td><td>{{.TotalBytes}}</td><td>{{.State}}</td></tr>\n"
+		tmpl = "<tr><td>{{.SrcIP}}</td>" + annotationCells + "<td>{{.DstIP}}</td><td>{{.TupleStr}}</td><td>{{.TotalDurationStr}}</td><td>{{.MaxDurationStr}}</td><td>{{.ConnectionCount}}</td><td>{{.TotalBytes}}</td><td>{{.State}}</td></tr>\n"
 	}
 
 	out, err := template.New("Conn").Parse(tmpl)
@@ -72,12 +83,16 @@ func getLongConnWriter(conns []uconn.LongConnResult, showNetNames bool) (string,
 			TotalDurationStr string
 			MaxDurationStr   string
 			State            string
+			Devices          string
+			Users            string
 		}{
 			LongConnResult:   conn,
 			TupleStr:         strings.Join(conn.Tuples, ",  "),
 			TotalDurationStr: util.FormatDuration(time.Duration(int(conn.TotalDuration * float64(time.Second)))),
 			MaxDurationStr:   util.FormatDuration(time.Duration(int(conn.MaxDuration * float64(time.Second)))),
 			State:            state,
+			Devices:          sourceDevices(devices, 0, 0, conn.UniqueSrcIP.Unpair()),
+			Users:            sourceUsers(users, conn.UniqueSrcIP.Unpair()),
 		}
 
 		err := out.Execute(w, connTmplData)
@@ -0,0 +1,97 @@
+package reporting
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/pkg/lease"
+	"github.com/activecm/rita/pkg/sshconn"
+	"github.com/activecm/rita/reporting/templates"
+	"github.com/activecm/rita/resources"
+)
+
+func printSSH(db string, showNetNames bool, devices *lease.Timeline, res *resources.Resources, logsGeneratedAt string) error {
+	f, err := os.Create("ssh.html")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var sshTempl string
+	if showNetNames {
+		sshTempl = templates.SSHNetNamesTempl
+	} else {
+		sshTempl = templates.SSHTempl
+	}
+
+	out, err := template.New("ssh.html").Parse(sshTempl)
+	if err != nil {
+		return err
+	}
+
+	res.DB.SelectDB(db)
+
+	limit := 1000
+
+	data, err := sshconn.Results(res, limit, false, filter.Filter{})
+	if err != nil {
+		return err
+	}
+
+	w, err := getSSHWriter(data, showNetNames, devices)
+	if err != nil {
+		return err
+	}
+
+	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt, ShowDevices: devices != nil})
+}
+
+func getSSHWriter(results []sshconn.Result, showNetNames bool, devices *lease.Timeline) (string, error) {
+	tmpl := "<tr><td>{{printf \"%.3f\" .Score}}</td><td>{{.DetectionStr}}</td>"
+	if showNetNames {
+		tmpl += "<td>{{.SrcNetworkName}}</td><td>{{.DstNetworkName}}</td>"
+	}
+	tmpl += "<td>{{.SrcIP}}</td>"
+	if devices != nil {
+		tmpl += "<td>{{.Devices}}</td>"
+	}
+	tmpl += "<td>{{.DstIP}}</td><td>{{.PortStr}}</td><td>{{.Sessions}}</td>"
+	tmpl += "<td>{{.AuthAttempts}}</td><td>{{.AuthSuccesses}}</td><td>{{.FailedSessions}}</td><td>{{.SprayTargets}}</td>"
+	tmpl += "<td>{{printf \"%.3f\" .BeaconScore}}</td><td>{{.ClientStr}}</td><td>{{.HASSHStr}}</td></tr>\n"
+
+	out, err := template.New("ssh").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	w := new(bytes.Buffer)
+
+	for _, result := range results {
+		var ports []string
+		for _, port := range result.DstPorts {
+			ports = append(ports, strconv.Itoa(port))
+		}
+
+		row := struct {
+			sshconn.Result
+			DetectionStr, PortStr, ClientStr, HASSHStr, Devices string
+		}{
+			Result:       result,
+			DetectionStr: strings.Join(result.Detections, " "),
+			PortStr:      strings.Join(ports, " "),
+			ClientStr:    strings.Join(result.ClientVersions, " "),
+			HASSHStr:     strings.Join(result.HASSHs, " "),
+			Devices:      sourceDevices(devices, result.FirstSeen, result.LastSeen, result.UniqueSrcIP.Unpair()),
+		}
+
+		err := out.Execute(w, row)
+		if err != nil {
+			return "", err
+		}
+	}
+	return w.String(), nil
+}
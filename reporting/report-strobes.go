@@ -6,11 +6,13 @@ import (
 	"os"
 
 	"github.com/activecm/rita/pkg/beacon"
+	"github.com/activecm/rita/pkg/filter"
+	"github.com/activecm/rita/pkg/lease"
 	"github.com/activecm/rita/reporting/templates"
 	"github.com/activecm/rita/resources"
 )
 
-func printStrobes(db string, showNetNames bool, res *resources.Resources, logsGeneratedAt string) error {
+func printStrobes(db string, showNetNames bool, devices *lease.Timeline, res *resources.Resources, logsGeneratedAt string) error {
 	f, err := os.Create("strobes.html")
 	if err != nil {
 		return err
@@ -29,25 +31,30 @@ func printStrobes(db string, showNetNames bool, res *resources.Resources, logsGe
 		return err
 	}
 
-	data, err := beacon.StrobeResults(res, -1, 1000, false)
+	data, err := beacon.StrobeResults(res, -1, 1000, false, filter.Filter{})
 	if err != nil {
 		return err
 	}
 
-	w, err := getStrobesWriter(data, showNetNames)
+	w, err := getStrobesWriter(data, showNetNames, devices)
 	if err != nil {
 		return err
 	}
 
-	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt})
+	return out.Execute(f, &templates.ReportingInfo{DB: db, Writer: template.HTML(w), LogsGeneratedAt: logsGeneratedAt, ShowDevices: devices != nil})
 }
 
-func getStrobesWriter(strobes []beacon.StrobeResult, showNetNames bool) (string, error) {
+func getStrobesWriter(strobes []beacon.StrobeResult, showNetNames bool, devices *lease.Timeline) (string, error) {
+	var devicesCell string
+	if devices != nil {
+		devicesCell = "<td>{{.Devices}}</td>"
+	}
+
 	var tmpl string
 	if showNetNames {
-		tmpl = "<tr><td>{{.SrcNetworkName}}</td><td>{{.DstNetworkName}}</td><td>{{.SrcIP}}</td><td>{{.DstIP}}</td><td>{{.ConnectionCount}}</td></tr>\n"
+		tmpl = "<tr><td>{{.SrcNetworkName}}</td><td>{{.DstNetworkName}}</td><td>{{.SrcIP}}</td>" + devicesCell + "<td>{{.DstIP}}</td><td>{{.ConnectionCount}}</td></tr>\n"
 	} else {
-		tmpl = "<tr><td>{{.SrcIP}}</td><td>{{.DstIP}}</td><td>{{.ConnectionCount}}</td></tr>\n"
+		tmpl = "<tr><td>{{.SrcIP}}</td>" + devicesCell + "<td>{{.DstIP}}</td><td>{{.ConnectionCount}}</td></tr>\n"
 	}
 
 	out, err := template.New("Strobes").Parse(tmpl)
@@ -56,7 +63,12 @@ func getStrobesWriter(strobes []beacon.StrobeResult, showNetNames bool) (string,
 	}
 	w := new(bytes.Buffer)
 	for _, strobe := range strobes {
-		err := out.Execute(w, strobe)
+		row := struct {
+			beacon.StrobeResult
+			Devices string
+		}{strobe, sourceDevices(devices, 0, 0, strobe.UniqueSrcIP.Unpair())}
+
+		err := out.Execute(w, row)
 		if err != nil {
 			return "", err
 		}
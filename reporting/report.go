@@ -7,8 +7,12 @@ import (
 	"io/ioutil"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/activecm/rita/pkg/data"
+	"github.com/activecm/rita/pkg/lease"
+	"github.com/activecm/rita/pkg/logon"
 	htmlTempl "github.com/activecm/rita/reporting/templates"
 	"github.com/activecm/rita/resources"
 	"github.com/activecm/rita/util"
@@ -21,7 +25,7 @@ import (
 // a directory named after the selected dataset, or `rita-html-report` if
 // mupltiple were selected, within the current working directory,
 // mongodb must be running to call this command, will exit on any writing error
-func PrintHTML(dbsIn []string, showNetNames bool, noBrowser bool, res *resources.Resources) error {
+func PrintHTML(dbsIn []string, showNetNames bool, showDevices bool, showUsers bool, noBrowser bool, res *resources.Resources) error {
 	if len(dbsIn) == 0 {
 		return errors.New("no analyzed databases to report on")
 	}
@@ -74,7 +78,7 @@ func PrintHTML(dbsIn []string, showNetNames bool, noBrowser bool, res *resources
 
 	// Start db iteration
 	for k := range dbs {
-		err = writeDB(dbs[k], wd, showNetNames, res)
+		err = writeDB(dbs[k], wd, showNetNames, showDevices, showUsers, res)
 		if err != nil {
 			return err
 		}
@@ -128,7 +132,7 @@ func writeDBHomePage(db string, logsGeneratedAt string) error {
 	return out.Execute(f, htmlTempl.ReportingInfo{DB: db, LogsGeneratedAt: logsGeneratedAt})
 }
 
-func writeDB(db string, wd string, showNetNames bool, res *resources.Resources) error {
+func writeDB(db string, wd string, showNetNames bool, showDevices bool, showUsers bool, res *resources.Resources) error {
 	writeDir := wd + "/" + db
 	var err error
 
@@ -145,6 +149,26 @@ func writeDB(db string, wd string, showNetNames bool, res *resources.Resources)
 	}
 	res.DB.SelectDB(db)
 
+	// the source hosts are annotated with devices only if the DHCP leases were imported
+	var devices *lease.Timeline
+	if showDevices {
+		devices, err = lease.LoadTimeline(res, db)
+		if err != nil || devices.Empty() {
+			fmt.Println("[-] No DHCP leases were found for " + db + ", source hosts will not be annotated with devices")
+			devices = nil
+		}
+	}
+
+	// likewise, the source hosts are annotated with users only if the Kerberos or NTLM logs were imported
+	var users *logon.Timeline
+	if showUsers {
+		users, err = logon.LoadTimeline(res, db)
+		if err != nil || users.Empty() {
+			fmt.Println("[-] No user logons were found for " + db + ", source hosts will not be annotated with users")
+			users = nil
+		}
+	}
+
 	maxTime := time.Now().Format(time.RFC1123)
 
 	err = writeDBHomePage(db, maxTime)
@@ -156,40 +180,44 @@ func writeDB(db string, wd string, showNetNames bool, res *resources.Resources)
 	if err != nil {
 		fmt.Println("[-] Error writing DNS page: " + err.Error())
 	}
-	err = printBLSourceIPs(db, showNetNames, res, maxTime)
+	err = printDNSTunnels(db, showNetNames, res, maxTime)
+	if err != nil {
+		fmt.Println("[-] Error writing DNS tunnels page: " + err.Error())
+	}
+	err = printBLSourceIPs(db, showNetNames, devices, users, res, maxTime)
 	if err != nil {
 		fmt.Println("[-] Error writing blacklist-source page: " + err.Error())
 	}
-	err = printBLDestIPs(db, showNetNames, res, maxTime)
+	err = printBLDestIPs(db, showNetNames, devices, users, res, maxTime)
 	if err != nil {
 		fmt.Println("[-] Error writing blacklist-destination page: " + err.Error())
 	}
-	err = printBLHostnames(db, showNetNames, res, maxTime)
+	err = printBLHostnames(db, showNetNames, devices, res, maxTime)
 	if err != nil {
 		fmt.Println("[-] Error writing blacklist-hostnames page: " + err.Error())
 	}
 
-	err = printBeacons(db, showNetNames, res, maxTime)
+	err = printBeacons(db, showNetNames, devices, users, res, maxTime)
 	if err != nil {
 		fmt.Println("[-] Error writing beacons page: " + err.Error())
 	}
 
-	err = printBeaconsProxy(db, showNetNames, res, maxTime)
+	err = printBeaconsProxy(db, showNetNames, devices, res, maxTime)
 	if err != nil {
 		fmt.Println("[-] Error writing beaconsProxy page: " + err.Error())
 	}
 
-	err = printBeaconsSNI(db, showNetNames, res, maxTime)
+	err = printBeaconsSNI(db, showNetNames, devices, res, maxTime)
 	if err != nil {
 		fmt.Println("[-] Error writing beaconsSNI page: " + err.Error())
 	}
 
-	err = printStrobes(db, showNetNames, res, maxTime)
+	err = printStrobes(db, showNetNames, devices, res, maxTime)
 	if err != nil {
 		fmt.Println("[-] Error writing strobes page: " + err.Error())
 	}
 
-	err = printLongConns(db, showNetNames, res, maxTime)
+	err = printLongConns(db, showNetNames, devices, users, res, maxTime)
 	if err != nil {
 		fmt.Println("[-] Error writing long connections page: " + err.Error())
 	}
@@ -197,6 +225,18 @@ func writeDB(db string, wd string, showNetNames bool, res *resources.Resources)
 	if err != nil {
 		fmt.Println("[-] Error writing user agents page: " + err.Error())
 	}
+	err = printCertificates(db, showNetNames, res, maxTime)
+	if err != nil {
+		fmt.Println("[-] Error writing certificates page: " + err.Error())
+	}
+	err = printSSH(db, showNetNames, devices, res, maxTime)
+	if err != nil {
+		fmt.Println("[-] Error writing SSH page: " + err.Error())
+	}
+	err = printFileTransfers(db, showNetNames, devices, users, res, maxTime)
+	if err != nil {
+		fmt.Println("[-] Error writing file transfers page: " + err.Error())
+	}
 
 	err = os.Chdir("..")
 	if err != nil {
@@ -205,3 +245,13 @@ func writeDB(db string, wd string, showNetNames bool, res *resources.Resources)
 
 	return nil
 }
+
+// sourceDevices names the devices which held the IP addresses of the source hosts between from and to
+func sourceDevices(devices *lease.Timeline, from, to int64, hosts ...data.UniqueIP) string {
+	return strings.Join(devices.Names(hosts, from, to), " ")
+}
+
+// sourceUsers names the accounts which authenticated from the source hosts during the dataset
+func sourceUsers(users *logon.Timeline, hosts ...data.UniqueIP) string {
+	return strings.Join(users.Names(hosts), " ")
+}
@@ -1,6 +1,7 @@
 package resources
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -10,7 +11,10 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/activecm/rita/config"
+	"github.com/activecm/rita/database"
 	"github.com/rifflock/lfshook"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 //DayFormat stores a correctly formatted timestamp for the day
@@ -62,3 +66,48 @@ func addFileLogger(logger *log.Logger, logPath string) {
 		log.PanicLevel: path.Join(logPath, logFile),
 	}, nil))
 }
+
+// dbLogHook is a logrus hook which places log entries into a MongoDB collection
+type dbLogHook struct {
+	collection *mongo.Collection
+}
+
+// newDBLogHook readies a hook to place log entries inside of the given collection
+func newDBLogHook(db *database.DB, dbName, collection string) *dbLogHook {
+	return &dbLogHook{collection: db.Client.Database(dbName).Collection(collection)}
+}
+
+// Fire places a logrus entry into the log collection
+func (h *dbLogHook) Fire(entry *log.Entry) error {
+	data := bson.M{
+		"Level":   entry.Level,
+		"Time":    entry.Time,
+		"Message": entry.Message,
+	}
+	for k, v := range entry.Data {
+		if errData, isError := v.(error); log.ErrorKey == k && v != nil && isError {
+			data[k] = errData.Error()
+		} else {
+			data[k] = v
+		}
+	}
+
+	// log entries are still recorded while the database operations are being cancelled
+	_, err := h.collection.InsertOne(context.Background(), data)
+	if err != nil {
+		return fmt.Errorf("Failed to send log entry to mongodb: %v", err)
+	}
+	return nil
+}
+
+// Levels returns the logrus levels the hook supports
+func (h *dbLogHook) Levels() []log.Level {
+	return []log.Level{
+		log.PanicLevel,
+		log.FatalLevel,
+		log.ErrorLevel,
+		log.WarnLevel,
+		log.InfoLevel,
+		log.DebugLevel,
+	}
+}
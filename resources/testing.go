@@ -6,15 +6,19 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/activecm/mgorus"
 	"github.com/activecm/rita/config"
 	"github.com/activecm/rita/database"
 )
 
+//testMongoDBURIEnv names the environment variable holding the URI of the MongoDB
+//server to test against. Tests keep their databases in memory if it is not set.
+const testMongoDBURIEnv = "RITA_TEST_MONGODB_URI"
+
 //InitIntegrationTestingResources creates a default testing
 //resource bundle for use with integration testing.
 //The MongoDB server is contacted via the URI provided
-//as by go test -args [MongoDB URI].
+//as by go test -args [MongoDB URI]. Without a URI the databases
+//are kept in memory by the embedded storage backend.
 func InitIntegrationTestingResources(t *testing.T) *Resources {
 	if testing.Short() {
 		t.Skip()
@@ -23,7 +27,7 @@ func InitIntegrationTestingResources(t *testing.T) *Resources {
 	mongoURI := os.Args[len(os.Args)-1]
 
 	if !strings.Contains(mongoURI, "mongodb://") {
-		t.Fatal("-args [MongoDB URI] is required to run RITA integration tests with go test")
+		mongoURI = os.Getenv(testMongoDBURIEnv)
 	}
 
 	conf, err := config.LoadTestingConfig(mongoURI)
@@ -41,13 +45,13 @@ func InitIntegrationTestingResources(t *testing.T) *Resources {
 	}
 
 	// Allows code to create and remove tracked databases
-	metaDB := database.NewMetaDB(conf, db.Session, log)
+	metaDB := database.NewMetaDB(conf, db, log)
 
 	//Begin logging to the metadatabase
 	if conf.S.Log.LogToDB {
 		log.Hooks.Add(
-			mgorus.NewHookerFromSession(
-				db.Session, conf.S.MongoDB.MetaDB, conf.T.Log.RitaLogTable,
+			newDBLogHook(
+				db, conf.S.MongoDB.MetaDB, conf.T.Log.RitaLogTable,
 			),
 		)
 	}
@@ -64,9 +68,11 @@ func InitIntegrationTestingResources(t *testing.T) *Resources {
 
 //InitTestResources creates a default testing
 //resource bundle for use with integration testing.
+//The MongoDB server given by RITA_TEST_MONGODB_URI is used if
+//it is set, otherwise the databases are kept in memory.
 func InitTestResources() *Resources {
 
-	conf, err := config.LoadTestingConfig("mongodb://localhost:27017")
+	conf, err := config.LoadTestingConfig(os.Getenv(testMongoDBURIEnv))
 	if err != nil {
 		fmt.Println(err)
 		return nil
@@ -83,13 +89,13 @@ func InitTestResources() *Resources {
 	}
 
 	// Allows code to create and remove tracked databases
-	metaDB := database.NewMetaDB(conf, db.Session, log)
+	metaDB := database.NewMetaDB(conf, db, log)
 
 	//Begin logging to the metadatabase
 	if conf.S.Log.LogToDB {
 		log.Hooks.Add(
-			mgorus.NewHookerFromSession(
-				db.Session, conf.S.MongoDB.MetaDB, conf.T.Log.RitaLogTable,
+			newDBLogHook(
+				db, conf.S.MongoDB.MetaDB, conf.T.Log.RitaLogTable,
 			),
 		)
 	}
@@ -0,0 +1,74 @@
+package util
+
+import "math"
+
+//Autocorrelation returns the autocorrelation of series at lags 0 through len(series)-1.
+//The series is zero padded and transformed with an FFT so that, by the Wiener-Khinchin
+//theorem, the inverse transform of its power spectrum yields the (unnormalized)
+//autocorrelation without wrapping around.
+func Autocorrelation(series []float64) []float64 {
+	size := 1
+	for size < 2*len(series) {
+		size <<= 1
+	}
+
+	spectrum := make([]complex128, size)
+	for i, value := range series {
+		spectrum[i] = complex(value, 0)
+	}
+
+	fft(spectrum, false)
+
+	// replace each frequency with its power
+	for i, value := range spectrum {
+		spectrum[i] = complex(real(value)*real(value)+imag(value)*imag(value), 0)
+	}
+
+	fft(spectrum, true)
+
+	acf := make([]float64, len(series))
+	for i := range acf {
+		acf[i] = real(spectrum[i]) / float64(size)
+	}
+	return acf
+}
+
+//fft performs an in place radix-2 fast Fourier transform. The length of x
+//must be a power of two. The inverse transform is left unscaled.
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+
+	// reorder the input into bit reversed order
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+
+	// combine the transforms of successively larger blocks
+	for length := 2; length <= n; length <<= 1 {
+		angle := sign * 2 * math.Pi / float64(length)
+		step := complex(math.Cos(angle), math.Sin(angle))
+		half := length / 2
+		for start := 0; start < n; start += length {
+			w := complex(1, 0)
+			for k := 0; k < half; k++ {
+				u := x[start+k]
+				v := x[start+k+half] * w
+				x[start+k] = u + v
+				x[start+k+half] = u - v
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutocorrelation(t *testing.T) {
+	series := []float64{3, -1, 4, 1, -5, 9, 2, -6, 5}
+
+	acf := Autocorrelation(series)
+
+	assert.Len(t, acf, len(series))
+	for lag := range series {
+		expected := 0.0
+		for i := 0; i+lag < len(series); i++ {
+			expected += series[i] * series[i+lag]
+		}
+		assert.InDelta(t, expected, acf[lag], 1e-9, "lag %d", lag)
+	}
+}
@@ -110,6 +110,25 @@ func Int64InSlice(value int64, list []int64) bool {
 	return false
 }
 
+//ShannonEntropy returns the Shannon entropy of the characters in a string in bits per character
+func ShannonEntropy(value string) float64 {
+	if len(value) == 0 {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, char := range value {
+		counts[char]++
+		total++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
 const (
 	day  = time.Minute * 60 * 24
 	year = 365 * day
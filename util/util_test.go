@@ -108,3 +108,20 @@ func TestStringInSlice(t *testing.T) {
 	}
 
 }
+
+func TestShannonEntropy(t *testing.T) {
+	tables := []struct {
+		val string
+		out float64
+	}{
+		{"", 0},
+		{"aaaa", 0},
+		{"ab", 1},
+		{"abcd", 2},
+		{"aabb", 1},
+	}
+
+	for _, test := range tables {
+		require.InDelta(t, test.out, ShannonEntropy(test.val), 0.0001)
+	}
+}